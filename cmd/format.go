@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// timeAgo describes t relative to now, e.g. "4 minutes ago" or "in 2
+// hours". Beyond a month it gives the date in the server's time zone, which
+// the pages show every time in.
+func timeAgo(t time.Time) string {
+	return relativeTime(t, time.Now())
+}
+
+// relativeTime describes t relative to now, see timeAgo
+func relativeTime(t, now time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	d := now.Sub(t)
+	future := d < 0
+	if future {
+		d = -d
+	}
+
+	var span string
+	switch {
+	case d < 45*time.Second:
+		if future {
+			return "in a few seconds"
+		}
+		return "just now"
+	case d < 90*time.Second:
+		span = "1 minute"
+	case d < 45*time.Minute:
+		span = plural(int(d.Round(time.Minute)/time.Minute), "minute", "minutes")
+	case d < 90*time.Minute:
+		span = "1 hour"
+	case d < 22*time.Hour:
+		span = plural(int(d.Round(time.Hour)/time.Hour), "hour", "hours")
+	case d < 36*time.Hour:
+		span = "1 day"
+	case d < 30*24*time.Hour:
+		span = plural(int(d.Round(24*time.Hour)/(24*time.Hour)), "day", "days")
+	default:
+		return t.In(time.Local).Format("Jan 02, 2006")
+	}
+	if future {
+		return "in " + span
+	}
+	return span + " ago"
+}
+
+// formatDuration renders a duration in milliseconds for reading: "850ms",
+// "2.3s", "1m 12s" or, from an hour on, "1h 5m"
+func formatDuration(ms interface{}) string {
+	n, _ := toInt64(ms)
+	if n < 0 {
+		n = 0
+	}
+	if n < 1000 {
+		return fmt.Sprintf("%dms", n)
+	}
+	if tenths := (n + 50) / 100; tenths < 600 {
+		return fmt.Sprintf("%d.%ds", tenths/10, tenths%10)
+	}
+	seconds := (n + 500) / 1000
+	if seconds < 3600 {
+		return fmt.Sprintf("%dm %ds", seconds/60, seconds%60)
+	}
+	minutes := (seconds + 30) / 60
+	return fmt.Sprintf("%dh %dm", minutes/60, minutes%60)
+}
+
+// plural gives n with the noun in the matching number, e.g. "1 agent" or
+// "3 agents"; n is grouped in thousands
+func plural(n interface{}, singular, plural string) string {
+	count, _ := toInt64(n)
+	noun := plural
+	if count == 1 || count == -1 {
+		noun = singular
+	}
+	return formatInt(count) + " " + noun
+}
+
+// formatInt renders an integer with thousands separators, e.g. "12,345"
+func formatInt(n interface{}) string {
+	v, ok := toInt64(n)
+	if !ok {
+		return fmt.Sprint(n)
+	}
+	digits := strconv.FormatInt(v, 10)
+	sign := ""
+	if v < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	for i := len(digits) - 3; i > 0; i -= 3 {
+		digits = digits[:i] + "," + digits[i:]
+	}
+	return sign + digits
+}
+
+// toInt64 converts any integer type, as templates hand them over, to int64
+func toInt64(v interface{}) (int64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), true
+	}
+	return 0, false
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRelativeTime(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		offset time.Duration
+		want   string
+	}{
+		{0, "just now"},
+		{44 * time.Second, "just now"},
+		{45 * time.Second, "1 minute ago"},
+		{89 * time.Second, "1 minute ago"},
+		{90 * time.Second, "2 minutes ago"},
+		{4 * time.Minute, "4 minutes ago"},
+		{44 * time.Minute, "44 minutes ago"},
+		{45 * time.Minute, "1 hour ago"},
+		{89 * time.Minute, "1 hour ago"},
+		{90 * time.Minute, "2 hours ago"},
+		{21 * time.Hour, "21 hours ago"},
+		{22 * time.Hour, "1 day ago"},
+		{35 * time.Hour, "1 day ago"},
+		{36 * time.Hour, "2 days ago"},
+		{29 * 24 * time.Hour, "29 days ago"},
+		// Future timestamps, e.g. from a skewed clock
+		{-10 * time.Second, "in a few seconds"},
+		{-5 * time.Minute, "in 5 minutes"},
+		{-3 * time.Hour, "in 3 hours"},
+		{-2 * 24 * time.Hour, "in 2 days"},
+	}
+	for _, tt := range tests {
+		if got := relativeTime(now.Add(-tt.offset), now); got != tt.want {
+			t.Errorf("relativeTime(now - %v) = %q, want %q", tt.offset, got, tt.want)
+		}
+	}
+
+	// Beyond a month the date is given in the display time zone
+	old := now.Add(-30 * 24 * time.Hour)
+	if got, want := relativeTime(old, now), old.In(time.Local).Format("Jan 02, 2006"); got != want {
+		t.Errorf("relativeTime a month back = %q, want %q", got, want)
+	}
+	if got := relativeTime(time.Time{}, now); got != "" {
+		t.Errorf("relativeTime of the zero time = %q, want empty", got)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		ms   interface{}
+		want string
+	}{
+		{0, "0ms"},
+		{850, "850ms"},
+		{999, "999ms"},
+		{1000, "1.0s"},
+		{2349, "2.3s"},
+		{2350, "2.4s"},
+		{59949, "59.9s"},
+		{59950, "1m 0s"},
+		{72000, "1m 12s"},
+		{3599499, "59m 59s"},
+		{3599500, "1h 0m"},
+		{3900000, "1h 5m"},
+		{int64(90000000), "25h 0m"},
+		{uint32(1500), "1.5s"},
+		{-20, "0ms"},
+		{"1500", "0ms"},
+	}
+	for _, tt := range tests {
+		if got := formatDuration(tt.ms); got != tt.want {
+			t.Errorf("formatDuration(%v) = %q, want %q", tt.ms, got, tt.want)
+		}
+	}
+}
+
+func TestPluralAndFormatInt(t *testing.T) {
+	pluralTests := []struct {
+		n    interface{}
+		want string
+	}{
+		{0, "0 agents"},
+		{1, "1 agent"},
+		{2, "2 agents"},
+		{int64(-1), "-1 agent"},
+		{12345, "12,345 agents"},
+	}
+	for _, tt := range pluralTests {
+		if got := plural(tt.n, "agent", "agents"); got != tt.want {
+			t.Errorf("plural(%v) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+
+	intTests := []struct {
+		n    interface{}
+		want string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1,000"},
+		{-1234567, "-1,234,567"},
+		{uint64(10000000000), "10,000,000,000"},
+		{"n/a", "n/a"},
+	}
+	for _, tt := range intTests {
+		if got := formatInt(tt.n); got != tt.want {
+			t.Errorf("formatInt(%v) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFormatHelpersInTemplates(t *testing.T) {
+	tmpl := template.Must(template.New("t").Funcs(templateFuncs()).Parse(
+		`{{ plural .Agents "agent" "agents" }}, {{ formatInt .Tokens }} tokens in {{ formatDuration .ResponseTime }}, {{ timeago .CreatedAt }}`))
+	var b strings.Builder
+	data := map[string]interface{}{"Agents": 3, "Tokens": 48210, "ResponseTime": 2300, "CreatedAt": time.Now().Add(-4 * time.Minute)}
+	if err := tmpl.Execute(&b, data); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if want := "3 agents, 48,210 tokens in 2.3s, 4 minutes ago"; b.String() != want {
+		t.Errorf("template = %q, want %q", b.String(), want)
+	}
+}
@@ -1,14 +1,25 @@
 package main
 
 import (
+	"context"
 	"court-table-ai/pkg/database"
 	"court-table-ai/pkg/handlers"
+	"court-table-ai/pkg/jobs"
 	"court-table-ai/pkg/models"
 	"court-table-ai/pkg/orchestrator"
+	"court-table-ai/pkg/seed"
+	"court-table-ai/pkg/transcript"
+	"court-table-ai/pkg/version"
+	"flag"
+	"fmt"
 	"html/template"
 	"io"
 	"log"
+	"os"
+	"reflect"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -23,7 +34,12 @@ func (t *TemplateRenderer) Render(w io.Writer, name string, data interface{}, c
 }
 
 func loadTemplates() *template.Template {
-	templ := template.New("").Funcs(template.FuncMap{
+	return template.Must(template.New("").Funcs(templateFuncs()).ParseGlob("templates/*.html"))
+}
+
+// templateFuncs returns the helpers available to every page template
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
 		"add": func(a, b int) int {
 			return a + b
 		},
@@ -33,6 +49,7 @@ func loadTemplates() *template.Template {
 			}
 			return b
 		},
+		// substr works on runes so multi-byte names are never split
 		"substr": func(s string, start int, length ...int) string {
 			runes := []rune(s)
 			if start < 0 {
@@ -42,14 +59,50 @@ func loadTemplates() *template.Template {
 				return ""
 			}
 			end := len(runes)
-			if len(length) > 0 && start+length[0] < len(runes) {
-				end = start + length[0]
+			if len(length) > 0 {
+				if length[0] <= 0 {
+					return ""
+				}
+				if start+length[0] < len(runes) {
+					end = start + length[0]
+				}
 			}
 			return string(runes[start:end])
 		},
+		// truncate shortens s to at most n runes, ending with an ellipsis
+		"truncate": func(n int, s string) string {
+			runes := []rune(s)
+			if n <= 0 {
+				return ""
+			}
+			if len(runes) <= n {
+				return s
+			}
+			return strings.TrimRightFunc(string(runes[:n-1]), unicode.IsSpace) + "…"
+		},
+		// len replaces the builtin so nil values count as empty instead of
+		// failing the render
+		"len": func(v interface{}) int {
+			rv := reflect.ValueOf(v)
+			for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+				if rv.IsNil() {
+					return 0
+				}
+				rv = rv.Elem()
+			}
+			switch rv.Kind() {
+			case reflect.String, reflect.Slice, reflect.Map, reflect.Array, reflect.Chan:
+				return rv.Len()
+			}
+			return 0
+		},
 		"upper": func(s string) string {
 			return strings.ToUpper(s)
 		},
+		"timeago":        timeAgo,
+		"formatDuration": formatDuration,
+		"plural":         plural,
+		"formatInt":      formatInt,
 		"getProviderDisplay": func(agent *models.Agent) string {
 			if agent.ProviderType != "" {
 				return strings.Title(agent.ProviderType)
@@ -67,18 +120,23 @@ func loadTemplates() *template.Template {
 			}
 			return "Custom"
 		},
+		"annotationSegments": models.AnnotationSegments,
 		"isModerator": func(agentID int64, moderatorID *int64) bool {
 			if moderatorID == nil {
 				return false
 			}
 			return agentID == *moderatorID
 		},
-	})
-
-	return template.Must(templ.ParseGlob("templates/*.html"))
+	}
 }
 
 func main() {
+	legacyJSONSlices := flag.Bool("legacy-json-slices", false, "accept comma-separated agent_ids written by old versions")
+	seedDemo := flag.Bool("seed", false, "fill an empty database with demo agents and a finished discussion (also SEED_DEMO=1)")
+	rotateKey := flag.Bool("rotate-key", false, "re-encrypt agent API tokens under the key in "+database.NewMasterKeyEnv+" and exit")
+	flag.Parse()
+	models.LegacyJSONSlices = *legacyJSONSlices
+
 	// Initialize database
 	db, err := database.NewDB("court_table_ai.db")
 	if err != nil {
@@ -91,8 +149,80 @@ func main() {
 		log.Fatal("Failed to create tables:", err)
 	}
 
+	// Agent API tokens are encrypted at rest once a master key is set
+	if encoded := os.Getenv(database.MasterKeyEnv); encoded != "" {
+		key, err := database.ParseMasterKey(encoded)
+		if err != nil {
+			log.Fatalf("Invalid %s: %v", database.MasterKeyEnv, err)
+		}
+		if err := db.SetMasterKey(key); err != nil {
+			log.Fatalf("Invalid %s: %v", database.MasterKeyEnv, err)
+		}
+	}
+	if err := db.CheckMasterKey(); err != nil {
+		log.Fatal("Cannot read agent API tokens: ", err)
+	}
+	if *rotateKey {
+		newKey, err := database.ParseMasterKey(os.Getenv(database.NewMasterKeyEnv))
+		if err != nil {
+			log.Fatalf("Invalid %s: %v", database.NewMasterKeyEnv, err)
+		}
+		n, err := db.RotateMasterKey(newKey)
+		if err != nil {
+			log.Fatal("Failed to rotate the master key: ", err)
+		}
+		log.Printf("Re-encrypted %d API tokens; start the server with %s set to the new key", n, database.MasterKeyEnv)
+		return
+	}
+	if n, err := db.EncryptTokens(); err != nil {
+		log.Fatal("Failed to encrypt API tokens: ", err)
+	} else if n > 0 {
+		log.Printf("Encrypted %d plaintext API tokens", n)
+	}
+
+	if *seedDemo || os.Getenv("SEED_DEMO") == "1" || os.Getenv("SEED_DEMO") == "true" {
+		seeded, err := seed.Demo(db)
+		if err != nil {
+			log.Fatal("Failed to seed demo data:", err)
+		}
+		if seeded {
+			log.Println("Seeded demo agents and an example discussion")
+		} else {
+			log.Println("Skipping demo seed: database is not empty or was already seeded")
+		}
+	}
+
 	// Initialize debate engine
 	debateEngine := orchestrator.NewDebateEngine(db)
+	debateEngine.StartWatchdog(context.Background(), time.Minute)
+	debateEngine.StartSubscriberReaper(context.Background(), 5*time.Second)
+
+	// Append every log entry to the transcript files when enabled in
+	// settings; self-test debates are left out
+	transcriptLog := transcript.NewLogger(db)
+	db.AfterLogInsert = func(entry *models.DiscussionLog) {
+		if !debateEngine.IsSelfTest(entry.DiscussionID) {
+			transcriptLog.Record(entry)
+		}
+	}
+	defer transcriptLog.Close()
+
+	// Background jobs report completion on the global event stream
+	jobManager := jobs.NewManager()
+	jobManager.OnFinish = func(job jobs.Job) {
+		message := fmt.Sprintf("Job %s (%s) %s", job.ID, job.Type, job.Status)
+		if job.Error != "" {
+			message += ": " + job.Error
+		}
+		debateEngine.Notify(&models.EngineEvent{
+			Type:         "job_" + job.Status,
+			DiscussionID: job.Target,
+			Message:      message,
+		})
+	}
+	if err := jobManager.ResumeDeletes(db); err != nil {
+		log.Printf("Failed to resume pending deletes: %v", err)
+	}
 
 	// Initialize Echo
 	e := echo.New()
@@ -112,9 +242,14 @@ func main() {
 
 	// Initialize handlers
 	agentHandler := handlers.NewAgentHandler(db, debateEngine)
-	discussionHandler := handlers.NewDiscussionHandler(db, debateEngine)
+	discussionHandler := handlers.NewDiscussionHandler(db, debateEngine, jobManager)
 	sseHandler := handlers.NewSSEHandler(db, debateEngine)
 	pageHandler := handlers.NewPageHandler(db)
+	adminHandler := handlers.NewAdminHandler(db, debateEngine)
+	systemHandler := handlers.NewSystemHandler(db, debateEngine)
+	jobHandler := handlers.NewJobHandler(jobManager)
+	configHandler := handlers.NewConfigHandler(db)
+	pricingHandler := handlers.NewPricingHandler(db)
 
 	// API Routes
 	api := e.Group("/api")
@@ -122,31 +257,110 @@ func main() {
 	// Agent routes
 	api.POST("/agents", agentHandler.CreateAgent)
 	api.GET("/agents", agentHandler.GetAgents)
+	api.GET("/agents/stats", agentHandler.GetAgentStats)
+	api.POST("/agents/probe", agentHandler.ProbeProvider)
+	api.GET("/stats/tokens", agentHandler.GetTokenStats)
 	api.GET("/agents/:id", agentHandler.GetAgent)
 	api.PUT("/agents/:id", agentHandler.UpdateAgent)
 	api.DELETE("/agents/:id", agentHandler.DeleteAgent)
 	api.POST("/agents/:id/ping", agentHandler.PingAgent)
+	api.POST("/agents/:id/test", agentHandler.TestAgent)
+	api.GET("/agents/:id/models", agentHandler.ListAgentModels)
 	api.POST("/agents/:id/duplicate", agentHandler.DuplicateAgent)
 
 	// Discussion routes
 	api.POST("/discussions", discussionHandler.CreateDiscussion)
 	api.GET("/discussions", discussionHandler.GetDiscussions)
+	api.GET("/presets", discussionHandler.GetPresets)
+	api.POST("/discussions/validate", discussionHandler.ValidateDiscussion)
+	api.POST("/discussions/import", discussionHandler.ImportDiscussion)
+	api.GET("/dashboard", discussionHandler.GetDashboard)
 	api.GET("/discussions/:id", discussionHandler.GetDiscussion)
+	api.POST("/discussions/:id/pause", discussionHandler.PauseDiscussion)
 	api.POST("/discussions/:id/stop", discussionHandler.StopDiscussion)
+	api.POST("/discussions/:id/resume", discussionHandler.ResumeDiscussion)
 	api.DELETE("/discussions/:id", discussionHandler.DeleteDiscussion)
 	api.POST("/discussions/:id/retry/:agentId", discussionHandler.RetryAgent)
+	api.POST("/discussions/:id/logs/:logId/retry", discussionHandler.RetryLogEntry)
+	api.POST("/discussions/:id/logs/:logId/rate", discussionHandler.RateLog)
+	api.GET("/discussions/:id/logs/:logId/trace", discussionHandler.GetLogTrace)
+	api.GET("/discussions/:id/wait", discussionHandler.WaitDiscussion)
+	api.GET("/discussions/:id/replay", discussionHandler.ReplayDiscussion)
+	api.GET("/discussions/:id/export", discussionHandler.ExportDiscussion)
+	api.GET("/discussions/:id/notes", discussionHandler.GetNotes)
+	api.POST("/discussions/:id/notes", discussionHandler.CreateNote)
+	api.PUT("/discussions/:id/notes/:noteId", discussionHandler.UpdateNote)
+	api.DELETE("/discussions/:id/notes/:noteId", discussionHandler.DeleteNote)
+	api.GET("/discussions/:id/annotations", discussionHandler.GetAnnotations)
+	api.POST("/discussions/:id/annotations", discussionHandler.CreateAnnotation)
+	api.PUT("/discussions/:id/annotations/:annotationId", discussionHandler.UpdateAnnotation)
+	api.DELETE("/discussions/:id/annotations/:annotationId", discussionHandler.DeleteAnnotation)
+	api.GET("/discussions/:id/claims", discussionHandler.GetClaims)
+	api.POST("/discussions/:id/extract-claims", discussionHandler.ExtractClaims)
+	api.GET("/discussions/:id/analysis", discussionHandler.GetAnalysis)
+	api.GET("/discussions/:id/rounds/:n", discussionHandler.GetRound)
+	api.POST("/discussions/:id/analyze", discussionHandler.Analyze)
+	api.GET("/discussions/:id/cost", discussionHandler.GetDiscussionCost)
+	api.GET("/discussions/:id/webhook/deliveries", discussionHandler.GetWebhookDeliveries)
+	api.POST("/discussions/:id/webhook/test", discussionHandler.TestDiscussionWebhook)
+	api.POST("/webhooks/test", discussionHandler.TestWebhook)
 
 	// SSE routes
 	api.GET("/discussions/:id/stream", sseHandler.StreamDiscussion)
+	api.GET("/discussions/:id/ws", sseHandler.WebSocketDiscussion)
+	api.GET("/events", sseHandler.StreamEvents)
+
+	// System routes
+	api.GET("/version", systemHandler.GetVersion)
+	api.GET("/capabilities", systemHandler.GetCapabilities)
+	api.GET("/jobs/:id", jobHandler.GetJob)
+
+	// Model pricing for cost estimates
+	api.GET("/pricing", pricingHandler.GetPricing)
+	api.POST("/pricing", pricingHandler.CreatePricing)
+	api.PUT("/pricing/:id", pricingHandler.UpdatePricing)
+	api.DELETE("/pricing/:id", pricingHandler.DeletePricing)
+
+	// Configuration export/import (settings only, no agents or discussions)
+	api.GET("/export/config", configHandler.ExportConfig)
+	api.POST("/export/config", configHandler.ImportConfig)
+
+	// Admin routes
+	api.GET("/admin/pause-provider", adminHandler.GetProviderPauses)
+	api.POST("/admin/pause-provider", adminHandler.PauseProvider)
+	api.GET("/admin/debates", adminHandler.GetDebates)
+	api.GET("/admin/watchdog", adminHandler.GetWatchdog)
+	api.PUT("/admin/watchdog", adminHandler.UpdateWatchdog)
+	api.GET("/admin/slow-call", adminHandler.GetSlowCall)
+	api.PUT("/admin/slow-call", adminHandler.UpdateSlowCall)
+	api.GET("/admin/delta-coalescing", adminHandler.GetDeltaCoalescing)
+	api.PUT("/admin/delta-coalescing", adminHandler.UpdateDeltaCoalescing)
+	api.GET("/admin/subscribers", adminHandler.GetSubscribers)
+	api.PUT("/admin/subscribers", adminHandler.UpdateSubscriberLimits)
+	api.GET("/admin/host-limits", adminHandler.GetHostLimits)
+	api.PUT("/admin/host-limits", adminHandler.UpdateHostLimits)
+	api.GET("/admin/duplicate-guard", adminHandler.GetDuplicateGuard)
+	api.PUT("/admin/duplicate-guard", adminHandler.UpdateDuplicateGuard)
+	api.GET("/admin/transcript-log", adminHandler.GetTranscriptLog)
+	api.PUT("/admin/transcript-log", adminHandler.UpdateTranscriptLog)
+	api.GET("/admin/timeouts", adminHandler.GetAgentTimeouts)
+	api.PUT("/admin/timeouts", adminHandler.UpdateAgentTimeouts)
+	api.GET("/admin/retries", adminHandler.GetAgentRetries)
+	api.PUT("/admin/retries", adminHandler.UpdateAgentRetries)
+	api.GET("/admin/alerts", adminHandler.GetAlertRules)
+	api.PUT("/admin/alerts", adminHandler.UpdateAlertRules)
+	api.GET("/alerts", adminHandler.GetAlerts)
+	api.POST("/admin/selftest", adminHandler.RunSelfTest)
 
 	// Page routes
 	e.GET("/", pageHandler.Dashboard)
 	e.GET("/agents", pageHandler.AgentsPage)
 	e.GET("/discussions", pageHandler.DiscussionsPage)
 	e.GET("/discussions/:id", pageHandler.DiscussionDetail)
+	e.GET("/discussions/:id/replay", pageHandler.DiscussionReplay)
 
 	// Start server
-	log.Println("Starting server on :8880")
+	log.Printf("Starting server v%s on :8880", version.Version)
 	if err := e.Start(":8880"); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
@@ -4,6 +4,7 @@ go 1.24.0
 
 require (
 	github.com/labstack/echo/v4 v4.15.0
+	golang.org/x/net v0.48.0
 	modernc.org/sqlite v1.46.0
 )
 
@@ -19,7 +20,6 @@ require (
 	github.com/valyala/fasttemplate v1.2.2 // indirect
 	golang.org/x/crypto v0.46.0 // indirect
 	golang.org/x/exp v0.0.0-20251023183803-a4bb9ffd2546 // indirect
-	golang.org/x/net v0.48.0 // indirect
 	golang.org/x/sys v0.39.0 // indirect
 	golang.org/x/text v0.32.0 // indirect
 	golang.org/x/time v0.14.0 // indirect
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"court-table-ai/pkg/models"
+	"database/sql"
+	"fmt"
+)
+
+// agentAlertsSQL creates the table recording agent alerts. Alerts outlive
+// their agent, so agent_id is not a foreign key and the name is kept.
+const agentAlertsSQL = `
+	CREATE TABLE IF NOT EXISTS agent_alerts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		agent_id INTEGER NOT NULL,
+		agent_name TEXT NOT NULL DEFAULT '',
+		metric TEXT NOT NULL,
+		state TEXT NOT NULL,
+		value REAL NOT NULL DEFAULT 0,
+		threshold REAL NOT NULL DEFAULT 0,
+		calls INTEGER NOT NULL DEFAULT 0,
+		message TEXT NOT NULL DEFAULT '',
+		fired_at DATETIME NOT NULL,
+		resolved_at DATETIME
+	);`
+
+// agentAlertIndexes are created alongside agent_alerts
+var agentAlertIndexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_agent_alerts_agent_metric ON agent_alerts(agent_id, metric, fired_at);",
+	"CREATE INDEX IF NOT EXISTS idx_agent_alerts_state ON agent_alerts(state);",
+}
+
+const alertColumns = `id, agent_id, agent_name, metric, state, value, threshold, calls, message, fired_at, resolved_at`
+
+// InsertAlert records a newly fired alert
+func (db *DB) InsertAlert(alert *models.Alert) error {
+	result, err := db.Exec(`
+	INSERT INTO agent_alerts (agent_id, agent_name, metric, state, value, threshold, calls, message, fired_at, resolved_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		alert.AgentID, alert.AgentName, alert.Metric, alert.State, alert.Value, alert.Threshold,
+		alert.Calls, alert.Message, alert.FiredAt, alert.ResolvedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert alert: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	alert.ID = id
+	return nil
+}
+
+// ResolveAlert marks a firing alert resolved at alert.ResolvedAt
+func (db *DB) ResolveAlert(alert *models.Alert) error {
+	_, err := db.Exec(`UPDATE agent_alerts SET state = ?, resolved_at = ? WHERE id = ?`,
+		models.AlertStateResolved, alert.ResolvedAt, alert.ID)
+	if err != nil {
+		return fmt.Errorf("failed to resolve alert: %w", err)
+	}
+	return nil
+}
+
+// GetAlerts retrieves alerts newest first, limited to one state when state is
+// not empty
+func (db *DB) GetAlerts(state string, limit int) ([]*models.Alert, error) {
+	query := `SELECT ` + alertColumns + ` FROM agent_alerts`
+	args := []interface{}{}
+	if state != "" {
+		query += ` WHERE state = ?`
+		args = append(args, state)
+	}
+	query += ` ORDER BY fired_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	return db.queryAlerts(query, args...)
+}
+
+// GetLatestAlerts returns the most recent alert of each agent and metric
+// pair, keyed by agent ID and then metric
+func (db *DB) GetLatestAlerts() (map[int64]map[string]*models.Alert, error) {
+	alerts, err := db.queryAlerts(`
+	SELECT ` + alertColumns + ` FROM agent_alerts a
+	WHERE id = (SELECT id FROM agent_alerts b WHERE b.agent_id = a.agent_id AND b.metric = a.metric
+		ORDER BY fired_at DESC, id DESC LIMIT 1)`)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[int64]map[string]*models.Alert)
+	for _, alert := range alerts {
+		if latest[alert.AgentID] == nil {
+			latest[alert.AgentID] = make(map[string]*models.Alert)
+		}
+		latest[alert.AgentID][alert.Metric] = alert
+	}
+	return latest, nil
+}
+
+func (db *DB) queryAlerts(query string, args ...interface{}) ([]*models.Alert, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alerts: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := []*models.Alert{}
+	for rows.Next() {
+		alert := &models.Alert{}
+		var resolvedAt sql.NullTime
+		if err := rows.Scan(&alert.ID, &alert.AgentID, &alert.AgentName, &alert.Metric, &alert.State, &alert.Value,
+			&alert.Threshold, &alert.Calls, &alert.Message, &alert.FiredAt, &resolvedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan alert: %w", err)
+		}
+		if resolvedAt.Valid {
+			alert.ResolvedAt = &resolvedAt.Time
+		}
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, rows.Err()
+}
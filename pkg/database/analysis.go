@@ -0,0 +1,79 @@
+package database
+
+import (
+	"court-table-ai/pkg/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// discussionAnalysisSQL creates the table holding the latest consensus
+// analysis of each discussion
+const discussionAnalysisSQL = `
+	CREATE TABLE IF NOT EXISTS discussion_analysis (
+		discussion_id INTEGER PRIMARY KEY,
+		analyst_id INTEGER NOT NULL,
+		status TEXT NOT NULL,
+		points TEXT NOT NULL DEFAULT '[]',
+		unknown_names TEXT NOT NULL DEFAULT '[]',
+		raw_text TEXT NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT '',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (discussion_id) REFERENCES discussions(id) ON DELETE CASCADE
+	);`
+
+// SaveDiscussionAnalysis stores an analysis, replacing the previous one of
+// the discussion
+func (db *DB) SaveDiscussionAnalysis(analysis *models.DiscussionAnalysis) error {
+	if analysis.Points == nil {
+		analysis.Points = models.JSONSlice[models.AnalysisPoint]{}
+	}
+	if analysis.UnknownNames == nil {
+		analysis.UnknownNames = models.JSONSlice[string]{}
+	}
+	analysis.CreatedAt = time.Now()
+
+	_, err := db.Exec(`
+	INSERT INTO discussion_analysis (discussion_id, analyst_id, status, points, unknown_names, raw_text, error, attempts, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(discussion_id) DO UPDATE SET
+		analyst_id = excluded.analyst_id, status = excluded.status, points = excluded.points,
+		unknown_names = excluded.unknown_names, raw_text = excluded.raw_text, error = excluded.error,
+		attempts = excluded.attempts, created_at = excluded.created_at`,
+		analysis.DiscussionID, analysis.AnalystID, analysis.Status, analysis.Points, analysis.UnknownNames,
+		analysis.RawText, analysis.Error, analysis.Attempts, analysis.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save discussion analysis: %w", err)
+	}
+	return nil
+}
+
+// GetDiscussionAnalysis retrieves the analysis of a discussion, or nil when
+// it has not been analyzed
+func (db *DB) GetDiscussionAnalysis(discussionID int64) (*models.DiscussionAnalysis, error) {
+	analysis := &models.DiscussionAnalysis{}
+	err := db.QueryRow(`
+	SELECT discussion_id, analyst_id, status, points, unknown_names, raw_text, error, attempts, created_at
+	FROM discussion_analysis WHERE discussion_id = ?`, discussionID).Scan(
+		&analysis.DiscussionID, &analysis.AnalystID, &analysis.Status, &analysis.Points, &analysis.UnknownNames,
+		&analysis.RawText, &analysis.Error, &analysis.Attempts, &analysis.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get discussion analysis: %w", err)
+	}
+	return analysis, nil
+}
+
+// DeleteDiscussionAnalysis deletes the analysis of a discussion. Foreign keys
+// are only enforced on some pooled connections, so deletes do not rely on
+// cascade.
+func (db *DB) DeleteDiscussionAnalysis(discussionID int64) error {
+	if _, err := db.Exec(`DELETE FROM discussion_analysis WHERE discussion_id = ?`, discussionID); err != nil {
+		return fmt.Errorf("failed to delete discussion analysis: %w", err)
+	}
+	return nil
+}
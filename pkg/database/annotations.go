@@ -0,0 +1,142 @@
+package database
+
+import (
+	"court-table-ai/pkg/models"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// discussionAnnotationsSQL creates the table holding highlighted spans of
+// discussion log entries
+const discussionAnnotationsSQL = `
+	CREATE TABLE IF NOT EXISTS discussion_annotations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		discussion_id INTEGER NOT NULL,
+		log_id INTEGER NOT NULL,
+		start_offset INTEGER NOT NULL,
+		end_offset INTEGER NOT NULL,
+		comment TEXT NOT NULL DEFAULT '',
+		author TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (discussion_id) REFERENCES discussions(id) ON DELETE CASCADE,
+		FOREIGN KEY (log_id) REFERENCES discussion_logs(id) ON DELETE CASCADE
+	);`
+
+// discussionAnnotationIndexes are created alongside discussion_annotations
+var discussionAnnotationIndexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_discussion_annotations_discussion_id ON discussion_annotations(discussion_id);",
+	"CREATE INDEX IF NOT EXISTS idx_discussion_annotations_log_id ON discussion_annotations(log_id);",
+}
+
+// annotationColumns lists the columns scanAnnotation reads, in order
+const annotationColumns = `id, discussion_id, log_id, start_offset, end_offset, comment, author, created_at, updated_at`
+
+// scanAnnotation scans one annotationColumns row
+func scanAnnotation(row interface{ Scan(...interface{}) error }) (*models.DiscussionAnnotation, error) {
+	a := &models.DiscussionAnnotation{}
+	err := row.Scan(&a.ID, &a.DiscussionID, &a.LogID, &a.StartOffset, &a.EndOffset,
+		&a.Comment, &a.Author, &a.CreatedAt, &a.UpdatedAt)
+	return a, err
+}
+
+// InsertDiscussionAnnotation adds an annotation to a log entry
+func (db *DB) InsertDiscussionAnnotation(a *models.DiscussionAnnotation) error {
+	query := `
+	INSERT INTO discussion_annotations (discussion_id, log_id, start_offset, end_offset, comment, author, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	now := time.Now()
+	result, err := db.Exec(query, a.DiscussionID, a.LogID, a.StartOffset, a.EndOffset, a.Comment, a.Author, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to insert discussion annotation: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+
+	a.ID = id
+	a.CreatedAt = now
+	a.UpdatedAt = now
+	return nil
+}
+
+// GetDiscussionAnnotation retrieves a single annotation by ID
+func (db *DB) GetDiscussionAnnotation(id int64) (*models.DiscussionAnnotation, error) {
+	a, err := scanAnnotation(db.QueryRow(`SELECT `+annotationColumns+` FROM discussion_annotations WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("annotation not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get discussion annotation: %w", err)
+	}
+	return a, nil
+}
+
+// GetDiscussionAnnotations retrieves all annotations of a discussion in
+// transcript order
+func (db *DB) GetDiscussionAnnotations(discussionID int64) ([]*models.DiscussionAnnotation, error) {
+	query := `SELECT ` + annotationColumns + ` FROM discussion_annotations
+	WHERE discussion_id = ? ORDER BY log_id ASC, start_offset ASC, id ASC`
+
+	rows, err := db.Query(query, discussionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query discussion annotations: %w", err)
+	}
+	defer rows.Close()
+
+	annotations := []*models.DiscussionAnnotation{}
+	for rows.Next() {
+		a, err := scanAnnotation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan discussion annotation: %w", err)
+		}
+		annotations = append(annotations, a)
+	}
+
+	return annotations, rows.Err()
+}
+
+// UpdateDiscussionAnnotation replaces an annotation's span, comment and author
+func (db *DB) UpdateDiscussionAnnotation(a *models.DiscussionAnnotation) error {
+	query := `UPDATE discussion_annotations SET start_offset = ?, end_offset = ?, comment = ?, author = ?, updated_at = ? WHERE id = ?`
+
+	now := time.Now()
+	result, err := db.Exec(query, a.StartOffset, a.EndOffset, a.Comment, a.Author, now, a.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update discussion annotation: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("annotation not found")
+	}
+
+	a.UpdatedAt = now
+	return nil
+}
+
+// DeleteDiscussionAnnotation deletes an annotation by ID
+func (db *DB) DeleteDiscussionAnnotation(id int64) error {
+	result, err := db.Exec(`DELETE FROM discussion_annotations WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete discussion annotation: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("annotation not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"court-table-ai/pkg/models"
+	"fmt"
+	"time"
+)
+
+// discussionClaimsSQL creates the tables holding claims extracted from
+// discussions and the per-agent outcome of each extraction
+const discussionClaimsSQL = `
+	CREATE TABLE IF NOT EXISTS discussion_claims (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		discussion_id INTEGER NOT NULL,
+		agent_id INTEGER NOT NULL,
+		claim TEXT NOT NULL,
+		stance TEXT NOT NULL DEFAULT 'neutral',
+		rounds TEXT NOT NULL DEFAULT '[]',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (discussion_id) REFERENCES discussions(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS discussion_claim_extractions (
+		discussion_id INTEGER NOT NULL,
+		agent_id INTEGER NOT NULL,
+		extractor_id INTEGER NOT NULL,
+		status TEXT NOT NULL,
+		error TEXT NOT NULL DEFAULT '',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		claim_count INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (discussion_id, agent_id),
+		FOREIGN KEY (discussion_id) REFERENCES discussions(id) ON DELETE CASCADE
+	);`
+
+// discussionClaimIndexes are created alongside discussion_claims
+var discussionClaimIndexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_discussion_claims_discussion_id ON discussion_claims(discussion_id, agent_id);",
+}
+
+// SaveClaimExtraction replaces the claims stored for one agent of a discussion
+// with the result of a new extraction. A failed extraction clears the agent's
+// previous claims so stored claims always match the latest outcome.
+func (db *DB) SaveClaimExtraction(extraction *models.ClaimExtraction, claims []*models.DiscussionClaim) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM discussion_claims WHERE discussion_id = ? AND agent_id = ?`,
+		extraction.DiscussionID, extraction.AgentID); err != nil {
+		return fmt.Errorf("failed to clear discussion claims: %w", err)
+	}
+
+	now := time.Now()
+	for _, claim := range claims {
+		claim.DiscussionID = extraction.DiscussionID
+		claim.AgentID = extraction.AgentID
+		claim.CreatedAt = now
+		if claim.Rounds == nil {
+			claim.Rounds = models.JSONSlice[int]{}
+		}
+
+		result, err := tx.Exec(`
+		INSERT INTO discussion_claims (discussion_id, agent_id, claim, stance, rounds, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+			claim.DiscussionID, claim.AgentID, claim.Claim, claim.Stance, claim.Rounds, claim.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to insert discussion claim: %w", err)
+		}
+		if claim.ID, err = result.LastInsertId(); err != nil {
+			return fmt.Errorf("failed to get last insert ID: %w", err)
+		}
+	}
+
+	extraction.ClaimCount = len(claims)
+	extraction.CreatedAt = now
+	_, err = tx.Exec(`
+	INSERT INTO discussion_claim_extractions (discussion_id, agent_id, extractor_id, status, error, attempts, claim_count, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(discussion_id, agent_id) DO UPDATE SET
+		extractor_id = excluded.extractor_id, status = excluded.status, error = excluded.error,
+		attempts = excluded.attempts, claim_count = excluded.claim_count, created_at = excluded.created_at`,
+		extraction.DiscussionID, extraction.AgentID, extraction.ExtractorID, extraction.Status,
+		extraction.Error, extraction.Attempts, extraction.ClaimCount, extraction.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save claim extraction: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit claim extraction: %w", err)
+	}
+	return nil
+}
+
+// GetDiscussionClaims retrieves the stored claims and extraction outcomes for
+// a discussion, grouped by agent
+func (db *DB) GetDiscussionClaims(discussionID int64) (*models.DiscussionClaims, error) {
+	result := &models.DiscussionClaims{
+		Claims:      []*models.DiscussionClaim{},
+		Extractions: []*models.ClaimExtraction{},
+	}
+
+	rows, err := db.Query(`
+	SELECT id, discussion_id, agent_id, claim, stance, rounds, created_at
+	FROM discussion_claims WHERE discussion_id = ? ORDER BY agent_id ASC, id ASC`, discussionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query discussion claims: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		claim := &models.DiscussionClaim{}
+		if err := rows.Scan(&claim.ID, &claim.DiscussionID, &claim.AgentID, &claim.Claim,
+			&claim.Stance, &claim.Rounds, &claim.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan discussion claim: %w", err)
+		}
+		result.Claims = append(result.Claims, claim)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	extractionRows, err := db.Query(`
+	SELECT discussion_id, agent_id, extractor_id, status, error, attempts, claim_count, created_at
+	FROM discussion_claim_extractions WHERE discussion_id = ? ORDER BY agent_id ASC`, discussionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query claim extractions: %w", err)
+	}
+	defer extractionRows.Close()
+
+	for extractionRows.Next() {
+		e := &models.ClaimExtraction{}
+		if err := extractionRows.Scan(&e.DiscussionID, &e.AgentID, &e.ExtractorID, &e.Status,
+			&e.Error, &e.Attempts, &e.ClaimCount, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan claim extraction: %w", err)
+		}
+		result.Extractions = append(result.Extractions, e)
+	}
+
+	return result, extractionRows.Err()
+}
+
+// DeleteDiscussionClaims deletes every claim and extraction record of a
+// discussion. Foreign keys are only enforced on some pooled connections, so
+// deletes do not rely on cascade.
+func (db *DB) DeleteDiscussionClaims(discussionID int64) error {
+	if _, err := db.Exec(`DELETE FROM discussion_claims WHERE discussion_id = ?`, discussionID); err != nil {
+		return fmt.Errorf("failed to delete discussion claims: %w", err)
+	}
+	if _, err := db.Exec(`DELETE FROM discussion_claim_extractions WHERE discussion_id = ?`, discussionID); err != nil {
+		return fmt.Errorf("failed to delete claim extractions: %w", err)
+	}
+	return nil
+}
@@ -3,20 +3,35 @@ package database
 import (
 	"court-table-ai/pkg/models"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	_ "modernc.org/sqlite"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
 type DB struct {
 	*sql.DB
+
+	settingsMu    sync.RWMutex
+	settingsCache map[string]string
+
+	// masterKey encrypts agent API tokens at rest; nil stores them as given
+	masterKey []byte
+
+	// AfterLogInsert is called with every discussion log entry once it has
+	// been stored
+	AfterLogInsert func(log *models.DiscussionLog)
 }
 
 // NewDB creates a new database connection
 func NewDB(dataSourceName string) (*DB, error) {
-	db, err := sql.Open("sqlite", dataSourceName)
+	db, err := sql.Open("sqlite", withBusyTimeout(dataSourceName))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -38,7 +53,32 @@ func NewDB(dataSourceName string) (*DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &DB{db}, nil
+	return &DB{DB: db}, nil
+}
+
+// withBusyTimeout sets busy_timeout in the DSN. The pragma is per connection,
+// so one PRAGMA statement only covers whichever pooled connection ran it and
+// writers on the others fail at once with SQLITE_BUSY instead of waiting.
+func withBusyTimeout(dataSourceName string) string {
+	if strings.Contains(dataSourceName, "busy_timeout") {
+		return dataSourceName
+	}
+	sep := "?"
+	if strings.Contains(dataSourceName, "?") {
+		sep = "&"
+	}
+	return dataSourceName + sep + "_pragma=busy_timeout(5000)"
+}
+
+// IsBusy reports whether err is SQLite giving up on a locked database, which
+// is worth retrying
+func IsBusy(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	code := sqliteErr.Code() & 0xff // primary code without the extended bits
+	return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
 }
 
 // Remove the broken custom contains function as we now use strings.Contains
@@ -47,72 +87,93 @@ func NewDB(dataSourceName string) (*DB, error) {
 // CreateTables creates all necessary tables for the application
 func (db *DB) CreateTables() error {
 	// Create agents table
-	agentsSQL := `
-	CREATE TABLE IF NOT EXISTS agents (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL UNIQUE,
-		provider_type TEXT NOT NULL DEFAULT 'custom',
-		provider_url TEXT NOT NULL,
-		api_token TEXT NOT NULL,
-		model_name TEXT NOT NULL,
-		timeout_seconds INTEGER DEFAULT 30,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	if _, err := db.Exec(agentsSQL); err != nil {
+	if _, err := db.Exec(agentsTableSQL("agents")); err != nil {
 		return fmt.Errorf("failed to create agents table: %w", err)
 	}
 
 	// Create discussions table
-	discussionsSQL := `
-	CREATE TABLE IF NOT EXISTS discussions (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		topic TEXT NOT NULL,
-		final_summary TEXT NOT NULL DEFAULT '',
-		status TEXT DEFAULT 'running' CHECK (status IN ('running', 'completed', 'failed')),
-		agent_ids TEXT NOT NULL,
-		moderator_id INTEGER,
-		max_rounds INTEGER DEFAULT 3,
-		language TEXT DEFAULT 'English',
-		max_char_limit INTEGER DEFAULT 1000,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (moderator_id) REFERENCES agents(id) ON DELETE SET NULL
-	);`
-
-	if _, err := db.Exec(discussionsSQL); err != nil {
+	if _, err := db.Exec(discussionsTableSQL("discussions")); err != nil {
 		return fmt.Errorf("failed to create discussions table: %w", err)
 	}
 
 	// Create discussion_logs table
-	discussionLogsSQL := `
-	CREATE TABLE IF NOT EXISTS discussion_logs (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		discussion_id INTEGER NOT NULL,
-		agent_id INTEGER NOT NULL,
-		content TEXT NOT NULL DEFAULT '',
-		status TEXT NOT NULL CHECK (status IN ('success', 'timeout', 'error')),
-		response_time INTEGER DEFAULT 0,
-		is_moderator BOOLEAN DEFAULT FALSE,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (discussion_id) REFERENCES discussions(id) ON DELETE CASCADE,
-		FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE
+	if _, err := db.Exec(discussionLogsTableSQL("discussion_logs")); err != nil {
+		return fmt.Errorf("failed to create discussion_logs table: %w", err)
+	}
+
+	// Create settings table
+	settingsSQL := `
+	CREATE TABLE IF NOT EXISTS settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL DEFAULT '',
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);`
 
-	if _, err := db.Exec(discussionLogsSQL); err != nil {
-		return fmt.Errorf("failed to create discussion_logs table: %w", err)
+	if _, err := db.Exec(settingsSQL); err != nil {
+		return fmt.Errorf("failed to create settings table: %w", err)
 	}
 
-	// Create indexes for better performance
-	indexes := []string{
-		"CREATE INDEX IF NOT EXISTS idx_discussions_status ON discussions(status);",
-		"CREATE INDEX IF NOT EXISTS idx_discussions_created_at ON discussions(created_at);",
-		"CREATE INDEX IF NOT EXISTS idx_discussion_logs_discussion_id ON discussion_logs(discussion_id);",
-		"CREATE INDEX IF NOT EXISTS idx_discussion_logs_agent_id ON discussion_logs(agent_id);",
-		"CREATE INDEX IF NOT EXISTS idx_discussion_logs_created_at ON discussion_logs(created_at);",
+	// Create discussion_notes table
+	if _, err := db.Exec(discussionNotesSQL); err != nil {
+		return fmt.Errorf("failed to create discussion_notes table: %w", err)
+	}
+
+	// Create discussion_claims and discussion_claim_extractions tables
+	if _, err := db.Exec(discussionClaimsSQL); err != nil {
+		return fmt.Errorf("failed to create discussion_claims tables: %w", err)
+	}
+
+	// Create discussion_annotations table
+	if _, err := db.Exec(discussionAnnotationsSQL); err != nil {
+		return fmt.Errorf("failed to create discussion_annotations table: %w", err)
+	}
+
+	// Create discussion_log_ratings table
+	if _, err := db.Exec(logRatingsSQL); err != nil {
+		return fmt.Errorf("failed to create discussion_log_ratings table: %w", err)
 	}
 
+	// Create webhook_deliveries table
+	if _, err := db.Exec(webhookDeliveriesSQL); err != nil {
+		return fmt.Errorf("failed to create webhook_deliveries table: %w", err)
+	}
+
+	// Create model_pricing table
+	if _, err := db.Exec(modelPricingSQL); err != nil {
+		return fmt.Errorf("failed to create model_pricing table: %w", err)
+	}
+
+	// Create agent_scratchpads table
+	if _, err := db.Exec(agentScratchpadsSQL); err != nil {
+		return fmt.Errorf("failed to create agent_scratchpads table: %w", err)
+	}
+
+	// Create discussion_participants table
+	if _, err := db.Exec(discussionParticipantsSQL); err != nil {
+		return fmt.Errorf("failed to create discussion_participants table: %w", err)
+	}
+
+	// Create discussion_analysis table
+	if _, err := db.Exec(discussionAnalysisSQL); err != nil {
+		return fmt.Errorf("failed to create discussion_analysis table: %w", err)
+	}
+
+	// Create agent_alerts table
+	if _, err := db.Exec(agentAlertsSQL); err != nil {
+		return fmt.Errorf("failed to create agent_alerts table: %w", err)
+	}
+
+	// Create indexes for better performance
+	var indexes []string
+	indexes = append(indexes, discussionIndexes...)
+	indexes = append(indexes, discussionLogIndexes...)
+	indexes = append(indexes, discussionNoteIndexes...)
+	indexes = append(indexes, discussionClaimIndexes...)
+	indexes = append(indexes, discussionAnnotationIndexes...)
+	indexes = append(indexes, logRatingIndexes...)
+	indexes = append(indexes, webhookDeliveryIndexes...)
+	indexes = append(indexes, agentAlertIndexes...)
+
 	for _, indexSQL := range indexes {
 		if _, err := db.Exec(indexSQL); err != nil {
 			return fmt.Errorf("failed to create index: %w", err)
@@ -134,20 +195,141 @@ func (db *DB) CreateTables() error {
 	db.Exec("UPDATE discussions SET final_summary = '' WHERE final_summary IS NULL")
 	db.Exec("UPDATE discussion_logs SET content = '' WHERE content IS NULL")
 
+	if err := db.runMigrations(); err != nil {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+
 	return nil
 }
 
+// agentsTableSQL returns the agents definition under the given table name so
+// migrations can rebuild it with the current constraints. Names are unique
+// among agents that are not deleted, through agentIndexes.
+func agentsTableSQL(table string) string {
+	return fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		provider_type TEXT NOT NULL DEFAULT 'custom',
+		provider_url TEXT NOT NULL,
+		api_token TEXT NOT NULL,
+		model_name TEXT NOT NULL,
+		timeout_seconds INTEGER DEFAULT 30,
+		endpoint_style TEXT NOT NULL DEFAULT '',
+		api_version TEXT NOT NULL DEFAULT '',
+		extra_headers TEXT NOT NULL DEFAULT '{}',
+		system_prompt TEXT NOT NULL DEFAULT '',
+		disabled BOOLEAN NOT NULL DEFAULT FALSE,
+		trace BOOLEAN NOT NULL DEFAULT FALSE,
+		deleted_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`, table)
+}
+
+// agentIndexes are recreated whenever agents is rebuilt. A deleted agent
+// keeps its name without holding it. Migration 26 creates them, as older
+// databases have no deleted_at column until migration 25.
+var agentIndexes = []string{
+	"CREATE UNIQUE INDEX IF NOT EXISTS idx_agents_name ON agents(name) WHERE deleted_at IS NULL;",
+}
+
+// discussionsTableSQL returns the discussions definition under the given
+// table name so migrations can rebuild it with the current constraints
+func discussionsTableSQL(table string) string {
+	return fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		topic TEXT NOT NULL,
+		final_summary TEXT NOT NULL DEFAULT '',
+		status TEXT DEFAULT 'running' CHECK (status IN ('running', 'paused', 'completed', 'stopped', 'failed', 'deleting', 'imported')),
+		agent_ids TEXT NOT NULL,
+		moderator_id INTEGER,
+		max_rounds INTEGER DEFAULT 3,
+		language TEXT DEFAULT 'English',
+		max_char_limit INTEGER DEFAULT 1000,
+		app_version TEXT NOT NULL DEFAULT '',
+		settings TEXT NOT NULL DEFAULT '{}',
+		error_message TEXT NOT NULL DEFAULT '',
+		completed_rounds INTEGER NOT NULL DEFAULT 0,
+		end_reason TEXT NOT NULL DEFAULT '',
+		judge_id INTEGER,
+		verdict_winner_id INTEGER,
+		verdict_scores TEXT,
+		verdict_reasoning TEXT,
+		self_test BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (moderator_id) REFERENCES agents(id) ON DELETE SET NULL,
+		FOREIGN KEY (judge_id) REFERENCES agents(id) ON DELETE SET NULL
+	);`, table)
+}
+
+// discussionIndexes are recreated whenever discussions is rebuilt
+var discussionIndexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_discussions_status ON discussions(status);",
+	"CREATE INDEX IF NOT EXISTS idx_discussions_created_at ON discussions(created_at);",
+}
+
+// discussionLogsTableSQL returns the discussion_logs definition under the given
+// table name so migrations can rebuild it with the current constraints
+func discussionLogsTableSQL(table string) string {
+	return fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		discussion_id INTEGER NOT NULL,
+		agent_id INTEGER,
+		content TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL CHECK (status IN ('success', 'timeout', 'error', 'skipped', 'rejected')),
+		response_time INTEGER DEFAULT 0,
+		is_moderator BOOLEAN DEFAULT FALSE,
+		metadata TEXT NOT NULL DEFAULT '{}',
+		log_type TEXT NOT NULL DEFAULT 'response',
+		sequence INTEGER NOT NULL DEFAULT 0,
+		prompt_tokens INTEGER NOT NULL DEFAULT 0,
+		completion_tokens INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (discussion_id) REFERENCES discussions(id) ON DELETE CASCADE,
+		FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE
+	);`, table)
+}
+
+// discussionLogIndexes are recreated whenever discussion_logs is rebuilt
+var discussionLogIndexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_discussion_logs_discussion_id ON discussion_logs(discussion_id);",
+	"CREATE INDEX IF NOT EXISTS idx_discussion_logs_agent_id ON discussion_logs(agent_id);",
+	"CREATE INDEX IF NOT EXISTS idx_discussion_logs_created_at ON discussion_logs(created_at);",
+}
+
+// discussionLogSequenceIndex makes log sequences unique per discussion, which
+// the stream's replay cursor relies on. Migration 21 creates it once older
+// entries are numbered, and rebuilds of discussion_logs must recreate it.
+const discussionLogSequenceIndex = "CREATE UNIQUE INDEX IF NOT EXISTS idx_discussion_logs_sequence ON discussion_logs(discussion_id, sequence);"
+
+// ErrAgentNameExists is returned when another agent already has the name
+var ErrAgentNameExists = errors.New("an agent with this name already exists")
+
 // InsertAgent creates a new agent in the database
 func (db *DB) InsertAgent(agent *models.Agent) error {
 	query := `
-	INSERT INTO agents (name, provider_type, provider_url, api_token, model_name, timeout_seconds, created_at, updated_at)
-	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	INSERT INTO agents (name, provider_type, provider_url, api_token, model_name, timeout_seconds, endpoint_style, api_version, extra_headers, system_prompt, disabled, trace, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	
+	// The token is sealed to the agent's ID, so it is written once the row has one
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	now := time.Now()
-	result, err := db.Exec(query, agent.Name, agent.ProviderType, agent.ProviderURL, agent.APIToken, 
-		agent.ModelName, agent.TimeoutSeconds, now, now)
+	result, err := tx.Exec(query, agent.Name, agent.ProviderType, agent.ProviderURL, "", 
+		agent.ModelName, agent.TimeoutSeconds, agent.EndpointStyle, agent.APIVersion, agent.ExtraHeaders, agent.SystemPrompt, agent.Disabled, agent.Trace, now, now)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrAgentNameExists
+		}
 		return fmt.Errorf("failed to insert agent: %w", err)
 	}
 
@@ -156,6 +338,19 @@ func (db *DB) InsertAgent(agent *models.Agent) error {
 		return fmt.Errorf("failed to get last insert ID: %w", err)
 	}
 
+	token, err := db.encryptToken(id, agent.APIToken)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt API token: %w", err)
+	}
+	if token != "" {
+		if _, err := tx.Exec(`UPDATE agents SET api_token = ? WHERE id = ?`, token, id); err != nil {
+			return fmt.Errorf("failed to store API token: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit agent: %w", err)
+	}
+
 	agent.ID = id
 	agent.CreatedAt = now
 	agent.UpdatedAt = now
@@ -165,14 +360,14 @@ func (db *DB) InsertAgent(agent *models.Agent) error {
 // GetAgent retrieves an agent by ID
 func (db *DB) GetAgent(id int64) (*models.Agent, error) {
 	query := `
-	SELECT id, name, provider_type, provider_url, api_token, model_name, timeout_seconds, created_at, updated_at
-	FROM agents WHERE id = ?
+	SELECT id, name, provider_type, provider_url, api_token, model_name, timeout_seconds, endpoint_style, api_version, extra_headers, system_prompt, disabled, trace, created_at, updated_at
+	FROM agents WHERE id = ? AND deleted_at IS NULL
 	`
 	
 	agent := &models.Agent{}
 	err := db.QueryRow(query, id).Scan(
 		&agent.ID, &agent.Name, &agent.ProviderType, &agent.ProviderURL, &agent.APIToken,
-		&agent.ModelName, &agent.TimeoutSeconds, &agent.CreatedAt, &agent.UpdatedAt,
+		&agent.ModelName, &agent.TimeoutSeconds, &agent.EndpointStyle, &agent.APIVersion, &agent.ExtraHeaders, &agent.SystemPrompt, &agent.Disabled, &agent.Trace, &agent.CreatedAt, &agent.UpdatedAt,
 	)
 	
 	if err == sql.ErrNoRows {
@@ -181,15 +376,72 @@ func (db *DB) GetAgent(id int64) (*models.Agent, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get agent: %w", err)
 	}
+	if agent.APIToken, err = db.decryptToken(agent.ID, agent.APIToken); err != nil {
+		return nil, fmt.Errorf("failed to decrypt API token of agent %d: %w", agent.ID, err)
+	}
 
 	return agent, nil
 }
 
+// GetAgentsByIDs retrieves the agents with the given IDs in one query, keyed
+// by ID. IDs without an agent are absent from the map.
+func (db *DB) GetAgentsByIDs(ids []int64) (map[int64]*models.Agent, error) {
+	agents := make(map[int64]*models.Agent, len(ids))
+	if len(ids) == 0 {
+		return agents, nil
+	}
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	query := `
+	SELECT id, name, provider_type, provider_url, api_token, model_name, timeout_seconds, endpoint_style, api_version, extra_headers, system_prompt, disabled, trace, created_at, updated_at
+	FROM agents WHERE id IN (?` + strings.Repeat(", ?", len(ids)-1) + `) AND deleted_at IS NULL
+	`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query agents: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		agent := &models.Agent{}
+		err := rows.Scan(
+			&agent.ID, &agent.Name, &agent.ProviderType, &agent.ProviderURL, &agent.APIToken,
+			&agent.ModelName, &agent.TimeoutSeconds, &agent.EndpointStyle, &agent.APIVersion, &agent.ExtraHeaders, &agent.SystemPrompt, &agent.Disabled, &agent.Trace, &agent.CreatedAt, &agent.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan agent: %w", err)
+		}
+		if agent.APIToken, err = db.decryptToken(agent.ID, agent.APIToken); err != nil {
+			return nil, fmt.Errorf("failed to decrypt API token of agent %d: %w", agent.ID, err)
+		}
+		agents[agent.ID] = agent
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query agents: %w", err)
+	}
+
+	return agents, nil
+}
+
 // GetAllAgents retrieves all agents from the database
 func (db *DB) GetAllAgents() ([]*models.Agent, error) {
+	return db.queryAgents(`WHERE deleted_at IS NULL`)
+}
+
+// GetAllAgentsWithDeleted is GetAllAgents including deleted agents, for
+// naming and pricing the turns they left in transcripts
+func (db *DB) GetAllAgentsWithDeleted() ([]*models.Agent, error) {
+	return db.queryAgents("")
+}
+
+func (db *DB) queryAgents(where string) ([]*models.Agent, error) {
 	query := `
-	SELECT id, name, provider_type, provider_url, api_token, model_name, timeout_seconds, created_at, updated_at
-	FROM agents ORDER BY created_at DESC
+	SELECT id, name, provider_type, provider_url, api_token, model_name, timeout_seconds, endpoint_style, api_version, extra_headers, system_prompt, disabled, trace, created_at, updated_at
+	FROM agents ` + where + ` ORDER BY created_at DESC
 	`
 	
 	rows, err := db.Query(query)
@@ -203,28 +455,63 @@ func (db *DB) GetAllAgents() ([]*models.Agent, error) {
 		agent := &models.Agent{}
 		err := rows.Scan(
 			&agent.ID, &agent.Name, &agent.ProviderType, &agent.ProviderURL, &agent.APIToken,
-			&agent.ModelName, &agent.TimeoutSeconds, &agent.CreatedAt, &agent.UpdatedAt,
+			&agent.ModelName, &agent.TimeoutSeconds, &agent.EndpointStyle, &agent.APIVersion, &agent.ExtraHeaders, &agent.SystemPrompt, &agent.Disabled, &agent.Trace, &agent.CreatedAt, &agent.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan agent: %w", err)
 		}
+		if agent.APIToken, err = db.decryptToken(agent.ID, agent.APIToken); err != nil {
+			return nil, fmt.Errorf("failed to decrypt API token of agent %d: %w", agent.ID, err)
+		}
 		agents = append(agents, agent)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query agents: %w", err)
+	}
 
 	return agents, nil
 }
 
+// GetAgentName returns the name of an agent, deleted or not, for labelling
+// its turns
+func (db *DB) GetAgentName(id int64) (string, error) {
+	var name string
+	err := db.QueryRow(`SELECT name FROM agents WHERE id = ?`, id).Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("agent not found")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get agent name: %w", err)
+	}
+	return name, nil
+}
+
+// IsAgentDeleted reports whether the agent was deleted with DeleteAgent
+func (db *DB) IsAgentDeleted(id int64) (bool, error) {
+	var deleted bool
+	err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM agents WHERE id = ? AND deleted_at IS NOT NULL)`, id).Scan(&deleted)
+	if err != nil {
+		return false, fmt.Errorf("failed to check agent: %w", err)
+	}
+	return deleted, nil
+}
+
 // UpdateAgent updates an existing agent
 func (db *DB) UpdateAgent(agent *models.Agent) error {
 	query := `
 	UPDATE agents 
-	SET name = ?, provider_type = ?, provider_url = ?, api_token = ?, model_name = ?, timeout_seconds = ?, updated_at = ?
-	WHERE id = ?
+	SET name = ?, provider_type = ?, provider_url = ?, api_token = ?, model_name = ?, timeout_seconds = ?, endpoint_style = ?, api_version = ?, extra_headers = ?, system_prompt = ?, disabled = ?, trace = ?, updated_at = ?
+	WHERE id = ? AND deleted_at IS NULL
 	`
 	
+	token, err := db.encryptToken(agent.ID, agent.APIToken)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt API token: %w", err)
+	}
+
 	agent.UpdatedAt = time.Now()
-	result, err := db.Exec(query, agent.Name, agent.ProviderType, agent.ProviderURL, agent.APIToken,
-		agent.ModelName, agent.TimeoutSeconds, agent.UpdatedAt, agent.ID)
+	result, err := db.Exec(query, agent.Name, agent.ProviderType, agent.ProviderURL, token,
+		agent.ModelName, agent.TimeoutSeconds, agent.EndpointStyle, agent.APIVersion, agent.ExtraHeaders, agent.SystemPrompt, agent.Disabled, agent.Trace, agent.UpdatedAt, agent.ID)
 	if err != nil {
 		return fmt.Errorf("failed to update agent: %w", err)
 	}
@@ -241,11 +528,37 @@ func (db *DB) UpdateAgent(agent *models.Agent) error {
 	return nil
 }
 
-// DeleteAgent deletes an agent by ID
+// CountAgents returns the number of configured agents
+func (db *DB) CountAgents() (int, error) {
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM agents WHERE deleted_at IS NULL`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count agents: %w", err)
+	}
+	return count, nil
+}
+
+// IsEmpty reports whether the database holds no agents and no discussions
+func (db *DB) IsEmpty() (bool, error) {
+	var hasData bool
+	err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM agents) OR EXISTS(SELECT 1 FROM discussions)`).Scan(&hasData)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for existing data: %w", err)
+	}
+	return !hasData, nil
+}
+
+// DeleteAgent deletes an agent by ID. The row is kept, disabled and stripped
+// of its credentials, so the discussions it took part in keep its turns under
+// its name; the name is free for a new agent.
 func (db *DB) DeleteAgent(id int64) error {
-	query := `DELETE FROM agents WHERE id = ?`
+	query := `
+	UPDATE agents
+	SET api_token = '', extra_headers = '{}', disabled = TRUE, deleted_at = ?, updated_at = ?
+	WHERE id = ? AND deleted_at IS NULL
+	`
 	
-	result, err := db.Exec(query, id)
+	now := time.Now()
+	result, err := db.Exec(query, now, now, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete agent: %w", err)
 	}
@@ -262,17 +575,52 @@ func (db *DB) DeleteAgent(id int64) error {
 	return nil
 }
 
-// InsertDiscussion creates a new discussion
+// PurgeAgent removes an agent row outright, together with any turns it
+// left. Only for agents nothing should remember, such as the self-test's;
+// everything else goes through DeleteAgent.
+func (db *DB) PurgeAgent(id int64) error {
+	result, err := db.Exec(`DELETE FROM agents WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to purge agent: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("agent not found")
+	}
+
+	return nil
+}
+
+// ErrDiscussionNotFound is returned when no discussion has the ID
+var ErrDiscussionNotFound = errors.New("discussion not found")
+
+// ErrDiscussionLive is returned when an operation needs the discussion's
+// debate to have ended but it is running or paused
+var ErrDiscussionLive = errors.New("discussion is running")
+
+// InsertDiscussion creates a new discussion with its agent stances
 func (db *DB) InsertDiscussion(discussion *models.Discussion) error {
 	query := `
-	INSERT INTO discussions (topic, final_summary, status, agent_ids, moderator_id, max_rounds, language, max_char_limit, created_at, updated_at)
-	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	INSERT INTO discussions (topic, final_summary, status, agent_ids, moderator_id, judge_id, max_rounds, language, max_char_limit, app_version, settings, completed_rounds, end_reason, self_test, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 	
 	now := time.Now()
-	result, err := db.Exec(query, discussion.Topic, discussion.FinalSummary, 
-		discussion.Status, discussion.AgentIDs, discussion.ModeratorID, 
-		discussion.MaxRounds, discussion.Language, discussion.MaxCharLimit, now, now)
+	result, err := tx.Exec(query, discussion.Topic, discussion.FinalSummary, 
+		discussion.Status, discussion.AgentIDs, discussion.ModeratorID, discussion.JudgeID,
+		discussion.MaxRounds, discussion.Language, discussion.MaxCharLimit, discussion.AppVersion, discussion.Settings,
+		discussion.CompletedRounds, discussion.EndReason, discussion.SelfTest, now, now)
 	if err != nil {
 		return fmt.Errorf("failed to insert discussion: %w", err)
 	}
@@ -281,6 +629,12 @@ func (db *DB) InsertDiscussion(discussion *models.Discussion) error {
 	if err != nil {
 		return fmt.Errorf("failed to get last insert ID: %w", err)
 	}
+	if err := insertDiscussionStances(tx, id, discussion.Stances); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit discussion: %w", err)
+	}
 
 	discussion.ID = id
 	discussion.CreatedAt = now
@@ -293,38 +647,184 @@ func (db *DB) GetDiscussion(id int64) (*models.Discussion, error) {
 	query := `
 	SELECT id, topic, COALESCE(final_summary, ''), status, agent_ids, moderator_id, 
 	       COALESCE(max_rounds, 3), COALESCE(language, 'English'), COALESCE(max_char_limit, 1000), 
-	       created_at, updated_at
+	       COALESCE(app_version, ''), COALESCE(settings, '{}'), COALESCE(error_message, ''), completed_rounds, end_reason, created_at, updated_at,
+	       judge_id, verdict_winner_id, verdict_scores, verdict_reasoning
 	FROM discussions WHERE id = ?
 	`
 	
 	discussion := &models.Discussion{}
+	var agentIDs sql.NullString
+	var verdict verdictColumns
 	err := db.QueryRow(query, id).Scan(
 		&discussion.ID, &discussion.Topic, &discussion.FinalSummary,
-		&discussion.Status, &discussion.AgentIDs, &discussion.ModeratorID,
+		&discussion.Status, &agentIDs, &discussion.ModeratorID,
 		&discussion.MaxRounds, &discussion.Language, &discussion.MaxCharLimit,
-		&discussion.CreatedAt, &discussion.UpdatedAt,
+		&discussion.AppVersion, &discussion.Settings, &discussion.ErrorMessage,
+		&discussion.CompletedRounds, &discussion.EndReason, &discussion.CreatedAt, &discussion.UpdatedAt,
+		&discussion.JudgeID, &verdict.winnerID, &verdict.scores, &verdict.reasoning,
 	)
 	
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("discussion not found")
+		return nil, ErrDiscussionNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get discussion: %w", err)
 	}
+	if err := scanAgentIDs(discussion, agentIDs); err != nil {
+		return nil, err
+	}
+	discussion.Verdict = verdict.verdict(discussion.JudgeID)
+	if discussion.Stances, err = db.GetDiscussionStances(id); err != nil {
+		return nil, err
+	}
 
 	return discussion, nil
 }
 
-// GetAllDiscussions retrieves all discussions
-func (db *DB) GetAllDiscussions() ([]*models.Discussion, error) {
-	query := `
+// scanAgentIDs decodes a discussion's agent_ids column, warning when the
+// legacy comma-separated fallback had to be used
+func scanAgentIDs(discussion *models.Discussion, raw sql.NullString) error {
+	var value interface{}
+	if raw.Valid {
+		value = raw.String
+	}
+
+	fallback, err := discussion.AgentIDs.ScanFallback(value)
+	if err != nil {
+		return fmt.Errorf("failed to read agent_ids of discussion %d: %w", discussion.ID, err)
+	}
+	if fallback {
+		log.Printf("Warning: discussion %d has legacy comma-separated agent_ids %q", discussion.ID, raw.String)
+	}
+	return nil
+}
+
+// GetDiscussionsPage retrieves one page of discussions and the total number
+// of discussions. The query must already be normalized; its sort and order
+// are checked again since they end up in the SQL text.
+func (db *DB) GetDiscussionsPage(q models.DiscussionQuery) (*models.DiscussionPage, error) {
+	if err := q.Normalize(); err != nil {
+		return nil, err
+	}
+
+	where, args := discussionFilter(q)
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM discussions`+where, args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to count discussions: %w", err)
+	}
+
+	// id breaks ties so a page boundary never splits equal sort values
+	// differently between requests
+	order := fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT ? OFFSET ?", q.Sort, q.Order, q.Order)
+	items, err := db.queryDiscussions(discussionSelectSQL+where+order, append(args, q.PerPage, q.Offset())...)
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []*models.Discussion{}
+	}
+
+	return &models.DiscussionPage{Items: items, Total: total, Page: q.Page, PerPage: q.PerPage}, nil
+}
+
+// agentIDsContainSQL matches discussions whose agent_ids hold an agent ID,
+// given twice: as a number, then as a string. agent_ids is a JSON array, or
+// comma-separated in legacy rows.
+const agentIDsContainSQL = `CASE WHEN json_valid(agent_ids)
+			THEN EXISTS (SELECT 1 FROM json_each(discussions.agent_ids) WHERE json_each.value = ?)
+			ELSE ',' || REPLACE(agent_ids, ' ', '') || ',' LIKE '%,' || ? || ',%' END`
+
+// GetLiveDiscussionIDsUsingAgent returns the running and paused discussions
+// that have the agent as a participant, moderator or judge
+func (db *DB) GetLiveDiscussionIDsUsingAgent(agentID int64) ([]int64, error) {
+	rows, err := db.Query(`SELECT id FROM discussions
+		WHERE status IN ('running', 'paused')
+		  AND (moderator_id = ? OR judge_id = ? OR `+agentIDsContainSQL+`)
+		ORDER BY id`,
+		agentID, agentID, agentID, strconv.FormatInt(agentID, 10))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query discussions using agent: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan discussion id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// discussionFilter builds the WHERE clause of a discussion list query and its
+// arguments. Discussions being deleted and self-test runs are always left out.
+func discussionFilter(q models.DiscussionQuery) (string, []interface{}) {
+	conditions := []string{"status != 'deleting'", "NOT self_test"}
+	var args []interface{}
+
+	if q.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, q.Status)
+	}
+	if q.AgentID != 0 {
+		conditions = append(conditions, agentIDsContainSQL)
+		args = append(args, q.AgentID, strconv.FormatInt(q.AgentID, 10))
+	}
+	// created_at is compared as stored, so bounds use the same local time
+	if !q.From.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, q.From.In(time.Local))
+	}
+	if !q.Until.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, q.Until.In(time.Local))
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// GetRecentDiscussions retrieves the newest discussions, at most limit,
+// leaving out discussions being deleted and self-test runs
+func (db *DB) GetRecentDiscussions(limit int) ([]*models.Discussion, error) {
+	return db.queryDiscussions(discussionSelectSQL+` WHERE status != 'deleting' AND NOT self_test ORDER BY created_at DESC LIMIT ?`, limit)
+}
+
+// CountDiscussionsByStatus returns the number of discussions in each status,
+// leaving out discussions being deleted and self-test runs
+func (db *DB) CountDiscussionsByStatus() (map[string]int, error) {
+	rows, err := db.Query(`SELECT status, COUNT(*) FROM discussions WHERE status != 'deleting' AND NOT self_test GROUP BY status`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count discussions: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan discussion count: %w", err)
+		}
+		counts[status] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// discussionSelectSQL selects every discussion column in the order
+// queryDiscussions scans them
+const discussionSelectSQL = `
 	SELECT id, topic, COALESCE(final_summary, ''), status, agent_ids, moderator_id, 
 	       COALESCE(max_rounds, 3), COALESCE(language, 'English'), COALESCE(max_char_limit, 1000), 
-	       created_at, updated_at
-	FROM discussions ORDER BY created_at DESC
-	`
-	
-	rows, err := db.Query(query)
+	       COALESCE(app_version, ''), COALESCE(settings, '{}'), COALESCE(error_message, ''), completed_rounds, end_reason, created_at, updated_at,
+	       judge_id, verdict_winner_id, verdict_scores, verdict_reasoning
+	FROM discussions`
+
+// queryDiscussions runs a discussionSelectSQL query and scans the rows
+func (db *DB) queryDiscussions(query string, args ...interface{}) ([]*models.Discussion, error) {
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query discussions: %w", err)
 	}
@@ -333,15 +833,23 @@ func (db *DB) GetAllDiscussions() ([]*models.Discussion, error) {
 	var discussions []*models.Discussion
 	for rows.Next() {
 		discussion := &models.Discussion{}
+		var agentIDs sql.NullString
+		var verdict verdictColumns
 		err := rows.Scan(
 			&discussion.ID, &discussion.Topic, &discussion.FinalSummary,
-			&discussion.Status, &discussion.AgentIDs, &discussion.ModeratorID,
+			&discussion.Status, &agentIDs, &discussion.ModeratorID,
 			&discussion.MaxRounds, &discussion.Language, &discussion.MaxCharLimit,
-			&discussion.CreatedAt, &discussion.UpdatedAt,
+			&discussion.AppVersion, &discussion.Settings, &discussion.ErrorMessage,
+			&discussion.CompletedRounds, &discussion.EndReason, &discussion.CreatedAt, &discussion.UpdatedAt,
+			&discussion.JudgeID, &verdict.winnerID, &verdict.scores, &verdict.reasoning,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan discussion: %w", err)
 		}
+		if err := scanAgentIDs(discussion, agentIDs); err != nil {
+			return nil, err
+		}
+		discussion.Verdict = verdict.verdict(discussion.JudgeID)
 		discussions = append(discussions, discussion)
 	}
 
@@ -351,34 +859,202 @@ func (db *DB) GetAllDiscussions() ([]*models.Discussion, error) {
 // InsertDiscussionLog creates a new discussion log entry
 func (db *DB) InsertDiscussionLog(log *models.DiscussionLog) error {
 	query := `
-	INSERT INTO discussion_logs (discussion_id, agent_id, content, status, response_time, is_moderator, created_at)
-	VALUES (?, ?, ?, ?, ?, ?, ?)
+	INSERT INTO discussion_logs (discussion_id, agent_id, content, status, response_time, is_moderator, metadata, log_type, sequence, prompt_tokens, completion_tokens, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?,
+		(SELECT COALESCE(MAX(sequence), 0) + 1 FROM discussion_logs WHERE discussion_id = ?), ?, ?, ?)
+	RETURNING id, sequence
 	`
 	
-	log.CreatedAt = time.Now()
-	result, err := db.Exec(query, log.DiscussionID, log.AgentID, log.Content,
-		log.Status, log.ResponseTime, log.IsModerator, log.CreatedAt)
+	// Imported transcripts keep their original timestamps
+	if log.CreatedAt.IsZero() {
+		log.CreatedAt = time.Now()
+	}
+	if log.LogType == "" {
+		log.LogType = models.LogTypeResponse
+	}
+	// The system participant is stored as a NULL agent_id
+	var agentID interface{} = log.AgentID
+	if log.AgentID == models.SystemAgentID {
+		agentID = nil
+	}
+
+	// The sequence is assigned in the same statement so concurrent inserts
+	// for one discussion cannot share a number
+	err := db.QueryRow(query, log.DiscussionID, agentID, log.Content,
+		log.Status, log.ResponseTime, log.IsModerator, log.Metadata, log.LogType,
+		log.DiscussionID, log.PromptTokens, log.CompletionTokens, log.CreatedAt).Scan(&log.ID, &log.Sequence)
 	if err != nil {
 		return fmt.Errorf("failed to insert discussion log: %w", err)
 	}
 
-	id, err := result.LastInsertId()
+	if db.AfterLogInsert != nil {
+		db.AfterLogInsert(log)
+	}
+	return nil
+}
+
+// GetAgentOutcomes retrieves every agent call since the given time for
+// reliability scoring. Skips and system entries are not calls and are left out.
+func (db *DB) GetAgentOutcomes(since time.Time) ([]models.AgentOutcome, error) {
+	query := `
+	SELECT agent_id, status,
+		status = 'timeout' OR (status != 'success' AND (content LIKE '%deadline exceeded%' OR content LIKE '%timeout%')),
+		response_time, created_at
+	FROM discussion_logs
+	WHERE agent_id IS NOT NULL AND log_type = ? AND created_at >= ?
+	`
+
+	rows, err := db.Query(query, models.LogTypeResponse, since)
 	if err != nil {
-		return fmt.Errorf("failed to get last insert ID: %w", err)
+		return nil, fmt.Errorf("failed to query agent outcomes: %w", err)
+	}
+	defer rows.Close()
+
+	var outcomes []models.AgentOutcome
+	for rows.Next() {
+		var o models.AgentOutcome
+		if err := rows.Scan(&o.AgentID, &o.Status, &o.TimedOut, &o.ResponseTime, &o.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan agent outcome: %w", err)
+		}
+		outcomes = append(outcomes, o)
+	}
+
+	return outcomes, rows.Err()
+}
+
+// GetTokenCalls retrieves every agent call since the given time that recorded
+// the fingerprint of the API token it used
+func (db *DB) GetTokenCalls(since time.Time) ([]models.TokenCall, error) {
+	query := `
+	SELECT agent_id, status, COALESCE(metadata, '{}'), prompt_tokens + completion_tokens, created_at
+	FROM discussion_logs
+	WHERE agent_id IS NOT NULL AND log_type = ? AND created_at >= ? AND metadata LIKE '%"token_fingerprint"%'
+	`
+
+	rows, err := db.Query(query, models.LogTypeResponse, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query token calls: %w", err)
+	}
+	defer rows.Close()
+
+	var calls []models.TokenCall
+	for rows.Next() {
+		var call models.TokenCall
+		var metadata models.JSONMap
+		if err := rows.Scan(&call.AgentID, &call.Status, &metadata, &call.TotalTokens, &call.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan token call: %w", err)
+		}
+		call.Fingerprint = metadata["token_fingerprint"]
+		call.ErrorClass = metadata["error_class"]
+		// Entries written before token columns only carry usage in metadata
+		if call.TotalTokens == 0 {
+			call.TotalTokens, _ = strconv.Atoi(metadata["total_tokens"])
+		}
+		calls = append(calls, call)
+	}
+
+	return calls, rows.Err()
+}
+
+// GetAgentTokenHistory returns the average reply size of each agent's
+// successful calls since the given time that reported token usage
+func (db *DB) GetAgentTokenHistory(since time.Time) (map[int64]models.AgentTokenHistory, error) {
+	query := `
+	SELECT agent_id, COUNT(*), AVG(completion_tokens)
+	FROM discussion_logs
+	WHERE agent_id IS NOT NULL AND log_type = ? AND status = 'success' AND completion_tokens > 0 AND created_at >= ?
+	GROUP BY agent_id
+	`
+
+	rows, err := db.Query(query, models.LogTypeResponse, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query agent token history: %w", err)
+	}
+	defer rows.Close()
+
+	history := make(map[int64]models.AgentTokenHistory)
+	for rows.Next() {
+		var h models.AgentTokenHistory
+		if err := rows.Scan(&h.AgentID, &h.Calls, &h.AvgCompletionTokens); err != nil {
+			return nil, fmt.Errorf("failed to scan agent token history: %w", err)
+		}
+		history[h.AgentID] = h
+	}
+
+	return history, rows.Err()
+}
+
+// GetDiscussionLog retrieves a single log entry by ID
+func (db *DB) GetDiscussionLog(id int64) (*models.DiscussionLog, error) {
+	query := `
+	SELECT id, discussion_id, agent_id, COALESCE(content, ''), status, response_time, is_moderator, COALESCE(metadata, '{}'), log_type, sequence, prompt_tokens, completion_tokens, created_at
+	FROM discussion_logs WHERE id = ?
+	`
+
+	log := &models.DiscussionLog{}
+	var agentID sql.NullInt64
+	err := db.QueryRow(query, id).Scan(
+		&log.ID, &log.DiscussionID, &agentID, &log.Content,
+		&log.Status, &log.ResponseTime, &log.IsModerator, &log.Metadata, &log.LogType, &log.Sequence,
+		&log.PromptTokens, &log.CompletionTokens, &log.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("discussion log not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get discussion log: %w", err)
 	}
 
-	log.ID = id
+	log.AgentID = agentID.Int64
+	return log, nil
+}
+
+// UpdateDiscussionLogMetadata replaces the metadata of a log entry
+func (db *DB) UpdateDiscussionLogMetadata(id int64, metadata models.JSONMap) error {
+	if _, err := db.Exec(`UPDATE discussion_logs SET metadata = ? WHERE id = ?`, metadata, id); err != nil {
+		return fmt.Errorf("failed to update discussion log metadata: %w", err)
+	}
 	return nil
 }
 
 // GetDiscussionLogs retrieves all logs for a discussion
 func (db *DB) GetDiscussionLogs(discussionID int64) ([]*models.DiscussionLog, error) {
-	query := `
-	SELECT id, discussion_id, agent_id, COALESCE(content, ''), status, response_time, is_moderator, created_at
-	FROM discussion_logs WHERE discussion_id = ? ORDER BY created_at ASC
-	`
-	
-	rows, err := db.Query(query, discussionID)
+	return db.queryDiscussionLogs(`
+	SELECT id, discussion_id, agent_id, COALESCE(content, ''), status, response_time, is_moderator, COALESCE(metadata, '{}'), log_type, sequence, prompt_tokens, completion_tokens, created_at
+	FROM discussion_logs WHERE discussion_id = ? ORDER BY created_at ASC, id ASC
+	`, discussionID)
+}
+
+// GetDiscussionLogsAfter retrieves the logs of a discussion written after the
+// entry afterID, in the order they were written. This is the durable cursor
+// of the discussion stream: sequence numbers strictly increase within a
+// discussion (assigned on insert and unique per discussion), so the entries
+// after the cursor are exactly those a client that has seen afterID is
+// missing. An afterID that is not an entry of the discussion returns every
+// entry.
+func (db *DB) GetDiscussionLogsAfter(discussionID, afterID int64) ([]*models.DiscussionLog, error) {
+	return db.queryDiscussionLogs(`
+	SELECT id, discussion_id, agent_id, COALESCE(content, ''), status, response_time, is_moderator, COALESCE(metadata, '{}'), log_type, sequence, prompt_tokens, completion_tokens, created_at
+	FROM discussion_logs
+	WHERE discussion_id = ? AND sequence > COALESCE((SELECT sequence FROM discussion_logs WHERE id = ? AND discussion_id = ?), 0)
+	ORDER BY sequence ASC
+	`, discussionID, afterID, discussionID)
+}
+
+// LastDiscussionLogID returns the ID of the latest log of a discussion, or 0
+// when it has none
+func (db *DB) LastDiscussionLogID(discussionID int64) (int64, error) {
+	var id int64
+	err := db.QueryRow(`SELECT id FROM discussion_logs WHERE discussion_id = ? ORDER BY sequence DESC LIMIT 1`, discussionID).Scan(&id)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to get last discussion log: %w", err)
+	}
+	return id, nil
+}
+
+func (db *DB) queryDiscussionLogs(query string, args ...interface{}) ([]*models.DiscussionLog, error) {
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query discussion logs: %w", err)
 	}
@@ -387,32 +1063,49 @@ func (db *DB) GetDiscussionLogs(discussionID int64) ([]*models.DiscussionLog, er
 	var logs []*models.DiscussionLog
 	for rows.Next() {
 		log := &models.DiscussionLog{}
+		var agentID sql.NullInt64
 		err := rows.Scan(
-			&log.ID, &log.DiscussionID, &log.AgentID, &log.Content,
-			&log.Status, &log.ResponseTime, &log.IsModerator, &log.CreatedAt,
+			&log.ID, &log.DiscussionID, &agentID, &log.Content,
+			&log.Status, &log.ResponseTime, &log.IsModerator, &log.Metadata, &log.LogType, &log.Sequence,
+			&log.PromptTokens, &log.CompletionTokens, &log.CreatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan discussion log: %w", err)
 		}
+		log.AgentID = agentID.Int64
 		logs = append(logs, log)
 	}
 
 	return logs, nil
 }
 
-// UpdateDiscussion updates a discussion
-func (db *DB) UpdateDiscussion(discussion *models.Discussion) error {
-	query := `
-	UPDATE discussions 
-	SET topic = ?, final_summary = ?, status = ?, agent_ids = ?, moderator_id = ?, updated_at = ?
-	WHERE id = ?
-	`
-	
-	discussion.UpdatedAt = time.Now()
-	result, err := db.Exec(query, discussion.Topic, discussion.FinalSummary,
-		discussion.Status, discussion.AgentIDs, discussion.ModeratorID, discussion.UpdatedAt, discussion.ID)
+// UpdateDiscussionStatus ends a running or paused discussion with the given
+// status, end reason and error message, leaving every other column alone. It
+// reports false, changing nothing, when the discussion had already ended, so
+// an outcome recorded elsewhere (a stop, a watchdog failure) is never
+// overwritten.
+func (db *DB) UpdateDiscussionStatus(id int64, status, endReason, errorMessage string) (bool, error) {
+	result, err := db.Exec(`
+	UPDATE discussions SET status = ?, end_reason = ?, error_message = ?, updated_at = ?
+	WHERE id = ? AND status IN ('running', 'paused')`,
+		status, endReason, errorMessage, time.Now(), id)
+	if err != nil {
+		return false, fmt.Errorf("failed to update discussion status: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// UpdateDiscussionSummary stores the final summary of a discussion
+func (db *DB) UpdateDiscussionSummary(id int64, summary string) error {
+	result, err := db.Exec(`UPDATE discussions SET final_summary = ?, updated_at = ? WHERE id = ? AND status != 'deleting'`,
+		summary, time.Now(), id)
 	if err != nil {
-		return fmt.Errorf("failed to update discussion: %w", err)
+		return fmt.Errorf("failed to update discussion summary: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -427,6 +1120,177 @@ func (db *DB) UpdateDiscussion(discussion *models.Discussion) error {
 	return nil
 }
 
+// RestartDiscussion sets a failed discussion running again from the start,
+// clearing its outcome. It reports false when the discussion was not failed.
+func (db *DB) RestartDiscussion(id int64) (bool, error) {
+	result, err := db.Exec(`
+	UPDATE discussions SET status = 'running', error_message = '', final_summary = '', completed_rounds = 0, end_reason = '', updated_at = ?
+	WHERE id = ? AND status = 'failed'`,
+		time.Now(), id)
+	if err != nil {
+		return false, fmt.Errorf("failed to restart discussion: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// UpdateDiscussionProgress records the rounds a running or paused discussion
+// has completed so far. It leaves discussions that have ended alone.
+func (db *DB) UpdateDiscussionProgress(id int64, completedRounds int) error {
+	_, err := db.Exec(`UPDATE discussions SET completed_rounds = ?, updated_at = ? WHERE id = ? AND status IN ('running', 'paused')`,
+		completedRounds, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update discussion progress: %w", err)
+	}
+	return nil
+}
+
+// SwitchDiscussionStatus moves a discussion from one status to another,
+// leaving every other column alone. It reports whether the discussion was in
+// the from status.
+func (db *DB) SwitchDiscussionStatus(id int64, from, to string) (bool, error) {
+	result, err := db.Exec(`UPDATE discussions SET status = ?, updated_at = ? WHERE id = ? AND status = ?`, to, time.Now(), id, from)
+	if err != nil {
+		return false, fmt.Errorf("failed to update discussion status: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// MarkDiscussionDeleting flags a discussion for background deletion so it
+// disappears from lists immediately. A running or paused discussion is left
+// alone with ErrDiscussionLive unless force is set; the check and the update
+// are one statement, so a debate resumed in between cannot slip through.
+func (db *DB) MarkDiscussionDeleting(id int64, force bool) error {
+	query := `UPDATE discussions SET status = 'deleting', updated_at = ? WHERE id = ?`
+	if !force {
+		query += ` AND status NOT IN ('running', 'paused')`
+	}
+	result, err := db.Exec(query, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to mark discussion for deletion: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		var exists bool
+		if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM discussions WHERE id = ?)`, id).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check discussion: %w", err)
+		}
+		if exists {
+			return ErrDiscussionLive
+		}
+		return ErrDiscussionNotFound
+	}
+
+	return nil
+}
+
+// GetDeletingDiscussionIDs returns discussions whose background deletion has not finished
+func (db *DB) GetDeletingDiscussionIDs() ([]int64, error) {
+	rows, err := db.Query(`SELECT id FROM discussions WHERE status = 'deleting'`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deleting discussions: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan discussion id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// CountDiscussionLogs returns the number of log entries for a discussion
+func (db *DB) CountDiscussionLogs(discussionID int64) (int64, error) {
+	var count int64
+	if err := db.QueryRow(`SELECT COUNT(*) FROM discussion_logs WHERE discussion_id = ?`, discussionID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count discussion logs: %w", err)
+	}
+	return count, nil
+}
+
+// DeleteDiscussionLogsBatch deletes up to limit log entries of a discussion,
+// with their annotations and ratings, and returns how many entries were removed
+func (db *DB) DeleteDiscussionLogsBatch(discussionID int64, limit int) (int64, error) {
+	batch := `SELECT id FROM discussion_logs WHERE discussion_id = ? ORDER BY id LIMIT ?`
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Annotations and ratings go with their log entries. Foreign keys are
+	// only enforced on some pooled connections, so this does not rely on cascade.
+	if _, err := tx.Exec(`DELETE FROM discussion_annotations WHERE log_id IN (`+batch+`)`, discussionID, limit); err != nil {
+		return 0, fmt.Errorf("failed to delete discussion annotations: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM discussion_log_ratings WHERE log_id IN (`+batch+`)`, discussionID, limit); err != nil {
+		return 0, fmt.Errorf("failed to delete log ratings: %w", err)
+	}
+
+	result, err := tx.Exec(`DELETE FROM discussion_logs WHERE id IN (`+batch+`)`, discussionID, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete discussion logs: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit log deletion: %w", err)
+	}
+	return deleted, nil
+}
+
+// PurgeDiscussion deletes a small discussion and everything recorded for it
+// at once. Large discussions go through the batched background delete.
+func (db *DB) PurgeDiscussion(id int64) error {
+	for {
+		deleted, err := db.DeleteDiscussionLogsBatch(id, 500)
+		if err != nil {
+			return err
+		}
+		if deleted < 500 {
+			break
+		}
+	}
+	for _, del := range []func(int64) error{
+		db.DeleteDiscussionNotes,
+		db.DeleteDiscussionClaims,
+		db.DeleteDiscussionAnalysis,
+		db.DeleteAgentScratchpads,
+		db.DeleteDiscussionParticipants,
+		db.DeleteWebhookDeliveries,
+		db.DeleteDiscussion,
+	} {
+		if err := del(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // DeleteDiscussion deletes a discussion by ID
 func (db *DB) DeleteDiscussion(id int64) error {
 	query := `DELETE FROM discussions WHERE id = ?`
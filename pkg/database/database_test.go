@@ -0,0 +1,817 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/models"
+)
+
+func TestWithBusyTimeout(t *testing.T) {
+	tests := []struct{ dsn, want string }{
+		{"court.db", "court.db?_pragma=busy_timeout(5000)"},
+		{"file:court.db?mode=rwc", "file:court.db?mode=rwc&_pragma=busy_timeout(5000)"},
+		{"court.db?_pragma=busy_timeout(100)", "court.db?_pragma=busy_timeout(100)"},
+	}
+	for _, tt := range tests {
+		if got := withBusyTimeout(tt.dsn); got != tt.want {
+			t.Errorf("withBusyTimeout(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+	}
+}
+
+// newTestDB opens a fresh database with every table and migration applied
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.CreateTables(); err != nil {
+		t.Fatalf("CreateTables: %v", err)
+	}
+	return db
+}
+
+func insertTestAgent(t *testing.T, db *DB, name string) *models.Agent {
+	t.Helper()
+	agent := &models.Agent{
+		Name:           name,
+		ProviderType:   "openai",
+		ProviderURL:    "http://127.0.0.1:1",
+		APIToken:       "sk-test",
+		ModelName:      "test-model",
+		TimeoutSeconds: 30,
+		ExtraHeaders:   models.JSONMap{"X-Key": "secret"},
+	}
+	if err := db.InsertAgent(agent); err != nil {
+		t.Fatalf("InsertAgent(%q): %v", name, err)
+	}
+	return agent
+}
+
+func TestDeleteAgentKeepsTranscript(t *testing.T) {
+	db := newTestDB(t)
+	alice := insertTestAgent(t, db, "Alice")
+	bob := insertTestAgent(t, db, "Bob")
+
+	discussion := &models.Discussion{
+		Topic:       "Tabs or spaces",
+		Status:      "completed",
+		AgentIDs:    models.JSONSlice[int64]{alice.ID, bob.ID},
+		ModeratorID: &alice.ID,
+		MaxRounds:   1,
+	}
+	if err := db.InsertDiscussion(discussion); err != nil {
+		t.Fatalf("InsertDiscussion: %v", err)
+	}
+	for _, agent := range []*models.Agent{alice, bob} {
+		entry := &models.DiscussionLog{DiscussionID: discussion.ID, AgentID: agent.ID, Content: agent.Name + " speaks", Status: "success"}
+		if err := db.InsertDiscussionLog(entry); err != nil {
+			t.Fatalf("InsertDiscussionLog: %v", err)
+		}
+	}
+
+	if err := db.DeleteAgent(alice.ID); err != nil {
+		t.Fatalf("DeleteAgent: %v", err)
+	}
+
+	logs, err := db.GetDiscussionLogs(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	if len(logs) != 2 || logs[0].AgentID != alice.ID || logs[0].Content != "Alice speaks" {
+		t.Fatalf("transcript after delete = %+v, want both turns kept", logs)
+	}
+	stored, err := db.GetDiscussion(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussion: %v", err)
+	}
+	if stored.ModeratorID == nil || *stored.ModeratorID != alice.ID {
+		t.Errorf("moderator_id = %v, want %d kept", stored.ModeratorID, alice.ID)
+	}
+
+	if _, err := db.GetAgent(alice.ID); err == nil {
+		t.Error("GetAgent found the deleted agent")
+	}
+	if found, err := db.GetAgentsByIDs([]int64{alice.ID, bob.ID}); err != nil || len(found) != 1 || found[bob.ID] == nil {
+		t.Errorf("GetAgentsByIDs = %v, %v; want only Bob", found, err)
+	}
+	if deleted, err := db.IsAgentDeleted(alice.ID); err != nil || !deleted {
+		t.Errorf("IsAgentDeleted(alice) = %v, %v; want true", deleted, err)
+	}
+	if deleted, err := db.IsAgentDeleted(bob.ID); err != nil || deleted {
+		t.Errorf("IsAgentDeleted(bob) = %v, %v; want false", deleted, err)
+	}
+	if count, err := db.CountAgents(); err != nil || count != 1 {
+		t.Errorf("CountAgents = %d, %v; want 1", count, err)
+	}
+
+	name, err := db.GetAgentName(alice.ID)
+	if err != nil {
+		t.Fatalf("GetAgentName: %v", err)
+	}
+	if name != "Alice" {
+		t.Errorf("GetAgentName = %q, want the name unchanged", name)
+	}
+
+	live, err := db.GetAllAgents()
+	if err != nil || len(live) != 1 || live[0].ID != bob.ID {
+		t.Errorf("GetAllAgents = %v, %v; want only Bob", live, err)
+	}
+	all, err := db.GetAllAgentsWithDeleted()
+	if err != nil || len(all) != 2 {
+		t.Fatalf("GetAllAgentsWithDeleted = %v, %v; want both agents", all, err)
+	}
+	for _, agent := range all {
+		if agent.ID != alice.ID {
+			continue
+		}
+		if agent.APIToken != "" || len(agent.ExtraHeaders) != 0 || !agent.Disabled {
+			t.Errorf("deleted agent kept credentials or is enabled: token %q, headers %v, disabled %v", agent.APIToken, agent.ExtraHeaders, agent.Disabled)
+		}
+	}
+
+	// The name is free for one new agent, and the deleted one stays deleted
+	insertTestAgent(t, db, "Alice")
+	if err := db.InsertAgent(&models.Agent{Name: "Alice", ProviderType: "openai", ProviderURL: "http://127.0.0.1:1", ModelName: "m", TimeoutSeconds: 30}); !errors.Is(err, ErrAgentNameExists) {
+		t.Errorf("a second live Alice = %v, want ErrAgentNameExists", err)
+	}
+	if name, _ := db.GetAgentName(alice.ID); name != "Alice" {
+		t.Errorf("deleted agent renamed to %q", name)
+	}
+	if err := db.DeleteAgent(alice.ID); err == nil {
+		t.Error("deleting an agent twice succeeded")
+	}
+	alice.Name = "Alice again"
+	if err := db.UpdateAgent(alice); err == nil {
+		t.Error("UpdateAgent changed a deleted agent")
+	}
+}
+
+func TestMarkDiscussionDeleting(t *testing.T) {
+	db := newTestDB(t)
+	for _, tt := range []struct {
+		status string
+		force  bool
+		want   error
+	}{
+		{"completed", false, nil},
+		{"running", false, ErrDiscussionLive},
+		{"paused", false, ErrDiscussionLive},
+		{"running", true, nil},
+	} {
+		discussion := &models.Discussion{Topic: "Tabs or spaces", Status: tt.status, MaxRounds: 1}
+		if err := db.InsertDiscussion(discussion); err != nil {
+			t.Fatalf("InsertDiscussion: %v", err)
+		}
+		if err := db.MarkDiscussionDeleting(discussion.ID, tt.force); !errors.Is(err, tt.want) {
+			t.Errorf("MarkDiscussionDeleting(%s, force %v) = %v, want %v", tt.status, tt.force, err, tt.want)
+		}
+		stored, err := db.GetDiscussion(discussion.ID)
+		if err != nil {
+			t.Fatalf("GetDiscussion: %v", err)
+		}
+		if wantStatus := map[bool]string{true: tt.status, false: "deleting"}[tt.want != nil]; stored.Status != wantStatus {
+			t.Errorf("status after MarkDiscussionDeleting(%s, force %v) = %s, want %s", tt.status, tt.force, stored.Status, wantStatus)
+		}
+	}
+
+	if err := db.MarkDiscussionDeleting(999, false); !errors.Is(err, ErrDiscussionNotFound) {
+		t.Errorf("MarkDiscussionDeleting(unknown) = %v, want ErrDiscussionNotFound", err)
+	}
+	if _, err := db.GetDiscussion(999); !errors.Is(err, ErrDiscussionNotFound) {
+		t.Errorf("GetDiscussion(unknown) = %v, want ErrDiscussionNotFound", err)
+	}
+}
+
+func TestSystemLogsAreNotAgentCalls(t *testing.T) {
+	db := newTestDB(t)
+	alice := insertTestAgent(t, db, "Alice")
+	discussion := &models.Discussion{Topic: "Tabs or spaces", Status: "completed", AgentIDs: models.JSONSlice[int64]{alice.ID}, MaxRounds: 1}
+	if err := db.InsertDiscussion(discussion); err != nil {
+		t.Fatalf("InsertDiscussion: %v", err)
+	}
+	metadata := models.JSONMap{"token_fingerprint": "abcd"}
+	entries := []*models.DiscussionLog{
+		{DiscussionID: discussion.ID, AgentID: alice.ID, Content: "Spaces.", Status: "success", PromptTokens: 10, CompletionTokens: 5, Metadata: metadata},
+		// An extractive summary: a response authored by the system participant
+		{DiscussionID: discussion.ID, AgentID: models.SystemAgentID, Content: "Summary", Status: "success", PromptTokens: 7, Metadata: metadata},
+		{DiscussionID: discussion.ID, AgentID: models.SystemAgentID, Content: "Watchdog", Status: "error", LogType: models.LogTypeSystem},
+	}
+	for _, e := range entries {
+		if err := db.InsertDiscussionLog(e); err != nil {
+			t.Fatalf("InsertDiscussionLog(%q): %v", e.Content, err)
+		}
+	}
+
+	var nulls int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM discussion_logs WHERE agent_id IS NULL`).Scan(&nulls); err != nil {
+		t.Fatalf("count NULL authors: %v", err)
+	}
+	if nulls != 2 {
+		t.Errorf("%d entries stored without an agent, want the 2 system entries", nulls)
+	}
+
+	logs, err := db.GetDiscussionLogs(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	if len(logs) != 3 || logs[0].IsSystem() || !logs[1].IsSystem() || !logs[2].IsSystem() {
+		t.Fatalf("logs = %+v, want Alice then two system entries", logs)
+	}
+
+	since := discussion.CreatedAt.Add(-time.Hour)
+	outcomes, err := db.GetAgentOutcomes(since)
+	if err != nil {
+		t.Fatalf("GetAgentOutcomes: %v", err)
+	}
+	if len(outcomes) != 1 || outcomes[0].AgentID != alice.ID {
+		t.Errorf("outcomes = %+v, want only Alice's call", outcomes)
+	}
+	calls, err := db.GetTokenCalls(since)
+	if err != nil {
+		t.Fatalf("GetTokenCalls: %v", err)
+	}
+	if len(calls) != 1 || calls[0].AgentID != alice.ID {
+		t.Errorf("token calls = %+v, want only Alice's call", calls)
+	}
+}
+
+func TestDashboardQueries(t *testing.T) {
+	db := newTestDB(t)
+	alice := insertTestAgent(t, db, "Alice")
+	insertTestAgent(t, db, "Bob")
+	if err := db.DeleteAgent(alice.ID); err != nil {
+		t.Fatalf("DeleteAgent: %v", err)
+	}
+
+	statuses := []string{"completed", "completed", "running", "failed", "completed", "deleting", "stopped"}
+	base := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i, status := range statuses {
+		d := &models.Discussion{Topic: "Topic " + strconv.Itoa(i), Status: status, MaxRounds: 1}
+		if err := db.InsertDiscussion(d); err != nil {
+			t.Fatalf("InsertDiscussion: %v", err)
+		}
+		if _, err := db.Exec(`UPDATE discussions SET created_at = ? WHERE id = ?`, base.Add(time.Duration(i)*time.Hour), d.ID); err != nil {
+			t.Fatalf("backdating discussion: %v", err)
+		}
+	}
+	// A running self-test is the newest discussion but is never listed
+	selfTest := &models.Discussion{Topic: "Self-test", Status: "running", MaxRounds: 1, SelfTest: true}
+	if err := db.InsertDiscussion(selfTest); err != nil {
+		t.Fatalf("InsertDiscussion: %v", err)
+	}
+
+	if n, err := db.CountAgents(); err != nil || n != 1 {
+		t.Errorf("CountAgents = %d, %v; want 1 without the deleted agent", n, err)
+	}
+
+	counts, err := db.CountDiscussionsByStatus()
+	if err != nil {
+		t.Fatalf("CountDiscussionsByStatus: %v", err)
+	}
+	want := map[string]int{"completed": 3, "running": 1, "failed": 1, "stopped": 1}
+	if len(counts) != len(want) {
+		t.Errorf("counts = %v, want %v", counts, want)
+	}
+	for status, n := range want {
+		if counts[status] != n {
+			t.Errorf("counts[%q] = %d, want %d", status, counts[status], n)
+		}
+	}
+
+	recent, err := db.GetRecentDiscussions(3)
+	if err != nil {
+		t.Fatalf("GetRecentDiscussions: %v", err)
+	}
+	var topics []string
+	for _, d := range recent {
+		topics = append(topics, d.Topic)
+	}
+	// Newest first, skipping the discussion being deleted and the self-test
+	if len(topics) != 3 || topics[0] != "Topic 6" || topics[1] != "Topic 4" || topics[2] != "Topic 3" {
+		t.Errorf("recent = %v, want Topic 6, 4 and 3", topics)
+	}
+
+	page, err := db.GetDiscussionsPage(models.DiscussionQuery{Status: "running"})
+	if err != nil {
+		t.Fatalf("GetDiscussionsPage: %v", err)
+	}
+	if page.Total != 1 || len(page.Items) != 1 || page.Items[0].ID == selfTest.ID {
+		t.Errorf("running discussions = %d %v, want one without the self-test", page.Total, page.Items)
+	}
+	if _, err := db.GetDiscussion(selfTest.ID); err != nil {
+		t.Errorf("GetDiscussion of the self-test = %v, want it readable by ID", err)
+	}
+}
+
+func TestDeletingLogsRemovesTheirAnnotations(t *testing.T) {
+	db := newTestDB(t)
+	alice := insertTestAgent(t, db, "Alice")
+	discussion := &models.Discussion{Topic: "Tabs or spaces", Status: "completed", AgentIDs: models.JSONSlice[int64]{alice.ID}, MaxRounds: 1}
+	if err := db.InsertDiscussion(discussion); err != nil {
+		t.Fatalf("InsertDiscussion: %v", err)
+	}
+	var logs []*models.DiscussionLog
+	for i := 0; i < 3; i++ {
+		l := &models.DiscussionLog{DiscussionID: discussion.ID, AgentID: alice.ID, Content: "Spaces, always.", Status: "success"}
+		if err := db.InsertDiscussionLog(l); err != nil {
+			t.Fatalf("InsertDiscussionLog: %v", err)
+		}
+		logs = append(logs, l)
+		a := &models.DiscussionAnnotation{DiscussionID: discussion.ID, LogID: l.ID, StartOffset: 0, EndOffset: 6, Comment: "note " + strconv.Itoa(i)}
+		if err := db.InsertDiscussionAnnotation(a); err != nil {
+			t.Fatalf("InsertDiscussionAnnotation: %v", err)
+		}
+	}
+
+	// A partial batch only takes the annotations of the entries it removes
+	if n, err := db.DeleteDiscussionLogsBatch(discussion.ID, 2); err != nil || n != 2 {
+		t.Fatalf("DeleteDiscussionLogsBatch = %d, %v", n, err)
+	}
+	annotations, err := db.GetDiscussionAnnotations(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionAnnotations: %v", err)
+	}
+	if len(annotations) != 1 || annotations[0].LogID != logs[2].ID {
+		t.Errorf("annotations left = %+v, want only the one on the remaining entry", annotations)
+	}
+
+	if err := db.PurgeDiscussion(discussion.ID); err != nil {
+		t.Fatalf("PurgeDiscussion: %v", err)
+	}
+	var left int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM discussion_annotations`).Scan(&left); err != nil || left != 0 {
+		t.Errorf("%d annotations left after the purge (%v), want none", left, err)
+	}
+}
+
+func TestAgentNameMigration(t *testing.T) {
+	db := newTestDB(t)
+	alice := insertTestAgent(t, db, "Alice")
+	bob := insertTestAgent(t, db, "Bob (deleted #7)")
+	if err := db.DeleteAgent(alice.ID); err != nil {
+		t.Fatalf("DeleteAgent: %v", err)
+	}
+	// As an earlier DeleteAgent stored it
+	if _, err := db.Exec(`UPDATE agents SET name = name || ' (deleted #' || id || ')' WHERE id = ?`, alice.ID); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+
+	m := migrations[25]
+	if m.version != 26 {
+		t.Fatalf("migrations[25] is version %d, want 26", m.version)
+	}
+	if err := m.up(db); err != nil {
+		t.Fatalf("migration: %v", err)
+	}
+	if name, err := db.GetAgentName(alice.ID); err != nil || name != "Alice" {
+		t.Errorf("deleted agent name = %q, %v, want the suffix dropped", name, err)
+	}
+	// Only the suffix with the agent's own ID is dropped, and live agents are untouched
+	if name, err := db.GetAgentName(bob.ID); err != nil || name != "Bob (deleted #7)" {
+		t.Errorf("live agent name = %q, %v", name, err)
+	}
+	insertTestAgent(t, db, "Alice")
+	if err := db.InsertAgent(&models.Agent{Name: "Bob (deleted #7)", ProviderType: "openai", ProviderURL: "http://127.0.0.1:1", ModelName: "m", TimeoutSeconds: 30}); !errors.Is(err, ErrAgentNameExists) {
+		t.Errorf("duplicate live name after the rebuild = %v, want ErrAgentNameExists", err)
+	}
+}
+
+func TestNormalizeLanguages(t *testing.T) {
+	db := newTestDB(t)
+	stored := map[string]string{"English": "en", "bahasa indonesia": "id", "fr": "fr", "Klingon": "Klingon"}
+	ids := make(map[string]int64)
+	for language := range stored {
+		d := &models.Discussion{Topic: "Tabs or spaces", Status: "completed", MaxRounds: 1, Language: language}
+		if err := db.InsertDiscussion(d); err != nil {
+			t.Fatalf("InsertDiscussion: %v", err)
+		}
+		ids[language] = d.ID
+	}
+
+	if err := db.normalizeLanguages(); err != nil {
+		t.Fatalf("normalizeLanguages: %v", err)
+	}
+	for language, want := range stored {
+		d, err := db.GetDiscussion(ids[language])
+		if err != nil {
+			t.Fatalf("GetDiscussion: %v", err)
+		}
+		if d.Language != want {
+			t.Errorf("stored %q became %q, want %q", language, d.Language, want)
+		}
+	}
+}
+
+func TestAgentPersonaRoundTrip(t *testing.T) {
+	db := newTestDB(t)
+	agent := &models.Agent{
+		Name:           "Economist",
+		ProviderType:   "openai",
+		ProviderURL:    "http://127.0.0.1:1",
+		ModelName:      "test-model",
+		TimeoutSeconds: 30,
+		SystemPrompt:   "You are a skeptical economist.\nCite numbers.",
+	}
+	if err := db.InsertAgent(agent); err != nil {
+		t.Fatalf("InsertAgent: %v", err)
+	}
+	plain := insertTestAgent(t, db, "Plain")
+
+	stored, err := db.GetAgent(agent.ID)
+	if err != nil || stored.SystemPrompt != agent.SystemPrompt {
+		t.Fatalf("GetAgent persona = %q, %v", stored.SystemPrompt, err)
+	}
+
+	stored.SystemPrompt = "You argue from a legal perspective."
+	if err := db.UpdateAgent(stored); err != nil {
+		t.Fatalf("UpdateAgent: %v", err)
+	}
+	all, err := db.GetAllAgents()
+	if err != nil {
+		t.Fatalf("GetAllAgents: %v", err)
+	}
+	personas := map[int64]string{}
+	for _, a := range all {
+		personas[a.ID] = a.SystemPrompt
+	}
+	if personas[agent.ID] != "You argue from a legal perspective." || personas[plain.ID] != "" {
+		t.Errorf("listed personas = %q", personas)
+	}
+}
+
+func TestAgentScratchpads(t *testing.T) {
+	db := newTestDB(t)
+	alice := insertTestAgent(t, db, "Alice")
+	discussion := &models.Discussion{Topic: "Tabs or spaces", Status: "running", AgentIDs: models.JSONSlice[int64]{alice.ID}, MaxRounds: 3}
+	if err := db.InsertDiscussion(discussion); err != nil {
+		t.Fatalf("InsertDiscussion: %v", err)
+	}
+
+	if pad, err := db.GetAgentScratchpad(discussion.ID, alice.ID); err != nil || pad != nil {
+		t.Errorf("scratchpad before any notes = %+v, %v; want none", pad, err)
+	}
+	for round, notes := range []string{"Answer the width point", "Raise diff noise"} {
+		if err := db.SaveAgentScratchpad(&models.AgentScratchpad{DiscussionID: discussion.ID, AgentID: alice.ID, Notes: notes, Round: round + 1}); err != nil {
+			t.Fatalf("SaveAgentScratchpad: %v", err)
+		}
+	}
+	pad, err := db.GetAgentScratchpad(discussion.ID, alice.ID)
+	if err != nil || pad == nil || pad.Notes != "Raise diff noise" || pad.Round != 2 {
+		t.Errorf("scratchpad = %+v, %v; want the latest notes replacing the first", pad, err)
+	}
+
+	if err := db.DeleteAgentScratchpads(discussion.ID); err != nil {
+		t.Fatalf("DeleteAgentScratchpads: %v", err)
+	}
+	if pad, err := db.GetAgentScratchpad(discussion.ID, alice.ID); err != nil || pad != nil {
+		t.Errorf("scratchpad after deleting = %+v, %v; want none", pad, err)
+	}
+}
+
+func TestDiscussionLogCursor(t *testing.T) {
+	db := newTestDB(t)
+	discussion := &models.Discussion{Topic: "Tabs or spaces", Status: "running", MaxRounds: 1}
+	if err := db.InsertDiscussion(discussion); err != nil {
+		t.Fatalf("InsertDiscussion: %v", err)
+	}
+	if id, err := db.LastDiscussionLogID(discussion.ID); err != nil || id != 0 {
+		t.Errorf("LastDiscussionLogID without logs = %d, %v, want 0", id, err)
+	}
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		l := &models.DiscussionLog{DiscussionID: discussion.ID, AgentID: models.SystemAgentID, Content: fmt.Sprintf("entry %d", i), Status: "success", LogType: models.LogTypeSystem}
+		if err := db.InsertDiscussionLog(l); err != nil {
+			t.Fatalf("InsertDiscussionLog: %v", err)
+		}
+		ids = append(ids, l.ID)
+	}
+
+	logIDs := func(logs []*models.DiscussionLog) []int64 {
+		var got []int64
+		for _, l := range logs {
+			got = append(got, l.ID)
+		}
+		return got
+	}
+	for _, tt := range []struct {
+		after int64
+		want  []int64
+	}{{0, ids}, {ids[0], ids[1:]}, {ids[2], nil}, {99999, ids}} {
+		logs, err := db.GetDiscussionLogsAfter(discussion.ID, tt.after)
+		if err != nil {
+			t.Fatalf("GetDiscussionLogsAfter: %v", err)
+		}
+		if got := logIDs(logs); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("logs after %d = %v, want %v", tt.after, got, tt.want)
+		}
+	}
+	if id, err := db.LastDiscussionLogID(discussion.ID); err != nil || id != ids[2] {
+		t.Errorf("LastDiscussionLogID = %d, %v, want %d", id, err, ids[2])
+	}
+
+	// The cursor relies on sequences being unique within a discussion
+	if _, err := db.Exec(`UPDATE discussion_logs SET sequence = (SELECT sequence FROM discussion_logs WHERE id = ?) WHERE id = ?`, ids[0], ids[1]); err == nil {
+		t.Error("two logs of a discussion were given the same sequence")
+	}
+}
+
+func TestDiscussionUpdatesKeepOtherColumns(t *testing.T) {
+	db := newTestDB(t)
+	discussion := &models.Discussion{Topic: "Tabs or spaces", Status: "running", MaxRounds: 3, Language: "en"}
+	if err := db.InsertDiscussion(discussion); err != nil {
+		t.Fatalf("InsertDiscussion: %v", err)
+	}
+	stored := func() *models.Discussion {
+		t.Helper()
+		d, err := db.GetDiscussion(discussion.ID)
+		if err != nil {
+			t.Fatalf("GetDiscussion: %v", err)
+		}
+		return d
+	}
+
+	if err := db.UpdateDiscussionProgress(discussion.ID, 2); err != nil {
+		t.Fatalf("UpdateDiscussionProgress: %v", err)
+	}
+	if updated, err := db.UpdateDiscussionStatus(discussion.ID, "stopped", models.EndReasonStopped, ""); err != nil || !updated {
+		t.Fatalf("UpdateDiscussionStatus(stopped) = %v, %v", updated, err)
+	}
+	if err := db.UpdateDiscussionSummary(discussion.ID, "Spaces won."); err != nil {
+		t.Fatalf("UpdateDiscussionSummary: %v", err)
+	}
+	if d := stored(); d.Status != "stopped" || d.EndReason != models.EndReasonStopped || d.CompletedRounds != 2 || d.FinalSummary != "Spaces won." || d.Topic != "Tabs or spaces" || d.MaxRounds != 3 {
+		t.Errorf("discussion = %+v, want each update kept", d)
+	}
+
+	// An ended discussion keeps its outcome and progress
+	if updated, err := db.UpdateDiscussionStatus(discussion.ID, "completed", models.EndReasonMaxRounds, ""); err != nil || updated {
+		t.Errorf("UpdateDiscussionStatus(completed) after the stop = %v, %v, want false", updated, err)
+	}
+	if err := db.UpdateDiscussionProgress(discussion.ID, 3); err != nil {
+		t.Fatalf("UpdateDiscussionProgress: %v", err)
+	}
+	if d := stored(); d.Status != "stopped" || d.EndReason != models.EndReasonStopped || d.CompletedRounds != 2 {
+		t.Errorf("discussion after late writes = %s (%s) after %d rounds, want stopped after 2", d.Status, d.EndReason, d.CompletedRounds)
+	}
+
+	// Only a failed discussion restarts, from a clean slate
+	if restarted, err := db.RestartDiscussion(discussion.ID); err != nil || restarted {
+		t.Errorf("RestartDiscussion(stopped) = %v, %v, want false", restarted, err)
+	}
+	failed := &models.Discussion{Topic: "Vim or Emacs", Status: "running", MaxRounds: 3}
+	if err := db.InsertDiscussion(failed); err != nil {
+		t.Fatalf("InsertDiscussion: %v", err)
+	}
+	db.UpdateDiscussionProgress(failed.ID, 1)
+	db.UpdateDiscussionStatus(failed.ID, "failed", models.EndReasonFailed, "Alice (timeout)")
+	if restarted, err := db.RestartDiscussion(failed.ID); err != nil || !restarted {
+		t.Fatalf("RestartDiscussion(failed) = %v, %v", restarted, err)
+	}
+	d, err := db.GetDiscussion(failed.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussion: %v", err)
+	}
+	if d.Status != "running" || d.EndReason != "" || d.ErrorMessage != "" || d.CompletedRounds != 0 || d.Topic != "Vim or Emacs" {
+		t.Errorf("restarted discussion = %+v", d)
+	}
+}
+
+func TestConcurrentStopAndProgress(t *testing.T) {
+	db := newTestDB(t)
+	for i := 0; i < 20; i++ {
+		discussion := &models.Discussion{Topic: "Tabs or spaces", Status: "running", MaxRounds: 100}
+		if err := db.InsertDiscussion(discussion); err != nil {
+			t.Fatalf("InsertDiscussion: %v", err)
+		}
+
+		// The engine records rounds while a stop request arrives mid-way
+		progressed := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for round := 1; round <= 100; round++ {
+				if err := db.UpdateDiscussionProgress(discussion.ID, round); err != nil {
+					t.Errorf("UpdateDiscussionProgress: %v", err)
+					return
+				}
+				if round == 5 {
+					close(progressed)
+				}
+			}
+		}()
+		<-progressed
+		if updated, err := db.UpdateDiscussionStatus(discussion.ID, "stopped", models.EndReasonStopped, ""); err != nil || !updated {
+			t.Fatalf("UpdateDiscussionStatus = %v, %v", updated, err)
+		}
+		<-done
+		// The engine ending the debate afterwards does not undo the stop
+		if updated, _ := db.UpdateDiscussionStatus(discussion.ID, "completed", models.EndReasonMaxRounds, ""); updated {
+			t.Error("the engine overwrote the stop")
+		}
+
+		d, err := db.GetDiscussion(discussion.ID)
+		if err != nil {
+			t.Fatalf("GetDiscussion: %v", err)
+		}
+		if d.Status != "stopped" || d.EndReason != models.EndReasonStopped || d.CompletedRounds < 5 {
+			t.Fatalf("discussion ended %s (%s) after %d rounds, want stopped after at least 5", d.Status, d.EndReason, d.CompletedRounds)
+		}
+	}
+}
+
+func TestGetDiscussionsPage(t *testing.T) {
+	db := newTestDB(t)
+	// 45 discussions an hour apart, updated in the opposite order, one of
+	// them being deleted
+	statuses := []string{"completed", "running", "failed"}
+	base := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 45; i++ {
+		status := statuses[i%len(statuses)]
+		if i == 7 {
+			status = "deleting"
+		}
+		d := &models.Discussion{Topic: "Topic " + strconv.Itoa(i), Status: status, MaxRounds: 1}
+		if err := db.InsertDiscussion(d); err != nil {
+			t.Fatalf("InsertDiscussion: %v", err)
+		}
+		if _, err := db.Exec(`UPDATE discussions SET created_at = ?, updated_at = ? WHERE id = ?`,
+			base.Add(time.Duration(i)*time.Hour), base.Add(time.Duration(100-i)*time.Hour), d.ID); err != nil {
+			t.Fatalf("backdating discussion: %v", err)
+		}
+	}
+	topics := func(page *models.DiscussionPage) []string {
+		var got []string
+		for _, d := range page.Items {
+			got = append(got, d.Topic)
+		}
+		return got
+	}
+
+	for _, tt := range []struct {
+		name      string
+		query     models.DiscussionQuery
+		wantLen   int
+		wantFirst string
+		wantLast  string
+	}{
+		{"defaults", models.DiscussionQuery{}, 20, "Topic 44", "Topic 25"},
+		{"second page", models.DiscussionQuery{Page: 2}, 20, "Topic 24", "Topic 4"},
+		{"last page", models.DiscussionQuery{Page: 3}, 4, "Topic 3", "Topic 0"},
+		{"oldest first", models.DiscussionQuery{Order: models.SortAsc, PerPage: 10}, 10, "Topic 0", "Topic 10"},
+		{"least recently updated", models.DiscussionQuery{Sort: models.DiscussionSortUpdatedAt, Order: models.SortAsc, PerPage: 5}, 5, "Topic 44", "Topic 40"},
+		// Equal statuses fall back to id in the same order
+		{"by status", models.DiscussionQuery{Sort: models.DiscussionSortStatus, Order: models.SortAsc, PerPage: 3}, 3, "Topic 0", "Topic 6"},
+		{"past the end", models.DiscussionQuery{Page: 9}, 0, "", ""},
+	} {
+		page, err := db.GetDiscussionsPage(tt.query)
+		if err != nil {
+			t.Fatalf("%s: GetDiscussionsPage: %v", tt.name, err)
+		}
+		got := topics(page)
+		if page.Total != 44 || page.Items == nil || len(got) != tt.wantLen {
+			t.Errorf("%s: page of %d with total %d, want %d of 44", tt.name, len(got), page.Total, tt.wantLen)
+			continue
+		}
+		if tt.wantLen > 0 && (got[0] != tt.wantFirst || got[len(got)-1] != tt.wantLast) {
+			t.Errorf("%s: page = %v, want %s to %s", tt.name, got, tt.wantFirst, tt.wantLast)
+		}
+	}
+
+	for _, q := range []models.DiscussionQuery{
+		{PerPage: models.MaxDiscussionsPerPage + 1},
+		{Page: -1},
+		{Sort: "topic"},
+		{Order: "sideways"},
+	} {
+		if _, err := db.GetDiscussionsPage(q); err == nil {
+			t.Errorf("GetDiscussionsPage(%+v) accepted an invalid query", q)
+		}
+	}
+}
+
+func TestDiscussionFilters(t *testing.T) {
+	db := newTestDB(t)
+	defer func(old bool) { models.LegacyJSONSlices = old }(models.LegacyJSONSlices)
+	models.LegacyJSONSlices = true
+	base := time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local)
+	for i, tt := range []struct {
+		status   string
+		agentIDs string
+		day      int
+	}{
+		{"running", "[3, 5]", 0},
+		{"completed", "[13]", 3},
+		{"running", "[1,3]", 10},
+		// Rows written before agent_ids held JSON
+		{"completed", "1, 3", 20},
+		{"running", "13,31", 29},
+		{"running", "[3]", 30},
+		{"deleting", "[3]", 5},
+	} {
+		d := &models.Discussion{Topic: "Topic " + strconv.Itoa(i), Status: tt.status, MaxRounds: 1}
+		if err := db.InsertDiscussion(d); err != nil {
+			t.Fatalf("InsertDiscussion: %v", err)
+		}
+		if _, err := db.Exec(`UPDATE discussions SET agent_ids = ?, created_at = ? WHERE id = ?`, tt.agentIDs, base.AddDate(0, 0, tt.day).Add(12*time.Hour), d.ID); err != nil {
+			t.Fatalf("backdating discussion: %v", err)
+		}
+	}
+
+	june := base.AddDate(0, 1, 0)
+	for _, tt := range []struct {
+		name  string
+		query models.DiscussionQuery
+		want  []string
+	}{
+		{"agent", models.DiscussionQuery{AgentID: 3}, []string{"Topic 5", "Topic 3", "Topic 2", "Topic 0"}},
+		{"agent with a similar ID", models.DiscussionQuery{AgentID: 13}, []string{"Topic 4", "Topic 1"}},
+		{"status", models.DiscussionQuery{Status: "completed"}, []string{"Topic 3", "Topic 1"}},
+		{"date range", models.DiscussionQuery{From: base.AddDate(0, 0, 3), Until: base.AddDate(0, 0, 21)}, []string{"Topic 3", "Topic 2", "Topic 1"}},
+		{"combined", models.DiscussionQuery{Status: "running", AgentID: 3, From: base, Until: june}, []string{"Topic 2", "Topic 0"}},
+		{"nothing matches", models.DiscussionQuery{Status: "failed"}, nil},
+	} {
+		page, err := db.GetDiscussionsPage(tt.query)
+		if err != nil {
+			t.Fatalf("%s: GetDiscussionsPage: %v", tt.name, err)
+		}
+		var got []string
+		for _, d := range page.Items {
+			got = append(got, d.Topic)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) || page.Total != len(tt.want) {
+			t.Errorf("%s: got %v of %d, want %v", tt.name, got, page.Total, tt.want)
+		}
+	}
+
+	if _, err := db.GetDiscussionsPage(models.DiscussionQuery{Status: "pending"}); err == nil {
+		t.Error("GetDiscussionsPage accepted an unknown status")
+	}
+}
+
+func TestInsertAgentNameExists(t *testing.T) {
+	db := newTestDB(t)
+	insertTestAgent(t, db, "Alice")
+
+	dup := &models.Agent{Name: "Alice", ProviderType: "openai", ProviderURL: "http://127.0.0.1:2", ModelName: "other", TimeoutSeconds: 30}
+	if err := db.InsertAgent(dup); !errors.Is(err, ErrAgentNameExists) {
+		t.Fatalf("InsertAgent with a taken name = %v, want ErrAgentNameExists", err)
+	}
+	if dup.ID != 0 {
+		t.Errorf("rejected agent got ID %d", dup.ID)
+	}
+	agents, err := db.GetAllAgents()
+	if err != nil || len(agents) != 1 {
+		t.Errorf("agents = %d, %v, want only the first Alice", len(agents), err)
+	}
+}
+
+func TestGetLiveDiscussionIDsUsingAgent(t *testing.T) {
+	db := newTestDB(t)
+	var agents []*models.Agent
+	for i := 0; i < 12; i++ {
+		agents = append(agents, insertTestAgent(t, db, "Agent "+strconv.Itoa(i+1)))
+	}
+	alice, bob, eleventh := agents[0], agents[1], agents[10]
+
+	insert := func(status string, agentIDs []int64, moderator, judge *int64) int64 {
+		t.Helper()
+		d := &models.Discussion{Topic: "Tabs or spaces", Status: status, MaxRounds: 1, AgentIDs: agentIDs, ModeratorID: moderator, JudgeID: judge}
+		if err := db.InsertDiscussion(d); err != nil {
+			t.Fatalf("InsertDiscussion: %v", err)
+		}
+		return d.ID
+	}
+	debater := insert("running", []int64{alice.ID, bob.ID}, nil, nil)
+	paused := insert("paused", []int64{bob.ID, alice.ID}, nil, nil)
+	moderating := insert("running", []int64{bob.ID}, &alice.ID, nil)
+	judging := insert("running", []int64{bob.ID}, nil, &alice.ID)
+	insert("completed", []int64{alice.ID}, nil, nil)
+	insert("stopped", []int64{bob.ID}, &alice.ID, nil)
+	// Agent 11 must not match agent 1
+	insert("running", []int64{eleventh.ID}, nil, nil)
+	legacy := insert("running", nil, nil, nil)
+	if _, err := db.Exec(`UPDATE discussions SET agent_ids = ? WHERE id = ?`, fmt.Sprintf("%d, %d", eleventh.ID, alice.ID), legacy); err != nil {
+		t.Fatalf("store legacy agent_ids: %v", err)
+	}
+
+	ids, err := db.GetLiveDiscussionIDsUsingAgent(alice.ID)
+	if err != nil {
+		t.Fatalf("GetLiveDiscussionIDsUsingAgent: %v", err)
+	}
+	want := []int64{debater, paused, moderating, judging, legacy}
+	if fmt.Sprint(ids) != fmt.Sprint(want) {
+		t.Errorf("live discussions using Alice = %v, want %v", ids, want)
+	}
+
+	if ids, err := db.GetLiveDiscussionIDsUsingAgent(agents[5].ID); err != nil || len(ids) != 0 {
+		t.Errorf("live discussions using an idle agent = %v, %v, want none", ids, err)
+	}
+}
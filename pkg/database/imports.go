@@ -0,0 +1,169 @@
+package database
+
+import (
+	"court-table-ai/pkg/models"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ImportDiscussion stores a validated transcript as a read-only discussion
+// with status imported. Participants are matched to existing agents by name,
+// ignoring case; unmatched participants become disabled stub agents when the
+// transcript asks for it. Everything is written in one transaction and turns
+// keep their original timestamps when they have one.
+func (db *DB) ImportDiscussion(t *models.TranscriptImport) (*models.ImportResult, error) {
+	existing, err := db.GetAllAgents()
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*models.Agent, len(existing))
+	for _, agent := range existing {
+		byName[strings.ToLower(agent.Name)] = agent
+	}
+
+	var missing []string
+	for _, p := range t.Participants {
+		if _, ok := byName[strings.ToLower(p.Name)]; !ok {
+			missing = append(missing, p.Name)
+		}
+	}
+	if len(missing) > 0 && !t.CreateMissingAgents {
+		return nil, fmt.Errorf("%w: %s", models.ErrUnknownParticipants, strings.Join(missing, ", "))
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result := &models.ImportResult{}
+	agentIDs := make(map[string]int64, len(t.Participants))
+	discussion := &models.Discussion{
+		Topic:        t.Topic,
+		FinalSummary: t.FinalSummary,
+		Status:       models.DiscussionStatusImported,
+		AgentIDs:     models.JSONSlice[int64]{},
+		MaxRounds:    1,
+		Language:     t.Language,
+		MaxCharLimit: 1000,
+	}
+
+	for _, p := range t.Participants {
+		key := strings.ToLower(p.Name)
+		mapped := models.ImportedAgent{Name: p.Name}
+		if agent, ok := byName[key]; ok {
+			mapped.AgentID = agent.ID
+		} else {
+			model := p.Model
+			if model == "" {
+				model = "unknown"
+			}
+			res, err := tx.Exec(`
+			INSERT INTO agents (name, provider_type, provider_url, api_token, model_name, timeout_seconds, endpoint_style, disabled, created_at, updated_at)
+			VALUES (?, 'custom', '', '', ?, 30, '', TRUE, ?, ?)`, p.Name, model, now, now)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create stub agent %q: %w", p.Name, err)
+			}
+			if mapped.AgentID, err = res.LastInsertId(); err != nil {
+				return nil, fmt.Errorf("failed to get last insert ID: %w", err)
+			}
+			mapped.Created = true
+		}
+		agentIDs[key] = mapped.AgentID
+		result.Agents = append(result.Agents, mapped)
+
+		if p.Role == models.ImportRoleModerator {
+			id := mapped.AgentID
+			discussion.ModeratorID = &id
+		} else {
+			discussion.AgentIDs = append(discussion.AgentIDs, mapped.AgentID)
+		}
+	}
+
+	// The discussion starts with its first timestamped turn
+	discussion.CreatedAt = now
+	for _, turn := range t.Turns {
+		if turn.Timestamp != nil {
+			discussion.CreatedAt = *turn.Timestamp
+			break
+		}
+	}
+	for _, turn := range t.Turns {
+		if turn.Round > discussion.MaxRounds {
+			discussion.MaxRounds = turn.Round
+		}
+		if n := utf8.RuneCountInString(turn.Content); n > discussion.MaxCharLimit {
+			discussion.MaxCharLimit = n
+		}
+	}
+	discussion.CompletedRounds = discussion.MaxRounds
+	discussion.UpdatedAt = now
+
+	res, err := tx.Exec(`
+	INSERT INTO discussions (topic, final_summary, status, agent_ids, moderator_id, max_rounds, language, max_char_limit, app_version, settings, completed_rounds, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, '', ?, ?, ?, ?)`,
+		discussion.Topic, discussion.FinalSummary, discussion.Status, discussion.AgentIDs, discussion.ModeratorID,
+		discussion.MaxRounds, discussion.Language, discussion.MaxCharLimit, discussion.Settings,
+		discussion.CompletedRounds, discussion.CreatedAt, discussion.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert discussion: %w", err)
+	}
+	if discussion.ID, err = res.LastInsertId(); err != nil {
+		return nil, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+
+	// Transcripts are read in time order, so a turn without a timestamp takes
+	// the one before it rather than the import time
+	logs := make([]*models.DiscussionLog, 0, len(t.Turns))
+	at := discussion.CreatedAt
+	for i, turn := range t.Turns {
+		if turn.Timestamp != nil {
+			at = *turn.Timestamp
+		}
+		entry := &models.DiscussionLog{
+			DiscussionID: discussion.ID,
+			AgentID:      agentIDs[strings.ToLower(turn.Participant)],
+			Content:      turn.Content,
+			Status:       "success",
+			IsModerator:  turn.Role == models.ImportRoleModerator,
+			Metadata:     models.JSONMap{"imported": "true"},
+			LogType:      models.LogTypeResponse,
+			Sequence:     int64(i + 1),
+			CreatedAt:    at,
+		}
+		if turn.Round > 0 {
+			entry.Metadata["round"] = strconv.Itoa(turn.Round)
+		}
+
+		res, err := tx.Exec(`
+		INSERT INTO discussion_logs (discussion_id, agent_id, content, status, response_time, is_moderator, metadata, log_type, sequence, created_at)
+		VALUES (?, ?, ?, ?, 0, ?, ?, ?, ?, ?)`,
+			entry.DiscussionID, entry.AgentID, entry.Content, entry.Status, entry.IsModerator,
+			entry.Metadata, entry.LogType, entry.Sequence, entry.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert imported turn %d: %w", i+1, err)
+		}
+		if entry.ID, err = res.LastInsertId(); err != nil {
+			return nil, fmt.Errorf("failed to get last insert ID: %w", err)
+		}
+		logs = append(logs, entry)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit import: %w", err)
+	}
+
+	if db.AfterLogInsert != nil {
+		for _, entry := range logs {
+			db.AfterLogInsert(entry)
+		}
+	}
+
+	result.Discussion = discussion
+	return result, nil
+}
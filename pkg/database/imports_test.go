@@ -0,0 +1,132 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/models"
+)
+
+func TestImportDiscussion(t *testing.T) {
+	db := newTestDB(t)
+	alice := insertTestAgent(t, db, "Alice")
+
+	start := time.Date(2024, 3, 5, 9, 30, 0, 0, time.UTC)
+	doc := &models.TranscriptImport{
+		Topic: "Tabs or spaces",
+		Participants: []models.ImportParticipant{
+			{Name: "alice"},
+			{Name: "Bob", Model: "gpt-3.5"},
+			{Name: "Chair", Role: models.ImportRoleModerator},
+		},
+		Turns: []models.ImportTurn{
+			{Participant: "Chair", Content: "Welcome."},
+			{Participant: "ALICE", Content: "Spaces.", Round: 1, Timestamp: &start},
+			{Participant: "Bob", Content: "Tabs.", Round: 1, Timestamp: ptrTime(start.Add(2 * time.Minute))},
+			{Participant: "alice", Content: "Still spaces.", Round: 2, Timestamp: ptrTime(start.Add(5 * time.Minute))},
+		},
+		CreateMissingAgents: true,
+	}
+	if err := doc.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	result, err := db.ImportDiscussion(doc)
+	if err != nil {
+		t.Fatalf("ImportDiscussion: %v", err)
+	}
+
+	// Alice matches the existing agent regardless of case; the others are stubs
+	if len(result.Agents) != 3 || result.Agents[0].AgentID != alice.ID || result.Agents[0].Created {
+		t.Fatalf("mapped agents = %+v, want alice matched to agent %d", result.Agents, alice.ID)
+	}
+	for _, mapped := range result.Agents[1:] {
+		if !mapped.Created {
+			t.Errorf("%s was not created as a stub", mapped.Name)
+			continue
+		}
+		stub, err := db.GetAgent(mapped.AgentID)
+		if err != nil {
+			t.Fatalf("GetAgent(%s): %v", mapped.Name, err)
+		}
+		if !stub.Disabled || stub.APIToken != "" || stub.ProviderURL != "" {
+			t.Errorf("stub %s = %+v, want a disabled agent without credentials", mapped.Name, stub)
+		}
+	}
+	if bob, _ := db.GetAgent(result.Agents[1].AgentID); bob.ModelName != "gpt-3.5" {
+		t.Errorf("Bob's stub model = %q, want the transcript's model", bob.ModelName)
+	}
+	if chair, _ := db.GetAgent(result.Agents[2].AgentID); chair.ModelName != "unknown" {
+		t.Errorf("Chair's stub model = %q, want unknown", chair.ModelName)
+	}
+
+	discussion, err := db.GetDiscussion(result.Discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussion: %v", err)
+	}
+	if discussion.Status != models.DiscussionStatusImported || discussion.MaxRounds != 2 || discussion.CompletedRounds != 2 {
+		t.Errorf("discussion = status %q, %d/%d rounds; want imported with 2 rounds", discussion.Status, discussion.CompletedRounds, discussion.MaxRounds)
+	}
+	if discussion.ModeratorID == nil || *discussion.ModeratorID != result.Agents[2].AgentID {
+		t.Errorf("moderator = %v, want Chair", discussion.ModeratorID)
+	}
+	if len(discussion.AgentIDs) != 2 || discussion.AgentIDs[0] != alice.ID || discussion.AgentIDs[1] != result.Agents[1].AgentID {
+		t.Errorf("agents = %v, want Alice and Bob", discussion.AgentIDs)
+	}
+	if !discussion.CreatedAt.Equal(start) {
+		t.Errorf("discussion created at %v, want the first turn's timestamp %v", discussion.CreatedAt, start)
+	}
+
+	logs, err := db.GetDiscussionLogs(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	if len(logs) != 4 {
+		t.Fatalf("got %d logs, want 4", len(logs))
+	}
+	if !logs[0].IsModerator || logs[0].Metadata["round"] != "" {
+		t.Errorf("first log = %+v, want the moderator's roundless welcome", logs[0])
+	}
+	// An untimestamped turn keeps its place rather than taking the import time
+	if !logs[0].CreatedAt.Equal(start) {
+		t.Errorf("untimestamped welcome created at %v, want %v", logs[0].CreatedAt, start)
+	}
+	for i, l := range logs[1:] {
+		want := start.Add([]time.Duration{0, 2 * time.Minute, 5 * time.Minute}[i])
+		if !l.CreatedAt.Equal(want) {
+			t.Errorf("log %d created at %v, want the original %v", l.Sequence, l.CreatedAt, want)
+		}
+		if l.Content != doc.Turns[i+1].Content || l.Metadata["imported"] != "true" {
+			t.Errorf("log %d = %q %v, want %q marked imported", l.Sequence, l.Content, l.Metadata, doc.Turns[i+1].Content)
+		}
+	}
+	if logs[3].Metadata["round"] != "2" || logs[3].AgentID != alice.ID {
+		t.Errorf("last log = agent %d round %q, want Alice in round 2", logs[3].AgentID, logs[3].Metadata["round"])
+	}
+}
+
+func TestImportRejectsUnknownParticipants(t *testing.T) {
+	db := newTestDB(t)
+	insertTestAgent(t, db, "Alice")
+
+	doc := &models.TranscriptImport{
+		Topic:        "Tabs or spaces",
+		Participants: []models.ImportParticipant{{Name: "Alice"}, {Name: "Bob"}},
+		Turns:        []models.ImportTurn{{Participant: "Bob", Content: "Tabs."}},
+	}
+	if err := doc.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if _, err := db.ImportDiscussion(doc); !errors.Is(err, models.ErrUnknownParticipants) {
+		t.Fatalf("ImportDiscussion = %v, want ErrUnknownParticipants", err)
+	}
+
+	var agents, discussions int
+	db.QueryRow(`SELECT COUNT(*) FROM agents`).Scan(&agents)
+	db.QueryRow(`SELECT COUNT(*) FROM discussions`).Scan(&discussions)
+	if agents != 1 || discussions != 0 {
+		t.Errorf("after a rejected import: %d agents, %d discussions; want nothing written", agents, discussions)
+	}
+}
+
+func ptrTime(t time.Time) *time.Time { return &t }
@@ -0,0 +1,365 @@
+package database
+
+import (
+	"context"
+	"court-table-ai/pkg/models"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// migration is a numbered schema change applied once per database
+type migration struct {
+	version     int
+	description string
+	up          func(db *DB) error
+}
+
+// migrations must be appended in increasing version order and never reordered
+var migrations = []migration{
+	{1, "add app_version to discussions", func(db *DB) error {
+		return db.addColumnIfMissing("discussions", "app_version", "TEXT NOT NULL DEFAULT ''")
+	}},
+	{2, "add endpoint_style to agents", func(db *DB) error {
+		return db.addColumnIfMissing("agents", "endpoint_style", "TEXT NOT NULL DEFAULT ''")
+	}},
+	{3, "add settings to discussions", func(db *DB) error {
+		return db.addColumnIfMissing("discussions", "settings", "TEXT NOT NULL DEFAULT '{}'")
+	}},
+	{4, "add metadata to discussion_logs", func(db *DB) error {
+		return db.addColumnIfMissing("discussion_logs", "metadata", "TEXT NOT NULL DEFAULT '{}'")
+	}},
+	{5, "add log_type and skipped status to discussion_logs", func(db *DB) error {
+		return db.rebuildTable("discussion_logs", discussionLogsTableSQL,
+			[]string{"id", "discussion_id", "agent_id", "content", "status", "response_time", "is_moderator", "metadata", "created_at"},
+			discussionLogIndexes)
+	}},
+	{6, "add deleting status to discussions", func(db *DB) error {
+		return db.rebuildTable("discussions", discussionsTableSQL,
+			[]string{"id", "topic", "final_summary", "status", "agent_ids", "moderator_id", "max_rounds", "language", "max_char_limit", "app_version", "settings", "created_at", "updated_at"},
+			discussionIndexes)
+	}},
+	{7, "allow NULL agent_id for system log entries", func(db *DB) error {
+		if err := db.rebuildTable("discussion_logs", discussionLogsTableSQL,
+			[]string{"id", "discussion_id", "agent_id", "content", "status", "response_time", "is_moderator", "metadata", "log_type", "created_at"},
+			discussionLogIndexes); err != nil {
+			return err
+		}
+		// Watchdog alerts used to borrow the moderator's or first agent's ID
+		_, err := db.Exec(`UPDATE discussion_logs SET agent_id = NULL, is_moderator = FALSE, log_type = 'system'
+			WHERE log_type = 'response' AND metadata LIKE '%"system":"true"%'`)
+		return err
+	}},
+	{8, "add sequence to discussion_logs", func(db *DB) error {
+		return db.addColumnIfMissing("discussion_logs", "sequence", "INTEGER NOT NULL DEFAULT 0")
+	}},
+	{9, "add error_message to discussions", func(db *DB) error {
+		return db.addColumnIfMissing("discussions", "error_message", "TEXT NOT NULL DEFAULT ''")
+	}},
+	{10, "add imported status to discussions", func(db *DB) error {
+		return db.rebuildTable("discussions", discussionsTableSQL,
+			[]string{"id", "topic", "final_summary", "status", "agent_ids", "moderator_id", "max_rounds", "language", "max_char_limit", "app_version", "settings", "error_message", "created_at", "updated_at"},
+			discussionIndexes)
+	}},
+	{11, "add disabled to agents", func(db *DB) error {
+		return db.addColumnIfMissing("agents", "disabled", "BOOLEAN NOT NULL DEFAULT FALSE")
+	}},
+	{12, "add completed_rounds and end_reason to discussions", func(db *DB) error {
+		if err := db.addColumnIfMissing("discussions", "completed_rounds", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+		return db.addColumnIfMissing("discussions", "end_reason", "TEXT NOT NULL DEFAULT ''")
+	}},
+	{13, "add stopped status to discussions", func(db *DB) error {
+		if err := db.rebuildTable("discussions", discussionsTableSQL,
+			[]string{"id", "topic", "final_summary", "status", "agent_ids", "moderator_id", "max_rounds", "language", "max_char_limit", "app_version", "settings", "error_message", "completed_rounds", "end_reason", "created_at", "updated_at"},
+			discussionIndexes); err != nil {
+			return err
+		}
+		// Stopped discussions used to be recorded as completed
+		_, err := db.Exec(`UPDATE discussions SET status = 'stopped' WHERE status = 'completed' AND end_reason = 'stopped'`)
+		return err
+	}},
+	{14, "store discussion languages as codes", func(db *DB) error {
+		return db.normalizeLanguages()
+	}},
+	{15, "normalize agent provider types", func(db *DB) error {
+		return db.normalizeProviderTypes()
+	}},
+	{16, "add rejected status to discussion_logs", func(db *DB) error {
+		return db.rebuildTable("discussion_logs", discussionLogsTableSQL,
+			[]string{"id", "discussion_id", "agent_id", "content", "status", "response_time", "is_moderator", "metadata", "log_type", "sequence", "created_at"},
+			discussionLogIndexes)
+	}},
+	{17, "add token usage to discussion_logs", func(db *DB) error {
+		if err := db.addColumnIfMissing("discussion_logs", "prompt_tokens", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+		return db.addColumnIfMissing("discussion_logs", "completion_tokens", "INTEGER NOT NULL DEFAULT 0")
+	}},
+	{18, "add system_prompt to agents", func(db *DB) error {
+		return db.addColumnIfMissing("agents", "system_prompt", "TEXT NOT NULL DEFAULT ''")
+	}},
+	{19, "add paused status to discussions", func(db *DB) error {
+		return db.rebuildTable("discussions", discussionsTableSQL,
+			[]string{"id", "topic", "final_summary", "status", "agent_ids", "moderator_id", "max_rounds", "language", "max_char_limit", "app_version", "settings", "error_message", "completed_rounds", "end_reason", "created_at", "updated_at"},
+			discussionIndexes)
+	}},
+	{20, "add judge and verdict to discussions", func(db *DB) error {
+		columns := [][2]string{
+			{"judge_id", "INTEGER REFERENCES agents(id) ON DELETE SET NULL"},
+			{"verdict_winner_id", "INTEGER"},
+			{"verdict_scores", "TEXT"},
+			{"verdict_reasoning", "TEXT"},
+		}
+		for _, c := range columns {
+			if err := db.addColumnIfMissing("discussions", c[0], c[1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{21, "number every discussion log and make sequences unique per discussion", func(db *DB) error {
+		// Entries written before migration 8 all have sequence 0
+		if _, err := db.Exec(`UPDATE discussion_logs SET sequence = (
+			SELECT COUNT(*) FROM discussion_logs AS earlier
+			WHERE earlier.discussion_id = discussion_logs.discussion_id AND earlier.id <= discussion_logs.id)
+		WHERE discussion_id IN (SELECT discussion_id FROM discussion_logs WHERE sequence = 0)`); err != nil {
+			return err
+		}
+		_, err := db.Exec(discussionLogSequenceIndex)
+		return err
+	}},
+	{22, "add trace to agents", func(db *DB) error {
+		return db.addColumnIfMissing("agents", "trace", "BOOLEAN NOT NULL DEFAULT FALSE")
+	}},
+	{23, "add api_version to agents", func(db *DB) error {
+		return db.addColumnIfMissing("agents", "api_version", "TEXT NOT NULL DEFAULT ''")
+	}},
+	{24, "add extra_headers to agents", func(db *DB) error {
+		return db.addColumnIfMissing("agents", "extra_headers", "TEXT NOT NULL DEFAULT '{}'")
+	}},
+	{25, "soft delete agents", func(db *DB) error {
+		return db.addColumnIfMissing("agents", "deleted_at", "DATETIME")
+	}},
+	{26, "unique agent names among agents not deleted", func(db *DB) error {
+		if err := db.rebuildTable("agents", agentsTableSQL,
+			[]string{"id", "name", "provider_type", "provider_url", "api_token", "model_name", "timeout_seconds", "endpoint_style", "api_version", "extra_headers", "system_prompt", "disabled", "trace", "deleted_at", "created_at", "updated_at"},
+			agentIndexes); err != nil {
+			return err
+		}
+		// Deleted agents used to free their name with a "(deleted #id)" suffix
+		_, err := db.Exec(`UPDATE agents SET name = substr(name, 1, length(name) - length(' (deleted #' || id || ')'))
+			WHERE deleted_at IS NOT NULL AND name LIKE '% (deleted #' || id || ')'`)
+		return err
+	}},
+	{27, "add self_test to discussions", func(db *DB) error {
+		return db.addColumnIfMissing("discussions", "self_test", "BOOLEAN NOT NULL DEFAULT FALSE")
+	}},
+}
+
+// runMigrations applies every migration newer than the recorded schema version
+func (db *DB) runMigrations() error {
+	migrationsSQL := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		description TEXT NOT NULL DEFAULT '',
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := db.Exec(migrationsSQL); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	current, err := db.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		if err := m.up(db); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.description, err)
+		}
+
+		if _, err := db.Exec(`INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)`,
+			m.version, m.description, time.Now()); err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", m.version, err)
+		}
+
+		log.Printf("Applied migration %d: %s", m.version, m.description)
+	}
+
+	return nil
+}
+
+// SchemaVersion returns the highest applied migration version
+func (db *DB) SchemaVersion() (int, error) {
+	var version int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %w", err)
+	}
+	return version, nil
+}
+
+// addColumnIfMissing adds a column unless the table already has it
+func (db *DB) addColumnIfMissing(table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue interface{}
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return fmt.Errorf("failed to scan table info: %w", err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+// rebuildTable recreates a table from createSQL and copies the listed columns
+// across. SQLite cannot change CHECK or FOREIGN KEY constraints in place.
+// Foreign keys are disabled on a dedicated connection for the duration so
+// dropping a parent table does not cascade into its children.
+func (db *DB) rebuildTable(table string, createSQL func(table string) string, columns []string, indexes []string) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection for rebuild of %s: %w", table, err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys=OFF"); err != nil {
+		return fmt.Errorf("failed to disable foreign keys: %w", err)
+	}
+	defer conn.ExecContext(ctx, "PRAGMA foreign_keys=ON")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin rebuild of %s: %w", table, err)
+	}
+	defer tx.Rollback()
+
+	tmp := table + "_new"
+	cols := strings.Join(columns, ", ")
+	statements := []string{
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", tmp),
+		createSQL(tmp),
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", tmp, cols, cols, table),
+		fmt.Sprintf("DROP TABLE %s", table),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", tmp, table),
+	}
+	statements = append(statements, indexes...)
+
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to rebuild table %s: %w", table, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// normalizeLanguages rewrites recognised discussion languages, which used to
+// be stored as free text such as "English", as language codes. Unknown
+// languages are left as they are.
+func (db *DB) normalizeLanguages() error {
+	rows, err := db.Query(`SELECT DISTINCT language FROM discussions WHERE language IS NOT NULL`)
+	if err != nil {
+		return fmt.Errorf("failed to list discussion languages: %w", err)
+	}
+	var languages []string
+	for rows.Next() {
+		var language string
+		if err := rows.Scan(&language); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan discussion language: %w", err)
+		}
+		languages = append(languages, language)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	for _, language := range languages {
+		code, known := models.NormalizeLanguage(language)
+		if !known || code == language {
+			continue
+		}
+		if _, err := db.Exec(`UPDATE discussions SET language = ? WHERE language = ?`, code, language); err != nil {
+			return fmt.Errorf("failed to normalize language %q: %w", language, err)
+		}
+	}
+	return nil
+}
+
+// normalizeProviderTypes lowercases stored agent provider types and replaces
+// unknown or empty ones with the type detected from the provider URL, so
+// existing agents pass validation when they are next edited
+func (db *DB) normalizeProviderTypes() error {
+	rows, err := db.Query(`SELECT id, COALESCE(provider_type, ''), provider_url FROM agents`)
+	if err != nil {
+		return fmt.Errorf("failed to list agent provider types: %w", err)
+	}
+	fixes := make(map[int64]string)
+	for rows.Next() {
+		var (
+			id                int64
+			providerType, url string
+		)
+		if err := rows.Scan(&id, &providerType, &url); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan agent provider type: %w", err)
+		}
+
+		normalized := strings.ToLower(strings.TrimSpace(providerType))
+		known := false
+		for _, t := range models.ProviderTypes {
+			known = known || normalized == t
+		}
+		if !known {
+			normalized = models.DetectProviderType(url)
+		}
+		if normalized != providerType {
+			fixes[id] = normalized
+		}
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	for id, providerType := range fixes {
+		if _, err := db.Exec(`UPDATE agents SET provider_type = ? WHERE id = ?`, providerType, id); err != nil {
+			return fmt.Errorf("failed to normalize provider type of agent %d: %w", id, err)
+		}
+	}
+	return nil
+}
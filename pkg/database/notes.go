@@ -0,0 +1,144 @@
+package database
+
+import (
+	"court-table-ai/pkg/models"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// discussionNotesSQL creates the table holding reader notes on discussions
+const discussionNotesSQL = `
+	CREATE TABLE IF NOT EXISTS discussion_notes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		discussion_id INTEGER NOT NULL,
+		author TEXT NOT NULL DEFAULT '',
+		content TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (discussion_id) REFERENCES discussions(id) ON DELETE CASCADE
+	);`
+
+// discussionNoteIndexes are created alongside discussion_notes
+var discussionNoteIndexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_discussion_notes_discussion_id ON discussion_notes(discussion_id);",
+}
+
+// InsertDiscussionNote adds a note to a discussion
+func (db *DB) InsertDiscussionNote(note *models.DiscussionNote) error {
+	query := `
+	INSERT INTO discussion_notes (discussion_id, author, content, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?)
+	`
+
+	now := time.Now()
+	result, err := db.Exec(query, note.DiscussionID, note.Author, note.Content, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to insert discussion note: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+
+	note.ID = id
+	note.CreatedAt = now
+	note.UpdatedAt = now
+	return nil
+}
+
+// GetDiscussionNote retrieves a single note by ID
+func (db *DB) GetDiscussionNote(id int64) (*models.DiscussionNote, error) {
+	query := `
+	SELECT id, discussion_id, author, content, created_at, updated_at
+	FROM discussion_notes WHERE id = ?
+	`
+
+	note := &models.DiscussionNote{}
+	err := db.QueryRow(query, id).Scan(
+		&note.ID, &note.DiscussionID, &note.Author, &note.Content, &note.CreatedAt, &note.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("note not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get discussion note: %w", err)
+	}
+
+	return note, nil
+}
+
+// GetDiscussionNotes retrieves all notes for a discussion, oldest first
+func (db *DB) GetDiscussionNotes(discussionID int64) ([]*models.DiscussionNote, error) {
+	query := `
+	SELECT id, discussion_id, author, content, created_at, updated_at
+	FROM discussion_notes WHERE discussion_id = ? ORDER BY created_at ASC, id ASC
+	`
+
+	rows, err := db.Query(query, discussionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query discussion notes: %w", err)
+	}
+	defer rows.Close()
+
+	notes := []*models.DiscussionNote{}
+	for rows.Next() {
+		note := &models.DiscussionNote{}
+		if err := rows.Scan(&note.ID, &note.DiscussionID, &note.Author, &note.Content, &note.CreatedAt, &note.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan discussion note: %w", err)
+		}
+		notes = append(notes, note)
+	}
+
+	return notes, rows.Err()
+}
+
+// UpdateDiscussionNote replaces a note's author and content
+func (db *DB) UpdateDiscussionNote(note *models.DiscussionNote) error {
+	query := `UPDATE discussion_notes SET author = ?, content = ?, updated_at = ? WHERE id = ?`
+
+	now := time.Now()
+	result, err := db.Exec(query, note.Author, note.Content, now, note.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update discussion note: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("note not found")
+	}
+
+	note.UpdatedAt = now
+	return nil
+}
+
+// DeleteDiscussionNote deletes a note by ID
+func (db *DB) DeleteDiscussionNote(id int64) error {
+	result, err := db.Exec(`DELETE FROM discussion_notes WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete discussion note: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("note not found")
+	}
+
+	return nil
+}
+
+// DeleteDiscussionNotes deletes every note of a discussion. Foreign keys are
+// only enforced on some pooled connections, so deletes do not rely on cascade.
+func (db *DB) DeleteDiscussionNotes(discussionID int64) error {
+	if _, err := db.Exec(`DELETE FROM discussion_notes WHERE discussion_id = ?`, discussionID); err != nil {
+		return fmt.Errorf("failed to delete discussion notes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"court-table-ai/pkg/models"
+	"database/sql"
+	"fmt"
+)
+
+// discussionParticipantsSQL creates the table holding the stance assigned to
+// each agent of a discussion
+const discussionParticipantsSQL = `
+	CREATE TABLE IF NOT EXISTS discussion_participants (
+		discussion_id INTEGER NOT NULL,
+		agent_id INTEGER NOT NULL,
+		stance TEXT NOT NULL,
+		PRIMARY KEY (discussion_id, agent_id),
+		FOREIGN KEY (discussion_id) REFERENCES discussions(id) ON DELETE CASCADE
+	);`
+
+// insertDiscussionStances stores the stances of a new discussion
+func insertDiscussionStances(tx *sql.Tx, discussionID int64, stances []models.AgentStance) error {
+	for _, s := range stances {
+		if _, err := tx.Exec(`INSERT INTO discussion_participants (discussion_id, agent_id, stance) VALUES (?, ?, ?)`,
+			discussionID, s.AgentID, s.Stance); err != nil {
+			return fmt.Errorf("failed to insert discussion stance: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetDiscussionStances retrieves the stances assigned in a discussion
+func (db *DB) GetDiscussionStances(discussionID int64) ([]models.AgentStance, error) {
+	rows, err := db.Query(`SELECT agent_id, stance FROM discussion_participants WHERE discussion_id = ? ORDER BY agent_id`, discussionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query discussion stances: %w", err)
+	}
+	defer rows.Close()
+
+	var stances []models.AgentStance
+	for rows.Next() {
+		var s models.AgentStance
+		if err := rows.Scan(&s.AgentID, &s.Stance); err != nil {
+			return nil, fmt.Errorf("failed to scan discussion stance: %w", err)
+		}
+		stances = append(stances, s)
+	}
+	return stances, rows.Err()
+}
+
+// DeleteDiscussionParticipants deletes the stances of a discussion. Foreign
+// keys are only enforced on some pooled connections, so deletes do not rely
+// on cascade.
+func (db *DB) DeleteDiscussionParticipants(discussionID int64) error {
+	if _, err := db.Exec(`DELETE FROM discussion_participants WHERE discussion_id = ?`, discussionID); err != nil {
+		return fmt.Errorf("failed to delete discussion participants: %w", err)
+	}
+	return nil
+}
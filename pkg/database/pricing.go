@@ -0,0 +1,149 @@
+package database
+
+import (
+	"court-table-ai/pkg/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// modelPricingSQL creates the table holding per-model token prices
+const modelPricingSQL = `
+	CREATE TABLE IF NOT EXISTS model_pricing (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		provider TEXT NOT NULL,
+		model TEXT NOT NULL COLLATE NOCASE,
+		input_per_1k REAL NOT NULL DEFAULT 0,
+		output_per_1k REAL NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (provider, model)
+	);`
+
+// ErrPricingExists is returned when a provider and model already have pricing
+var ErrPricingExists = errors.New("pricing for this provider and model already exists")
+
+// InsertModelPricing adds the pricing of a provider and model
+func (db *DB) InsertModelPricing(pricing *models.ModelPricing) error {
+	query := `
+	INSERT INTO model_pricing (provider, model, input_per_1k, output_per_1k, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	now := time.Now()
+	result, err := db.Exec(query, pricing.Provider, pricing.Model, pricing.InputPer1K, pricing.OutputPer1K, now, now)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrPricingExists
+		}
+		return fmt.Errorf("failed to insert model pricing: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+
+	pricing.ID = id
+	pricing.CreatedAt = now
+	pricing.UpdatedAt = now
+	return nil
+}
+
+// GetModelPricing retrieves a pricing entry by ID
+func (db *DB) GetModelPricing(id int64) (*models.ModelPricing, error) {
+	query := `
+	SELECT id, provider, model, input_per_1k, output_per_1k, created_at, updated_at
+	FROM model_pricing WHERE id = ?
+	`
+
+	pricing := &models.ModelPricing{}
+	err := db.QueryRow(query, id).Scan(&pricing.ID, &pricing.Provider, &pricing.Model,
+		&pricing.InputPer1K, &pricing.OutputPer1K, &pricing.CreatedAt, &pricing.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("pricing not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get model pricing: %w", err)
+	}
+
+	return pricing, nil
+}
+
+// GetAllModelPricing retrieves every pricing entry ordered by provider and model
+func (db *DB) GetAllModelPricing() ([]*models.ModelPricing, error) {
+	query := `
+	SELECT id, provider, model, input_per_1k, output_per_1k, created_at, updated_at
+	FROM model_pricing ORDER BY provider ASC, model ASC
+	`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query model pricing: %w", err)
+	}
+	defer rows.Close()
+
+	pricing := []*models.ModelPricing{}
+	for rows.Next() {
+		p := &models.ModelPricing{}
+		if err := rows.Scan(&p.ID, &p.Provider, &p.Model, &p.InputPer1K, &p.OutputPer1K, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan model pricing: %w", err)
+		}
+		pricing = append(pricing, p)
+	}
+
+	return pricing, rows.Err()
+}
+
+// UpdateModelPricing replaces a pricing entry
+func (db *DB) UpdateModelPricing(pricing *models.ModelPricing) error {
+	query := `
+	UPDATE model_pricing SET provider = ?, model = ?, input_per_1k = ?, output_per_1k = ?, updated_at = ?
+	WHERE id = ?
+	`
+
+	now := time.Now()
+	result, err := db.Exec(query, pricing.Provider, pricing.Model, pricing.InputPer1K, pricing.OutputPer1K, now, pricing.ID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrPricingExists
+		}
+		return fmt.Errorf("failed to update model pricing: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("pricing not found")
+	}
+
+	pricing.UpdatedAt = now
+	return nil
+}
+
+// DeleteModelPricing deletes a pricing entry by ID
+func (db *DB) DeleteModelPricing(id int64) error {
+	result, err := db.Exec(`DELETE FROM model_pricing WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete model pricing: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("pricing not found")
+	}
+
+	return nil
+}
+
+// isUniqueViolation reports whether err is a SQLite UNIQUE constraint failure
+func isUniqueViolation(err error) bool {
+	return strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
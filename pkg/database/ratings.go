@@ -0,0 +1,91 @@
+package database
+
+import (
+	"court-table-ai/pkg/models"
+	"fmt"
+	"time"
+)
+
+// logRatingsSQL creates the table holding reader ratings of log entries
+const logRatingsSQL = `
+	CREATE TABLE IF NOT EXISTS discussion_log_ratings (
+		log_id INTEGER NOT NULL,
+		rater TEXT NOT NULL DEFAULT '',
+		discussion_id INTEGER NOT NULL,
+		agent_id INTEGER NOT NULL,
+		rating INTEGER NOT NULL CHECK (rating IN (-1, 0, 1)),
+		note TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (log_id, rater),
+		FOREIGN KEY (discussion_id) REFERENCES discussions(id) ON DELETE CASCADE,
+		FOREIGN KEY (log_id) REFERENCES discussion_logs(id) ON DELETE CASCADE
+	);`
+
+// logRatingIndexes are created alongside discussion_log_ratings
+var logRatingIndexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_discussion_log_ratings_discussion_id ON discussion_log_ratings(discussion_id);",
+	"CREATE INDEX IF NOT EXISTS idx_discussion_log_ratings_agent_id ON discussion_log_ratings(agent_id, updated_at);",
+}
+
+// ratingColumns lists the columns scanRating reads, in order
+const ratingColumns = `log_id, discussion_id, agent_id, rater, rating, note, created_at, updated_at`
+
+// scanRating scans one ratingColumns row
+func scanRating(row interface{ Scan(...interface{}) error }) (*models.LogRating, error) {
+	r := &models.LogRating{}
+	err := row.Scan(&r.LogID, &r.DiscussionID, &r.AgentID, &r.Rater, &r.Rating, &r.Note, &r.CreatedAt, &r.UpdatedAt)
+	return r, err
+}
+
+// SetLogRating stores a rating, replacing the rater's previous rating of the
+// same log entry
+func (db *DB) SetLogRating(r *models.LogRating) error {
+	now := time.Now()
+	err := db.QueryRow(`
+	INSERT INTO discussion_log_ratings (log_id, rater, discussion_id, agent_id, rating, note, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(log_id, rater) DO UPDATE SET
+		rating = excluded.rating, note = excluded.note, updated_at = excluded.updated_at
+	RETURNING created_at`,
+		r.LogID, r.Rater, r.DiscussionID, r.AgentID, r.Rating, r.Note, now, now).Scan(&r.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save log rating: %w", err)
+	}
+
+	r.UpdatedAt = now
+	return nil
+}
+
+// GetDiscussionRatings retrieves every rating of a discussion's log entries
+func (db *DB) GetDiscussionRatings(discussionID int64) ([]*models.LogRating, error) {
+	return db.queryRatings(`SELECT `+ratingColumns+` FROM discussion_log_ratings
+	WHERE discussion_id = ? ORDER BY log_id ASC, rater ASC`, discussionID)
+}
+
+// GetAgentRatings retrieves every rating updated since the given time for
+// reliability scoring
+func (db *DB) GetAgentRatings(since time.Time) ([]*models.LogRating, error) {
+	return db.queryRatings(`SELECT `+ratingColumns+` FROM discussion_log_ratings
+	WHERE updated_at >= ?`, since)
+}
+
+// queryRatings runs a ratingColumns query
+func (db *DB) queryRatings(query string, args ...interface{}) ([]*models.LogRating, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query log ratings: %w", err)
+	}
+	defer rows.Close()
+
+	ratings := []*models.LogRating{}
+	for rows.Next() {
+		r, err := scanRating(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan log rating: %w", err)
+		}
+		ratings = append(ratings, r)
+	}
+
+	return ratings, rows.Err()
+}
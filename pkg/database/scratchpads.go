@@ -0,0 +1,65 @@
+package database
+
+import (
+	"court-table-ai/pkg/models"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// agentScratchpadsSQL creates the table holding each agent's private notes
+// in a discussion
+const agentScratchpadsSQL = `
+	CREATE TABLE IF NOT EXISTS agent_scratchpads (
+		discussion_id INTEGER NOT NULL,
+		agent_id INTEGER NOT NULL,
+		notes TEXT NOT NULL,
+		round INTEGER NOT NULL DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (discussion_id, agent_id),
+		FOREIGN KEY (discussion_id) REFERENCES discussions(id) ON DELETE CASCADE
+	);`
+
+// SaveAgentScratchpad replaces an agent's notes in a discussion
+func (db *DB) SaveAgentScratchpad(pad *models.AgentScratchpad) error {
+	query := `
+	INSERT INTO agent_scratchpads (discussion_id, agent_id, notes, round, updated_at)
+	VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT (discussion_id, agent_id) DO UPDATE SET notes = excluded.notes, round = excluded.round, updated_at = excluded.updated_at
+	`
+
+	pad.UpdatedAt = time.Now()
+	if _, err := db.Exec(query, pad.DiscussionID, pad.AgentID, pad.Notes, pad.Round, pad.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to save agent scratchpad: %w", err)
+	}
+	return nil
+}
+
+// GetAgentScratchpad retrieves an agent's notes in a discussion, or nil when
+// the agent has not written any
+func (db *DB) GetAgentScratchpad(discussionID, agentID int64) (*models.AgentScratchpad, error) {
+	query := `
+	SELECT discussion_id, agent_id, notes, round, updated_at
+	FROM agent_scratchpads WHERE discussion_id = ? AND agent_id = ?
+	`
+
+	pad := &models.AgentScratchpad{}
+	err := db.QueryRow(query, discussionID, agentID).Scan(&pad.DiscussionID, &pad.AgentID, &pad.Notes, &pad.Round, &pad.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agent scratchpad: %w", err)
+	}
+	return pad, nil
+}
+
+// DeleteAgentScratchpads deletes the notes of every agent in a discussion.
+// Foreign keys are only enforced on some pooled connections, so deletes do
+// not rely on cascade.
+func (db *DB) DeleteAgentScratchpads(discussionID int64) error {
+	if _, err := db.Exec(`DELETE FROM agent_scratchpads WHERE discussion_id = ?`, discussionID); err != nil {
+		return fmt.Errorf("failed to delete agent scratchpads: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package database
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MasterKeyEnv names the environment variable holding the master key that
+// encrypts agent API tokens at rest: 32 random bytes in base64, e.g. from
+// `openssl rand -base64 32`. Without it tokens are stored as given.
+const MasterKeyEnv = "COURT_TABLE_MASTER_KEY"
+
+// NewMasterKeyEnv names the environment variable holding the key that
+// RotateMasterKey moves the tokens to
+const NewMasterKeyEnv = "COURT_TABLE_NEW_MASTER_KEY"
+
+// masterKeySize is the length of a master key: AES-256
+const masterKeySize = 32
+
+// encryptedTokenPrefix marks an api_token column holding an encrypted token:
+// enc:v1:<wrapped data key>:<sealed token>, both base64 with their nonces
+// in front. Both are bound to the agent through tokenAAD.
+const encryptedTokenPrefix = "enc:v1:"
+
+// ErrMasterKeyRequired is returned when an encrypted API token is read
+// without a master key
+var ErrMasterKeyRequired = errors.New("agent API tokens are encrypted but " + MasterKeyEnv + " is not set")
+
+// ParseMasterKey decodes a base64 master key
+func ParseMasterKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("master key is not valid base64: %w", err)
+	}
+	if len(key) != masterKeySize {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d", masterKeySize, len(key))
+	}
+	return key, nil
+}
+
+// SetMasterKey makes the database encrypt the API tokens it writes under
+// key, and decrypt the ones it reads. It is set once at startup.
+func (db *DB) SetMasterKey(key []byte) error {
+	if len(key) != masterKeySize {
+		return fmt.Errorf("master key must be %d bytes, got %d", masterKeySize, len(key))
+	}
+	db.masterKey = key
+	return nil
+}
+
+// tokenAAD is the additional data sealing the API token of agent id, so a
+// token copied onto another agent's row does not decrypt
+func tokenAAD(id int64) []byte {
+	return []byte(fmt.Sprintf("agents.api_token:%d", id))
+}
+
+// encryptToken returns the token of agent id as it is stored: encrypted
+// under the master key when one is set. An empty token stays empty.
+func (db *DB) encryptToken(id int64, token string) (string, error) {
+	if db.masterKey == nil || token == "" {
+		return token, nil
+	}
+	return sealToken(db.masterKey, token, tokenAAD(id))
+}
+
+// decryptToken returns the API token of agent id stored as stored. Tokens
+// written before a master key was set are read as they are.
+func (db *DB) decryptToken(id int64, stored string) (string, error) {
+	if !strings.HasPrefix(stored, encryptedTokenPrefix) {
+		return stored, nil
+	}
+	if db.masterKey == nil {
+		return "", ErrMasterKeyRequired
+	}
+	return openToken(db.masterKey, stored, tokenAAD(id))
+}
+
+// CheckMasterKey fails when agents have encrypted API tokens and the master
+// key is missing, or any of them does not decrypt under it
+func (db *DB) CheckMasterKey() error {
+	rows, err := db.Query(`SELECT id, api_token FROM agents WHERE api_token LIKE ?`, encryptedTokenPrefix+"%")
+	if err != nil {
+		return fmt.Errorf("failed to read API tokens: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var stored string
+		if err := rows.Scan(&id, &stored); err != nil {
+			return fmt.Errorf("failed to scan API token: %w", err)
+		}
+		if _, err := db.decryptToken(id, stored); err != nil {
+			return fmt.Errorf("agent %d: %w", id, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read API tokens: %w", err)
+	}
+	return nil
+}
+
+// EncryptTokens encrypts the API tokens still stored in plaintext under the
+// master key and returns how many it encrypted. It does nothing without a
+// master key, and once every token is encrypted.
+func (db *DB) EncryptTokens() (int, error) {
+	if db.masterKey == nil {
+		return 0, nil
+	}
+	return db.reencryptTokens(func(id int64, stored string) (string, bool, error) {
+		if strings.HasPrefix(stored, encryptedTokenPrefix) {
+			return stored, false, nil
+		}
+		sealed, err := sealToken(db.masterKey, stored, tokenAAD(id))
+		return sealed, true, err
+	})
+}
+
+// RotateMasterKey moves every API token to newKey and makes it the master
+// key. Encrypted tokens keep their data keys, which are wrapped again under
+// newKey; plaintext tokens are encrypted. It returns the number of tokens
+// rewritten. Either all of them move or, on an error, none.
+func (db *DB) RotateMasterKey(newKey []byte) (int, error) {
+	if len(newKey) != masterKeySize {
+		return 0, fmt.Errorf("master key must be %d bytes, got %d", masterKeySize, len(newKey))
+	}
+	n, err := db.reencryptTokens(func(id int64, stored string) (string, bool, error) {
+		if !strings.HasPrefix(stored, encryptedTokenPrefix) {
+			sealed, err := sealToken(newKey, stored, tokenAAD(id))
+			return sealed, true, err
+		}
+		if db.masterKey == nil {
+			return "", false, ErrMasterKeyRequired
+		}
+		rewrapped, err := rewrapToken(db.masterKey, newKey, stored, tokenAAD(id))
+		return rewrapped, true, err
+	})
+	if err != nil {
+		return 0, err
+	}
+	db.masterKey = newKey
+	return n, nil
+}
+
+// reencryptTokens rewrites the non-empty API token of every agent with
+// convert in one transaction and returns the number of tokens changed
+func (db *DB) reencryptTokens(convert func(id int64, stored string) (string, bool, error)) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, api_token FROM agents WHERE api_token != ''`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query API tokens: %w", err)
+	}
+	tokens := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var stored string
+		if err := rows.Scan(&id, &stored); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan API token: %w", err)
+		}
+		tokens[id] = stored
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query API tokens: %w", err)
+	}
+
+	changed := 0
+	for id, stored := range tokens {
+		converted, ok, err := convert(id, stored)
+		if err != nil {
+			return 0, fmt.Errorf("agent %d: %w", id, err)
+		}
+		if !ok {
+			continue
+		}
+		if _, err := tx.Exec(`UPDATE agents SET api_token = ? WHERE id = ?`, converted, id); err != nil {
+			return 0, fmt.Errorf("failed to update API token of agent %d: %w", id, err)
+		}
+		changed++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit API tokens: %w", err)
+	}
+	return changed, nil
+}
+
+// sealToken encrypts token under a fresh data key and wraps the data key
+// under master, so rotating the master key only rewraps data keys. Both are
+// sealed with aad, which opening them must repeat.
+func sealToken(master []byte, token string, aad []byte) (string, error) {
+	dataKey := make([]byte, masterKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+	wrapped, err := gcmSeal(master, dataKey, aad)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := gcmSeal(dataKey, []byte(token), aad)
+	if err != nil {
+		return "", err
+	}
+	return encryptedTokenPrefix + base64.StdEncoding.EncodeToString(wrapped) + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openToken decrypts a token written by sealToken
+func openToken(master []byte, stored string, aad []byte) (string, error) {
+	dataKey, sealed, err := unwrapToken(master, stored, aad)
+	if err != nil {
+		return "", err
+	}
+	token, err := gcmOpen(dataKey, sealed, aad)
+	if err != nil {
+		return "", errors.New("encrypted API token is corrupt")
+	}
+	return string(token), nil
+}
+
+// rewrapToken wraps the data key of a token written by sealToken under
+// newMaster, leaving the sealed token as it is
+func rewrapToken(oldMaster, newMaster []byte, stored string, aad []byte) (string, error) {
+	dataKey, sealed, err := unwrapToken(oldMaster, stored, aad)
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := gcmSeal(newMaster, dataKey, aad)
+	if err != nil {
+		return "", err
+	}
+	return encryptedTokenPrefix + base64.StdEncoding.EncodeToString(wrapped) + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// unwrapToken splits a token written by sealToken and unwraps its data key
+func unwrapToken(master []byte, stored string, aad []byte) (dataKey, sealed []byte, err error) {
+	wrappedPart, sealedPart, ok := strings.Cut(strings.TrimPrefix(stored, encryptedTokenPrefix), ":")
+	if !ok {
+		return nil, nil, errors.New("encrypted API token is malformed")
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(wrappedPart)
+	if err != nil {
+		return nil, nil, errors.New("encrypted API token is malformed")
+	}
+	if sealed, err = base64.StdEncoding.DecodeString(sealedPart); err != nil {
+		return nil, nil, errors.New("encrypted API token is malformed")
+	}
+	if dataKey, err = gcmOpen(master, wrapped, aad); err != nil {
+		return nil, nil, errors.New("API token was encrypted under a different master key than " + MasterKeyEnv + ", or for another agent")
+	}
+	return dataKey, sealed, nil
+}
+
+// gcmSeal encrypts plaintext with AES-GCM under key and authenticates aad,
+// nonce first
+func gcmSeal(key, plaintext, aad []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// gcmOpen decrypts the output of gcmSeal
+func gcmOpen(key, sealed, aad []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,199 @@
+package database
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testMasterKey(fill byte) []byte {
+	return bytes.Repeat([]byte{fill}, masterKeySize)
+}
+
+// storedToken reads the api_token column of an agent as it is on disk
+func storedToken(t *testing.T, db *DB, id int64) string {
+	t.Helper()
+	var stored string
+	if err := db.QueryRow(`SELECT api_token FROM agents WHERE id = ?`, id).Scan(&stored); err != nil {
+		t.Fatalf("read api_token: %v", err)
+	}
+	return stored
+}
+
+func TestParseMasterKey(t *testing.T) {
+	key := testMasterKey(7)
+	if got, err := ParseMasterKey(" " + base64.StdEncoding.EncodeToString(key) + "\n"); err != nil || !bytes.Equal(got, key) {
+		t.Errorf("ParseMasterKey = %v, %v", got, err)
+	}
+	for _, encoded := range []string{"not base64!", base64.StdEncoding.EncodeToString(key[:16])} {
+		if _, err := ParseMasterKey(encoded); err == nil {
+			t.Errorf("ParseMasterKey(%q) accepted an invalid key", encoded)
+		}
+	}
+	if err := newTestDB(t).SetMasterKey(key[:16]); err == nil {
+		t.Error("SetMasterKey accepted a 16 byte key")
+	}
+}
+
+func TestTokensEncryptedAtRest(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.SetMasterKey(testMasterKey(1)); err != nil {
+		t.Fatalf("SetMasterKey: %v", err)
+	}
+	agent := insertTestAgent(t, db, "Alice")
+
+	stored := storedToken(t, db, agent.ID)
+	if !strings.HasPrefix(stored, encryptedTokenPrefix) || strings.Contains(stored, "sk-test") {
+		t.Fatalf("stored token = %q, want it encrypted", stored)
+	}
+	// Each write seals the token afresh
+	if err := db.UpdateAgent(agent); err != nil {
+		t.Fatalf("UpdateAgent: %v", err)
+	}
+	if again := storedToken(t, db, agent.ID); again == stored || !strings.HasPrefix(again, encryptedTokenPrefix) {
+		t.Errorf("token after update = %q, want a new ciphertext", again)
+	}
+
+	got, err := db.GetAgent(agent.ID)
+	if err != nil || got.APIToken != "sk-test" {
+		t.Fatalf("GetAgent = %+v, %v, want the token decrypted", got, err)
+	}
+	agents, err := db.GetAllAgents()
+	if err != nil || len(agents) != 1 || agents[0].APIToken != "sk-test" {
+		t.Errorf("GetAllAgents = %v, %v, want the token decrypted", agents, err)
+	}
+
+	// Empty tokens stay empty
+	agent.APIToken = ""
+	if err := db.UpdateAgent(agent); err != nil || storedToken(t, db, agent.ID) != "" {
+		t.Errorf("empty token stored as %q, %v", storedToken(t, db, agent.ID), err)
+	}
+}
+
+func TestCheckMasterKey(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.CheckMasterKey(); err != nil {
+		t.Errorf("CheckMasterKey without encrypted tokens = %v", err)
+	}
+	db.SetMasterKey(testMasterKey(1))
+	agent := insertTestAgent(t, db, "Alice")
+
+	db.masterKey = nil
+	if err := db.CheckMasterKey(); !errors.Is(err, ErrMasterKeyRequired) {
+		t.Errorf("CheckMasterKey without the key = %v, want ErrMasterKeyRequired", err)
+	}
+	if _, err := db.GetAgent(agent.ID); !errors.Is(err, ErrMasterKeyRequired) {
+		t.Errorf("GetAgent without the key = %v, want ErrMasterKeyRequired", err)
+	}
+	db.SetMasterKey(testMasterKey(2))
+	if err := db.CheckMasterKey(); err == nil || !strings.Contains(err.Error(), "different master key") {
+		t.Errorf("CheckMasterKey with another key = %v", err)
+	}
+	db.SetMasterKey(testMasterKey(1))
+	if err := db.CheckMasterKey(); err != nil {
+		t.Errorf("CheckMasterKey with the right key = %v", err)
+	}
+
+	// Every encrypted token is checked, not just the first
+	bob := insertTestAgent(t, db, "Bob")
+	sealed, err := sealToken(testMasterKey(2), "sk-other", tokenAAD(bob.ID))
+	if err != nil {
+		t.Fatalf("sealToken: %v", err)
+	}
+	db.Exec(`UPDATE agents SET api_token = ? WHERE id = ?`, sealed, bob.ID)
+	if err := db.CheckMasterKey(); err == nil || !strings.Contains(err.Error(), "different master key") {
+		t.Errorf("CheckMasterKey with one token under another key = %v", err)
+	}
+
+	// A token copied onto another agent does not decrypt there
+	stored := storedToken(t, db, agent.ID)
+	db.Exec(`UPDATE agents SET api_token = ? WHERE id = ?`, stored, bob.ID)
+	if _, err := db.GetAgent(bob.ID); err == nil || !strings.Contains(err.Error(), "another agent") {
+		t.Errorf("GetAgent of a token copied from another agent = %v", err)
+	}
+	if err := db.CheckMasterKey(); err == nil {
+		t.Error("CheckMasterKey accepted a token copied from another agent")
+	}
+	db.Exec(`UPDATE agents SET api_token = '' WHERE id = ?`, bob.ID)
+
+	// A token tampered with on disk is reported, not returned garbled
+	wrapped, sealed, _ := strings.Cut(strings.TrimPrefix(stored, encryptedTokenPrefix), ":")
+	raw, _ := base64.StdEncoding.DecodeString(sealed)
+	raw[len(raw)-1] ^= 1
+	db.Exec(`UPDATE agents SET api_token = ? WHERE id = ?`, encryptedTokenPrefix+wrapped+":"+base64.StdEncoding.EncodeToString(raw), agent.ID)
+	if _, err := db.GetAgent(agent.ID); err == nil || !strings.Contains(err.Error(), "corrupt") {
+		t.Errorf("GetAgent of a tampered token = %v", err)
+	}
+	db.Exec(`UPDATE agents SET api_token = ? WHERE id = ?`, encryptedTokenPrefix+"no-separator", agent.ID)
+	if _, err := db.GetAgent(agent.ID); err == nil || !strings.Contains(err.Error(), "malformed") {
+		t.Errorf("GetAgent of a malformed token = %v", err)
+	}
+}
+
+func TestEncryptTokens(t *testing.T) {
+	db := newTestDB(t)
+	alice := insertTestAgent(t, db, "Alice")
+	bob := insertTestAgent(t, db, "Bob")
+	bob.APIToken = ""
+	db.UpdateAgent(bob)
+
+	if n, err := db.EncryptTokens(); n != 0 || err != nil || storedToken(t, db, alice.ID) != "sk-test" {
+		t.Errorf("EncryptTokens without a key = %d, %v", n, err)
+	}
+
+	db.SetMasterKey(testMasterKey(1))
+	if n, err := db.EncryptTokens(); n != 1 || err != nil {
+		t.Fatalf("EncryptTokens = %d, %v, want the one plaintext token", n, err)
+	}
+	if stored := storedToken(t, db, alice.ID); !strings.HasPrefix(stored, encryptedTokenPrefix) {
+		t.Errorf("token after EncryptTokens = %q", stored)
+	}
+	if n, err := db.EncryptTokens(); n != 0 || err != nil {
+		t.Errorf("second EncryptTokens = %d, %v, want nothing left to do", n, err)
+	}
+	if got, _ := db.GetAgent(alice.ID); got.APIToken != "sk-test" {
+		t.Errorf("token = %q after encryption", got.APIToken)
+	}
+}
+
+func TestRotateMasterKey(t *testing.T) {
+	db := newTestDB(t)
+	alice := insertTestAgent(t, db, "Alice")
+	db.SetMasterKey(testMasterKey(1))
+	bob := insertTestAgent(t, db, "Bob")
+	bobSealed := storedToken(t, db, bob.ID)
+
+	if _, err := db.RotateMasterKey(testMasterKey(2)[:8]); err == nil {
+		t.Error("RotateMasterKey accepted a short key")
+	}
+	// The plaintext token is encrypted and the encrypted one rewrapped
+	if n, err := db.RotateMasterKey(testMasterKey(2)); n != 2 || err != nil {
+		t.Fatalf("RotateMasterKey = %d, %v", n, err)
+	}
+	_, oldSealed, _ := strings.Cut(strings.TrimPrefix(bobSealed, encryptedTokenPrefix), ":")
+	_, newSealed, _ := strings.Cut(strings.TrimPrefix(storedToken(t, db, bob.ID), encryptedTokenPrefix), ":")
+	if oldSealed != newSealed {
+		t.Error("rotation re-encrypted the token instead of rewrapping its data key")
+	}
+	for _, id := range []int64{alice.ID, bob.ID} {
+		if got, err := db.GetAgent(id); err != nil || got.APIToken != "sk-test" {
+			t.Errorf("agent %d after rotation = %v, %v", id, got, err)
+		}
+	}
+	db.SetMasterKey(testMasterKey(1))
+	if err := db.CheckMasterKey(); err == nil {
+		t.Error("the old key still reads the tokens after rotation")
+	}
+
+	// Without the current key nothing moves
+	db.masterKey = nil
+	before := storedToken(t, db, bob.ID)
+	if _, err := db.RotateMasterKey(testMasterKey(3)); !errors.Is(err, ErrMasterKeyRequired) {
+		t.Errorf("RotateMasterKey without the current key = %v", err)
+	}
+	if storedToken(t, db, bob.ID) != before || db.masterKey != nil {
+		t.Error("a failed rotation changed the stored tokens or the key")
+	}
+}
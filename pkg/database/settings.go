@@ -0,0 +1,211 @@
+package database
+
+import (
+	"court-table-ai/pkg/models"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Setting keys
+const (
+	SettingProviderPauses   = "provider_pauses"
+	SettingWatchdog         = "watchdog"
+	SettingTranscriptLog    = "transcript_log"
+	SettingDemoSeeded       = "demo_seeded"
+	SettingSlowCall         = "slow_call"
+	SettingDuplicateGuard   = "duplicate_guard"
+	SettingDeltaCoalescing  = "delta_coalescing"
+	SettingHostLimits       = "host_limits"
+	SettingAgentRetries     = "agent_retries"
+	SettingAlertRules       = "alert_rules"
+	SettingSubscriberLimits = "subscriber_limits"
+
+	SettingDefaultAgentTimeout = "default_agent_timeout_seconds"
+	SettingMaxAgentTimeout     = "max_agent_timeout_seconds"
+	SettingAgentTimeoutBuffer  = "agent_timeout_buffer_seconds"
+)
+
+// loadSettings fills the settings cache from the database
+func (db *DB) loadSettings() error {
+	rows, err := db.Query(`SELECT key, value FROM settings`)
+	if err != nil {
+		return fmt.Errorf("failed to query settings: %w", err)
+	}
+	defer rows.Close()
+
+	cache := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return fmt.Errorf("failed to scan setting: %w", err)
+		}
+		cache[key] = value
+	}
+
+	db.settingsCache = cache
+	return rows.Err()
+}
+
+// GetSetting returns the raw value of a setting and whether it is set
+func (db *DB) GetSetting(key string) (string, bool, error) {
+	db.settingsMu.RLock()
+	if db.settingsCache != nil {
+		value, ok := db.settingsCache[key]
+		db.settingsMu.RUnlock()
+		return value, ok, nil
+	}
+	db.settingsMu.RUnlock()
+
+	db.settingsMu.Lock()
+	defer db.settingsMu.Unlock()
+	if db.settingsCache == nil {
+		if err := db.loadSettings(); err != nil {
+			return "", false, err
+		}
+	}
+
+	value, ok := db.settingsCache[key]
+	return value, ok, nil
+}
+
+// GetAllSettings returns a copy of every stored setting
+func (db *DB) GetAllSettings() (map[string]string, error) {
+	db.settingsMu.Lock()
+	defer db.settingsMu.Unlock()
+
+	if db.settingsCache == nil {
+		if err := db.loadSettings(); err != nil {
+			return nil, err
+		}
+	}
+
+	settings := make(map[string]string, len(db.settingsCache))
+	for k, v := range db.settingsCache {
+		settings[k] = v
+	}
+	return settings, nil
+}
+
+// SetSetting stores a setting and updates the cache
+func (db *DB) SetSetting(key, value string) error {
+	query := `
+	INSERT INTO settings (key, value, updated_at) VALUES (?, ?, ?)
+	ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+	`
+
+	db.settingsMu.Lock()
+	defer db.settingsMu.Unlock()
+
+	if _, err := db.Exec(query, key, value, time.Now()); err != nil {
+		return fmt.Errorf("failed to save setting %s: %w", key, err)
+	}
+
+	if db.settingsCache != nil {
+		db.settingsCache[key] = value
+	}
+	return nil
+}
+
+// DeleteSetting removes a setting so its default applies again
+func (db *DB) DeleteSetting(key string) error {
+	db.settingsMu.Lock()
+	defer db.settingsMu.Unlock()
+
+	if _, err := db.Exec(`DELETE FROM settings WHERE key = ?`, key); err != nil {
+		return fmt.Errorf("failed to delete setting %s: %w", key, err)
+	}
+
+	if db.settingsCache != nil {
+		delete(db.settingsCache, key)
+	}
+	return nil
+}
+
+// GetSettingJSON decodes a JSON setting into v. It returns false when the
+// setting is not set, leaving v untouched.
+func (db *DB) GetSettingJSON(key string, v interface{}) (bool, error) {
+	value, ok, err := db.GetSetting(key)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	if err := json.Unmarshal([]byte(value), v); err != nil {
+		return false, fmt.Errorf("failed to decode setting %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// SetSettingJSON encodes v as JSON and stores it as a setting
+func (db *DB) SetSettingJSON(key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode setting %s: %w", key, err)
+	}
+	return db.SetSetting(key, string(data))
+}
+
+// GetSettingInt returns an integer setting, or def when it is not set
+func (db *DB) GetSettingInt(key string, def int) (int, error) {
+	value, ok, err := db.GetSetting(key)
+	if err != nil || !ok {
+		return def, err
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return def, fmt.Errorf("failed to decode setting %s: %w", key, err)
+	}
+	return n, nil
+}
+
+// GetAgentTimeouts returns the agent timeout settings with defaults applied.
+// An invalid combination falls back to the built-in defaults.
+func (db *DB) GetAgentTimeouts() (models.AgentTimeouts, error) {
+	timeouts := models.DefaultAgentTimeouts()
+
+	var err error
+	if timeouts.DefaultSeconds, err = db.GetSettingInt(SettingDefaultAgentTimeout, models.DefaultAgentTimeoutSeconds); err != nil {
+		return models.DefaultAgentTimeouts(), err
+	}
+	if timeouts.MaxSeconds, err = db.GetSettingInt(SettingMaxAgentTimeout, models.DefaultMaxAgentTimeoutSeconds); err != nil {
+		return models.DefaultAgentTimeouts(), err
+	}
+	if timeouts.BufferSeconds, err = db.GetSettingInt(SettingAgentTimeoutBuffer, models.DefaultAgentTimeoutBufferSeconds); err != nil {
+		return models.DefaultAgentTimeouts(), err
+	}
+
+	if err := timeouts.Validate(); err != nil {
+		return models.DefaultAgentTimeouts(), err
+	}
+	return timeouts, nil
+}
+
+// GetAgentRetryConfig returns the agent call retry settings, or the built-in
+// defaults when none or invalid ones are stored
+func (db *DB) GetAgentRetryConfig() (models.AgentRetryConfig, error) {
+	cfg := models.DefaultAgentRetryConfig()
+	found, err := db.GetSettingJSON(SettingAgentRetries, &cfg)
+	if err != nil || !found {
+		return models.DefaultAgentRetryConfig(), err
+	}
+	if err := cfg.Validate(); err != nil {
+		return models.DefaultAgentRetryConfig(), err
+	}
+	return cfg, nil
+}
+
+// GetTranscriptLogConfig returns the transcript log settings with defaults
+// applied. An invalid stored value leaves the log disabled.
+func (db *DB) GetTranscriptLogConfig() (models.TranscriptLogConfig, error) {
+	var cfg models.TranscriptLogConfig
+	if _, err := db.GetSettingJSON(SettingTranscriptLog, &cfg); err != nil {
+		return models.TranscriptLogConfig{}, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return models.TranscriptLogConfig{}, err
+	}
+	return cfg, nil
+}
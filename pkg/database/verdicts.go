@@ -0,0 +1,54 @@
+package database
+
+import (
+	"court-table-ai/pkg/models"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// verdictColumns receives the verdict_* columns of a discussion.
+// verdict_reasoning is NULL until the judge has answered.
+type verdictColumns struct {
+	winnerID  sql.NullInt64
+	scores    models.VerdictScores
+	reasoning sql.NullString
+}
+
+// verdict returns the stored verdict, or nil when there is none
+func (v verdictColumns) verdict(judgeID *int64) *models.Verdict {
+	if !v.reasoning.Valid {
+		return nil
+	}
+	verdict := &models.Verdict{Scores: v.scores, Reasoning: v.reasoning.String}
+	if judgeID != nil {
+		verdict.JudgeID = *judgeID
+	}
+	if v.winnerID.Valid {
+		winner := v.winnerID.Int64
+		verdict.WinnerAgentID = &winner
+	}
+	return verdict
+}
+
+// SaveDiscussionVerdict stores the judge's verdict on a discussion; a nil
+// verdict clears it
+func (db *DB) SaveDiscussionVerdict(discussionID int64, verdict *models.Verdict) error {
+	var (
+		winner    *int64
+		scores    models.VerdictScores
+		reasoning *string
+	)
+	if verdict != nil {
+		winner, scores, reasoning = verdict.WinnerAgentID, verdict.Scores, &verdict.Reasoning
+	}
+
+	_, err := db.Exec(`
+	UPDATE discussions SET verdict_winner_id = ?, verdict_scores = ?, verdict_reasoning = ?, updated_at = ?
+	WHERE id = ? AND status != 'deleting'`,
+		winner, scores, reasoning, time.Now(), discussionID)
+	if err != nil {
+		return fmt.Errorf("failed to save discussion verdict: %w", err)
+	}
+	return nil
+}
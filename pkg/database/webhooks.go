@@ -0,0 +1,95 @@
+package database
+
+import (
+	"court-table-ai/pkg/models"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// webhookDeliveriesSQL creates the table recording webhook delivery attempts.
+// Test fires made without a discussion are stored with a NULL discussion_id.
+const webhookDeliveriesSQL = `
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		discussion_id INTEGER,
+		url TEXT NOT NULL,
+		event TEXT NOT NULL,
+		delivered BOOLEAN NOT NULL DEFAULT FALSE,
+		status_code INTEGER NOT NULL DEFAULT 0,
+		latency_ms INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (discussion_id) REFERENCES discussions(id) ON DELETE CASCADE
+	);`
+
+// webhookDeliveryIndexes are created alongside webhook_deliveries
+var webhookDeliveryIndexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_discussion_id ON webhook_deliveries(discussion_id);",
+}
+
+// InsertWebhookDelivery records a webhook delivery attempt
+func (db *DB) InsertWebhookDelivery(delivery *models.WebhookDelivery) error {
+	query := `
+	INSERT INTO webhook_deliveries (discussion_id, url, event, delivered, status_code, latency_ms, error, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	if delivery.CreatedAt.IsZero() {
+		delivery.CreatedAt = time.Now()
+	}
+	var discussionID interface{}
+	if delivery.DiscussionID != 0 {
+		discussionID = delivery.DiscussionID
+	}
+
+	result, err := db.Exec(query, discussionID, delivery.URL, delivery.Event, delivery.Delivered,
+		delivery.StatusCode, delivery.LatencyMs, delivery.Error, delivery.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert webhook delivery: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	delivery.ID = id
+	return nil
+}
+
+// GetWebhookDeliveries retrieves the webhook deliveries of a discussion,
+// newest first
+func (db *DB) GetWebhookDeliveries(discussionID int64) ([]*models.WebhookDelivery, error) {
+	query := `
+	SELECT id, discussion_id, url, event, delivered, status_code, latency_ms, error, created_at
+	FROM webhook_deliveries WHERE discussion_id = ? ORDER BY created_at DESC, id DESC
+	`
+
+	rows, err := db.Query(query, discussionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []*models.WebhookDelivery{}
+	for rows.Next() {
+		delivery := &models.WebhookDelivery{}
+		var discussion sql.NullInt64
+		if err := rows.Scan(&delivery.ID, &discussion, &delivery.URL, &delivery.Event, &delivery.Delivered,
+			&delivery.StatusCode, &delivery.LatencyMs, &delivery.Error, &delivery.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		delivery.DiscussionID = discussion.Int64
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// DeleteWebhookDeliveries deletes the webhook deliveries of a discussion
+func (db *DB) DeleteWebhookDeliveries(discussionID int64) error {
+	if _, err := db.Exec(`DELETE FROM webhook_deliveries WHERE discussion_id = ?`, discussionID); err != nil {
+		return fmt.Errorf("failed to delete webhook deliveries: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package export
+
+import (
+	"court-table-ai/pkg/models"
+	"fmt"
+	"strconv"
+)
+
+// Anchor locates a log entry in the transcript for deep links
+type Anchor struct {
+	Round int    // 0 before the first agent turn
+	ID    string // e.g. "r2-s14", unique within the discussion
+}
+
+// ContentsEntry is one round of a transcript's table of contents
+type ContentsEntry struct {
+	Round   int    `json:"round"`  // 0 for the entries before the first agent turn
+	Anchor  string `json:"anchor"` // of the round's first entry
+	Entries int    `json:"entries"`
+}
+
+// Anchors returns the anchor of every entry of logs, keyed by log ID. logs
+// must be in transcript order. An anchor combines the round with the entry's
+// sequence, or its ID for entries predating sequence tracking, so it does
+// not change as the discussion grows. A retry is anchored under the entry it
+// retried, e.g. "r2-s14-retry1", and shares its round.
+func Anchors(logs []*models.DiscussionLog) map[int64]Anchor {
+	anchors := make(map[int64]Anchor, len(logs))
+	retries := make(map[int64]int)
+	turns := make(map[int64]int)
+	round := 0
+
+	for _, l := range logs {
+		entryRound := round
+		if !l.IsModerator && !l.IsSystem() && l.LogType == models.LogTypeResponse {
+			if l.Status == "rejected" {
+				// Rejected replies do not count as turns, see Walk
+				if r, err := strconv.Atoi(l.Metadata["round"]); err == nil && r > 0 {
+					entryRound = r
+				}
+			} else {
+				if r := logRound(l, turns, round); r > round {
+					round = r
+				}
+				entryRound = round
+			}
+		}
+
+		if parentID, err := strconv.ParseInt(l.Metadata["retry_of"], 10, 64); err == nil {
+			if parent, ok := anchors[parentID]; ok {
+				retries[parentID]++
+				anchors[l.ID] = Anchor{Round: parent.Round, ID: fmt.Sprintf("%s-retry%d", parent.ID, retries[parentID])}
+				continue
+			}
+		}
+
+		anchor := Anchor{Round: entryRound}
+		if l.Sequence > 0 {
+			anchor.ID = fmt.Sprintf("r%d-s%d", entryRound, l.Sequence)
+		} else {
+			anchor.ID = fmt.Sprintf("r%d-l%d", entryRound, l.ID)
+		}
+		anchors[l.ID] = anchor
+	}
+	return anchors
+}
+
+// ApplyAnchors sets the Anchor of every entry of logs, see Anchors
+func ApplyAnchors(logs []*models.DiscussionLog) map[int64]Anchor {
+	anchors := Anchors(logs)
+	for _, l := range logs {
+		l.Anchor = anchors[l.ID].ID
+	}
+	return anchors
+}
+
+// Contents lists the rounds of logs in order with the anchor of each
+// round's first entry
+func Contents(logs []*models.DiscussionLog, anchors map[int64]Anchor) []ContentsEntry {
+	contents := []ContentsEntry{}
+	index := make(map[int]int)
+	for _, l := range logs {
+		anchor, ok := anchors[l.ID]
+		if !ok {
+			continue
+		}
+		i, seen := index[anchor.Round]
+		if !seen {
+			i = len(contents)
+			index[anchor.Round] = i
+			contents = append(contents, ContentsEntry{Round: anchor.Round, Anchor: anchor.ID})
+		}
+		contents[i].Entries++
+	}
+	return contents
+}
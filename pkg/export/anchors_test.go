@@ -0,0 +1,99 @@
+package export
+
+import (
+	"strconv"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+// anchorFixture is a two-round debate with a moderator in which Bob's second
+// turn failed
+func anchorFixture() []*models.DiscussionLog {
+	moderator := func(seq int64, phase string) *models.DiscussionLog {
+		return &models.DiscussionLog{ID: seq, Sequence: seq, AgentID: 3, IsModerator: true, Status: "success", LogType: models.LogTypeResponse,
+			Metadata: models.JSONMap{"moderator_phase": phase}}
+	}
+	turn := func(seq, agentID int64, round, status string) *models.DiscussionLog {
+		return &models.DiscussionLog{ID: seq, Sequence: seq, AgentID: agentID, Status: status, LogType: models.LogTypeResponse,
+			Metadata: models.JSONMap{"round": round}}
+	}
+	return []*models.DiscussionLog{
+		moderator(1, "opening"),
+		turn(2, 1, "1", "success"),
+		turn(3, 2, "1", "rejected"),
+		turn(4, 2, "1", "success"),
+		moderator(5, "round_summary"),
+		turn(6, 1, "2", "success"),
+		turn(7, 2, "2", "error"),
+		moderator(8, "closing"),
+	}
+}
+
+func TestAnchors(t *testing.T) {
+	logs := anchorFixture()
+	before := Anchors(logs)
+	want := map[int64]Anchor{
+		1: {0, "r0-s1"},
+		2: {1, "r1-s2"},
+		// A rejected reply keeps the round it was given
+		3: {1, "r1-s3"},
+		4: {1, "r1-s4"},
+		5: {1, "r1-s5"},
+		6: {2, "r2-s6"},
+		7: {2, "r2-s7"},
+		8: {2, "r2-s8"},
+	}
+	for id, w := range want {
+		if before[id] != w {
+			t.Errorf("anchor of %d = %+v, want %+v", id, before[id], w)
+		}
+	}
+
+	// Retries are logged at the end and anchored under the failed turn
+	retry := func(seq, of int64, status string) *models.DiscussionLog {
+		return &models.DiscussionLog{ID: seq, Sequence: seq, AgentID: 2, Status: status, LogType: models.LogTypeResponse,
+			Metadata: models.JSONMap{"round": "2", "retry_of": strconv.FormatInt(of, 10)}}
+	}
+	logs = append(logs, retry(9, 7, "error"), retry(10, 7, "success"))
+	after := Anchors(logs)
+	for id, w := range want {
+		if after[id] != w {
+			t.Errorf("anchor of %d changed to %+v after a retry, want %+v", id, after[id], w)
+		}
+	}
+	if after[9] != (Anchor{2, "r2-s7-retry1"}) || after[10] != (Anchor{2, "r2-s7-retry2"}) {
+		t.Errorf("retry anchors = %+v and %+v, want r2-s7-retry1 and r2-s7-retry2", after[9], after[10])
+	}
+
+	// Entries from before sequences were tracked fall back to their ID
+	legacy := []*models.DiscussionLog{{ID: 41, AgentID: 1, Status: "success", LogType: models.LogTypeResponse}}
+	if got := Anchors(legacy)[41]; got != (Anchor{1, "r1-l41"}) {
+		t.Errorf("legacy anchor = %+v, want r1-l41", got)
+	}
+}
+
+func TestContents(t *testing.T) {
+	logs := anchorFixture()
+	logs = append(logs, &models.DiscussionLog{ID: 9, Sequence: 9, AgentID: 2, Status: "success", LogType: models.LogTypeResponse,
+		Metadata: models.JSONMap{"round": "2", "retry_of": "7"}})
+
+	got := Contents(logs, ApplyAnchors(logs))
+	want := []ContentsEntry{
+		{Round: 0, Anchor: "r0-s1", Entries: 1},
+		{Round: 1, Anchor: "r1-s2", Entries: 4},
+		// The retry at the end is counted in the round it belongs to
+		{Round: 2, Anchor: "r2-s6", Entries: 4},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("contents = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("contents[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if logs[8].Anchor != "r2-s7-retry1" {
+		t.Errorf("ApplyAnchors set %q on the retry", logs[8].Anchor)
+	}
+}
@@ -0,0 +1,211 @@
+package export
+
+import (
+	"court-table-ai/pkg/models"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// FormatJSON renders a discussion as a structured JSON document
+const FormatJSON = "json"
+
+// JSONSchemaVersion versions the JSON transcript. Adding fields keeps the
+// version; renaming, removing or changing the meaning of one bumps it.
+const JSONSchemaVersion = 1
+
+// Participant and turn roles in the JSON transcript
+const (
+	RoleParticipant = "participant"
+	RoleModerator   = "moderator"
+	RoleJudge       = "judge"
+)
+
+// JSONTranscript is the document written by WriteJSON. Its types are the
+// export schema and are kept apart from the API models, so changes to the
+// API do not change the export.
+type JSONTranscript struct {
+	SchemaVersion int               `json:"schema_version"`
+	ExportedAt    time.Time         `json:"exported_at"`
+	Discussion    JSONDiscussion    `json:"discussion"`
+	Participants  []JSONParticipant `json:"participants"`
+	Contents      []ContentsEntry   `json:"contents"` // the rounds, linking to turn anchors
+	Turns         []JSONTurn        `json:"turns"`
+	// Notes are the readers' notes on the discussion, only when asked for
+	Notes []JSONNote `json:"notes,omitempty"`
+}
+
+// JSONDiscussion is the discussion metadata of a JSON transcript
+type JSONDiscussion struct {
+	ID              int64           `json:"id"`
+	Topic           string          `json:"topic"`
+	Status          string          `json:"status"`
+	Language        string          `json:"language"`
+	MaxRounds       int             `json:"max_rounds"`
+	CompletedRounds int             `json:"completed_rounds"`
+	MaxCharLimit    int             `json:"max_char_limit"`
+	EndReason       string          `json:"end_reason"`
+	ErrorMessage    string          `json:"error_message"`
+	FinalSummary    string          `json:"final_summary"`
+	Verdict         *models.Verdict `json:"verdict"`
+	AppVersion      string          `json:"app_version"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+// JSONParticipant is an agent taking part in the discussion with its
+// configuration, without its API token. An agent deleted since keeps only
+// its ID and role.
+type JSONParticipant struct {
+	AgentID        int64  `json:"agent_id"`
+	Name           string `json:"name"`
+	Role           string `json:"role"`   // participant, moderator, judge
+	Stance         string `json:"stance"` // pro, con, neutral or empty
+	ProviderType   string `json:"provider_type"`
+	ProviderURL    string `json:"provider_url"`
+	ModelName      string `json:"model_name"`
+	EndpointStyle  string `json:"endpoint_style"`
+	APIVersion     string `json:"api_version"` // Azure OpenAI only
+	TimeoutSeconds int    `json:"timeout_seconds"`
+	SystemPrompt   string `json:"system_prompt"`
+	// Summary is where a debating agent ended up, as in the discussion API
+	Summary *models.ParticipantSummary `json:"summary,omitempty"`
+}
+
+// JSONTurn is one entry of the transcript, in speaking order
+type JSONTurn struct {
+	Index          int       `json:"index"` // position in the transcript, from 1
+	LogID          int64     `json:"log_id"`
+	Round          int       `json:"round"`  // 0 before the first agent turn
+	Anchor         string    `json:"anchor"` // stable across exports, see Anchors
+	AgentID        int64     `json:"agent_id"`
+	AgentName      string    `json:"agent_name"`
+	Role           string    `json:"role"` // participant, moderator
+	Kind           string    `json:"kind"` // speech, failure, skip
+	Phase          string    `json:"phase"`
+	Content        string    `json:"content"`
+	Status         string    `json:"status"`
+	ResponseTimeMs int       `json:"response_time_ms"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// JSONNote is a reader's note on the discussion. Notes are not part of the
+// debate and never reached the agents.
+type JSONNote struct {
+	ID        int64     `json:"id"`
+	Author    string    `json:"author"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BuildJSON assembles the JSON transcript of a discussion. agents maps the
+// IDs of the discussion's agents to their current configuration, and
+// summaries are the debating agents' participant summaries. notes are left
+// out of the document when nil.
+func BuildJSON(discussion *models.Discussion, logs []*models.DiscussionLog, agents map[int64]*models.Agent, summaries []models.ParticipantSummary, notes []*models.DiscussionNote) (*JSONTranscript, error) {
+	doc := &JSONTranscript{
+		SchemaVersion: JSONSchemaVersion,
+		ExportedAt:    time.Now().UTC(),
+		Discussion: JSONDiscussion{
+			ID:              discussion.ID,
+			Topic:           discussion.Topic,
+			Status:          discussion.Status,
+			Language:        discussion.Language,
+			MaxRounds:       discussion.MaxRounds,
+			CompletedRounds: discussion.CompletedRounds,
+			MaxCharLimit:    discussion.MaxCharLimit,
+			EndReason:       discussion.EndReason,
+			ErrorMessage:    discussion.ErrorMessage,
+			FinalSummary:    discussion.FinalSummary,
+			Verdict:         discussion.Verdict,
+			AppVersion:      discussion.AppVersion,
+			CreatedAt:       discussion.CreatedAt,
+			UpdatedAt:       discussion.UpdatedAt,
+		},
+		Participants: []JSONParticipant{},
+		Contents:     Contents(logs, Anchors(logs)),
+		Turns:        []JSONTurn{},
+	}
+
+	names := make(map[int64]string, len(agents))
+	for id, agent := range agents {
+		names[id] = agent.Name
+	}
+
+	participant := func(id int64, role string) JSONParticipant {
+		p := JSONParticipant{AgentID: id, Name: speakerName(names, id), Role: role, Stance: discussion.StanceOf(id)}
+		if agent := agents[id]; agent != nil {
+			p.ProviderType = agent.EffectiveProviderType()
+			p.ProviderURL = agent.ProviderURL
+			p.ModelName = agent.ModelName
+			p.EndpointStyle = agent.EndpointStyle
+			p.APIVersion = agent.APIVersion
+			p.TimeoutSeconds = agent.TimeoutSeconds
+			p.SystemPrompt = agent.SystemPrompt
+		}
+		return p
+	}
+	for _, id := range discussion.AgentIDs {
+		p := participant(id, RoleParticipant)
+		for i := range summaries {
+			if summaries[i].AgentID == id {
+				p.Summary = &summaries[i]
+			}
+		}
+		doc.Participants = append(doc.Participants, p)
+	}
+	if discussion.ModeratorID != nil {
+		doc.Participants = append(doc.Participants, participant(*discussion.ModeratorID, RoleModerator))
+	}
+	if discussion.JudgeID != nil {
+		p := participant(*discussion.JudgeID, RoleJudge)
+		p.Stance = ""
+		doc.Participants = append(doc.Participants, p)
+	}
+
+	err := Walk(logs, names, func(t Turn) error {
+		role := RoleParticipant
+		if t.IsModerator {
+			role = RoleModerator
+		}
+		doc.Turns = append(doc.Turns, JSONTurn{
+			Index:          len(doc.Turns) + 1,
+			LogID:          t.Log.ID,
+			Round:          t.Round,
+			Anchor:         t.Anchor,
+			AgentID:        t.AgentID,
+			AgentName:      t.Speaker,
+			Role:           role,
+			Kind:           t.Kind,
+			Phase:          t.Phase,
+			Content:        t.Content,
+			Status:         t.Log.Status,
+			ResponseTimeMs: t.Log.ResponseTime,
+			CreatedAt:      t.Log.CreatedAt,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if notes != nil {
+		doc.Notes = make([]JSONNote, 0, len(notes))
+		for _, n := range notes {
+			doc.Notes = append(doc.Notes, JSONNote{ID: n.ID, Author: n.Author, Content: n.Content, CreatedAt: n.CreatedAt, UpdatedAt: n.UpdatedAt})
+		}
+	}
+	return doc, nil
+}
+
+// WriteJSON writes the JSON transcript of a discussion, see BuildJSON
+func WriteJSON(w io.Writer, discussion *models.Discussion, logs []*models.DiscussionLog, agents map[int64]*models.Agent, summaries []models.ParticipantSummary, notes []*models.DiscussionNote) error {
+	doc, err := BuildJSON(discussion, logs, agents, summaries, notes)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
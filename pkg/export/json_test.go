@@ -0,0 +1,149 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/models"
+)
+
+// jsonFixture is the script fixture with the agents' configuration, each
+// with an API token that must stay out of the export
+func jsonFixture() (*models.Discussion, []*models.DiscussionLog, map[int64]*models.Agent) {
+	discussion, logs, names := scriptFixture()
+	discussion.Status = "completed"
+	discussion.Language = "en"
+	discussion.MaxRounds = 2
+	discussion.CompletedRounds = 2
+	discussion.Stances = []models.AgentStance{{AgentID: 1, Stance: models.StancePro}}
+	agents := make(map[int64]*models.Agent, len(names))
+	for id, name := range names {
+		agents[id] = &models.Agent{
+			ID:             id,
+			Name:           name,
+			ProviderType:   models.ProviderOpenAI,
+			ProviderURL:    "https://api.openai.com/v1",
+			APIToken:       "sk-secret-token",
+			ModelName:      "gpt-4o-mini",
+			EndpointStyle:  models.EndpointStyleChatCompletions,
+			TimeoutSeconds: 60,
+		}
+	}
+	for i, l := range logs {
+		l.ResponseTime = 100 * i
+		l.CreatedAt = time.Date(2025, 3, 1, 9, 0, i, 0, time.UTC)
+	}
+	return discussion, logs, agents
+}
+
+func TestBuildJSON(t *testing.T) {
+	discussion, logs, agents := jsonFixture()
+	doc, err := BuildJSON(discussion, logs, agents, nil, nil)
+	if err != nil {
+		t.Fatalf("BuildJSON: %v", err)
+	}
+	// The golden file pins the schema: changing it other than by adding
+	// fields needs a new JSONSchemaVersion. The export time is the only part
+	// that changes between runs.
+	doc.ExportedAt = time.Time{}
+	got, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		t.Fatalf("json.MarshalIndent: %v", err)
+	}
+	checkGolden(t, "transcript.json.golden", append(got, '\n'))
+
+	if bytes.Contains(got, []byte("sk-secret-token")) || bytes.Contains(got, []byte("api_token")) {
+		t.Error("the export carries an API token")
+	}
+}
+
+func TestBuildJSONTurns(t *testing.T) {
+	discussion, logs, agents := jsonFixture()
+	// An agent deleted since keeps its ID and role
+	delete(agents, 2)
+	doc, err := BuildJSON(discussion, logs, agents, nil, nil)
+	if err != nil {
+		t.Fatalf("BuildJSON: %v", err)
+	}
+	if doc.SchemaVersion != JSONSchemaVersion || doc.Notes != nil {
+		t.Errorf("schema_version = %d with notes %v, want %d without notes", doc.SchemaVersion, doc.Notes, JSONSchemaVersion)
+	}
+
+	var roles []string
+	for _, p := range doc.Participants {
+		roles = append(roles, p.Role+":"+p.Name+":"+p.Stance+":"+p.ModelName)
+	}
+	if want := "participant:Zoë:pro:gpt-4o-mini|participant:Agent #2::|moderator:Sage::gpt-4o-mini"; strings.Join(roles, "|") != want {
+		t.Errorf("participants = %v, want %s", roles, want)
+	}
+
+	// Rejected replies and engine notes are left out, as in the script
+	var turns []string
+	for i, turn := range doc.Turns {
+		if turn.Index != i+1 {
+			t.Errorf("turn %d has index %d", i, turn.Index)
+		}
+		turns = append(turns, strings.Join([]string{turn.Role, turn.AgentName, turn.Kind, turn.Status, strconv.Itoa(turn.Round)}, ":"))
+	}
+	want := []string{
+		"moderator:Sage:speech:success:0",
+		"participant:Zoë:speech:success:1",
+		"participant:Agent #2:failure:error:1",
+		"moderator:Sage:speech:success:1",
+		"participant:Zoë:speech:success:2",
+		"participant:Agent #2:skip:success:2",
+		"participant:Zoë:speech:success:2",
+		"participant:Agent #2:speech:success:2",
+		"moderator:Sage:speech:success:2",
+	}
+	if strings.Join(turns, "\n") != strings.Join(want, "\n") {
+		t.Errorf("turns =\n%s\nwant\n%s", strings.Join(turns, "\n"), strings.Join(want, "\n"))
+	}
+
+	notes := []*models.DiscussionNote{{ID: 4, Author: "Rina", Content: "Check round 2"}}
+	if doc, err := BuildJSON(discussion, logs, agents, nil, notes); err != nil || len(doc.Notes) != 1 || doc.Notes[0].Author != "Rina" {
+		t.Errorf("BuildJSON with notes = %+v, %v", doc, err)
+	}
+}
+
+func TestBuildJSONParticipantSummaries(t *testing.T) {
+	discussion, logs, agents := jsonFixture()
+	judge := int64(3)
+	discussion.JudgeID = &judge
+	agents[judge] = &models.Agent{ID: judge, Name: "Judy"}
+	summaries := []models.ParticipantSummary{
+		{AgentID: 2, FinalPosition: "Spaces.", Turns: 1, Failures: 1},
+		{AgentID: 1, Stance: models.StancePro, FinalPosition: "Tabs.", Turns: 3},
+	}
+	doc, err := BuildJSON(discussion, logs, agents, summaries, nil)
+	if err != nil {
+		t.Fatalf("BuildJSON: %v", err)
+	}
+
+	// Summaries are matched by agent, and only debaters get one
+	for _, p := range doc.Participants {
+		switch {
+		case p.Role != RoleParticipant && p.Summary != nil:
+			t.Errorf("%s %s has a participant summary", p.Role, p.Name)
+		case p.Role == RoleParticipant && (p.Summary == nil || p.Summary.AgentID != p.AgentID):
+			t.Errorf("participant %d has summary %+v", p.AgentID, p.Summary)
+		}
+	}
+	if got := doc.Participants[0].Summary; got == nil || got.FinalPosition != "Tabs." || got.Turns != 3 {
+		t.Errorf("agent 1 summary = %+v", got)
+	}
+
+	doc, err = BuildJSON(discussion, logs, agents, nil, nil)
+	if err != nil {
+		t.Fatalf("BuildJSON: %v", err)
+	}
+	for _, p := range doc.Participants {
+		if p.Summary != nil {
+			t.Errorf("participant %d has a summary without summaries", p.AgentID)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package export
+
+import (
+	"bufio"
+	"court-table-ai/pkg/models"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// FormatScript renders a discussion as a plain-text script for reading aloud
+const FormatScript = "script"
+
+// ScriptOptions controls the script renderer
+type ScriptOptions struct {
+	// Cast lists the participants under the title
+	Cast bool
+	// Agreement, when set, adds where each participant stood on the key
+	// points after the debate
+	Agreement *models.AgreementMatrix
+}
+
+// technicalDifficulty stands in for a turn whose call failed
+const technicalDifficulty = "[technical difficulty]"
+
+// WriteScript writes discussion as a script: one paragraph per turn labelled
+// with the speaker's name in capitals, or MODERATOR, with stage directions
+// at round transitions, the closing statements and skipped turns. Markdown is reduced to plain text
+// so the result can be handed to a narrator or a text-to-speech engine.
+func WriteScript(w io.Writer, discussion *models.Discussion, logs []*models.DiscussionLog, names map[int64]string, opts ScriptOptions) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "%s\n", strings.ToUpper(oneLine(discussion.Topic)))
+	if opts.Cast {
+		writeCast(bw, discussion, names)
+	}
+
+	round := 0
+	closing := false
+	err := Walk(logs, names, func(t Turn) error {
+		if !t.IsModerator && t.Round > round {
+			round = t.Round
+			fmt.Fprintf(bw, "\n[ROUND %d]\n", round)
+		}
+		if t.Phase == models.PhaseClosingStatement && !closing {
+			closing = true
+			fmt.Fprint(bw, "\n[CLOSING STATEMENTS]\n")
+		}
+
+		speaker := strings.ToUpper(t.Speaker)
+		if t.IsModerator {
+			speaker = "MODERATOR"
+		}
+
+		switch t.Kind {
+		case TurnSkip:
+			fmt.Fprintf(bw, "\n(%s sits out this turn.)\n", speaker)
+		case TurnFailure:
+			fmt.Fprintf(bw, "\n%s: %s\n", speaker, technicalDifficulty)
+		default:
+			if direction := moderatorDirection(t.Phase); t.IsModerator && direction != "" {
+				fmt.Fprintf(bw, "\n(%s)\n", direction)
+			}
+			text := StripMarkdown(t.Content)
+			if text == "" {
+				text = "(says nothing)"
+			}
+			fmt.Fprintf(bw, "\n%s: %s\n", speaker, text)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if opts.Agreement != nil && len(opts.Agreement.Rows) > 0 {
+		writeAgreement(bw, opts.Agreement)
+	}
+
+	fmt.Fprint(bw, "\n[END]\n")
+	return bw.Flush()
+}
+
+// writeCast lists the moderator and the debaters in their speaking order
+func writeCast(w io.Writer, discussion *models.Discussion, names map[int64]string) {
+	fmt.Fprint(w, "\nCAST\n")
+	if discussion.ModeratorID != nil {
+		fmt.Fprintf(w, "MODERATOR, played by %s\n", oneLine(speakerName(names, *discussion.ModeratorID)))
+	}
+	for _, id := range discussion.AgentIDs {
+		fmt.Fprintf(w, "%s\n", strings.ToUpper(oneLine(speakerName(names, id))))
+	}
+}
+
+// writeAgreement lists the key points of an analysis with each
+// participant's position and the share of agreement on the point
+func writeAgreement(w io.Writer, matrix *models.AgreementMatrix) {
+	fmt.Fprintf(w, "\n[AGREEMENT: %d%% CONSENSUS]\n", percent(matrix.Consensus))
+	for _, row := range matrix.Rows {
+		fmt.Fprintf(w, "\n%s (%d%% agreement)\n", oneLine(row.Point), percent(row.Agreement))
+		for i, agent := range matrix.Agents {
+			position := "did not address it"
+			switch row.Positions[i] {
+			case models.AnalysisSupport:
+				position = "supports"
+			case models.AnalysisOppose:
+				position = "opposes"
+			}
+			fmt.Fprintf(w, "%s %s\n", strings.ToUpper(oneLine(agent.Name)), position)
+		}
+	}
+}
+
+func percent(share float64) int {
+	return int(math.Round(share * 100))
+}
+
+// moderatorDirection returns the stage direction announcing a moderator phase
+func moderatorDirection(phase string) string {
+	switch phase {
+	case "opening":
+		return "The moderator opens the debate."
+	case "round_summary":
+		return "The moderator sums up the round."
+	case "closing":
+		return "The moderator closes the debate."
+	}
+	return ""
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Markdown patterns removed by StripMarkdown
+var (
+	mdFence      = regexp.MustCompile("^\\s*(```|~~~)")
+	mdHeading    = regexp.MustCompile(`^\s{0,3}#{1,6}\s+`)
+	mdQuote      = regexp.MustCompile(`^\s*>\s?`)
+	mdRule       = regexp.MustCompile(`^\s*([-*_]\s*){3,}$`)
+	mdBullet     = regexp.MustCompile(`^\s*[-*+]\s+`)
+	mdImage      = regexp.MustCompile(`!\[([^\]]*)\]\(\s*([^)\s]+)[^)]*\)`)
+	mdLink       = regexp.MustCompile(`\[([^\]]+)\]\(\s*([^)\s]+)[^)]*\)`)
+	mdCode       = regexp.MustCompile("`+([^`]+)`+")
+	mdBold       = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	mdItalic     = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+	mdUnderscore = regexp.MustCompile(`(^|[^\pL\pN_])_([^_\s][^_]*)_([^\pL\pN_]|$)`)
+	mdStrike     = regexp.MustCompile(`~~([^~]+)~~`)
+	blankLines   = regexp.MustCompile(`\n{3,}`)
+)
+
+// StripMarkdown reduces Markdown to plain text: fences, headings, quotes,
+// rules and emphasis markers are dropped, code keeps its text, and links and
+// images become "title (url)"
+func StripMarkdown(s string) string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	out := make([]string, 0, len(lines))
+	inFence := false
+
+	for _, line := range lines {
+		if mdFence.MatchString(line) {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			out = append(out, line)
+			continue
+		}
+		if mdRule.MatchString(line) {
+			out = append(out, "")
+			continue
+		}
+
+		line = mdHeading.ReplaceAllString(line, "")
+		line = mdQuote.ReplaceAllString(line, "")
+		line = mdBullet.ReplaceAllString(line, "")
+		line = mdImage.ReplaceAllStringFunc(line, flattenLink(mdImage))
+		line = mdLink.ReplaceAllStringFunc(line, flattenLink(mdLink))
+		line = mdCode.ReplaceAllString(line, "$1")
+		line = mdBold.ReplaceAllString(line, "$1$2")
+		line = mdItalic.ReplaceAllString(line, "$1")
+		line = mdUnderscore.ReplaceAllString(line, "$1$2$3")
+		line = mdStrike.ReplaceAllString(line, "$1")
+		out = append(out, strings.TrimRight(line, " \t"))
+	}
+
+	text := blankLines.ReplaceAllString(strings.Join(out, "\n"), "\n\n")
+	return strings.TrimSpace(text)
+}
+
+// flattenLink turns a link or image matched by re into "title (url)", or
+// just the URL when the title is empty or the URL itself
+func flattenLink(re *regexp.Regexp) func(string) string {
+	return func(match string) string {
+		m := re.FindStringSubmatch(match)
+		title, url := strings.TrimSpace(m[1]), m[2]
+		if title == "" || title == url {
+			return url
+		}
+		return fmt.Sprintf("%s (%s)", title, url)
+	}
+}
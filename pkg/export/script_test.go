@@ -0,0 +1,125 @@
+package export
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// scriptFixture is a two-round debate with a moderator, multi-byte names, a
+// failed call, a skipped turn, a rejected reply, an engine note, Markdown and
+// a lightning round
+func scriptFixture() (*models.Discussion, []*models.DiscussionLog, map[int64]string) {
+	moderator := int64(3)
+	discussion := &models.Discussion{
+		ID:          1,
+		Topic:       "Tabs  or\nspaces?",
+		AgentIDs:    models.JSONSlice[int64]{1, 2},
+		ModeratorID: &moderator,
+	}
+	names := map[int64]string{1: "Zoë", 2: "李明", 3: "Sage"}
+	logs := []*models.DiscussionLog{
+		{ID: 1, AgentID: 3, IsModerator: true, Status: "success", LogType: models.LogTypeResponse,
+			Content:  "[Moderator - Opening Remarks]\nWelcome, **Zoë** and 李明.",
+			Metadata: models.JSONMap{"moderator_phase": "opening"}},
+		{ID: 2, AgentID: 1, Status: "success", LogType: models.LogTypeResponse,
+			Content:  "## Spaces\n\nSpaces render the same [everywhere](https://example.com/spaces). Use `gofmt`:\n\n```go\nfunc main() {}\n```\n\n- aligned\n- _portable_",
+			Metadata: models.JSONMap{"round": "1"}},
+		{ID: 3, AgentID: 2, Status: "rejected", LogType: models.LogTypeResponse,
+			Content: "I cannot take part.", Metadata: models.JSONMap{"round": "1"}},
+		{ID: 4, AgentID: 2, Status: "error", LogType: models.LogTypeResponse,
+			Content: "Error: connection refused", Metadata: models.JSONMap{"round": "1"}},
+		{ID: 5, AgentID: 3, IsModerator: true, Status: "success", LogType: models.LogTypeResponse,
+			Content:  "[Moderator - Round Summary]\nOne answer so far.",
+			Metadata: models.JSONMap{"moderator_phase": "round_summary"}},
+		{ID: 6, AgentID: models.SystemAgentID, Status: "success", LogType: models.LogTypeSystem,
+			Content: "Slow call from 李明"},
+		{ID: 7, AgentID: 1, Status: "success", LogType: models.LogTypeResponse,
+			Content: "> Quoting myself\n\nStill ~~tabs~~ spaces.", Metadata: models.JSONMap{"round": "2"}},
+		{ID: 8, AgentID: models.SystemAgentID, Status: "success", LogType: models.LogTypeSkip,
+			Content:  "李明 skipped",
+			Metadata: models.JSONMap{"skipped_agent_id": "2", "skip_reason": "provider paused"}},
+		{ID: 9, AgentID: 1, Status: "success", LogType: models.LogTypeResponse,
+			Content: "Spaces, finally.", Metadata: models.JSONMap{"round": "2", "phase": models.PhaseClosingStatement}},
+		{ID: 10, AgentID: 2, Status: "success", LogType: models.LogTypeResponse,
+			Content: "Tabs, still.", Metadata: models.JSONMap{"round": "2", "phase": models.PhaseClosingStatement}},
+		{ID: 11, AgentID: 3, IsModerator: true, Status: "success", LogType: models.LogTypeResponse,
+			Content:  "[Moderator - Closing Remarks]\n",
+			Metadata: models.JSONMap{"moderator_phase": "closing"}},
+	}
+	return discussion, logs, names
+}
+
+// checkGolden compares got with testdata/name, rewriting it with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("write golden file: %v", err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file:\n--- got ---\n%s\n--- want ---\n%s", name, got, want)
+	}
+}
+
+func TestWriteScript(t *testing.T) {
+	discussion, logs, names := scriptFixture()
+	var buf bytes.Buffer
+	if err := WriteScript(&buf, discussion, logs, names, ScriptOptions{}); err != nil {
+		t.Fatalf("WriteScript: %v", err)
+	}
+	checkGolden(t, "script.golden", buf.Bytes())
+}
+
+func TestWriteScriptWithCastAndAgreement(t *testing.T) {
+	discussion, logs, names := scriptFixture()
+	// An agent without a name falls back to its ID
+	delete(names, 2)
+	agreement := &models.AgreementMatrix{
+		Agents:    []models.AnalysisAgent{{ID: 1, Name: "Zoë"}, {ID: 2, Name: "Agent #2"}},
+		Consensus: 0.5,
+		Rows: []models.AgreementRow{{
+			Point:     "Spaces  render\nthe same everywhere",
+			Positions: []string{models.AnalysisSupport, ""},
+			Agreement: 1,
+		}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteScript(&buf, discussion, logs, names, ScriptOptions{Cast: true, Agreement: agreement}); err != nil {
+		t.Fatalf("WriteScript: %v", err)
+	}
+	checkGolden(t, "script_cast.golden", buf.Bytes())
+}
+
+func TestStripMarkdown(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"**bold** and *italic* and __under__", "bold and italic and under"},
+		{"snake_case_name stays", "snake_case_name stays"},
+		{"see [the docs](https://example.com/docs)", "see the docs (https://example.com/docs)"},
+		{"[https://example.com](https://example.com)", "https://example.com"},
+		{"![a chart](https://example.com/c.png)", "a chart (https://example.com/c.png)"},
+		{"```\n*kept* as is\n```", "*kept* as is"},
+		{"# Title\n\n---\n\n> quoted", "Title\n\nquoted"},
+		{"a\n\n\n\nb", "a\n\nb"},
+	}
+	for _, tt := range tests {
+		if got := StripMarkdown(tt.in); got != tt.want {
+			t.Errorf("StripMarkdown(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
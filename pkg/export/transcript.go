@@ -0,0 +1,111 @@
+// Package export renders a discussion transcript as a document for use
+// outside the app. Every format walks the same sequence of turns, so they
+// agree on what a transcript contains.
+package export
+
+import (
+	"court-table-ai/pkg/models"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Turn kinds
+const (
+	TurnSpeech  = "speech"  // an agent or moderator response
+	TurnFailure = "failure" // a call that errored or timed out
+	TurnSkip    = "skip"    // a participant sat out a turn
+)
+
+// Turn is one transcript entry as seen by an export format
+type Turn struct {
+	Kind        string
+	Round       int // 0 until the first agent turn
+	AgentID     int64
+	Speaker     string
+	IsModerator bool
+	Phase       string // moderator phase, e.g. "opening" or "closing", or "closing_statement" for the lightning round
+	Content     string // the moderator role header is removed
+	Anchor      string // see Anchors
+	Log         *models.DiscussionLog
+}
+
+// Walk calls fn with each turn of logs, which must already be in transcript
+// order, and stops at the first error fn returns. Rejected replies and engine
+// notes other than skips are not part of the debate and are left out. names
+// maps agent IDs to the names to show; agents missing from it appear as
+// "Agent #ID".
+func Walk(logs []*models.DiscussionLog, names map[int64]string, fn func(Turn) error) error {
+	anchors := Anchors(logs)
+	turns := make(map[int64]int)
+	round := 0
+
+	for _, l := range logs {
+		turn := Turn{Kind: TurnSpeech, AgentID: l.AgentID, IsModerator: l.IsModerator, Anchor: anchors[l.ID].ID, Log: l}
+
+		switch {
+		case l.LogType == models.LogTypeSkip:
+			id, _ := strconv.ParseInt(l.Metadata["skipped_agent_id"], 10, 64)
+			turn.Kind = TurnSkip
+			turn.AgentID = id
+			turn.IsModerator = l.Metadata["skipped_role"] == "moderator"
+			turn.Content = l.Metadata["skip_reason"]
+
+		case l.IsSystem() || l.LogType != models.LogTypeResponse || l.Status == "rejected":
+			continue
+
+		case l.IsModerator:
+			turn.Phase = l.Metadata["moderator_phase"]
+			turn.Content = stripModeratorHeader(l.Content)
+
+		default:
+			if r := logRound(l, turns, round); r > round {
+				round = r
+			}
+			turn.Phase = l.Metadata["phase"]
+			turn.Content = l.Content
+		}
+
+		if turn.Kind == TurnSpeech && l.Status != "success" {
+			turn.Kind = TurnFailure
+		}
+		turn.Round = round
+		turn.Speaker = speakerName(names, turn.AgentID)
+
+		if err := fn(turn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logRound returns the round of an agent entry, inferring it from the
+// agent's turn count for entries logged before rounds were recorded
+func logRound(l *models.DiscussionLog, turns map[int64]int, current int) int {
+	if round, err := strconv.Atoi(l.Metadata["round"]); err == nil && round > 0 {
+		return round
+	}
+	if l.Metadata["retry_of"] != "" {
+		return max(current, 1)
+	}
+	turns[l.AgentID]++
+	return turns[l.AgentID]
+}
+
+// stripModeratorHeader removes the "[Moderator - Role]" line the engine
+// puts in front of moderator responses
+func stripModeratorHeader(content string) string {
+	if strings.HasPrefix(content, "[Moderator - ") {
+		if i := strings.IndexByte(content, '\n'); i >= 0 {
+			return content[i+1:]
+		}
+	}
+	return content
+}
+
+func speakerName(names map[int64]string, id int64) string {
+	if name := names[id]; name != "" {
+		return name
+	}
+	return fmt.Sprintf("Agent #%d", id)
+}
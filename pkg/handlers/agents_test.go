@@ -0,0 +1,381 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/orchestrator"
+)
+
+func TestAgentTimeoutCeiling(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	admin := NewAdminHandler(db, engine)
+	agents := NewAgentHandler(db, engine)
+
+	created := 0
+	create := func(timeout string) (int, models.Agent) {
+		t.Helper()
+		created++
+		body := `{"name": "Alice ` + strconv.Itoa(created) + `", "provider_type": "openai", "provider_url": "http://127.0.0.1:1/v1", "api_token": "sk-test", "model_name": "test-model", "timeout_seconds": ` + timeout + `}`
+		rec := call(agents.CreateAgent, jsonRequest(http.MethodPost, "/api/agents", body), nil)
+		var agent models.Agent
+		json.Unmarshal(rec.Body.Bytes(), &agent)
+		return rec.Code, agent
+	}
+
+	if code, agent := create("0"); code != http.StatusCreated || agent.TimeoutSeconds != models.DefaultAgentTimeoutSeconds {
+		t.Errorf("create without a timeout = %d with %ds, want the %ds default", code, agent.TimeoutSeconds, models.DefaultAgentTimeoutSeconds)
+	}
+	if code, _ := create("150"); code != http.StatusCreated {
+		t.Errorf("create with 150s under the default ceiling = %d", code)
+	}
+
+	rec := call(admin.UpdateAgentTimeouts, jsonRequest(http.MethodPut, "/api/admin/timeouts", `{"default_agent_timeout_seconds": 20, "max_agent_timeout_seconds": 60, "agent_timeout_buffer_seconds": 5}`), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("UpdateAgentTimeouts = %d %s", rec.Code, rec.Body)
+	}
+
+	// The new settings apply at once, without a restart
+	if code, _ := create("90"); code != http.StatusBadRequest {
+		t.Errorf("create above the new ceiling = %d, want 400", code)
+	}
+	code, agent := create("60")
+	if code != http.StatusCreated {
+		t.Fatalf("create at the ceiling = %d", code)
+	}
+	if code, agent := create(`"0"`); code != http.StatusCreated || agent.TimeoutSeconds != 20 {
+		t.Errorf("create without a timeout = %d with %ds, want the new 20s default", code, agent.TimeoutSeconds)
+	}
+
+	update := `{"name": "Alice", "provider_type": "openai", "provider_url": "http://127.0.0.1:1/v1", "model_name": "test-model", "timeout_seconds": 61}`
+	rec = call(agents.UpdateAgent, jsonRequest(http.MethodPut, "/", update), map[string]string{"id": strconv.FormatInt(agent.ID, 10)})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("update above the ceiling = %d %s, want 400", rec.Code, rec.Body)
+	}
+
+	for _, body := range []string{
+		`{"default_agent_timeout_seconds": 90, "max_agent_timeout_seconds": 60}`,
+		`{"agent_timeout_buffer_seconds": -1}`,
+	} {
+		if rec := call(admin.UpdateAgentTimeouts, jsonRequest(http.MethodPut, "/api/admin/timeouts", body), nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("UpdateAgentTimeouts(%s) = %d, want 400", body, rec.Code)
+		}
+	}
+}
+
+func TestTokenStatsDays(t *testing.T) {
+	db := newTestDB(t)
+	agents := NewAgentHandler(db, orchestrator.NewDebateEngine(db))
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", http.StatusOK},
+		{"?days=1", http.StatusOK},
+		{"?days=90", http.StatusOK},
+		{"?days=0", http.StatusBadRequest},
+		{"?days=91", http.StatusBadRequest},
+		{"?days=week", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := call(agents.GetTokenStats, httptest.NewRequest(http.MethodGet, "/api/stats/tokens"+tt.query, nil), nil)
+		if rec.Code != tt.want {
+			t.Errorf("GET /api/stats/tokens%s = %d, want %d", tt.query, rec.Code, tt.want)
+		}
+		if tt.want == http.StatusOK && strings.TrimSpace(rec.Body.String()) != "[]" {
+			t.Errorf("GET /api/stats/tokens%s on an empty database = %s, want []", tt.query, rec.Body)
+		}
+	}
+}
+
+func TestProbeProvider(t *testing.T) {
+	db := newTestDB(t)
+	agents := NewAgentHandler(db, orchestrator.NewDebateEngine(db))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"models":[{"name":"llama3:8b"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	rec := call(agents.ProbeProvider, jsonRequest(http.MethodPost, "/api/agents/probe", `{"provider_url": "`+server.URL+`/api"}`), nil)
+	var result models.ProbeResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("ProbeProvider = %d %s", rec.Code, rec.Body)
+	}
+	want := models.ProbeSuggestion{ProviderType: models.ProviderOllama, ProviderURL: server.URL, ModelName: "llama3:8b", TimeoutSeconds: models.DefaultAgentTimeoutSeconds}
+	if !result.Detected || result.Suggested != want {
+		t.Errorf("suggested %+v, want %+v", result.Suggested, want)
+	}
+
+	for _, body := range []string{
+		`{"provider_url": ""}`,
+		`{"provider_url": "ftp://llm.example.com"}`,
+		`{"provider_url": "localhost:11434"}`,
+		`{"provider_url": "https://llm.example.com", "api_token": "` + strings.Repeat("k", models.MaxAPITokenLength+1) + `"}`,
+		`not json`,
+	} {
+		if rec := call(agents.ProbeProvider, jsonRequest(http.MethodPost, "/api/agents/probe", body), nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("ProbeProvider(%.60s) = %d, want 400", body, rec.Code)
+		}
+	}
+}
+
+func TestAgentExtraHeaders(t *testing.T) {
+	db := newTestDB(t)
+	agents := NewAgentHandler(db, orchestrator.NewDebateEngine(db))
+
+	body := `{"name": "Router", "provider_type": "openai", "provider_url": "https://openrouter.ai/api/v1", "api_token": "sk-test", "model_name": "gpt-4o",
+		"extra_headers": {"HTTP-Referer": "https://court.example.com", "cf-aig-authorization": "Bearer cf-secret"}}`
+	rec := call(agents.CreateAgent, jsonRequest(http.MethodPost, "/api/agents", body), nil)
+	if rec.Code != http.StatusCreated || strings.Contains(rec.Body.String(), "cf-secret") {
+		t.Fatalf("create = %d %s, want 201 without the secret", rec.Code, rec.Body)
+	}
+	var created models.Agent
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	id := strconv.FormatInt(created.ID, 10)
+
+	for name, rec := range map[string]*httptest.ResponseRecorder{
+		"list": call(agents.GetAgents, httptest.NewRequest(http.MethodGet, "/api/agents", nil), nil),
+		"get":  call(agents.GetAgent, httptest.NewRequest(http.MethodGet, "/", nil), map[string]string{"id": id}),
+	} {
+		if strings.Contains(rec.Body.String(), "cf-secret") || !strings.Contains(rec.Body.String(), `"cf-aig-authorization":"********"`) {
+			t.Errorf("%s = %s, want the secret header masked", name, rec.Body)
+		}
+	}
+	stored, err := db.GetAgent(created.ID)
+	if err != nil || stored.ExtraHeaders["cf-aig-authorization"] != "Bearer cf-secret" {
+		t.Fatalf("stored headers = %v, %v, want the real value", stored.ExtraHeaders, err)
+	}
+	if created.ExtraHeaders["HTTP-Referer"] != "https://court.example.com" || created.ExtraHeaders["cf-aig-authorization"] != models.MaskedHeaderValue {
+		t.Errorf("created headers = %v, want the referer as is and the secret masked", created.ExtraHeaders)
+	}
+
+	// Sending the masked value back keeps the stored secret
+	update := `{"name": "Router", "provider_type": "openai", "provider_url": "https://openrouter.ai/api/v1", "model_name": "gpt-4o",
+		"extra_headers": {"HTTP-Referer": "https://court.example.com", "X-Title": "Court Table", "cf-aig-authorization": "********"}}`
+	rec = call(agents.UpdateAgent, jsonRequest(http.MethodPut, "/", update), map[string]string{"id": id})
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "cf-secret") {
+		t.Fatalf("update = %d %s", rec.Code, rec.Body)
+	}
+	stored, _ = db.GetAgent(created.ID)
+	if stored.ExtraHeaders["cf-aig-authorization"] != "Bearer cf-secret" || stored.ExtraHeaders["X-Title"] != "Court Table" {
+		t.Errorf("headers after update = %v, want the secret kept and the title added", stored.ExtraHeaders)
+	}
+
+	invalid := `{"name": "Plain", "provider_type": "openai", "provider_url": "https://openrouter.ai/api/v1", "model_name": "gpt-4o", "extra_headers": {"Content-Type": "text/plain"}}`
+	rec = call(agents.CreateAgent, jsonRequest(http.MethodPost, "/api/agents", invalid), nil)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "Content-Type cannot be set") {
+		t.Errorf("create with Content-Type = %d %s, want 400", rec.Code, rec.Body)
+	}
+}
+
+func TestTestAgentEndpoint(t *testing.T) {
+	db := newTestDB(t)
+	h := NewAgentHandler(db, orchestrator.NewDebateEngine(db))
+	var prompts []string
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		for _, m := range body.Messages {
+			if m.Role == "user" {
+				prompts = append(prompts, m.Content)
+			}
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Hello there."},"finish_reason":"stop"}],"usage":{"prompt_tokens":7,"completion_tokens":2}}`))
+	}))
+	defer provider.Close()
+	agent := insertProviderAgent(t, db, "Alice", provider.URL)
+	params := map[string]string{"id": strconv.FormatInt(agent.ID, 10)}
+
+	rec := call(h.TestAgent, jsonRequest(http.MethodPost, "/", `{}`), params)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("TestAgent = %d: %s", rec.Code, rec.Body.String())
+	}
+	var result models.AgentTestResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !result.Success || result.Content != "Hello there." || result.Prompt != models.DefaultAgentTestPrompt || result.TotalTokens != 9 {
+		t.Errorf("result = %+v", result)
+	}
+	if len(prompts) != 1 || !strings.Contains(prompts[0], models.DefaultAgentTestPrompt) {
+		t.Errorf("prompts sent = %q, want the default prompt", prompts)
+	}
+
+	rec = call(h.TestAgent, jsonRequest(http.MethodPost, "/", `{"prompt":"  Name a colour.  "}`), params)
+	json.Unmarshal(rec.Body.Bytes(), &result)
+	if rec.Code != http.StatusOK || result.Prompt != "Name a colour." {
+		t.Errorf("TestAgent with a prompt = %d %+v", rec.Code, result)
+	}
+
+	long := `{"prompt":"` + strings.Repeat("a", models.MaxAgentTestPromptLength+1) + `"}`
+	for name, tc := range map[string]struct {
+		body   string
+		params map[string]string
+		want   int
+	}{
+		"long prompt":   {long, params, http.StatusBadRequest},
+		"invalid body":  {`{"prompt":`, params, http.StatusBadRequest},
+		"invalid ID":    {`{}`, map[string]string{"id": "x"}, http.StatusBadRequest},
+		"missing agent": {`{}`, map[string]string{"id": "9999"}, http.StatusNotFound},
+	} {
+		if rec := call(h.TestAgent, jsonRequest(http.MethodPost, "/", tc.body), tc.params); rec.Code != tc.want {
+			t.Errorf("%s: TestAgent = %d, want %d", name, rec.Code, tc.want)
+		}
+	}
+	if len(prompts) != 2 {
+		t.Errorf("provider got %d calls, want 2", len(prompts))
+	}
+}
+
+func TestDuplicateAgent(t *testing.T) {
+	db := newTestDB(t)
+	h := NewAgentHandler(db, orchestrator.NewDebateEngine(db))
+	alice := insertProviderAgent(t, db, "Alice", "http://127.0.0.1:1")
+	alice.ExtraHeaders = models.JSONMap{"X-Api-Key": "secret"}
+	alice.SystemPrompt = "You are terse."
+	if err := db.UpdateAgent(alice); err != nil {
+		t.Fatalf("UpdateAgent: %v", err)
+	}
+
+	duplicate := func(id int64) (*httptest.ResponseRecorder, models.Agent) {
+		rec := call(h.DuplicateAgent, httptest.NewRequest(http.MethodPost, "/", nil), map[string]string{"id": strconv.FormatInt(id, 10)})
+		var agent models.Agent
+		json.Unmarshal(rec.Body.Bytes(), &agent)
+		return rec, agent
+	}
+
+	// Each copy takes the next free number instead of failing on the name
+	var copies []models.Agent
+	for _, want := range []string{"Alice - Copy", "Alice - Copy 2", "Alice - Copy 3"} {
+		rec, agent := duplicate(alice.ID)
+		if rec.Code != http.StatusCreated || agent.Name != want {
+			t.Fatalf("DuplicateAgent = %d %q, want 201 %q: %s", rec.Code, agent.Name, want, rec.Body)
+		}
+		copies = append(copies, agent)
+	}
+	if rec, agent := duplicate(copies[0].ID); rec.Code != http.StatusCreated || agent.Name != "Alice - Copy - Copy" {
+		t.Errorf("duplicating a copy = %d %q", rec.Code, agent.Name)
+	}
+
+	// The copy keeps the configuration and secrets, masked in the response
+	if copies[0].ExtraHeaders["X-Api-Key"] == "secret" {
+		t.Error("the duplicate's extra headers are not masked")
+	}
+	stored, err := db.GetAgent(copies[0].ID)
+	if err != nil {
+		t.Fatalf("GetAgent: %v", err)
+	}
+	if stored.APIToken != alice.APIToken || stored.ExtraHeaders["X-Api-Key"] != "secret" || stored.SystemPrompt != "You are terse." || stored.ModelName != alice.ModelName {
+		t.Errorf("stored copy = %+v, want Alice's configuration", stored)
+	}
+
+	if rec, _ := duplicate(9999); rec.Code != http.StatusNotFound {
+		t.Errorf("duplicating a missing agent = %d, want 404", rec.Code)
+	}
+}
+
+func TestCopyName(t *testing.T) {
+	long := strings.Repeat("é", models.MaxAgentNameLength)
+	for _, tt := range []struct {
+		name   string
+		n      int
+		suffix string
+	}{
+		{"Alice", 1, "Alice - Copy"},
+		{"Alice", 12, "Alice - Copy 12"},
+		{long, 1, " - Copy"},
+		{long, 100, " - Copy 100"},
+	} {
+		got := copyName(tt.name, tt.n)
+		if !strings.HasSuffix(got, tt.suffix) || len([]rune(got)) > models.MaxAgentNameLength {
+			t.Errorf("copyName(%d runes, %d) = %q, want it to end in %q within %d characters", len([]rune(tt.name)), tt.n, got, tt.suffix, models.MaxAgentNameLength)
+		}
+	}
+}
+
+func TestDeleteAgentInUse(t *testing.T) {
+	db := newTestDB(t)
+	h := NewAgentHandler(db, orchestrator.NewDebateEngine(db))
+	alice := insertProviderAgent(t, db, "Alice", "http://127.0.0.1:1")
+	bob := insertProviderAgent(t, db, "Bob", "http://127.0.0.1:1")
+	running := &models.Discussion{Topic: "Tabs or spaces", Status: "running", MaxRounds: 2, AgentIDs: models.JSONSlice[int64]{alice.ID, bob.ID}}
+	moderated := &models.Discussion{Topic: "Vim or Emacs", Status: "paused", MaxRounds: 2, AgentIDs: models.JSONSlice[int64]{bob.ID}, ModeratorID: &alice.ID}
+	completed := &models.Discussion{Topic: "Light or dark", Status: "completed", MaxRounds: 2, AgentIDs: models.JSONSlice[int64]{alice.ID}}
+	for _, d := range []*models.Discussion{running, moderated, completed} {
+		if err := db.InsertDiscussion(d); err != nil {
+			t.Fatalf("InsertDiscussion: %v", err)
+		}
+	}
+	deleteAgent := func(id int64, query string) *httptest.ResponseRecorder {
+		return call(h.DeleteAgent, httptest.NewRequest(http.MethodDelete, "/"+query, nil), map[string]string{"id": strconv.FormatInt(id, 10)})
+	}
+
+	rec := deleteAgent(alice.ID, "")
+	var conflict struct {
+		Error         string  `json:"error"`
+		DiscussionIDs []int64 `json:"discussion_ids"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &conflict)
+	if rec.Code != http.StatusConflict || len(conflict.DiscussionIDs) != 2 || conflict.DiscussionIDs[0] != running.ID || conflict.DiscussionIDs[1] != moderated.ID {
+		t.Fatalf("DeleteAgent in use = %d %+v, want 409 naming both live discussions", rec.Code, conflict)
+	}
+	if _, err := db.GetAgent(alice.ID); err != nil {
+		t.Fatalf("agent gone after a refused delete: %v", err)
+	}
+
+	if rec := deleteAgent(alice.ID, "?force=true"); rec.Code != http.StatusNoContent {
+		t.Fatalf("DeleteAgent with force = %d: %s", rec.Code, rec.Body)
+	}
+	if _, err := db.GetAgent(alice.ID); err == nil {
+		t.Error("agent still found after a forced delete")
+	}
+	// Each live discussion explains the missing agent; finished ones are left alone
+	for _, d := range []*models.Discussion{running, moderated, completed} {
+		logs, err := db.GetDiscussionLogs(d.ID)
+		if err != nil {
+			t.Fatalf("GetDiscussionLogs: %v", err)
+		}
+		var notes int
+		for _, l := range logs {
+			if l.LogType == models.LogTypeSystem && l.Metadata["alert"] == "agent_deleted" && l.Metadata["deleted_agent_id"] == strconv.FormatInt(alice.ID, 10) && strings.Contains(l.Content, "Alice") {
+				notes++
+			}
+		}
+		want := 1
+		if d == completed {
+			want = 0
+		}
+		if notes != want {
+			t.Errorf("discussion %q has %d delete notes, want %d", d.Topic, notes, want)
+		}
+	}
+
+	if rec := deleteAgent(bob.ID, ""); rec.Code != http.StatusConflict {
+		t.Errorf("DeleteAgent(bob) = %d, want 409 while the debates run", rec.Code)
+	}
+	// An agent no debate uses is deleted without force
+	carol := insertProviderAgent(t, db, "Carol", "http://127.0.0.1:1")
+	if rec := deleteAgent(carol.ID, ""); rec.Code != http.StatusNoContent {
+		t.Errorf("DeleteAgent(carol) = %d, want 204", rec.Code)
+	}
+	if rec := deleteAgent(carol.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("deleting Carol again = %d, want 404", rec.Code)
+	}
+}
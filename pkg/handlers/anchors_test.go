@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"court-table-ai/pkg/export"
+	"court-table-ai/pkg/jobs"
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/orchestrator"
+)
+
+// discussionAnchors returns the anchors of a discussion's entries by log ID
+// and its table of contents, as the detail API reports them
+func discussionAnchors(t *testing.T, h *DiscussionHandler, id int64) (map[int64]string, []export.ContentsEntry) {
+	t.Helper()
+	rec := call(h.GetDiscussion, httptest.NewRequest(http.MethodGet, "/", nil), map[string]string{"id": strconv.FormatInt(id, 10)})
+	var body struct {
+		Logs     []models.DiscussionLog `json:"logs"`
+		Contents []export.ContentsEntry `json:"contents"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("GetDiscussion = %d %s", rec.Code, rec.Body)
+	}
+	anchors := make(map[int64]string, len(body.Logs))
+	for _, l := range body.Logs {
+		if l.Anchor == "" {
+			t.Errorf("log %d has no anchor", l.ID)
+		}
+		anchors[l.ID] = l.Anchor
+	}
+	return anchors, body.Contents
+}
+
+func TestAnchorsSurviveRetries(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	h := NewDiscussionHandler(db, engine, jobs.NewManager())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Tabs, on reflection."},"finish_reason":"stop"}]}`))
+	}))
+	t.Cleanup(server.Close)
+	alice := insertProviderAgent(t, db, "Alice", server.URL)
+	bob := insertProviderAgent(t, db, "Bob", server.URL)
+	sage := insertProviderAgent(t, db, "Sage", server.URL)
+	discussion := &models.Discussion{Topic: "Tabs or spaces", Status: "completed", MaxRounds: 2, AgentIDs: models.JSONSlice[int64]{alice.ID, bob.ID}, ModeratorID: &sage.ID}
+	if err := db.InsertDiscussion(discussion); err != nil {
+		t.Fatalf("InsertDiscussion: %v", err)
+	}
+
+	moderator := func(phase string) *models.DiscussionLog {
+		return &models.DiscussionLog{DiscussionID: discussion.ID, AgentID: sage.ID, IsModerator: true, Content: "[Moderator - " + phase + "]\nNoted.", Status: "success",
+			Metadata: models.JSONMap{"moderator_phase": phase}}
+	}
+	turn := func(agent *models.Agent, round, status string) *models.DiscussionLog {
+		return &models.DiscussionLog{DiscussionID: discussion.ID, AgentID: agent.ID, Content: "Spaces.", Status: status, Metadata: models.JSONMap{"round": round}}
+	}
+	logs := []*models.DiscussionLog{
+		moderator("opening"),
+		turn(alice, "1", "success"), turn(bob, "1", "success"), moderator("round_summary"),
+		turn(alice, "2", "success"), turn(bob, "2", "error"), moderator("round_summary"),
+		moderator("closing"),
+	}
+	for _, l := range logs {
+		if err := db.InsertDiscussionLog(l); err != nil {
+			t.Fatalf("InsertDiscussionLog: %v", err)
+		}
+	}
+	failed := logs[5]
+
+	before, contents := discussionAnchors(t, h, discussion.ID)
+	if len(contents) != 3 || contents[2].Round != 2 || contents[2].Entries != 4 {
+		t.Errorf("contents = %+v, want rounds 0 to 2 with four entries in the last", contents)
+	}
+
+	retried, err := engine.RetryLogEntry(context.Background(), discussion.ID, failed.ID)
+	if err != nil || retried.Status != "success" {
+		t.Fatalf("RetryLogEntry = %+v, %v", retried, err)
+	}
+	after, contents := discussionAnchors(t, h, discussion.ID)
+	for id, anchor := range before {
+		if after[id] != anchor {
+			t.Errorf("anchor of log %d changed from %s to %s after a retry", id, anchor, after[id])
+		}
+	}
+	if want := before[failed.ID] + "-retry1"; after[retried.ID] != want {
+		t.Errorf("retry anchored at %s, want %s", after[retried.ID], want)
+	}
+	if len(contents) != 3 || contents[2].Entries != 5 {
+		t.Errorf("contents = %+v, want the retry counted in round 2", contents)
+	}
+
+	getRound := func(n string) *httptest.ResponseRecorder {
+		return call(h.GetRound, httptest.NewRequest(http.MethodGet, "/", nil), map[string]string{"id": strconv.FormatInt(discussion.ID, 10), "n": n})
+	}
+	rec := getRound("2")
+	var round struct {
+		Round   int                     `json:"round"`
+		Logs    []*models.DiscussionLog `json:"logs"`
+		Summary *models.DiscussionLog   `json:"summary"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &round); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("GetRound(2) = %d %s", rec.Code, rec.Body)
+	}
+	// The round's turns, its summary and the retry, without the closing remarks
+	wantIDs := []int64{logs[4].ID, failed.ID, logs[6].ID, retried.ID}
+	if len(round.Logs) != len(wantIDs) {
+		t.Fatalf("round 2 has %d entries, want %d: %+v", len(round.Logs), len(wantIDs), round.Logs)
+	}
+	for i, l := range round.Logs {
+		if l.ID != wantIDs[i] || l.Anchor != after[l.ID] {
+			t.Errorf("entry %d = log %d anchored %s, want log %d anchored %s", i, l.ID, l.Anchor, wantIDs[i], after[wantIDs[i]])
+		}
+	}
+	if round.Round != 2 || round.Summary == nil || round.Summary.ID != logs[6].ID {
+		t.Errorf("round %d summary = %+v, want log %d", round.Round, round.Summary, logs[6].ID)
+	}
+
+	for n, want := range map[string]int{"1": http.StatusOK, "3": http.StatusNotFound, "0": http.StatusBadRequest, "two": http.StatusBadRequest} {
+		if rec := getRound(n); rec.Code != want {
+			t.Errorf("GetRound(%s) = %d, want %d", n, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"court-table-ai/pkg/jobs"
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/orchestrator"
+)
+
+func TestDiscussionAnnotations(t *testing.T) {
+	db := newTestDB(t)
+	h := NewDiscussionHandler(db, orchestrator.NewDebateEngine(db), jobs.NewManager())
+	discussion := insertTestDiscussion(t, db, "completed")
+	other := insertTestDiscussion(t, db, "completed")
+	// Eight runes, twenty-four bytes
+	entry := &models.DiscussionLog{DiscussionID: discussion.ID, Content: "日本語のテキスト", Status: "success", LogType: models.LogTypeResponse}
+	foreign := &models.DiscussionLog{DiscussionID: other.ID, Content: "Elsewhere", Status: "success", LogType: models.LogTypeResponse}
+	for _, l := range []*models.DiscussionLog{entry, foreign} {
+		if err := db.InsertDiscussionLog(l); err != nil {
+			t.Fatalf("InsertDiscussionLog: %v", err)
+		}
+	}
+	params := map[string]string{"id": strconv.FormatInt(discussion.ID, 10)}
+
+	create := func(body string) *httptest.ResponseRecorder {
+		return call(h.CreateAnnotation, jsonRequest(http.MethodPost, "/", body), params)
+	}
+	for _, tt := range []struct {
+		name string
+		body string
+		want string
+	}{
+		{"byte offsets", fmt.Sprintf(`{"log_id": %d, "start_offset": 3, "end_offset": 24}`, entry.ID), "end_offset <= 8"},
+		{"empty span", fmt.Sprintf(`{"log_id": %d, "start_offset": 2, "end_offset": 2}`, entry.ID), "start_offset < end_offset"},
+		{"negative start", fmt.Sprintf(`{"log_id": %d, "start_offset": -1, "end_offset": 2}`, entry.ID), "0 <= start_offset"},
+		{"log of another discussion", fmt.Sprintf(`{"log_id": %d, "start_offset": 0, "end_offset": 2}`, foreign.ID), "does not belong"},
+	} {
+		rec := create(tt.body)
+		var body map[string]string
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(body["error"], tt.want) {
+			t.Errorf("%s: CreateAnnotation = %d %s, want 400 mentioning %q", tt.name, rec.Code, rec.Body, tt.want)
+		}
+	}
+
+	rec := create(fmt.Sprintf(`{"log_id": %d, "start_offset": 4, "end_offset": 8, "comment": "the text", "author": "Rina"}`, entry.ID))
+	var annotation models.DiscussionAnnotation
+	if err := json.Unmarshal(rec.Body.Bytes(), &annotation); rec.Code != http.StatusCreated || err != nil {
+		t.Fatalf("CreateAnnotation = %d %s", rec.Code, rec.Body)
+	}
+	if got := string([]rune(entry.Content)[annotation.StartOffset:annotation.EndOffset]); got != "テキスト" {
+		t.Errorf("annotated span = %q, want テキスト", got)
+	}
+
+	annotationParams := map[string]string{"id": params["id"], "annotationId": strconv.FormatInt(annotation.ID, 10)}
+	if rec := call(h.UpdateAnnotation, jsonRequest(http.MethodPut, "/", `{"start_offset": 0, "end_offset": 9}`), annotationParams); rec.Code != http.StatusBadRequest {
+		t.Errorf("moving the span past the end = %d, want 400", rec.Code)
+	}
+	if rec := call(h.UpdateAnnotation, jsonRequest(http.MethodPut, "/", `{"start_offset": 0, "end_offset": 3, "comment": "the language"}`), annotationParams); rec.Code != http.StatusOK {
+		t.Errorf("UpdateAnnotation = %d %s", rec.Code, rec.Body)
+	}
+	wrongDiscussion := map[string]string{"id": strconv.FormatInt(other.ID, 10), "annotationId": annotationParams["annotationId"]}
+	if rec := call(h.DeleteAnnotation, httptest.NewRequest(http.MethodDelete, "/", nil), wrongDiscussion); rec.Code != http.StatusNotFound {
+		t.Errorf("deleting through another discussion = %d, want 404", rec.Code)
+	}
+
+	detail := call(h.GetDiscussion, httptest.NewRequest(http.MethodGet, "/?include_annotations=true", nil), params).Body.String()
+	if !strings.Contains(detail, `"comment":"the language"`) {
+		t.Errorf("detail with include_annotations lacks the annotation: %s", detail)
+	}
+	if detail := call(h.GetDiscussion, httptest.NewRequest(http.MethodGet, "/", nil), params).Body.String(); strings.Contains(detail, "the language") {
+		t.Error("detail without include_annotations has the annotation")
+	}
+
+	if rec := call(h.DeleteAnnotation, httptest.NewRequest(http.MethodDelete, "/", nil), annotationParams); rec.Code != http.StatusNoContent {
+		t.Errorf("DeleteAnnotation = %d", rec.Code)
+	}
+	rec = call(h.GetAnnotations, httptest.NewRequest(http.MethodGet, "/", nil), params)
+	if strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("annotations after delete = %s", rec.Body)
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"court-table-ai/pkg/jobs"
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/orchestrator"
+)
+
+func TestGetLogTrace(t *testing.T) {
+	db := newTestDB(t)
+	h := NewDiscussionHandler(db, orchestrator.NewDebateEngine(db), jobs.NewManager())
+	discussion := insertTestDiscussion(t, db, "completed")
+	other := insertTestDiscussion(t, db, "completed")
+
+	recorded := models.CallTrace{Requests: []models.CallTraceRequest{
+		{Attempt: 1, Method: http.MethodPost, Endpoint: "https://llm.example.com/v1/chat/completions", Status: http.StatusNotFound},
+		{Attempt: 1, Method: http.MethodPost, Endpoint: "https://llm.example.com/chat/completions", Status: http.StatusOK},
+	}}
+	encoded, err := json.Marshal(recorded)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	traced := &models.DiscussionLog{DiscussionID: discussion.ID, Content: "Spaces.", Status: "success", LogType: models.LogTypeResponse,
+		Metadata: models.JSONMap{"call_trace": string(encoded)}}
+	untraced := &models.DiscussionLog{DiscussionID: discussion.ID, Content: "Tabs.", Status: "success", LogType: models.LogTypeResponse}
+	for _, l := range []*models.DiscussionLog{traced, untraced} {
+		if err := db.InsertDiscussionLog(l); err != nil {
+			t.Fatalf("InsertDiscussionLog: %v", err)
+		}
+	}
+
+	get := func(discussionID, logID string) *httptest.ResponseRecorder {
+		return call(h.GetLogTrace, httptest.NewRequest(http.MethodGet, "/", nil), map[string]string{"id": discussionID, "logId": logID})
+	}
+	id := strconv.FormatInt(discussion.ID, 10)
+	rec := get(id, strconv.FormatInt(traced.ID, 10))
+	var body struct {
+		LogID int64            `json:"log_id"`
+		Trace models.CallTrace `json:"trace"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("GetLogTrace = %d %s", rec.Code, rec.Body)
+	}
+	if body.LogID != traced.ID || len(body.Trace.Requests) != 2 || body.Trace.Requests[0].Status != http.StatusNotFound || body.Trace.Requests[1].Status != http.StatusOK {
+		t.Errorf("GetLogTrace = %+v, want the recorded requests in order", body)
+	}
+
+	for _, tt := range []struct {
+		name         string
+		discussionID string
+		logID        string
+		want         int
+	}{
+		{"no trace", id, strconv.FormatInt(untraced.ID, 10), http.StatusNotFound},
+		{"log of another discussion", strconv.FormatInt(other.ID, 10), strconv.FormatInt(traced.ID, 10), http.StatusNotFound},
+		{"unknown log", id, "999", http.StatusNotFound},
+		{"bad log ID", id, "abc", http.StatusBadRequest},
+		{"bad discussion ID", "abc", strconv.FormatInt(traced.ID, 10), http.StatusBadRequest},
+	} {
+		if rec := get(tt.discussionID, tt.logID); rec.Code != tt.want {
+			t.Errorf("%s: GetLogTrace = %d %s, want %d", tt.name, rec.Code, rec.Body, tt.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"court-table-ai/pkg/database"
+)
+
+// importConfig posts doc to ImportConfig with query and decodes the changes
+func importConfig(t *testing.T, h *ConfigHandler, query, doc string) (int, []ConfigChange) {
+	t.Helper()
+	rec := call(h.ImportConfig, jsonRequest(http.MethodPost, "/api/export/config"+query, doc), nil)
+	var body struct {
+		Changes []ConfigChange `json:"changes"`
+	}
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("import body %q: %v", rec.Body, err)
+		}
+	}
+	return rec.Code, body.Changes
+}
+
+// actions flattens changes to key=action pairs
+func actions(changes []ConfigChange) string {
+	var parts []string
+	for _, c := range changes {
+		parts = append(parts, c.Key+"="+c.Action)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestImportConfig(t *testing.T) {
+	db := newTestDB(t)
+	h := NewConfigHandler(db)
+	if err := db.SetSetting(database.SettingWatchdog, `{"stall_minutes":15,"force_fail":false}`); err != nil {
+		t.Fatalf("SetSetting: %v", err)
+	}
+	if err := db.SetSetting(database.SettingDefaultAgentTimeout, `30`); err != nil {
+		t.Fatalf("SetSetting: %v", err)
+	}
+	doc := `{"settings": {
+		"` + database.SettingWatchdog + `": {"stall_minutes":5,"force_fail":true},
+		"` + database.SettingDefaultAgentTimeout + `": 30,
+		"` + database.SettingProviderPauses + `": {"pause_all":false,"provider_types":["openai"],"hosts":[]}
+	}}`
+	stored := func(key string) string {
+		t.Helper()
+		value, _, err := db.GetSetting(key)
+		if err != nil {
+			t.Fatalf("GetSetting(%s): %v", key, err)
+		}
+		return value
+	}
+
+	code, changes := importConfig(t, h, "?dry_run=true&overwrite=true", doc)
+	want := database.SettingDefaultAgentTimeout + "=unchanged " + database.SettingProviderPauses + "=create " + database.SettingWatchdog + "=update"
+	if code != http.StatusOK || actions(changes) != want {
+		t.Errorf("dry run = %d %s, want %s", code, actions(changes), want)
+	}
+	if stored(database.SettingProviderPauses) != "" || !strings.Contains(stored(database.SettingWatchdog), `"stall_minutes":15`) {
+		t.Error("dry run changed the stored settings")
+	}
+
+	code, changes = importConfig(t, h, "", doc)
+	want = database.SettingDefaultAgentTimeout + "=unchanged " + database.SettingProviderPauses + "=create " + database.SettingWatchdog + "=skip"
+	if code != http.StatusOK || actions(changes) != want {
+		t.Errorf("import without overwrite = %d %s, want %s", code, actions(changes), want)
+	}
+	if stored(database.SettingProviderPauses) == "" || !strings.Contains(stored(database.SettingWatchdog), `"stall_minutes":15`) {
+		t.Error("import without overwrite did not create the new setting or replaced the existing one")
+	}
+
+	code, changes = importConfig(t, h, "?overwrite=true", doc)
+	if code != http.StatusOK || !strings.Contains(actions(changes), database.SettingWatchdog+"=update") {
+		t.Errorf("import with overwrite = %d %s", code, actions(changes))
+	}
+	if !strings.Contains(stored(database.SettingWatchdog), `"stall_minutes":5`) {
+		t.Errorf("watchdog = %s, want the imported value", stored(database.SettingWatchdog))
+	}
+}
+
+func TestImportConfigRejectsInvalid(t *testing.T) {
+	db := newTestDB(t)
+	h := NewConfigHandler(db)
+
+	for _, doc := range []string{
+		// One bad value rejects the whole document, including the valid setting
+		`{"settings": {"` + database.SettingDefaultAgentTimeout + `": 45, "` + database.SettingWatchdog + `": {"stall_minutes": -3}}}`,
+		`{"settings": {"` + database.SettingDefaultAgentTimeout + `": 45, "` + database.SettingMaxAgentTimeout + `": "soon"}}`,
+		`{"settings": {"` + database.SettingDefaultAgentTimeout + `": 45, "agents": []}}`,
+	} {
+		if code, _ := importConfig(t, h, "?overwrite=true", doc); code != http.StatusBadRequest {
+			t.Errorf("import %s = %d, want 400", doc, code)
+		}
+	}
+	if value, ok, _ := db.GetSetting(database.SettingDefaultAgentTimeout); ok {
+		t.Errorf("a rejected import stored %s", value)
+	}
+}
+
+func TestExportConfigOnlySettings(t *testing.T) {
+	db := newTestDB(t)
+	h := NewConfigHandler(db)
+	insertProviderAgent(t, db, "Alice", "http://127.0.0.1:1")
+	insertTestDiscussion(t, db, "completed")
+	if err := db.SetSetting(database.SettingDefaultAgentTimeout, `45`); err != nil {
+		t.Fatalf("SetSetting: %v", err)
+	}
+
+	rec := call(h.ExportConfig, httptest.NewRequest(http.MethodGet, "/api/export/config", nil), nil)
+	var export ConfigExport
+	if err := json.Unmarshal(rec.Body.Bytes(), &export); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("ExportConfig = %d %s", rec.Code, rec.Body)
+	}
+	if string(export.Settings[database.SettingDefaultAgentTimeout]) != "45" {
+		t.Errorf("settings = %v", export.Settings)
+	}
+	if body := rec.Body.String(); strings.Contains(body, "Alice") || strings.Contains(body, "Tabs or spaces") || strings.Contains(body, "sk-test") {
+		t.Errorf("config export leaks agents or discussions: %s", body)
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"court-table-ai/pkg/jobs"
+	"court-table-ai/pkg/orchestrator"
+)
+
+func TestCreateDiscussionSuppressesDuplicates(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	discussions := NewDiscussionHandler(db, engine, jobs.NewManager())
+	provider := newGatedProvider(t)
+	provider.releaseAll()
+	a := insertProviderAgent(t, db, "Agent A", provider.URL)
+	b := insertProviderAgent(t, db, "Agent B", provider.URL)
+	t.Cleanup(func() {
+		waitFor(t, "the debates to end", func() bool {
+			counts, err := db.CountDiscussionsByStatus()
+			return err == nil && counts["running"] == 0
+		})
+	})
+
+	create := func(topic, client string, suppress bool) (int, createDiscussionResponse) {
+		t.Helper()
+		body := `{"topic": "` + topic + `", "agent_ids": [` + strconv.FormatInt(a.ID, 10) + `, ` + strconv.FormatInt(b.ID, 10) + `], "max_rounds": 1, "suppress_duplicates": ` + strconv.FormatBool(suppress) + `, "settings": {"summary_backend": "extractive"}}`
+		req := jsonRequest(http.MethodPost, "/api/discussions", body)
+		req.Header.Set("X-Real-IP", client)
+		rec := call(discussions.CreateDiscussion, req, nil)
+		var resp createDiscussionResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Discussion == nil {
+			t.Fatalf("create %q = %d %s", topic, rec.Code, rec.Body)
+		}
+		return rec.Code, resp
+	}
+
+	code, first := create("Tabs or spaces", "10.0.0.1", true)
+	if code != http.StatusCreated || first.DuplicateSuppressed {
+		t.Fatalf("first submission = %d suppressed %v, want 201", code, first.DuplicateSuppressed)
+	}
+
+	code, again := create("tabs OR spaces ", "10.0.0.1", true)
+	if code != http.StatusOK || !again.DuplicateSuppressed || again.ID != first.ID {
+		t.Errorf("resubmission = %d discussion %d suppressed %v, want 200 with discussion %d suppressed", code, again.ID, again.DuplicateSuppressed, first.ID)
+	}
+
+	if code, other := create("Tabs or spaces", "10.0.0.2", true); code != http.StatusCreated || other.ID == first.ID {
+		t.Errorf("submission from another client = %d discussion %d, want 201 with a new discussion", code, other.ID)
+	}
+	if code, plain := create("Tabs or spaces", "10.0.0.1", false); code != http.StatusCreated || plain.ID == first.ID {
+		t.Errorf("submission without suppress_duplicates = %d discussion %d, want 201 with a new discussion", code, plain.ID)
+	}
+}
+
+func TestDuplicateGuardSettings(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	admin := NewAdminHandler(db, engine)
+
+	get := func() string {
+		rec := call(admin.GetDuplicateGuard, httptest.NewRequest(http.MethodGet, "/api/admin/duplicate-guard", nil), nil)
+		return strings.TrimSpace(rec.Body.String())
+	}
+	if got := get(); got != `{"window_seconds":10}` {
+		t.Errorf("default duplicate guard = %s", got)
+	}
+
+	for _, body := range []string{`{"window_seconds": -1}`, `{"window_seconds": 100000}`} {
+		if rec := call(admin.UpdateDuplicateGuard, jsonRequest(http.MethodPut, "/api/admin/duplicate-guard", body), nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("PUT %s = %d, want 400", body, rec.Code)
+		}
+	}
+	if rec := call(admin.UpdateDuplicateGuard, jsonRequest(http.MethodPut, "/api/admin/duplicate-guard", `{"window_seconds": 0}`), nil); rec.Code != http.StatusOK {
+		t.Errorf("PUT window 0 = %d %s, want 200", rec.Code, rec.Body)
+	}
+	if got := get(); got != `{"window_seconds":0}` {
+		t.Errorf("duplicate guard after disabling = %s", got)
+	}
+}
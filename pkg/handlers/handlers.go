@@ -1,16 +1,29 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/export"
+	"court-table-ai/pkg/jobs"
 	"court-table-ai/pkg/models"
 	"court-table-ai/pkg/orchestrator"
+	"court-table-ai/pkg/stats"
+	"court-table-ai/pkg/version"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
 )
 
 type AgentHandler struct {
@@ -22,11 +35,16 @@ type AgentHandler struct {
 type AgentRequest struct {
 	ID            string      `json:"id"`
 	Name          string      `json:"name"`
-	ProviderType  string      `json:"provider_type"`  // for frontend use only
+	ProviderType  string      `json:"provider_type"`  // detected from provider_url when empty
 	ProviderURL   string      `json:"provider_url"`
 	APIToken      string      `json:"api_token"`
 	ModelName     string      `json:"model_name"`
 	TimeoutSeconds interface{} `json:"timeout_seconds"` // can be string or int
+	EndpointStyle  string      `json:"endpoint_style"`
+	APIVersion     string      `json:"api_version"` // Azure OpenAI only
+	ExtraHeaders   models.JSONMap `json:"extra_headers"` // masked values keep the stored ones on update
+	SystemPrompt   string      `json:"system_prompt"`
+	Trace          bool        `json:"trace"`
 }
 
 func NewAgentHandler(db *database.DB, debateEngine *orchestrator.DebateEngine) *AgentHandler {
@@ -36,36 +54,47 @@ func NewAgentHandler(db *database.DB, debateEngine *orchestrator.DebateEngine) *
 	}
 }
 
+// agentTimeout parses timeout_seconds, which may be a string or a number,
+// applying the configured default and rejecting values above the ceiling
+func (h *AgentHandler) agentTimeout(raw interface{}) (int, error) {
+	timeouts, err := h.db.GetAgentTimeouts()
+	if err != nil {
+		fmt.Printf("Failed to read agent timeout settings, using defaults: %v\n", err)
+	}
+
+	timeoutSeconds := 0
+	switch v := raw.(type) {
+	case string:
+		if parsed, err := strconv.Atoi(v); err == nil {
+			timeoutSeconds = parsed
+		}
+	case float64:
+		timeoutSeconds = int(v)
+	case int:
+		timeoutSeconds = v
+	case int64:
+		timeoutSeconds = int(v)
+	}
+
+	if timeoutSeconds == 0 {
+		return timeouts.DefaultSeconds, nil
+	}
+	if timeoutSeconds < 1 || timeoutSeconds > timeouts.MaxSeconds {
+		return 0, fmt.Errorf("timeout_seconds must be between 1 and %d", timeouts.MaxSeconds)
+	}
+	return timeoutSeconds, nil
+}
+
 // CreateAgent handles POST /api/agents
 func (h *AgentHandler) CreateAgent(c echo.Context) error {
 	var req AgentRequest
 	if err := c.Bind(&req); err != nil {
-		fmt.Printf("Bind error: %v\n", err) // Debug log
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid request body: %v", err)})
 	}
 
-	fmt.Printf("Received request: %+v\n", req) // Debug log
-
-	// Validate required fields
-	if req.Name == "" || req.ProviderURL == "" || req.ModelName == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "name, provider_url, and model_name are required"})
-	}
-
-	// Parse timeout_seconds - handle both string and int
-	timeoutSeconds := 30 // default
-	if req.TimeoutSeconds != nil {
-		switch v := req.TimeoutSeconds.(type) {
-		case string:
-			if parsed, err := strconv.Atoi(v); err == nil {
-				timeoutSeconds = parsed
-			}
-		case float64:
-			timeoutSeconds = int(v)
-		case int:
-			timeoutSeconds = v
-		case int64:
-			timeoutSeconds = int(v)
-		}
+	timeoutSeconds, err := h.agentTimeout(req.TimeoutSeconds)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
 	// Convert request to model
@@ -76,12 +105,22 @@ func (h *AgentHandler) CreateAgent(c echo.Context) error {
 		APIToken:      req.APIToken,
 		ModelName:     req.ModelName,
 		TimeoutSeconds: timeoutSeconds,
+		EndpointStyle:  req.EndpointStyle,
+		APIVersion:     req.APIVersion,
+		ExtraHeaders:   req.ExtraHeaders,
+		SystemPrompt:   req.SystemPrompt,
+		Trace:          req.Trace,
+	}
+
+	if err := agent.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
 	if err := h.db.InsertAgent(&agent); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to create agent: %v", err)})
 	}
 
+	maskAgent(&agent)
 	return c.JSON(http.StatusCreated, agent)
 }
 
@@ -92,9 +131,83 @@ func (h *AgentHandler) GetAgents(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get agents: %v", err)})
 	}
 
+	scores, err := stats.LoadReliability(h.db)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get agent reliability: %v", err)})
+	}
+	for _, agent := range agents {
+		r := scores[agent.ID]
+		r.AgentID = agent.ID
+		agent.Reliability = &r
+	}
+
+	if err := stats.MarkSharedKeys(h.db, agents); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to check shared API keys: %v", err)})
+	}
+	for _, agent := range agents {
+		maskAgent(agent)
+	}
+
 	return c.JSON(http.StatusOK, agents)
 }
 
+// GetTokenStats handles GET /api/stats/tokens
+func (h *AgentHandler) GetTokenStats(c echo.Context) error {
+	days := stats.DefaultTokenStatsDays
+	if v := c.QueryParam("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > stats.MaxTokenStatsDays {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("days must be between 1 and %d", stats.MaxTokenStatsDays)})
+		}
+		days = n
+	}
+
+	usage, err := stats.LoadTokenUsage(h.db, days)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get token usage: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, usage)
+}
+
+// GetAgentStats handles GET /api/agents/stats
+func (h *AgentHandler) GetAgentStats(c echo.Context) error {
+	agents, err := h.db.GetAllAgents()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get agents: %v", err)})
+	}
+
+	scores, err := stats.LoadReliability(h.db)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get agent reliability: %v", err)})
+	}
+
+	latencies, err := stats.LoadLatency(h.db)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get agent latency: %v", err)})
+	}
+
+	result := make([]models.AgentReliability, 0, len(agents))
+	latency := make([]models.AgentLatency, 0, len(agents))
+	for _, agent := range agents {
+		r := scores[agent.ID]
+		r.AgentID = agent.ID
+		result = append(result, r)
+
+		l, ok := latencies[agent.ID]
+		if !ok {
+			l = stats.Latency(agent.ID, nil)
+		}
+		latency = append(latency, l)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"reliability":           result,
+		"low_reliability_score": stats.LowReliabilityScore,
+		"latency":               latency,
+	})
+}
+
 // GetAgent handles GET /api/agents/:id
 func (h *AgentHandler) GetAgent(c echo.Context) error {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
@@ -107,6 +220,7 @@ func (h *AgentHandler) GetAgent(c echo.Context) error {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "Agent not found"})
 	}
 
+	maskAgent(agent)
 	return c.JSON(http.StatusOK, agent)
 }
 
@@ -122,21 +236,9 @@ func (h *AgentHandler) UpdateAgent(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid request body: %v", err)})
 	}
 
-	// Parse timeout_seconds - handle both string and int
-	timeoutSeconds := 30 // default
-	if req.TimeoutSeconds != nil {
-		switch v := req.TimeoutSeconds.(type) {
-		case string:
-			if parsed, err := strconv.Atoi(v); err == nil {
-				timeoutSeconds = parsed
-			}
-		case float64:
-			timeoutSeconds = int(v)
-		case int:
-			timeoutSeconds = v
-		case int64:
-			timeoutSeconds = int(v)
-		}
+	timeoutSeconds, err := h.agentTimeout(req.TimeoutSeconds)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
 	// Convert request to model
@@ -148,12 +250,25 @@ func (h *AgentHandler) UpdateAgent(c echo.Context) error {
 		APIToken:      req.APIToken,
 		ModelName:     req.ModelName,
 		TimeoutSeconds: timeoutSeconds,
+		EndpointStyle:  req.EndpointStyle,
+		APIVersion:     req.APIVersion,
+		ExtraHeaders:   req.ExtraHeaders,
+		SystemPrompt:   req.SystemPrompt,
+		Trace:          req.Trace,
+	}
+	if stored, err := h.db.GetAgent(id); err == nil {
+		agent.RestoreMaskedHeaders(stored.ExtraHeaders)
+	}
+
+	if err := agent.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
 	if err := h.db.UpdateAgent(&agent); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to update agent: %v", err)})
 	}
 
+	maskAgent(&agent)
 	return c.JSON(http.StatusOK, agent)
 }
 
@@ -164,9 +279,27 @@ func (h *AgentHandler) DeleteAgent(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid agent ID"})
 	}
 
+	agent, err := h.db.GetAgent(id)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Agent not found"})
+	}
+
+	// Running and paused debates would lose the agent mid-round
+	live, err := h.db.GetLiveDiscussionIDsUsingAgent(id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to check discussions: %v", err)})
+	}
+	if len(live) > 0 && c.QueryParam("force") != "true" {
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"error":          "Agent is in use by running discussions; stop them first or delete with force=true",
+			"discussion_ids": live,
+		})
+	}
+
 	if err := h.db.DeleteAgent(id); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to delete agent: %v", err)})
 	}
+	h.debateEngine.NoteAgentDeleted(agent, live)
 
 	return c.NoContent(http.StatusNoContent)
 }
@@ -186,20 +319,64 @@ func (h *AgentHandler) DuplicateAgent(c echo.Context) error {
 
 	// Create duplicated agent with modified name
 	duplicatedAgent := models.Agent{
-		Name:           agent.Name + " - Copy",
+		Name:           copyName(agent.Name, 1),
 		ProviderType:   agent.ProviderType,
 		ProviderURL:    agent.ProviderURL,
 		APIToken:       agent.APIToken,
 		ModelName:      agent.ModelName,
 		TimeoutSeconds: agent.TimeoutSeconds,
+		EndpointStyle:  agent.EndpointStyle,
+		APIVersion:     agent.APIVersion,
+		ExtraHeaders:   agent.ExtraHeaders,
+		SystemPrompt:   agent.SystemPrompt,
+		Trace:          agent.Trace,
+	}
+
+	if err := duplicatedAgent.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
-	if err := h.db.InsertAgent(&duplicatedAgent); err != nil {
+	// Names are unique, so copies of copies number themselves on
+	for n := 2; ; n++ {
+		err = h.db.InsertAgent(&duplicatedAgent)
+		if !errors.Is(err, database.ErrAgentNameExists) || n > maxAgentCopies {
+			break
+		}
+		duplicatedAgent.Name = copyName(agent.Name, n)
+	}
+	if errors.Is(err, database.ErrAgentNameExists) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": fmt.Sprintf("Agent already has %d copies", maxAgentCopies)})
+	}
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to duplicate agent: %v", err)})
 	}
 
+	maskAgent(&duplicatedAgent)
 	return c.JSON(http.StatusCreated, duplicatedAgent)
 }
+
+// maxAgentCopies bounds the copy numbers DuplicateAgent tries
+const maxAgentCopies = 100
+
+// copyName names the nth copy of an agent: "Name - Copy", then
+// "Name - Copy 2" and so on. The original name is shortened to keep the
+// copy within the name length limit.
+func copyName(name string, n int) string {
+	suffix := " - Copy"
+	if n > 1 {
+		suffix += " " + strconv.Itoa(n)
+	}
+	if runes, room := []rune(name), models.MaxAgentNameLength-len(suffix); len(runes) > room {
+		name = strings.TrimSpace(string(runes[:room]))
+	}
+	return name + suffix
+}
+
+// maskAgent hides the secret extra header values of an agent about to be
+// returned by the agents API
+func maskAgent(agent *models.Agent) {
+	agent.ExtraHeaders = agent.MaskedHeaders()
+}
 func (h *AgentHandler) PingAgent(c echo.Context) error {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
@@ -213,261 +390,2639 @@ func (h *AgentHandler) PingAgent(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
 }
 
-// DiscussionHandler handles discussion-related endpoints
-type DiscussionHandler struct {
-	db          *database.DB
-	debateEngine *orchestrator.DebateEngine
-}
-
-func NewDiscussionHandler(db *database.DB, debateEngine *orchestrator.DebateEngine) *DiscussionHandler {
-	return &DiscussionHandler{
-		db:          db,
-		debateEngine: debateEngine,
-	}
-}
-
-// CreateDiscussion handles POST /api/discussions
-func (h *DiscussionHandler) CreateDiscussion(c echo.Context) error {
-	var request struct {
-		Topic        string  `json:"topic"`
-		AgentIDs     []int64 `json:"agent_ids"`
-		ModeratorID  *int64  `json:"moderator_id"`
-		MaxRounds    int     `json:"max_rounds"`
-		Language     string  `json:"language"`
-		MaxCharLimit int     `json:"max_char_limit"`
+// TestAgent handles POST /api/agents/:id/test. It sends the agent a prompt
+// as a debate turn would and returns the reply, without creating a
+// discussion; a failed call is reported in the result with status 200.
+func (h *AgentHandler) TestAgent(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid agent ID"})
 	}
 
-	if err := c.Bind(&request); err != nil {
+	var req models.AgentTestRequest
+	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
 	}
-
-	// Validate required fields
-	if request.Topic == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "topic is required"})
-	}
-
-	if len(request.AgentIDs) == 0 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "at least one agent is required"})
-	}
-
-	// Set defaults if not provided
-	if request.MaxRounds <= 0 {
-		request.MaxRounds = 3
-	}
-	if request.Language == "" {
-		request.Language = "English"
-	}
-	if request.MaxCharLimit <= 0 {
-		request.MaxCharLimit = 1000
+	prompt := strings.TrimSpace(req.Prompt)
+	if prompt == "" {
+		prompt = models.DefaultAgentTestPrompt
 	}
-
-	discussion, err := h.debateEngine.RunDebate(c.Request().Context(), request.Topic, request.AgentIDs, request.ModeratorID, request.MaxRounds, request.Language, request.MaxCharLimit)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to create discussion: %v", err)})
+	if len(prompt) > models.MaxAgentTestPromptLength {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("prompt must be at most %d characters", models.MaxAgentTestPromptLength)})
 	}
 
-	return c.JSON(http.StatusCreated, discussion)
-}
-
-// GetDiscussions handles GET /api/discussions
-func (h *DiscussionHandler) GetDiscussions(c echo.Context) error {
-	discussions, err := h.db.GetAllDiscussions()
+	agent, err := h.db.GetAgent(id)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get discussions: %v", err)})
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Agent not found"})
 	}
 
-	return c.JSON(http.StatusOK, discussions)
+	return c.JSON(http.StatusOK, h.debateEngine.TestAgent(c.Request().Context(), agent, prompt))
 }
 
-// GetDiscussion handles GET /api/discussions/:id
-func (h *DiscussionHandler) GetDiscussion(c echo.Context) error {
+// ListAgentModels handles GET /api/agents/:id/models. It answers 501 for
+// providers that cannot list their models and 502 when the provider fails.
+func (h *AgentHandler) ListAgentModels(c echo.Context) error {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid discussion ID"})
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid agent ID"})
 	}
 
-	discussion, logs, err := h.debateEngine.GetDiscussionStatus(id)
+	agent, err := h.db.GetAgent(id)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Discussion not found"})
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Agent not found"})
 	}
 
-	response := map[string]interface{}{
-		"discussion": discussion,
-		"logs":       logs,
+	list, err := h.debateEngine.ListAgentModels(c.Request().Context(), agent)
+	if errors.Is(err, orchestrator.ErrModelListUnsupported) {
+		return c.JSON(http.StatusNotImplemented, map[string]string{"error": err.Error()})
 	}
-
-	return c.JSON(http.StatusOK, response)
-}
-
-// StopDiscussion handles POST /api/discussions/:id/stop
-func (h *DiscussionHandler) StopDiscussion(c echo.Context) error {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid discussion ID"})
-	}
-
-	if err := h.debateEngine.StopDiscussion(id); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Failed to stop discussion: %v", err)})
+		return c.JSON(http.StatusBadGateway, map[string]string{"error": fmt.Sprintf("Failed to list models: %v", err)})
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{"status": "stopped"})
+	return c.JSON(http.StatusOK, list)
 }
 
-// DeleteDiscussion handles DELETE /api/discussions/:id
-func (h *DiscussionHandler) DeleteDiscussion(c echo.Context) error {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid discussion ID"})
+// ProbeProvider handles POST /api/agents/probe. It detects the provider at
+// provider_url and suggests an agent configuration for it; nothing is stored.
+func (h *AgentHandler) ProbeProvider(c echo.Context) error {
+	var req models.ProbeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
 	}
 
-	if err := h.db.DeleteDiscussion(id); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to delete discussion: %v", err)})
+	u, err := url.Parse(strings.TrimSpace(req.ProviderURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "provider_url must be an http or https URL"})
+	}
+	if len(req.APIToken) > models.MaxAPITokenLength {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("api_token must be at most %d characters", models.MaxAPITokenLength)})
 	}
 
-	return c.NoContent(http.StatusNoContent)
+	return c.JSON(http.StatusOK, h.debateEngine.ProbeProvider(c.Request().Context(), req.ProviderURL, req.APIToken))
 }
 
-// RetryAgent handles POST /api/discussions/:id/retry/:agentId
-func (h *DiscussionHandler) RetryAgent(c echo.Context) error {
-	discussionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid discussion ID"})
-	}
+// CreateDiscussionRequest represents the payload for starting a discussion
+type CreateDiscussionRequest struct {
+	Topic        string                    `json:"topic"`
+	AgentIDs     []int64                   `json:"agent_ids"`
+	// Stances optionally assigns a side to some of the agents
+	Stances      []models.AgentStance      `json:"stances"`
+	ModeratorID  *int64                    `json:"moderator_id"`
+	// JudgeID names an agent that gives a verdict once the debate ends
+	JudgeID      *int64                    `json:"judge_id"`
+	MaxRounds    int                       `json:"max_rounds"`
+	Language     string                    `json:"language"`
+	MaxCharLimit int                       `json:"max_char_limit"`
+	Settings     models.DiscussionSettings `json:"settings"`
+	// SuppressDuplicates returns the discussion this client just created with
+	// the same topic and agents instead of starting another one
+	SuppressDuplicates bool `json:"suppress_duplicates"`
+	// Preset names a debate format whose values the request starts from
+	Preset string `json:"preset"`
+}
 
-	agentID, err := strconv.ParseInt(c.Param("agentId"), 10, 64)
+// bindDiscussionRequest reads a discussion request. With a known preset the
+// request starts from the preset's rounds and settings and the body is bound
+// over them, so anything the body sets, including false and zero values,
+// wins. Stances and the judge are filled in by validate, once the agents are
+// known.
+func bindDiscussionRequest(c echo.Context, request *CreateDiscussionRequest) error {
+	body, err := io.ReadAll(c.Request().Body)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid agent ID"})
+		return err
 	}
-
-	if err := h.debateEngine.RetryFailedAgent(c.Request().Context(), discussionID, agentID); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to retry agent: %v", err)})
+	var named struct {
+		Preset string `json:"preset"`
+	}
+	// A body that is not JSON is left for Bind to decode or reject
+	_ = json.Unmarshal(body, &named)
+	if preset, ok := models.LookupPreset(normalizePreset(named.Preset)); ok {
+		request.MaxRounds = preset.MaxRounds
+		// Copied through JSON so binding never writes into the preset itself
+		settings, err := json.Marshal(preset.Settings)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(settings, &request.Settings); err != nil {
+			return err
+		}
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{"status": "retry initiated"})
+	c.Request().Body = io.NopCloser(bytes.NewReader(body))
+	if err := c.Bind(request); err != nil {
+		return err
+	}
+	request.Preset = normalizePreset(request.Preset)
+	return nil
 }
 
-// SSEHandler handles Server-Sent Events for real-time updates
-type SSEHandler struct {
-	db          *database.DB
-	debateEngine *orchestrator.DebateEngine
+func normalizePreset(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
 }
 
-func NewSSEHandler(db *database.DB, debateEngine *orchestrator.DebateEngine) *SSEHandler {
-	return &SSEHandler{
-		db:          db,
-		debateEngine: debateEngine,
+// applyPreset checks the request against its preset and assigns the
+// preset's stances and judge where the request has none
+func (r *CreateDiscussionRequest) applyPreset() error {
+	preset, ok := models.LookupPreset(r.Preset)
+	if !ok {
+		return fmt.Errorf("unknown preset %q", r.Preset)
+	}
+	if len(r.AgentIDs) < preset.MinAgents {
+		return fmt.Errorf("preset %s needs at least %d agents", preset.Name, preset.MinAgents)
+	}
+	r.Settings.Preset = preset.Name
+	if len(r.Stances) == 0 && preset.Stances != nil {
+		r.Stances = preset.Stances(r.AgentIDs)
+	}
+	if preset.ModeratorJudges && r.JudgeID == nil && r.ModeratorID != nil {
+		judge := *r.ModeratorID
+		r.JudgeID = &judge
 	}
+	return nil
 }
 
-// StreamDiscussion handles GET /api/discussions/:id/stream
-func (h *SSEHandler) StreamDiscussion(c echo.Context) error {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid discussion ID"})
+// validate checks the required fields, fills defaults and normalizes the
+// language to its code. An unknown language is kept as given and reported
+// in the returned warnings.
+func (r *CreateDiscussionRequest) validate() ([]string, error) {
+	if r.Topic == "" {
+		return nil, errors.New("topic is required")
 	}
 
-	// Set SSE headers
-	c.Response().Header().Set("Content-Type", "text/event-stream")
-	c.Response().Header().Set("Cache-Control", "no-cache")
-	c.Response().Header().Set("Connection", "keep-alive")
-	c.Response().Header().Set("Access-Control-Allow-Origin", "*")
+	if len(r.AgentIDs) == 0 {
+		return nil, errors.New("at least one agent is required")
+	}
 
-	// Subscribe to discussion updates
-	updateChan := h.debateEngine.Subscribe(id)
-	defer h.debateEngine.Unsubscribe(id, updateChan)
+	if r.Preset != "" {
+		if err := r.applyPreset(); err != nil {
+			return nil, err
+		}
+	}
 
-	// Context for disconnection
-	ctx := c.Request().Context()
+	if err := r.Settings.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid settings: %w", err)
+	}
 
-	// Initial status message
-	h.sendSSEUpdate(c.Response(), "status", map[string]string{"message": "Streaming started"})
+	if err := models.ValidateStances(r.Stances, r.AgentIDs); err != nil {
+		return nil, fmt.Errorf("invalid stances: %w", err)
+	}
 
-	// Listen for updates or disconnection
-	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		case update := <-updateChan:
-			var eventType string
-			switch v := update.(type) {
-			case *models.DiscussionLog:
-				eventType = "log"
-				// Add agent name to log for UI
-				agent, _ := h.db.GetAgent(v.AgentID)
-				initial := "A"
-				name := "Unknown Agent"
-				if agent != nil {
-					name = agent.Name
-					if len(name) > 0 {
-						runes := []rune(name)
-						initial = strings.ToUpper(string(runes[0]))
-					}
-				}
-				update = map[string]interface{}{
-					"log": v,
-					"agent": map[string]interface{}{
-						"name":    name,
-						"initial": initial,
-					},
-				}
-			case *models.Discussion:
-				eventType = "discussion"
-			default:
-				eventType = "update"
-			}
-			if err := h.sendSSEUpdate(c.Response(), eventType, update); err != nil {
-				return nil
-			}
+	// Set defaults if not provided
+	if r.MaxRounds == 0 {
+		r.MaxRounds = models.DefaultMaxRounds
+	}
+	if r.MaxRounds < models.MinMaxRounds || r.MaxRounds > models.MaxMaxRounds {
+		return nil, fmt.Errorf("max_rounds must be between %d and %d", models.MinMaxRounds, models.MaxMaxRounds)
+	}
+	if r.MaxCharLimit == 0 {
+		r.MaxCharLimit = models.DefaultMaxCharLimit
+	}
+	if r.MaxCharLimit < models.MinMaxCharLimit || r.MaxCharLimit > models.MaxMaxCharLimit {
+		return nil, fmt.Errorf("max_char_limit must be between %d and %d", models.MinMaxCharLimit, models.MaxMaxCharLimit)
+	}
+	warnings := []string{}
+	if language, known := models.NormalizeLanguage(r.Language); language == "" {
+		r.Language = models.DefaultLanguage
+	} else {
+		r.Language = language
+		if !known {
+			warnings = append(warnings, fmt.Sprintf("Language %q is not recognised; agents will be asked to respond in it as written and replies will not be checked for it", language))
 		}
 	}
+	return warnings, nil
 }
 
-func (h *SSEHandler) sendSSEUpdate(resp *echo.Response, eventType string, data interface{}) error {
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return err
+// createDiscussionResponse is the created discussion plus any warnings about
+// the request. DuplicateSuppressed marks an existing discussion returned in
+// place of a duplicate.
+type createDiscussionResponse struct {
+	*models.Discussion
+	Warnings            []string `json:"warnings,omitempty"`
+	DuplicateSuppressed bool     `json:"duplicate_suppressed,omitempty"`
+	// HostWarnings details the provider hosts named in Warnings that the
+	// discussion may overload
+	HostWarnings []models.HostLoadWarning `json:"host_warnings,omitempty"`
+}
+
+// hostLoadWarnings checks the provider hosts of a discussion request against
+// the host limits and adds a warning message for each overloaded host
+func (h *DiscussionHandler) hostLoadWarnings(request *CreateDiscussionRequest, warnings []string) ([]models.HostLoadWarning, []string) {
+	agents := make([]*models.Agent, 0, len(request.AgentIDs))
+	for _, id := range request.AgentIDs {
+		if agent, err := h.db.GetAgent(id); err == nil {
+			agents = append(agents, agent)
+		}
+	}
+	var moderator *models.Agent
+	if request.ModeratorID != nil {
+		if agent, err := h.db.GetAgent(*request.ModeratorID); err == nil {
+			moderator = agent
+		}
 	}
 
-	_, err = fmt.Fprintf(resp, "event: %s\ndata: %s\n\n", eventType, string(jsonData))
-	resp.Flush()
-	return err
+	parallel := request.Settings.RoundMode == models.RoundModeParallel
+	hostWarnings := h.debateEngine.HostLoadWarnings(agents, moderator, parallel)
+	for _, w := range hostWarnings {
+		warnings = append(warnings, w.Message)
+	}
+	return hostWarnings, warnings
 }
 
-// Page handlers for serving HTML
-type PageHandler struct {
-	db *database.DB
+// DiscussionHandler handles discussion-related endpoints
+type DiscussionHandler struct {
+	db          *database.DB
+	debateEngine *orchestrator.DebateEngine
+	jobs         *jobs.Manager
 }
 
-func NewPageHandler(db *database.DB) *PageHandler {
-	return &PageHandler{db: db}
+func NewDiscussionHandler(db *database.DB, debateEngine *orchestrator.DebateEngine, jobManager *jobs.Manager) *DiscussionHandler {
+	return &DiscussionHandler{
+		db:          db,
+		debateEngine: debateEngine,
+		jobs:         jobManager,
+	}
 }
 
-// Dashboard handles GET /
-func (h *PageHandler) Dashboard(c echo.Context) error {
-	agents, err := h.db.GetAllAgents()
-	if err != nil {
-		return c.HTML(http.StatusInternalServerError, "<h1>Error loading agents</h1>")
+// CreateDiscussion handles POST /api/discussions
+func (h *DiscussionHandler) CreateDiscussion(c echo.Context) error {
+	var request CreateDiscussionRequest
+	if err := bindDiscussionRequest(c, &request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
 	}
 
-	discussions, err := h.db.GetAllDiscussions()
+	warnings, err := request.validate()
 	if err != nil {
-		return c.HTML(http.StatusInternalServerError, "<h1>Error loading discussions</h1>")
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
+	if request.Settings.Webhook != nil && !h.debateEngine.FeatureEnabled(orchestrator.FeatureWebhooks) {
+		return featureUnavailable(c, orchestrator.FeatureWebhooks)
+	}
+	// Checked before starting so the new discussion does not count itself
+	hostWarnings, warnings := h.hostLoadWarnings(&request, warnings)
 
-	runningCount := 0
-	for _, d := range discussions {
-		if d.Status == "running" {
-			runningCount++
+	create := func() (*models.Discussion, error) {
+		return h.debateEngine.RunDebate(c.Request().Context(), request.Topic, request.AgentIDs, request.Stances, request.ModeratorID, request.JudgeID, request.MaxRounds, request.Language, request.MaxCharLimit, request.Settings)
+	}
+	var discussion *models.Discussion
+	suppressed := false
+	if request.SuppressDuplicates {
+		fingerprint := orchestrator.DiscussionFingerprint(c.RealIP(), request.Topic, request.AgentIDs)
+		discussion, suppressed, err = h.debateEngine.CreateOnce(fingerprint, create)
+	} else {
+		discussion, err = create()
+	}
+	if err != nil {
+		if errors.Is(err, orchestrator.ErrAllProvidersPaused) {
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+		}
+		if errors.Is(err, orchestrator.ErrAgentDisabled) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		var lookupErr *orchestrator.AgentLookupError
+		if errors.As(err, &lookupErr) {
+			status := http.StatusInternalServerError
+			if len(lookupErr.Missing()) > 0 {
+				status = http.StatusNotFound
+			}
+			return c.JSON(status, map[string]interface{}{"error": lookupErr.Error(), "agents": lookupErr.Results})
 		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to create discussion: %v", err)})
 	}
 
-	data := map[string]interface{}{
-		"Agents":       agents,
-		"Discussions":  discussions,
-		"RunningCount": runningCount,
+	if suppressed {
+		return c.JSON(http.StatusOK, createDiscussionResponse{Discussion: discussion, DuplicateSuppressed: true})
 	}
+	return c.JSON(http.StatusCreated, createDiscussionResponse{Discussion: discussion, Warnings: warnings, HostWarnings: hostWarnings})
+}
 
-	return c.Render(http.StatusOK, "dashboard.html", data)
+// GetPresets handles GET /api/presets. Each preset lists the rounds and
+// settings a discussion request naming it in "preset" starts from.
+func (h *DiscussionHandler) GetPresets(c echo.Context) error {
+	return c.JSON(http.StatusOK, models.Presets())
+}
+
+// ImportDiscussion handles POST /api/discussions/import. It stores a
+// transcript produced by another tool as a read-only discussion.
+func (h *DiscussionHandler) ImportDiscussion(c echo.Context) error {
+	var doc models.TranscriptImport
+	if err := c.Bind(&doc); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid request body: %v", err)})
+	}
+
+	if err := doc.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	result, err := h.db.ImportDiscussion(&doc)
+	if err != nil {
+		if errors.Is(err, models.ErrUnknownParticipants) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to import discussion: %v", err)})
+	}
+
+	return c.JSON(http.StatusCreated, result)
+}
+
+// ValidateDiscussion handles POST /api/discussions/validate. It checks a
+// discussion request without starting it and warns about selected agents
+// whose reliability score is low and provider hosts it may overload.
+func (h *DiscussionHandler) ValidateDiscussion(c echo.Context) error {
+	var request CreateDiscussionRequest
+	if err := bindDiscussionRequest(c, &request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	result := map[string]interface{}{"valid": true, "warnings": []string{}}
+	warnings, err := request.validate()
+	if err != nil {
+		result["valid"] = false
+		result["error"] = err.Error()
+		return c.JSON(http.StatusOK, result)
+	}
+
+	scores, err := stats.LoadReliability(h.db)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get agent reliability: %v", err)})
+	}
+
+	for _, id := range request.AgentIDs {
+		r, ok := scores[id]
+		if !ok || !r.Rated || r.Score >= stats.LowReliabilityScore {
+			continue
+		}
+		name := fmt.Sprintf("#%d", id)
+		if agent, err := h.db.GetAgent(id); err == nil {
+			name = agent.Name
+		}
+		warnings = append(warnings, fmt.Sprintf("Agent %s has a low reliability score (%d/100: %.0f%% success, %.0f%% timeouts)",
+			name, r.Score, r.SuccessRate*100, r.TimeoutRate*100))
+	}
+	result["host_warnings"], warnings = h.hostLoadWarnings(&request, warnings)
+	result["warnings"] = warnings
+
+	estimate, err := h.estimateDiscussion(&request)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to estimate discussion: %v", err)})
+	}
+	result["estimate"] = estimate
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// estimateDiscussion estimates the calls, tokens and cost of a discussion
+// request from the agents' past reply sizes and the model pricing. Agents
+// that cannot be loaded are left out.
+func (h *DiscussionHandler) estimateDiscussion(request *CreateDiscussionRequest) (models.DiscussionEstimate, error) {
+	history, err := stats.LoadTokenHistory(h.db)
+	if err != nil {
+		return models.DiscussionEstimate{}, err
+	}
+	pricing, err := h.db.GetAllModelPricing()
+	if err != nil {
+		return models.DiscussionEstimate{}, err
+	}
+
+	plan := stats.EstimatePlan{
+		Topic:        request.Topic,
+		MaxRounds:    request.MaxRounds,
+		MaxCharLimit: request.MaxCharLimit,
+		Settings:     request.Settings,
+	}
+	for _, id := range request.AgentIDs {
+		if agent, err := h.db.GetAgent(id); err == nil {
+			plan.Agents = append(plan.Agents, agent)
+		}
+	}
+	if request.ModeratorID != nil {
+		plan.Moderator, _ = h.db.GetAgent(*request.ModeratorID)
+	}
+	if request.JudgeID != nil {
+		plan.Judge, _ = h.db.GetAgent(*request.JudgeID)
+	}
+	return stats.EstimateDiscussion(plan, history, pricing), nil
+}
+
+// parseListDate reads a from or to parameter of the discussion list, either
+// a date (2006-01-02, in server time) or an RFC 3339 timestamp. It returns
+// the start of the range the value names and that range's length.
+func parseListDate(name, value string) (time.Time, time.Duration, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, 24 * time.Hour, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, time.Second, nil
+	}
+	return time.Time{}, 0, fmt.Errorf("%s must be a date (YYYY-MM-DD) or an RFC 3339 timestamp", name)
+}
+
+// parseDiscussionQuery reads the paging (page, per_page, sort, order) and
+// filter (status, agent_id, from, to) query parameters of a discussion list
+// request. from and to are both inclusive.
+func parseDiscussionQuery(c echo.Context) (models.DiscussionQuery, error) {
+	q := models.DiscussionQuery{
+		Sort:   c.QueryParam("sort"),
+		Order:  strings.ToLower(c.QueryParam("order")),
+		Status: c.QueryParam("status"),
+	}
+	if v := c.QueryParam("agent_id"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 {
+			return q, errors.New("agent_id must be a positive integer")
+		}
+		q.AgentID = n
+	}
+	if v := c.QueryParam("from"); v != "" {
+		from, _, err := parseListDate("from", v)
+		if err != nil {
+			return q, err
+		}
+		q.From = from
+	}
+	if v := c.QueryParam("to"); v != "" {
+		to, span, err := parseListDate("to", v)
+		if err != nil {
+			return q, err
+		}
+		q.Until = to.Add(span)
+	}
+	if v := c.QueryParam("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return q, errors.New("page must be at least 1")
+		}
+		q.Page = n
+	}
+	if v := c.QueryParam("per_page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return q, fmt.Errorf("per_page must be between 1 and %d", models.MaxDiscussionsPerPage)
+		}
+		q.PerPage = n
+	}
+	return q, q.Normalize()
+}
+
+// GetDiscussions handles GET /api/discussions. The query parameters filter
+// the list and select the page returned with the total count of matches.
+func (h *DiscussionHandler) GetDiscussions(c echo.Context) error {
+	q, err := parseDiscussionQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	page, err := h.db.GetDiscussionsPage(q)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get discussions: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// GetDiscussion handles GET /api/discussions/:id
+func (h *DiscussionHandler) GetDiscussion(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid discussion ID"})
+	}
+
+	discussion, logs, err := h.debateEngine.GetDiscussionStatus(id)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Discussion not found"})
+	}
+	// Anchored before filtering, so they match the full transcript's
+	anchors := export.ApplyAnchors(logs)
+	ratings, err := h.db.GetDiscussionRatings(id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get ratings: %v", err)})
+	}
+	participants := orchestrator.Participants(discussion, logs, ratings)
+
+	// ?exclude_log_types=skip drops engine notes from the transcript
+	if exclude := c.QueryParam("exclude_log_types"); exclude != "" {
+		excluded := make(map[string]bool)
+		for _, t := range strings.Split(exclude, ",") {
+			excluded[strings.TrimSpace(t)] = true
+		}
+		filtered := make([]*models.DiscussionLog, 0, len(logs))
+		for _, l := range logs {
+			if !excluded[l.LogType] {
+				filtered = append(filtered, l)
+			}
+		}
+		logs = filtered
+	}
+
+	cost, err := h.estimateCosts(logs)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to estimate cost: %v", err)})
+	}
+	discussion.EstimatedCost = cost.TotalCost
+
+	response := map[string]interface{}{
+		"discussion":   discussion,
+		"logs":         logs,
+		"participants": participants,
+		"compliance":   orchestrator.ComplianceReport(logs),
+		"citations":    orchestrator.CitationReport(logs),
+		"token_usage":  stats.DiscussionTokenUsage(logs),
+		"contents":     export.Contents(logs, anchors),
+	}
+
+	if c.QueryParam("include_notes") == "true" {
+		notes, err := h.db.GetDiscussionNotes(id)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get notes: %v", err)})
+		}
+		response["notes"] = notes
+	}
+
+	response["ratings"] = ratings
+	response["rating_counts"] = models.CountRatings(ratings)
+
+	analysis, err := h.db.GetDiscussionAnalysis(id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get analysis: %v", err)})
+	}
+	if analysis != nil {
+		response["analysis"] = analysis
+		response["agreement_matrix"] = h.debateEngine.AnalysisMatrix(discussion, analysis)
+	}
+
+	if c.QueryParam("include_annotations") == "true" {
+		annotations, err := h.db.GetDiscussionAnnotations(id)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get annotations: %v", err)})
+		}
+		response["annotations"] = annotations
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// ReplayDiscussion handles GET /api/discussions/:id/replay?upto=SEQ
+// Without upto the whole transcript is replayed.
+func (h *DiscussionHandler) ReplayDiscussion(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid discussion ID"})
+	}
+
+	upto := int64(-1)
+	if u := c.QueryParam("upto"); u != "" {
+		upto, err = strconv.ParseInt(u, 10, 64)
+		if err != nil || upto < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "upto must be a non-negative integer"})
+		}
+	}
+
+	replay, err := h.debateEngine.ReplayDiscussion(id, upto)
+	if err != nil || replay.Discussion.Status == "deleting" {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Discussion not found"})
+	}
+
+	return c.JSON(http.StatusOK, replay)
+}
+
+// ExportDiscussion handles GET /api/discussions/:id/export?format=script|json
+// The script is plain text and cast=true adds a cast list to it; json is the
+// versioned export.JSONTranscript. download=true serves either as an
+// attachment.
+func (h *DiscussionHandler) ExportDiscussion(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid discussion ID"})
+	}
+
+	format := c.QueryParam("format")
+	if format == "" {
+		format = export.FormatScript
+	}
+	if format != export.FormatScript && format != export.FormatJSON {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("format must be %s or %s", export.FormatScript, export.FormatJSON)})
+	}
+
+	replay, err := h.debateEngine.ReplayDiscussion(id, -1)
+	if err != nil || replay.Discussion.Status == "deleting" {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Discussion not found"})
+	}
+
+	agents, err := h.db.GetAllAgentsWithDeleted()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get agents: %v", err)})
+	}
+	names := make(map[int64]string, len(agents))
+	byID := make(map[int64]*models.Agent, len(agents))
+	for _, a := range agents {
+		names[a.ID] = a.Name
+		byID[a.ID] = a
+	}
+
+	if format == export.FormatJSON {
+		ratings, err := h.db.GetDiscussionRatings(id)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get ratings: %v", err)})
+		}
+		var notes []*models.DiscussionNote
+		if c.QueryParam("include_notes") == "true" {
+			if notes, err = h.db.GetDiscussionNotes(id); err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get notes: %v", err)})
+			}
+		}
+		res := c.Response()
+		res.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+		if c.QueryParam("download") == "true" {
+			res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"discussion-%d.json\"", id))
+		}
+		res.WriteHeader(http.StatusOK)
+		return export.WriteJSON(res, replay.Discussion, replay.Logs, byID, orchestrator.Participants(replay.Discussion, replay.Logs, ratings), notes)
+	}
+
+	analysis, err := h.db.GetDiscussionAnalysis(id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get analysis: %v", err)})
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, echo.MIMETextPlainCharsetUTF8)
+	// Topics and replies are user text; never let a browser sniff them as HTML
+	res.Header().Set(echo.HeaderXContentTypeOptions, "nosniff")
+	if c.QueryParam("download") == "true" {
+		res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"discussion-%d-script.txt\"", id))
+	}
+	res.WriteHeader(http.StatusOK)
+
+	opts := export.ScriptOptions{Cast: c.QueryParam("cast") == "true"}
+	if analysis != nil && analysis.Status == models.AnalysisSuccess {
+		opts.Agreement = h.debateEngine.AnalysisMatrix(replay.Discussion, analysis)
+	}
+	return export.WriteScript(res, replay.Discussion, replay.Logs, names, opts)
+}
+
+// Long-poll limits for WaitDiscussion, in seconds
+const (
+	defaultWaitTimeout = 60
+	maxWaitTimeout     = 120
+)
+
+// WaitDiscussion handles GET /api/discussions/:id/wait
+// It blocks until the discussion status differs from ?from= (default: the
+// current status) or the timeout elapses, then returns the discussion.
+func (h *DiscussionHandler) WaitDiscussion(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid discussion ID"})
+	}
+
+	timeout := defaultWaitTimeout
+	if t := c.QueryParam("timeout"); t != "" {
+		timeout, err = strconv.Atoi(t)
+		if err != nil || timeout < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "timeout must be a non-negative number of seconds"})
+		}
+	}
+	if timeout > maxWaitTimeout {
+		timeout = maxWaitTimeout
+	}
+
+	// Subscribe before reading the current state so a change in between is not missed
+	updateChan, err := h.debateEngine.Subscribe(id)
+	if err != nil {
+		return subscribeError(c, err)
+	}
+	defer h.debateEngine.Unsubscribe(id, updateChan)
+
+	discussion, err := h.db.GetDiscussion(id)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Discussion not found"})
+	}
+
+	from := c.QueryParam("from")
+	if from == "" {
+		from = discussion.Status
+	}
+	if discussion.Status != from || !discussion.InProgress() {
+		return c.JSON(http.StatusOK, map[string]interface{}{"discussion": discussion, "timed_out": false})
+	}
+
+	timer := time.NewTimer(time.Duration(timeout) * time.Second)
+	defer timer.Stop()
+
+	ctx := c.Request().Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+			return c.JSON(http.StatusOK, map[string]interface{}{"discussion": discussion, "timed_out": true})
+		case update, ok := <-updateChan:
+			if !ok {
+				return c.JSON(http.StatusOK, map[string]interface{}{"discussion": discussion, "timed_out": false})
+			}
+			if d, isDiscussion := update.Data.(models.Discussion); isDiscussion && d.Status != from {
+				return c.JSON(http.StatusOK, map[string]interface{}{"discussion": d, "timed_out": false})
+			}
+		}
+	}
+}
+
+// PauseDiscussion handles POST /api/discussions/:id/pause
+func (h *DiscussionHandler) PauseDiscussion(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid discussion ID"})
+	}
+
+	discussion, err := h.debateEngine.PauseDiscussion(id)
+	if err != nil {
+		if errors.Is(err, orchestrator.ErrNotPausable) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Failed to pause discussion: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, discussion)
+}
+
+// StopDiscussion handles POST /api/discussions/:id/stop
+func (h *DiscussionHandler) StopDiscussion(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid discussion ID"})
+	}
+
+	if err := h.debateEngine.StopDiscussion(id); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Failed to stop discussion: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"status": "stopped"})
+}
+
+// DeleteDiscussion handles DELETE /api/discussions/:id
+func (h *DiscussionHandler) DeleteDiscussion(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid discussion ID"})
+	}
+
+	discussion, err := h.db.GetDiscussion(id)
+	if errors.Is(err, database.ErrDiscussionNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Discussion not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get discussion: %v", err)})
+	}
+	// A live debate is only deleted with force=true, which stops it first
+	force := c.QueryParam("force") == "true"
+	if discussion.InProgress() {
+		if !force {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "Discussion is running; stop it first or delete with force=true"})
+		}
+		if err := h.debateEngine.StopDiscussion(id); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to stop discussion: %v", err)})
+		}
+	}
+
+	if err := h.db.MarkDiscussionDeleting(id, force); err != nil {
+		switch {
+		case errors.Is(err, database.ErrDiscussionNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Discussion not found"})
+		case errors.Is(err, database.ErrDiscussionLive):
+			// Resumed between the check above and the update
+			return c.JSON(http.StatusConflict, map[string]string{"error": "Discussion is running; stop it first or delete with force=true"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to delete discussion: %v", err)})
+	}
+
+	// Logs are removed in batches in the background so live debates keep writing
+	job := h.jobs.DeleteDiscussion(h.db, id)
+
+	return c.JSON(http.StatusAccepted, map[string]interface{}{
+		"status": "deleting",
+		"job_id": job.ID,
+	})
+}
+
+// ResumeDiscussion handles POST /api/discussions/:id/resume
+func (h *DiscussionHandler) ResumeDiscussion(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid discussion ID"})
+	}
+
+	discussion, err := h.debateEngine.ResumeDiscussion(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, orchestrator.ErrNotResumable), errors.Is(err, orchestrator.ErrDebateGone):
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		case errors.Is(err, orchestrator.ErrAllProvidersPaused):
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Failed to resume discussion: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, discussion)
+}
+
+// RetryAgent handles POST /api/discussions/:id/retry/:agentId
+func (h *DiscussionHandler) RetryAgent(c echo.Context) error {
+	discussionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid discussion ID"})
+	}
+
+	agentID, err := strconv.ParseInt(c.Param("agentId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid agent ID"})
+	}
+
+	if err := h.debateEngine.RetryFailedAgent(c.Request().Context(), discussionID, agentID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to retry agent: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"status": "retry initiated"})
+}
+
+// RetryLogEntry handles POST /api/discussions/:id/logs/:logId/retry
+func (h *DiscussionHandler) RetryLogEntry(c echo.Context) error {
+	discussionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid discussion ID"})
+	}
+
+	logID, err := strconv.ParseInt(c.Param("logId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid log ID"})
+	}
+
+	retried, err := h.debateEngine.RetryLogEntry(c.Request().Context(), discussionID, logID)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Failed to retry entry: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status": "retry initiated",
+		"log":    retried,
+	})
+}
+
+// NoteRequest represents the request body for creating or editing a note
+type NoteRequest struct {
+	Author  string `json:"author"`
+	Content string `json:"content"`
+}
+
+// noteDiscussion loads the discussion a note, annotation or claim route refers
+// to. Notes can be attached to a discussion in any status except one being
+// deleted.
+func (h *DiscussionHandler) noteDiscussion(c echo.Context) (*models.Discussion, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid discussion ID"})
+	}
+
+	discussion, err := h.db.GetDiscussion(id)
+	if err != nil || discussion.Status == "deleting" {
+		return nil, c.JSON(http.StatusNotFound, map[string]string{"error": "Discussion not found"})
+	}
+	return discussion, nil
+}
+
+// discussionNote loads a note and checks that it belongs to the discussion
+func (h *DiscussionHandler) discussionNote(c echo.Context, discussionID int64) (*models.DiscussionNote, error) {
+	noteID, err := strconv.ParseInt(c.Param("noteId"), 10, 64)
+	if err != nil {
+		return nil, c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid note ID"})
+	}
+
+	note, err := h.db.GetDiscussionNote(noteID)
+	if err != nil || note.DiscussionID != discussionID {
+		return nil, c.JSON(http.StatusNotFound, map[string]string{"error": "Note not found"})
+	}
+	return note, nil
+}
+
+// GetNotes handles GET /api/discussions/:id/notes
+func (h *DiscussionHandler) GetNotes(c echo.Context) error {
+	discussion, err := h.noteDiscussion(c)
+	if discussion == nil {
+		return err
+	}
+
+	notes, err := h.db.GetDiscussionNotes(discussion.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get notes: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, notes)
+}
+
+// CreateNote handles POST /api/discussions/:id/notes
+func (h *DiscussionHandler) CreateNote(c echo.Context) error {
+	discussion, err := h.noteDiscussion(c)
+	if discussion == nil {
+		return err
+	}
+
+	var req NoteRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	note := models.DiscussionNote{
+		DiscussionID: discussion.ID,
+		Author:       req.Author,
+		Content:      req.Content,
+	}
+	if err := note.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := h.db.InsertDiscussionNote(&note); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to create note: %v", err)})
+	}
+
+	return c.JSON(http.StatusCreated, note)
+}
+
+// UpdateNote handles PUT /api/discussions/:id/notes/:noteId
+func (h *DiscussionHandler) UpdateNote(c echo.Context) error {
+	discussion, err := h.noteDiscussion(c)
+	if discussion == nil {
+		return err
+	}
+
+	note, err := h.discussionNote(c, discussion.ID)
+	if note == nil {
+		return err
+	}
+
+	var req NoteRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	note.Author = req.Author
+	note.Content = req.Content
+	if err := note.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := h.db.UpdateDiscussionNote(note); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to update note: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, note)
+}
+
+// DeleteNote handles DELETE /api/discussions/:id/notes/:noteId
+func (h *DiscussionHandler) DeleteNote(c echo.Context) error {
+	discussion, err := h.noteDiscussion(c)
+	if discussion == nil {
+		return err
+	}
+
+	note, err := h.discussionNote(c, discussion.ID)
+	if note == nil {
+		return err
+	}
+
+	if err := h.db.DeleteDiscussionNote(note.ID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to delete note: %v", err)})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// AnnotationRequest represents the payload for creating or updating an annotation.
+// log_id is only read on create.
+type AnnotationRequest struct {
+	LogID       int64  `json:"log_id"`
+	StartOffset int    `json:"start_offset"`
+	EndOffset   int    `json:"end_offset"`
+	Comment     string `json:"comment"`
+	Author      string `json:"author"`
+}
+
+// discussionAnnotation loads an annotation and checks that it belongs to the discussion
+func (h *DiscussionHandler) discussionAnnotation(c echo.Context, discussionID int64) (*models.DiscussionAnnotation, error) {
+	annotationID, err := strconv.ParseInt(c.Param("annotationId"), 10, 64)
+	if err != nil {
+		return nil, c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid annotation ID"})
+	}
+
+	annotation, err := h.db.GetDiscussionAnnotation(annotationID)
+	if err != nil || annotation.DiscussionID != discussionID {
+		return nil, c.JSON(http.StatusNotFound, map[string]string{"error": "Annotation not found"})
+	}
+	return annotation, nil
+}
+
+// GetAnnotations handles GET /api/discussions/:id/annotations
+func (h *DiscussionHandler) GetAnnotations(c echo.Context) error {
+	discussion, err := h.noteDiscussion(c)
+	if discussion == nil {
+		return err
+	}
+
+	annotations, err := h.db.GetDiscussionAnnotations(discussion.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get annotations: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, annotations)
+}
+
+// CreateAnnotation handles POST /api/discussions/:id/annotations
+func (h *DiscussionHandler) CreateAnnotation(c echo.Context) error {
+	discussion, err := h.noteDiscussion(c)
+	if discussion == nil {
+		return err
+	}
+
+	var req AnnotationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	logEntry, err := h.db.GetDiscussionLog(req.LogID)
+	if err != nil || logEntry.DiscussionID != discussion.ID {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "log_id does not belong to this discussion"})
+	}
+
+	annotation := models.DiscussionAnnotation{
+		DiscussionID: discussion.ID,
+		LogID:        logEntry.ID,
+		StartOffset:  req.StartOffset,
+		EndOffset:    req.EndOffset,
+		Comment:      req.Comment,
+		Author:       req.Author,
+	}
+	if err := annotation.Validate(logEntry.Content); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := h.db.InsertDiscussionAnnotation(&annotation); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to create annotation: %v", err)})
+	}
+
+	return c.JSON(http.StatusCreated, annotation)
+}
+
+// UpdateAnnotation handles PUT /api/discussions/:id/annotations/:annotationId
+func (h *DiscussionHandler) UpdateAnnotation(c echo.Context) error {
+	discussion, err := h.noteDiscussion(c)
+	if discussion == nil {
+		return err
+	}
+
+	annotation, err := h.discussionAnnotation(c, discussion.ID)
+	if annotation == nil {
+		return err
+	}
+
+	var req AnnotationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	logEntry, err := h.db.GetDiscussionLog(annotation.LogID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Annotated log entry not found"})
+	}
+
+	annotation.StartOffset = req.StartOffset
+	annotation.EndOffset = req.EndOffset
+	annotation.Comment = req.Comment
+	annotation.Author = req.Author
+	if err := annotation.Validate(logEntry.Content); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := h.db.UpdateDiscussionAnnotation(annotation); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to update annotation: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, annotation)
+}
+
+// DeleteAnnotation handles DELETE /api/discussions/:id/annotations/:annotationId
+func (h *DiscussionHandler) DeleteAnnotation(c echo.Context) error {
+	discussion, err := h.noteDiscussion(c)
+	if discussion == nil {
+		return err
+	}
+
+	annotation, err := h.discussionAnnotation(c, discussion.ID)
+	if annotation == nil {
+		return err
+	}
+
+	if err := h.db.DeleteDiscussionAnnotation(annotation.ID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to delete annotation: %v", err)})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RateLogRequest represents the request body for rating a turn
+type RateLogRequest struct {
+	Rating *int   `json:"rating"`
+	Note   string `json:"note"`
+}
+
+// GetLogTrace handles GET /api/discussions/:id/logs/:logId/trace, the
+// structured trace of the call that produced a log entry. Entries written
+// without tracing on have none and answer 404.
+func (h *DiscussionHandler) GetLogTrace(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid discussion ID"})
+	}
+	logID, err := strconv.ParseInt(c.Param("logId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid log ID"})
+	}
+
+	logEntry, err := h.db.GetDiscussionLog(logID)
+	if err != nil || logEntry.DiscussionID != id {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Log entry not found"})
+	}
+	encoded, ok := logEntry.Metadata["call_trace"]
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "No trace was recorded for this log entry; set trace on the agent or the discussion"})
+	}
+
+	var trace models.CallTrace
+	if err := json.Unmarshal([]byte(encoded), &trace); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to read trace: %v", err)})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"log_id": logEntry.ID,
+		"trace":  trace,
+	})
+}
+
+// RateLog handles POST /api/discussions/:id/logs/:logId/rate. Rating the same
+// turn again replaces the earlier rating.
+func (h *DiscussionHandler) RateLog(c echo.Context) error {
+	if !h.debateEngine.FeatureEnabled(orchestrator.FeatureVoting) {
+		return featureUnavailable(c, orchestrator.FeatureVoting)
+	}
+	discussion, err := h.noteDiscussion(c)
+	if discussion == nil {
+		return err
+	}
+
+	logID, err := strconv.ParseInt(c.Param("logId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid log ID"})
+	}
+
+	logEntry, err := h.db.GetDiscussionLog(logID)
+	if err != nil || logEntry.DiscussionID != discussion.ID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Log entry not found"})
+	}
+	if logEntry.IsSystem() || logEntry.LogType != models.LogTypeResponse {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Only agent responses can be rated"})
+	}
+
+	var req RateLogRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if req.Rating == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "rating is required"})
+	}
+
+	rating := models.LogRating{
+		LogID:        logEntry.ID,
+		DiscussionID: discussion.ID,
+		AgentID:      logEntry.AgentID,
+		Rating:       *req.Rating,
+		Note:         req.Note,
+	}
+	if err := rating.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := h.db.SetLogRating(&rating); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to save rating: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, rating)
+}
+
+// ExtractClaimsRequest represents the payload for starting a claim extraction
+type ExtractClaimsRequest struct {
+	AgentID *int64 `json:"agent_id"`
+}
+
+// ExtractClaims handles POST /api/discussions/:id/extract-claims. Claims are
+// extracted in a background job by the given agent, or the moderator.
+func (h *DiscussionHandler) ExtractClaims(c echo.Context) error {
+	discussion, err := h.noteDiscussion(c)
+	if discussion == nil {
+		return err
+	}
+
+	var req ExtractClaimsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	if discussion.InProgress() {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Discussion is still running"})
+	}
+
+	extractorID := req.AgentID
+	if extractorID == nil {
+		extractorID = discussion.ModeratorID
+	}
+	if extractorID == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "agent_id is required for discussions without a moderator"})
+	}
+	if _, err := h.db.GetAgent(*extractorID); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Extractor agent not found"})
+	}
+
+	id, agentID := discussion.ID, *extractorID
+	job := h.jobs.Start(orchestrator.JobTypeExtractClaims, id, func(ctx context.Context, progress jobs.Progress) error {
+		return h.debateEngine.ExtractClaims(ctx, id, agentID, progress)
+	})
+
+	return c.JSON(http.StatusAccepted, map[string]interface{}{
+		"status": "extracting",
+		"job_id": job.ID,
+	})
+}
+
+// GetClaims handles GET /api/discussions/:id/claims
+func (h *DiscussionHandler) GetClaims(c echo.Context) error {
+	discussion, err := h.noteDiscussion(c)
+	if discussion == nil {
+		return err
+	}
+
+	claims, err := h.db.GetDiscussionClaims(discussion.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get claims: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, claims)
+}
+
+// AnalyzeRequest represents the payload for starting a consensus analysis
+type AnalyzeRequest struct {
+	AgentID *int64 `json:"agent_id"`
+}
+
+// Analyze handles POST /api/discussions/:id/analyze. The analysis runs in a
+// background job by the given agent, or the moderator, or else the first
+// participant.
+func (h *DiscussionHandler) Analyze(c echo.Context) error {
+	discussion, err := h.noteDiscussion(c)
+	if discussion == nil {
+		return err
+	}
+
+	var req AnalyzeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	if discussion.InProgress() {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Discussion is still running"})
+	}
+
+	analystID := req.AgentID
+	if analystID == nil {
+		analystID = discussion.ModeratorID
+	}
+	if analystID == nil && len(discussion.AgentIDs) > 0 {
+		analystID = &discussion.AgentIDs[0]
+	}
+	if analystID == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "agent_id is required"})
+	}
+	if _, err := h.db.GetAgent(*analystID); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Analyst agent not found"})
+	}
+
+	id, agentID := discussion.ID, *analystID
+	job := h.jobs.Start(orchestrator.JobTypeAnalyze, id, func(ctx context.Context, progress jobs.Progress) error {
+		progress(0, 1)
+		if _, err := h.debateEngine.AnalyzeDiscussion(ctx, id, agentID); err != nil {
+			return err
+		}
+		progress(1, 1)
+		return nil
+	})
+
+	return c.JSON(http.StatusAccepted, map[string]interface{}{
+		"status": "analyzing",
+		"job_id": job.ID,
+	})
+}
+
+// GetAnalysis handles GET /api/discussions/:id/analysis
+func (h *DiscussionHandler) GetAnalysis(c echo.Context) error {
+	discussion, err := h.noteDiscussion(c)
+	if discussion == nil {
+		return err
+	}
+
+	analysis, err := h.db.GetDiscussionAnalysis(discussion.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get analysis: %v", err)})
+	}
+	if analysis == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Discussion has not been analyzed"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"analysis":         analysis,
+		"agreement_matrix": h.debateEngine.AnalysisMatrix(discussion, analysis),
+	})
+}
+
+// GetRound handles GET /api/discussions/:id/rounds/:n
+// It returns the entries of one round with their anchors, for embedding the
+// round elsewhere, and the moderator's summary of it when there is one. The
+// closing statements and remarks follow the last round but are not part of it.
+func (h *DiscussionHandler) GetRound(c echo.Context) error {
+	discussion, err := h.noteDiscussion(c)
+	if discussion == nil {
+		return err
+	}
+	round, err := strconv.Atoi(c.Param("n"))
+	if err != nil || round < 1 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Round must be a positive integer"})
+	}
+
+	logs, err := h.db.GetDiscussionLogs(discussion.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get discussion logs: %v", err)})
+	}
+	discussion.ApplyStances(logs)
+	anchors := export.ApplyAnchors(logs)
+
+	entries := []*models.DiscussionLog{}
+	var summary *models.DiscussionLog
+	for _, l := range logs {
+		if anchors[l.ID].Round != round || (l.IsModerator && l.Metadata["moderator_phase"] == "closing") || l.Metadata["phase"] == models.PhaseClosingStatement {
+			continue
+		}
+		entries = append(entries, l)
+		// A retried summary replaces the failed one
+		if l.IsModerator && l.Metadata["moderator_phase"] == "round_summary" && l.Status == "success" {
+			summary = l
+		}
+	}
+	if len(entries) == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": fmt.Sprintf("Round %d has no entries", round)})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"discussion_id": discussion.ID,
+		"round":         round,
+		"logs":          entries,
+		"summary":       summary,
+	})
+}
+
+// GetDiscussionCost handles GET /api/discussions/:id/cost
+func (h *DiscussionHandler) GetDiscussionCost(c echo.Context) error {
+	discussion, err := h.noteDiscussion(c)
+	if discussion == nil {
+		return err
+	}
+
+	logs, err := h.db.GetDiscussionLogs(discussion.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get discussion logs: %v", err)})
+	}
+
+	cost, err := h.estimateCosts(logs)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to estimate cost: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, cost)
+}
+
+// estimateCosts prices a discussion's calls with the stored model pricing
+func (h *DiscussionHandler) estimateCosts(logs []*models.DiscussionLog) (models.DiscussionCost, error) {
+	pricing, err := h.db.GetAllModelPricing()
+	if err != nil {
+		return models.DiscussionCost{}, err
+	}
+	agents, err := h.db.GetAllAgentsWithDeleted()
+	if err != nil {
+		return models.DiscussionCost{}, err
+	}
+
+	byID := make(map[int64]*models.Agent, len(agents))
+	for _, agent := range agents {
+		byID[agent.ID] = agent
+	}
+	return stats.EstimateCosts(logs, byID, pricing), nil
+}
+
+// TestWebhook handles POST /api/webhooks/test. It sends a sample signed
+// payload to the given webhook and returns the delivery result; a failed
+// delivery is still a 200 with the error in the result.
+func (h *DiscussionHandler) TestWebhook(c echo.Context) error {
+	if !h.debateEngine.FeatureEnabled(orchestrator.FeatureWebhooks) {
+		return featureUnavailable(c, orchestrator.FeatureWebhooks)
+	}
+	var hook models.DiscussionWebhook
+	if err := c.Bind(&hook); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := hook.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, h.debateEngine.TestWebhook(hook, nil))
+}
+
+// TestDiscussionWebhook handles POST /api/discussions/:id/webhook/test. It
+// sends a sample payload describing the discussion to its webhook.
+func (h *DiscussionHandler) TestDiscussionWebhook(c echo.Context) error {
+	if !h.debateEngine.FeatureEnabled(orchestrator.FeatureWebhooks) {
+		return featureUnavailable(c, orchestrator.FeatureWebhooks)
+	}
+	discussion, err := h.noteDiscussion(c)
+	if discussion == nil {
+		return err
+	}
+	if discussion.Settings.Webhook == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Discussion has no webhook"})
+	}
+
+	return c.JSON(http.StatusOK, h.debateEngine.TestWebhook(*discussion.Settings.Webhook, discussion))
+}
+
+// GetWebhookDeliveries handles GET /api/discussions/:id/webhook/deliveries
+func (h *DiscussionHandler) GetWebhookDeliveries(c echo.Context) error {
+	if !h.debateEngine.FeatureEnabled(orchestrator.FeatureWebhooks) {
+		return featureUnavailable(c, orchestrator.FeatureWebhooks)
+	}
+	discussion, err := h.noteDiscussion(c)
+	if discussion == nil {
+		return err
+	}
+
+	deliveries, err := h.db.GetWebhookDeliveries(discussion.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get webhook deliveries: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, deliveries)
+}
+
+// Number of items listed on the dashboard
+const (
+	dashboardRecentDiscussions = 5
+	dashboardAgents            = 6
+)
+
+// dashboardSummary gathers the dashboard counts without loading every discussion
+func dashboardSummary(db *database.DB) (*models.DashboardSummary, error) {
+	agentCount, err := db.CountAgents()
+	if err != nil {
+		return nil, err
+	}
+
+	agents, err := db.GetAllAgents()
+	if err != nil {
+		return nil, err
+	}
+	if len(agents) > dashboardAgents {
+		agents = agents[:dashboardAgents]
+	}
+
+	counts, err := db.CountDiscussionsByStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	recent, err := db.GetRecentDiscussions(dashboardRecentDiscussions)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &models.DashboardSummary{
+		AgentCount:        agentCount,
+		RunningCount:      counts["running"],
+		StatusCounts:      counts,
+		RecentDiscussions: recent,
+		Agents:            agents,
+	}
+	for _, n := range counts {
+		summary.DiscussionCount += n
+	}
+	return summary, nil
+}
+
+// GetDashboard handles GET /api/dashboard
+func (h *DiscussionHandler) GetDashboard(c echo.Context) error {
+	summary, err := dashboardSummary(h.db)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to load dashboard: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, summary)
+}
+
+// JobHandler handles background job endpoints
+type JobHandler struct {
+	jobs *jobs.Manager
+}
+
+func NewJobHandler(jobManager *jobs.Manager) *JobHandler {
+	return &JobHandler{jobs: jobManager}
+}
+
+// GetJob handles GET /api/jobs/:id
+func (h *JobHandler) GetJob(c echo.Context) error {
+	job, ok := h.jobs.Get(c.Param("id"))
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Job not found"})
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
+
+// AdminHandler handles administrative endpoints
+type AdminHandler struct {
+	db           *database.DB
+	debateEngine *orchestrator.DebateEngine
+}
+
+func NewAdminHandler(db *database.DB, debateEngine *orchestrator.DebateEngine) *AdminHandler {
+	return &AdminHandler{db: db, debateEngine: debateEngine}
+}
+
+// PauseProviderRequest represents the payload for pausing or unpausing providers.
+// PauseAll matches the key GET returns; All is accepted as its shorthand.
+type PauseProviderRequest struct {
+	ProviderType string `json:"provider_type"`
+	Host         string `json:"host"`
+	PauseAll     bool   `json:"pause_all"`
+	All          bool   `json:"all"`
+	Paused       bool   `json:"paused"`
+}
+
+// GetProviderPauses handles GET /api/admin/pause-provider
+func (h *AdminHandler) GetProviderPauses(c echo.Context) error {
+	pauses := models.ProviderPauses{ProviderTypes: []string{}, Hosts: []string{}}
+	if _, err := h.db.GetSettingJSON(database.SettingProviderPauses, &pauses); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get provider pauses: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, pauses)
+}
+
+// PauseProvider handles POST /api/admin/pause-provider
+func (h *AdminHandler) PauseProvider(c echo.Context) error {
+	var req PauseProviderRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	req.ProviderType = strings.ToLower(strings.TrimSpace(req.ProviderType))
+	req.Host = strings.ToLower(strings.TrimSpace(req.Host))
+	req.PauseAll = req.PauseAll || req.All
+	if !req.PauseAll && req.ProviderType == "" && req.Host == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "one of provider_type, host, or pause_all is required"})
+	}
+
+	pauses := models.ProviderPauses{ProviderTypes: []string{}, Hosts: []string{}}
+	if _, err := h.db.GetSettingJSON(database.SettingProviderPauses, &pauses); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get provider pauses: %v", err)})
+	}
+
+	if req.PauseAll {
+		pauses.PauseAll = req.Paused
+	}
+	if req.ProviderType != "" {
+		pauses.ProviderTypes = togglePause(pauses.ProviderTypes, req.ProviderType, req.Paused)
+	}
+	if req.Host != "" {
+		pauses.Hosts = togglePause(pauses.Hosts, req.Host, req.Paused)
+	}
+
+	if err := h.db.SetSettingJSON(database.SettingProviderPauses, pauses); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to save provider pauses: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, pauses)
+}
+
+// GetDebates handles GET /api/admin/debates
+func (h *AdminHandler) GetDebates(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.debateEngine.RunningDebates())
+}
+
+// GetWatchdog handles GET /api/admin/watchdog
+func (h *AdminHandler) GetWatchdog(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.debateEngine.WatchdogConfig())
+}
+
+// UpdateWatchdog handles PUT /api/admin/watchdog
+func (h *AdminHandler) UpdateWatchdog(c echo.Context) error {
+	var cfg models.WatchdogConfig
+	if err := c.Bind(&cfg); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := h.db.SetSettingJSON(database.SettingWatchdog, cfg); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to save watchdog settings: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, cfg)
+}
+
+// GetSlowCall handles GET /api/admin/slow-call
+func (h *AdminHandler) GetSlowCall(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.debateEngine.SlowCallConfig())
+}
+
+// UpdateSlowCall handles PUT /api/admin/slow-call
+func (h *AdminHandler) UpdateSlowCall(c echo.Context) error {
+	var cfg models.SlowCallConfig
+	if err := c.Bind(&cfg); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := h.db.SetSettingJSON(database.SettingSlowCall, cfg); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to save slow-call settings: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, cfg)
+}
+
+// GetDeltaCoalescing handles GET /api/admin/delta-coalescing
+func (h *AdminHandler) GetDeltaCoalescing(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.debateEngine.DeltaCoalescingConfig())
+}
+
+// UpdateDeltaCoalescing handles PUT /api/admin/delta-coalescing
+func (h *AdminHandler) UpdateDeltaCoalescing(c echo.Context) error {
+	var cfg models.DeltaCoalescingConfig
+	if err := c.Bind(&cfg); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := h.db.SetSettingJSON(database.SettingDeltaCoalescing, cfg); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to save delta coalescing settings: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, cfg)
+}
+
+// GetSubscribers handles GET /api/admin/subscribers
+func (h *AdminHandler) GetSubscribers(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.debateEngine.SubscriberStats())
+}
+
+// UpdateSubscriberLimits handles PUT /api/admin/subscribers
+func (h *AdminHandler) UpdateSubscriberLimits(c echo.Context) error {
+	var cfg models.SubscriberLimitsConfig
+	if err := c.Bind(&cfg); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := h.db.SetSettingJSON(database.SettingSubscriberLimits, cfg); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to save subscriber limits: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, h.debateEngine.SubscriberStats())
+}
+
+// GetHostLimits handles GET /api/admin/host-limits
+func (h *AdminHandler) GetHostLimits(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.debateEngine.HostLimits())
+}
+
+// UpdateHostLimits handles PUT /api/admin/host-limits
+func (h *AdminHandler) UpdateHostLimits(c echo.Context) error {
+	var cfg models.HostLimitsConfig
+	if err := c.Bind(&cfg); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := h.db.SetSettingJSON(database.SettingHostLimits, cfg); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to save host limits: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, cfg)
+}
+
+// GetAgentRetries handles GET /api/admin/retries
+func (h *AdminHandler) GetAgentRetries(c echo.Context) error {
+	cfg, err := h.db.GetAgentRetryConfig()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get retry settings: %v", err)})
+	}
+	return c.JSON(http.StatusOK, cfg)
+}
+
+// UpdateAgentRetries handles PUT /api/admin/retries
+func (h *AdminHandler) UpdateAgentRetries(c echo.Context) error {
+	var cfg models.AgentRetryConfig
+	if err := c.Bind(&cfg); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := h.db.SetSettingJSON(database.SettingAgentRetries, cfg); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to save retry settings: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, cfg)
+}
+
+// GetAlertRules handles GET /api/admin/alerts
+func (h *AdminHandler) GetAlertRules(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.debateEngine.AlertRules())
+}
+
+// UpdateAlertRules handles PUT /api/admin/alerts
+func (h *AdminHandler) UpdateAlertRules(c echo.Context) error {
+	var cfg models.AlertRulesConfig
+	if err := c.Bind(&cfg); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := h.db.SetSettingJSON(database.SettingAlertRules, cfg); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to save alert rules: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, cfg)
+}
+
+// Limits for GET /api/alerts
+const (
+	defaultAlertLimit = 50
+	maxAlertLimit     = 500
+)
+
+// GetAlerts handles GET /api/alerts. The optional state (firing or resolved)
+// and limit query parameters narrow the list, which is newest first.
+func (h *AdminHandler) GetAlerts(c echo.Context) error {
+	state := c.QueryParam("state")
+	if state != "" && state != models.AlertStateFiring && state != models.AlertStateResolved {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "state must be firing or resolved"})
+	}
+
+	limit := defaultAlertLimit
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAlertLimit {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("limit must be between 1 and %d", maxAlertLimit)})
+		}
+		limit = n
+	}
+
+	alerts, err := h.db.GetAlerts(state, limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get alerts: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, alerts)
+}
+
+// RunSelfTest handles POST /api/admin/selftest. It answers 200 when the
+// self-test debate passed and 503 with the failing stage otherwise, so
+// uptime monitors can alert on the status code alone.
+func (h *AdminHandler) RunSelfTest(c echo.Context) error {
+	report := h.debateEngine.SelfTest(c.Request().Context())
+	if !report.Passed {
+		return c.JSON(http.StatusServiceUnavailable, report)
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+// GetDuplicateGuard handles GET /api/admin/duplicate-guard
+func (h *AdminHandler) GetDuplicateGuard(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.debateEngine.DuplicateGuardConfig())
+}
+
+// UpdateDuplicateGuard handles PUT /api/admin/duplicate-guard
+func (h *AdminHandler) UpdateDuplicateGuard(c echo.Context) error {
+	var cfg models.DuplicateGuardConfig
+	if err := c.Bind(&cfg); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := h.db.SetSettingJSON(database.SettingDuplicateGuard, cfg); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to save duplicate guard settings: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, cfg)
+}
+
+// GetTranscriptLog handles GET /api/admin/transcript-log
+func (h *AdminHandler) GetTranscriptLog(c echo.Context) error {
+	cfg, err := h.db.GetTranscriptLogConfig()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get transcript log settings: %v", err)})
+	}
+	return c.JSON(http.StatusOK, cfg)
+}
+
+// UpdateTranscriptLog handles PUT /api/admin/transcript-log
+func (h *AdminHandler) UpdateTranscriptLog(c echo.Context) error {
+	var cfg models.TranscriptLogConfig
+	if err := c.Bind(&cfg); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := h.db.SetSettingJSON(database.SettingTranscriptLog, cfg); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to save transcript log settings: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, cfg)
+}
+
+// GetAgentTimeouts handles GET /api/admin/timeouts
+func (h *AdminHandler) GetAgentTimeouts(c echo.Context) error {
+	timeouts, err := h.db.GetAgentTimeouts()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get timeout settings: %v", err)})
+	}
+	return c.JSON(http.StatusOK, timeouts)
+}
+
+// UpdateAgentTimeouts handles PUT /api/admin/timeouts. Omitted fields keep
+// their current values.
+func (h *AdminHandler) UpdateAgentTimeouts(c echo.Context) error {
+	timeouts, err := h.db.GetAgentTimeouts()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get timeout settings: %v", err)})
+	}
+
+	if err := c.Bind(&timeouts); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	if err := timeouts.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	values := map[string]int{
+		database.SettingDefaultAgentTimeout: timeouts.DefaultSeconds,
+		database.SettingMaxAgentTimeout:     timeouts.MaxSeconds,
+		database.SettingAgentTimeoutBuffer:  timeouts.BufferSeconds,
+	}
+	for key, value := range values {
+		if err := h.db.SetSetting(key, strconv.Itoa(value)); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to save timeout settings: %v", err)})
+		}
+	}
+
+	return c.JSON(http.StatusOK, timeouts)
+}
+
+// togglePause adds or removes value from a pause list
+func togglePause(list []string, value string, paused bool) []string {
+	result := []string{}
+	for _, v := range list {
+		if !strings.EqualFold(v, value) {
+			result = append(result, v)
+		}
+	}
+	if paused {
+		result = append(result, value)
+	}
+	return result
+}
+
+// ConfigHandler handles export and import of instance configuration.
+// Only settings are covered; agents and discussions are never exported here.
+type ConfigHandler struct {
+	db *database.DB
+}
+
+func NewConfigHandler(db *database.DB) *ConfigHandler {
+	return &ConfigHandler{db: db}
+}
+
+// ConfigExport is the document produced by GET /api/export/config
+type ConfigExport struct {
+	AppVersion string                     `json:"app_version"`
+	Settings   map[string]json.RawMessage `json:"settings"`
+}
+
+// ConfigChange describes what an import did, or would do, to one setting
+type ConfigChange struct {
+	Section string `json:"section"`
+	Key     string `json:"key"`
+	Action  string `json:"action"` // create, update, unchanged, skip
+}
+
+// settingValidators decode and check each importable setting
+var settingValidators = map[string]func(raw json.RawMessage) error{
+	database.SettingProviderPauses: func(raw json.RawMessage) error {
+		var pauses models.ProviderPauses
+		return json.Unmarshal(raw, &pauses)
+	},
+	database.SettingDefaultAgentTimeout: validateIntSetting(1, models.MaxAgentTimeoutCeilingSeconds),
+	database.SettingMaxAgentTimeout:     validateIntSetting(1, models.MaxAgentTimeoutCeilingSeconds),
+	database.SettingAgentTimeoutBuffer:  validateIntSetting(0, 300),
+	database.SettingWatchdog: func(raw json.RawMessage) error {
+		var cfg models.WatchdogConfig
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return err
+		}
+		return cfg.Validate()
+	},
+	database.SettingDuplicateGuard: func(raw json.RawMessage) error {
+		var cfg models.DuplicateGuardConfig
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return err
+		}
+		return cfg.Validate()
+	},
+	database.SettingDeltaCoalescing: func(raw json.RawMessage) error {
+		var cfg models.DeltaCoalescingConfig
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return err
+		}
+		return cfg.Validate()
+	},
+	database.SettingSubscriberLimits: func(raw json.RawMessage) error {
+		var cfg models.SubscriberLimitsConfig
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return err
+		}
+		return cfg.Validate()
+	},
+	database.SettingHostLimits: func(raw json.RawMessage) error {
+		var cfg models.HostLimitsConfig
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return err
+		}
+		return cfg.Validate()
+	},
+	database.SettingAgentRetries: func(raw json.RawMessage) error {
+		var cfg models.AgentRetryConfig
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return err
+		}
+		return cfg.Validate()
+	},
+	database.SettingAlertRules: func(raw json.RawMessage) error {
+		var cfg models.AlertRulesConfig
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return err
+		}
+		return cfg.Validate()
+	},
+	database.SettingSlowCall: func(raw json.RawMessage) error {
+		var cfg models.SlowCallConfig
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return err
+		}
+		return cfg.Validate()
+	},
+	database.SettingTranscriptLog: func(raw json.RawMessage) error {
+		var cfg models.TranscriptLogConfig
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return err
+		}
+		return cfg.Validate()
+	},
+}
+
+// validateIntSetting checks that a setting is an integer within [min, max]
+func validateIntSetting(min, max int) func(raw json.RawMessage) error {
+	return func(raw json.RawMessage) error {
+		var n int
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return err
+		}
+		if n < min || n > max {
+			return fmt.Errorf("must be between %d and %d", min, max)
+		}
+		return nil
+	}
+}
+
+// ExportConfig handles GET /api/export/config
+func (h *ConfigHandler) ExportConfig(c echo.Context) error {
+	settings, err := h.db.GetAllSettings()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get settings: %v", err)})
+	}
+
+	export := ConfigExport{
+		AppVersion: version.Version,
+		Settings:   make(map[string]json.RawMessage, len(settings)),
+	}
+	for key, value := range settings {
+		if _, ok := settingValidators[key]; ok {
+			export.Settings[key] = json.RawMessage(value)
+		}
+	}
+
+	return c.JSON(http.StatusOK, export)
+}
+
+// ImportConfig handles POST /api/export/config?dry_run=true&overwrite=true
+func (h *ConfigHandler) ImportConfig(c echo.Context) error {
+	var doc ConfigExport
+	if err := c.Bind(&doc); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	dryRun := c.QueryParam("dry_run") == "true"
+	overwrite := c.QueryParam("overwrite") == "true"
+
+	// Validate everything before applying anything
+	for key, raw := range doc.Settings {
+		validate, ok := settingValidators[key]
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unknown setting %q", key)})
+		}
+		if err := validate(raw); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid setting %q: %v", key, err)})
+		}
+	}
+
+	current, err := h.db.GetAllSettings()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get settings: %v", err)})
+	}
+
+	keys := make([]string, 0, len(doc.Settings))
+	for key := range doc.Settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	changes := []ConfigChange{}
+	for _, key := range keys {
+		value := string(doc.Settings[key])
+		change := ConfigChange{Section: "settings", Key: key, Action: "create"}
+		if existing, ok := current[key]; ok {
+			switch {
+			case existing == value:
+				change.Action = "unchanged"
+			case overwrite:
+				change.Action = "update"
+			default:
+				change.Action = "skip"
+			}
+		}
+		changes = append(changes, change)
+
+		if dryRun || (change.Action != "create" && change.Action != "update") {
+			continue
+		}
+		if err := h.db.SetSetting(key, value); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to save setting %s: %v", key, err)})
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"dry_run": dryRun,
+		"changes": changes,
+	})
+}
+
+// SystemHandler handles server information endpoints
+type SystemHandler struct {
+	db           *database.DB
+	debateEngine *orchestrator.DebateEngine
+}
+
+func NewSystemHandler(db *database.DB, debateEngine *orchestrator.DebateEngine) *SystemHandler {
+	return &SystemHandler{db: db, debateEngine: debateEngine}
+}
+
+// GetCapabilities handles GET /api/capabilities. It lists the optional
+// features of this server, with whether each is on, and its limits.
+func (h *SystemHandler) GetCapabilities(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.debateEngine.Capabilities())
+}
+
+// featureUnavailable answers a request for an optional feature this server
+// does not offer or has turned off
+func featureUnavailable(c echo.Context, feature string) error {
+	return c.JSON(http.StatusNotImplemented, map[string]string{"error": fmt.Sprintf("Feature %q is not enabled on this server", feature)})
+}
+
+// GetVersion handles GET /api/version
+func (h *SystemHandler) GetVersion(c echo.Context) error {
+	schemaVersion, err := h.db.SchemaVersion()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get schema version: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"version":        version.Version,
+		"schema_version": schemaVersion,
+	})
+}
+
+// DefaultSSEHeartbeat is how often an idle SSE stream sends a keepalive
+// comment, well inside the 60 second idle timeout of common proxies
+const DefaultSSEHeartbeat = 20 * time.Second
+
+// SSEHandler handles Server-Sent Events for real-time updates
+type SSEHandler struct {
+	db          *database.DB
+	debateEngine *orchestrator.DebateEngine
+
+	// HeartbeatInterval is how often SSE streams write a keepalive comment
+	// while waiting for events, so proxies do not close them during a long
+	// agent call. Zero turns heartbeats off.
+	HeartbeatInterval time.Duration
+}
+
+func NewSSEHandler(db *database.DB, debateEngine *orchestrator.DebateEngine) *SSEHandler {
+	return &SSEHandler{
+		db:                db,
+		debateEngine:      debateEngine,
+		HeartbeatInterval: DefaultSSEHeartbeat,
+	}
+}
+
+// StreamDiscussion handles GET /api/discussions/:id/stream. The stream opens
+// with the current discussion and then relays every log entry and update
+// until the discussion stops running or the client disconnects. A client
+// reconnecting with the Last-Event-ID header, or the last_event_id query
+// parameter, first gets the log entries it missed from the database, so it
+// catches up even when the server restarted in between.
+func (h *SSEHandler) StreamDiscussion(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid discussion ID"})
+	}
+	if discussion, err := h.db.GetDiscussion(id); err != nil || discussion.Status == "deleting" {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Discussion not found"})
+	}
+	updateChan, err := h.debateEngine.Subscribe(id)
+	if err != nil {
+		return subscribeError(c, err)
+	}
+	defer h.debateEngine.Unsubscribe(id, updateChan)
+
+	// Set SSE headers
+	c.Response().Header().Set("Content-Type", "text/event-stream")
+	c.Response().Header().Set("Cache-Control", "no-cache")
+	c.Response().Header().Set("Connection", "keep-alive")
+	c.Response().Header().Set("Access-Control-Allow-Origin", "*")
+
+	lastEventID := c.Request().Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.QueryParam("last_event_id")
+	}
+	send := func(id string, eventType string, data interface{}) error {
+		return h.sendSSEEvent(c.Response(), id, eventType, data)
+	}
+	h.streamDiscussion(c.Request().Context(), id, updateChan, lastEventID, send, h.sseHeartbeat(c.Response()))
+	return nil
+}
+
+// subscribeError answers a stream request the engine would not subscribe,
+// with 429 when the discussion has too many subscribers already
+func subscribeError(c echo.Context, err error) error {
+	if errors.Is(err, orchestrator.ErrTooManySubscribers) {
+		return c.JSON(http.StatusTooManyRequests, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to subscribe: %v", err)})
+}
+
+// frameSender writes one event of a discussion stream to its client. id is
+// empty for events without an identity.
+type frameSender func(id string, eventType string, data interface{}) error
+
+// streamDiscussion runs a discussion stream over any transport: a "status"
+// event, the log entries missed since lastEventID, the current discussion,
+// and then every log entry and update until the discussion stops running,
+// ctx is done, send fails or the engine drops the subscription. SSE and
+// WebSocket streams both go through it so they send the same events with the
+// same ids and payloads. updateChan is the caller's subscription to the
+// discussion. heartbeat, when not nil, is called every HeartbeatInterval
+// while waiting for events.
+func (h *SSEHandler) streamDiscussion(ctx context.Context, id int64, updateChan chan orchestrator.Event, lastEventID string, send frameSender, heartbeat func() error) {
+	// Initial status message
+	if err := send("", "status", map[string]string{"message": "Streaming started"}); err != nil {
+		return
+	}
+
+	// Read the discussion only after subscribing so an update made in
+	// between is not lost. Events queued before the revision was taken are
+	// already part of what is read. A discussion that already ended has
+	// nothing more to stream; closing lets the client stop instead of
+	// reconnecting.
+	dedupe := newSSEDedupe(h.debateEngine.Revision())
+	discussion, err := h.db.GetDiscussion(id)
+	if err != nil {
+		return
+	}
+
+	// cursor is the last log entry the client has; discussion frames carry
+	// it so a client whose last frame was an update can still resume
+	cursor, resuming := parseSSECursor(lastEventID)
+	if resuming {
+		missed, err := h.db.GetDiscussionLogsAfter(id, cursor)
+		if err != nil {
+			return
+		}
+		for _, l := range missed {
+			event := orchestrator.Event{Type: orchestrator.EventLog, Data: *l}
+			if !dedupe.allow(event) {
+				continue
+			}
+			if err := send(event.ID(), event.Type, h.logFrame(*l)); err != nil {
+				return
+			}
+			cursor = l.ID
+		}
+	} else if cursor, err = h.db.LastDiscussionLogID(id); err != nil {
+		return
+	}
+
+	initial := orchestrator.Event{Type: orchestrator.EventDiscussion, Revision: dedupe.revision}
+	if err := send(sseFrameID(initial, cursor), initial.Type, discussion); err != nil || !discussion.InProgress() {
+		return
+	}
+	status := discussion.Status
+
+	ticks, stop := h.heartbeatTicks(heartbeat)
+	defer stop()
+
+	// Listen for updates or disconnection
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticks:
+			if err := heartbeat(); err != nil {
+				return
+			}
+		case update, ok := <-updateChan:
+			if !ok {
+				return
+			}
+			if !dedupe.allow(update) {
+				continue
+			}
+			data := update.Data
+			if v, isLog := data.(models.DiscussionLog); isLog {
+				data = h.logFrame(v)
+				cursor = v.ID
+			}
+			if err := send(sseFrameID(update, cursor), update.Type, data); err != nil {
+				return
+			}
+			d, ok := update.Data.(models.Discussion)
+			if !ok {
+				continue
+			}
+			// Pausing and resuming keep the stream open
+			if d.Status != status && d.InProgress() {
+				message := "Discussion resumed"
+				if d.Status == "paused" {
+					message = "Discussion paused"
+				}
+				if err := send("", "status", map[string]string{"message": message, "status": d.Status}); err != nil {
+					return
+				}
+			}
+			status = d.Status
+			// The engine announces the final status last
+			if !d.InProgress() {
+				return
+			}
+		}
+	}
+}
+
+// Keepalive timing of WebSocket streams
+const (
+	wsPingInterval = 30 * time.Second
+	wsWriteTimeout = 10 * time.Second
+)
+
+// wsFrame is one event of a WebSocket stream, the SSE id, event and data
+// lines of the same event as a JSON object
+type wsFrame struct {
+	ID    string      `json:"id,omitempty"`
+	Event string      `json:"event"`
+	Data  interface{} `json:"data"`
+}
+
+// WebSocketDiscussion handles GET /api/discussions/:id/ws, the discussion
+// stream over a WebSocket for networks whose proxies buffer SSE. Each text
+// message is a JSON object with the event's "event" name, its "data" and,
+// when it has one, its "id"; the events are those of the SSE stream. The
+// last_event_id query parameter resumes like it does there. The server pings
+// every 30 seconds and closes the socket once the discussion stops running.
+func (h *SSEHandler) WebSocketDiscussion(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid discussion ID"})
+	}
+	if discussion, err := h.db.GetDiscussion(id); err != nil || discussion.Status == "deleting" {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Discussion not found"})
+	}
+	updateChan, err := h.debateEngine.Subscribe(id)
+	if err != nil {
+		return subscribeError(c, err)
+	}
+	defer h.debateEngine.Unsubscribe(id, updateChan)
+
+	// Like the SSE stream, any origin may connect
+	server := websocket.Server{Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+		ctx, cancel := context.WithCancel(c.Request().Context())
+		defer cancel()
+
+		// Writes come from the stream and the pinger
+		var writeMu sync.Mutex
+		write := func(payloadType byte, msg []byte) error {
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			ws.PayloadType = payloadType
+			ws.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			_, err := ws.Write(msg)
+			return err
+		}
+
+		// Reading answers the client's pings and notices when it goes away;
+		// messages from the client are ignored
+		go func() {
+			defer cancel()
+			for {
+				var msg []byte
+				if err := websocket.Message.Receive(ws, &msg); err != nil {
+					return
+				}
+			}
+		}()
+
+		// A ping that cannot be written within the timeout drops the client
+		go func() {
+			ticker := time.NewTicker(wsPingInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if err := write(websocket.PingFrame, nil); err != nil {
+						cancel()
+						return
+					}
+				}
+			}
+		}()
+
+		send := func(id string, eventType string, data interface{}) error {
+			msg, err := json.Marshal(wsFrame{ID: id, Event: eventType, Data: data})
+			if err != nil {
+				return err
+			}
+			return write(websocket.TextFrame, msg)
+		}
+		h.streamDiscussion(ctx, id, updateChan, c.QueryParam("last_event_id"), send, nil)
+	}}
+	server.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
+
+// logFrame adds the agent's name and initial to a log entry for the UI
+func (h *SSEHandler) logFrame(l models.DiscussionLog) map[string]interface{} {
+	var agentName string
+	if !l.IsSystem() {
+		agentName, _ = h.db.GetAgentName(l.AgentID)
+	}
+	initial := "A"
+	name := "Unknown Agent"
+	if l.IsSystem() {
+		initial = "S"
+		name = "System"
+	} else if agentName != "" {
+		name = agentName
+		if len(name) > 0 {
+			runes := []rune(name)
+			initial = strings.ToUpper(string(runes[0]))
+		}
+	}
+	return map[string]interface{}{
+		"log": l,
+		"agent": map[string]interface{}{
+			"name":    name,
+			"initial": initial,
+		},
+	}
+}
+
+// sseFrameID is the SSE id of a frame. Discussion frames add the stream's
+// log cursor, "rev-<revision>:log-<log ID>", so the id a client reconnects
+// with always says which log entries it has.
+func sseFrameID(event orchestrator.Event, cursor int64) string {
+	id := event.ID()
+	if event.Type == orchestrator.EventDiscussion && cursor > 0 {
+		id += ":log-" + strconv.FormatInt(cursor, 10)
+	}
+	return id
+}
+
+// parseSSECursor reads the log cursor from the id a client reconnects with,
+// "log-<log ID>" or "rev-<revision>:log-<log ID>". An id without one, like
+// the "rev-<revision>" of a discussion sent before any log, resumes from the
+// start of the discussion.
+func parseSSECursor(lastEventID string) (int64, bool) {
+	if lastEventID == "" {
+		return 0, false
+	}
+	i := strings.LastIndex(lastEventID, "log-")
+	if i < 0 {
+		return 0, true
+	}
+	cursor, err := strconv.ParseInt(lastEventID[i+len("log-"):], 10, 64)
+	if err != nil || cursor < 0 {
+		return 0, true
+	}
+	return cursor, true
+}
+
+// StreamEvents handles GET /api/events
+func (h *SSEHandler) StreamEvents(c echo.Context) error {
+	// Set SSE headers
+	c.Response().Header().Set("Content-Type", "text/event-stream")
+	c.Response().Header().Set("Cache-Control", "no-cache")
+	c.Response().Header().Set("Connection", "keep-alive")
+	c.Response().Header().Set("Access-Control-Allow-Origin", "*")
+
+	// Subscribe to engine-wide events
+	eventChan := h.debateEngine.SubscribeGlobal()
+	defer h.debateEngine.UnsubscribeGlobal(eventChan)
+
+	ctx := c.Request().Context()
+
+	h.sendSSEUpdate(c.Response(), "status", map[string]string{"message": "Streaming started"})
+
+	heartbeat := h.sseHeartbeat(c.Response())
+	ticks, stop := h.heartbeatTicks(heartbeat)
+	defer stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticks:
+			if err := heartbeat(); err != nil {
+				return nil
+			}
+		case event := <-eventChan:
+			if err := h.sendSSEUpdate(c.Response(), event.Type, event.Data); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// sseHeartbeat returns a function writing a keepalive comment, which
+// EventSource ignores, or nil when heartbeats are off
+func (h *SSEHandler) sseHeartbeat(resp *echo.Response) func() error {
+	if h.HeartbeatInterval <= 0 {
+		return nil
+	}
+	return func() error {
+		_, err := fmt.Fprint(resp, ": keepalive\n\n")
+		resp.Flush()
+		return err
+	}
+}
+
+// heartbeatTicks returns a channel ticking every HeartbeatInterval and the
+// function stopping it. Without a heartbeat the channel never ticks.
+func (h *SSEHandler) heartbeatTicks(heartbeat func() error) (<-chan time.Time, func()) {
+	if heartbeat == nil || h.HeartbeatInterval <= 0 {
+		return nil, func() {}
+	}
+	ticker := time.NewTicker(h.HeartbeatInterval)
+	return ticker.C, ticker.Stop
+}
+
+func (h *SSEHandler) sendSSEUpdate(resp *echo.Response, eventType string, data interface{}) error {
+	return h.sendSSEEvent(resp, "", eventType, data)
+}
+
+// sendSSEEvent writes one frame, with an id line when the event has an identity
+func (h *SSEHandler) sendSSEEvent(resp *echo.Response, id string, eventType string, data interface{}) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if id != "" {
+		_, err = fmt.Fprintf(resp, "id: %s\nevent: %s\ndata: %s\n\n", id, eventType, string(jsonData))
+	} else {
+		_, err = fmt.Fprintf(resp, "event: %s\ndata: %s\n\n", eventType, string(jsonData))
+	}
+	resp.Flush()
+	return err
+}
+
+// sseDedupe tracks what one stream connection has sent so an event delivered
+// twice, e.g. by a replay from the database racing the live channel, goes
+// out once. Log entries are identified by ID. Discussion updates carry a
+// revision, and one at or below the last revision sent is a duplicate or an
+// older state.
+type sseDedupe struct {
+	logs     map[int64]bool
+	revision int64
+}
+
+func newSSEDedupe(revision int64) *sseDedupe {
+	return &sseDedupe{logs: make(map[int64]bool), revision: revision}
+}
+
+// allow reports whether event should be sent and records it as sent
+func (d *sseDedupe) allow(event orchestrator.Event) bool {
+	switch event.Type {
+	case orchestrator.EventLog:
+		l, ok := event.Data.(models.DiscussionLog)
+		if !ok {
+			return true
+		}
+		if d.logs[l.ID] {
+			return false
+		}
+		d.logs[l.ID] = true
+	case orchestrator.EventDiscussion:
+		if event.Revision <= d.revision {
+			return false
+		}
+		d.revision = event.Revision
+	}
+	return true
+}
+
+// Page handlers for serving HTML
+type PageHandler struct {
+	db *database.DB
+}
+
+func NewPageHandler(db *database.DB) *PageHandler {
+	return &PageHandler{db: db}
+}
+
+// Dashboard handles GET /
+func (h *PageHandler) Dashboard(c echo.Context) error {
+	summary, err := dashboardSummary(h.db)
+	if err != nil {
+		fmt.Printf("Error loading dashboard: %v\n", err)
+		return c.HTML(http.StatusInternalServerError, "<h1>Error loading dashboard</h1>")
+	}
+
+	return c.Render(http.StatusOK, "dashboard.html", summary)
 }
 
 // AgentsPage handles GET /agents
@@ -486,7 +3041,12 @@ func (h *PageHandler) AgentsPage(c echo.Context) error {
 
 // DiscussionsPage handles GET /discussions
 func (h *PageHandler) DiscussionsPage(c echo.Context) error {
-	discussions, err := h.db.GetAllDiscussions()
+	q, err := parseDiscussionQuery(c)
+	if err != nil {
+		return c.HTML(http.StatusBadRequest, "<h1>Invalid discussion list parameters</h1>")
+	}
+
+	page, err := h.db.GetDiscussionsPage(q)
 	if err != nil {
 		return c.HTML(http.StatusInternalServerError, "<h1>Error loading discussions</h1>")
 	}
@@ -497,8 +3057,16 @@ func (h *PageHandler) DiscussionsPage(c echo.Context) error {
 	}
 
 	data := map[string]interface{}{
-		"Discussions": discussions,
+		"Discussions": page.Items,
 		"Agents":      agents,
+		"Page":        page,
+		"Presets":     models.Presets(),
+	}
+	if page.Page > 1 {
+		data["PrevURL"] = "/discussions?" + q.Encode(page.Page-1)
+	}
+	if page.Page < page.Pages() {
+		data["NextURL"] = "/discussions?" + q.Encode(page.Page+1)
 	}
 
 	return c.Render(http.StatusOK, "discussions.html", data)
@@ -523,6 +3091,8 @@ func (h *PageHandler) DiscussionDetail(c echo.Context) error {
 		fmt.Printf("Error fetching logs for discussion %d: %v\n", id, err)
 		return c.HTML(http.StatusInternalServerError, "<h1>Error loading discussion logs</h1>")
 	}
+	discussion.ApplyStances(logs)
+	anchors := export.ApplyAnchors(logs)
 
 	agents, err := h.db.GetAllAgents()
 	if err != nil {
@@ -530,10 +3100,40 @@ func (h *PageHandler) DiscussionDetail(c echo.Context) error {
 		return c.HTML(http.StatusInternalServerError, "<h1>Error loading agents</h1>")
 	}
 
+	claims, err := h.db.GetDiscussionClaims(id)
+	if err != nil {
+		fmt.Printf("Error fetching claims for discussion %d: %v\n", id, err)
+		return c.HTML(http.StatusInternalServerError, "<h1>Error loading claims</h1>")
+	}
+
+	annotations, err := h.db.GetDiscussionAnnotations(id)
+	if err != nil {
+		fmt.Printf("Error fetching annotations for discussion %d: %v\n", id, err)
+		return c.HTML(http.StatusInternalServerError, "<h1>Error loading annotations</h1>")
+	}
+	annotationsByLog := make(map[int64][]*models.DiscussionAnnotation)
+	for _, a := range annotations {
+		annotationsByLog[a.LogID] = append(annotationsByLog[a.LogID], a)
+	}
+
+	ratings, err := h.db.GetDiscussionRatings(id)
+	if err != nil {
+		fmt.Printf("Error fetching ratings for discussion %d: %v\n", id, err)
+		return c.HTML(http.StatusInternalServerError, "<h1>Error loading ratings</h1>")
+	}
+	ratingsByLog := make(map[int64]int)
+	for _, r := range ratings {
+		ratingsByLog[r.LogID] = r.Rating
+	}
+
 	data := map[string]interface{}{
-		"Discussion": discussion,
-		"Logs":       logs,
-		"Agents":     agents,
+		"Discussion":  discussion,
+		"Logs":        logs,
+		"Contents":    export.Contents(logs, anchors),
+		"Agents":      agents,
+		"Claims":      claims,
+		"Annotations": annotationsByLog,
+		"Ratings":     ratingsByLog,
 	}
 
 	err = c.Render(http.StatusOK, "discussion_detail.html", data)
@@ -543,3 +3143,126 @@ func (h *PageHandler) DiscussionDetail(c echo.Context) error {
 	}
 	return nil
 }
+
+// DiscussionReplay handles GET /discussions/:id/replay
+func (h *PageHandler) DiscussionReplay(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.HTML(http.StatusBadRequest, "<h1>Invalid discussion ID</h1>")
+	}
+
+	discussion, err := h.db.GetDiscussion(id)
+	if err != nil {
+		return c.HTML(http.StatusNotFound, "<h1>Discussion not found</h1>")
+	}
+
+	count, err := h.db.CountDiscussionLogs(id)
+	if err != nil {
+		fmt.Printf("Error counting logs for discussion %d: %v\n", id, err)
+		return c.HTML(http.StatusInternalServerError, "<h1>Error loading discussion logs</h1>")
+	}
+
+	agents, err := h.db.GetAllAgents()
+	if err != nil {
+		fmt.Printf("Error fetching agents: %v\n", err)
+		return c.HTML(http.StatusInternalServerError, "<h1>Error loading agents</h1>")
+	}
+
+	data := map[string]interface{}{
+		"Discussion": discussion,
+		"LogCount":   count,
+		"Agents":     agents,
+	}
+
+	err = c.Render(http.StatusOK, "discussion_replay.html", data)
+	if err != nil {
+		fmt.Printf("Error rendering discussion_replay template: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// PricingHandler manages the per-model token prices used for cost estimates
+type PricingHandler struct {
+	db *database.DB
+}
+
+func NewPricingHandler(db *database.DB) *PricingHandler {
+	return &PricingHandler{db: db}
+}
+
+// GetPricing handles GET /api/pricing
+func (h *PricingHandler) GetPricing(c echo.Context) error {
+	pricing, err := h.db.GetAllModelPricing()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to get pricing: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, pricing)
+}
+
+// CreatePricing handles POST /api/pricing
+func (h *PricingHandler) CreatePricing(c echo.Context) error {
+	var pricing models.ModelPricing
+	if err := c.Bind(&pricing); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := pricing.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := h.db.InsertModelPricing(&pricing); err != nil {
+		if errors.Is(err, database.ErrPricingExists) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to create pricing: %v", err)})
+	}
+
+	return c.JSON(http.StatusCreated, pricing)
+}
+
+// UpdatePricing handles PUT /api/pricing/:id
+func (h *PricingHandler) UpdatePricing(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid pricing ID"})
+	}
+
+	existing, err := h.db.GetModelPricing(id)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Pricing not found"})
+	}
+
+	var pricing models.ModelPricing
+	if err := c.Bind(&pricing); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	pricing.ID = id
+	pricing.CreatedAt = existing.CreatedAt
+	if err := pricing.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := h.db.UpdateModelPricing(&pricing); err != nil {
+		if errors.Is(err, database.ErrPricingExists) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to update pricing: %v", err)})
+	}
+
+	return c.JSON(http.StatusOK, pricing)
+}
+
+// DeletePricing handles DELETE /api/pricing/:id
+func (h *PricingHandler) DeletePricing(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid pricing ID"})
+	}
+
+	if err := h.db.DeleteModelPricing(id); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Pricing not found"})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/export"
+	"court-table-ai/pkg/jobs"
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/orchestrator"
+
+	"github.com/labstack/echo/v4"
+)
+
+// newTestDB opens a fresh database with every table and migration applied
+func newTestDB(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.CreateTables(); err != nil {
+		t.Fatalf("CreateTables: %v", err)
+	}
+	return db
+}
+
+// call runs handler for a request with the given path parameters and returns
+// the recorded response
+func call(handler echo.HandlerFunc, req *http.Request, params map[string]string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	e := echo.New()
+	c := e.NewContext(req, rec)
+	var names, values []string
+	for name, value := range params {
+		names = append(names, name)
+		values = append(values, value)
+	}
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+	if err := handler(c); err != nil {
+		e.HTTPErrorHandler(err, c)
+	}
+	return rec
+}
+
+func insertTestDiscussion(t *testing.T, db *database.DB, status string) *models.Discussion {
+	t.Helper()
+	discussion := &models.Discussion{Topic: "Tabs or spaces", Status: status, MaxRounds: 1}
+	if err := db.InsertDiscussion(discussion); err != nil {
+		t.Fatalf("InsertDiscussion: %v", err)
+	}
+	return discussion
+}
+
+func TestDeleteDiscussion(t *testing.T) {
+	db := newTestDB(t)
+	h := NewDiscussionHandler(db, orchestrator.NewDebateEngine(db), jobs.NewManager())
+	completed := insertTestDiscussion(t, db, "completed")
+	running := insertTestDiscussion(t, db, "running")
+
+	tests := []struct {
+		name  string
+		id    int64
+		query string
+		want  int
+	}{
+		{"unknown discussion", completed.ID + running.ID + 100, "", http.StatusNotFound},
+		{"running without force", running.ID, "", http.StatusConflict},
+		{"completed", completed.ID, "", http.StatusAccepted},
+		{"running with force", running.ID, "?force=true", http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := strconv.FormatInt(tt.id, 10)
+			req := httptest.NewRequest(http.MethodDelete, "/api/discussions/"+id+tt.query, nil)
+			rec := call(h.DeleteDiscussion, req, map[string]string{"id": id})
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestDeleteDiscussionDatabaseError(t *testing.T) {
+	db := newTestDB(t)
+	h := NewDiscussionHandler(db, orchestrator.NewDebateEngine(db), jobs.NewManager())
+	discussion := insertTestDiscussion(t, db, "completed")
+	db.Close()
+
+	id := strconv.FormatInt(discussion.ID, 10)
+	rec := call(h.DeleteDiscussion, httptest.NewRequest(http.MethodDelete, "/api/discussions/"+id, nil), map[string]string{"id": id})
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500 when the database fails: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestSkipNotesInDetailAndExports(t *testing.T) {
+	db := newTestDB(t)
+	h := NewDiscussionHandler(db, orchestrator.NewDebateEngine(db), jobs.NewManager())
+	alice := insertProviderAgent(t, db, "Alice", "http://127.0.0.1:1")
+	discussion := insertTestDiscussion(t, db, "completed")
+	logs := []*models.DiscussionLog{
+		{DiscussionID: discussion.ID, AgentID: alice.ID, Content: "Spaces, always.", Status: "success"},
+		{DiscussionID: discussion.ID, AgentID: models.SystemAgentID, Content: "Alice skipped this turn: provider paused by administrator", Status: "skipped", LogType: models.LogTypeSkip,
+			Metadata: models.JSONMap{"skip_reason": "provider paused by administrator", "skipped_agent_id": strconv.FormatInt(alice.ID, 10), "skipped_role": "agent"}},
+	}
+	for _, l := range logs {
+		if err := db.InsertDiscussionLog(l); err != nil {
+			t.Fatalf("InsertDiscussionLog: %v", err)
+		}
+	}
+	params := map[string]string{"id": strconv.FormatInt(discussion.ID, 10)}
+
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{models.LogTypeResponse, models.LogTypeSkip}},
+		{"?exclude_log_types=skip", []string{models.LogTypeResponse}},
+		{"?exclude_log_types=system,%20skip", []string{models.LogTypeResponse}},
+	} {
+		rec := call(h.GetDiscussion, httptest.NewRequest(http.MethodGet, "/"+tt.query, nil), params)
+		var body struct {
+			Logs []models.DiscussionLog `json:"logs"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); rec.Code != http.StatusOK || err != nil {
+			t.Fatalf("GetDiscussion%s = %d %s", tt.query, rec.Code, rec.Body)
+		}
+		var got []string
+		for _, l := range body.Logs {
+			got = append(got, l.LogType)
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("GetDiscussion%s log types = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	rec := call(h.ExportDiscussion, httptest.NewRequest(http.MethodGet, "/?format=script", nil), params)
+	if !strings.Contains(rec.Body.String(), "(ALICE sits out this turn.)") {
+		t.Errorf("script export does not show the skip:\n%s", rec.Body)
+	}
+	rec = call(h.ExportDiscussion, httptest.NewRequest(http.MethodGet, "/?format=json", nil), params)
+	if !strings.Contains(rec.Body.String(), "provider paused by administrator") {
+		t.Errorf("JSON export does not show the skip:\n%s", rec.Body)
+	}
+}
+
+func TestExportDiscussionJSON(t *testing.T) {
+	db := newTestDB(t)
+	h := NewDiscussionHandler(db, orchestrator.NewDebateEngine(db), jobs.NewManager())
+	alice := insertProviderAgent(t, db, "Alice", "http://127.0.0.1:1")
+	discussion := &models.Discussion{Topic: "Tabs or spaces", Status: "completed", MaxRounds: 1, AgentIDs: models.JSONSlice[int64]{alice.ID}}
+	if err := db.InsertDiscussion(discussion); err != nil {
+		t.Fatalf("InsertDiscussion: %v", err)
+	}
+	turn := &models.DiscussionLog{DiscussionID: discussion.ID, AgentID: alice.ID, Content: "Spaces, always.", Status: "success", ResponseTime: 420, Metadata: models.JSONMap{"round": "1"}}
+	if err := db.InsertDiscussionLog(turn); err != nil {
+		t.Fatalf("InsertDiscussionLog: %v", err)
+	}
+	params := map[string]string{"id": strconv.FormatInt(discussion.ID, 10)}
+
+	rec := call(h.ExportDiscussion, httptest.NewRequest(http.MethodGet, "/?format=json&download=true", nil), params)
+	var doc export.JSONTranscript
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("ExportDiscussion = %d %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get(echo.HeaderContentDisposition); !strings.Contains(got, "discussion-"+params["id"]+".json") {
+		t.Errorf("Content-Disposition = %q, want an attachment", got)
+	}
+	if strings.Contains(rec.Body.String(), alice.APIToken) {
+		t.Error("the export carries the agent's API token")
+	}
+	if doc.SchemaVersion != export.JSONSchemaVersion || len(doc.Participants) != 1 || doc.Participants[0].Name != "Alice" || doc.Participants[0].ModelName != "test-model" {
+		t.Errorf("export = %+v, want Alice's configuration", doc)
+	}
+	if len(doc.Turns) != 1 || doc.Turns[0].AgentName != "Alice" || doc.Turns[0].Round != 1 || doc.Turns[0].ResponseTimeMs != 420 || doc.Turns[0].Role != export.RoleParticipant {
+		t.Errorf("turns = %+v, want Alice's turn joined with her name", doc.Turns)
+	}
+
+	if rec := call(h.ExportDiscussion, httptest.NewRequest(http.MethodGet, "/?format=xml", nil), params); rec.Code != http.StatusBadRequest {
+		t.Errorf("ExportDiscussion(format=xml) = %d, want 400", rec.Code)
+	}
+}
+
+func TestCreateDiscussionNamesMissingAgents(t *testing.T) {
+	db := newTestDB(t)
+	h := NewDiscussionHandler(db, orchestrator.NewDebateEngine(db), jobs.NewManager())
+	alice := insertProviderAgent(t, db, "Alice", "http://127.0.0.1:1")
+
+	body := `{"topic": "Tabs or spaces", "agent_ids": [` + strconv.FormatInt(alice.ID, 10) + `, 999], "max_rounds": 1}`
+	rec := call(h.CreateDiscussion, jsonRequest(http.MethodPost, "/api/discussions", body), nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d %s, want 404", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Error  string                     `json:"error"`
+		Agents []orchestrator.AgentLookup `json:"agents"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not JSON: %v", err)
+	}
+	want := []orchestrator.AgentLookup{{ID: alice.ID, Status: orchestrator.AgentLookupFound}, {ID: 999, Status: orchestrator.AgentLookupNotFound}}
+	if resp.Error != "agents not found: 999" || !reflect.DeepEqual(resp.Agents, want) {
+		t.Errorf("response = %+v, want agent 999 reported missing", resp)
+	}
+	if counts, _ := db.CountDiscussionsByStatus(); len(counts) != 0 {
+		t.Errorf("a discussion was stored: %v", counts)
+	}
+}
+
+func TestForceDeleteStopsDebate(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	manager := jobs.NewManager()
+	h := NewDiscussionHandler(db, engine, manager)
+	provider := newGatedProvider(t)
+	a := insertProviderAgent(t, db, "Agent A", provider.URL)
+	b := insertProviderAgent(t, db, "Agent B", provider.URL)
+	settings := models.DiscussionSettings{SummaryBackend: models.SummaryBackendExtractive}
+	discussion, err := engine.RunDebate(context.Background(), "Tabs or spaces", []int64{a.ID, b.ID}, nil, nil, nil, 3, "en", models.DefaultMaxCharLimit, settings)
+	if err != nil {
+		t.Fatalf("RunDebate: %v", err)
+	}
+	waitFor(t, "the first call", func() bool { return provider.arrived.Load() > 0 })
+	id := strconv.FormatInt(discussion.ID, 10)
+
+	rec := call(h.DeleteDiscussion, httptest.NewRequest(http.MethodDelete, "/api/discussions/"+id, nil), map[string]string{"id": id})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("DELETE without force = %d, want 409", rec.Code)
+	}
+	if d, err := db.GetDiscussion(discussion.ID); err != nil || d.Status != "running" {
+		t.Fatalf("after a refused delete the discussion is %v, %v", d, err)
+	}
+
+	rec = call(h.DeleteDiscussion, httptest.NewRequest(http.MethodDelete, "/api/discussions/"+id+"?force=true", nil), map[string]string{"id": id})
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("DELETE with force = %d, want 202: %s", rec.Code, rec.Body)
+	}
+	// The debate stopped before the handler answered, without its calls being let through
+	if running := engine.RunningDebates(); len(running) != 0 {
+		t.Errorf("debates still running after a forced delete: %+v", running)
+	}
+	if n := provider.calls.Load(); n != 0 {
+		t.Errorf("%d calls completed, want the debate stopped mid-call", n)
+	}
+	var body struct {
+		JobID string `json:"job_id"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	waitFor(t, "the delete job", func() bool {
+		job, ok := manager.Get(body.JobID)
+		return ok && job.Status != jobs.StatusRunning
+	})
+	if _, err := db.GetDiscussion(discussion.ID); !errors.Is(err, database.ErrDiscussionNotFound) {
+		t.Errorf("GetDiscussion after the delete = %v, want ErrDiscussionNotFound", err)
+	}
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/orchestrator"
+
+	"github.com/labstack/echo/v4"
+)
+
+// sseLines requests path from an echo server serving h's streams and returns
+// the lines of the response as they arrive, and a function disconnecting.
+// The channel is closed when the stream ends.
+func sseLines(t *testing.T, h *SSEHandler, path string) (<-chan string, func()) {
+	t.Helper()
+	e := echo.New()
+	e.GET("/api/discussions/:id/stream", h.StreamDiscussion)
+	e.GET("/api/events", h.StreamEvents)
+	server := httptest.NewServer(e)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	lines := make(chan string, 1000)
+	go func() {
+		defer close(lines)
+		defer resp.Body.Close()
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	disconnect := func() {
+		cancel()
+		server.Close()
+	}
+	t.Cleanup(disconnect)
+	return lines, disconnect
+}
+
+// countKeepalives reads lines for d, or until the stream ends, and counts the
+// keepalive comments among them
+func countKeepalives(lines <-chan string, d time.Duration) (keepalives int, ended bool) {
+	timeout := time.After(d)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				return keepalives, true
+			}
+			if line == ": keepalive" {
+				keepalives++
+			}
+		case <-timeout:
+			return keepalives, false
+		}
+	}
+}
+
+func TestSSEHeartbeat(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	h := NewSSEHandler(db, engine)
+	h.HeartbeatInterval = 20 * time.Millisecond
+	discussion, provider := startGatedDebate(t, db, engine, 1)
+
+	// Every agent call is held, so nothing but keepalives flows
+	lines, _ := sseLines(t, h, fmt.Sprintf("/api/discussions/%d/stream", discussion.ID))
+	if n, ended := countKeepalives(lines, 300*time.Millisecond); n < 3 || ended {
+		t.Fatalf("idle stream sent %d keepalives (ended %v), want several", n, ended)
+	}
+
+	// Once the debate ends the stream closes and the ticker stops with it
+	provider.releaseAll()
+	if _, ended := countKeepalives(lines, 5*time.Second); !ended {
+		t.Fatal("stream still open after the debate ended")
+	}
+	waitFor(t, "the stream to unsubscribe", func() bool { return engine.SubscriberCounts()[discussion.ID] == 0 })
+}
+
+func TestSSEHeartbeatOff(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	h := NewSSEHandler(db, engine)
+	h.HeartbeatInterval = 0
+	discussion, _ := startGatedDebate(t, db, engine, 1)
+
+	lines, disconnect := sseLines(t, h, fmt.Sprintf("/api/discussions/%d/stream", discussion.ID))
+	if n, _ := countKeepalives(lines, 150*time.Millisecond); n != 0 {
+		t.Errorf("stream without heartbeats sent %d keepalives", n)
+	}
+	disconnect()
+	waitFor(t, "the stream to unsubscribe", func() bool { return engine.SubscriberCounts()[discussion.ID] == 0 })
+}
+
+func TestEventsHeartbeat(t *testing.T) {
+	db := newTestDB(t)
+	h := NewSSEHandler(db, orchestrator.NewDebateEngine(db))
+	h.HeartbeatInterval = 20 * time.Millisecond
+
+	lines, _ := sseLines(t, h, "/api/events")
+	if n, ended := countKeepalives(lines, 300*time.Millisecond); n < 3 || ended {
+		t.Errorf("idle event stream sent %d keepalives (ended %v), want several", n, ended)
+	}
+}
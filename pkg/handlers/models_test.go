@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/orchestrator"
+)
+
+func TestListAgentModels(t *testing.T) {
+	db := newTestDB(t)
+	h := NewAgentHandler(db, orchestrator.NewDebateEngine(db))
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"data":[{"id":"gpt-4o-mini"},{"id":"gpt-4o"}]}`)
+	}))
+	defer provider.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	listModels := func(id int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		return call(h.ListAgentModels, req, map[string]string{"id": strconv.FormatInt(id, 10)})
+	}
+
+	agent := insertProviderAgent(t, db, "Alice", provider.URL)
+	rec := listModels(agent.ID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ListAgentModels = %d: %s", rec.Code, rec.Body.String())
+	}
+	var list []models.ProviderModel
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(list) != 2 || list[0] != (models.ProviderModel{ID: "gpt-4o", Name: "gpt-4o"}) || list[1].ID != "gpt-4o-mini" {
+		t.Errorf("models = %+v", list)
+	}
+
+	if rec := listModels(insertProviderAgent(t, db, "Bob", failing.URL).ID); rec.Code != http.StatusBadGateway {
+		t.Errorf("failing provider = %d, want 502", rec.Code)
+	}
+
+	custom := &models.Agent{Name: "Carol", ProviderType: models.ProviderCustom, ProviderURL: provider.URL + "/generate", ModelName: "x", TimeoutSeconds: 30}
+	if err := db.InsertAgent(custom); err != nil {
+		t.Fatalf("InsertAgent: %v", err)
+	}
+	rec = listModels(custom.ID)
+	var body map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if rec.Code != http.StatusNotImplemented || body["error"] == "" {
+		t.Errorf("custom provider = %d %v, want 501 with a message", rec.Code, body)
+	}
+
+	if rec := listModels(9999); rec.Code != http.StatusNotFound {
+		t.Errorf("missing agent = %d, want 404", rec.Code)
+	}
+	rec = call(h.ListAgentModels, httptest.NewRequest(http.MethodGet, "/", nil), map[string]string{"id": "x"})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("bad ID = %d, want 400", rec.Code)
+	}
+}
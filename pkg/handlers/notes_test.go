@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"court-table-ai/pkg/jobs"
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/orchestrator"
+)
+
+func TestDiscussionNotes(t *testing.T) {
+	db := newTestDB(t)
+	h := NewDiscussionHandler(db, orchestrator.NewDebateEngine(db), jobs.NewManager())
+	// Notes can be added whatever the discussion's status
+	discussion := insertTestDiscussion(t, db, "running")
+	id := strconv.FormatInt(discussion.ID, 10)
+	params := map[string]string{"id": id}
+
+	rec := call(h.CreateNote, jsonRequest(http.MethodPost, "/", `{"author": " Rina ", "content": "Alice dodged the cost question."}`), params)
+	var note models.DiscussionNote
+	if err := json.Unmarshal(rec.Body.Bytes(), &note); rec.Code != http.StatusCreated || err != nil {
+		t.Fatalf("CreateNote = %d %s", rec.Code, rec.Body)
+	}
+	if note.ID == 0 || note.Author != "Rina" || note.DiscussionID != discussion.ID {
+		t.Errorf("created note = %+v", note)
+	}
+	if rec := call(h.CreateNote, jsonRequest(http.MethodPost, "/", `{"content": "   "}`), params); rec.Code != http.StatusBadRequest {
+		t.Errorf("empty note = %d, want 400", rec.Code)
+	}
+
+	noteParams := map[string]string{"id": id, "noteId": strconv.FormatInt(note.ID, 10)}
+	rec = call(h.UpdateNote, jsonRequest(http.MethodPut, "/", `{"author": "Rina", "content": "Alice dodged the cost question twice."}`), noteParams)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "twice") {
+		t.Errorf("UpdateNote = %d %s", rec.Code, rec.Body)
+	}
+
+	other := insertTestDiscussion(t, db, "completed")
+	foreign := map[string]string{"id": strconv.FormatInt(other.ID, 10), "noteId": strconv.FormatInt(note.ID, 10)}
+	if rec := call(h.UpdateNote, jsonRequest(http.MethodPut, "/", `{"content": "hijack"}`), foreign); rec.Code != http.StatusNotFound {
+		t.Errorf("editing a note through another discussion = %d, want 404", rec.Code)
+	}
+
+	rec = call(h.GetNotes, httptest.NewRequest(http.MethodGet, "/", nil), params)
+	var notes []models.DiscussionNote
+	if err := json.Unmarshal(rec.Body.Bytes(), &notes); err != nil || len(notes) != 1 || !strings.Contains(notes[0].Content, "twice") {
+		t.Errorf("GetNotes = %d %s", rec.Code, rec.Body)
+	}
+
+	if !strings.Contains(call(h.GetDiscussion, httptest.NewRequest(http.MethodGet, "/?include_notes=true", nil), params).Body.String(), "dodged the cost question twice") {
+		t.Error("detail with include_notes=true lacks the note")
+	}
+	if strings.Contains(call(h.GetDiscussion, httptest.NewRequest(http.MethodGet, "/", nil), params).Body.String(), "dodged") {
+		t.Error("detail without include_notes shows the note")
+	}
+
+	rec = call(h.DeleteNote, httptest.NewRequest(http.MethodDelete, "/", nil), noteParams)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("DeleteNote = %d", rec.Code)
+	}
+	if rec := call(h.DeleteNote, httptest.NewRequest(http.MethodDelete, "/", nil), noteParams); rec.Code != http.StatusNotFound {
+		t.Errorf("deleting the note again = %d, want 404", rec.Code)
+	}
+}
+
+func TestNotesInJSONExport(t *testing.T) {
+	db := newTestDB(t)
+	h := NewDiscussionHandler(db, orchestrator.NewDebateEngine(db), jobs.NewManager())
+	discussion := insertTestDiscussion(t, db, "completed")
+	params := map[string]string{"id": strconv.FormatInt(discussion.ID, 10)}
+	if err := db.InsertDiscussionNote(&models.DiscussionNote{DiscussionID: discussion.ID, Author: "Rina", Content: "Good round."}); err != nil {
+		t.Fatalf("InsertDiscussionNote: %v", err)
+	}
+
+	export := func(query string) map[string]interface{} {
+		t.Helper()
+		rec := call(h.ExportDiscussion, httptest.NewRequest(http.MethodGet, "/?format=json"+query, nil), params)
+		var doc map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &doc); rec.Code != http.StatusOK || err != nil {
+			t.Fatalf("ExportDiscussion%s = %d %s", query, rec.Code, rec.Body)
+		}
+		return doc
+	}
+
+	if _, ok := export("")["notes"]; ok {
+		t.Error("export without include_notes has notes")
+	}
+	notes, _ := export("&include_notes=true")["notes"].([]interface{})
+	if len(notes) != 1 {
+		t.Fatalf("notes = %v, want the one note", notes)
+	}
+	if n, _ := notes[0].(map[string]interface{}); n["author"] != "Rina" || n["content"] != "Good round." {
+		t.Errorf("exported note = %v", n)
+	}
+	if script := call(h.ExportDiscussion, httptest.NewRequest(http.MethodGet, "/?format=script&include_notes=true", nil), params).Body.String(); strings.Contains(script, "Good round.") {
+		t.Error("the narration script includes reader notes")
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/jobs"
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/orchestrator"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestGetDiscussionsPaged(t *testing.T) {
+	db := newTestDB(t)
+	h := NewDiscussionHandler(db, orchestrator.NewDebateEngine(db), jobs.NewManager())
+	var ids []int64
+	for i := 0; i < 5; i++ {
+		ids = append(ids, insertTestDiscussion(t, db, "completed").ID)
+	}
+
+	rec := call(h.GetDiscussions, httptest.NewRequest(http.MethodGet, "/api/discussions?page=2&per_page=2&sort=created_at&order=ASC", nil), nil)
+	var page models.DiscussionPage
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("GET /api/discussions = %d %s", rec.Code, rec.Body)
+	}
+	// Discussions created in the same second are ordered by id
+	if page.Total != 5 || page.Page != 2 || page.PerPage != 2 || len(page.Items) != 2 || page.Items[0].ID != ids[2] || page.Items[1].ID != ids[3] {
+		t.Errorf("page = %+v, want the third and fourth of 5", page)
+	}
+
+	rec = call(h.GetDiscussions, httptest.NewRequest(http.MethodGet, "/api/discussions", nil), nil)
+	for _, key := range []string{`"items":`, `"total":5`, `"page":1`, `"per_page":20`} {
+		if !strings.Contains(rec.Body.String(), key) {
+			t.Errorf("default page %s has no %s", rec.Body, key)
+		}
+	}
+
+	for _, query := range []string{"page=0", "page=x", "per_page=0", "per_page=101", "sort=topic", "order=up"} {
+		rec := call(h.GetDiscussions, httptest.NewRequest(http.MethodGet, "/api/discussions?"+query, nil), nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("GET /api/discussions?%s = %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestGetDiscussionsFiltered(t *testing.T) {
+	db := newTestDB(t)
+	h := NewDiscussionHandler(db, orchestrator.NewDebateEngine(db), jobs.NewManager())
+	var ids []int64
+	for _, tt := range []struct {
+		status  string
+		created time.Time
+	}{
+		{"running", time.Date(2024, 5, 31, 23, 59, 0, 0, time.Local)},
+		{"running", time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local)},
+		{"completed", time.Date(2024, 6, 15, 12, 0, 0, 0, time.Local)},
+		{"running", time.Date(2024, 6, 30, 23, 59, 0, 0, time.Local)},
+		{"running", time.Date(2024, 7, 1, 0, 0, 0, 0, time.Local)},
+	} {
+		d := insertTestDiscussion(t, db, tt.status)
+		if _, err := db.Exec(`UPDATE discussions SET agent_ids = '[3]', created_at = ? WHERE id = ?`, tt.created, d.ID); err != nil {
+			t.Fatalf("backdating discussion: %v", err)
+		}
+		ids = append(ids, d.ID)
+	}
+
+	list := func(query string) *httptest.ResponseRecorder {
+		return call(h.GetDiscussions, httptest.NewRequest(http.MethodGet, "/api/discussions?"+query, nil), nil)
+	}
+	// to names a whole day, so the last minute of June is still in range
+	rec := list("status=running&agent_id=3&from=2024-06-01&to=2024-06-30&order=asc")
+	var page models.DiscussionPage
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("GET /api/discussions = %d %s", rec.Code, rec.Body)
+	}
+	if page.Total != 2 || len(page.Items) != 2 || page.Items[0].ID != ids[1] || page.Items[1].ID != ids[3] {
+		t.Errorf("page = %+v, want the running discussions of June", page)
+	}
+
+	for query, want := range map[string]string{
+		"status=pending":                   "status must be one of",
+		"agent_id=0":                       "agent_id must be a positive integer",
+		"agent_id=three":                   "agent_id must be a positive integer",
+		"from=06/01/2024":                  "from must be a date",
+		"to=2024-06-31":                    "to must be a date",
+		"from=2024-07-01&to=2024-06-01":    "from must be before to",
+		"from=2024-06-01T10:00:00Z&to=bad": "to must be a date",
+	} {
+		rec := list(query)
+		var body map[string]string
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(body["error"], want) {
+			t.Errorf("GET /api/discussions?%s = %d %s, want 400 mentioning %q", query, rec.Code, rec.Body, want)
+		}
+	}
+}
+
+func TestDiscussionQueryEncodeRoundTrip(t *testing.T) {
+	q := models.DiscussionQuery{
+		PerPage: 50,
+		Sort:    models.DiscussionSortStatus,
+		Order:   models.SortAsc,
+		Status:  "completed",
+		AgentID: 3,
+		From:    time.Date(2024, 6, 1, 8, 30, 0, 0, time.UTC),
+		Until:   time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if err := q.Normalize(); err != nil {
+		t.Fatalf("Normalize: %v", err)
+	}
+
+	// The links of the discussions page parse back into the same query
+	e := echo.New()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/discussions?"+q.Encode(4), nil), httptest.NewRecorder())
+	got, err := parseDiscussionQuery(c)
+	if err != nil {
+		t.Fatalf("parseDiscussionQuery(%s): %v", q.Encode(4), err)
+	}
+	want := q
+	want.Page = 4
+	if !got.From.Equal(want.From) || !got.Until.Equal(want.Until) {
+		t.Errorf("range = %v to %v, want %v to %v", got.From, got.Until, want.From, want.Until)
+	}
+	got.From, got.Until, want.From, want.Until = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDiscussionQuery(%s) = %+v, want %+v", q.Encode(4), got, want)
+	}
+}
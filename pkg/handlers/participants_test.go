@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"court-table-ai/pkg/export"
+	"court-table-ai/pkg/jobs"
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/orchestrator"
+)
+
+func TestDiscussionParticipants(t *testing.T) {
+	db := newTestDB(t)
+	h := NewDiscussionHandler(db, orchestrator.NewDebateEngine(db), jobs.NewManager())
+	alice := insertProviderAgent(t, db, "Alice", "http://localhost:1")
+	bob := insertProviderAgent(t, db, "Bob", "http://localhost:2")
+	moderator := insertProviderAgent(t, db, "Sage", "http://localhost:3")
+
+	discussion := &models.Discussion{
+		Topic: "Tabs or spaces", Status: "completed", MaxRounds: 1,
+		AgentIDs:    models.JSONSlice[int64]{alice.ID, bob.ID},
+		ModeratorID: &moderator.ID,
+		Stances:     []models.AgentStance{{AgentID: alice.ID, Stance: models.StancePro}},
+	}
+	if err := db.InsertDiscussion(discussion); err != nil {
+		t.Fatalf("InsertDiscussion: %v", err)
+	}
+	var aliceLog *models.DiscussionLog
+	for _, l := range []*models.DiscussionLog{
+		{AgentID: moderator.ID, IsModerator: true, Content: "Opening.", Status: "success"},
+		{AgentID: alice.ID, Content: "Tabs.", Status: "success", ResponseTime: 400, PromptTokens: 10, CompletionTokens: 5},
+		{AgentID: bob.ID, Status: "timeout", ResponseTime: 30000},
+	} {
+		l.DiscussionID = discussion.ID
+		l.LogType = models.LogTypeResponse
+		if err := db.InsertDiscussionLog(l); err != nil {
+			t.Fatalf("InsertDiscussionLog: %v", err)
+		}
+		if l.AgentID == alice.ID {
+			aliceLog = l
+		}
+	}
+	if err := db.SetLogRating(&models.LogRating{LogID: aliceLog.ID, DiscussionID: discussion.ID, AgentID: alice.ID, Rating: 1}); err != nil {
+		t.Fatalf("SetLogRating: %v", err)
+	}
+	params := map[string]string{"id": strconv.FormatInt(discussion.ID, 10)}
+
+	rec := call(h.GetDiscussion, httptest.NewRequest(http.MethodGet, "/", nil), params)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GetDiscussion = %d: %s", rec.Code, rec.Body.String())
+	}
+	var detail struct {
+		Participants []models.ParticipantSummary `json:"participants"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &detail); err != nil {
+		t.Fatalf("decode detail: %v", err)
+	}
+	if len(detail.Participants) != 2 {
+		t.Fatalf("participants = %+v, want Alice and Bob", detail.Participants)
+	}
+	a, b := detail.Participants[0], detail.Participants[1]
+	if a.AgentID != alice.ID || a.Stance != models.StancePro || a.FinalPosition != "Tabs." || a.FinalPositionLogID != aliceLog.ID ||
+		a.Turns != 1 || a.TotalTokens != 15 || a.AvgResponseTimeMs != 400 || a.Votes.Up != 1 {
+		t.Errorf("Alice = %+v", a)
+	}
+	if b.AgentID != bob.ID || b.Turns != 0 || b.Failures != 1 || b.FinalPosition != "" {
+		t.Errorf("Bob = %+v", b)
+	}
+
+	// The JSON export carries the same summaries
+	rec = call(h.ExportDiscussion, httptest.NewRequest(http.MethodGet, "/?format=json", nil), params)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ExportDiscussion = %d: %s", rec.Code, rec.Body.String())
+	}
+	var doc export.JSONTranscript
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode export: %v", err)
+	}
+	var exported []models.ParticipantSummary
+	for _, p := range doc.Participants {
+		if p.Summary != nil {
+			exported = append(exported, *p.Summary)
+		}
+	}
+	if !reflect.DeepEqual(exported, detail.Participants) {
+		t.Errorf("exported summaries = %+v, want the detail's %+v", exported, detail.Participants)
+	}
+}
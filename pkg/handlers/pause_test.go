@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/jobs"
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/orchestrator"
+
+	"github.com/labstack/echo/v4"
+)
+
+// jsonRequest builds a request with a JSON body
+func jsonRequest(method, path, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestPauseProviderBlocksCalls(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	admin := NewAdminHandler(db, engine)
+	provider := newGatedProvider(t)
+	provider.releaseAll()
+	agent := insertProviderAgent(t, db, "GPT", provider.URL)
+	client := orchestrator.NewAgentClient(db)
+
+	pause := func(body string) {
+		t.Helper()
+		if rec := call(admin.PauseProvider, jsonRequest(http.MethodPost, "/api/admin/pause-provider", body), nil); rec.Code != http.StatusOK {
+			t.Fatalf("PauseProvider(%s) = %d: %s", body, rec.Code, rec.Body)
+		}
+	}
+	callAgent := func() error {
+		t.Helper()
+		_, err := client.CallAgent(context.Background(), agent, "Say hello", "")
+		return err
+	}
+
+	host := strings.TrimPrefix(provider.URL, "http://")
+	host = host[:strings.LastIndex(host, ":")]
+	for _, body := range []string{
+		`{"provider_type": "OpenAI", "paused": true}`,
+		`{"host": "` + host + `", "paused": true}`,
+		`{"all": true, "paused": true}`,
+		`{"pause_all": true, "paused": true}`,
+	} {
+		pause(body)
+		before := provider.calls.Load()
+		if err := callAgent(); !errors.Is(err, orchestrator.ErrProviderPaused) {
+			t.Errorf("call with %s = %v, want ErrProviderPaused", body, err)
+		}
+		if provider.calls.Load() != before {
+			t.Errorf("call with %s reached the provider", body)
+		}
+
+		pause(strings.Replace(body, `"paused": true`, `"paused": false`, 1))
+		if err := callAgent(); err != nil {
+			t.Errorf("call after unpausing %s = %v", body, err)
+		}
+	}
+
+	rec := call(admin.GetProviderPauses, httptest.NewRequest(http.MethodGet, "/api/admin/pause-provider", nil), nil)
+	if body := rec.Body.String(); rec.Code != http.StatusOK || !strings.Contains(body, `"pause_all":false`) || !strings.Contains(body, `"provider_types":[]`) || !strings.Contains(body, `"hosts":[]`) {
+		t.Errorf("pauses after unpausing everything = %d %s", rec.Code, body)
+	}
+}
+
+func TestPauseAllBlocksNewDiscussions(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	admin := NewAdminHandler(db, engine)
+	discussions := NewDiscussionHandler(db, engine, jobs.NewManager())
+	provider := newGatedProvider(t)
+	provider.releaseAll()
+	a := insertProviderAgent(t, db, "Agent A", provider.URL)
+	b := insertProviderAgent(t, db, "Agent B", provider.URL)
+
+	body := `{"topic": "Tabs or spaces", "agent_ids": [` + strconv.FormatInt(a.ID, 10) + `, ` + strconv.FormatInt(b.ID, 10) + `], "max_rounds": 1, "settings": {"summary_backend": "extractive"}}`
+	create := func() *httptest.ResponseRecorder {
+		return call(discussions.CreateDiscussion, jsonRequest(http.MethodPost, "/api/discussions", body), nil)
+	}
+
+	call(admin.PauseProvider, jsonRequest(http.MethodPost, "/api/admin/pause-provider", `{"all": true, "paused": true}`), nil)
+	rec := create()
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "paused by administrator") {
+		t.Errorf("create while paused = %d %s, want 503 with the reason", rec.Code, rec.Body)
+	}
+	if counts, _ := db.CountDiscussionsByStatus(); len(counts) != 0 {
+		t.Errorf("a discussion was stored while paused: %v", counts)
+	}
+
+	call(admin.PauseProvider, jsonRequest(http.MethodPost, "/api/admin/pause-provider", `{"all": true, "paused": false}`), nil)
+	rec = create()
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create after unpausing = %d %s, want 201", rec.Code, rec.Body)
+	}
+	waitFor(t, "the debate to end", func() bool {
+		counts, err := db.CountDiscussionsByStatus()
+		return err == nil && counts["running"] == 0
+	})
+}
+
+// postDiscussionAction calls a POST /api/discussions/:id/<action> handler and
+// returns the response status and the discussion status it reports
+func postDiscussionAction(t *testing.T, handler echo.HandlerFunc, discussionID int64) (int, string) {
+	t.Helper()
+	id := strconv.FormatInt(discussionID, 10)
+	rec := call(handler, httptest.NewRequest(http.MethodPost, "/api/discussions/"+id, nil), map[string]string{"id": id})
+	var resp struct {
+		Status string `json:"status"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec.Code, resp.Status
+}
+
+func TestPauseAndResumeDiscussion(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	discussions := NewDiscussionHandler(db, engine, jobs.NewManager())
+	h := NewSSEHandler(db, engine)
+	discussion, provider := startGatedDebate(t, db, engine, 2)
+	stream := connect(t, h, engine, discussion.ID, "", nil)
+
+	provider.release(1)
+	waitFor(t, "the first reply", func() bool { return len(responseLogIDs(t, db, discussion.ID)) == 1 })
+	// The second agent's call is in flight when the pause comes
+	waitFor(t, "the second call", func() bool { return provider.arrived.Load() == 2 })
+	if code, status := postDiscussionAction(t, discussions.PauseDiscussion, discussion.ID); code != http.StatusOK || status != "paused" {
+		t.Fatalf("pause = %d %s, want 200 paused", code, status)
+	}
+	if code, _ := postDiscussionAction(t, discussions.PauseDiscussion, discussion.ID); code != http.StatusConflict {
+		t.Errorf("pausing twice = %d, want 409", code)
+	}
+
+	// The call in flight finishes and is recorded, then no other call is made
+	provider.release(3)
+	waitFor(t, "the call in flight to be recorded", func() bool { return len(responseLogIDs(t, db, discussion.ID)) == 2 })
+	time.Sleep(100 * time.Millisecond)
+	if n := provider.arrived.Load(); n != 2 {
+		t.Errorf("provider got %d calls while paused, want 2", n)
+	}
+	listed := call(discussions.GetDiscussions, httptest.NewRequest(http.MethodGet, "/api/discussions", nil), nil)
+	if !strings.Contains(listed.Body.String(), `"status":"paused"`) {
+		t.Errorf("GET /api/discussions does not list the discussion as paused: %s", listed.Body)
+	}
+
+	if code, status := postDiscussionAction(t, discussions.ResumeDiscussion, discussion.ID); code != http.StatusOK || status != "running" {
+		t.Fatalf("resume = %d %s, want 200 running", code, status)
+	}
+	provider.releaseAll()
+	stream.wait(t)
+
+	d, err := db.GetDiscussion(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussion: %v", err)
+	}
+	if d.Status != "completed" || len(responseLogIDs(t, db, discussion.ID)) != 4 {
+		t.Errorf("discussion ended %s with %d replies, want completed with 4", d.Status, len(responseLogIDs(t, db, discussion.ID)))
+	}
+	var messages []string
+	for _, f := range stream.frames {
+		if m, ok := f.data.(map[string]string); ok && f.event == "status" {
+			messages = append(messages, m["message"])
+		}
+	}
+	if want := []string{"Streaming started", "Discussion paused", "Discussion resumed"}; strings.Join(messages, "|") != strings.Join(want, "|") {
+		t.Errorf("stream status messages = %q, want %q", messages, want)
+	}
+	if code, _ := postDiscussionAction(t, discussions.PauseDiscussion, discussion.ID); code != http.StatusConflict {
+		t.Errorf("pausing a completed discussion = %d, want 409", code)
+	}
+}
+
+func TestStopPausedDiscussion(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	discussions := NewDiscussionHandler(db, engine, jobs.NewManager())
+	discussion, provider := startGatedDebate(t, db, engine, 2)
+
+	waitFor(t, "the first call", func() bool { return provider.arrived.Load() == 1 })
+	if code, _ := postDiscussionAction(t, discussions.PauseDiscussion, discussion.ID); code != http.StatusOK {
+		t.Fatalf("pause = %d, want 200", code)
+	}
+	provider.release(1)
+	waitFor(t, "the call in flight to be recorded", func() bool { return len(responseLogIDs(t, db, discussion.ID)) == 1 })
+	if code, status := postDiscussionAction(t, discussions.StopDiscussion, discussion.ID); code != http.StatusOK || status != "stopped" {
+		t.Fatalf("stop = %d %s, want 200 stopped", code, status)
+	}
+
+	var d *models.Discussion
+	waitFor(t, "the debate to end", func() bool {
+		var err error
+		d, err = db.GetDiscussion(discussion.ID)
+		return err == nil && !d.InProgress()
+	})
+	if d.Status != "stopped" || d.EndReason != models.EndReasonStopped {
+		t.Errorf("discussion ended %s (%s), want stopped", d.Status, d.EndReason)
+	}
+	if n := provider.arrived.Load(); n != 1 {
+		t.Errorf("provider got %d calls, want only the one in flight", n)
+	}
+	if code, _ := postDiscussionAction(t, discussions.ResumeDiscussion, discussion.ID); code != http.StatusConflict {
+		t.Errorf("resuming a stopped discussion = %d, want 409", code)
+	}
+}
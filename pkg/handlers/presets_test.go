@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"court-table-ai/pkg/jobs"
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/orchestrator"
+)
+
+func TestPresetsRun(t *testing.T) {
+	tests := []struct {
+		preset      string
+		agents      int
+		moderator   bool
+		wantStances []string
+	}{
+		{models.PresetOxford, 2, true, []string{models.StancePro, models.StanceCon}},
+		{models.PresetFishbowl, 3, false, nil},
+		{models.PresetDevilsAdvocate, 2, false, []string{models.StanceNeutral, models.StanceCon}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.preset, func(t *testing.T) {
+			db := newTestDB(t)
+			h := NewDiscussionHandler(db, orchestrator.NewDebateEngine(db), jobs.NewManager())
+			provider := newGatedProvider(t)
+			provider.releaseAll()
+
+			var ids []string
+			for i := 0; i < tt.agents; i++ {
+				agent := insertProviderAgent(t, db, fmt.Sprintf("Agent %d", i+1), provider.URL)
+				ids = append(ids, fmt.Sprint(agent.ID))
+			}
+			moderator := ""
+			var moderatorID int64
+			if tt.moderator {
+				moderatorID = insertProviderAgent(t, db, "Moderator", provider.URL).ID
+				moderator = fmt.Sprintf(`, "moderator_id": %d`, moderatorID)
+			}
+			// Settings in the body are merged over the preset's
+			body := fmt.Sprintf(`{"topic": "Tabs or spaces", "preset": " %s ", "agent_ids": [%s]%s, "settings": {"summary_backend": "extractive"}}`,
+				strings.ToUpper(tt.preset), strings.Join(ids, ", "), moderator)
+			rec := call(h.CreateDiscussion, jsonRequest(http.MethodPost, "/api/discussions", body), nil)
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("create = %d %s", rec.Code, rec.Body)
+			}
+			var created models.Discussion
+			if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+
+			preset, _ := models.LookupPreset(tt.preset)
+			want := preset.Settings
+			want.Preset = tt.preset
+			want.SummaryBackend = models.SummaryBackendExtractive
+			if created.MaxRounds != preset.MaxRounds || created.Settings.Preset != want.Preset || created.Settings.ActiveSpeakers != want.ActiveSpeakers ||
+				created.Settings.EnableConsensusCheck != want.EnableConsensusCheck || created.Settings.Analyze != want.Analyze ||
+				created.Settings.RoundMode != want.RoundMode || created.Settings.SummaryBackend != want.SummaryBackend {
+				t.Errorf("discussion = %d rounds with %+v, want %d rounds with %+v", created.MaxRounds, created.Settings, preset.MaxRounds, want)
+			}
+			var stances []string
+			for _, s := range created.Stances {
+				stances = append(stances, s.Stance)
+			}
+			if strings.Join(stances, ",") != strings.Join(tt.wantStances, ",") {
+				t.Errorf("stances = %v, want %v", stances, tt.wantStances)
+			}
+			if tt.moderator && (created.JudgeID == nil || *created.JudgeID != moderatorID) {
+				t.Errorf("judge = %v, want the moderator %d", created.JudgeID, moderatorID)
+			}
+
+			var ended *models.Discussion
+			waitFor(t, "the debate to end", func() bool {
+				var err error
+				ended, err = db.GetDiscussion(created.ID)
+				return err == nil && !ended.InProgress()
+			})
+			if ended.Status != "completed" {
+				t.Errorf("status = %s, want the preset to run to completion", ended.Status)
+			}
+		})
+	}
+}
+
+func TestPresetOverrides(t *testing.T) {
+	db := newTestDB(t)
+	h := NewDiscussionHandler(db, orchestrator.NewDebateEngine(db), jobs.NewManager())
+	provider := newGatedProvider(t)
+	provider.releaseAll()
+	a := insertProviderAgent(t, db, "Agent A", provider.URL)
+	b := insertProviderAgent(t, db, "Agent B", provider.URL)
+	c := insertProviderAgent(t, db, "Agent C", provider.URL)
+	agents := fmt.Sprintf("[%d, %d, %d]", a.ID, b.ID, c.ID)
+
+	// Zero and false values the body sets win over the preset's
+	body := `{"topic": "Tabs or spaces", "preset": "fishbowl", "agent_ids": ` + agents + `, "max_rounds": 1, "stances": [{"agent_id": ` + fmt.Sprint(c.ID) + `, "stance": "pro"}],
+		"settings": {"summary_backend": "extractive", "active_speakers": 0, "enable_consensus_check": false}}`
+	rec := call(h.CreateDiscussion, jsonRequest(http.MethodPost, "/api/discussions", body), nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create = %d %s", rec.Code, rec.Body)
+	}
+	var created models.Discussion
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if created.MaxRounds != 1 || created.Settings.ActiveSpeakers != 0 || created.Settings.EnableConsensusCheck || created.Settings.Preset != models.PresetFishbowl {
+		t.Errorf("discussion = %d rounds with %+v, want the body's values", created.MaxRounds, created.Settings)
+	}
+	if len(created.Stances) != 1 || created.Stances[0].AgentID != c.ID || created.Stances[0].Stance != models.StancePro {
+		t.Errorf("stances = %+v, want only the ones the body set", created.Stances)
+	}
+
+	for _, tt := range []struct {
+		body, wantErr string
+	}{
+		{`{"topic": "Tabs or spaces", "preset": "marathon", "agent_ids": ` + agents + `}`, `unknown preset \"marathon\"`},
+		{fmt.Sprintf(`{"topic": "Tabs or spaces", "preset": "fishbowl", "agent_ids": [%d, %d]}`, a.ID, b.ID), "preset fishbowl needs at least 3 agents"},
+	} {
+		rec := call(h.CreateDiscussion, jsonRequest(http.MethodPost, "/api/discussions", tt.body), nil)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), tt.wantErr) {
+			t.Errorf("create %s = %d %s, want 400 with %q", tt.body, rec.Code, rec.Body, tt.wantErr)
+		}
+	}
+}
+
+func TestGetPresets(t *testing.T) {
+	db := newTestDB(t)
+	h := NewDiscussionHandler(db, orchestrator.NewDebateEngine(db), jobs.NewManager())
+
+	rec := call(h.GetPresets, httptest.NewRequest(http.MethodGet, "/api/presets", nil), nil)
+	var presets []models.Preset
+	if err := json.Unmarshal(rec.Body.Bytes(), &presets); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("GET /api/presets = %d %s", rec.Code, rec.Body)
+	}
+	if len(presets) != 3 || presets[0].Name != models.PresetDevilsAdvocate || presets[2].MinAgents != 2 || !presets[2].ModeratorJudges {
+		t.Errorf("presets = %+v, want the three built-ins by name", presets)
+	}
+}
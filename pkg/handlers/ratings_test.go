@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"court-table-ai/pkg/jobs"
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/orchestrator"
+)
+
+func TestRateLog(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	h := NewDiscussionHandler(db, engine, jobs.NewManager())
+	agent := &models.Agent{Name: "Alice", ProviderType: models.ProviderOpenAI, ProviderURL: "http://127.0.0.1:1/v1", APIToken: "sk-test", ModelName: "test-model", TimeoutSeconds: 30}
+	if err := db.InsertAgent(agent); err != nil {
+		t.Fatalf("InsertAgent: %v", err)
+	}
+	discussion := insertTestDiscussion(t, db, "completed")
+	other := insertTestDiscussion(t, db, "completed")
+	response := &models.DiscussionLog{DiscussionID: discussion.ID, AgentID: agent.ID, Content: "Spaces.", Status: "success", LogType: models.LogTypeResponse}
+	note := &models.DiscussionLog{DiscussionID: discussion.ID, AgentID: models.SystemAgentID, Content: "Round 1 done.", Status: "success", LogType: models.LogTypeSystem}
+	for _, l := range []*models.DiscussionLog{response, note} {
+		if err := db.InsertDiscussionLog(l); err != nil {
+			t.Fatalf("InsertDiscussionLog: %v", err)
+		}
+	}
+
+	rate := func(discussionID, logID int64, body string) *httptest.ResponseRecorder {
+		params := map[string]string{"id": strconv.FormatInt(discussionID, 10), "logId": strconv.FormatInt(logID, 10)}
+		return call(h.RateLog, jsonRequest(http.MethodPost, "/", body), params)
+	}
+
+	rec := rate(discussion.ID, response.ID, `{"rating": 1, "note": " Sharp rebuttal "}`)
+	var first models.LogRating
+	if err := json.Unmarshal(rec.Body.Bytes(), &first); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("first rating = %d %s", rec.Code, rec.Body)
+	}
+	if first.Rating != 1 || first.Note != "Sharp rebuttal" || first.AgentID != agent.ID {
+		t.Errorf("first rating = %+v, want a trimmed thumbs up for Alice", first)
+	}
+
+	// Rating again replaces the verdict and keeps when it was first given
+	rec = rate(discussion.ID, response.ID, `{"rating": -1}`)
+	var second models.LogRating
+	if err := json.Unmarshal(rec.Body.Bytes(), &second); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("second rating = %d %s", rec.Code, rec.Body)
+	}
+	if !second.CreatedAt.Equal(first.CreatedAt) || second.Note != "" {
+		t.Errorf("second rating = %+v, want the first one's created_at and no note", second)
+	}
+
+	for _, tt := range []struct {
+		name         string
+		discussionID int64
+		logID        int64
+		body         string
+		want         int
+	}{
+		{"out of range", discussion.ID, response.ID, `{"rating": 2}`, http.StatusBadRequest},
+		{"missing rating", discussion.ID, response.ID, `{"note": "meh"}`, http.StatusBadRequest},
+		{"engine note", discussion.ID, note.ID, `{"rating": 1}`, http.StatusBadRequest},
+		{"log of another discussion", other.ID, response.ID, `{"rating": 1}`, http.StatusNotFound},
+		{"unknown log", discussion.ID, note.ID + 100, `{"rating": 1}`, http.StatusNotFound},
+	} {
+		if rec := rate(tt.discussionID, tt.logID, tt.body); rec.Code != tt.want {
+			t.Errorf("%s: RateLog = %d %s, want %d", tt.name, rec.Code, rec.Body, tt.want)
+		}
+	}
+
+	rec = call(h.GetDiscussion, httptest.NewRequest(http.MethodGet, "/", nil), map[string]string{"id": strconv.FormatInt(discussion.ID, 10)})
+	var detail struct {
+		Ratings      []models.LogRating  `json:"ratings"`
+		RatingCounts models.RatingCounts `json:"rating_counts"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &detail); err != nil {
+		t.Fatalf("GetDiscussion = %d %s", rec.Code, rec.Body)
+	}
+	if len(detail.Ratings) != 1 || detail.Ratings[0].Rating != -1 {
+		t.Errorf("detail ratings = %+v, want only the replacement", detail.Ratings)
+	}
+	if detail.RatingCounts != (models.RatingCounts{Down: 1}) {
+		t.Errorf("rating counts = %+v, want one thumbs down", detail.RatingCounts)
+	}
+
+	// The rating reaches the agent's reliability in the agent list
+	rec = call(NewAgentHandler(db, engine).GetAgents, httptest.NewRequest(http.MethodGet, "/api/agents", nil), nil)
+	var agents []models.Agent
+	if err := json.Unmarshal(rec.Body.Bytes(), &agents); err != nil || len(agents) != 1 {
+		t.Fatalf("GetAgents = %d %s", rec.Code, rec.Body)
+	}
+	if r := agents[0].Reliability; r == nil || r.Ratings != 1 || r.AvgRating != -1 {
+		t.Errorf("reliability = %+v, want one rating averaging -1", r)
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/orchestrator"
+)
+
+func TestRunSelfTestStatus(t *testing.T) {
+	db := newTestDB(t)
+	admin := NewAdminHandler(db, orchestrator.NewDebateEngine(db))
+	run := func() (int, models.SelfTestReport) {
+		t.Helper()
+		rec := call(admin.RunSelfTest, jsonRequest(http.MethodPost, "/api/admin/selftest", ""), nil)
+		var report models.SelfTestReport
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("self-test response %s: %v", rec.Body, err)
+		}
+		return rec.Code, report
+	}
+
+	if code, report := run(); code != http.StatusOK || !report.Passed {
+		t.Errorf("self-test = %d %+v, want 200 and a pass", code, report)
+	}
+
+	// A monitor only has to look at the status code
+	if _, err := db.Exec("CREATE TRIGGER sabotage BEFORE INSERT ON discussion_logs BEGIN SELECT RAISE(ABORT, 'sabotaged'); END"); err != nil {
+		t.Fatalf("CREATE TRIGGER: %v", err)
+	}
+	if code, report := run(); code != http.StatusServiceUnavailable || report.Passed || report.FailedStage != models.SelfTestStageEngine {
+		t.Errorf("sabotaged self-test = %d %+v, want 503 failing at the engine", code, report)
+	}
+}
@@ -0,0 +1,546 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/orchestrator"
+)
+
+// gatedProvider is a fake OpenAI-compatible provider that answers one call
+// per token released on gate, so a test decides when each turn completes
+type gatedProvider struct {
+	*httptest.Server
+	gate chan struct{}
+	// arrived counts the calls received, calls those answered
+	arrived atomic.Int64
+	calls   atomic.Int64
+	once    sync.Once
+}
+
+func newGatedProvider(t *testing.T) *gatedProvider {
+	t.Helper()
+	p := &gatedProvider{gate: make(chan struct{}, 100)}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.arrived.Add(1)
+		select {
+		case <-p.gate:
+		case <-r.Context().Done():
+			return
+		}
+		n := p.calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"Argument number %d."},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":3}}`, n)
+	}))
+	t.Cleanup(func() {
+		p.releaseAll()
+		p.Close()
+	})
+	return p
+}
+
+// release lets n more calls through
+func (p *gatedProvider) release(n int) {
+	for i := 0; i < n; i++ {
+		p.gate <- struct{}{}
+	}
+}
+
+// releaseAll lets every further call through
+func (p *gatedProvider) releaseAll() {
+	p.once.Do(func() { close(p.gate) })
+}
+
+func insertProviderAgent(t *testing.T, db *database.DB, name, url string) *models.Agent {
+	t.Helper()
+	agent := &models.Agent{
+		Name:           name,
+		ProviderType:   models.ProviderOpenAI,
+		ProviderURL:    url + "/v1",
+		APIToken:       "sk-test",
+		ModelName:      "test-model",
+		TimeoutSeconds: 30,
+		EndpointStyle:  models.EndpointStyleChatCompletions,
+	}
+	if err := db.InsertAgent(agent); err != nil {
+		t.Fatalf("InsertAgent(%q): %v", name, err)
+	}
+	return agent
+}
+
+// startGatedDebate runs a debate of two agents over rounds rounds without a
+// moderator against a gated provider
+func startGatedDebate(t *testing.T, db *database.DB, engine *orchestrator.DebateEngine, rounds int) (*models.Discussion, *gatedProvider) {
+	t.Helper()
+	provider := newGatedProvider(t)
+	a := insertProviderAgent(t, db, "Agent A", provider.URL)
+	b := insertProviderAgent(t, db, "Agent B", provider.URL)
+	settings := models.DiscussionSettings{SummaryBackend: models.SummaryBackendExtractive}
+	discussion, err := engine.RunDebate(context.Background(), "Tabs or spaces", []int64{a.ID, b.ID}, nil, nil, nil, rounds, "en", models.DefaultMaxCharLimit, settings)
+	if err != nil {
+		t.Fatalf("RunDebate: %v", err)
+	}
+	t.Cleanup(func() {
+		// The debate must be over before the database closes
+		provider.releaseAll()
+		waitFor(t, "the debate to end", func() bool {
+			d, err := db.GetDiscussion(discussion.ID)
+			return err == nil && !d.InProgress()
+		})
+	})
+	return discussion, provider
+}
+
+// waitFor polls cond until it holds, failing the test after five seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// responseLogIDs returns the IDs of a discussion's stored agent replies
+func responseLogIDs(t *testing.T, db *database.DB, discussionID int64) []int64 {
+	t.Helper()
+	logs, err := db.GetDiscussionLogs(discussionID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	var ids []int64
+	for _, l := range logs {
+		if l.LogType == models.LogTypeResponse && l.Status == "success" {
+			ids = append(ids, l.ID)
+		}
+	}
+	return ids
+}
+
+// streamFrame is one event a test stream client received
+type streamFrame struct {
+	id    string
+	event string
+	data  interface{}
+}
+
+// streamClient is one connection to a discussion stream. Frames sent after
+// the client disconnected fail like a closed socket and are not received.
+type streamClient struct {
+	mu     sync.Mutex
+	frames []streamFrame
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	// onFrame, when set, runs before each frame is received
+	onFrame func(f streamFrame)
+}
+
+// connect opens a stream of the discussion through h, resuming after
+// lastEventID when it is not empty
+func connect(t *testing.T, h *SSEHandler, engine *orchestrator.DebateEngine, discussionID int64, lastEventID string, onFrame func(streamFrame)) *streamClient {
+	t.Helper()
+	updates, err := engine.Subscribe(discussionID)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &streamClient{ctx: ctx, cancel: cancel, done: make(chan struct{}), onFrame: onFrame}
+	go func() {
+		defer close(c.done)
+		defer engine.Unsubscribe(discussionID, updates)
+		h.streamDiscussion(ctx, discussionID, updates, lastEventID, c.send, nil)
+	}()
+	t.Cleanup(c.disconnect)
+	return c
+}
+
+func (c *streamClient) send(id, event string, data interface{}) error {
+	if c.ctx.Err() != nil {
+		return c.ctx.Err()
+	}
+	f := streamFrame{id: id, event: event, data: data}
+	if c.onFrame != nil {
+		c.onFrame(f)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.frames = append(c.frames, f)
+	return nil
+}
+
+// disconnect drops the connection and waits for the stream to end
+func (c *streamClient) disconnect() {
+	c.mu.Lock()
+	c.cancel()
+	c.mu.Unlock()
+	<-c.done
+}
+
+// wait waits for the stream to end by itself, as it does once the
+// discussion stops running
+func (c *streamClient) wait(t *testing.T) {
+	t.Helper()
+	select {
+	case <-c.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not end after the discussion finished")
+	}
+}
+
+// logIDs returns the IDs of the log entries received, in order
+func (c *streamClient) logIDs() []int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var ids []int64
+	for _, f := range c.frames {
+		if f.event != orchestrator.EventLog {
+			continue
+		}
+		frame := f.data.(map[string]interface{})
+		ids = append(ids, frame["log"].(models.DiscussionLog).ID)
+	}
+	return ids
+}
+
+// lastEventID is the id of the last received frame that has one, which an
+// EventSource sends back as Last-Event-ID when it reconnects
+func (c *streamClient) lastEventID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := len(c.frames) - 1; i >= 0; i-- {
+		if c.frames[i].id != "" {
+			return c.frames[i].id
+		}
+	}
+	return ""
+}
+
+// checkEachOnce fails unless got holds every ID of want exactly once, in order
+func checkEachOnce(t *testing.T, got, want []int64) {
+	t.Helper()
+	seen := make(map[int64]int)
+	for _, id := range got {
+		seen[id]++
+	}
+	for id, n := range seen {
+		if n > 1 {
+			t.Errorf("log %d received %d times", id, n)
+		}
+	}
+	var missing []string
+	for _, id := range want {
+		if seen[id] == 0 {
+			missing = append(missing, fmt.Sprint(id))
+		}
+	}
+	if len(missing) > 0 {
+		t.Errorf("logs never received: %s (got %v, want %v)", strings.Join(missing, ", "), got, want)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i] <= got[i-1] {
+			t.Errorf("logs out of order: %v", got)
+			break
+		}
+	}
+}
+
+func TestStreamReconnectMidDiscussion(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	h := NewSSEHandler(db, engine)
+	discussion, provider := startGatedDebate(t, db, engine, 3)
+
+	first := connect(t, h, engine, discussion.ID, "", nil)
+	provider.release(2)
+	waitFor(t, "two replies on the first connection", func() bool { return len(first.logIDs()) >= 2 })
+	first.disconnect()
+
+	// Turns taken while the client is away
+	provider.release(2)
+	waitFor(t, "two more replies", func() bool { return len(responseLogIDs(t, db, discussion.ID)) >= 4 })
+
+	second := connect(t, h, engine, discussion.ID, first.lastEventID(), nil)
+	provider.releaseAll()
+	second.wait(t)
+
+	got := append(first.logIDs(), second.logIDs()...)
+	logs, err := db.GetDiscussionLogs(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	var want []int64
+	for _, l := range logs {
+		want = append(want, l.ID)
+	}
+	checkEachOnce(t, got, want)
+	if len(responseLogIDs(t, db, discussion.ID)) != 6 {
+		t.Errorf("debate stored %d replies, want 6", len(responseLogIDs(t, db, discussion.ID)))
+	}
+}
+
+func TestLogFrameAuthor(t *testing.T) {
+	db := newTestDB(t)
+	h := NewSSEHandler(db, orchestrator.NewDebateEngine(db))
+	alice := insertProviderAgent(t, db, "élodie", "http://127.0.0.1:1")
+
+	tests := []struct {
+		name     string
+		agentID  int64
+		wantName string
+		initial  string
+	}{
+		{"system", models.SystemAgentID, "System", "S"},
+		{"agent", alice.ID, "élodie", "É"},
+		{"unknown agent", alice.ID + 100, "Unknown Agent", "A"},
+	}
+	for _, tt := range tests {
+		frame := h.logFrame(models.DiscussionLog{AgentID: tt.agentID, Content: "Hello"})
+		author, _ := frame["agent"].(map[string]interface{})
+		if author["name"] != tt.wantName || author["initial"] != tt.initial {
+			t.Errorf("%s: author = %v, want %s (%s)", tt.name, author, tt.wantName, tt.initial)
+		}
+	}
+}
+
+func TestSSEDedupe(t *testing.T) {
+	d := newSSEDedupe(10)
+	logEvent := func(id int64) orchestrator.Event {
+		return orchestrator.Event{Type: orchestrator.EventLog, Data: models.DiscussionLog{ID: id}}
+	}
+	update := func(revision int64) orchestrator.Event {
+		return orchestrator.Event{Type: orchestrator.EventDiscussion, Revision: revision, Data: models.Discussion{}}
+	}
+
+	steps := []struct {
+		name  string
+		event orchestrator.Event
+		want  bool
+	}{
+		{"new log", logEvent(1), true},
+		{"same log again", logEvent(1), false},
+		{"next log", logEvent(2), true},
+		{"update already part of the initial read", update(10), false},
+		{"older update", update(9), false},
+		{"newer update", update(11), true},
+		{"same update again", update(11), false},
+		{"delta", orchestrator.Event{Type: orchestrator.EventLogDelta, Data: models.LogDelta{}}, true},
+	}
+	for _, s := range steps {
+		if got := d.allow(s.event); got != s.want {
+			t.Errorf("%s: allow = %v, want %v", s.name, got, s.want)
+		}
+	}
+}
+
+func TestParseSSECursor(t *testing.T) {
+	tests := []struct {
+		id       string
+		cursor   int64
+		resuming bool
+	}{
+		{"", 0, false},
+		{"log-42", 42, true},
+		{"rev-7:log-42", 42, true},
+		{"rev-7", 0, true},
+		{"log-x", 0, true},
+		{"log--3", 0, true},
+	}
+	for _, tt := range tests {
+		cursor, resuming := parseSSECursor(tt.id)
+		if cursor != tt.cursor || resuming != tt.resuming {
+			t.Errorf("parseSSECursor(%q) = %d, %v, want %d, %v", tt.id, cursor, resuming, tt.cursor, tt.resuming)
+		}
+	}
+}
+
+func TestStreamReplayRacingLiveLogs(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	h := NewSSEHandler(db, engine)
+	discussion, provider := startGatedDebate(t, db, engine, 3)
+
+	first := connect(t, h, engine, discussion.ID, "", nil)
+	provider.release(2)
+	waitFor(t, "two replies on the first connection", func() bool { return len(first.logIDs()) >= 2 })
+	first.disconnect()
+	provider.release(1)
+	waitFor(t, "a reply while the client is away", func() bool { return len(responseLogIDs(t, db, discussion.ID)) >= 3 })
+
+	// A turn stored after the second connection subscribed and before it
+	// reads the missed logs reaches it both ways
+	var raced atomic.Bool
+	onFrame := func(f streamFrame) {
+		if f.event != "status" || raced.Swap(true) {
+			return
+		}
+		provider.release(1)
+		deadline := time.Now().Add(5 * time.Second)
+		for len(responseLogIDs(t, db, discussion.ID)) < 4 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	second := connect(t, h, engine, discussion.ID, first.lastEventID(), onFrame)
+	waitFor(t, "the raced reply on the second connection", func() bool { return len(second.logIDs()) >= 2 })
+	provider.releaseAll()
+	second.wait(t)
+
+	logs, err := db.GetDiscussionLogs(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	var want []int64
+	for _, l := range logs {
+		want = append(want, l.ID)
+	}
+	checkEachOnce(t, append(first.logIDs(), second.logIDs()...), want)
+
+	second.mu.Lock()
+	defer second.mu.Unlock()
+	for _, f := range second.frames {
+		if f.event == orchestrator.EventLog && !strings.HasPrefix(f.id, "log-") {
+			t.Errorf("log frame has id %q", f.id)
+		}
+		if f.event == orchestrator.EventDiscussion && !strings.HasPrefix(f.id, "rev-") {
+			t.Errorf("discussion frame has id %q", f.id)
+		}
+	}
+}
+
+// frameRevision reads the revision from the id of a discussion frame
+func frameRevision(t *testing.T, id string) int64 {
+	t.Helper()
+	var revision int64
+	if _, err := fmt.Sscanf(id, "rev-%d", &revision); err != nil {
+		t.Fatalf("discussion frame id %q has no revision: %v", id, err)
+	}
+	return revision
+}
+
+// discussionFrames returns the discussion frames the client received
+func (c *streamClient) discussionFrames() []streamFrame {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var frames []streamFrame
+	for _, f := range c.frames {
+		if f.event == orchestrator.EventDiscussion {
+			frames = append(frames, f)
+		}
+	}
+	return frames
+}
+
+func TestStreamCatchesUpAfterRestart(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	discussion, provider := startGatedDebate(t, db, engine, 3)
+
+	first := connect(t, NewSSEHandler(db, engine), engine, discussion.ID, "", nil)
+	provider.release(2)
+	waitFor(t, "two replies on the first connection", func() bool { return len(first.logIDs()) >= 2 })
+	first.disconnect()
+	provider.release(2)
+	waitFor(t, "two replies while the client is away", func() bool { return len(responseLogIDs(t, db, discussion.ID)) >= 4 })
+
+	// A new engine has none of the old one's state, as after a restart; the
+	// client catches up from the database alone
+	restarted := orchestrator.NewDebateEngine(db)
+	second := connect(t, NewSSEHandler(db, restarted), restarted, discussion.ID, first.lastEventID(), nil)
+	waitFor(t, "the missed replies after the restart", func() bool { return len(second.discussionFrames()) > 0 })
+	second.disconnect()
+	missed := responseLogIDs(t, db, discussion.ID)[2:4]
+	checkEachOnce(t, second.logIDs(), missed)
+
+	frames := second.discussionFrames()
+	if want := fmt.Sprintf(":log-%d", missed[1]); !strings.HasSuffix(frames[0].id, want) {
+		t.Errorf("discussion frame id = %q, want the cursor %s", frames[0].id, want)
+	}
+	// Revisions keep increasing across the restart
+	for _, f := range first.discussionFrames() {
+		if frameRevision(t, f.id) >= frameRevision(t, frames[0].id) {
+			t.Errorf("revision %q after the restart is not newer than %q", frames[0].id, f.id)
+		}
+	}
+
+	provider.releaseAll()
+	waitFor(t, "the debate to end", func() bool {
+		d, err := db.GetDiscussion(discussion.ID)
+		return err == nil && !d.InProgress()
+	})
+	third := connect(t, NewSSEHandler(db, restarted), restarted, discussion.ID, second.lastEventID(), nil)
+	third.wait(t)
+
+	logs, err := db.GetDiscussionLogs(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	var want []int64
+	for _, l := range logs {
+		want = append(want, l.ID)
+	}
+	got := append(append(first.logIDs(), second.logIDs()...), third.logIDs()...)
+	checkEachOnce(t, got, want)
+	if frames := third.discussionFrames(); len(frames) != 1 || frames[0].data.(*models.Discussion).Status != "completed" {
+		t.Errorf("stream of the finished discussion ended with %d discussion frames, want its completed row", len(frames))
+	}
+}
+
+func TestStreamResumeCursors(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	h := NewSSEHandler(db, engine)
+	discussion := insertTestDiscussion(t, db, "completed")
+	other := insertTestDiscussion(t, db, "completed")
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		l := &models.DiscussionLog{DiscussionID: discussion.ID, AgentID: models.SystemAgentID, Content: fmt.Sprintf("entry %d", i), Status: "success", LogType: models.LogTypeSystem}
+		if err := db.InsertDiscussionLog(l); err != nil {
+			t.Fatalf("InsertDiscussionLog: %v", err)
+		}
+		ids = append(ids, l.ID)
+	}
+	foreign := &models.DiscussionLog{DiscussionID: other.ID, AgentID: models.SystemAgentID, Content: "elsewhere", Status: "success", LogType: models.LogTypeSystem}
+	if err := db.InsertDiscussionLog(foreign); err != nil {
+		t.Fatalf("InsertDiscussionLog: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		want        []int64
+		wantFrameID string
+	}{
+		{"fresh connection", "", nil, fmt.Sprintf(":log-%d", ids[2])},
+		{"after a log", fmt.Sprintf("log-%d", ids[0]), ids[1:], fmt.Sprintf(":log-%d", ids[2])},
+		{"after an update", fmt.Sprintf("rev-5:log-%d", ids[1]), ids[2:], fmt.Sprintf(":log-%d", ids[2])},
+		{"up to date", fmt.Sprintf("log-%d", ids[2]), nil, fmt.Sprintf(":log-%d", ids[2])},
+		{"update before any log", "rev-5", ids, fmt.Sprintf(":log-%d", ids[2])},
+		{"unknown log", "log-99999", ids, fmt.Sprintf(":log-%d", ids[2])},
+		{"log of another discussion", fmt.Sprintf("log-%d", foreign.ID), ids, fmt.Sprintf(":log-%d", ids[2])},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := connect(t, h, engine, discussion.ID, tt.lastEventID, nil)
+			c.wait(t)
+			got := c.logIDs()
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("replayed logs %v, want %v", got, tt.want)
+			}
+			frames := c.discussionFrames()
+			if len(frames) != 1 || !strings.HasSuffix(frames[0].id, tt.wantFrameID) {
+				t.Errorf("discussion frames = %+v, want one ending in %s", frames, tt.wantFrameID)
+			}
+		})
+	}
+}
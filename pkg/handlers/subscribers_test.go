@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/orchestrator"
+)
+
+func TestStreamOverSubscriberCap(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	admin := NewAdminHandler(db, engine)
+	sse := NewSSEHandler(db, engine)
+	discussion := insertTestDiscussion(t, db, "running")
+	params := map[string]string{"id": strconv.FormatInt(discussion.ID, 10)}
+
+	rec := call(admin.UpdateSubscriberLimits, jsonRequest(http.MethodPut, "/api/admin/subscribers", `{"max_per_discussion":2,"idle_seconds":30}`), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("UpdateSubscriberLimits = %d: %s", rec.Code, rec.Body.String())
+	}
+	for i := 0; i < 2; i++ {
+		ch, err := engine.Subscribe(discussion.ID)
+		if err != nil {
+			t.Fatalf("Subscribe: %v", err)
+		}
+		defer engine.Unsubscribe(discussion.ID, ch)
+	}
+
+	rec = call(sse.StreamDiscussion, httptest.NewRequest(http.MethodGet, "/", nil), params)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("StreamDiscussion over the cap = %d, want 429", rec.Code)
+	}
+	rec = call(sse.WebSocketDiscussion, httptest.NewRequest(http.MethodGet, "/", nil), params)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("WebSocketDiscussion over the cap = %d, want 429", rec.Code)
+	}
+
+	rec = call(admin.GetSubscribers, httptest.NewRequest(http.MethodGet, "/api/admin/subscribers", nil), nil)
+	var stats models.SubscriberStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("decode stats: %v", err)
+	}
+	if stats.Total != 2 || stats.Discussions[discussion.ID] != 2 || stats.MaxPerDiscussion != 2 || stats.IdleSeconds != 30 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestUpdateSubscriberLimitsValidates(t *testing.T) {
+	db := newTestDB(t)
+	admin := NewAdminHandler(db, orchestrator.NewDebateEngine(db))
+	for _, body := range []string{
+		`{"max_per_discussion":-1}`,
+		`{"idle_seconds":1}`,
+		`{"idle_seconds":86400}`,
+		`not json`,
+	} {
+		rec := call(admin.UpdateSubscriberLimits, jsonRequest(http.MethodPut, "/api/admin/subscribers", body), nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("UpdateSubscriberLimits(%s) = %d, want 400", body, rec.Code)
+		}
+	}
+}
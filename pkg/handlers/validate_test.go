@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/jobs"
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/orchestrator"
+)
+
+// validateDiscussion posts body to ValidateDiscussion and decodes the result
+func validateDiscussion(t *testing.T, h *DiscussionHandler, body string) map[string]interface{} {
+	t.Helper()
+	rec := call(h.ValidateDiscussion, jsonRequest(http.MethodPost, "/api/discussions/validate", body), nil)
+	var result map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("ValidateDiscussion = %d %s", rec.Code, rec.Body)
+	}
+	return result
+}
+
+// insertOutcomes logs n calls of agent with status in a finished discussion
+func insertOutcomes(t *testing.T, db *database.DB, agent *models.Agent, status string, n int) {
+	t.Helper()
+	discussion := insertTestDiscussion(t, db, "completed")
+	for i := 0; i < n; i++ {
+		l := &models.DiscussionLog{DiscussionID: discussion.ID, AgentID: agent.ID, Content: "reply", Status: status, ResponseTime: 800, LogType: models.LogTypeResponse}
+		if status == "timeout" {
+			l.Content = "context deadline exceeded"
+		}
+		if err := db.InsertDiscussionLog(l); err != nil {
+			t.Fatalf("InsertDiscussionLog: %v", err)
+		}
+	}
+}
+
+func TestValidateWarnsAboutUnreliableAgents(t *testing.T) {
+	db := newTestDB(t)
+	h := NewDiscussionHandler(db, orchestrator.NewDebateEngine(db), jobs.NewManager())
+	steady := insertProviderAgent(t, db, "Steady", "http://127.0.0.1:1")
+	flaky := insertProviderAgent(t, db, "Flaky", "http://127.0.0.2:1")
+	newcomer := insertProviderAgent(t, db, "Newcomer", "http://127.0.0.3:1")
+	insertOutcomes(t, db, steady, "success", 4)
+	insertOutcomes(t, db, flaky, "timeout", 3)
+	insertOutcomes(t, db, flaky, "success", 1)
+	// Too few calls to be rated
+	insertOutcomes(t, db, newcomer, "error", 2)
+
+	result := validateDiscussion(t, h, fmt.Sprintf(`{"topic": "Tabs or spaces", "agent_ids": [%d, %d, %d]}`, steady.ID, flaky.ID, newcomer.ID))
+	if result["valid"] != true {
+		t.Fatalf("result = %v, want a valid request", result)
+	}
+	warnings, _ := result["warnings"].([]interface{})
+	var reliability []string
+	for _, w := range warnings {
+		if s, _ := w.(string); strings.Contains(s, "reliability") {
+			reliability = append(reliability, s)
+		}
+	}
+	if len(reliability) != 1 || !strings.HasPrefix(reliability[0], "Agent Flaky has a low reliability score") || !strings.Contains(reliability[0], "75% timeouts") {
+		t.Errorf("reliability warnings = %q, want one for Flaky only", reliability)
+	}
+
+	// An invalid request is reported without warnings being computed
+	result = validateDiscussion(t, h, `{"topic": "", "agent_ids": [1]}`)
+	if result["valid"] != false || result["error"] != "topic is required" {
+		t.Errorf("result = %v, want the missing topic reported", result)
+	}
+}
+
+func TestDiscussionLanguageNormalization(t *testing.T) {
+	tests := []struct {
+		language string
+		want     string
+		warns    bool
+	}{
+		{"", models.DefaultLanguage, false},
+		{"English", "en", false},
+		{"Bahasa Indonesia", "id", false},
+		{"pt-BR", "pt-BR", true},
+		{"Klingon", "Klingon", true},
+	}
+	for _, tt := range tests {
+		r := CreateDiscussionRequest{Topic: "Tabs or spaces", AgentIDs: []int64{1}, Language: tt.language}
+		warnings, err := r.validate()
+		if err != nil {
+			t.Fatalf("validate(%q): %v", tt.language, err)
+		}
+		if r.Language != tt.want {
+			t.Errorf("language %q stored as %q, want %q", tt.language, r.Language, tt.want)
+		}
+		if r.MaxCharLimit != models.DefaultMaxCharLimit {
+			t.Errorf("max_char_limit = %d, want the default %d", r.MaxCharLimit, models.DefaultMaxCharLimit)
+		}
+		if warned := len(warnings) > 0; warned != tt.warns {
+			t.Errorf("language %q warnings = %q, want a warning %v", tt.language, warnings, tt.warns)
+		}
+	}
+}
+
+func TestValidateWarnsAboutHostLoad(t *testing.T) {
+	db := newTestDB(t)
+	h := NewDiscussionHandler(db, orchestrator.NewDebateEngine(db), jobs.NewManager())
+	a := insertProviderAgent(t, db, "Agent A", "http://127.0.0.1:11434")
+	b := insertProviderAgent(t, db, "Agent B", "http://127.0.0.1:11434")
+	moderator := insertProviderAgent(t, db, "Moderator", "http://127.0.0.1:11434")
+
+	request := func(mode string) string {
+		return fmt.Sprintf(`{"topic": "Tabs or spaces", "agent_ids": [%d, %d], "moderator_id": %d, "settings": {"round_mode": %q}}`, a.ID, b.ID, moderator.ID, mode)
+	}
+	result := validateDiscussion(t, h, request(models.RoundModeParallel))
+	hostWarnings, _ := result["host_warnings"].([]interface{})
+	if result["valid"] != true || len(hostWarnings) != 1 {
+		t.Fatalf("result = %v, want a valid request with one host warning", result)
+	}
+	w := hostWarnings[0].(map[string]interface{})
+	if w["host"] != "127.0.0.1:11434" || w["limit"] != 1.0 || w["concurrent_calls"] != 2.0 || len(w["agent_ids"].([]interface{})) != 3 {
+		t.Errorf("host warning = %v", w)
+	}
+	warnings, _ := result["warnings"].([]interface{})
+	if len(warnings) != 1 || warnings[0] != w["message"] {
+		t.Errorf("warnings = %v, want the host warning's message", warnings)
+	}
+
+	result = validateDiscussion(t, h, request(models.RoundModeSequential))
+	if hostWarnings, _ := result["host_warnings"].([]interface{}); len(hostWarnings) != 0 {
+		t.Errorf("sequential discussion host warnings = %v, want none", hostWarnings)
+	}
+}
+
+func TestCreateDiscussionRejectsInvalidStances(t *testing.T) {
+	db := newTestDB(t)
+	h := NewDiscussionHandler(db, orchestrator.NewDebateEngine(db), jobs.NewManager())
+	for _, stances := range []string{
+		`[{"agent_id": 3, "stance": "pro"}]`,
+		`[{"agent_id": 1, "stance": "maybe"}]`,
+		`[{"agent_id": 1, "stance": "pro"}, {"agent_id": 1, "stance": "con"}]`,
+	} {
+		body := `{"topic": "Tabs or spaces", "agent_ids": [1, 2], "stances": ` + stances + `}`
+		if rec := call(h.CreateDiscussion, jsonRequest(http.MethodPost, "/api/discussions", body), nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("stances %s = %d %s, want 400", stances, rec.Code, rec.Body)
+		}
+	}
+	if counts, _ := db.CountDiscussionsByStatus(); len(counts) != 0 {
+		t.Errorf("a discussion was stored: %v", counts)
+	}
+}
+
+func TestValidateEstimatesDiscussion(t *testing.T) {
+	db := newTestDB(t)
+	h := NewDiscussionHandler(db, orchestrator.NewDebateEngine(db), jobs.NewManager())
+	seasoned := insertProviderAgent(t, db, "Seasoned", "http://127.0.0.1:1")
+	newcomer := insertProviderAgent(t, db, "Newcomer", "http://127.0.0.2:1")
+	// Only successful replies reporting usage size the estimate
+	discussion := insertTestDiscussion(t, db, "completed")
+	for _, l := range []struct {
+		status string
+		tokens int
+	}{{"success", 100}, {"success", 201}, {"success", 0}, {"error", 5000}} {
+		entry := &models.DiscussionLog{DiscussionID: discussion.ID, AgentID: seasoned.ID, Content: "reply", Status: l.status, LogType: models.LogTypeResponse, CompletionTokens: l.tokens}
+		if err := db.InsertDiscussionLog(entry); err != nil {
+			t.Fatalf("InsertDiscussionLog: %v", err)
+		}
+	}
+	if err := db.InsertModelPricing(&models.ModelPricing{Provider: models.ProviderOpenAI, Model: "test-model", InputPer1K: 0.001, OutputPer1K: 0.002}); err != nil {
+		t.Fatalf("InsertModelPricing: %v", err)
+	}
+
+	result := validateDiscussion(t, h, fmt.Sprintf(`{"topic": "Tabs or spaces", "agent_ids": [%d, %d], "max_rounds": 2, "max_char_limit": 1000, "settings": {"summary_backend": "extractive"}}`, seasoned.ID, newcomer.ID))
+	raw, err := json.Marshal(result["estimate"])
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var estimate models.DiscussionEstimate
+	if err := json.Unmarshal(raw, &estimate); err != nil {
+		t.Fatalf("estimate %s: %v", raw, err)
+	}
+	if estimate.Calls != 4 || estimate.Cost == nil || len(estimate.UnpricedModels) != 0 {
+		t.Errorf("estimate = %d calls costing %v, unpriced %v; want 4 priced calls", estimate.Calls, estimate.Cost, estimate.UnpricedModels)
+	}
+	if len(estimate.Agents) != 2 {
+		t.Fatalf("estimate has %d agents, want 2", len(estimate.Agents))
+	}
+	// 150.5 tokens on average, against a quarter of the character limit
+	seasonedEstimate, newcomerEstimate := estimate.Agents[0], estimate.Agents[1]
+	if seasonedEstimate.AgentID != seasoned.ID || !seasonedEstimate.Historical || seasonedEstimate.CompletionTokens != 2*151 {
+		t.Errorf("seasoned agent estimate = %+v, want 151 tokens a reply from its history", seasonedEstimate)
+	}
+	if newcomerEstimate.AgentID != newcomer.ID || newcomerEstimate.Historical || newcomerEstimate.CompletionTokens != 2*250 {
+		t.Errorf("new agent estimate = %+v, want 250 tokens a reply from the limit", newcomerEstimate)
+	}
+}
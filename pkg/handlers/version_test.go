@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/orchestrator"
+	"court-table-ai/pkg/version"
+)
+
+func TestVersionRecordedWithDiscussion(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	discussion, provider := startGatedDebate(t, db, engine, 1)
+	provider.releaseAll()
+	waitFor(t, "the debate to end", func() bool {
+		d, err := db.GetDiscussion(discussion.ID)
+		return err == nil && !d.InProgress()
+	})
+
+	stored, err := db.GetDiscussion(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussion: %v", err)
+	}
+	if stored.AppVersion == "" || stored.AppVersion != version.Version {
+		t.Errorf("app_version = %q, want %q", stored.AppVersion, version.Version)
+	}
+
+	discussions := NewDiscussionHandler(db, engine, nil)
+	params := map[string]string{"id": strconv.FormatInt(discussion.ID, 10)}
+	for _, tc := range []struct {
+		name    string
+		handler func() *httptest.ResponseRecorder
+		field   func(map[string]interface{}) interface{}
+	}{
+		{"detail", func() *httptest.ResponseRecorder {
+			return call(discussions.GetDiscussion, httptest.NewRequest(http.MethodGet, "/", nil), params)
+		}, func(m map[string]interface{}) interface{} {
+			d, _ := m["discussion"].(map[string]interface{})
+			return d["app_version"]
+		}},
+		{"JSON export", func() *httptest.ResponseRecorder {
+			return call(discussions.ExportDiscussion, httptest.NewRequest(http.MethodGet, "/?format=json", nil), params)
+		}, func(m map[string]interface{}) interface{} {
+			d, _ := m["discussion"].(map[string]interface{})
+			return d["app_version"]
+		}},
+	} {
+		rec := tc.handler()
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); rec.Code != http.StatusOK || err != nil {
+			t.Fatalf("%s = %d %s", tc.name, rec.Code, rec.Body)
+		}
+		if got := tc.field(body); got != version.Version {
+			t.Errorf("%s app_version = %v, want %q", tc.name, got, version.Version)
+		}
+	}
+}
+
+func TestGetVersion(t *testing.T) {
+	db := newTestDB(t)
+	h := NewSystemHandler(db, orchestrator.NewDebateEngine(db))
+
+	rec := call(h.GetVersion, httptest.NewRequest(http.MethodGet, "/api/version", nil), nil)
+	var body struct {
+		Version       string `json:"version"`
+		SchemaVersion int    `json:"schema_version"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("GetVersion = %d %s", rec.Code, rec.Body)
+	}
+	schema, err := db.SchemaVersion()
+	if err != nil {
+		t.Fatalf("SchemaVersion: %v", err)
+	}
+	if body.Version != version.Version || body.SchemaVersion != schema || schema == 0 {
+		t.Errorf("GetVersion = %+v, want version %q and schema %d", body, version.Version, schema)
+	}
+}
+
+func TestGetCapabilitiesFollowsSettings(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	h := NewSystemHandler(db, engine)
+	admin := NewAdminHandler(db, engine)
+
+	capabilities := func() models.Capabilities {
+		t.Helper()
+		rec := call(h.GetCapabilities, httptest.NewRequest(http.MethodGet, "/api/capabilities", nil), nil)
+		var caps models.Capabilities
+		if err := json.Unmarshal(rec.Body.Bytes(), &caps); rec.Code != http.StatusOK || err != nil {
+			t.Fatalf("GetCapabilities = %d %s", rec.Code, rec.Body)
+		}
+		return caps
+	}
+
+	caps := capabilities()
+	if caps.SchemaVersion != models.CapabilitiesSchemaVersion || caps.Version != version.Version || caps.Limits.MaxRounds != models.MaxMaxRounds {
+		t.Errorf("capabilities = %+v", caps)
+	}
+	if !caps.Features[orchestrator.FeatureDuplicateGuard] || caps.Features[orchestrator.FeatureAuth] {
+		t.Errorf("default features = %v", caps.Features)
+	}
+
+	call(admin.UpdateDuplicateGuard, jsonRequest(http.MethodPut, "/api/admin/duplicate-guard", `{"window_seconds": 0}`), nil)
+	if caps := capabilities(); caps.Features[orchestrator.FeatureDuplicateGuard] {
+		t.Error("duplicate_guard still enabled after setting its window to 0")
+	}
+}
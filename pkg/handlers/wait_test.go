@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/orchestrator"
+)
+
+// waitResult is the body of GET /api/discussions/:id/wait
+type waitResult struct {
+	Discussion models.Discussion `json:"discussion"`
+	TimedOut   bool              `json:"timed_out"`
+}
+
+// waitDiscussion calls WaitDiscussion with query and reports how long it took
+func waitDiscussion(t *testing.T, ctx context.Context, h *DiscussionHandler, id int64, query string) (*httptest.ResponseRecorder, waitResult, time.Duration) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/?"+query, nil).WithContext(ctx)
+	start := time.Now()
+	rec := call(h.WaitDiscussion, req, map[string]string{"id": strconv.FormatInt(id, 10)})
+	elapsed := time.Since(start)
+	var result waitResult
+	if rec.Body.Len() > 0 {
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatalf("wait body %q: %v", rec.Body, err)
+		}
+	}
+	return rec, result, elapsed
+}
+
+func TestWaitDiscussionReturnsOnCompletion(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	h := NewDiscussionHandler(db, engine, nil)
+	discussion, provider := startGatedDebate(t, db, engine, 1)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		provider.releaseAll()
+	}()
+	rec, result, elapsed := waitDiscussion(t, context.Background(), h, discussion.ID, "timeout=10")
+	if rec.Code != http.StatusOK || result.TimedOut || result.Discussion.Status != "completed" {
+		t.Errorf("wait = %d %+v, want the completed discussion", rec.Code, result)
+	}
+	if elapsed > 5*time.Second {
+		t.Errorf("wait took %v after the debate completed", elapsed)
+	}
+
+	// Already terminal: no waiting at all
+	rec, result, elapsed = waitDiscussion(t, context.Background(), h, discussion.ID, "timeout=10")
+	if rec.Code != http.StatusOK || result.TimedOut || result.Discussion.Status != "completed" || elapsed > time.Second {
+		t.Errorf("wait on a finished discussion = %d %+v after %v", rec.Code, result, elapsed)
+	}
+}
+
+func TestWaitDiscussionTimeout(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	h := NewDiscussionHandler(db, engine, nil)
+	discussion, _ := startGatedDebate(t, db, engine, 1)
+
+	rec, result, elapsed := waitDiscussion(t, context.Background(), h, discussion.ID, "timeout=1")
+	if rec.Code != http.StatusOK || !result.TimedOut || result.Discussion.Status != "running" {
+		t.Errorf("wait = %d %+v, want a timeout while running", rec.Code, result)
+	}
+	if elapsed < time.Second || elapsed > 3*time.Second {
+		t.Errorf("wait with timeout=1 took %v", elapsed)
+	}
+
+	// A from that no longer matches returns at once
+	rec, result, elapsed = waitDiscussion(t, context.Background(), h, discussion.ID, "timeout=10&from=pending")
+	if rec.Code != http.StatusOK || result.TimedOut || elapsed > time.Second {
+		t.Errorf("wait from another status = %d %+v after %v", rec.Code, result, elapsed)
+	}
+
+	if rec, _, _ := waitDiscussion(t, context.Background(), h, discussion.ID, "timeout=-1"); rec.Code != http.StatusBadRequest {
+		t.Errorf("negative timeout = %d, want 400", rec.Code)
+	}
+}
+
+func TestWaitDiscussionClientDisconnect(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	h := NewDiscussionHandler(db, engine, nil)
+	discussion, _ := startGatedDebate(t, db, engine, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	_, _, elapsed := waitDiscussion(t, ctx, h, discussion.ID, "timeout=60")
+	if elapsed > 5*time.Second {
+		t.Errorf("wait kept going %v after the client left", elapsed)
+	}
+}
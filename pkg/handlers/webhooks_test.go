@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"court-table-ai/pkg/jobs"
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/orchestrator"
+)
+
+func TestWebhookTestFire(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	discussions := NewDiscussionHandler(db, engine, jobs.NewManager())
+	var signature string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(orchestrator.WebhookSignatureHeader)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer receiver.Close()
+
+	for _, body := range []string{`{}`, `{"url": "ftp://example.com/hook"}`, `{"url": "` + receiver.URL + `", "events": ["discussion_exploded"]}`} {
+		if rec := call(discussions.TestWebhook, jsonRequest(http.MethodPost, "/api/webhooks/test", body), nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("test fire with %s = %d, want 400", body, rec.Code)
+		}
+	}
+
+	rec := call(discussions.TestWebhook, jsonRequest(http.MethodPost, "/api/webhooks/test", `{"url": "`+receiver.URL+`", "secret": "s3cret"}`), nil)
+	var delivery models.WebhookDelivery
+	if err := json.Unmarshal(rec.Body.Bytes(), &delivery); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("test fire = %d %s", rec.Code, rec.Body)
+	}
+	if !delivery.Delivered || delivery.StatusCode != http.StatusAccepted || delivery.Event != models.WebhookEventTest || signature == "" {
+		t.Errorf("test fire result = %+v, signature %q", delivery, signature)
+	}
+
+	// A failed delivery is still reported with 200
+	receiver.Close()
+	rec = call(discussions.TestWebhook, jsonRequest(http.MethodPost, "/api/webhooks/test", `{"url": "`+receiver.URL+`"}`), nil)
+	if err := json.Unmarshal(rec.Body.Bytes(), &delivery); rec.Code != http.StatusOK || err != nil || delivery.Delivered || delivery.Error == "" {
+		t.Errorf("test fire to a closed receiver = %d %s, want 200 with the error", rec.Code, rec.Body)
+	}
+}
+
+func TestDiscussionWebhookTestFire(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	discussions := NewDiscussionHandler(db, engine, jobs.NewManager())
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer receiver.Close()
+
+	plain := insertTestDiscussion(t, db, "completed")
+	params := map[string]string{"id": strconv.FormatInt(plain.ID, 10)}
+	if rec := call(discussions.TestDiscussionWebhook, jsonRequest(http.MethodPost, "/", ""), params); rec.Code != http.StatusBadRequest {
+		t.Errorf("test fire of a discussion without a webhook = %d, want 400", rec.Code)
+	}
+
+	hooked := &models.Discussion{Topic: "Tabs or spaces", Status: "completed", MaxRounds: 1, MaxCharLimit: models.DefaultMaxCharLimit,
+		Settings: models.DiscussionSettings{Webhook: &models.DiscussionWebhook{URL: receiver.URL}}}
+	if err := db.InsertDiscussion(hooked); err != nil {
+		t.Fatalf("InsertDiscussion: %v", err)
+	}
+	params = map[string]string{"id": strconv.FormatInt(hooked.ID, 10)}
+	if rec := call(discussions.TestDiscussionWebhook, jsonRequest(http.MethodPost, "/", ""), params); rec.Code != http.StatusOK {
+		t.Fatalf("test fire of the discussion's webhook = %d %s", rec.Code, rec.Body)
+	}
+
+	rec := call(discussions.GetWebhookDeliveries, httptest.NewRequest(http.MethodGet, "/", nil), params)
+	var deliveries []models.WebhookDelivery
+	if err := json.Unmarshal(rec.Body.Bytes(), &deliveries); err != nil || len(deliveries) != 1 || !deliveries[0].Delivered || deliveries[0].DiscussionID != hooked.ID {
+		t.Errorf("deliveries = %d %s, want the test fire", rec.Code, rec.Body)
+	}
+}
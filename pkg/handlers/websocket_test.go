@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/orchestrator"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+)
+
+// wsMessage is one message received on a discussion WebSocket
+type wsMessage struct {
+	ID    string          `json:"id"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// dialDiscussion opens the WebSocket stream of a discussion served by h
+func dialDiscussion(t *testing.T, h *SSEHandler, discussionID int64, lastEventID string) *websocket.Conn {
+	t.Helper()
+	e := echo.New()
+	e.GET("/api/discussions/:id/ws", h.WebSocketDiscussion)
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+
+	url := fmt.Sprintf("ws%s/api/discussions/%d/ws", strings.TrimPrefix(server.URL, "http"), discussionID)
+	if lastEventID != "" {
+		url += "?last_event_id=" + lastEventID
+	}
+	ws, err := websocket.Dial(url, "", server.URL)
+	if err != nil {
+		t.Fatalf("websocket.Dial: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+// readUntilClosed reads messages until the server closes the socket
+func readUntilClosed(t *testing.T, ws *websocket.Conn) []wsMessage {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msgs []wsMessage
+	for {
+		var msg wsMessage
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			if strings.Contains(err.Error(), "timeout") {
+				t.Fatalf("socket still open after %d messages", len(msgs))
+			}
+			return msgs
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+// decoded reads JSON back into plain values so payloads compare by content
+func decoded(t *testing.T, data []byte) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("payload %s is not JSON: %v", data, err)
+	}
+	return v
+}
+
+func TestWebSocketStream(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	h := NewSSEHandler(db, engine)
+	discussion, provider := startGatedDebate(t, db, engine, 1)
+
+	ws := dialDiscussion(t, h, discussion.ID, "")
+	provider.releaseAll()
+	msgs := readUntilClosed(t, ws)
+
+	if len(msgs) < 2 || msgs[0].Event != "status" || msgs[1].Event != orchestrator.EventDiscussion {
+		t.Fatalf("stream opened with %+v, want a status and the discussion", msgs)
+	}
+	var ids []int64
+	for _, msg := range msgs {
+		if msg.Event != orchestrator.EventLog {
+			continue
+		}
+		var frame struct {
+			Log   models.DiscussionLog `json:"log"`
+			Agent struct {
+				Name string `json:"name"`
+			} `json:"agent"`
+		}
+		if err := json.Unmarshal(msg.Data, &frame); err != nil {
+			t.Fatalf("log message %s: %v", msg.Data, err)
+		}
+		if msg.ID == "" || (frame.Log.LogType == models.LogTypeResponse && !strings.HasPrefix(frame.Agent.Name, "Agent ")) {
+			t.Errorf("log message = %+v, want an id and the agent's name", frame)
+		}
+		ids = append(ids, frame.Log.ID)
+	}
+	logs, err := db.GetDiscussionLogs(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	var want []int64
+	for _, l := range logs {
+		want = append(want, l.ID)
+	}
+	checkEachOnce(t, ids, want)
+
+	// The socket closes after the final status
+	last := msgs[len(msgs)-1]
+	var d models.Discussion
+	if err := json.Unmarshal(last.Data, &d); err != nil || last.Event != orchestrator.EventDiscussion || d.Status != "completed" {
+		t.Errorf("last message = %s %s, want the completed discussion", last.Event, last.Data)
+	}
+}
+
+func TestWebSocketMatchesSSE(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	h := NewSSEHandler(db, engine)
+	discussion, provider := startGatedDebate(t, db, engine, 1)
+	provider.releaseAll()
+	waitFor(t, "the debate to end", func() bool {
+		d, err := db.GetDiscussion(discussion.ID)
+		return err == nil && !d.InProgress()
+	})
+
+	// Both transports replay the same transcript from the same cursor
+	sse := connect(t, h, engine, discussion.ID, "log-0", nil)
+	sse.wait(t)
+	msgs := readUntilClosed(t, dialDiscussion(t, h, discussion.ID, "log-0"))
+
+	sse.mu.Lock()
+	frames := append([]streamFrame(nil), sse.frames...)
+	sse.mu.Unlock()
+	if len(msgs) != len(frames) {
+		t.Fatalf("WebSocket sent %d messages, SSE %d frames", len(msgs), len(frames))
+	}
+	for i, f := range frames {
+		data, err := json.Marshal(f.data)
+		if err != nil {
+			t.Fatalf("json.Marshal: %v", err)
+		}
+		if msgs[i].ID != f.id || msgs[i].Event != f.event || !reflect.DeepEqual(decoded(t, msgs[i].Data), decoded(t, data)) {
+			t.Errorf("message %d = %s %s %s, SSE frame = %s %s %s", i, msgs[i].ID, msgs[i].Event, msgs[i].Data, f.id, f.event, data)
+		}
+	}
+}
+
+func TestWebSocketUnsubscribesOnClose(t *testing.T) {
+	db := newTestDB(t)
+	engine := orchestrator.NewDebateEngine(db)
+	h := NewSSEHandler(db, engine)
+	discussion, _ := startGatedDebate(t, db, engine, 1)
+
+	ws := dialDiscussion(t, h, discussion.ID, "")
+	waitFor(t, "the socket to subscribe", func() bool { return engine.SubscriberCounts()[discussion.ID] == 1 })
+	ws.Close()
+	waitFor(t, "the socket to unsubscribe", func() bool { return engine.SubscriberCounts()[discussion.ID] == 0 })
+}
+
+func TestWebSocketUnknownDiscussion(t *testing.T) {
+	db := newTestDB(t)
+	h := NewSSEHandler(db, orchestrator.NewDebateEngine(db))
+	for id, want := range map[string]int{"abc": http.StatusBadRequest, "999": http.StatusNotFound} {
+		rec := call(h.WebSocketDiscussion, httptest.NewRequest(http.MethodGet, "/api/discussions/"+id+"/ws", nil), map[string]string{"id": id})
+		if rec.Code != want {
+			t.Errorf("GET /api/discussions/%s/ws = %d, want %d", id, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package jobs
+
+import (
+	"context"
+	"court-table-ai/pkg/database"
+	"fmt"
+	"time"
+)
+
+// Batched delete tuning: small batches keep the SQLite writer free for live debates
+const (
+	DeleteBatchSize  = 500
+	DeleteBatchPause = 20 * time.Millisecond
+)
+
+// JobTypeDeleteDiscussion identifies background discussion deletes
+const JobTypeDeleteDiscussion = "delete_discussion"
+
+// DeleteDiscussion removes a discussion's logs in batches, then its notes,
+// claims and the discussion itself. The discussion must already be marked as deleting.
+func (m *Manager) DeleteDiscussion(db *database.DB, discussionID int64) Job {
+	return m.Start(JobTypeDeleteDiscussion, discussionID, func(ctx context.Context, progress Progress) error {
+		total, err := db.CountDiscussionLogs(discussionID)
+		if err != nil {
+			return err
+		}
+		progress(0, total)
+
+		var done int64
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			deleted, err := db.DeleteDiscussionLogsBatch(discussionID, DeleteBatchSize)
+			if err != nil {
+				return err
+			}
+			done += deleted
+			progress(done, total)
+
+			if deleted < DeleteBatchSize {
+				break
+			}
+			time.Sleep(DeleteBatchPause)
+		}
+
+		if err := db.DeleteDiscussionNotes(discussionID); err != nil {
+			return err
+		}
+
+		if err := db.DeleteDiscussionClaims(discussionID); err != nil {
+			return err
+		}
+
+		if err := db.DeleteDiscussionAnalysis(discussionID); err != nil {
+			return err
+		}
+
+		if err := db.DeleteAgentScratchpads(discussionID); err != nil {
+			return err
+		}
+
+		if err := db.DeleteDiscussionParticipants(discussionID); err != nil {
+			return err
+		}
+
+		if err := db.DeleteWebhookDeliveries(discussionID); err != nil {
+			return err
+		}
+
+		if err := db.DeleteDiscussion(discussionID); err != nil {
+			return fmt.Errorf("failed to delete discussion %d: %w", discussionID, err)
+		}
+		return nil
+	})
+}
+
+// ResumeDeletes restarts deletes that were interrupted by a shutdown
+func (m *Manager) ResumeDeletes(db *database.DB) error {
+	ids, err := db.GetDeletingDiscussionIDs()
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		m.DeleteDiscussion(db, id)
+	}
+	return nil
+}
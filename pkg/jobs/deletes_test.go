@@ -0,0 +1,171 @@
+package jobs
+
+import (
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+)
+
+func newTestDB(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.CreateTables(); err != nil {
+		t.Fatalf("CreateTables: %v", err)
+	}
+	return db
+}
+
+func insertDiscussion(t *testing.T, db *database.DB, status string) *models.Discussion {
+	t.Helper()
+	discussion := &models.Discussion{Topic: "Tabs or spaces", Status: status, MaxRounds: 1}
+	if err := db.InsertDiscussion(discussion); err != nil {
+		t.Fatalf("InsertDiscussion: %v", err)
+	}
+	return discussion
+}
+
+func insertLog(db *database.DB, discussionID int64, content string) (*models.DiscussionLog, error) {
+	l := &models.DiscussionLog{DiscussionID: discussionID, AgentID: models.SystemAgentID, Content: content, Status: "success"}
+	return l, db.InsertDiscussionLog(l)
+}
+
+func TestDeleteDiscussionInBatches(t *testing.T) {
+	db := newTestDB(t)
+	doomed := insertDiscussion(t, db, "completed")
+	live := insertDiscussion(t, db, "running")
+
+	const logs = 2*DeleteBatchSize + 37
+	var first *models.DiscussionLog
+	for i := 0; i < logs; i++ {
+		l, err := insertLog(db, doomed.ID, "old turn")
+		if err != nil {
+			t.Fatalf("InsertDiscussionLog: %v", err)
+		}
+		if first == nil {
+			first = l
+		}
+	}
+	if err := db.InsertDiscussionAnnotation(&models.DiscussionAnnotation{DiscussionID: doomed.ID, LogID: first.ID, EndOffset: 3, Comment: "typo"}); err != nil {
+		t.Fatalf("InsertDiscussionAnnotation: %v", err)
+	}
+	if err := db.SetLogRating(&models.LogRating{DiscussionID: doomed.ID, LogID: first.ID, Rating: 1}); err != nil {
+		t.Fatalf("SetLogRating: %v", err)
+	}
+
+	if err := db.MarkDiscussionDeleting(doomed.ID, false); err != nil {
+		t.Fatalf("MarkDiscussionDeleting: %v", err)
+	}
+	listed, err := db.GetRecentDiscussions(10)
+	if err != nil {
+		t.Fatalf("GetRecentDiscussions: %v", err)
+	}
+	for _, d := range listed {
+		if d.ID == doomed.ID {
+			t.Errorf("discussion marked deleting is still listed")
+		}
+	}
+
+	// A live debate keeps writing while the delete runs
+	var (
+		stop      atomic.Bool
+		wg        sync.WaitGroup
+		inserted  atomic.Int64
+		insertErr atomic.Value
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for !stop.Load() {
+			if _, err := insertLog(db, live.ID, "new turn"); err != nil {
+				insertErr.Store(err)
+				return
+			}
+			inserted.Add(1)
+			// Pace the writes like a debate does; a loop that never lets go
+			// of the write lock starves the delete past busy_timeout
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	m := NewManager()
+	finished := make(chan Job, 1)
+	m.OnFinish = func(job Job) { finished <- job }
+	started := m.DeleteDiscussion(db, doomed.ID)
+	if started.Status != StatusRunning || started.Type != JobTypeDeleteDiscussion || started.Target != doomed.ID {
+		t.Errorf("started job = %+v", started)
+	}
+
+	var job Job
+	select {
+	case job = <-finished:
+	case <-time.After(30 * time.Second):
+		t.Fatal("delete job did not finish")
+	}
+	stop.Store(true)
+	wg.Wait()
+
+	if job.Status != StatusCompleted || job.Done != logs || job.Total != logs || job.FinishedAt == nil {
+		t.Errorf("finished job = %+v, want %d of %d logs deleted", job, logs, logs)
+	}
+	if got, ok := m.Get(started.ID); !ok || got.Status != StatusCompleted {
+		t.Errorf("Get(%s) = %+v, %v", started.ID, got, ok)
+	}
+	if err, _ := insertErr.Load().(error); err != nil {
+		t.Errorf("insert for another discussion failed during the delete: %v", err)
+	}
+	if inserted.Load() == 0 {
+		t.Error("no inserts ran alongside the delete")
+	}
+
+	if _, err := db.GetDiscussion(doomed.ID); err != database.ErrDiscussionNotFound {
+		t.Errorf("GetDiscussion after delete = %v, want ErrDiscussionNotFound", err)
+	}
+	if n, err := db.CountDiscussionLogs(doomed.ID); err != nil || n != 0 {
+		t.Errorf("%d logs left (%v), want none", n, err)
+	}
+	if ratings, err := db.GetDiscussionRatings(doomed.ID); err != nil || len(ratings) != 0 {
+		t.Errorf("ratings left: %v (%v)", ratings, err)
+	}
+	if n, err := db.CountDiscussionLogs(live.ID); err != nil || n != inserted.Load() {
+		t.Errorf("live discussion has %d logs (%v), want %d", n, err, inserted.Load())
+	}
+}
+
+func TestResumeDeletes(t *testing.T) {
+	db := newTestDB(t)
+	doomed := insertDiscussion(t, db, "completed")
+	kept := insertDiscussion(t, db, "completed")
+	if _, err := insertLog(db, doomed.ID, "old turn"); err != nil {
+		t.Fatalf("InsertDiscussionLog: %v", err)
+	}
+	if err := db.MarkDiscussionDeleting(doomed.ID, false); err != nil {
+		t.Fatalf("MarkDiscussionDeleting: %v", err)
+	}
+
+	m := NewManager()
+	finished := make(chan Job, 1)
+	m.OnFinish = func(job Job) { finished <- job }
+	if err := m.ResumeDeletes(db); err != nil {
+		t.Fatalf("ResumeDeletes: %v", err)
+	}
+	select {
+	case job := <-finished:
+		if job.Status != StatusCompleted || job.Target != doomed.ID {
+			t.Errorf("resumed job = %+v", job)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("resumed delete did not finish")
+	}
+	if _, err := db.GetDiscussion(kept.ID); err != nil {
+		t.Errorf("the other discussion is gone: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Job statuses
+const (
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// Job is a long-running background task such as a batched delete
+type Job struct {
+	ID         string     `json:"id"`
+	Type       string     `json:"type"`
+	Target     int64      `json:"target_id,omitempty"`
+	Status     string     `json:"status"`
+	Done       int64      `json:"done"`
+	Total      int64      `json:"total"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Manager runs jobs in the background and keeps their progress in memory
+type Manager struct {
+	mu   sync.RWMutex
+	jobs map[string]*Job
+
+	// OnFinish is called after a job completes or fails
+	OnFinish func(job Job)
+}
+
+// NewManager creates a new job manager
+func NewManager() *Manager {
+	return &Manager{jobs: make(map[string]*Job)}
+}
+
+// Progress lets a running job report how far it has got
+type Progress func(done, total int64)
+
+// Start runs fn in a new goroutine and returns a snapshot of the job
+func (m *Manager) Start(jobType string, target int64, fn func(ctx context.Context, progress Progress) error) Job {
+	job := &Job{
+		ID:        newJobID(),
+		Type:      jobType,
+		Target:    target,
+		Status:    StatusRunning,
+		StartedAt: time.Now(),
+	}
+
+	m.mu.Lock()
+	m.jobs[job.ID] = job
+	snapshot := *job
+	m.mu.Unlock()
+
+	go func() {
+		err := fn(context.Background(), func(done, total int64) {
+			m.mu.Lock()
+			job.Done = done
+			job.Total = total
+			m.mu.Unlock()
+		})
+
+		m.mu.Lock()
+		now := time.Now()
+		job.FinishedAt = &now
+		if err != nil {
+			job.Status = StatusFailed
+			job.Error = err.Error()
+			log.Printf("Job %s (%s) failed: %v", job.ID, job.Type, err)
+		} else {
+			job.Status = StatusCompleted
+			log.Printf("Job %s (%s) completed", job.ID, job.Type)
+		}
+		finished := *job
+		m.mu.Unlock()
+
+		if m.OnFinish != nil {
+			m.OnFinish(finished)
+		}
+	}()
+
+	return snapshot
+}
+
+// Get returns a snapshot of a job by ID
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// newJobID returns a random identifier for a job
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
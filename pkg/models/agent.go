@@ -3,22 +3,198 @@ package models
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 )
 
 // Agent represents an AI agent configuration
 type Agent struct {
 	ID            int64     `json:"id" db:"id"`
 	Name          string    `json:"name" db:"name"`
-	ProviderType  string    `json:"provider_type" db:"provider_type"` // ollama, openai, anthropic, google, custom
+	ProviderType  string    `json:"provider_type" db:"provider_type"` // ollama, openai, azure, anthropic, google, custom
 	ProviderURL   string    `json:"provider_url" db:"provider_url"`
 	APIToken      string    `json:"api_token" db:"api_token"`
-	ModelName     string    `json:"model_name" db:"model_name"`
+	ModelName     string    `json:"model_name" db:"model_name"` // the deployment name for Azure OpenAI
+	APIVersion    string    `json:"api_version" db:"api_version"` // Azure OpenAI only; empty takes ?api-version= from the URL or the default
+	ExtraHeaders  JSONMap   `json:"extra_headers" db:"extra_headers"` // sent with every request, e.g. OpenRouter's HTTP-Referer; secret values are masked by the agents API
 	TimeoutSeconds int      `json:"timeout_seconds" db:"timeout_seconds"`
+	EndpointStyle string    `json:"endpoint_style" db:"endpoint_style"` // chat_completions, completions, responses; empty probes
+	SystemPrompt  string    `json:"system_prompt" db:"system_prompt"` // persona put ahead of the debate instructions; empty for none
+	Disabled      bool      `json:"disabled" db:"disabled"` // stub agents created for imported transcripts are never called
+	Trace         bool      `json:"trace" db:"trace"`       // record a CallTrace of every call in the log metadata
 	CreatedAt     time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+
+	// Reliability is computed from past discussions and only set by the agents API
+	Reliability *AgentReliability `json:"reliability,omitempty" db:"-"`
+	// TokenFingerprint and SharedKeyWarning are only set by the agents API.
+	// The warning is set when other agents use the same token and it was
+	// recently rate limited.
+	TokenFingerprint string `json:"token_fingerprint,omitempty" db:"-"`
+	SharedKeyWarning string `json:"shared_key_warning,omitempty" db:"-"`
+}
+
+// Endpoint styles an agent can pin for OpenAI-compatible providers
+const (
+	EndpointStyleChatCompletions = "chat_completions"
+	EndpointStyleCompletions     = "completions"
+	EndpointStyleResponses       = "responses"
+)
+
+// Provider types an agent can be called with
+const (
+	ProviderOllama    = "ollama"
+	ProviderOpenAI    = "openai"
+	ProviderAzure     = "azure"
+	ProviderAnthropic = "anthropic"
+	ProviderGoogle    = "google"
+	ProviderCustom    = "custom"
+)
+
+// ProviderTypes lists the accepted provider types
+var ProviderTypes = []string{ProviderOllama, ProviderOpenAI, ProviderAzure, ProviderAnthropic, ProviderGoogle, ProviderCustom}
+
+// DetectProviderType guesses the provider type from a provider URL. It only
+// recognises the providers' own hosts; gateways and proxies on other
+// domains come out as custom.
+func DetectProviderType(url string) string {
+	if strings.Contains(url, "ollama") || strings.Contains(url, "localhost:11434") {
+		return ProviderOllama
+	} else if strings.Contains(url, "openai.azure.com") {
+		return ProviderAzure
+	} else if strings.Contains(url, "openai.com") {
+		return ProviderOpenAI
+	} else if strings.Contains(url, "anthropic.com") {
+		return ProviderAnthropic
+	} else if strings.Contains(url, "googleapis.com") {
+		return ProviderGoogle
+	}
+	return ProviderCustom
+}
+
+// EffectiveProviderType returns the agent's provider type, guessed from its
+// URL only for agents stored without one
+func (a *Agent) EffectiveProviderType() string {
+	if a.ProviderType == "" {
+		return DetectProviderType(a.ProviderURL)
+	}
+	return a.ProviderType
+}
+
+// DefaultAzureAPIVersion is the Azure OpenAI API version used when neither
+// the agent nor its URL names one
+const DefaultAzureAPIVersion = "2024-06-01"
+
+// Field length limits for agent configuration
+const (
+	MaxAgentNameLength   = 100
+	MaxModelNameLength   = 200
+	MaxProviderURLLength = 2048
+	MaxAPITokenLength    = 4096
+	MaxAPIVersionLength  = 50
+)
+
+// Validate trims the agent's string fields and checks them against the allowed
+// lengths and character sets. It must be called before an agent is persisted.
+func (a *Agent) Validate() error {
+	a.Name = strings.TrimSpace(a.Name)
+	a.ProviderType = strings.ToLower(strings.TrimSpace(a.ProviderType))
+	a.ProviderURL = strings.TrimSpace(a.ProviderURL)
+	a.APIToken = strings.TrimSpace(a.APIToken)
+	a.ModelName = strings.TrimSpace(a.ModelName)
+	a.APIVersion = strings.TrimSpace(a.APIVersion)
+
+	if a.Name == "" || a.ProviderURL == "" || a.ModelName == "" {
+		return errors.New("name, provider_url, and model_name are required")
+	}
+
+	if len([]rune(a.Name)) > MaxAgentNameLength {
+		return fmt.Errorf("name must be at most %d characters", MaxAgentNameLength)
+	}
+	if len([]rune(a.ModelName)) > MaxModelNameLength {
+		return fmt.Errorf("model_name must be at most %d characters", MaxModelNameLength)
+	}
+	if len(a.ProviderURL) > MaxProviderURLLength {
+		return fmt.Errorf("provider_url must be at most %d characters", MaxProviderURLLength)
+	}
+	if len(a.APIToken) > MaxAPITokenLength {
+		return fmt.Errorf("api_token must be at most %d characters", MaxAPITokenLength)
+	}
+
+	if hasControlChars(a.Name) {
+		return errors.New("name must not contain control characters")
+	}
+	if hasControlChars(a.ModelName) {
+		return errors.New("model_name must not contain control characters")
+	}
+	if hasControlChars(a.ProviderURL) || hasControlChars(a.APIToken) {
+		return errors.New("provider_url and api_token must not contain control characters")
+	}
+
+	// An omitted provider type is resolved once and stored, so the agent is
+	// not re-guessed on every call
+	if a.ProviderType == "" {
+		a.ProviderType = DetectProviderType(a.ProviderURL)
+	}
+	valid := false
+	for _, t := range ProviderTypes {
+		valid = valid || a.ProviderType == t
+	}
+	if !valid {
+		return fmt.Errorf("provider_type must be one of %s", strings.Join(ProviderTypes, ", "))
+	}
+
+	a.SystemPrompt = strings.TrimSpace(a.SystemPrompt)
+	if len(a.SystemPrompt) > MaxSystemPromptLength {
+		return fmt.Errorf("system_prompt must be at most %d characters", MaxSystemPromptLength)
+	}
+
+	a.EndpointStyle = strings.TrimSpace(a.EndpointStyle)
+	switch a.EndpointStyle {
+	case "", EndpointStyleChatCompletions, EndpointStyleCompletions, EndpointStyleResponses:
+	default:
+		return fmt.Errorf("endpoint_style must be one of %s, %s, %s", EndpointStyleChatCompletions, EndpointStyleCompletions, EndpointStyleResponses)
+	}
+
+	// Some providers build the request path from the model name, so it must
+	// not be able to escape its path segment
+	if a.ProviderType == ProviderGoogle || a.ProviderType == ProviderAzure {
+		if strings.ContainsAny(a.ModelName, "/\\?#") || strings.Contains(a.ModelName, "..") || strings.ContainsRune(a.ModelName, ' ') {
+			return errors.New("model_name must not contain spaces, path separators, '?', '#' or '..'")
+		}
+	}
+
+	headers, err := validateExtraHeaders(a.ExtraHeaders)
+	if err != nil {
+		return err
+	}
+	a.ExtraHeaders = headers
+
+	// The API version goes into the query string as it is
+	for _, r := range a.APIVersion {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '.' {
+			return errors.New("api_version may only contain letters, digits, '-' and '.'")
+		}
+	}
+	if len(a.APIVersion) > MaxAPIVersionLength {
+		return fmt.Errorf("api_version must be at most %d characters", MaxAPIVersionLength)
+	}
+
+	return nil
+}
+
+// hasControlChars reports whether s contains any Unicode control character
+func hasControlChars(s string) bool {
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			return true
+		}
+	}
+	return false
 }
 
 // Discussion represents a debate/discussion session
@@ -26,14 +202,93 @@ type Discussion struct {
 	ID           int64              `json:"id" db:"id"`
 	Topic        string             `json:"topic" db:"topic"`
 	FinalSummary string             `json:"final_summary" db:"final_summary"`
-	Status       string             `json:"status" db:"status"` // running, completed, failed
+	Status       string             `json:"status" db:"status"` // running, paused, completed, stopped, failed
 	AgentIDs     JSONSlice[int64]   `json:"agent_ids" db:"agent_ids"`
 	ModeratorID  *int64             `json:"moderator_id" db:"moderator_id"` // nullable
+	JudgeID      *int64             `json:"judge_id" db:"judge_id"`         // nullable
 	MaxRounds    int                `json:"max_rounds" db:"max_rounds"`
 	Language     string             `json:"language" db:"language"`
 	MaxCharLimit int                `json:"max_char_limit" db:"max_char_limit"`
-	CreatedAt    time.Time          `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time          `json:"updated_at" db:"updated_at"`
+	AppVersion   string             `json:"app_version" db:"app_version"`
+	Settings     DiscussionSettings `json:"settings" db:"settings"`
+	ErrorMessage string             `json:"error_message,omitempty" db:"error_message"` // why a discussion failed
+	// CompletedRounds counts rounds that finished, which can be fewer than
+	// MaxRounds when the debate ended early; EndReason says why it ended
+	CompletedRounds int       `json:"completed_rounds" db:"completed_rounds"`
+	EndReason       string    `json:"end_reason,omitempty" db:"end_reason"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+
+	// EstimatedCost in USD is only set by the discussion API, and left out
+	// when any call in the discussion cannot be priced
+	EstimatedCost *float64 `json:"estimated_cost,omitempty" db:"-"`
+
+	// Stances are the sides assigned to agents, kept in discussion_participants
+	Stances []AgentStance `json:"stances,omitempty" db:"-"`
+	// Verdict is the judge's ruling, stored in the verdict_* columns once the
+	// judge has answered
+	Verdict *Verdict `json:"verdict,omitempty" db:"-"`
+	// SelfTest marks the throwaway discussion of a self-test run, which
+	// discussion lists leave out. It is only written.
+	SelfTest bool `json:"-" db:"self_test"`
+}
+
+// InProgress reports whether the discussion's debate has not ended, which
+// includes a paused debate
+func (d *Discussion) InProgress() bool {
+	return d.Status == "running" || d.Status == "paused"
+}
+
+// PromptProfile returns the prompt profile the discussion uses: the one chosen
+// in its settings, or compact when MaxCharLimit is at most CompactCharLimit
+func (d *Discussion) PromptProfile() string {
+	if d.Settings.PromptProfile != "" {
+		return d.Settings.PromptProfile
+	}
+	if d.MaxCharLimit > 0 && d.MaxCharLimit <= CompactCharLimit {
+		return PromptProfileCompact
+	}
+	return PromptProfileStandard
+}
+
+// Defaults and limits for max_rounds and max_char_limit of a new discussion
+const (
+	DefaultMaxRounds    = 3
+	MinMaxRounds        = 1
+	MaxMaxRounds        = 20
+	DefaultMaxCharLimit = 2000
+	MinMaxCharLimit     = 200
+	MaxMaxCharLimit     = 20000
+)
+
+// Why a discussion ended, recorded in Discussion.EndReason
+const (
+	EndReasonMaxRounds     = "max_rounds"      // every round ran
+	EndReasonAllAgentsDone = "all_agents_done" // no agent answered in a later round
+	EndReasonConsensus     = "consensus"       // the consensus check found the positions converged
+	EndReasonBudget        = "budget"          // the token budget could not cover the next turn
+	EndReasonStopped       = "stopped"         // stopped by the user
+	EndReasonFailed        = "failed"          // every agent failed in round 1, the watchdog gave up or the engine crashed
+)
+
+// EndReasonText describes an end reason for summaries
+func EndReasonText(reason string) string {
+	switch reason {
+	case EndReasonMaxRounds:
+		return "it reached the maximum number of rounds"
+	case EndReasonAllAgentsDone:
+		return "no agent had anything further to add"
+	case EndReasonConsensus:
+		return "the participants reached consensus"
+	case EndReasonBudget:
+		return "its token budget ran out"
+	case EndReasonStopped:
+		return "it was stopped by the user"
+	case EndReasonFailed:
+		return "it failed"
+	default:
+		return ""
+	}
 }
 
 // DiscussionLog represents individual agent responses in a discussion
@@ -42,12 +297,44 @@ type DiscussionLog struct {
 	DiscussionID int64     `json:"discussion_id" db:"discussion_id"`
 	AgentID      int64     `json:"agent_id" db:"agent_id"`
 	Content      string    `json:"content" db:"content"`
-	Status       string    `json:"status" db:"status"` // success, timeout, error
+	Status       string    `json:"status" db:"status"` // success, timeout, error, skipped, rejected
 	ResponseTime int       `json:"response_time" db:"response_time"` // in milliseconds
 	IsModerator  bool      `json:"is_moderator" db:"is_moderator"` // moderator role indicator
+	Metadata     JSONMap   `json:"metadata,omitempty" db:"metadata"`
+	LogType      string    `json:"log_type" db:"log_type"` // response, skip, system
+	Sequence     int64     `json:"sequence" db:"sequence"` // per-discussion order, 0 for entries predating sequence tracking
+	PromptTokens     int   `json:"prompt_tokens" db:"prompt_tokens"`         // 0 when the provider reported no usage
+	CompletionTokens int   `json:"completion_tokens" db:"completion_tokens"`
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+
+	// EstimatedCost in USD is only set by the discussion API, and left out
+	// when the call reported no usage or its model has no pricing
+	EstimatedCost *float64 `json:"estimated_cost,omitempty" db:"-"`
+
+	// Stance is the side the agent was assigned, left out when it has none
+	Stance string `json:"stance,omitempty" db:"-"`
+
+	// Anchor is the entry's stable deep-link ID, only set by the discussion
+	// API and pages
+	Anchor string `json:"anchor,omitempty" db:"-"`
+}
+
+// IsSystem reports whether the entry was written by the engine rather than an agent
+func (l *DiscussionLog) IsSystem() bool {
+	return l.AgentID == SystemAgentID
 }
 
+// Discussion log types
+const (
+	LogTypeResponse = "response"
+	LogTypeSkip     = "skip"
+	LogTypeSystem   = "system"
+)
+
+// SystemAgentID is the reserved author of engine-generated log entries.
+// It is stored as a NULL agent_id and never matches a real agent.
+const SystemAgentID int64 = 0
+
 // JSONSlice is a custom type for handling JSON arrays in database
 type JSONSlice[T any] []T
 
@@ -55,12 +342,26 @@ func (j JSONSlice[T]) Value() (driver.Value, error) {
 	return json.Marshal(j)
 }
 
+// LegacyJSONSlices enables the comma-separated fallback in JSONSlice.Scan
+// for databases written before list columns were stored as JSON. It is set
+// once at startup.
+var LegacyJSONSlices bool
+
+// Scan implements sql.Scanner. It rejects anything that is not a JSON array
+// of T unless LegacyJSONSlices is set.
 func (j *JSONSlice[T]) Scan(value interface{}) error {
+	_, err := j.ScanFallback(value)
+	return err
+}
+
+// ScanFallback scans like Scan and also reports whether the legacy
+// comma-separated fallback was needed, so callers can log which row used it
+func (j *JSONSlice[T]) ScanFallback(value interface{}) (bool, error) {
 	if value == nil {
 		*j = JSONSlice[T]{}
-		return nil
+		return false, nil
 	}
-	
+
 	var data []byte
 	switch v := value.(type) {
 	case []byte:
@@ -68,45 +369,103 @@ func (j *JSONSlice[T]) Scan(value interface{}) error {
 	case string:
 		data = []byte(v)
 	default:
-		return fmt.Errorf("unexpected type for JSONSlice: %T", value)
+		return false, fmt.Errorf("unexpected type for JSONSlice: %T", value)
 	}
 
-	if len(data) == 0 {
+	strData := strings.TrimSpace(string(data))
+	if strData == "" || strData == "null" {
 		*j = JSONSlice[T]{}
-		return nil
+		return false, nil
 	}
 
-	// Try to unmarshal as JSON
-	err := json.Unmarshal(data, j)
-	if err == nil {
-		return nil
+	if strings.HasPrefix(strData, "[") {
+		result, err := parseJSONSliceArray[T]([]byte(strData))
+		if err != nil {
+			return false, fmt.Errorf("invalid JSONSlice value %s: %w", quoteRaw(strData), err)
+		}
+		*j = result
+		return false, nil
+	}
+
+	if !LegacyJSONSlices {
+		return false, fmt.Errorf("invalid JSONSlice value %s: not a JSON array", quoteRaw(strData))
 	}
 
-	// Fallback for legacy or malformed data: try to parse as comma-separated if it's not valid JSON
-	// This is a safety measure to prevent Internal Server Errors on older records
-	strData := string(data)
-	if !strings.HasPrefix(strData, "[") {
-		parts := strings.Split(strData, ",")
-		var result []T
-		for _, p := range parts {
-			p = strings.TrimSpace(p)
-			if p == "" {
-				continue
+	// Legacy comma-separated data; every part must still convert
+	var result []T
+	for _, p := range strings.Split(strData, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		val, err := parseJSONSliceElement[T](p)
+		if err != nil {
+			return true, fmt.Errorf("invalid JSONSlice value %s: element %q: %w", quoteRaw(strData), p, err)
+		}
+		result = append(result, val)
+	}
+	*j = result
+	return true, nil
+}
+
+// parseJSONSliceArray decodes a JSON array, accepting numbers sent as
+// strings (["1","2"]) for numeric element types
+func parseJSONSliceArray[T any](data []byte) (JSONSlice[T], error) {
+	var result JSONSlice[T]
+	if err := json.Unmarshal(data, &result); err == nil {
+		if result == nil {
+			result = JSONSlice[T]{}
+		}
+		return result, nil
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal(data, &elements); err != nil {
+		return nil, err
+	}
+
+	result = make(JSONSlice[T], 0, len(elements))
+	for i, raw := range elements {
+		var val T
+		if err := json.Unmarshal(raw, &val); err != nil {
+			var str string
+			if json.Unmarshal(raw, &str) != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
 			}
-			var val T
-			// Use JSON to convert the string to the target type T if possible
-			// This works for basic types like int64 or string
-			if err := json.Unmarshal([]byte(fmt.Sprintf("%q", p)), &val); err == nil {
-				result = append(result, val)
-			} else if err := json.Unmarshal([]byte(p), &val); err == nil {
-				result = append(result, val)
+			if val, err = parseJSONSliceElement[T](strings.TrimSpace(str)); err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
 			}
 		}
-		*j = result
-		return nil
+		result = append(result, val)
 	}
+	return result, nil
+}
+
+// parseJSONSliceElement converts a bare value such as 12 or abc to T
+func parseJSONSliceElement[T any](s string) (T, error) {
+	var val T
+	if err := json.Unmarshal([]byte(s), &val); err == nil {
+		return val, nil
+	}
+	// Strings are written bare in legacy data
+	err := json.Unmarshal([]byte(strconv.Quote(s)), &val)
+	return val, err
+}
 
-	return fmt.Errorf("failed to unmarshal JSONSlice: %w", err)
+// quoteRaw quotes a raw column value for error messages, truncating long values
+func quoteRaw(s string) string {
+	const max = 100
+	if len(s) > max {
+		return strconv.Quote(s[:max]) + "..."
+	}
+	return strconv.Quote(s)
+}
+
+// ProviderPauses is the administrator kill switch for provider calls
+type ProviderPauses struct {
+	PauseAll      bool     `json:"pause_all"`
+	ProviderTypes []string `json:"provider_types"`
+	Hosts         []string `json:"hosts"`
 }
 
 // AgentRequest represents a request to an AI agent
@@ -123,4 +482,143 @@ type AgentResponse struct {
 	ErrorMessage string            `json:"error_message,omitempty"`
 	ResponseTime int               `json:"response_time"` // in milliseconds
 	Metadata     map[string]string `json:"metadata,omitempty"`
+	// Token counts reported by the provider, zero when it reports none
+	PromptTokens     int `json:"prompt_tokens,omitempty"`
+	CompletionTokens int `json:"completion_tokens,omitempty"`
+}
+
+// LogDelta is the partial content of a turn still being generated. Content
+// is cumulative, so a client can render the latest delta of a turn as it is
+// and ignore any with a lower Sequence. The last delta of a turn is Final.
+type LogDelta struct {
+	DiscussionID int64  `json:"discussion_id"`
+	AgentID      int64  `json:"agent_id"`
+	Round        int    `json:"round"`
+	IsModerator  bool   `json:"is_moderator"`
+	Sequence     int    `json:"sequence"` // 1-based, per turn
+	Content      string `json:"content"`
+	Final        bool   `json:"final"`
+}
+
+// EngineEvent is an engine-wide notification sent on the global event stream
+type EngineEvent struct {
+	Type         string    `json:"type"`
+	DiscussionID int64     `json:"discussion_id,omitempty"`
+	Message      string    `json:"message"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AgentCompliance summarizes how well one agent followed a discussion's
+// character limit and language across its successful turns, how many of its
+// replies the acceptance rules rejected, and how many the injection guard
+// flagged for review
+type AgentCompliance struct {
+	AgentID            int64    `json:"agent_id"`
+	Turns              int      `json:"turns"`
+	OverLimitTurns     int      `json:"over_limit_turns"`
+	TotalOverLimitBy   int      `json:"total_over_limit_by"`
+	MaxOverLimitBy     int      `json:"max_over_limit_by"`
+	LanguageChecked    int      `json:"language_checked"`
+	LanguageMismatches int      `json:"language_mismatches"`
+	Rejections         int      `json:"rejections"`
+	RejectionReasons   []string `json:"rejection_reasons,omitempty"`
+	InjectionFlags     int      `json:"injection_flags"`
+	FlaggedLogIDs      []int64  `json:"flagged_log_ids,omitempty"`
+}
+
+// Citation is a URL cited in a discussion with the turns and agents citing it
+type Citation struct {
+	URL      string  `json:"url"`
+	AgentIDs []int64 `json:"agent_ids"`
+	LogIDs   []int64 `json:"log_ids"`
 }
+
+// DiscussionReplay is the state of a discussion as of a point in its transcript
+type DiscussionReplay struct {
+	Discussion       *Discussion      `json:"discussion"`
+	Logs             []*DiscussionLog `json:"logs"`
+	Upto             int64            `json:"upto"`
+	Total            int64            `json:"total"`
+	Ordering         string           `json:"ordering"` // sequence, or timestamp for older discussions
+	Round            int              `json:"round"`
+	Phase            string           `json:"phase"` // opening, agent, interim, round_summary, closing_statement, closing
+	CompletedTurns   int              `json:"completed_turns"`
+	Context          string           `json:"context"`
+	ModeratorContext string           `json:"moderator_context,omitempty"`
+	Finished         bool             `json:"finished"`
+}
+
+// AgentOutcome is one past call to an agent, as used for reliability scoring
+type AgentOutcome struct {
+	AgentID      int64
+	Status       string // success, timeout, error
+	TimedOut     bool
+	ResponseTime int // in milliseconds
+	CreatedAt    time.Time
+}
+
+// AgentReliability summarises how dependably an agent has answered. Rates and
+// latency are weighted towards recent calls.
+type AgentReliability struct {
+	AgentID      int64   `json:"agent_id"`
+	Score        int     `json:"score"` // 0-100
+	Rated        bool    `json:"rated"` // false until there are enough calls to judge
+	Samples      int     `json:"samples"`
+	SuccessRate  float64 `json:"success_rate"`
+	TimeoutRate  float64 `json:"timeout_rate"`
+	AvgLatencyMs int     `json:"avg_latency_ms"`
+	Ratings      int     `json:"ratings"`    // reader ratings of the agent's turns
+	AvgRating    float64 `json:"avg_rating"` // -1 to 1, weighted towards recent ratings
+}
+
+// AgentLatency describes the spread of an agent's response times, which an
+// average hides. Percentiles use the nearest-rank method.
+type AgentLatency struct {
+	AgentID   int64           `json:"agent_id"`
+	Samples   int             `json:"samples"`
+	P50Ms     int             `json:"p50_ms"`
+	P90Ms     int             `json:"p90_ms"`
+	P99Ms     int             `json:"p99_ms"`
+	MaxMs     int             `json:"max_ms"`
+	Histogram []LatencyBucket `json:"histogram"`
+}
+
+// LatencyBucket counts calls that took at most UpToMs milliseconds and more
+// than the previous bucket's bound. The last bucket has no bound (UpToMs 0).
+type LatencyBucket struct {
+	UpToMs int `json:"up_to_ms,omitempty"`
+	Count  int `json:"count"`
+}
+
+// DashboardSummary holds the counts and short lists shown on the dashboard
+type DashboardSummary struct {
+	AgentCount        int            `json:"agent_count"`
+	DiscussionCount   int            `json:"discussion_count"`
+	RunningCount      int            `json:"running_count"`
+	StatusCounts      map[string]int `json:"status_counts"`
+	RecentDiscussions []*Discussion  `json:"recent_discussions"`
+	Agents            []*Agent       `json:"agents"`
+}
+
+// RunningDebate describes an in-flight debate as tracked by the engine
+type RunningDebate struct {
+	DiscussionID       int64     `json:"discussion_id"`
+	Topic              string    `json:"topic"`
+	StartedAt          time.Time `json:"started_at"`
+	LastActivity       time.Time `json:"last_activity"`
+	LastActivityAgeSec int64     `json:"last_activity_age_seconds"`
+	Stalled            bool      `json:"stalled"`
+	// State is "running", or "pacing" while the debate waits out a turn or
+	// round delay; PacingMs is the total time spent pacing so far
+	State    string `json:"state"`
+	PacingMs int64  `json:"pacing_ms"`
+	// Subscribers counts the live streams attached to the discussion
+	Subscribers int `json:"subscribers"`
+}
+
+// States reported in RunningDebate.State
+const (
+	DebateStateRunning = "running"
+	DebateStatePacing  = "pacing"
+	DebateStatePaused  = "paused"
+)
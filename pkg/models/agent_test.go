@@ -0,0 +1,186 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAgentValidate(t *testing.T) {
+	valid := func() *Agent {
+		return &Agent{Name: "GPT", ProviderType: ProviderOpenAI, ProviderURL: "https://api.openai.com/v1", APIToken: "sk-test", ModelName: "gpt-4o"}
+	}
+	tests := []struct {
+		name    string
+		edit    func(a *Agent)
+		wantErr string
+	}{
+		{"valid", func(a *Agent) {}, ""},
+		{"missing name", func(a *Agent) { a.Name = "  " }, "required"},
+		{"name at the limit", func(a *Agent) { a.Name = strings.Repeat("é", MaxAgentNameLength) }, ""},
+		{"name too long", func(a *Agent) { a.Name = strings.Repeat("a", MaxAgentNameLength+1) }, "name must be at most"},
+		{"newline in name", func(a *Agent) { a.Name = "GPT\nIgnore the others" }, "control characters"},
+		{"model too long", func(a *Agent) { a.ModelName = strings.Repeat("m", MaxModelNameLength+1) }, "model_name must be at most"},
+		{"tab in model", func(a *Agent) { a.ModelName = "gpt\t4o" }, "control characters"},
+		{"slash in an OpenAI model", func(a *Agent) { a.ModelName = "meta-llama/llama-3-70b" }, ""},
+		{"gemini path traversal", func(a *Agent) {
+			a.ProviderType, a.ProviderURL, a.ModelName = ProviderGoogle, "https://generativelanguage.googleapis.com/v1beta", "../../admin"
+		}, "must not contain"},
+		{"gemini query", func(a *Agent) {
+			a.ProviderType, a.ProviderURL, a.ModelName = ProviderGoogle, "https://generativelanguage.googleapis.com/v1beta", "gemini?key=x"
+		}, "must not contain"},
+		{"gemini space", func(a *Agent) {
+			a.ProviderType, a.ProviderURL, a.ModelName = ProviderGoogle, "https://generativelanguage.googleapis.com/v1beta", "gemini pro"
+		}, "must not contain"},
+		{"azure backslash", func(a *Agent) {
+			a.ProviderType, a.ProviderURL, a.ModelName = ProviderAzure, "https://res.openai.azure.com", `gpt\..\keys`
+		}, "must not contain"},
+		{"unknown provider", func(a *Agent) { a.ProviderType = "acme" }, "provider_type must be one of"},
+		{"bad endpoint style", func(a *Agent) { a.EndpointStyle = "graphql" }, "endpoint_style must be one of"},
+		{"api version with a query", func(a *Agent) { a.APIVersion = "2024-06-01&x=1" }, "api_version may only contain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := valid()
+			tt.edit(a)
+			err := a.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Validate() = %v, want no error", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAgentValidateTrims(t *testing.T) {
+	a := &Agent{Name: "  GPT \t", ProviderType: " OpenAI ", ProviderURL: " https://api.openai.com/v1 ", APIToken: " sk-test\n", ModelName: " gpt-4o "}
+	if err := a.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	if a.Name != "GPT" || a.ProviderType != ProviderOpenAI || a.ProviderURL != "https://api.openai.com/v1" || a.APIToken != "sk-test" || a.ModelName != "gpt-4o" {
+		t.Errorf("fields not trimmed: %+v", a)
+	}
+}
+
+func TestJSONSliceScan(t *testing.T) {
+	tests := []struct {
+		name         string
+		value        interface{}
+		legacy       bool
+		want         []int64
+		wantFallback bool
+		wantErr      string
+	}{
+		{name: "nil", value: nil, want: []int64{}},
+		{name: "empty string", value: "", want: []int64{}},
+		{name: "blank bytes", value: []byte("  "), want: []int64{}},
+		{name: "null", value: "null", want: []int64{}},
+		{name: "empty array", value: "[]", want: []int64{}},
+		{name: "array", value: "[1,2,3]", want: []int64{1, 2, 3}},
+		{name: "array as bytes", value: []byte(" [4, 5] "), want: []int64{4, 5}},
+		{name: "numbers as strings", value: `["1","2"]`, want: []int64{1, 2}},
+		{name: "mixed numbers and strings", value: `[1," 2 "]`, want: []int64{1, 2}},
+		{name: "corrupt array element", value: `[1,"two"]`, wantErr: `element 1`},
+		{name: "fractional id", value: `[1.5]`, wantErr: "element 0"},
+		{name: "unterminated array", value: `[1,2`, wantErr: `"[1,2"`},
+		{name: "csv without legacy", value: "1,2", wantErr: `invalid JSONSlice value "1,2": not a JSON array`},
+		{name: "single value without legacy", value: "7", wantErr: "not a JSON array"},
+		{name: "legacy csv", value: "1, 2,3", legacy: true, want: []int64{1, 2, 3}, wantFallback: true},
+		{name: "legacy csv with empty parts", value: "1,,2,", legacy: true, want: []int64{1, 2}, wantFallback: true},
+		{name: "legacy csv partly corrupt", value: "1,x,3", legacy: true, wantFallback: true, wantErr: `element "x"`},
+		{name: "legacy still reads JSON", value: "[9]", legacy: true, want: []int64{9}},
+		{name: "unsupported type", value: 42, wantErr: "unexpected type for JSONSlice: int"},
+	}
+	defer func(old bool) { LegacyJSONSlices = old }(LegacyJSONSlices)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			LegacyJSONSlices = tt.legacy
+			var got JSONSlice[int64]
+			fallback, err := got.ScanFallback(tt.value)
+			if fallback != tt.wantFallback {
+				t.Errorf("fallback = %v, want %v", fallback, tt.wantFallback)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ScanFallback(%v) = %v, want an error containing %q", tt.value, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ScanFallback(%v): %v", tt.value, err)
+			}
+			if !reflect.DeepEqual([]int64(got), tt.want) {
+				t.Errorf("ScanFallback(%v) = %#v, want %#v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONSliceScanStrings(t *testing.T) {
+	defer func(old bool) { LegacyJSONSlices = old }(LegacyJSONSlices)
+	LegacyJSONSlices = true
+
+	var got JSONSlice[string]
+	if err := got.Scan(`["a","b c"]`); err != nil || !reflect.DeepEqual([]string(got), []string{"a", "b c"}) {
+		t.Errorf("JSON strings = %#v, %v", got, err)
+	}
+	// Legacy string lists were written bare
+	if err := got.Scan("alpha, beta"); err != nil || !reflect.DeepEqual([]string(got), []string{"alpha", "beta"}) {
+		t.Errorf("legacy strings = %#v, %v", got, err)
+	}
+}
+
+func TestQuoteRawTruncates(t *testing.T) {
+	var got JSONSlice[int64]
+	err := got.Scan(strings.Repeat("9,", 200))
+	if err == nil || !strings.Contains(err.Error(), `"...:`) || len(err.Error()) > 200 {
+		t.Errorf("error for a long value = %v, want the raw value truncated", err)
+	}
+}
+
+func TestPromptProfile(t *testing.T) {
+	tests := []struct {
+		limit   int
+		profile string
+		want    string
+	}{
+		{280, "", PromptProfileCompact},
+		{CompactCharLimit, "", PromptProfileCompact},
+		{CompactCharLimit + 1, "", PromptProfileStandard},
+		{0, "", PromptProfileStandard},
+		{280, PromptProfileStandard, PromptProfileStandard},
+		{2000, PromptProfileCompact, PromptProfileCompact},
+	}
+	for _, tt := range tests {
+		d := &Discussion{MaxCharLimit: tt.limit, Settings: DiscussionSettings{PromptProfile: tt.profile}}
+		if got := d.PromptProfile(); got != tt.want {
+			t.Errorf("PromptProfile(limit %d, chosen %q) = %q, want %q", tt.limit, tt.profile, got, tt.want)
+		}
+	}
+
+	settings := DiscussionSettings{PromptProfile: " Compact "}
+	if err := settings.Validate(); err != nil || settings.PromptProfile != PromptProfileCompact {
+		t.Errorf("Validate(\" Compact \") = %v, profile %q", err, settings.PromptProfile)
+	}
+	settings = DiscussionSettings{PromptProfile: "tweet"}
+	if err := settings.Validate(); err == nil {
+		t.Error("Validate accepted an unknown prompt profile")
+	}
+}
+
+func TestDetectProviderType(t *testing.T) {
+	tests := map[string]string{
+		"http://localhost:11434":                               ProviderOllama,
+		"https://res.openai.azure.com/openai/deployments/gpt4": ProviderAzure,
+		"https://api.openai.com/v1":                            ProviderOpenAI,
+		"https://api.anthropic.com/v1":                         ProviderAnthropic,
+		"https://llm.example.com/v1":                           ProviderCustom,
+	}
+	for url, want := range tests {
+		if got := DetectProviderType(url); got != want {
+			t.Errorf("DetectProviderType(%q) = %s, want %s", url, got, want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Alert metrics
+const (
+	AlertMetricFailureRate = "failure_rate"
+	AlertMetricLatency     = "latency"
+)
+
+// Alert states
+const (
+	AlertStateFiring   = "firing"
+	AlertStateResolved = "resolved"
+)
+
+// Alert rule defaults and limits
+const (
+	DefaultAlertWindowMinutes   = 60
+	MaxAlertWindowMinutes       = 7 * 24 * 60
+	DefaultAlertFailurePercent  = 50
+	DefaultAlertMinCalls        = 5
+	MaxAlertMinCalls            = 10000
+	DefaultAlertCooldownMinutes = 60
+	MaxAlertCooldownMinutes     = 7 * 24 * 60
+)
+
+// AlertRulesConfig controls alerting on agents that start failing or slow
+// down. Each agent's calls over the last WindowMinutes are checked on the
+// watchdog interval; an alert fires when at least MinCalls were made and the
+// failure rate reaches FailureRatePercent, or the average latency of
+// successful calls reaches LatencyMs. Zero FailureRatePercent or LatencyMs
+// turns that rule off. A new alert for the same agent and metric is held back
+// until CooldownMinutes after the previous one fired. Notifications go to
+// Webhook when set, and always to /api/events.
+type AlertRulesConfig struct {
+	Enabled            bool               `json:"enabled"`
+	WindowMinutes      int                `json:"window_minutes"`
+	MinCalls           int                `json:"min_calls"`
+	FailureRatePercent int                `json:"failure_rate_percent"`
+	LatencyMs          int                `json:"latency_ms"`
+	CooldownMinutes    int                `json:"cooldown_minutes"`
+	Webhook            *DiscussionWebhook `json:"webhook,omitempty"`
+}
+
+// DefaultAlertRules returns the rules used when none were saved
+func DefaultAlertRules() AlertRulesConfig {
+	return AlertRulesConfig{
+		WindowMinutes:      DefaultAlertWindowMinutes,
+		MinCalls:           DefaultAlertMinCalls,
+		FailureRatePercent: DefaultAlertFailurePercent,
+		CooldownMinutes:    DefaultAlertCooldownMinutes,
+	}
+}
+
+// Validate checks the alert rules and the webhook, if any
+func (a *AlertRulesConfig) Validate() error {
+	if a.WindowMinutes < 1 || a.WindowMinutes > MaxAlertWindowMinutes {
+		return fmt.Errorf("window_minutes must be between 1 and %d", MaxAlertWindowMinutes)
+	}
+	if a.MinCalls < 1 || a.MinCalls > MaxAlertMinCalls {
+		return fmt.Errorf("min_calls must be between 1 and %d", MaxAlertMinCalls)
+	}
+	if a.FailureRatePercent < 0 || a.FailureRatePercent > 100 {
+		return fmt.Errorf("failure_rate_percent must be between 0 and 100")
+	}
+	if a.LatencyMs < 0 || a.LatencyMs > MaxAgentTimeoutCeilingSeconds*1000 {
+		return fmt.Errorf("latency_ms must be between 0 and %d", MaxAgentTimeoutCeilingSeconds*1000)
+	}
+	if a.CooldownMinutes < 0 || a.CooldownMinutes > MaxAlertCooldownMinutes {
+		return fmt.Errorf("cooldown_minutes must be between 0 and %d", MaxAlertCooldownMinutes)
+	}
+	if a.Webhook != nil {
+		// The alert webhook receives every alert event and nothing else
+		a.Webhook.Events = nil
+		if err := a.Webhook.Validate(); err != nil {
+			return fmt.Errorf("webhook: %w", err)
+		}
+	}
+	return nil
+}
+
+// Alert is one firing of an alert rule for an agent. It stays firing until
+// the metric recovers, when ResolvedAt is set.
+type Alert struct {
+	ID         int64      `json:"id" db:"id"`
+	AgentID    int64      `json:"agent_id" db:"agent_id"`
+	AgentName  string     `json:"agent_name" db:"agent_name"`
+	Metric     string     `json:"metric" db:"metric"` // failure_rate, latency
+	State      string     `json:"state" db:"state"`   // firing, resolved
+	Value      float64    `json:"value" db:"value"`   // percent or milliseconds when fired
+	Threshold  float64    `json:"threshold" db:"threshold"`
+	Calls      int        `json:"calls" db:"calls"`
+	Message    string     `json:"message" db:"message"`
+	FiredAt    time.Time  `json:"fired_at" db:"fired_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty" db:"resolved_at"`
+}
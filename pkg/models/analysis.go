@@ -0,0 +1,108 @@
+package models
+
+import "time"
+
+// Positions an agent can take on a key point of an analysis
+const (
+	AnalysisSupport      = "support"
+	AnalysisOppose       = "oppose"
+	AnalysisNotAddressed = "not_addressed"
+)
+
+// Analysis statuses
+const (
+	AnalysisSuccess = "success"
+	AnalysisRaw     = "raw" // the reply could not be parsed and is kept as text
+	AnalysisFailed  = "failed"
+)
+
+// Analysis limits
+const (
+	MaxAnalysisPoints      = 20
+	MaxAnalysisPointLength = 500
+)
+
+// AnalysisPoint is one key point of a discussion with the position every
+// participant took on it, keyed by agent ID
+type AnalysisPoint struct {
+	Point     string           `json:"point"`
+	Positions map[int64]string `json:"positions"` // support, oppose, not_addressed
+}
+
+// DiscussionAnalysis is the latest consensus analysis of a discussion: the
+// key points of the debate and where each participant stood on them. When
+// the analyst's reply could not be parsed its text is kept in RawText and
+// Points is empty.
+type DiscussionAnalysis struct {
+	DiscussionID int64                    `json:"discussion_id" db:"discussion_id"`
+	AnalystID    int64                    `json:"analyst_id" db:"analyst_id"`
+	Status       string                   `json:"status" db:"status"` // success, raw, failed
+	Points       JSONSlice[AnalysisPoint] `json:"points" db:"points"`
+	UnknownNames JSONSlice[string]        `json:"unknown_names" db:"unknown_names"` // names in the reply that are not participants
+	RawText      string                   `json:"raw_text,omitempty" db:"raw_text"`
+	Error        string                   `json:"error,omitempty" db:"error"`
+	Attempts     int                      `json:"attempts" db:"attempts"`
+	CreatedAt    time.Time                `json:"created_at" db:"created_at"`
+}
+
+// AnalysisAgent is a column of an agreement matrix
+type AnalysisAgent struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// AgreementRow is a key point in an agreement matrix. Positions follows the
+// matrix's agent columns. Agreement is the share of the agents addressing the
+// point that are on its majority side, from 0.5 (evenly split) to 1
+// (unanimous), and 0 when no agent addressed it.
+type AgreementRow struct {
+	Point        string   `json:"point"`
+	Positions    []string `json:"positions"`
+	Support      int      `json:"support"`
+	Oppose       int      `json:"oppose"`
+	NotAddressed int      `json:"not_addressed"`
+	Agreement    float64  `json:"agreement"`
+}
+
+// AgreementMatrix lays out an analysis as key points against participants.
+// Consensus averages the rows' agreement weighted by how many agents
+// addressed each point.
+type AgreementMatrix struct {
+	Agents    []AnalysisAgent `json:"agents"`
+	Rows      []AgreementRow  `json:"rows"`
+	Consensus float64         `json:"consensus"`
+}
+
+// Matrix builds the agreement matrix of the analysis for the given agent
+// columns. An agent without a position on a point has not addressed it.
+func (a *DiscussionAnalysis) Matrix(agents []AnalysisAgent) *AgreementMatrix {
+	matrix := &AgreementMatrix{Agents: agents, Rows: []AgreementRow{}}
+	weighted, addressed := 0.0, 0
+	for _, point := range a.Points {
+		row := AgreementRow{Point: point.Point, Positions: make([]string, len(agents))}
+		for i, agent := range agents {
+			position := point.Positions[agent.ID]
+			switch position {
+			case AnalysisSupport:
+				row.Support++
+			case AnalysisOppose:
+				row.Oppose++
+			default:
+				position = AnalysisNotAddressed
+				row.NotAddressed++
+			}
+			row.Positions[i] = position
+		}
+
+		if n := row.Support + row.Oppose; n > 0 {
+			row.Agreement = float64(max(row.Support, row.Oppose)) / float64(n)
+			weighted += row.Agreement * float64(n)
+			addressed += n
+		}
+		matrix.Rows = append(matrix.Rows, row)
+	}
+	if addressed > 0 {
+		matrix.Consensus = weighted / float64(addressed)
+	}
+	return matrix
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAnalysisMatrix(t *testing.T) {
+	analysis := &DiscussionAnalysis{Points: JSONSlice[AnalysisPoint]{
+		{Point: "Unanimous", Positions: map[int64]string{1: AnalysisSupport, 2: AnalysisSupport, 3: AnalysisSupport}},
+		{Point: "Split", Positions: map[int64]string{1: AnalysisSupport, 2: AnalysisOppose}},
+		{Point: "Ignored", Positions: map[int64]string{1: AnalysisNotAddressed, 9: AnalysisSupport}},
+	}}
+	agents := []AnalysisAgent{{ID: 1, Name: "Alice"}, {ID: 2, Name: "Bob"}, {ID: 3, Name: "Carol"}}
+	matrix := analysis.Matrix(agents)
+
+	want := []AgreementRow{
+		{Point: "Unanimous", Positions: []string{AnalysisSupport, AnalysisSupport, AnalysisSupport}, Support: 3, Agreement: 1},
+		// Carol has no position, so she has not addressed the point
+		{Point: "Split", Positions: []string{AnalysisSupport, AnalysisOppose, AnalysisNotAddressed}, Support: 1, Oppose: 1, NotAddressed: 1, Agreement: 0.5},
+		// Agent 9 is not a column
+		{Point: "Ignored", Positions: []string{AnalysisNotAddressed, AnalysisNotAddressed, AnalysisNotAddressed}, NotAddressed: 3},
+	}
+	if len(matrix.Rows) != len(want) {
+		t.Fatalf("matrix has %d rows, want %d", len(matrix.Rows), len(want))
+	}
+	for i, row := range matrix.Rows {
+		w := want[i]
+		if row.Point != w.Point || row.Support != w.Support || row.Oppose != w.Oppose || row.NotAddressed != w.NotAddressed || row.Agreement != w.Agreement {
+			t.Errorf("row %d = %+v, want %+v", i, row, w)
+		}
+		for j := range agents {
+			if row.Positions[j] != w.Positions[j] {
+				t.Errorf("row %d positions = %v, want %v", i, row.Positions, w.Positions)
+				break
+			}
+		}
+	}
+	// (1*3 + 0.5*2) / 5 addressed positions
+	if math.Abs(matrix.Consensus-0.8) > 1e-9 {
+		t.Errorf("consensus = %v, want 0.8", matrix.Consensus)
+	}
+
+	empty := (&DiscussionAnalysis{}).Matrix(agents)
+	if empty.Rows == nil || len(empty.Rows) != 0 || empty.Consensus != 0 {
+		t.Errorf("matrix of an empty analysis = %+v", empty)
+	}
+}
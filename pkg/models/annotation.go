@@ -0,0 +1,103 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Annotation length limits
+const (
+	MaxAnnotationCommentLength = 5000
+	MaxAnnotationAuthorLength  = 100
+)
+
+// DiscussionAnnotation highlights a span of one log entry's content, with an
+// optional comment. Offsets count runes, not bytes, so spans in multi-byte
+// text stay on character boundaries; EndOffset is exclusive.
+type DiscussionAnnotation struct {
+	ID           int64     `json:"id" db:"id"`
+	DiscussionID int64     `json:"discussion_id" db:"discussion_id"`
+	LogID        int64     `json:"log_id" db:"log_id"`
+	StartOffset  int       `json:"start_offset" db:"start_offset"`
+	EndOffset    int       `json:"end_offset" db:"end_offset"`
+	Comment      string    `json:"comment" db:"comment"`
+	Author       string    `json:"author" db:"author"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Validate trims the annotation and checks its span against the content of
+// the log entry it highlights
+func (a *DiscussionAnnotation) Validate(content string) error {
+	a.Author = strings.TrimSpace(a.Author)
+	a.Comment = strings.TrimSpace(a.Comment)
+
+	length := utf8.RuneCountInString(content)
+	if a.StartOffset < 0 || a.EndOffset > length || a.StartOffset >= a.EndOffset {
+		return fmt.Errorf("offsets must satisfy 0 <= start_offset < end_offset <= %d", length)
+	}
+	if len([]rune(a.Comment)) > MaxAnnotationCommentLength {
+		return fmt.Errorf("comment must be at most %d characters", MaxAnnotationCommentLength)
+	}
+	if len([]rune(a.Author)) > MaxAnnotationAuthorLength {
+		return fmt.Errorf("author must be at most %d characters", MaxAnnotationAuthorLength)
+	}
+	if hasControlChars(a.Author) {
+		return errors.New("author must not contain control characters")
+	}
+	return nil
+}
+
+// AnnotatedSegment is a run of content covered by the same set of annotations
+type AnnotatedSegment struct {
+	Text          string
+	AnnotationIDs []int64
+	Comments      []string
+}
+
+// Annotated reports whether any annotation covers the segment
+func (s AnnotatedSegment) Annotated() bool {
+	return len(s.AnnotationIDs) > 0
+}
+
+// AnnotationSegments splits content at every annotation boundary so templates
+// can wrap highlighted runs. Overlapping annotations produce segments covered
+// by several annotations; spans outside the content are ignored.
+func AnnotationSegments(content string, annotations []*DiscussionAnnotation) []AnnotatedSegment {
+	runes := []rune(content)
+
+	bounds := []int{0, len(runes)}
+	var valid []*DiscussionAnnotation
+	for _, a := range annotations {
+		if a.StartOffset < 0 || a.EndOffset > len(runes) || a.StartOffset >= a.EndOffset {
+			continue
+		}
+		valid = append(valid, a)
+		bounds = append(bounds, a.StartOffset, a.EndOffset)
+	}
+	sort.Ints(bounds)
+
+	var segments []AnnotatedSegment
+	for i := 0; i+1 < len(bounds); i++ {
+		start, end := bounds[i], bounds[i+1]
+		if start == end {
+			continue
+		}
+
+		segment := AnnotatedSegment{Text: string(runes[start:end])}
+		for _, a := range valid {
+			if a.StartOffset <= start && end <= a.EndOffset {
+				segment.AnnotationIDs = append(segment.AnnotationIDs, a.ID)
+				if a.Comment != "" {
+					segment.Comments = append(segment.Comments, a.Comment)
+				}
+			}
+		}
+		segments = append(segments, segment)
+	}
+	return segments
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnnotationValidate(t *testing.T) {
+	// Nine runes, eleven bytes
+	content := "Café über"
+	tests := []struct {
+		name       string
+		start, end int
+		author     string
+		comment    string
+		wantErr    string
+	}{
+		{name: "whole content", start: 0, end: 9},
+		{name: "multi-byte rune at the end", start: 5, end: 9},
+		{name: "single rune", start: 3, end: 4},
+		{name: "end past the runes", start: 0, end: 10, wantErr: "end_offset <= 9"},
+		{name: "byte length is not the limit", start: 0, end: len(content), wantErr: "end_offset <= 9"},
+		{name: "negative start", start: -1, end: 2, wantErr: "0 <= start_offset"},
+		{name: "empty span", start: 4, end: 4, wantErr: "start_offset < end_offset"},
+		{name: "reversed span", start: 5, end: 2, wantErr: "start_offset < end_offset"},
+		{name: "long comment", start: 0, end: 1, comment: strings.Repeat("é", MaxAnnotationCommentLength+1), wantErr: "comment must be at most"},
+		{name: "comment at the limit", start: 0, end: 1, comment: strings.Repeat("é", MaxAnnotationCommentLength)},
+		{name: "long author", start: 0, end: 1, author: strings.Repeat("a", MaxAnnotationAuthorLength+1), wantErr: "author must be at most"},
+		{name: "newline in author", start: 0, end: 1, author: "Rina\nAdmin", wantErr: "control characters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &DiscussionAnnotation{StartOffset: tt.start, EndOffset: tt.end, Author: tt.author, Comment: tt.comment}
+			err := a.Validate(content)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Validate() = %v, want no error", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	a := &DiscussionAnnotation{StartOffset: 0, EndOffset: 1, Author: "  Rina ", Comment: " nice \n"}
+	if err := a.Validate(content); err != nil || a.Author != "Rina" || a.Comment != "nice" {
+		t.Errorf("Validate() = %v with author %q and comment %q, want them trimmed", err, a.Author, a.Comment)
+	}
+}
+
+func TestAnnotationSegments(t *testing.T) {
+	content := "日本語のテキスト"
+	annotations := []*DiscussionAnnotation{
+		{ID: 1, StartOffset: 1, EndOffset: 4, Comment: "wide"},
+		{ID: 2, StartOffset: 3, EndOffset: 6},
+		// Outside the content, skipped
+		{ID: 3, StartOffset: 6, EndOffset: 20},
+		{ID: 4, StartOffset: 2, EndOffset: 2},
+	}
+
+	var got []string
+	for _, s := range AnnotationSegments(content, annotations) {
+		entry := s.Text
+		for _, id := range s.AnnotationIDs {
+			entry += "#" + string(rune('0'+id))
+		}
+		if len(s.Comments) > 0 {
+			entry += "(" + strings.Join(s.Comments, ",") + ")"
+		}
+		got = append(got, entry)
+	}
+	want := []string{"日", "本語#1(wide)", "の#1#2(wide)", "テキ#2", "スト"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("segments = %q, want %q", got, want)
+	}
+
+	if segments := AnnotationSegments("plain", nil); len(segments) != 1 || segments[0].Text != "plain" || segments[0].Annotated() {
+		t.Errorf("segments without annotations = %+v", segments)
+	}
+	if segments := AnnotationSegments("", annotations); len(segments) != 0 {
+		t.Errorf("segments of empty content = %+v", segments)
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+// MaxCallTraceRequests bounds the requests kept in one call's trace; later
+// ones are only counted
+const MaxCallTraceRequests = 32
+
+// CallTrace records every HTTP request made for one agent call, in order,
+// including endpoint probes and retries. It is kept in the "call_trace"
+// metadata of the call's log entry when tracing is on for the agent or the
+// discussion.
+type CallTrace struct {
+	Requests []CallTraceRequest `json:"requests"`
+	// Dropped counts requests made after MaxCallTraceRequests were recorded
+	Dropped int `json:"dropped,omitempty"`
+}
+
+// CallTraceRequest is one HTTP request of a traced call. Endpoint omits the
+// query string, which may hold an API key. DurationMs runs until the body
+// was read, or until the headers arrived for a body never read.
+type CallTraceRequest struct {
+	Attempt    int    `json:"attempt"` // 1 for the first try, 2 for the first retry, ...
+	Method     string `json:"method"`
+	Endpoint   string `json:"endpoint"`
+	Status     int    `json:"status,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	BytesOut   int64  `json:"bytes_out"`
+	BytesIn    int64  `json:"bytes_in"`
+}
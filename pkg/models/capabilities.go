@@ -0,0 +1,26 @@
+package models
+
+// CapabilitiesSchemaVersion is bumped whenever the capabilities payload
+// changes shape, so clients can tell which fields to expect
+const CapabilitiesSchemaVersion = 1
+
+// Capabilities describes the optional features and limits of a server, so a
+// client can show only the controls the server supports
+type Capabilities struct {
+	SchemaVersion int             `json:"schema_version"`
+	Version       string          `json:"version"`
+	Features      map[string]bool `json:"features"`
+	Limits        Limits          `json:"limits"`
+}
+
+// Limits are the bounds a server enforces on new discussions and agents. A
+// zero maximum means there is no limit.
+type Limits struct {
+	MaxAgentsPerDiscussion int `json:"max_agents_per_discussion"`
+	MinRounds              int `json:"min_rounds"`
+	MaxRounds              int `json:"max_rounds"`
+	MinCharLimit           int `json:"min_char_limit"`
+	MaxCharLimit           int `json:"max_char_limit"`
+	MaxAgentTimeoutSeconds int `json:"max_agent_timeout_seconds"`
+	MaxSystemPromptLength  int `json:"max_system_prompt_length"`
+}
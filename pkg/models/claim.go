@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// Claim stances
+const (
+	ClaimStanceFor     = "for"
+	ClaimStanceAgainst = "against"
+	ClaimStanceNeutral = "neutral"
+)
+
+// Claim extraction statuses
+const (
+	ClaimExtractionSuccess = "success"
+	ClaimExtractionFailed  = "failed"
+)
+
+// Claim limits
+const (
+	MaxClaimLength    = 1000
+	MaxClaimsPerAgent = 50
+)
+
+// DiscussionClaim is one distinct claim an agent made during a discussion,
+// extracted from its responses after the debate
+type DiscussionClaim struct {
+	ID           int64          `json:"id" db:"id"`
+	DiscussionID int64          `json:"discussion_id" db:"discussion_id"`
+	AgentID      int64          `json:"agent_id" db:"agent_id"`
+	Claim        string         `json:"claim" db:"claim"`
+	Stance       string         `json:"stance" db:"stance"` // for, against, neutral
+	Rounds       JSONSlice[int] `json:"rounds" db:"rounds"`
+	CreatedAt    time.Time      `json:"created_at" db:"created_at"`
+}
+
+// ClaimExtraction records the outcome of the latest claim extraction for one
+// agent of a discussion
+type ClaimExtraction struct {
+	DiscussionID int64     `json:"discussion_id" db:"discussion_id"`
+	AgentID      int64     `json:"agent_id" db:"agent_id"`
+	ExtractorID  int64     `json:"extractor_id" db:"extractor_id"`
+	Status       string    `json:"status" db:"status"` // success, failed
+	Error        string    `json:"error,omitempty" db:"error"`
+	Attempts     int       `json:"attempts" db:"attempts"`
+	ClaimCount   int       `json:"claim_count" db:"claim_count"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// DiscussionClaims is the stored claim extraction for a discussion
+type DiscussionClaims struct {
+	Claims      []*DiscussionClaim `json:"claims"`
+	Extractions []*ClaimExtraction `json:"extractions"`
+}
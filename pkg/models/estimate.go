@@ -0,0 +1,58 @@
+package models
+
+// Estimate phases, the kinds of call a discussion makes. Moderator phases
+// use the engine's moderator_phase names.
+const (
+	EstimatePhaseTurn             = "turn"
+	EstimatePhaseOpening          = "opening"
+	EstimatePhaseInterim          = "interim"
+	EstimatePhaseRoundSummary     = "round_summary"
+	EstimatePhaseConsensusCheck   = "consensus_check"
+	EstimatePhaseClosingStatement = PhaseClosingStatement
+	EstimatePhaseClosing          = "closing"
+	EstimatePhaseJudge            = "judge"
+	EstimatePhaseSummary          = "summary"
+)
+
+// AgentTokenHistory is the average reply size of an agent's past successful
+// calls that reported token usage
+type AgentTokenHistory struct {
+	AgentID             int64
+	Calls               int
+	AvgCompletionTokens float64
+}
+
+// CallEstimate is the expected number of calls, tokens and USD cost of part
+// of a discussion. Cost is nil when a model making the calls has no pricing.
+type CallEstimate struct {
+	Calls            int      `json:"calls"`
+	PromptTokens     int      `json:"prompt_tokens"`
+	CompletionTokens int      `json:"completion_tokens"`
+	Cost             *float64 `json:"cost"`
+}
+
+// DiscussionEstimate is the expected cost of a discussion before it runs,
+// assuming every round runs and every call succeeds once
+type DiscussionEstimate struct {
+	CallEstimate
+	TotalTokens    int             `json:"total_tokens"`
+	Currency       string          `json:"currency"`
+	Agents         []AgentEstimate `json:"agents"`
+	Phases         []PhaseEstimate `json:"phases"`
+	UnpricedModels []string        `json:"unpriced_models"` // "provider/model" without pricing
+}
+
+// AgentEstimate is the expected cost of the calls one agent makes, in every
+// role it has in the discussion. Historical is set when its replies are
+// sized from its past calls rather than the character limit.
+type AgentEstimate struct {
+	AgentID int64 `json:"agent_id"`
+	CallEstimate
+	Historical bool `json:"historical"`
+}
+
+// PhaseEstimate is the expected cost of one kind of call
+type PhaseEstimate struct {
+	Phase string `json:"phase"`
+	CallEstimate
+}
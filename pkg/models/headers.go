@@ -0,0 +1,116 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// Limits for an agent's extra headers
+const (
+	MaxExtraHeaders           = 20
+	MaxExtraHeaderValueLength = 4096
+)
+
+// MaskedHeaderValue stands in for a secret header value in API responses.
+// Sending it back in an update keeps the stored value.
+const MaskedHeaderValue = "********"
+
+// reservedHeaders are set by the client for every request and cannot be
+// replaced by an agent
+var reservedHeaders = map[string]bool{
+	"Content-Type":      true,
+	"Content-Length":    true,
+	"Host":              true,
+	"Connection":        true,
+	"Transfer-Encoding": true,
+	"X-Request-Id":      true,
+}
+
+// secretHeaderWords mark a header whose value is a credential
+var secretHeaderWords = []string{"auth", "key", "token", "secret", "password", "cookie", "signature"}
+
+// IsSecretHeader reports whether the value of the header name is masked in
+// API responses, e.g. cf-aig-authorization or X-Api-Key
+func IsSecretHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range secretHeaderWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateExtraHeaders trims the header names and values and checks them.
+// Names must be unique regardless of case.
+func validateExtraHeaders(headers JSONMap) (JSONMap, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+	if len(headers) > MaxExtraHeaders {
+		return nil, fmt.Errorf("at most %d extra_headers are allowed", MaxExtraHeaders)
+	}
+
+	clean := make(JSONMap, len(headers))
+	seen := make(map[string]bool, len(headers))
+	for name, value := range headers {
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+		if !httpguts.ValidHeaderFieldName(name) {
+			return nil, fmt.Errorf("extra header name %q is not a valid HTTP header name", name)
+		}
+		canonical := http.CanonicalHeaderKey(name)
+		if reservedHeaders[canonical] {
+			return nil, fmt.Errorf("extra header %s cannot be set, the client sets it", canonical)
+		}
+		if seen[canonical] {
+			return nil, fmt.Errorf("extra header %s is given more than once", canonical)
+		}
+		seen[canonical] = true
+		if len(value) > MaxExtraHeaderValueLength {
+			return nil, fmt.Errorf("extra header %s must be at most %d characters", canonical, MaxExtraHeaderValueLength)
+		}
+		if value == MaskedHeaderValue {
+			return nil, fmt.Errorf("extra header %s holds the masked value, enter the value again", canonical)
+		}
+		if !httpguts.ValidHeaderFieldValue(value) {
+			return nil, fmt.Errorf("extra header %s has an invalid value", canonical)
+		}
+		clean[name] = value
+	}
+	return clean, nil
+}
+
+// MaskedHeaders returns the agent's extra headers with the values of secret
+// headers replaced by MaskedHeaderValue
+func (a *Agent) MaskedHeaders() JSONMap {
+	if len(a.ExtraHeaders) == 0 {
+		return a.ExtraHeaders
+	}
+	masked := make(JSONMap, len(a.ExtraHeaders))
+	for name, value := range a.ExtraHeaders {
+		if IsSecretHeader(name) && value != "" {
+			value = MaskedHeaderValue
+		}
+		masked[name] = value
+	}
+	return masked
+}
+
+// RestoreMaskedHeaders replaces header values sent back as MaskedHeaderValue
+// with the values stored for the same header, in any case, on stored
+func (a *Agent) RestoreMaskedHeaders(stored JSONMap) {
+	for name, value := range a.ExtraHeaders {
+		if value != MaskedHeaderValue {
+			continue
+		}
+		for storedName, storedValue := range stored {
+			if strings.EqualFold(storedName, name) {
+				a.ExtraHeaders[name] = storedValue
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValidateExtraHeaders(t *testing.T) {
+	tooMany := JSONMap{}
+	for i := 0; i <= MaxExtraHeaders; i++ {
+		tooMany[fmt.Sprintf("X-Header-%d", i)] = "v"
+	}
+	tests := []struct {
+		name    string
+		headers JSONMap
+		want    JSONMap
+		wantErr string
+	}{
+		{"none", nil, nil, ""},
+		{"trimmed", JSONMap{" HTTP-Referer ": " https://court.example.com ", "X-Title": "Court Table"},
+			JSONMap{"HTTP-Referer": "https://court.example.com", "X-Title": "Court Table"}, ""},
+		{"content type", JSONMap{"content-type": "text/plain"}, nil, "extra header Content-Type cannot be set"},
+		{"request id", JSONMap{"X-Request-ID": "abc"}, nil, "extra header X-Request-Id cannot be set"},
+		{"same name twice", JSONMap{"X-Tenant-Id": "a", "x-tenant-id": "b"}, nil, "is given more than once"},
+		{"invalid name", JSONMap{"X Tenant": "a"}, nil, "not a valid HTTP header name"},
+		{"invalid value", JSONMap{"X-Tenant-Id": "a\r\nX-Admin: 1"}, nil, "has an invalid value"},
+		{"masked value", JSONMap{"cf-aig-authorization": MaskedHeaderValue}, nil, "holds the masked value"},
+		{"value too long", JSONMap{"X-Tenant-Id": strings.Repeat("a", MaxExtraHeaderValueLength+1)}, nil, "must be at most"},
+		{"too many", tooMany, nil, "at most 20 extra_headers"},
+	}
+	for _, tt := range tests {
+		a := &Agent{Name: "GPT", ProviderType: ProviderOpenAI, ProviderURL: "https://openrouter.ai/api/v1", ModelName: "gpt-4o", ExtraHeaders: tt.headers}
+		err := a.Validate()
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: Validate() = %v, want an error containing %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || fmt.Sprint(a.ExtraHeaders) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: Validate() = %v with headers %v, want %v", tt.name, err, a.ExtraHeaders, tt.want)
+		}
+	}
+}
+
+func TestMaskedHeaders(t *testing.T) {
+	for name, secret := range map[string]bool{
+		"cf-aig-authorization": true,
+		"X-Api-Key":            true,
+		"X-Auth-Token":         true,
+		"Cookie":               true,
+		"HTTP-Referer":         false,
+		"X-Title":              false,
+		"X-Tenant-Id":          false,
+	} {
+		if IsSecretHeader(name) != secret {
+			t.Errorf("IsSecretHeader(%q) = %v, want %v", name, !secret, secret)
+		}
+	}
+
+	a := &Agent{ExtraHeaders: JSONMap{"cf-aig-authorization": "Bearer cf-secret", "X-Title": "Court Table", "X-Api-Key": ""}}
+	masked := a.MaskedHeaders()
+	want := JSONMap{"cf-aig-authorization": MaskedHeaderValue, "X-Title": "Court Table", "X-Api-Key": ""}
+	if fmt.Sprint(masked) != fmt.Sprint(want) {
+		t.Errorf("MaskedHeaders() = %v, want %v", masked, want)
+	}
+	if a.ExtraHeaders["cf-aig-authorization"] != "Bearer cf-secret" {
+		t.Error("MaskedHeaders changed the agent's own headers")
+	}
+
+	// A masked value sent back keeps the stored one, matched in any case
+	update := &Agent{ExtraHeaders: JSONMap{"CF-AIG-Authorization": MaskedHeaderValue, "X-Title": "Court"}}
+	update.RestoreMaskedHeaders(a.ExtraHeaders)
+	if update.ExtraHeaders["CF-AIG-Authorization"] != "Bearer cf-secret" || update.ExtraHeaders["X-Title"] != "Court" {
+		t.Errorf("restored headers = %v", update.ExtraHeaders)
+	}
+}
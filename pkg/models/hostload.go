@@ -0,0 +1,91 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Host limit defaults and bounds
+const (
+	DefaultLocalHostLimit = 1
+	MaxHostLimit          = 100
+)
+
+// HostLimitsConfig sets how many calls a provider host is expected to serve
+// at once. A new discussion that would push a host past its limit is warned
+// about it, not refused. Local hosts (loopback, private networks and .local
+// names), usually a single Ollama server, use LocalLimit and other hosts
+// DefaultLimit. Hosts overrides either by host name, with or without port.
+// Zero means no limit.
+type HostLimitsConfig struct {
+	LocalLimit   int            `json:"local_limit"`
+	DefaultLimit int            `json:"default_limit"`
+	Hosts        map[string]int `json:"hosts,omitempty"`
+}
+
+// DefaultHostLimits returns the limits used when none were saved
+func DefaultHostLimits() HostLimitsConfig {
+	return HostLimitsConfig{LocalLimit: DefaultLocalHostLimit}
+}
+
+// Validate normalizes host names and checks the limits
+func (c *HostLimitsConfig) Validate() error {
+	if c.LocalLimit < 0 || c.LocalLimit > MaxHostLimit || c.DefaultLimit < 0 || c.DefaultLimit > MaxHostLimit {
+		return fmt.Errorf("local_limit and default_limit must be between 0 and %d", MaxHostLimit)
+	}
+	hosts := make(map[string]int, len(c.Hosts))
+	for host, limit := range c.Hosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host == "" {
+			return fmt.Errorf("host names must not be empty")
+		}
+		if limit < 0 || limit > MaxHostLimit {
+			return fmt.Errorf("limit for host %q must be between 0 and %d", host, MaxHostLimit)
+		}
+		hosts[host] = limit
+	}
+	c.Hosts = hosts
+	return nil
+}
+
+// Limit returns the limit of a host given as host[:port]
+func (c HostLimitsConfig) Limit(host string) int {
+	host = strings.ToLower(host)
+	if limit, ok := c.Hosts[host]; ok {
+		return limit
+	}
+	name := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		name = h
+	}
+	if limit, ok := c.Hosts[name]; ok {
+		return limit
+	}
+	if IsLocalHost(name) {
+		return c.LocalLimit
+	}
+	return c.DefaultLimit
+}
+
+// IsLocalHost reports whether a host name is this machine or on a private
+// network
+func IsLocalHost(name string) bool {
+	name = strings.Trim(strings.ToLower(name), "[]")
+	if name == "localhost" || strings.HasSuffix(name, ".localhost") || strings.HasSuffix(name, ".local") {
+		return true
+	}
+	ip := net.ParseIP(name)
+	return ip != nil && (ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified())
+}
+
+// HostLoadWarning reports a provider host that a new discussion would send
+// more concurrent calls than its limit
+type HostLoadWarning struct {
+	Host                 string  `json:"host"`
+	Limit                int     `json:"limit"`
+	ConcurrentCalls      int     `json:"concurrent_calls"`
+	AgentIDs             []int64 `json:"agent_ids"` // agents of the new discussion on the host, moderator included
+	RunningDiscussionIDs []int64 `json:"running_discussion_ids,omitempty"`
+	Message              string  `json:"message"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import "testing"
+
+func TestHostLimit(t *testing.T) {
+	cfg := HostLimitsConfig{LocalLimit: 1, DefaultLimit: 4, Hosts: map[string]int{"gpu-box:11434": 2, "gpu-box": 3, "api.example.com": 0}}
+	tests := []struct {
+		host string
+		want int
+	}{
+		{"gpu-box:11434", 2},
+		{"GPU-Box:11434", 2},
+		{"gpu-box:8080", 3},
+		{"gpu-box", 3},
+		{"api.example.com", 0},
+		{"localhost:11434", 1},
+		{"[::1]:11434", 1},
+		{"10.0.0.5", 1},
+		{"api.openai.com", 4},
+	}
+	for _, tt := range tests {
+		if got := cfg.Limit(tt.host); got != tt.want {
+			t.Errorf("Limit(%q) = %d, want %d", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestIsLocalHost(t *testing.T) {
+	local := []string{"localhost", "LOCALHOST", "ollama.localhost", "gpu.local", "127.0.0.1", "[::1]", "::1", "192.168.1.20", "172.16.0.3", "10.1.2.3", "169.254.0.9", "0.0.0.0"}
+	for _, name := range local {
+		if !IsLocalHost(name) {
+			t.Errorf("IsLocalHost(%q) = false", name)
+		}
+	}
+	remote := []string{"api.openai.com", "8.8.8.8", "172.32.0.1", "localhost.example.com", "2001:4860:4860::8888"}
+	for _, name := range remote {
+		if IsLocalHost(name) {
+			t.Errorf("IsLocalHost(%q) = true", name)
+		}
+	}
+}
+
+func TestHostLimitsValidate(t *testing.T) {
+	cfg := HostLimitsConfig{LocalLimit: 1, Hosts: map[string]int{" GPU-Box:11434 ": 2}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if len(cfg.Hosts) != 1 || cfg.Hosts["gpu-box:11434"] != 2 {
+		t.Errorf("hosts after Validate = %v, want normalized names", cfg.Hosts)
+	}
+
+	invalid := []HostLimitsConfig{
+		{LocalLimit: -1},
+		{DefaultLimit: MaxHostLimit + 1},
+		{Hosts: map[string]int{" ": 1}},
+		{Hosts: map[string]int{"gpu-box": -1}},
+		{Hosts: map[string]int{"gpu-box": MaxHostLimit + 1}},
+	}
+	for _, cfg := range invalid {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Validate(%+v) accepted invalid limits", cfg)
+		}
+	}
+}
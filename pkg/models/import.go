@@ -0,0 +1,161 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DiscussionStatusImported marks a discussion created from an external
+// transcript rather than run by the engine
+const DiscussionStatusImported = "imported"
+
+// Transcript import limits
+const (
+	MaxImportParticipants = 50
+	MaxImportTurns        = 5000
+	MaxImportTurnLength   = 100000
+)
+
+// Roles of an imported participant or turn
+const (
+	ImportRoleParticipant = "participant"
+	ImportRoleModerator   = "moderator"
+)
+
+// TranscriptImport is a debate transcript produced by another tool
+type TranscriptImport struct {
+	Topic        string              `json:"topic"`
+	Language     string              `json:"language"`
+	FinalSummary string              `json:"final_summary"`
+	Participants []ImportParticipant `json:"participants"`
+	Turns        []ImportTurn        `json:"turns"`
+	// CreateMissingAgents creates disabled stub agents for participants that
+	// match no existing agent by name; otherwise unmatched names are rejected
+	CreateMissingAgents bool `json:"create_missing_agents"`
+}
+
+// ImportParticipant is a speaker in an imported transcript
+type ImportParticipant struct {
+	Name  string `json:"name"`
+	Model string `json:"model"`
+	Role  string `json:"role"` // participant (default) or moderator
+}
+
+// ImportTurn is one entry of an imported transcript, in speaking order
+type ImportTurn struct {
+	Participant string     `json:"participant"`
+	Content     string     `json:"content"`
+	Round       int        `json:"round"` // optional, 1-based
+	Role        string     `json:"role"`  // optional, defaults to the participant's role
+	Timestamp   *time.Time `json:"timestamp"`
+}
+
+// Validate trims the transcript and checks that every turn names a
+// participant. Participant names are matched case-insensitively.
+func (t *TranscriptImport) Validate() error {
+	t.Topic = strings.TrimSpace(t.Topic)
+	t.Language = strings.TrimSpace(t.Language)
+	if t.Topic == "" {
+		return errors.New("topic is required")
+	}
+	if t.Language == "" {
+		t.Language = DefaultLanguage
+	}
+	t.Language, _ = NormalizeLanguage(t.Language)
+
+	if len(t.Participants) == 0 {
+		return errors.New("at least one participant is required")
+	}
+	if len(t.Participants) > MaxImportParticipants {
+		return fmt.Errorf("at most %d participants are allowed", MaxImportParticipants)
+	}
+	roles := make(map[string]string)
+	moderators := 0
+	for i := range t.Participants {
+		p := &t.Participants[i]
+		p.Name = strings.TrimSpace(p.Name)
+		p.Model = strings.TrimSpace(p.Model)
+		role, err := importRole(p.Role, ImportRoleParticipant)
+		if err != nil {
+			return fmt.Errorf("participant %q: %w", p.Name, err)
+		}
+		p.Role = role
+		if p.Name == "" || len([]rune(p.Name)) > MaxAgentNameLength || hasControlChars(p.Name) {
+			return fmt.Errorf("participant %d: name must be 1-%d characters without control characters", i+1, MaxAgentNameLength)
+		}
+		key := strings.ToLower(p.Name)
+		if _, dup := roles[key]; dup {
+			return fmt.Errorf("participant %q is listed twice", p.Name)
+		}
+		roles[key] = p.Role
+		if p.Role == ImportRoleModerator {
+			moderators++
+		}
+	}
+	if moderators > 1 {
+		return errors.New("at most one participant can be the moderator")
+	}
+	if moderators == len(t.Participants) {
+		return errors.New("at least one participant must not be the moderator")
+	}
+
+	if len(t.Turns) == 0 {
+		return errors.New("at least one turn is required")
+	}
+	if len(t.Turns) > MaxImportTurns {
+		return fmt.Errorf("at most %d turns are allowed", MaxImportTurns)
+	}
+	for i := range t.Turns {
+		turn := &t.Turns[i]
+		turn.Participant = strings.TrimSpace(turn.Participant)
+		defaultRole, ok := roles[strings.ToLower(turn.Participant)]
+		if !ok {
+			return fmt.Errorf("turn %d: unknown participant %q", i+1, turn.Participant)
+		}
+		role, err := importRole(turn.Role, defaultRole)
+		if err != nil {
+			return fmt.Errorf("turn %d: %w", i+1, err)
+		}
+		turn.Role = role
+		if turn.Round < 0 {
+			return fmt.Errorf("turn %d: round must not be negative", i+1)
+		}
+		if len([]rune(turn.Content)) > MaxImportTurnLength {
+			return fmt.Errorf("turn %d: content must be at most %d characters", i+1, MaxImportTurnLength)
+		}
+	}
+	return nil
+}
+
+// importRole normalises a role, using def when it is empty
+func importRole(role, def string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(role)) {
+	case "":
+		return def, nil
+	case ImportRoleParticipant:
+		return ImportRoleParticipant, nil
+	case ImportRoleModerator:
+		return ImportRoleModerator, nil
+	default:
+		return "", fmt.Errorf("role must be %q or %q", ImportRoleParticipant, ImportRoleModerator)
+	}
+}
+
+// ErrUnknownParticipants is returned when an import names participants that
+// match no agent and stub creation was not requested
+var ErrUnknownParticipants = errors.New("participants match no existing agent; set create_missing_agents to create stubs")
+
+// ImportResult describes a stored import and how its participants were mapped
+type ImportResult struct {
+	Discussion *Discussion     `json:"discussion"`
+	Agents     []ImportedAgent `json:"agents"`
+}
+
+// ImportedAgent maps a transcript participant to the agent its turns belong to
+type ImportedAgent struct {
+	Name    string `json:"name"`
+	AgentID int64  `json:"agent_id"`
+	Created bool   `json:"created"` // a disabled stub was created for it
+}
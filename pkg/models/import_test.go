@@ -0,0 +1,53 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTranscriptImportValidate(t *testing.T) {
+	valid := func() TranscriptImport {
+		return TranscriptImport{
+			Topic:        " Tabs or spaces ",
+			Participants: []ImportParticipant{{Name: " Alice "}, {Name: "Chair", Role: "Moderator"}},
+			Turns:        []ImportTurn{{Participant: "alice", Content: "Spaces."}, {Participant: "CHAIR", Content: "Noted."}},
+		}
+	}
+
+	doc := valid()
+	if err := doc.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if doc.Topic != "Tabs or spaces" || doc.Language != DefaultLanguage || doc.Participants[0].Name != "Alice" {
+		t.Errorf("validated = topic %q, language %q, name %q; want them trimmed and defaulted", doc.Topic, doc.Language, doc.Participants[0].Name)
+	}
+	if doc.Participants[0].Role != ImportRoleParticipant || doc.Turns[1].Role != ImportRoleModerator {
+		t.Errorf("roles = %q, %q; want a participant and the moderator's turn as moderator", doc.Participants[0].Role, doc.Turns[1].Role)
+	}
+
+	tests := []struct {
+		name   string
+		change func(*TranscriptImport)
+		want   string
+	}{
+		{"no topic", func(d *TranscriptImport) { d.Topic = " " }, "topic is required"},
+		{"no participants", func(d *TranscriptImport) { d.Participants = nil }, "at least one participant"},
+		{"duplicate names", func(d *TranscriptImport) { d.Participants = append(d.Participants, ImportParticipant{Name: "ALICE"}) }, "listed twice"},
+		{"two moderators", func(d *TranscriptImport) { d.Participants[0].Role = ImportRoleModerator }, "at most one participant"},
+		{"only a moderator", func(d *TranscriptImport) { d.Participants = d.Participants[1:]; d.Turns = d.Turns[1:] }, "must not be the moderator"},
+		{"unknown role", func(d *TranscriptImport) { d.Participants[0].Role = "judge" }, "role must be"},
+		{"no turns", func(d *TranscriptImport) { d.Turns = nil }, "at least one turn"},
+		{"unknown speaker", func(d *TranscriptImport) { d.Turns[0].Participant = "Bob" }, `unknown participant "Bob"`},
+		{"negative round", func(d *TranscriptImport) { d.Turns[0].Round = -1 }, "round must not be negative"},
+		{"turn too long", func(d *TranscriptImport) { d.Turns[0].Content = strings.Repeat("a", MaxImportTurnLength+1) }, "content must be at most"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := valid()
+			tt.change(&doc)
+			if err := doc.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package models
+
+import "strings"
+
+// DefaultLanguage is the language of a discussion created without one
+const DefaultLanguage = "en"
+
+// Language is a discussion language the engine knows by name
+type Language struct {
+	Code string `json:"code"` // ISO 639-1
+	Name string `json:"name"` // English display name, used in prompts
+}
+
+// Languages lists the known languages in the order offered by the UI
+var Languages = []Language{
+	{Code: "en", Name: "English"},
+	{Code: "id", Name: "Indonesian"},
+	{Code: "es", Name: "Spanish"},
+	{Code: "fr", Name: "French"},
+	{Code: "de", Name: "German"},
+	{Code: "ja", Name: "Japanese"},
+	{Code: "zh", Name: "Chinese"},
+}
+
+// languageAliases maps lowercase names, native names and ISO 639-1/639-2
+// codes to a language code. Display names and codes are added in init.
+var languageAliases = map[string]string{
+	"bahasa indonesia": "id",
+	"bahasa":           "id",
+	"indonesia":        "id",
+	"ind":              "id",
+	"eng":              "en",
+	"español":          "es",
+	"espanol":          "es",
+	"castellano":       "es",
+	"spa":              "es",
+	"français":         "fr",
+	"francais":         "fr",
+	"fra":              "fr",
+	"fre":              "fr",
+	"deutsch":          "de",
+	"deu":              "de",
+	"ger":              "de",
+	"日本語":              "ja",
+	"nihongo":          "ja",
+	"jpn":              "ja",
+	"中文":               "zh",
+	"mandarin":         "zh",
+	"zho":              "zh",
+	"chi":              "zh",
+}
+
+func init() {
+	for _, l := range Languages {
+		languageAliases[l.Code] = l.Code
+		languageAliases[strings.ToLower(l.Name)] = l.Code
+	}
+}
+
+// NormalizeLanguage maps a language name or code, in any case, to its
+// canonical code. Region and script subtags are ignored, so "en-US" and
+// "zh_Hant" are recognised. Unknown input is returned trimmed with known
+// false so callers can keep it as free text.
+func NormalizeLanguage(input string) (code string, known bool) {
+	input = strings.TrimSpace(input)
+	key := strings.Join(strings.Fields(strings.ToLower(input)), " ")
+	if code, ok := languageAliases[key]; ok {
+		return code, true
+	}
+	if i := strings.IndexAny(key, "-_"); i > 0 {
+		if code, ok := languageAliases[key[:i]]; ok {
+			return code, true
+		}
+	}
+	return input, false
+}
+
+// LanguageName returns the display name of a language. Discussions stored
+// before languages were normalized hold a name rather than a code, and
+// unknown languages are free text; both are returned as they are.
+func LanguageName(language string) string {
+	code, known := NormalizeLanguage(language)
+	if !known {
+		return code
+	}
+	for _, l := range Languages {
+		if l.Code == code {
+			return l.Name
+		}
+	}
+	return code
+}
+
+// LanguageName returns the display name of the discussion's language
+func (d *Discussion) LanguageName() string {
+	return LanguageName(d.Language)
+}
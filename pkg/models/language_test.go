@@ -0,0 +1,64 @@
+package models
+
+import "testing"
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := []struct {
+		input string
+		code  string
+		known bool
+	}{
+		{"English", "en", true},
+		{"english", "en", true},
+		{"  ENGLISH ", "en", true},
+		{"en", "en", true},
+		{"EN-us", "en", true},
+		{"eng", "en", true},
+		{"Bahasa Indonesia", "id", true},
+		{"bahasa   indonesia", "id", true},
+		{"Indonesian", "id", true},
+		{"id", "id", true},
+		{"ind", "id", true},
+		{"Español", "es", true},
+		{"espanol", "es", true},
+		{"es_MX", "es", true},
+		{"Français", "fr", true},
+		{"fre", "fr", true},
+		{"Deutsch", "de", true},
+		{"de-AT", "de", true},
+		{"日本語", "ja", true},
+		{"Japanese", "ja", true},
+		{"中文", "zh", true},
+		{"zh_Hant", "zh", true},
+		{"Mandarin", "zh", true},
+		// Unknown input passes through trimmed, in its own case
+		{" Klingon ", "Klingon", false},
+		{"x-pirate", "x-pirate", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		code, known := NormalizeLanguage(tt.input)
+		if code != tt.code || known != tt.known {
+			t.Errorf("NormalizeLanguage(%q) = %q, %v; want %q, %v", tt.input, code, known, tt.code, tt.known)
+		}
+	}
+}
+
+func TestLanguageName(t *testing.T) {
+	tests := []struct{ language, want string }{
+		{"id", "Indonesian"},
+		{"zh", "Chinese"},
+		// Stored before normalization
+		{"english", "English"},
+		{"Bahasa Indonesia", "Indonesian"},
+		{"Klingon", "Klingon"},
+	}
+	for _, tt := range tests {
+		if got := LanguageName(tt.language); got != tt.want {
+			t.Errorf("LanguageName(%q) = %q, want %q", tt.language, got, tt.want)
+		}
+	}
+	if got := (&Discussion{Language: "ja"}).LanguageName(); got != "Japanese" {
+		t.Errorf("Discussion.LanguageName = %q, want Japanese", got)
+	}
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Note length limits
+const (
+	MaxNoteLength       = 10000
+	MaxNoteAuthorLength = 100
+)
+
+// DiscussionNote is a reader's annotation attached to a discussion. Notes are
+// never included in agent prompts.
+type DiscussionNote struct {
+	ID           int64     `json:"id" db:"id"`
+	DiscussionID int64     `json:"discussion_id" db:"discussion_id"`
+	Author       string    `json:"author" db:"author"`
+	Content      string    `json:"content" db:"content"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Validate trims the note and checks its fields
+func (n *DiscussionNote) Validate() error {
+	n.Author = strings.TrimSpace(n.Author)
+	n.Content = strings.TrimSpace(n.Content)
+
+	if n.Content == "" {
+		return errors.New("content is required")
+	}
+	if len([]rune(n.Content)) > MaxNoteLength {
+		return fmt.Errorf("content must be at most %d characters", MaxNoteLength)
+	}
+	if len([]rune(n.Author)) > MaxNoteAuthorLength {
+		return fmt.Errorf("author must be at most %d characters", MaxNoteAuthorLength)
+	}
+	if hasControlChars(n.Author) {
+		return errors.New("author must not contain control characters")
+	}
+	return nil
+}
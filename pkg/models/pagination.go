@@ -0,0 +1,145 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Discussion list paging defaults and limits
+const (
+	DefaultDiscussionsPerPage = 20
+	MaxDiscussionsPerPage     = 100
+)
+
+// Discussion list sort columns
+const (
+	DiscussionSortCreatedAt = "created_at"
+	DiscussionSortUpdatedAt = "updated_at"
+	DiscussionSortStatus    = "status"
+)
+
+// Sort orders
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// DiscussionStatuses are the statuses the discussion list can be filtered by
+var DiscussionStatuses = []string{"running", "paused", "completed", "stopped", "failed", DiscussionStatusImported}
+
+// DiscussionQuery selects one page of the discussion list. Zero values mean
+// the first page of DefaultDiscussionsPerPage newest discussions. The
+// filters are combined: Status matches exactly, AgentID any participant, and
+// discussions are created at or after From and before Until.
+type DiscussionQuery struct {
+	Page    int
+	PerPage int
+	Sort    string
+	Order   string
+
+	Status  string
+	AgentID int64
+	From    time.Time
+	Until   time.Time
+}
+
+// Normalize fills in defaults and validates the query
+func (q *DiscussionQuery) Normalize() error {
+	if q.Page == 0 {
+		q.Page = 1
+	}
+	if q.PerPage == 0 {
+		q.PerPage = DefaultDiscussionsPerPage
+	}
+	if q.Sort == "" {
+		q.Sort = DiscussionSortCreatedAt
+	}
+	if q.Order == "" {
+		q.Order = SortDesc
+	}
+
+	if q.Page < 1 {
+		return errors.New("page must be at least 1")
+	}
+	if q.PerPage < 1 || q.PerPage > MaxDiscussionsPerPage {
+		return fmt.Errorf("per_page must be between 1 and %d", MaxDiscussionsPerPage)
+	}
+	switch q.Sort {
+	case DiscussionSortCreatedAt, DiscussionSortUpdatedAt, DiscussionSortStatus:
+	default:
+		return fmt.Errorf("sort must be %s, %s or %s", DiscussionSortCreatedAt, DiscussionSortUpdatedAt, DiscussionSortStatus)
+	}
+	if q.Order != SortAsc && q.Order != SortDesc {
+		return fmt.Errorf("order must be %s or %s", SortAsc, SortDesc)
+	}
+	if q.Status != "" && !validDiscussionStatus(q.Status) {
+		return fmt.Errorf("status must be one of %s", strings.Join(DiscussionStatuses, ", "))
+	}
+	if q.AgentID < 0 {
+		return errors.New("agent_id must be positive")
+	}
+	if !q.From.IsZero() && !q.Until.IsZero() && !q.From.Before(q.Until) {
+		return errors.New("from must be before to")
+	}
+	return nil
+}
+
+// validDiscussionStatus reports whether status is one of DiscussionStatuses
+func validDiscussionStatus(status string) bool {
+	for _, s := range DiscussionStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Encode returns the query as URL query parameters for the given page, in
+// the form the list handlers parse. Dates are written as timestamps.
+func (q DiscussionQuery) Encode(page int) string {
+	v := url.Values{}
+	v.Set("page", strconv.Itoa(page))
+	v.Set("per_page", strconv.Itoa(q.PerPage))
+	v.Set("sort", q.Sort)
+	v.Set("order", q.Order)
+	if q.Status != "" {
+		v.Set("status", q.Status)
+	}
+	if q.AgentID != 0 {
+		v.Set("agent_id", strconv.FormatInt(q.AgentID, 10))
+	}
+	if !q.From.IsZero() {
+		v.Set("from", q.From.Format(time.RFC3339))
+	}
+	if !q.Until.IsZero() {
+		// to is inclusive while Until is not
+		v.Set("to", q.Until.Add(-time.Second).Format(time.RFC3339))
+	}
+	return v.Encode()
+}
+
+// Offset is the number of discussions before the query's page
+func (q DiscussionQuery) Offset() int {
+	return (q.Page - 1) * q.PerPage
+}
+
+// DiscussionPage is one page of the discussion list. Total counts every
+// discussion the query matches, not only those on the page.
+type DiscussionPage struct {
+	Items   []*Discussion `json:"items"`
+	Total   int           `json:"total"`
+	Page    int           `json:"page"`
+	PerPage int           `json:"per_page"`
+}
+
+// Pages is the number of pages needed for Total, at least one
+func (p DiscussionPage) Pages() int {
+	if p.Total <= p.PerPage || p.PerPage <= 0 {
+		return 1
+	}
+	return (p.Total + p.PerPage - 1) / p.PerPage
+}
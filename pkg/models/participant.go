@@ -0,0 +1,91 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Debate stances an agent can be assigned in a discussion
+const (
+	StancePro     = "pro"
+	StanceCon     = "con"
+	StanceNeutral = "neutral"
+)
+
+// AgentStance assigns an agent the side it argues in a discussion
+type AgentStance struct {
+	AgentID int64  `json:"agent_id" db:"agent_id"`
+	Stance  string `json:"stance" db:"stance"` // pro, con, neutral
+}
+
+// ValidateStances normalizes the stances of a discussion request and checks
+// that each names a known stance and one of agentIDs, at most once
+func ValidateStances(stances []AgentStance, agentIDs []int64) error {
+	inDiscussion := make(map[int64]bool, len(agentIDs))
+	for _, id := range agentIDs {
+		inDiscussion[id] = true
+	}
+
+	seen := make(map[int64]bool, len(stances))
+	for i := range stances {
+		s := &stances[i]
+		s.Stance = strings.ToLower(strings.TrimSpace(s.Stance))
+		switch s.Stance {
+		case StancePro, StanceCon, StanceNeutral:
+		default:
+			return fmt.Errorf("stance of agent %d must be %q, %q or %q", s.AgentID, StancePro, StanceCon, StanceNeutral)
+		}
+		if !inDiscussion[s.AgentID] {
+			return fmt.Errorf("agent %d has a stance but is not in agent_ids", s.AgentID)
+		}
+		if seen[s.AgentID] {
+			return fmt.Errorf("agent %d has more than one stance", s.AgentID)
+		}
+		seen[s.AgentID] = true
+	}
+	return nil
+}
+
+// StanceOf returns the stance assigned to an agent, or "" when it has none
+func (d *Discussion) StanceOf(agentID int64) string {
+	for _, s := range d.Stances {
+		if s.AgentID == agentID {
+			return s.Stance
+		}
+	}
+	return ""
+}
+
+// ApplyStances sets the stance of each agent's entries in logs. Moderator and
+// engine entries have none.
+func (d *Discussion) ApplyStances(logs []*DiscussionLog) {
+	if len(d.Stances) == 0 {
+		return
+	}
+	for _, l := range logs {
+		if !l.IsModerator && !l.IsSystem() {
+			l.Stance = d.StanceOf(l.AgentID)
+		}
+	}
+}
+
+// ParticipantSummary is where a debating agent ended up: its final position
+// and how its turns went. FinalPosition is its closing statement when the
+// lightning round ran, and otherwise its last accepted reply. JudgeScore and
+// Winner are set once a judge has ruled, Compliance once its turns were
+// checked. Votes tallies the reader ratings of its turns.
+type ParticipantSummary struct {
+	AgentID            int64            `json:"agent_id"`
+	Stance             string           `json:"stance,omitempty"`
+	FinalPosition      string           `json:"final_position"`
+	FinalPositionLogID int64            `json:"final_position_log_id,omitempty"`
+	ClosingStatement   bool             `json:"closing_statement"`
+	Turns              int              `json:"turns"`    // accepted replies
+	Failures           int              `json:"failures"` // calls that timed out or failed
+	TotalTokens        int              `json:"total_tokens"`
+	AvgResponseTimeMs  int              `json:"avg_response_time_ms"` // of the accepted replies
+	JudgeScore         *int             `json:"judge_score,omitempty"`
+	Winner             bool             `json:"winner"`
+	Votes              RatingCounts     `json:"votes"`
+	Compliance         *AgentCompliance `json:"compliance,omitempty"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateStances(t *testing.T) {
+	stances := []AgentStance{{AgentID: 1, Stance: " PRO "}, {AgentID: 2, Stance: "Con"}, {AgentID: 3, Stance: "neutral"}}
+	if err := ValidateStances(stances, []int64{1, 2, 3}); err != nil {
+		t.Fatalf("ValidateStances: %v", err)
+	}
+	if stances[0].Stance != StancePro || stances[1].Stance != StanceCon || stances[2].Stance != StanceNeutral {
+		t.Errorf("stances = %+v, want them normalized", stances)
+	}
+
+	tests := []struct {
+		stances []AgentStance
+		want    string
+	}{
+		{[]AgentStance{{AgentID: 1, Stance: "for"}}, "stance of agent 1 must be"},
+		{[]AgentStance{{AgentID: 1, Stance: ""}}, "stance of agent 1 must be"},
+		{[]AgentStance{{AgentID: 4, Stance: "pro"}}, "agent 4 has a stance but is not in agent_ids"},
+		{[]AgentStance{{AgentID: 1, Stance: "pro"}, {AgentID: 1, Stance: "con"}}, "agent 1 has more than one stance"},
+	}
+	for _, tt := range tests {
+		err := ValidateStances(tt.stances, []int64{1, 2})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ValidateStances(%+v) = %v, want %q", tt.stances, err, tt.want)
+		}
+	}
+}
+
+func TestApplyStances(t *testing.T) {
+	d := &Discussion{Stances: []AgentStance{{AgentID: 1, Stance: StancePro}, {AgentID: 2, Stance: StanceCon}}}
+	logs := []*DiscussionLog{
+		{AgentID: 1, LogType: LogTypeResponse},
+		{AgentID: 2, LogType: LogTypeResponse},
+		{AgentID: 3, LogType: LogTypeResponse},
+		{AgentID: 1, LogType: LogTypeResponse, IsModerator: true},
+		{AgentID: SystemAgentID, LogType: LogTypeSystem},
+	}
+	d.ApplyStances(logs)
+
+	want := []string{StancePro, StanceCon, "", "", ""}
+	for i, l := range logs {
+		if l.Stance != want[i] {
+			t.Errorf("log %d stance = %q, want %q", i, l.Stance, want[i])
+		}
+	}
+	if d.StanceOf(2) != StanceCon || d.StanceOf(3) != "" {
+		t.Errorf("StanceOf = %q, %q", d.StanceOf(2), d.StanceOf(3))
+	}
+}
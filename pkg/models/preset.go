@@ -0,0 +1,145 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Preset is a named debate format. A discussion request naming it starts
+// from these values, and any field the request sets itself wins.
+type Preset struct {
+	Name        string             `json:"name"`
+	Title       string             `json:"title"`
+	Description string             `json:"description"`
+	MinAgents   int                `json:"min_agents"`
+	MaxRounds   int                `json:"max_rounds"`
+	Settings    DiscussionSettings `json:"settings"`
+	// StanceRule describes the sides Stances assigns
+	StanceRule string `json:"stance_rule,omitempty"`
+	// ModeratorJudges makes the moderator give the verdict when the request
+	// names no judge
+	ModeratorJudges bool `json:"moderator_judges"`
+
+	// Stances assigns sides to the agents of a request, in speaking order.
+	// It is only used when the request sets no stances.
+	Stances func(agentIDs []int64) []AgentStance `json:"-"`
+}
+
+var (
+	presetsMu sync.RWMutex
+	presets   = map[string]Preset{}
+)
+
+// RegisterPreset adds a preset. It panics on a duplicate name or invalid
+// settings, so a broken preset stops the program at startup rather than
+// failing requests.
+func RegisterPreset(p Preset) {
+	if p.Name == "" {
+		panic("preset without a name")
+	}
+	if err := p.Settings.Validate(); err != nil {
+		panic(fmt.Sprintf("preset %s: invalid settings: %v", p.Name, err))
+	}
+	if p.MaxRounds != 0 && (p.MaxRounds < MinMaxRounds || p.MaxRounds > MaxMaxRounds) {
+		panic(fmt.Sprintf("preset %s: max_rounds out of range", p.Name))
+	}
+
+	presetsMu.Lock()
+	defer presetsMu.Unlock()
+	if _, ok := presets[p.Name]; ok {
+		panic("preset registered twice: " + p.Name)
+	}
+	presets[p.Name] = p
+}
+
+// LookupPreset returns the preset registered as name
+func LookupPreset(name string) (Preset, bool) {
+	presetsMu.RLock()
+	defer presetsMu.RUnlock()
+	p, ok := presets[name]
+	return p, ok
+}
+
+// Presets lists the registered presets by name
+func Presets() []Preset {
+	presetsMu.RLock()
+	defer presetsMu.RUnlock()
+	list := make([]Preset, 0, len(presets))
+	for _, p := range presets {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Built-in presets
+const (
+	PresetOxford         = "oxford"
+	PresetFishbowl       = "fishbowl"
+	PresetDevilsAdvocate = "devils_advocate"
+)
+
+func init() {
+	RegisterPreset(Preset{
+		Name:        PresetOxford,
+		Title:       "Oxford-style debate",
+		Description: "Agents alternate for and against the motion over three rounds, and the moderator closes by judging which side carried the debate.",
+		MinAgents:   2,
+		MaxRounds:   3,
+		Settings: DiscussionSettings{
+			RoundMode:     RoundModeSequential,
+			PromptProfile: PromptProfileStandard,
+		},
+		StanceRule:      "alternating pro and con in speaking order",
+		ModeratorJudges: true,
+		Stances: func(agentIDs []int64) []AgentStance {
+			stances := make([]AgentStance, 0, len(agentIDs))
+			for i, id := range agentIDs {
+				stance := StancePro
+				if i%2 == 1 {
+					stance = StanceCon
+				}
+				stances = append(stances, AgentStance{AgentID: id, Stance: stance})
+			}
+			return stances
+		},
+	})
+
+	RegisterPreset(Preset{
+		Name:        PresetFishbowl,
+		Title:       "Fishbowl",
+		Description: "Two agents at a time hold the floor while the others listen, rotating each round so everyone takes part, until the positions converge.",
+		MinAgents:   3,
+		MaxRounds:   6,
+		Settings: DiscussionSettings{
+			RoundMode:            RoundModeSequential,
+			ActiveSpeakers:       2,
+			EnableConsensusCheck: true,
+		},
+	})
+
+	RegisterPreset(Preset{
+		Name:        PresetDevilsAdvocate,
+		Title:       "Devil's advocate",
+		Description: "The last agent argues against the topic every round, whatever the others conclude, to stress-test their reasoning.",
+		MinAgents:   2,
+		MaxRounds:   3,
+		Settings: DiscussionSettings{
+			RoundMode: RoundModeSequential,
+			Analyze:   true,
+		},
+		StanceRule: "con for the last agent, neutral for the others",
+		Stances: func(agentIDs []int64) []AgentStance {
+			stances := make([]AgentStance, 0, len(agentIDs))
+			for i, id := range agentIDs {
+				stance := StanceNeutral
+				if i == len(agentIDs)-1 {
+					stance = StanceCon
+				}
+				stances = append(stances, AgentStance{AgentID: id, Stance: stance})
+			}
+			return stances
+		},
+	})
+}
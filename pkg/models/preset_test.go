@@ -0,0 +1,107 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBuiltinPresets(t *testing.T) {
+	list := Presets()
+	var names []string
+	for _, p := range list {
+		names = append(names, p.Name)
+	}
+	if fmt.Sprint(names) != fmt.Sprint([]string{PresetDevilsAdvocate, PresetFishbowl, PresetOxford}) {
+		t.Fatalf("presets = %v, want the built-ins by name", names)
+	}
+	for _, p := range list {
+		if err := p.Settings.Validate(); err != nil {
+			t.Errorf("%s: settings invalid: %v", p.Name, err)
+		}
+		if p.MinAgents < 2 || p.MaxRounds < MinMaxRounds || p.MaxRounds > MaxMaxRounds {
+			t.Errorf("%s: %d agents over %d rounds", p.Name, p.MinAgents, p.MaxRounds)
+		}
+		if _, ok := LookupPreset(p.Name); !ok {
+			t.Errorf("LookupPreset(%q) found nothing", p.Name)
+		}
+	}
+	if _, ok := LookupPreset("Oxford"); ok {
+		t.Error("LookupPreset matched a name in another case")
+	}
+}
+
+func TestPresetStances(t *testing.T) {
+	ids := []int64{4, 7, 9}
+	tests := []struct {
+		preset string
+		want   []string
+	}{
+		{PresetOxford, []string{StancePro, StanceCon, StancePro}},
+		{PresetDevilsAdvocate, []string{StanceNeutral, StanceNeutral, StanceCon}},
+	}
+	for _, tt := range tests {
+		p, _ := LookupPreset(tt.preset)
+		stances := p.Stances(ids)
+		if len(stances) != len(ids) {
+			t.Fatalf("%s: %d stances for %d agents", tt.preset, len(stances), len(ids))
+		}
+		for i, s := range stances {
+			if s.AgentID != ids[i] || s.Stance != tt.want[i] {
+				t.Errorf("%s: stance %d = %+v, want %s for agent %d", tt.preset, i, s, tt.want[i], ids[i])
+			}
+		}
+	}
+}
+
+func TestRegisterPresetPanics(t *testing.T) {
+	tests := []struct {
+		name   string
+		preset Preset
+	}{
+		{"no name", Preset{MaxRounds: 3}},
+		{"duplicate", Preset{Name: PresetOxford}},
+		{"invalid settings", Preset{Name: "broken", Settings: DiscussionSettings{ActiveSpeakers: -1}}},
+		{"too many rounds", Preset{Name: "endless", MaxRounds: MaxMaxRounds + 1}},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: RegisterPreset did not panic", tt.name)
+				}
+			}()
+			RegisterPreset(tt.preset)
+		}()
+	}
+	if _, ok := LookupPreset("broken"); ok {
+		t.Error("a preset that panicked was registered")
+	}
+}
+
+func TestRoundSpeakers(t *testing.T) {
+	tests := []struct {
+		active, agents int
+		want           [][]int
+	}{
+		// Everyone speaks without a window, or with one as large as the table
+		{0, 3, [][]int{{0, 1, 2}, {0, 1, 2}}},
+		{3, 3, [][]int{{0, 1, 2}, {0, 1, 2}}},
+		{5, 3, [][]int{{0, 1, 2}}},
+		// Each round's window starts where the previous one ended
+		{2, 3, [][]int{{0, 1}, {2, 0}, {1, 2}, {0, 1}}},
+		{2, 4, [][]int{{0, 1}, {2, 3}, {0, 1}}},
+		{1, 2, [][]int{{0}, {1}, {0}}},
+	}
+	for _, tt := range tests {
+		settings := DiscussionSettings{ActiveSpeakers: tt.active}
+		for i, want := range tt.want {
+			if got := settings.RoundSpeakers(tt.agents, i+1); fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("%d of %d agents, round %d: speakers = %v, want %v", tt.active, tt.agents, i+1, got, want)
+			}
+		}
+	}
+	unknown := DiscussionSettings{Preset: "marathon"}
+	if err := unknown.Validate(); err == nil {
+		t.Error("Validate accepted an unknown preset")
+	}
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Pricing limits
+const (
+	MaxPricingModelLength = 200
+	MaxPricePer1K         = 1000.0
+)
+
+// PricingAnyModel as a model name prices every model of a provider that has
+// no entry of its own, e.g. to mark a local Ollama server as free
+const PricingAnyModel = "*"
+
+// ModelPricing is the USD price per 1,000 tokens of one provider and model
+type ModelPricing struct {
+	ID          int64     `json:"id" db:"id"`
+	Provider    string    `json:"provider" db:"provider"` // an agent provider type
+	Model       string    `json:"model" db:"model"`       // as configured on agents, or "*"
+	InputPer1K  float64   `json:"input_per_1k" db:"input_per_1k"`
+	OutputPer1K float64   `json:"output_per_1k" db:"output_per_1k"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Validate normalizes the provider and model and checks the rates
+func (p *ModelPricing) Validate() error {
+	p.Provider = strings.ToLower(strings.TrimSpace(p.Provider))
+	p.Model = strings.TrimSpace(p.Model)
+
+	valid := false
+	for _, t := range ProviderTypes {
+		valid = valid || p.Provider == t
+	}
+	if !valid {
+		return fmt.Errorf("provider must be one of %s", strings.Join(ProviderTypes, ", "))
+	}
+	if p.Model == "" {
+		return errors.New("model is required")
+	}
+	if len(p.Model) > MaxPricingModelLength {
+		return fmt.Errorf("model must be at most %d characters", MaxPricingModelLength)
+	}
+	if p.InputPer1K < 0 || p.InputPer1K > MaxPricePer1K || p.OutputPer1K < 0 || p.OutputPer1K > MaxPricePer1K {
+		return fmt.Errorf("input_per_1k and output_per_1k must be between 0 and %g", MaxPricePer1K)
+	}
+	return nil
+}
+
+// Cost returns the USD cost of a call with the given token counts
+func (p *ModelPricing) Cost(promptTokens, completionTokens int) float64 {
+	return float64(promptTokens)/1000*p.InputPer1K + float64(completionTokens)/1000*p.OutputPer1K
+}
+
+// DiscussionCost is the estimated USD cost of a discussion's agent and
+// moderator calls. A cost is nil when it cannot be estimated because a call
+// did not report token usage or its model has no pricing.
+type DiscussionCost struct {
+	Currency       string      `json:"currency"`
+	TotalCost      *float64    `json:"total_cost"`
+	Agents         []AgentCost `json:"agents"`
+	UnpricedModels []string    `json:"unpriced_models"` // "provider/model" without pricing
+}
+
+// AgentCost is the estimated USD cost of one agent's calls in a discussion
+type AgentCost struct {
+	AgentID          int64    `json:"agent_id"`
+	Calls            int      `json:"calls"`
+	PromptTokens     int      `json:"prompt_tokens"`
+	CompletionTokens int      `json:"completion_tokens"`
+	Cost             *float64 `json:"cost"`
+	UnpricedCalls    int      `json:"unpriced_calls"`
+}
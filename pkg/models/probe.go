@@ -0,0 +1,72 @@
+package models
+
+// ProbeRequest is the provider to probe before an agent is created
+type ProbeRequest struct {
+	ProviderURL string `json:"provider_url"`
+	APIToken    string `json:"api_token"`
+}
+
+// ProbeCheck is one request made while probing a provider, testing whether
+// it answers like ProviderType
+type ProbeCheck struct {
+	ProviderType string   `json:"provider_type"`
+	Endpoint     string   `json:"endpoint"`
+	Status       int      `json:"status,omitempty"` // HTTP status, 0 when no response came back
+	Matched      bool     `json:"matched"`
+	Models       []string `json:"models,omitempty"`
+	LatencyMs    int64    `json:"latency_ms"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// ProbeSuggestion is the agent configuration suggested by a probe. It has
+// the fields of an agent request so a form can be filled from it.
+type ProbeSuggestion struct {
+	ProviderType   string `json:"provider_type"`
+	ProviderURL    string `json:"provider_url"`
+	EndpointStyle  string `json:"endpoint_style"`
+	ModelName      string `json:"model_name"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+}
+
+// ProbeResult is what a provider probe found. Detected is false when no
+// check matched, and the suggestion then keeps the custom provider type.
+type ProbeResult struct {
+	Detected   bool            `json:"detected"`
+	Models     []string        `json:"models"`
+	LatencyMs  int64           `json:"latency_ms"` // of the matching check
+	DurationMs int64           `json:"duration_ms"`
+	Checks     []ProbeCheck    `json:"checks"`
+	Suggested  ProbeSuggestion `json:"suggested"`
+}
+
+// ProviderModel is one model a provider lists for an agent. Name is the
+// provider's display name, or the ID when it has none.
+type ProviderModel struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// DefaultAgentTestPrompt is sent by an agent test call without a prompt
+const DefaultAgentTestPrompt = "Say hello in one sentence"
+
+// MaxAgentTestPromptLength caps the prompt of an agent test call
+const MaxAgentTestPromptLength = 4000
+
+// AgentTestRequest is the prompt of an agent test call
+type AgentTestRequest struct {
+	Prompt string `json:"prompt"`
+}
+
+// AgentTestResult is the reply to an agent test call. Error is set when the
+// call failed, with whatever the provider returned before it did.
+type AgentTestResult struct {
+	Success          bool              `json:"success"`
+	Prompt           string            `json:"prompt"`
+	Content          string            `json:"content"`
+	ResponseTime     int               `json:"response_time"` // in milliseconds
+	PromptTokens     int               `json:"prompt_tokens"`
+	CompletionTokens int               `json:"completion_tokens"`
+	TotalTokens      int               `json:"total_tokens"`
+	Error            string            `json:"error,omitempty"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+}
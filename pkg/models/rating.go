@@ -0,0 +1,59 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxRatingNoteLength caps the note stored with a rating
+const MaxRatingNoteLength = 1000
+
+// LogRating is a reader's verdict on one turn: 1 for a good argument, -1 for
+// a poor one and 0 for neutral. Rater is empty until the app has users; each
+// rater has at most one rating per log entry.
+type LogRating struct {
+	LogID        int64     `json:"log_id" db:"log_id"`
+	DiscussionID int64     `json:"discussion_id" db:"discussion_id"`
+	AgentID      int64     `json:"agent_id" db:"agent_id"`
+	Rater        string    `json:"rater,omitempty" db:"rater"`
+	Rating       int       `json:"rating" db:"rating"`
+	Note         string    `json:"note" db:"note"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Validate trims the note and checks the rating value
+func (r *LogRating) Validate() error {
+	r.Note = strings.TrimSpace(r.Note)
+	if r.Rating < -1 || r.Rating > 1 {
+		return fmt.Errorf("rating must be -1, 0 or 1")
+	}
+	if len([]rune(r.Note)) > MaxRatingNoteLength {
+		return fmt.Errorf("note must be at most %d characters", MaxRatingNoteLength)
+	}
+	return nil
+}
+
+// RatingCounts tallies ratings by value
+type RatingCounts struct {
+	Up      int `json:"up"`
+	Down    int `json:"down"`
+	Neutral int `json:"neutral"`
+}
+
+// CountRatings tallies ratings by value
+func CountRatings(ratings []*LogRating) RatingCounts {
+	var c RatingCounts
+	for _, r := range ratings {
+		switch {
+		case r.Rating > 0:
+			c.Up++
+		case r.Rating < 0:
+			c.Down++
+		default:
+			c.Neutral++
+		}
+	}
+	return c
+}
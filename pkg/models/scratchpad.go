@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// MaxScratchpadLength caps the notes kept for one agent; longer notes are cut
+const MaxScratchpadLength = 2000
+
+// AgentScratchpad holds the private working notes an agent keeps during a
+// discussion with DiscussionSettings.Scratchpad on. Each new notes block
+// replaces the previous one, and only the agent that wrote the notes sees
+// them, at the top of its later prompts.
+type AgentScratchpad struct {
+	DiscussionID int64     `json:"discussion_id" db:"discussion_id"`
+	AgentID      int64     `json:"agent_id" db:"agent_id"`
+	Notes        string    `json:"notes" db:"notes"`
+	Round        int       `json:"round" db:"round"` // round the notes were written in
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
@@ -0,0 +1,29 @@
+package models
+
+// Self-test stages, in the order they run
+const (
+	SelfTestStageDatabase  = "database"
+	SelfTestStageEngine    = "engine"
+	SelfTestStageBroadcast = "broadcast"
+	SelfTestStageSummary   = "summary"
+	SelfTestStageCleanup   = "cleanup"
+)
+
+// SelfTestReport is the outcome of an end-to-end self-test debate. A failed
+// report names the first stage that failed; later stages are not run, except
+// cleanup, which always is.
+type SelfTestReport struct {
+	Passed      bool            `json:"passed"`
+	DurationMs  int64           `json:"duration_ms"`
+	FailedStage string          `json:"failed_stage,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	Stages      []SelfTestStage `json:"stages"`
+}
+
+// SelfTestStage is the result and timing of one self-test stage
+type SelfTestStage struct {
+	Name       string `json:"name"`
+	Passed     bool   `json:"passed"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
@@ -0,0 +1,743 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Parameter ranges shared by agent calls and per-discussion overrides
+const (
+	MinTemperature        = 0.0
+	MaxTemperature        = 2.0
+	MaxMaxTokens          = 32768
+	MaxSystemPromptLength = 8000
+)
+
+// Watchdog defaults
+const (
+	DefaultWatchdogStallMinutes = 15
+)
+
+// WatchdogConfig controls detection of debates that stopped making progress
+type WatchdogConfig struct {
+	StallMinutes int  `json:"stall_minutes"`
+	ForceFail    bool `json:"force_fail"`
+}
+
+// Validate fills defaults and checks the watchdog configuration
+func (w *WatchdogConfig) Validate() error {
+	if w.StallMinutes == 0 {
+		w.StallMinutes = DefaultWatchdogStallMinutes
+	}
+	if w.StallMinutes < 1 || w.StallMinutes > 24*60 {
+		return fmt.Errorf("stall_minutes must be between 1 and %d", 24*60)
+	}
+	return nil
+}
+
+// DefaultSlowCallSeconds applies until a slow-call threshold is saved
+const DefaultSlowCallSeconds = 60
+
+// SlowCallConfig controls tracing of agent calls that take unusually long.
+// Calls slower than ThresholdSeconds are written to the discussion log and
+// raise a slow_call event; zero turns tracing off.
+type SlowCallConfig struct {
+	ThresholdSeconds int `json:"threshold_seconds"`
+}
+
+// Validate checks the slow-call configuration
+func (s *SlowCallConfig) Validate() error {
+	if s.ThresholdSeconds < 0 || s.ThresholdSeconds > MaxAgentTimeoutCeilingSeconds {
+		return fmt.Errorf("threshold_seconds must be between 0 and %d", MaxAgentTimeoutCeilingSeconds)
+	}
+	return nil
+}
+
+// Delta coalescing defaults and limits
+const (
+	DefaultDeltaFlushMs        = 250
+	MinDeltaFlushMs            = 50
+	MaxDeltaFlushMs            = 5000
+	DefaultDeltaMaxBufferBytes = 4096
+	MinDeltaMaxBufferBytes     = 256
+	MaxDeltaMaxBufferBytes     = 1 << 20
+)
+
+// DeltaCoalescingConfig controls how partial turn content is batched before
+// it is sent to live subscribers. Deltas of a turn are flushed at most every
+// FlushMs, or sooner once MaxBufferBytes have piled up since the last flush.
+type DeltaCoalescingConfig struct {
+	FlushMs        int `json:"flush_ms"`
+	MaxBufferBytes int `json:"max_buffer_bytes"`
+}
+
+// Validate fills defaults and checks the delta coalescing configuration
+func (d *DeltaCoalescingConfig) Validate() error {
+	if d.FlushMs == 0 {
+		d.FlushMs = DefaultDeltaFlushMs
+	}
+	if d.MaxBufferBytes == 0 {
+		d.MaxBufferBytes = DefaultDeltaMaxBufferBytes
+	}
+	if d.FlushMs < MinDeltaFlushMs || d.FlushMs > MaxDeltaFlushMs {
+		return fmt.Errorf("flush_ms must be between %d and %d", MinDeltaFlushMs, MaxDeltaFlushMs)
+	}
+	if d.MaxBufferBytes < MinDeltaMaxBufferBytes || d.MaxBufferBytes > MaxDeltaMaxBufferBytes {
+		return fmt.Errorf("max_buffer_bytes must be between %d and %d", MinDeltaMaxBufferBytes, MaxDeltaMaxBufferBytes)
+	}
+	return nil
+}
+
+// Subscriber limit defaults and bounds
+const (
+	DefaultMaxSubscribers        = 50
+	MaxMaxSubscribers            = 1000
+	DefaultSubscriberIdleSeconds = 60
+	MinSubscriberIdleSeconds     = 5
+	MaxSubscriberIdleSeconds     = 3600
+)
+
+// SubscriberLimitsConfig bounds the live stream subscribers of a discussion.
+// At most MaxPerDiscussion SSE, WebSocket and long-poll subscribers attach
+// to one discussion; a subscriber whose event buffer stays full for
+// IdleSeconds is not reading and is dropped.
+type SubscriberLimitsConfig struct {
+	MaxPerDiscussion int `json:"max_per_discussion"`
+	IdleSeconds      int `json:"idle_seconds"`
+}
+
+// Validate fills defaults and checks the subscriber limits
+func (s *SubscriberLimitsConfig) Validate() error {
+	if s.MaxPerDiscussion == 0 {
+		s.MaxPerDiscussion = DefaultMaxSubscribers
+	}
+	if s.IdleSeconds == 0 {
+		s.IdleSeconds = DefaultSubscriberIdleSeconds
+	}
+	if s.MaxPerDiscussion < 1 || s.MaxPerDiscussion > MaxMaxSubscribers {
+		return fmt.Errorf("max_per_discussion must be between 1 and %d", MaxMaxSubscribers)
+	}
+	if s.IdleSeconds < MinSubscriberIdleSeconds || s.IdleSeconds > MaxSubscriberIdleSeconds {
+		return fmt.Errorf("idle_seconds must be between %d and %d", MinSubscriberIdleSeconds, MaxSubscriberIdleSeconds)
+	}
+	return nil
+}
+
+// SubscriberStats reports the subscriber limits with the subscribers
+// attached now, per discussion ID
+type SubscriberStats struct {
+	SubscriberLimitsConfig
+	Total       int           `json:"total"`
+	Discussions map[int64]int `json:"discussions"`
+	// Reaped counts the subscribers dropped for not reading since startup
+	Reaped int64 `json:"reaped"`
+}
+
+// Duplicate guard defaults and limits
+const (
+	DefaultDuplicateWindowSeconds = 10
+	MaxDuplicateWindowSeconds     = 300
+)
+
+// DuplicateGuardConfig controls suppression of repeated discussion
+// submissions. A request that opts in with suppress_duplicates and matches
+// a discussion created from the same client, topic and agents within
+// WindowSeconds gets that discussion back instead of a new one; zero turns
+// suppression off.
+type DuplicateGuardConfig struct {
+	WindowSeconds int `json:"window_seconds"`
+}
+
+// Validate checks the duplicate guard configuration
+func (d *DuplicateGuardConfig) Validate() error {
+	if d.WindowSeconds < 0 || d.WindowSeconds > MaxDuplicateWindowSeconds {
+		return fmt.Errorf("window_seconds must be between 0 and %d", MaxDuplicateWindowSeconds)
+	}
+	return nil
+}
+
+// Transcript log content modes
+const (
+	TranscriptContentFull = "full"
+	TranscriptContentHash = "hash"
+)
+
+// Transcript log defaults
+const (
+	DefaultTranscriptMaxFileMB     = 50
+	DefaultTranscriptRetentionDays = 90
+)
+
+// TranscriptLogConfig controls the append-only transcript files written
+// alongside the database
+type TranscriptLogConfig struct {
+	Enabled   bool   `json:"enabled"`
+	Directory string `json:"directory"`
+	// Content is "full" to write agent replies verbatim or "hash" to write
+	// only their SHA-256
+	Content       string `json:"content"`
+	MaxFileMB     int    `json:"max_file_mb"`
+	RetentionDays int    `json:"retention_days"`
+}
+
+// Validate fills defaults and checks the transcript log configuration
+func (t *TranscriptLogConfig) Validate() error {
+	t.Directory = strings.TrimSpace(t.Directory)
+	if t.Content == "" {
+		t.Content = TranscriptContentFull
+	}
+	if t.MaxFileMB == 0 {
+		t.MaxFileMB = DefaultTranscriptMaxFileMB
+	}
+	if t.RetentionDays == 0 {
+		t.RetentionDays = DefaultTranscriptRetentionDays
+	}
+
+	if t.Enabled && t.Directory == "" {
+		return errors.New("directory is required when the transcript log is enabled")
+	}
+	if t.Content != TranscriptContentFull && t.Content != TranscriptContentHash {
+		return fmt.Errorf("content must be %q or %q", TranscriptContentFull, TranscriptContentHash)
+	}
+	if t.MaxFileMB < 1 || t.MaxFileMB > 10240 {
+		return errors.New("max_file_mb must be between 1 and 10240")
+	}
+	if t.RetentionDays < 1 || t.RetentionDays > 3650 {
+		return errors.New("retention_days must be between 1 and 3650")
+	}
+	return nil
+}
+
+// Agent call timeout defaults, overridable through settings
+const (
+	DefaultAgentTimeoutSeconds       = 30
+	DefaultMaxAgentTimeoutSeconds    = 180
+	DefaultAgentTimeoutBufferSeconds = 10
+	MaxAgentTimeoutCeilingSeconds    = 3600
+)
+
+// AgentTimeouts holds the effective timeout settings for agent calls
+type AgentTimeouts struct {
+	DefaultSeconds int `json:"default_agent_timeout_seconds"`
+	MaxSeconds     int `json:"max_agent_timeout_seconds"`
+	BufferSeconds  int `json:"agent_timeout_buffer_seconds"`
+}
+
+// DefaultAgentTimeouts returns the built-in timeout settings
+func DefaultAgentTimeouts() AgentTimeouts {
+	return AgentTimeouts{
+		DefaultSeconds: DefaultAgentTimeoutSeconds,
+		MaxSeconds:     DefaultMaxAgentTimeoutSeconds,
+		BufferSeconds:  DefaultAgentTimeoutBufferSeconds,
+	}
+}
+
+// Validate checks that the timeouts are in range and consistent with each other
+func (t *AgentTimeouts) Validate() error {
+	if t.MaxSeconds < 1 || t.MaxSeconds > MaxAgentTimeoutCeilingSeconds {
+		return fmt.Errorf("max_agent_timeout_seconds must be between 1 and %d", MaxAgentTimeoutCeilingSeconds)
+	}
+	if t.DefaultSeconds < 1 || t.DefaultSeconds > t.MaxSeconds {
+		return fmt.Errorf("default_agent_timeout_seconds must be between 1 and %d", t.MaxSeconds)
+	}
+	if t.BufferSeconds < 0 || t.BufferSeconds > 300 {
+		return errors.New("agent_timeout_buffer_seconds must be between 0 and 300")
+	}
+	return nil
+}
+
+// Clamp returns the timeout to use for an agent configured with seconds:
+// zero falls back to the default and anything above the ceiling is capped
+func (t AgentTimeouts) Clamp(seconds int) int {
+	if seconds <= 0 {
+		seconds = t.DefaultSeconds
+	}
+	if seconds > t.MaxSeconds {
+		seconds = t.MaxSeconds
+	}
+	return seconds
+}
+
+// CallTimeout returns the context deadline for a call to an agent configured
+// with seconds, including the buffer for connection setup and slow starts
+func (t AgentTimeouts) CallTimeout(seconds int) time.Duration {
+	return time.Duration(t.Clamp(seconds)+t.BufferSeconds) * time.Second
+}
+
+// Agent call retry defaults and limits
+const (
+	DefaultAgentRetries          = 2
+	MaxAgentRetries              = 10
+	DefaultAgentRetryBaseDelayMs = 500
+	DefaultAgentRetryMaxDelayMs  = 8000
+	MaxAgentRetryDelayMs         = 60000
+)
+
+// AgentRetryConfig controls retries of agent calls that fail transiently:
+// network errors and HTTP 429, 500, 502, 503 and 529. Retry n waits between
+// half and all of BaseDelayMs doubled n-1 times, capped at MaxDelayMs, unless
+// the provider's Retry-After says otherwise. Retries never outlast the call
+// timeout; zero MaxRetries turns them off.
+type AgentRetryConfig struct {
+	MaxRetries  int `json:"max_retries"`
+	BaseDelayMs int `json:"base_delay_ms"`
+	MaxDelayMs  int `json:"max_delay_ms"`
+}
+
+// DefaultAgentRetryConfig returns the built-in retry settings
+func DefaultAgentRetryConfig() AgentRetryConfig {
+	return AgentRetryConfig{
+		MaxRetries:  DefaultAgentRetries,
+		BaseDelayMs: DefaultAgentRetryBaseDelayMs,
+		MaxDelayMs:  DefaultAgentRetryMaxDelayMs,
+	}
+}
+
+// Validate checks the retry settings
+func (r *AgentRetryConfig) Validate() error {
+	if r.MaxRetries < 0 || r.MaxRetries > MaxAgentRetries {
+		return fmt.Errorf("max_retries must be between 0 and %d", MaxAgentRetries)
+	}
+	if r.BaseDelayMs < 1 || r.BaseDelayMs > MaxAgentRetryDelayMs {
+		return fmt.Errorf("base_delay_ms must be between 1 and %d", MaxAgentRetryDelayMs)
+	}
+	if r.MaxDelayMs < r.BaseDelayMs || r.MaxDelayMs > MaxAgentRetryDelayMs {
+		return fmt.Errorf("max_delay_ms must be between base_delay_ms and %d", MaxAgentRetryDelayMs)
+	}
+	return nil
+}
+
+// Limits for per_turn_context_chars; zero disables truncation
+const (
+	MinPerTurnContextChars = 50
+	MaxPerTurnContextChars = 100000
+)
+
+// MaxPacingDelaySeconds caps turn_delay_seconds and round_delay_seconds
+const MaxPacingDelaySeconds = 60
+
+// Limits for summary_char_limit
+const (
+	DefaultSummaryCharLimit = 2000
+	MinSummaryCharLimit     = 200
+	MaxSummaryCharLimit     = 20000
+)
+
+// DiscussionSettings holds optional per-discussion configuration stored as JSON
+type DiscussionSettings struct {
+	ModeratorOverrides *ModeratorOverrides `json:"moderator_overrides,omitempty"`
+	// PerTurnContextChars truncates each prior response in the context sent
+	// to agents. Stored logs are never truncated.
+	PerTurnContextChars int `json:"per_turn_context_chars,omitempty"`
+	// Order is "" to keep the selected speaking order or "reliability" to
+	// let the most reliable agent speak first
+	Order string `json:"order,omitempty"`
+	// TurnDelaySeconds pauses between agent turns and RoundDelaySeconds
+	// between rounds so live viewers can keep up with fast agents
+	TurnDelaySeconds  int `json:"turn_delay_seconds,omitempty"`
+	RoundDelaySeconds int `json:"round_delay_seconds,omitempty"`
+	// SummaryBackend picks how the final summary is written: "" or "ai" asks
+	// the moderator, or the first agent, to summarize the transcript and falls
+	// back to "extractive" when that fails, "extractive" quotes the key
+	// sentences of each agent, "basic" lists the opening lines of the transcript
+	SummaryBackend string `json:"summary_backend,omitempty"`
+	// SummaryCharLimit caps the final summary; zero uses the discussion's
+	// MaxCharLimit for AI summaries and DefaultSummaryCharLimit otherwise
+	SummaryCharLimit int `json:"summary_char_limit,omitempty"`
+	// Acceptance rejects agent replies that do not take part in the debate
+	Acceptance *AcceptanceRules `json:"acceptance,omitempty"`
+	// Webhook is called when this discussion ends
+	Webhook *DiscussionWebhook `json:"webhook,omitempty"`
+	// Scratchpad invites agents to end their replies with private notes for
+	// their later turns. The notes are stripped from the reply before anyone
+	// else sees it. RevealScratchpad also keeps them in the log metadata, for
+	// debugging.
+	Scratchpad       bool `json:"scratchpad,omitempty"`
+	RevealScratchpad bool `json:"reveal_scratchpad,omitempty"`
+	// RoundMode is "" or "sequential" for agents to answer one after another,
+	// each seeing the earlier answers of the round, or "parallel" for all
+	// agents of a round to answer the previous rounds at once
+	RoundMode string `json:"round_mode,omitempty"`
+	// EnableConsensusCheck asks after each round whether the positions have
+	// converged and ends the debate early when they have
+	EnableConsensusCheck bool `json:"enable_consensus_check,omitempty"`
+	// PromptProfile is "" to pick the prompts from MaxCharLimit, "standard"
+	// for the full guidelines or "compact" for terse one-paragraph prompts
+	PromptProfile string `json:"prompt_profile,omitempty"`
+	// Trace records a CallTrace of every agent and moderator call of the
+	// discussion in its log entry, whatever the agents' own setting
+	Trace bool `json:"trace,omitempty"`
+	// Analyze runs a consensus analysis of the key points once the debate
+	// completes, with the agent writing the summary
+	Analyze bool `json:"analyze,omitempty"`
+	// ActiveSpeakers limits each round to that many agents, taken in turn
+	// along the speaking order so every agent rotates in; 0 lets all speak
+	ActiveSpeakers int `json:"active_speakers,omitempty"`
+	// Preset names the preset the discussion was created from, if any
+	Preset string `json:"preset,omitempty"`
+	// InjectionGuard fences the replies fed to other agents as quoted
+	// material and strips instructions aimed at them; nil turns it off
+	InjectionGuard *InjectionGuard `json:"injection_guard,omitempty"`
+	// LightningRound ends the debate with one short closing statement per
+	// agent, of at most LightningCharLimit characters (0 uses
+	// DefaultLightningCharLimit), before the moderator's closing remarks
+	LightningRound     bool `json:"lightning_round,omitempty"`
+	LightningCharLimit int  `json:"lightning_char_limit,omitempty"`
+	// TokenBudget caps the prompt and completion tokens, as reported by the
+	// providers, of the discussion's calls; 0 means no budget. The debate
+	// ends with end reason "budget" at the first agent turn it cannot cover.
+	TokenBudget int `json:"token_budget,omitempty"`
+	// AdaptiveMaxTokens shares the rest of TokenBudget among the calls still
+	// to come and lowers each agent turn's max_tokens to its share, never
+	// below MinTurnTokens (0 uses DefaultMinTurnTokens), so the budget lasts
+	// until the final round and the closing
+	AdaptiveMaxTokens bool `json:"adaptive_max_tokens,omitempty"`
+	MinTurnTokens     int  `json:"min_turn_tokens,omitempty"`
+}
+
+// TurnTokenFloor returns the least max_tokens an adaptive turn is given
+func (s DiscussionSettings) TurnTokenFloor() int {
+	if s.MinTurnTokens == 0 {
+		return DefaultMinTurnTokens
+	}
+	return s.MinTurnTokens
+}
+
+// LightningLimit returns the character limit of closing statements
+func (s DiscussionSettings) LightningLimit() int {
+	if s.LightningCharLimit == 0 {
+		return DefaultLightningCharLimit
+	}
+	return s.LightningCharLimit
+}
+
+// RoundSpeakers returns the positions in the speaking order of the agents
+// speaking in round, out of agents in all. With ActiveSpeakers set the window
+// starts where the previous round's ended and wraps around.
+func (s DiscussionSettings) RoundSpeakers(agents, round int) []int {
+	count := s.ActiveSpeakers
+	if count <= 0 || count >= agents {
+		count = agents
+	}
+	start := 0
+	if count < agents {
+		start = (round - 1) * count % agents
+	}
+	speakers := make([]int, 0, count)
+	for i := 0; i < count; i++ {
+		speakers = append(speakers, (start+i)%agents)
+	}
+	return speakers
+}
+
+// Lightning round limits
+const (
+	DefaultLightningCharLimit = 300
+	MinLightningCharLimit     = 50
+	MaxLightningCharLimit     = 2000
+)
+
+// Token budget limits
+const (
+	MinTokenBudget       = 1000
+	MaxTokenBudget       = 10000000
+	DefaultMinTurnTokens = 128
+	MinMinTurnTokens     = 16
+)
+
+// PhaseClosingStatement marks the agent entries of the lightning round in
+// their "phase" metadata
+const PhaseClosingStatement = "closing_statement"
+
+// Speaking orders for DiscussionSettings.Order
+const (
+	DiscussionOrderReliability = "reliability"
+)
+
+// Round modes for DiscussionSettings.RoundMode
+const (
+	RoundModeSequential = "sequential"
+	RoundModeParallel   = "parallel"
+)
+
+// Prompt profiles for DiscussionSettings.PromptProfile
+const (
+	PromptProfileStandard = "standard"
+	PromptProfileCompact  = "compact"
+)
+
+// CompactCharLimit is the largest MaxCharLimit that uses the compact prompt
+// profile when none was chosen; the standard guidelines alone are longer
+// than a reply that short
+const CompactCharLimit = 500
+
+// Summary backends for DiscussionSettings.SummaryBackend
+const (
+	SummaryBackendAI         = "ai"
+	SummaryBackendExtractive = "extractive"
+	SummaryBackendBasic      = "basic"
+)
+
+// Limits for acceptance rules
+const (
+	MaxAcceptanceMinChars   = 5000
+	MaxRefusalPatterns      = 20
+	MaxRefusalPatternLength = 200
+)
+
+// AcceptanceRules decide whether an agent reply counts as a turn. A rejected
+// reply is logged with status "rejected", left out of the debate context,
+// and the agent is asked once more with an encouraging prompt.
+type AcceptanceRules struct {
+	// MinChars is the shortest accepted reply; zero accepts any length
+	MinChars int `json:"min_chars,omitempty"`
+	// RefusalPatterns are case-insensitive regular expressions; a reply
+	// matching any of them is rejected as a non-answer
+	RefusalPatterns []string `json:"refusal_patterns,omitempty"`
+}
+
+// Validate drops empty refusal patterns and checks the rest compile
+func (a *AcceptanceRules) Validate() error {
+	if a.MinChars < 0 || a.MinChars > MaxAcceptanceMinChars {
+		return fmt.Errorf("min_chars must be between 0 and %d", MaxAcceptanceMinChars)
+	}
+
+	patterns := a.RefusalPatterns[:0]
+	for _, p := range a.RefusalPatterns {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	a.RefusalPatterns = patterns
+	if len(a.RefusalPatterns) > MaxRefusalPatterns {
+		return fmt.Errorf("at most %d refusal_patterns are allowed", MaxRefusalPatterns)
+	}
+	for _, p := range a.RefusalPatterns {
+		if len(p) > MaxRefusalPatternLength {
+			return fmt.Errorf("refusal pattern %q is longer than %d characters", p, MaxRefusalPatternLength)
+		}
+		if _, err := regexp.Compile("(?i)" + p); err != nil {
+			return fmt.Errorf("refusal pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// Limits for injection guard patterns
+const (
+	MaxInjectionPatterns      = 20
+	MaxInjectionPatternLength = 200
+)
+
+// DefaultInjectionPatterns catch the usual attempts of a reply to take over
+// the agents that read it
+var DefaultInjectionPatterns = []string{
+	`\b(ignore|disregard|forget|override)\b.{0,40}\b(previous|prior|above|earlier|preceding|system|your)\b.{0,20}\b(instructions?|prompts?)\b`,
+	`\b(reveal|print|repeat|show|output|leak)\b.{0,20}\b(your|the)\b.{0,10}\b(system prompt|instructions|hidden prompt)\b`,
+	`^\W*you are now\b`,
+	`\bnew (instructions|rules|system prompt)\s*:`,
+	`^\W*(system|assistant|developer)\s*(:|\]|>)`,
+	`<\|?(im_start|im_end|system|endoftext)\|?>`,
+	`\b(all )?(other|next) (agents?|debaters?|models?|assistants?)\b.{0,30}\b(must|should|shall)\b.{0,30}\b(say|agree|concede|respond|reply|write|output)\b`,
+}
+
+// InjectionGuard protects agents from instructions smuggled into the replies
+// of other agents. Each prior reply in their context is fenced and labeled
+// as quoted material, and reply lines matching any pattern are replaced by a
+// placeholder; the original lines are kept in the log metadata and the entry
+// is flagged for review.
+type InjectionGuard struct {
+	// Patterns are case-insensitive regular expressions matched against
+	// each line of a reply; empty uses DefaultInjectionPatterns
+	Patterns []string `json:"patterns,omitempty"`
+}
+
+// Validate drops empty patterns and checks the rest compile
+func (g *InjectionGuard) Validate() error {
+	patterns := g.Patterns[:0]
+	for _, p := range g.Patterns {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	g.Patterns = patterns
+	if len(g.Patterns) > MaxInjectionPatterns {
+		return fmt.Errorf("at most %d patterns are allowed", MaxInjectionPatterns)
+	}
+	for _, p := range g.Patterns {
+		if len(p) > MaxInjectionPatternLength {
+			return fmt.Errorf("pattern %q is longer than %d characters", p, MaxInjectionPatternLength)
+		}
+		if _, err := regexp.Compile("(?i)" + p); err != nil {
+			return fmt.Errorf("pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// EffectivePatterns returns the patterns in use
+func (g *InjectionGuard) EffectivePatterns() []string {
+	if len(g.Patterns) == 0 {
+		return DefaultInjectionPatterns
+	}
+	return g.Patterns
+}
+
+// ModeratorOverrides replaces the moderator agent's call parameters for
+// moderation duties only. Unset fields fall back to the agent's own configuration.
+type ModeratorOverrides struct {
+	SystemPrompt string   `json:"system_prompt,omitempty"`
+	Temperature  *float64 `json:"temperature,omitempty"`
+	MaxTokens    *int     `json:"max_tokens,omitempty"`
+}
+
+// Validate trims the settings and checks them against the allowed ranges
+func (s *DiscussionSettings) Validate() error {
+	if s.ModeratorOverrides != nil {
+		if err := s.ModeratorOverrides.Validate(); err != nil {
+			return fmt.Errorf("moderator_overrides: %w", err)
+		}
+	}
+	if s.PerTurnContextChars != 0 && (s.PerTurnContextChars < MinPerTurnContextChars || s.PerTurnContextChars > MaxPerTurnContextChars) {
+		return fmt.Errorf("per_turn_context_chars must be 0 or between %d and %d", MinPerTurnContextChars, MaxPerTurnContextChars)
+	}
+	s.Order = strings.ToLower(strings.TrimSpace(s.Order))
+	if s.Order != "" && s.Order != DiscussionOrderReliability {
+		return fmt.Errorf("order must be empty or %q", DiscussionOrderReliability)
+	}
+	if s.TurnDelaySeconds < 0 || s.TurnDelaySeconds > MaxPacingDelaySeconds {
+		return fmt.Errorf("turn_delay_seconds must be between 0 and %d", MaxPacingDelaySeconds)
+	}
+	if s.RoundDelaySeconds < 0 || s.RoundDelaySeconds > MaxPacingDelaySeconds {
+		return fmt.Errorf("round_delay_seconds must be between 0 and %d", MaxPacingDelaySeconds)
+	}
+	s.SummaryBackend = strings.ToLower(strings.TrimSpace(s.SummaryBackend))
+	switch s.SummaryBackend {
+	case "", SummaryBackendAI, SummaryBackendExtractive, SummaryBackendBasic:
+	default:
+		return fmt.Errorf("summary_backend must be empty, %q, %q or %q", SummaryBackendAI, SummaryBackendExtractive, SummaryBackendBasic)
+	}
+	if s.SummaryCharLimit != 0 && (s.SummaryCharLimit < MinSummaryCharLimit || s.SummaryCharLimit > MaxSummaryCharLimit) {
+		return fmt.Errorf("summary_char_limit must be 0 or between %d and %d", MinSummaryCharLimit, MaxSummaryCharLimit)
+	}
+	if s.Acceptance != nil {
+		if err := s.Acceptance.Validate(); err != nil {
+			return fmt.Errorf("acceptance: %w", err)
+		}
+	}
+	if s.Webhook != nil {
+		if err := s.Webhook.Validate(); err != nil {
+			return fmt.Errorf("webhook: %w", err)
+		}
+	}
+	if s.InjectionGuard != nil {
+		if err := s.InjectionGuard.Validate(); err != nil {
+			return fmt.Errorf("injection_guard: %w", err)
+		}
+	}
+	s.RoundMode = strings.ToLower(strings.TrimSpace(s.RoundMode))
+	switch s.RoundMode {
+	case "", RoundModeSequential, RoundModeParallel:
+	default:
+		return fmt.Errorf("round_mode must be empty, %q or %q", RoundModeSequential, RoundModeParallel)
+	}
+	s.PromptProfile = strings.ToLower(strings.TrimSpace(s.PromptProfile))
+	switch s.PromptProfile {
+	case "", PromptProfileStandard, PromptProfileCompact:
+	default:
+		return fmt.Errorf("prompt_profile must be empty, %q or %q", PromptProfileStandard, PromptProfileCompact)
+	}
+	if s.ActiveSpeakers < 0 {
+		return errors.New("active_speakers must not be negative")
+	}
+	if s.LightningCharLimit != 0 && (s.LightningCharLimit < MinLightningCharLimit || s.LightningCharLimit > MaxLightningCharLimit) {
+		return fmt.Errorf("lightning_char_limit must be 0 or between %d and %d", MinLightningCharLimit, MaxLightningCharLimit)
+	}
+	if s.TokenBudget != 0 && (s.TokenBudget < MinTokenBudget || s.TokenBudget > MaxTokenBudget) {
+		return fmt.Errorf("token_budget must be 0 or between %d and %d", MinTokenBudget, MaxTokenBudget)
+	}
+	if s.AdaptiveMaxTokens && s.TokenBudget == 0 {
+		return errors.New("adaptive_max_tokens requires a token_budget")
+	}
+	if s.MinTurnTokens != 0 && (s.MinTurnTokens < MinMinTurnTokens || s.MinTurnTokens > MaxMaxTokens) {
+		return fmt.Errorf("min_turn_tokens must be 0 or between %d and %d", MinMinTurnTokens, MaxMaxTokens)
+	}
+	if _, ok := LookupPreset(s.Preset); s.Preset != "" && !ok {
+		return fmt.Errorf("unknown preset %q", s.Preset)
+	}
+	return nil
+}
+
+// Validate trims the overrides and checks them against the agent parameter ranges
+func (o *ModeratorOverrides) Validate() error {
+	o.SystemPrompt = strings.TrimSpace(o.SystemPrompt)
+	if len(o.SystemPrompt) > MaxSystemPromptLength {
+		return fmt.Errorf("system_prompt must be at most %d characters", MaxSystemPromptLength)
+	}
+	if o.Temperature != nil && (*o.Temperature < MinTemperature || *o.Temperature > MaxTemperature) {
+		return fmt.Errorf("temperature must be between %.1f and %.1f", MinTemperature, MaxTemperature)
+	}
+	if o.MaxTokens != nil && (*o.MaxTokens < 1 || *o.MaxTokens > MaxMaxTokens) {
+		return fmt.Errorf("max_tokens must be between 1 and %d", MaxMaxTokens)
+	}
+	return nil
+}
+
+func (s DiscussionSettings) Value() (driver.Value, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (s *DiscussionSettings) Scan(value interface{}) error {
+	*s = DiscussionSettings{}
+	data, err := scanJSONBytes(value)
+	if err != nil || len(data) == 0 {
+		return err
+	}
+	return json.Unmarshal(data, s)
+}
+
+// JSONMap is a string map stored as a JSON object in the database
+type JSONMap map[string]string
+
+func (m JSONMap) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (m *JSONMap) Scan(value interface{}) error {
+	*m = nil
+	data, err := scanJSONBytes(value)
+	if err != nil || len(data) == 0 {
+		return err
+	}
+	return json.Unmarshal(data, m)
+}
+
+// scanJSONBytes converts a database value holding JSON text into bytes
+func scanJSONBytes(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	default:
+		return nil, fmt.Errorf("unexpected type for JSON column: %T", value)
+	}
+}
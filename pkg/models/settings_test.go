@@ -0,0 +1,143 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestModeratorOverridesValidate(t *testing.T) {
+	temperature := func(v float64) *float64 { return &v }
+	tokens := func(v int) *int { return &v }
+	tests := []struct {
+		name      string
+		overrides ModeratorOverrides
+		wantErr   bool
+	}{
+		{"empty", ModeratorOverrides{}, false},
+		{"in range", ModeratorOverrides{SystemPrompt: "Be neutral.", Temperature: temperature(0.1), MaxTokens: tokens(200)}, false},
+		{"lowest temperature", ModeratorOverrides{Temperature: temperature(MinTemperature)}, false},
+		{"highest temperature", ModeratorOverrides{Temperature: temperature(MaxTemperature)}, false},
+		{"temperature too high", ModeratorOverrides{Temperature: temperature(MaxTemperature + 0.1)}, true},
+		{"negative temperature", ModeratorOverrides{Temperature: temperature(-0.1)}, true},
+		{"zero max tokens", ModeratorOverrides{MaxTokens: tokens(0)}, true},
+		{"too many max tokens", ModeratorOverrides{MaxTokens: tokens(MaxMaxTokens + 1)}, true},
+		{"system prompt too long", ModeratorOverrides{SystemPrompt: string(make([]byte, MaxSystemPromptLength+1))}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := DiscussionSettings{ModeratorOverrides: &tt.overrides}
+			if err := settings.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestModeratorOverridesValidateTrims(t *testing.T) {
+	o := ModeratorOverrides{SystemPrompt: "  Be neutral.\n"}
+	if err := o.Validate(); err != nil || o.SystemPrompt != "Be neutral." {
+		t.Errorf("Validate() = %v, system prompt %q", err, o.SystemPrompt)
+	}
+}
+
+func TestAgentTimeouts(t *testing.T) {
+	timeouts := AgentTimeouts{DefaultSeconds: 30, MaxSeconds: 120, BufferSeconds: 10}
+	tests := []struct {
+		seconds int
+		clamped int
+		call    time.Duration
+	}{
+		{0, 30, 40 * time.Second},
+		{-5, 30, 40 * time.Second},
+		{1, 1, 11 * time.Second},
+		{90, 90, 100 * time.Second},
+		{120, 120, 130 * time.Second},
+		{600, 120, 130 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := timeouts.Clamp(tt.seconds); got != tt.clamped {
+			t.Errorf("Clamp(%d) = %d, want %d", tt.seconds, got, tt.clamped)
+		}
+		if got := timeouts.CallTimeout(tt.seconds); got != tt.call {
+			t.Errorf("CallTimeout(%d) = %v, want %v", tt.seconds, got, tt.call)
+		}
+	}
+
+	noBuffer := AgentTimeouts{DefaultSeconds: 30, MaxSeconds: 120}
+	if got := noBuffer.CallTimeout(45); got != 45*time.Second {
+		t.Errorf("CallTimeout without a buffer = %v, want 45s", got)
+	}
+}
+
+func TestAgentTimeoutsValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeouts AgentTimeouts
+		wantErr  bool
+	}{
+		{"defaults", DefaultAgentTimeouts(), false},
+		{"default equals max", AgentTimeouts{DefaultSeconds: 60, MaxSeconds: 60}, false},
+		{"default above max", AgentTimeouts{DefaultSeconds: 90, MaxSeconds: 60}, true},
+		{"zero default", AgentTimeouts{DefaultSeconds: 0, MaxSeconds: 60}, true},
+		{"max above ceiling", AgentTimeouts{DefaultSeconds: 30, MaxSeconds: MaxAgentTimeoutCeilingSeconds + 1}, true},
+		{"negative buffer", AgentTimeouts{DefaultSeconds: 30, MaxSeconds: 60, BufferSeconds: -1}, true},
+		{"buffer too long", AgentTimeouts{DefaultSeconds: 30, MaxSeconds: 60, BufferSeconds: 301}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.timeouts.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAgentRetryConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     AgentRetryConfig
+		wantErr bool
+	}{
+		{"defaults", DefaultAgentRetryConfig(), false},
+		{"retries off", AgentRetryConfig{MaxRetries: 0, BaseDelayMs: 1, MaxDelayMs: 1}, false},
+		{"most retries", AgentRetryConfig{MaxRetries: MaxAgentRetries, BaseDelayMs: 100, MaxDelayMs: MaxAgentRetryDelayMs}, false},
+		{"negative retries", AgentRetryConfig{MaxRetries: -1, BaseDelayMs: 100, MaxDelayMs: 1000}, true},
+		{"too many retries", AgentRetryConfig{MaxRetries: MaxAgentRetries + 1, BaseDelayMs: 100, MaxDelayMs: 1000}, true},
+		{"zero base delay", AgentRetryConfig{MaxRetries: 2, BaseDelayMs: 0, MaxDelayMs: 1000}, true},
+		{"max below base", AgentRetryConfig{MaxRetries: 2, BaseDelayMs: 500, MaxDelayMs: 100}, true},
+		{"max above ceiling", AgentRetryConfig{MaxRetries: 2, BaseDelayMs: 500, MaxDelayMs: MaxAgentRetryDelayMs + 1}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestInjectionGuardValidate(t *testing.T) {
+	var tooMany []string
+	for i := 0; i <= MaxInjectionPatterns; i++ {
+		tooMany = append(tooMany, "tabs")
+	}
+	tests := []struct {
+		name         string
+		patterns     []string
+		wantErr      bool
+		wantPatterns int
+	}{
+		{"defaults", nil, false, len(DefaultInjectionPatterns)},
+		{"blank patterns dropped", []string{"  ", "tabs win", ""}, false, 1},
+		{"invalid pattern", []string{"(tabs"}, true, 0},
+		{"too many", tooMany, true, 0},
+		{"too long", []string{strings.Repeat("a", MaxInjectionPatternLength+1)}, true, 0},
+	}
+	for _, tt := range tests {
+		settings := DiscussionSettings{InjectionGuard: &InjectionGuard{Patterns: tt.patterns}}
+		err := settings.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if !tt.wantErr && len(settings.InjectionGuard.EffectivePatterns()) != tt.wantPatterns {
+			t.Errorf("%s: patterns in use = %v", tt.name, settings.InjectionGuard.EffectivePatterns())
+		}
+	}
+}
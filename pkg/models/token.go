@@ -0,0 +1,66 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// TokenFingerprint identifies an API token in statistics without revealing
+// it: a short hash of the whole token plus its last four characters. Agents
+// without a token have no fingerprint.
+func TokenFingerprint(token string) string {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(token))
+	last := []rune(token)
+	if len(last) > 4 {
+		last = last[len(last)-4:]
+	}
+	return hex.EncodeToString(sum[:])[:12] + "…" + string(last)
+}
+
+// TokenCall is one agent call attributed to an API token fingerprint
+type TokenCall struct {
+	AgentID     int64
+	Fingerprint string
+	Status      string // success, timeout, error
+	ErrorClass  string // set on failed calls, e.g. rate_limited
+	TotalTokens int    // zero when the provider did not report usage
+	CreatedAt   time.Time
+}
+
+// TokenUsageDay aggregates the calls made with one API token on one UTC day
+// across every agent sharing it
+type TokenUsageDay struct {
+	Fingerprint string  `json:"fingerprint"`
+	Date        string  `json:"date"` // YYYY-MM-DD, UTC
+	AgentIDs    []int64 `json:"agent_ids"`
+	Calls       int     `json:"calls"`
+	Errors      int     `json:"errors"`
+	RateLimited int     `json:"rate_limited"` // calls rejected with 429
+	TotalTokens int     `json:"total_tokens"`
+}
+
+// DiscussionTokenUsage totals the tokens reported for a discussion's agent
+// and moderator calls, overall and per agent
+type DiscussionTokenUsage struct {
+	PromptTokens     int               `json:"prompt_tokens"`
+	CompletionTokens int               `json:"completion_tokens"`
+	TotalTokens      int               `json:"total_tokens"`
+	Agents           []AgentTokenUsage `json:"agents"` // most tokens first
+}
+
+// AgentTokenUsage totals the tokens reported for one agent's calls in a
+// discussion. Calls counts every call, including those without usage.
+type AgentTokenUsage struct {
+	AgentID          int64 `json:"agent_id"`
+	Calls            int   `json:"calls"`
+	PromptTokens     int   `json:"prompt_tokens"`
+	CompletionTokens int   `json:"completion_tokens"`
+	TotalTokens      int   `json:"total_tokens"`
+}
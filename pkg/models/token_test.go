@@ -0,0 +1,32 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenFingerprint(t *testing.T) {
+	token := "sk-proj-abcdefghijklmnop1234"
+	fp := TokenFingerprint(token)
+	if !strings.HasSuffix(fp, "…1234") || len([]rune(fp)) != 12+1+4 {
+		t.Errorf("TokenFingerprint = %q, want a 12-character hash, an ellipsis and the last four characters", fp)
+	}
+	if strings.Contains(fp, "abcdefgh") {
+		t.Errorf("TokenFingerprint = %q reveals the token", fp)
+	}
+	if again := TokenFingerprint(" " + token + "\n"); again != fp {
+		t.Errorf("fingerprint with surrounding whitespace = %q, want %q", again, fp)
+	}
+	// Tokens ending alike are still told apart by the hash
+	if other := TokenFingerprint("sk-other-1234"); other == fp || !strings.HasSuffix(other, "…1234") {
+		t.Errorf("fingerprint of a different token = %q, want the same suffix but a different hash than %q", other, fp)
+	}
+	if short := TokenFingerprint("abc"); !strings.HasSuffix(short, "…abc") {
+		t.Errorf("fingerprint of a short token = %q, want it to end with the whole token", short)
+	}
+	for _, empty := range []string{"", "   "} {
+		if got := TokenFingerprint(empty); got != "" {
+			t.Errorf("TokenFingerprint(%q) = %q, want no fingerprint", empty, got)
+		}
+	}
+}
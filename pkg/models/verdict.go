@@ -0,0 +1,50 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
+// Bounds of the score a judge gives each agent
+const (
+	MinVerdictScore = 1
+	MaxVerdictScore = 10
+)
+
+// Verdict is a judge's ruling on a finished debate. When the judge's reply
+// held no usable verdict, Reasoning is the raw reply and Scores is nil.
+type Verdict struct {
+	JudgeID       int64         `json:"judge_id"`
+	WinnerAgentID *int64        `json:"winner_agent_id"`
+	Scores        VerdictScores `json:"scores"`
+	Reasoning     string        `json:"reasoning"`
+}
+
+// IsWinner reports whether the judge named agentID the winner
+func (v *Verdict) IsWinner(agentID int64) bool {
+	return v.WinnerAgentID != nil && *v.WinnerAgentID == agentID
+}
+
+// VerdictScores maps agent IDs to their score. It is stored as JSON, and a
+// nil map as NULL.
+type VerdictScores map[int64]int
+
+func (s VerdictScores) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (s *VerdictScores) Scan(value interface{}) error {
+	*s = nil
+	data, err := scanJSONBytes(value)
+	if err != nil || len(data) == 0 {
+		return err
+	}
+	return json.Unmarshal(data, s)
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Webhook limits
+const (
+	MaxWebhookURLLength    = 2048
+	MaxWebhookSecretLength = 256
+)
+
+// Webhook events. WebhookEventTest is only sent by the test-fire endpoints,
+// the alert events only to the alert webhook.
+const (
+	WebhookEventCompleted     = "discussion_completed"
+	WebhookEventFailed        = "discussion_failed"
+	WebhookEventStopped       = "discussion_stopped"
+	WebhookEventTest          = "webhook_test"
+	WebhookEventAlertFiring   = "agent_alert_firing"
+	WebhookEventAlertResolved = "agent_alert_resolved"
+)
+
+// WebhookEvents lists the events a webhook can subscribe to
+var WebhookEvents = []string{WebhookEventCompleted, WebhookEventFailed, WebhookEventStopped}
+
+// DiscussionWebhook is a callback for a single discussion. Events lists the
+// events to deliver; empty means all of them. When Secret is set, each
+// payload is signed with HMAC-SHA256.
+type DiscussionWebhook struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret,omitempty"`
+	Events []string `json:"events,omitempty"`
+}
+
+// Validate trims the webhook and checks its URL and events
+func (w *DiscussionWebhook) Validate() error {
+	w.URL = strings.TrimSpace(w.URL)
+	if w.URL == "" {
+		return errors.New("url is required")
+	}
+	if len(w.URL) > MaxWebhookURLLength {
+		return fmt.Errorf("url must be at most %d characters", MaxWebhookURLLength)
+	}
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if len(w.Secret) > MaxWebhookSecretLength {
+		return fmt.Errorf("secret must be at most %d characters", MaxWebhookSecretLength)
+	}
+
+	events := make([]string, 0, len(w.Events))
+	seen := make(map[string]bool)
+	for _, event := range w.Events {
+		event = strings.ToLower(strings.TrimSpace(event))
+		if event == "" || seen[event] {
+			continue
+		}
+		if !isWebhookEvent(event) {
+			return fmt.Errorf("unknown event %q, expected one of %s", event, strings.Join(WebhookEvents, ", "))
+		}
+		seen[event] = true
+		events = append(events, event)
+	}
+	w.Events = events
+	return nil
+}
+
+// Wants reports whether the webhook subscribed to event
+func (w *DiscussionWebhook) Wants(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func isWebhookEvent(event string) bool {
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookPayload is the JSON body posted to a webhook
+type WebhookPayload struct {
+	Event           string    `json:"event"`
+	DiscussionID    int64     `json:"discussion_id,omitempty"`
+	Topic           string    `json:"topic,omitempty"`
+	Status          string    `json:"status,omitempty"`
+	EndReason       string    `json:"end_reason,omitempty"`
+	CompletedRounds int       `json:"completed_rounds"`
+	FinalSummary    string    `json:"final_summary,omitempty"`
+	ErrorMessage    string    `json:"error_message,omitempty"`
+	Alert           *Alert    `json:"alert,omitempty"`
+	Text            string    `json:"text,omitempty"` // alert summary, shown by Slack-style incoming webhooks
+	SentAt          time.Time `json:"sent_at"`
+}
+
+// WebhookDelivery records one attempt to deliver a webhook. StatusCode is
+// zero when no response was received.
+type WebhookDelivery struct {
+	ID           int64     `json:"id" db:"id"`
+	DiscussionID int64     `json:"discussion_id,omitempty" db:"discussion_id"` // 0 for test fires without a discussion
+	URL          string    `json:"url" db:"url"`
+	Event        string    `json:"event" db:"event"`
+	Delivered    bool      `json:"delivered" db:"delivered"` // a 2xx response was received
+	StatusCode   int       `json:"status_code" db:"status_code"`
+	LatencyMs    int       `json:"latency_ms" db:"latency_ms"`
+	Error        string    `json:"error,omitempty" db:"error"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
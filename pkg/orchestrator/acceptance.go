@@ -0,0 +1,46 @@
+package orchestrator
+
+import (
+	"court-table-ai/pkg/models"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// rejectionReason returns why content fails the acceptance rules, or "" when
+// it is accepted or there are no rules
+func rejectionReason(rules *models.AcceptanceRules, content string) string {
+	if rules == nil {
+		return ""
+	}
+
+	content = strings.TrimSpace(content)
+	if n := utf8.RuneCountInString(content); n < rules.MinChars {
+		return fmt.Sprintf("too short (%d of at least %d characters)", n, rules.MinChars)
+	}
+	for _, p := range rules.RefusalPatterns {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			continue
+		}
+		if re.MatchString(content) {
+			return fmt.Sprintf("non-answer (matched %q)", p)
+		}
+	}
+	return ""
+}
+
+// acceptanceRetryPrompt asks an agent whose reply was rejected to try again
+// and take part in the debate
+func acceptanceRetryPrompt(prompt, reason string, rules *models.AcceptanceRules) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Your previous reply could not be used in the debate: %s.\n", reason))
+	b.WriteString("This is a constructive exchange of views on the topic. Please take part: state your own position and the reasons for it")
+	if rules.MinChars > 0 {
+		b.WriteString(fmt.Sprintf(" in at least %d characters", rules.MinChars))
+	}
+	b.WriteString(".\n\n")
+	b.WriteString(prompt)
+	return b.String()
+}
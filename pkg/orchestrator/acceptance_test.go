@@ -0,0 +1,193 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+// newSequenceProvider serves OpenAI chat completions answering replies in
+// turn, repeating the last one, and returns the decoded request bodies it got
+func newSequenceProvider(t *testing.T, replies ...string) (*httptest.Server, func() []map[string]interface{}) {
+	t.Helper()
+	var (
+		mu     sync.Mutex
+		bodies []map[string]interface{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("provider got a body that is not JSON: %v", err)
+		}
+		mu.Lock()
+		reply := replies[min(len(bodies), len(replies)-1)]
+		bodies = append(bodies, body)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":%q},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":3}}`, reply)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []map[string]interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]map[string]interface{}(nil), bodies...)
+	}
+}
+
+// allMessages returns the content of every message of a chat completions body
+func allMessages(body map[string]interface{}) string {
+	var all strings.Builder
+	messages, _ := body["messages"].([]interface{})
+	for _, m := range messages {
+		msg, _ := m.(map[string]interface{})
+		content, _ := msg["content"].(string)
+		all.WriteString(content + "\n")
+	}
+	return all.String()
+}
+
+var testAcceptance = &models.AcceptanceRules{MinChars: 20, RefusalPatterns: []string{`^i can(no|')t`, `as an ai`}}
+
+func TestRejectionReason(t *testing.T) {
+	tests := []struct {
+		rules   *models.AcceptanceRules
+		content string
+		want    string
+	}{
+		{nil, "No.", ""},
+		{testAcceptance, "Spaces keep diffs aligned in every editor.", ""},
+		{testAcceptance, "  Spaces, always.  ", "too short (15 of at least 20 characters)"},
+		{testAcceptance, "I cannot participate in debates, sorry.", `non-answer (matched "^i can(no|')t")`},
+		{testAcceptance, "I can't take sides on this question at all.", `non-answer (matched "^i can(no|')t")`},
+		{testAcceptance, "Speaking AS AN AI, I have no opinion here.", `non-answer (matched "as an ai")`},
+		// Anchored patterns only match at the start of the reply
+		{testAcceptance, "Tabs? I cannot agree, spaces are clearer.", ""},
+		{&models.AcceptanceRules{MinChars: 5}, "我们应该认真", ""},
+		{&models.AcceptanceRules{RefusalPatterns: []string{"("}}, "Invalid patterns are skipped.", ""},
+	}
+	for _, tt := range tests {
+		if got := rejectionReason(tt.rules, tt.content); got != tt.want {
+			t.Errorf("rejectionReason(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+// runAcceptanceDebate runs a debate of Alice then Bob under testAcceptance and
+// returns their logs once it ended
+func runAcceptanceDebate(t *testing.T, de *DebateEngine, alice, bob *models.Agent, rounds int) (*models.Discussion, []*models.DiscussionLog) {
+	t.Helper()
+	settings := models.DiscussionSettings{SummaryBackend: models.SummaryBackendExtractive, Acceptance: testAcceptance}
+	discussion, err := de.RunDebate(context.Background(), "Tabs or spaces", []int64{alice.ID, bob.ID}, nil, nil, nil, rounds, "en", models.DefaultMaxCharLimit, settings)
+	if err != nil {
+		t.Fatalf("RunDebate: %v", err)
+	}
+	var ended *models.Discussion
+	waitUntil(t, "the debate to end", func() bool {
+		ended, err = de.db.GetDiscussion(discussion.ID)
+		return err == nil && !ended.InProgress()
+	})
+	logs, err := de.db.GetDiscussionLogs(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	var turns []*models.DiscussionLog
+	for _, l := range logs {
+		if l.AgentID == alice.ID || l.AgentID == bob.ID {
+			turns = append(turns, l)
+		}
+	}
+	return ended, turns
+}
+
+func TestRejectedReplyIsRetriedAndLeftOutOfContext(t *testing.T) {
+	de := newTestEngine(t)
+	refusal := "I cannot participate in debates."
+	answer := "Spaces are better because diffs stay aligned across editors."
+	aliceServer, aliceBodies := newSequenceProvider(t, refusal, answer)
+	bobServer, bobBodies := newSequenceProvider(t, "Tabs keep files smaller and let readers pick a width.")
+	alice := insertTestAgent(t, de, "Alice", aliceServer.URL)
+	bob := insertTestAgent(t, de, "Bob", bobServer.URL)
+
+	d, turns := runAcceptanceDebate(t, de, alice, bob, 2)
+	if d.Status != "completed" {
+		t.Errorf("discussion ended %s (%s), want completed", d.Status, d.ErrorMessage)
+	}
+	if len(turns) != 5 {
+		t.Fatalf("got %d agent entries, want a rejected and an accepted one of Alice and one of Bob in round 1, one each in round 2", len(turns))
+	}
+	rejected, retry := turns[0], turns[1]
+	if rejected.AgentID != alice.ID || rejected.Status != "rejected" || rejected.Content != refusal {
+		t.Errorf("first entry = agent %d %s %q, want Alice's rejected refusal", rejected.AgentID, rejected.Status, rejected.Content)
+	}
+	if reason := rejected.Metadata["rejection_reason"]; !strings.HasPrefix(reason, "non-answer") {
+		t.Errorf("rejection_reason = %q", reason)
+	}
+	if retry.AgentID != alice.ID || retry.Status != "success" || retry.Content != answer {
+		t.Errorf("second entry = agent %d %s %q, want Alice's accepted answer", retry.AgentID, retry.Status, retry.Content)
+	}
+	if retry.Metadata["acceptance_retry"] != "true" || retry.Metadata["retry_of"] != fmt.Sprint(rejected.ID) || rejected.Metadata["retried_by"] != fmt.Sprint(retry.ID) {
+		t.Errorf("retry is not linked to the rejected entry: retry %v, rejected %v", retry.Metadata, rejected.Metadata)
+	}
+
+	if bodies := aliceBodies(); len(bodies) != 3 || !strings.Contains(userMessage(bodies[1]), "could not be used in the debate") {
+		t.Errorf("Alice was not re-prompted after the rejection: %d calls", len(bodies))
+	}
+	for _, body := range bobBodies()[1:] {
+		if bobPrompt := allMessages(body); strings.Contains(bobPrompt, refusal) || !strings.Contains(bobPrompt, answer) {
+			t.Errorf("Bob's context should have Alice's answer and not her refusal:\n%s", bobPrompt)
+		}
+	}
+
+	logs, _ := de.db.GetDiscussionLogs(d.ID)
+	for _, c := range ComplianceReport(logs) {
+		if c.AgentID == alice.ID && (c.Rejections != 1 || c.Turns != 2 || len(c.RejectionReasons) != 1) {
+			t.Errorf("Alice's compliance = %+v, want one rejection and two turns", c)
+		}
+		if c.AgentID == bob.ID && c.Rejections != 0 {
+			t.Errorf("Bob's compliance = %+v, want no rejections", c)
+		}
+	}
+}
+
+func TestRepeatedRejectionMovesOn(t *testing.T) {
+	de := newTestEngine(t)
+	aliceServer, aliceBodies := newSequenceProvider(t, "Spaces.")
+	bobServer, bobBodies := newSequenceProvider(t, "Tabs keep files smaller and let readers pick a width.")
+	alice := insertTestAgent(t, de, "Alice", aliceServer.URL)
+	bob := insertTestAgent(t, de, "Bob", bobServer.URL)
+
+	d, turns := runAcceptanceDebate(t, de, alice, bob, 2)
+	if d.Status != "completed" || d.CompletedRounds != 2 {
+		t.Errorf("discussion ended %s after %d rounds, want completed after 2", d.Status, d.CompletedRounds)
+	}
+
+	// Each round Alice is asked twice and both replies are rejected
+	if n := len(aliceBodies()); n != 4 {
+		t.Errorf("Alice was called %d times, want 4", n)
+	}
+	aliceRejected := 0
+	for _, l := range turns {
+		if l.AgentID != alice.ID {
+			continue
+		}
+		if l.Status != "rejected" || !strings.HasPrefix(l.Metadata["rejection_reason"], "too short") {
+			t.Errorf("Alice's entry %d = %s %q", l.ID, l.Status, l.Metadata["rejection_reason"])
+		}
+		aliceRejected++
+	}
+	if aliceRejected != 4 {
+		t.Errorf("Alice has %d rejected entries, want 4", aliceRejected)
+	}
+	for _, body := range bobBodies() {
+		if prompt := allMessages(body); strings.Contains(prompt, "Spaces.") {
+			t.Errorf("Bob's context has Alice's rejected reply:\n%s", prompt)
+		}
+	}
+}
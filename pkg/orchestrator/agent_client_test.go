@@ -0,0 +1,729 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+)
+
+// roundTripFunc serves requests of an http.Client from a function
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// recordingClient returns an AgentClient without a database whose requests
+// are answered with status and body by a fake transport, and the requests it
+// sent
+func recordingClient(status int, body string) (*AgentClient, func() []*http.Request) {
+	return routingClient(func(*http.Request) (int, string) { return status, body })
+}
+
+// routingClient is recordingClient with the answer picked per request by
+// serve. Request bodies stay readable through GetBody.
+func routingClient(serve func(r *http.Request) (int, string)) (*AgentClient, func() []*http.Request) {
+	var (
+		mu   sync.Mutex
+		sent []*http.Request
+	)
+	ac := NewAgentClient(nil)
+	ac.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Body != nil {
+			data, _ := io.ReadAll(r.Body)
+			r.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil }
+		}
+		mu.Lock()
+		sent = append(sent, r)
+		mu.Unlock()
+		status, body := serve(r)
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    r,
+		}, nil
+	})}
+	return ac, func() []*http.Request {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*http.Request(nil), sent...)
+	}
+}
+
+// requestJSON decodes the body a fake transport recorded for r
+func requestJSON(t *testing.T, r *http.Request) map[string]interface{} {
+	t.Helper()
+	body, err := r.GetBody()
+	if err != nil {
+		t.Fatalf("GetBody: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.NewDecoder(body).Decode(&decoded); err != nil {
+		t.Fatalf("request body to %s is not JSON: %v", r.URL, err)
+	}
+	return decoded
+}
+
+func TestGeminiEndpointEscapesModel(t *testing.T) {
+	ac, sent := recordingClient(http.StatusOK, `{"candidates":[{"content":{"parts":[{"text":"Hello."}]},"finishReason":"STOP"}]}`)
+	// An agent stored before model names were validated
+	agent := &models.Agent{
+		Name:           "Gemini",
+		ProviderType:   models.ProviderGoogle,
+		ProviderURL:    "https://generativelanguage.googleapis.com/v1beta",
+		APIToken:       "key",
+		ModelName:      "../../admin?x=1#frag",
+		TimeoutSeconds: 10,
+	}
+
+	if _, err := ac.CallAgent(context.Background(), agent, "Say hello", ""); err != nil {
+		t.Fatalf("CallAgent: %v", err)
+	}
+	requests := sent()
+	if len(requests) != 1 {
+		t.Fatalf("sent %d requests, want 1", len(requests))
+	}
+	u := requests[0].URL
+	if u.Host != "generativelanguage.googleapis.com" {
+		t.Errorf("request went to host %q", u.Host)
+	}
+	if want := "/v1beta/models/..%2F..%2Fadmin%3Fx=1%23frag:generateContent"; u.EscapedPath() != want {
+		t.Errorf("path = %q, want %q", u.EscapedPath(), want)
+	}
+	if u.RawQuery != "" && strings.Contains(u.RawQuery, "x=1") || u.Fragment != "" {
+		t.Errorf("model name leaked into the query %q or fragment %q", u.RawQuery, u.Fragment)
+	}
+}
+
+func TestIsProviderPaused(t *testing.T) {
+	agent := &models.Agent{ProviderType: models.ProviderOpenAI, ProviderURL: "https://api.example.com:8443/v1"}
+	tests := []struct {
+		name   string
+		pauses models.ProviderPauses
+		want   bool
+	}{
+		{"nothing paused", models.ProviderPauses{}, false},
+		{"all", models.ProviderPauses{PauseAll: true}, true},
+		{"provider type", models.ProviderPauses{ProviderTypes: []string{"OpenAI"}}, true},
+		{"other provider type", models.ProviderPauses{ProviderTypes: []string{"anthropic"}}, false},
+		{"hostname", models.ProviderPauses{Hosts: []string{"API.example.com"}}, true},
+		{"host with port", models.ProviderPauses{Hosts: []string{"api.example.com:8443"}}, true},
+		{"other host", models.ProviderPauses{Hosts: []string{"example.com"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsProviderPaused(tt.pauses, agent); got != tt.want {
+				t.Errorf("IsProviderPaused(%+v) = %v, want %v", tt.pauses, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPausedProviderTurnsAreSkipped(t *testing.T) {
+	de := newTestEngine(t)
+	server, calls := newTestProvider(t, "Spaces, always.")
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+	bob := insertTestAgent(t, de, "Bob", server.URL)
+	discussion := insertTestDiscussion(t, de, "running", alice, bob)
+
+	if err := de.db.SetSettingJSON(database.SettingProviderPauses, models.ProviderPauses{ProviderTypes: []string{"openai"}}); err != nil {
+		t.Fatalf("SetSettingJSON: %v", err)
+	}
+	out := de.takeTurn(context.Background(), discussion, alice, 1, "", "Your turn", "", 0, 0, runNow)
+	if out.answered || !out.skipped || out.failure != "Alice (paused)" {
+		t.Errorf("turn while paused = %+v, want a skip labelled paused", out)
+	}
+	if calls.Load() != 0 {
+		t.Errorf("provider called %d times while paused", calls.Load())
+	}
+	started, err := de.RunDebate(context.Background(), "Tabs or spaces", []int64{alice.ID, bob.ID}, nil, nil, nil, 1, "en", models.DefaultMaxCharLimit, models.DiscussionSettings{SummaryBackend: models.SummaryBackendExtractive})
+	if err != nil {
+		t.Fatalf("RunDebate with one provider type paused = %v, want it started", err)
+	}
+	endedBeforeCleanup(t, de, started.ID)
+
+	if err := de.db.SetSettingJSON(database.SettingProviderPauses, models.ProviderPauses{PauseAll: true}); err != nil {
+		t.Fatalf("SetSettingJSON: %v", err)
+	}
+	if _, err := de.RunDebate(context.Background(), "Tabs or spaces", []int64{alice.ID, bob.ID}, nil, nil, nil, 1, "en", models.DefaultMaxCharLimit, models.DiscussionSettings{}); !errors.Is(err, ErrAllProvidersPaused) {
+		t.Errorf("RunDebate while everything is paused = %v, want ErrAllProvidersPaused", err)
+	}
+
+	if err := de.db.SetSettingJSON(database.SettingProviderPauses, models.ProviderPauses{}); err != nil {
+		t.Fatalf("SetSettingJSON: %v", err)
+	}
+	out = de.takeTurn(context.Background(), discussion, alice, 2, "", "Your turn", "", 0, 0, runNow)
+	if !out.answered {
+		t.Errorf("turn after unpausing = %+v, want an answer", out)
+	}
+
+	logs, err := de.db.GetDiscussionLogs(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	if len(logs) != 2 || logs[0].LogType != models.LogTypeSkip || logs[0].Metadata["skip_reason"] != ErrProviderPaused.Error() {
+		t.Errorf("transcript = %+v, want the skip note then the answer", logs)
+	}
+}
+
+func TestGetChatEndpoints(t *testing.T) {
+	tests := []struct {
+		name  string
+		url   string
+		style string
+		want  []string
+	}{
+		{"bare host", "https://api.openai.com", "", []string{
+			"https://api.openai.com/v1/chat/completions",
+			"https://api.openai.com/chat/completions",
+			"https://api.openai.com",
+		}},
+		{"trailing v1", "https://api.openai.com/v1/", "", []string{"https://api.openai.com/v1/chat/completions"}},
+		{"LiteLLM prefix", "https://litellm.internal/openai/v1", "", []string{"https://litellm.internal/openai/v1/chat/completions"}},
+		{"api prefix", "https://host.example.com/api/v1", "", []string{"https://host.example.com/api/v1/chat/completions"}},
+		{"OpenRouter", "https://openrouter.ai/api/v1", "", []string{"https://openrouter.ai/api/v1/chat/completions"}},
+		{"Gemini OpenAI compatibility", "https://generativelanguage.googleapis.com/v1beta/openai", "", []string{"https://generativelanguage.googleapis.com/v1beta/openai/chat/completions"}},
+		{"Azure-style deployment", "https://proxy.example.com/openai/deployments/gpt-4o", "", []string{
+			"https://proxy.example.com/openai/deployments/gpt-4o/v1/chat/completions",
+			"https://proxy.example.com/openai/deployments/gpt-4o/chat/completions",
+			"https://proxy.example.com/openai/deployments/gpt-4o",
+		}},
+		{"query string kept", "https://proxy.example.com/openai/v1?api-version=2024-06-01", "", []string{"https://proxy.example.com/openai/v1/chat/completions?api-version=2024-06-01"}},
+		{"Ollama port", "http://localhost:11434", "", []string{
+			"http://localhost:11434/v1/chat/completions",
+			"http://localhost:11434/chat/completions",
+			"http://localhost:11434",
+		}},
+		{"full chat endpoint", "https://host.example.com/v1/chat/completions", "", []string{"https://host.example.com/v1/chat/completions"}},
+		{"full responses endpoint", "https://host.example.com/v1/responses", "", []string{"https://host.example.com/v1/responses"}},
+		{"vllm prefix without version", "http://gpu-box:8000/llm", "", []string{
+			"http://gpu-box:8000/llm/v1/chat/completions",
+			"http://gpu-box:8000/llm/chat/completions",
+			"http://gpu-box:8000/llm",
+		}},
+		{"segment that only starts with v", "https://host.example.com/vendor", "", []string{
+			"https://host.example.com/vendor/v1/chat/completions",
+			"https://host.example.com/vendor/chat/completions",
+			"https://host.example.com/vendor",
+		}},
+		{"pinned chat completions", "https://host.example.com/openai/v1", models.EndpointStyleChatCompletions, []string{"https://host.example.com/openai/v1/chat/completions"}},
+		{"pinned completions without version", "https://host.example.com", models.EndpointStyleCompletions, []string{"https://host.example.com/v1/completions"}},
+		{"pinned responses", "https://host.example.com/api/v2beta", models.EndpointStyleResponses, []string{"https://host.example.com/api/v2beta/responses"}},
+		{"not a URL", "localhost", "", []string{"localhost"}},
+	}
+	ac := NewAgentClient(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ac.getChatEndpoints(tt.url, tt.style)
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("getChatEndpoints(%q, %q) =\n  %v\nwant\n  %v", tt.url, tt.style, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPinnedEndpointStyleSkipsProbing(t *testing.T) {
+	for _, tt := range []struct {
+		style string
+		want  int
+	}{
+		{"", 3},
+		{models.EndpointStyleChatCompletions, 1},
+	} {
+		ac, sent := recordingClient(http.StatusNotFound, `{"error":{"message":"not found"}}`)
+		agent := &models.Agent{
+			Name:           "Gateway",
+			ProviderType:   models.ProviderOpenAI,
+			ProviderURL:    "https://gateway.example.com",
+			APIToken:       "sk-test",
+			ModelName:      "test-model",
+			TimeoutSeconds: 10,
+			EndpointStyle:  tt.style,
+		}
+		if _, err := ac.CallAgent(context.Background(), agent, "Say hello", ""); err == nil {
+			t.Errorf("style %q: CallAgent succeeded against a 404", tt.style)
+		}
+		if got := len(sent()); got != tt.want {
+			t.Errorf("style %q: sent %d requests, want %d", tt.style, got, tt.want)
+		}
+	}
+}
+
+// responsesFixture is a Responses API answer without the output_text shortcut
+const responsesFixture = `{
+	"id": "resp_1",
+	"object": "response",
+	"status": "completed",
+	"output": [
+		{"type": "reasoning", "content": [{"type": "text", "text": "thinking"}]},
+		{"type": "message", "role": "assistant", "content": [
+			{"type": "output_text", "text": "Spaces."},
+			{"type": "output_text", "text": "Always spaces."}
+		]}
+	],
+	"usage": {"input_tokens": 12, "output_tokens": 4, "total_tokens": 16}
+}`
+
+func TestResponsesEndpointStyle(t *testing.T) {
+	ac, sent := recordingClient(http.StatusOK, responsesFixture)
+	temperature := 0.2
+	agent := &models.Agent{
+		Name:           "Responses",
+		ProviderType:   models.ProviderOpenAI,
+		ProviderURL:    "https://api.openai.com/v1",
+		APIToken:       "sk-test",
+		ModelName:      "o-model",
+		TimeoutSeconds: 10,
+		EndpointStyle:  models.EndpointStyleResponses,
+	}
+
+	resp, err := ac.CallAgentWithOptions(context.Background(), agent, "Tabs or spaces?", "", CallOptions{SystemPrompt: "Be brief.", Temperature: &temperature, MaxTokens: 50})
+	if err != nil {
+		t.Fatalf("CallAgentWithOptions: %v", err)
+	}
+	if resp.Content != "Spaces.\nAlways spaces." || resp.PromptTokens != 12 || resp.CompletionTokens != 4 {
+		t.Errorf("response = %q with %d/%d tokens", resp.Content, resp.PromptTokens, resp.CompletionTokens)
+	}
+	if resp.Metadata["endpoint_style"] != models.EndpointStyleResponses {
+		t.Errorf("metadata = %v, want the responses endpoint style", resp.Metadata)
+	}
+
+	requests := sent()
+	if len(requests) != 1 || requests[0].URL.String() != "https://api.openai.com/v1/responses" {
+		t.Fatalf("requests = %v, want one to /v1/responses", requests)
+	}
+	body := requestJSON(t, requests[0])
+	want := map[string]interface{}{
+		"model":             "o-model",
+		"input":             "Tabs or spaces?",
+		"instructions":      "Be brief.",
+		"stream":            false,
+		"temperature":       0.2,
+		"max_output_tokens": float64(50),
+	}
+	for key, value := range want {
+		if body[key] != value {
+			t.Errorf("request %s = %v, want %v", key, body[key], value)
+		}
+	}
+	if _, ok := body["messages"]; ok {
+		t.Errorf("responses request carries chat messages: %v", body)
+	}
+}
+
+func TestResponsesOutputText(t *testing.T) {
+	ac, _ := recordingClient(http.StatusOK, `{"output_text":"Tabs.","usage":{"input_tokens":3,"output_tokens":1}}`)
+	agent := &models.Agent{Name: "Responses", ProviderType: models.ProviderOpenAI, ProviderURL: "https://api.openai.com", ModelName: "o-model", TimeoutSeconds: 10, EndpointStyle: models.EndpointStyleResponses}
+	resp, err := ac.CallAgent(context.Background(), agent, "Tabs or spaces?", "")
+	if err != nil || resp.Content != "Tabs." || resp.PromptTokens != 3 {
+		t.Errorf("CallAgent = %+v, %v", resp, err)
+	}
+}
+
+func TestResponsesFallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		notFound string
+		want     []string
+	}{
+		{"hinted", `{"error":{"message":"This model is only supported in v1/responses and not in v1/chat/completions."}}`, []string{
+			"/v1/chat/completions",
+			"/v1/responses",
+		}},
+		{"plain 404", `{"error":{"message":"model not found"}}`, []string{
+			"/v1/chat/completions",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ac, sent := routingClient(func(r *http.Request) (int, string) {
+				if strings.HasSuffix(r.URL.Path, "/responses") {
+					return http.StatusOK, responsesFixture
+				}
+				return http.StatusNotFound, tt.notFound
+			})
+			agent := &models.Agent{Name: "Auto", ProviderType: models.ProviderOpenAI, ProviderURL: "https://api.openai.com/v1", ModelName: "o-model", TimeoutSeconds: 10}
+
+			resp, err := ac.CallAgent(context.Background(), agent, "Tabs or spaces?", "")
+			var paths []string
+			for _, r := range sent() {
+				paths = append(paths, r.URL.Path)
+			}
+			if strings.Join(paths, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("requested %v, want %v", paths, tt.want)
+			}
+			if hinted := tt.name == "hinted"; hinted != (err == nil) {
+				t.Errorf("CallAgent error = %v", err)
+			} else if hinted && resp.Content != "Spaces.\nAlways spaces." {
+				t.Errorf("fallback content = %q", resp.Content)
+			}
+		})
+	}
+}
+
+func TestCallAgentClampsTimeout(t *testing.T) {
+	de := newTestEngine(t)
+	for key, value := range map[string]string{
+		database.SettingDefaultAgentTimeout: "1",
+		database.SettingMaxAgentTimeout:     "1",
+		database.SettingAgentTimeoutBuffer:  "0",
+	} {
+		if err := de.db.SetSetting(key, value); err != nil {
+			t.Fatalf("SetSetting(%s): %v", key, err)
+		}
+	}
+	server, _ := newFrozenProvider(t)
+	// Stored before the ceiling was lowered
+	agent := insertTestAgent(t, de, "Slow", server.URL)
+	agent.TimeoutSeconds = 600
+
+	start := time.Now()
+	_, err := de.agentClient.CallAgent(context.Background(), agent, "Say hello", "")
+	if err == nil {
+		t.Fatal("CallAgent against a provider that never answers succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("call gave up after %v, want about the 1s ceiling", elapsed)
+	}
+}
+
+func TestAnsweredRequestsAreNotResent(t *testing.T) {
+	for _, provider := range []string{models.ProviderOpenAI, models.ProviderCustom} {
+		for _, tt := range []struct {
+			name    string
+			status  int
+			body    string
+			wantErr string
+		}{
+			{"200 with bad JSON", http.StatusOK, `{"choices": [`, "parse"},
+			{"200 with HTML", http.StatusOK, `<html>gateway</html>`, "parse"},
+			{"server error", http.StatusInternalServerError, `{"error":{"message":"boom"}}`, "500"},
+			{"rate limited", http.StatusTooManyRequests, `{"error":{"message":"slow down"}}`, "429"},
+		} {
+			ac, sent := recordingClient(tt.status, tt.body)
+			agent := &models.Agent{
+				Name:           "Gateway",
+				ProviderType:   provider,
+				ProviderURL:    "https://gateway.example.com",
+				APIToken:       "sk-test",
+				ModelName:      "test-model",
+				TimeoutSeconds: 10,
+			}
+			_, err := ac.CallAgent(context.Background(), agent, "Say hello", "")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s, %s: CallAgent error = %v, want one mentioning %q", provider, tt.name, err, tt.wantErr)
+			}
+			if got := len(sent()); got != 1 {
+				t.Errorf("%s, %s: sent %d requests, want exactly one", provider, tt.name, got)
+			}
+		}
+	}
+}
+
+func TestProbesShareRequestID(t *testing.T) {
+	ac, sent := routingClient(func(r *http.Request) (int, string) {
+		// Only the second candidate, /chat/completions without /v1, exists
+		if r.URL.Path != "/chat/completions" {
+			return http.StatusNotFound, `{"error":{"message":"not found"}}`
+		}
+		return http.StatusOK, `{"choices":[{"message":{"role":"assistant","content":"Hello."},"finish_reason":"stop"}]}`
+	})
+	agent := &models.Agent{
+		Name:           "Gateway",
+		ProviderType:   models.ProviderOpenAI,
+		ProviderURL:    "https://gateway.example.com",
+		APIToken:       "sk-test",
+		ModelName:      "test-model",
+		TimeoutSeconds: 10,
+	}
+	resp, err := ac.CallAgent(context.Background(), agent, "Say hello", "")
+	if err != nil || resp.Content != "Hello." {
+		t.Fatalf("CallAgent = %+v, %v", resp, err)
+	}
+
+	requests := sent()
+	if len(requests) < 2 {
+		t.Fatalf("sent %d requests, want a 404 probe before the answer", len(requests))
+	}
+	id := requests[0].Header.Get("X-Request-ID")
+	if id == "" {
+		t.Fatal("no X-Request-ID on the first request")
+	}
+	for _, r := range requests[1:] {
+		if got := r.Header.Get("X-Request-ID"); got != id {
+			t.Errorf("request to %s has X-Request-ID %q, want %q", r.URL.Path, got, id)
+		}
+	}
+
+	// A new call gets a new key
+	ac.CallAgent(context.Background(), agent, "Say hello", "")
+	if again := sent()[len(requests)].Header.Get("X-Request-ID"); again == id {
+		t.Errorf("second call reused X-Request-ID %q", id)
+	}
+}
+
+func TestCallAgentReportsTokenUsage(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		url      string
+		body     string
+	}{
+		{"openai", models.ProviderOpenAI, "https://api.openai.com/v1",
+			`{"choices":[{"message":{"role":"assistant","content":"Spaces."},"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":4,"total_tokens":16}}`},
+		{"anthropic", models.ProviderAnthropic, "https://api.anthropic.com/v1",
+			`{"content":[{"type":"text","text":"Spaces."}],"stop_reason":"end_turn","usage":{"input_tokens":12,"output_tokens":4}}`},
+		{"google", models.ProviderGoogle, "https://generativelanguage.googleapis.com/v1beta",
+			`{"candidates":[{"content":{"parts":[{"text":"Spaces."}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":12,"candidatesTokenCount":4,"totalTokenCount":16}}`},
+		{"ollama", models.ProviderOllama, "http://localhost:11434",
+			`{"message":{"role":"assistant","content":"Spaces."},"done":true,"prompt_eval_count":12,"eval_count":4}`},
+		{"custom", models.ProviderCustom, "https://llm.example.com/v1",
+			`{"choices":[{"message":{"role":"assistant","content":"Spaces."}}],"usage":{"prompt_tokens":12,"completion_tokens":4}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ac, _ := recordingClient(http.StatusOK, tt.body)
+			agent := &models.Agent{
+				Name:           tt.name,
+				ProviderType:   tt.provider,
+				ProviderURL:    tt.url,
+				APIToken:       "key",
+				ModelName:      "test-model",
+				TimeoutSeconds: 10,
+			}
+
+			resp, err := ac.CallAgent(context.Background(), agent, "Tabs or spaces?", "")
+			if err != nil || !resp.Success {
+				t.Fatalf("CallAgent = %+v, %v", resp, err)
+			}
+			if resp.Content != "Spaces." || resp.PromptTokens != 12 || resp.CompletionTokens != 4 {
+				t.Errorf("response = %q with %d/%d tokens, want \"Spaces.\" with 12/4", resp.Content, resp.PromptTokens, resp.CompletionTokens)
+			}
+			if resp.Metadata["input_tokens"] != "12" || resp.Metadata["output_tokens"] != "4" || resp.Metadata["total_tokens"] != "16" {
+				t.Errorf("metadata = %v, want the token counts", resp.Metadata)
+			}
+		})
+	}
+}
+
+func TestCallAgentWithoutUsage(t *testing.T) {
+	ac, _ := recordingClient(http.StatusOK, `{"choices":[{"message":{"role":"assistant","content":"Spaces."},"finish_reason":"stop"}]}`)
+	agent := &models.Agent{Name: "Quiet", ProviderType: models.ProviderOpenAI, ProviderURL: "https://api.openai.com/v1", ModelName: "m", TimeoutSeconds: 10}
+
+	resp, err := ac.CallAgent(context.Background(), agent, "Tabs or spaces?", "")
+	if err != nil {
+		t.Fatalf("CallAgent: %v", err)
+	}
+	if resp.PromptTokens != 0 || resp.CompletionTokens != 0 || resp.Metadata["total_tokens"] != "" {
+		t.Errorf("response without usage = %d/%d tokens, metadata %v", resp.PromptTokens, resp.CompletionTokens, resp.Metadata)
+	}
+}
+
+func TestTurnsStoreTokenUsage(t *testing.T) {
+	de := newTestEngine(t)
+	server := newScriptedProvider(t, "Spaces, always.", 30, 7, 100)
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+	bob := insertTestAgent(t, de, "Bob", server.URL)
+
+	d := runToEnd(t, de, []*models.Agent{alice, bob}, 2, models.DiscussionSettings{})
+	logs, err := de.db.GetDiscussionLogs(d.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	turns := 0
+	for _, l := range logs {
+		if l.IsSystem() {
+			continue
+		}
+		turns++
+		if l.PromptTokens != 30 || l.CompletionTokens != 7 {
+			t.Errorf("entry %d stored %d/%d tokens, want 30/7", l.ID, l.PromptTokens, l.CompletionTokens)
+		}
+	}
+	if turns != 4 {
+		t.Errorf("found %d agent entries, want 4", turns)
+	}
+}
+
+func TestPersonaLeadsTheSystemPrompt(t *testing.T) {
+	persona := "You are a skeptical economist."
+	tests := []struct {
+		name     string
+		provider string
+		url      string
+		reply    string
+		system   func(body map[string]interface{}) string
+	}{
+		{"openai", models.ProviderOpenAI, "https://api.openai.com/v1",
+			`{"choices":[{"message":{"role":"assistant","content":"Spaces."}}]}`, systemMessage},
+		{"anthropic", models.ProviderAnthropic, "https://api.anthropic.com/v1",
+			`{"content":[{"type":"text","text":"Spaces."}]}`,
+			func(body map[string]interface{}) string { s, _ := body["system"].(string); return s }},
+		{"google", models.ProviderGoogle, "https://generativelanguage.googleapis.com/v1beta",
+			`{"candidates":[{"content":{"parts":[{"text":"Spaces."}]}}]}`,
+			func(body map[string]interface{}) string {
+				instruction, _ := body["systemInstruction"].(map[string]interface{})
+				parts, _ := instruction["parts"].([]interface{})
+				if len(parts) == 0 {
+					return ""
+				}
+				s, _ := parts[0].(map[string]interface{})["text"].(string)
+				return s
+			}},
+		{"ollama", models.ProviderOllama, "http://localhost:11434",
+			`{"message":{"role":"assistant","content":"Spaces."},"done":true}`, systemMessage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			systemOf := func(agentPersona string, opts CallOptions) string {
+				t.Helper()
+				ac, sent := recordingClient(http.StatusOK, tt.reply)
+				agent := &models.Agent{Name: tt.name, ProviderType: tt.provider, ProviderURL: tt.url, APIToken: "key", ModelName: "m", TimeoutSeconds: 10, SystemPrompt: agentPersona}
+				if _, err := ac.CallAgentWithOptions(context.Background(), agent, "Tabs or spaces?", "Bob: tabs.", opts); err != nil {
+					t.Fatalf("CallAgentWithOptions: %v", err)
+				}
+				requests := sent()
+				return tt.system(requestJSON(t, requests[len(requests)-1]))
+			}
+
+			framing := systemOf("", CallOptions{})
+			if framing == "" || strings.Contains(framing, persona) {
+				t.Fatalf("system prompt without a persona = %q", framing)
+			}
+			if got := systemOf(persona, CallOptions{}); got != persona+"\n\n"+framing {
+				t.Errorf("system prompt with a persona = %q, want the persona then %q", got, framing)
+			}
+			// An explicit system prompt replaces both
+			if got := systemOf(persona, CallOptions{SystemPrompt: "Moderate."}); got != "Moderate." {
+				t.Errorf("system prompt with an override = %q, want the override alone", got)
+			}
+		})
+	}
+}
+
+func ollamaAgent() *models.Agent {
+	return &models.Agent{Name: "Llama", ProviderType: models.ProviderOllama, ProviderURL: "http://localhost:11434", ModelName: "llama3", TimeoutSeconds: 10}
+}
+
+func TestCallOllamaChat(t *testing.T) {
+	ac, sent := recordingClient(http.StatusOK, `{"message":{"role":"assistant","content":"Spaces."},"done":true,"prompt_eval_count":12,"eval_count":4}`)
+
+	resp, err := ac.CallAgent(context.Background(), ollamaAgent(), "Tabs or spaces?", "Bob: tabs.")
+	if err != nil || resp.Content != "Spaces." || resp.PromptTokens != 12 {
+		t.Fatalf("CallAgent = %+v, %v", resp, err)
+	}
+	requests := sent()
+	if len(requests) != 1 || requests[0].URL.Path != "/api/chat" {
+		t.Fatalf("requests = %v, want one to /api/chat", requests)
+	}
+	// The context goes into the system message, as on the OpenAI path
+	body := requestJSON(t, requests[0])
+	if system := systemMessage(body); system != debateInstructions("Bob: tabs.") {
+		t.Errorf("system message = %q, want the instructions with the context", system)
+	}
+	if user := userMessage(body); user != "Tabs or spaces?" {
+		t.Errorf("user message = %q, want the prompt alone", user)
+	}
+	if body["model"] != "llama3" || body["stream"] != false {
+		t.Errorf("request = %v", body)
+	}
+}
+
+func TestCallOllamaFallsBackToGenerate(t *testing.T) {
+	ac, sent := routingClient(func(r *http.Request) (int, string) {
+		if r.URL.Path == "/api/chat" {
+			return http.StatusNotFound, "404 page not found"
+		}
+		return http.StatusOK, `{"response":"Spaces.","done":true,"prompt_eval_count":12,"eval_count":4}`
+	})
+
+	resp, err := ac.CallAgent(context.Background(), ollamaAgent(), "Tabs or spaces?", "Bob: tabs.")
+	if err != nil || !resp.Success || resp.Content != "Spaces." || resp.CompletionTokens != 4 {
+		t.Fatalf("CallAgent = %+v, %v", resp, err)
+	}
+	requests := sent()
+	if len(requests) != 2 || requests[0].URL.Path != "/api/chat" || requests[1].URL.Path != "/api/generate" {
+		t.Fatalf("requests = %v, want /api/chat then /api/generate", requests)
+	}
+	// The legacy endpoint gets the context flattened into the prompt
+	if prompt, _ := requestJSON(t, requests[1])["prompt"].(string); !strings.Contains(prompt, "Bob: tabs.") || !strings.Contains(prompt, "Tabs or spaces?") {
+		t.Errorf("generate prompt = %q, want the context and the prompt", prompt)
+	}
+}
+
+func TestCallOllamaMissingModel(t *testing.T) {
+	ac, sent := recordingClient(http.StatusNotFound, `{"error":"model \"llama3\" not found, try pulling it first"}`)
+
+	resp, err := ac.CallAgent(context.Background(), ollamaAgent(), "Tabs or spaces?", "")
+	if err == nil || resp.Success || !strings.Contains(resp.ErrorMessage, "not found, try pulling it first") {
+		t.Errorf("CallAgent = %+v, %v, want the missing model reported", resp, err)
+	}
+	// A missing model is not mistaken for a server without /api/chat
+	for _, r := range sent() {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("fell back to %s for a missing model", r.URL.Path)
+		}
+	}
+}
+
+func TestExtraHeaders(t *testing.T) {
+	tests := []struct {
+		provider string
+		url      string
+		reply    string
+	}{
+		{models.ProviderOpenAI, "https://openrouter.ai/api/v1", `{"choices":[{"message":{"role":"assistant","content":"Spaces."}}],"data":[]}`},
+		{models.ProviderAnthropic, "https://gateway.ai.cloudflare.com/v1/acct/gw/anthropic", `{"content":[{"type":"text","text":"Spaces."}]}`},
+		{models.ProviderGoogle, "https://generativelanguage.googleapis.com/v1beta", `{"candidates":[{"content":{"parts":[{"text":"Spaces."}]}}]}`},
+		{models.ProviderOllama, "http://localhost:11434", `{"message":{"role":"assistant","content":"Spaces."},"done":true}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			ac, sent := recordingClient(http.StatusOK, tt.reply)
+			agent := &models.Agent{
+				Name: tt.provider, ProviderType: tt.provider, ProviderURL: tt.url, APIToken: "key", ModelName: "m", TimeoutSeconds: 10,
+				EndpointStyle: models.EndpointStyleChatCompletions,
+				// An extra header cannot replace the agent's credentials
+				ExtraHeaders: models.JSONMap{"HTTP-Referer": "https://court.example.com", "cf-aig-authorization": "Bearer cf-secret", "Authorization": "Bearer wrong", "x-api-key": "wrong"},
+			}
+			if _, err := ac.CallAgent(context.Background(), agent, "Tabs or spaces?", ""); err != nil {
+				t.Fatalf("CallAgent: %v", err)
+			}
+			if err := ac.Ping(context.Background(), agent); err != nil {
+				t.Fatalf("Ping: %v", err)
+			}
+
+			requests := sent()
+			if len(requests) < 2 {
+				t.Fatalf("sent %d requests, want the call and the ping", len(requests))
+			}
+			for _, r := range requests {
+				if r.Header.Get("HTTP-Referer") != "https://court.example.com" || r.Header.Get("Cf-Aig-Authorization") != "Bearer cf-secret" {
+					t.Errorf("%s %s lacks the extra headers: %v", r.Method, r.URL.Path, r.Header)
+				}
+				if r.Header.Get("Content-Type") == "" && r.Method == http.MethodPost {
+					t.Errorf("%s %s lost its Content-Type", r.Method, r.URL.Path)
+				}
+				switch tt.provider {
+				case models.ProviderAnthropic:
+					if r.Header.Get("X-Api-Key") != "key" {
+						t.Errorf("x-api-key = %q, want the agent's token", r.Header.Get("X-Api-Key"))
+					}
+				case models.ProviderOpenAI, models.ProviderOllama:
+					if r.Header.Get("Authorization") != "Bearer key" {
+						t.Errorf("Authorization = %q, want the agent's token", r.Header.Get("Authorization"))
+					}
+				}
+			}
+		})
+	}
+}
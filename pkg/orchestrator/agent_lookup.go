@@ -0,0 +1,136 @@
+package orchestrator
+
+import (
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Outcomes of looking up one agent in AgentLookup.Status
+const (
+	AgentLookupFound    = "found"
+	AgentLookupNotFound = "not_found"
+	AgentLookupFailed   = "error"
+)
+
+// agentLookupRetryDelay is the wait before retrying a lookup that found the
+// database locked
+const agentLookupRetryDelay = 100 * time.Millisecond
+
+// AgentLookup is the result of looking up one agent of a discussion
+type AgentLookup struct {
+	ID     int64  `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"` // the cause when Status is "error"
+}
+
+// AgentLookupError reports the agents of a discussion that could not be
+// loaded, with the result for every requested ID
+type AgentLookupError struct {
+	Results []AgentLookup
+}
+
+// Missing returns the requested IDs that have no agent
+func (e *AgentLookupError) Missing() []int64 {
+	return e.withStatus(AgentLookupNotFound)
+}
+
+// Failed returns the requested IDs whose lookup failed
+func (e *AgentLookupError) Failed() []int64 {
+	return e.withStatus(AgentLookupFailed)
+}
+
+func (e *AgentLookupError) withStatus(status string) []int64 {
+	var ids []int64
+	for _, r := range e.Results {
+		if r.Status == status {
+			ids = append(ids, r.ID)
+		}
+	}
+	return ids
+}
+
+func (e *AgentLookupError) Error() string {
+	var parts []string
+	if missing := e.Missing(); len(missing) > 0 {
+		parts = append(parts, "agents not found: "+joinIDs(missing))
+	}
+	if failed := e.Failed(); len(failed) > 0 {
+		cause := ""
+		for _, r := range e.Results {
+			if r.Status == AgentLookupFailed {
+				cause = r.Error
+				break
+			}
+		}
+		parts = append(parts, fmt.Sprintf("failed to load agents %s: %s", joinIDs(failed), cause))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func joinIDs(ids []int64) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(s, ", ")
+}
+
+// getAgents loads the agents of a discussion in the given order. A lookup
+// that finds the database locked is retried once. When any agent is missing
+// or could not be read the error is an *AgentLookupError.
+func (de *DebateEngine) getAgents(agentIDs []int64) ([]*models.Agent, error) {
+	found, err := de.db.GetAgentsByIDs(agentIDs)
+	if err != nil && database.IsBusy(err) {
+		log.Printf("Database busy while loading agents, retrying: %v", err)
+		time.Sleep(agentLookupRetryDelay)
+		found, err = de.db.GetAgentsByIDs(agentIDs)
+	}
+
+	results := make([]AgentLookup, len(agentIDs))
+	agents := make([]*models.Agent, 0, len(agentIDs))
+	complete := err == nil
+	for i, id := range agentIDs {
+		results[i] = AgentLookup{ID: id, Status: AgentLookupFound}
+		switch agent, ok := found[id]; {
+		case err != nil:
+			results[i].Status = AgentLookupFailed
+			results[i].Error = err.Error()
+		case !ok:
+			results[i].Status = AgentLookupNotFound
+			complete = false
+		default:
+			agents = append(agents, agent)
+		}
+	}
+	if !complete {
+		return nil, &AgentLookupError{Results: results}
+	}
+
+	for _, agent := range agents {
+		if agent.Disabled {
+			return nil, fmt.Errorf("%w: %s", ErrAgentDisabled, agent.Name)
+		}
+	}
+
+	return agents, nil
+}
+
+// NoteAgentDeleted records in each of the live discussions that agent was
+// deleted from under them. The agent's remaining turns are skipped, each with
+// its own skip note; the turns it already took stay in the transcript.
+func (de *DebateEngine) NoteAgentDeleted(agent *models.Agent, discussionIDs []int64) {
+	for _, id := range discussionIDs {
+		log.Printf("Agent %d was deleted while discussion %d was running", agent.ID, id)
+		de.insertSystemLog(id, models.LogTypeSystem, "error",
+			fmt.Sprintf("Agent %s was deleted while this discussion was running; its remaining turns are skipped", agent.Name),
+			models.JSONMap{
+				"alert":            "agent_deleted",
+				"deleted_agent_id": strconv.FormatInt(agent.ID, 10),
+			})
+	}
+}
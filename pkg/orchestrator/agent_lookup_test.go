@@ -0,0 +1,130 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+func TestDeletedAgentTurnsAreSkipped(t *testing.T) {
+	de := newTestEngine(t)
+	server, calls := newTestProvider(t, "Spaces, always.")
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+	discussion := insertTestDiscussion(t, de, "running", alice)
+
+	out := de.takeTurn(context.Background(), discussion, alice, 1, "", "Your turn", "", 0, 0, runNow)
+	if !out.answered || calls.Load() != 1 {
+		t.Fatalf("turn before delete: answered %v after %d calls, want an answer from one call", out.answered, calls.Load())
+	}
+
+	// Forced delete while the debate still holds the loaded agent
+	if err := de.db.DeleteAgent(alice.ID); err != nil {
+		t.Fatalf("DeleteAgent: %v", err)
+	}
+	de.NoteAgentDeleted(alice, []int64{discussion.ID})
+
+	out = de.takeTurn(context.Background(), discussion, alice, 2, "", "Your turn", "", 0, 0, runNow)
+	if out.answered || !out.skipped || out.failure != "Alice (deleted)" {
+		t.Errorf("turn after delete = %+v, want a skip labelled deleted", out)
+	}
+	if got := de.runModerator(context.Background(), discussion, alice, "summary", "", 0); got != nil {
+		t.Errorf("moderator turn after delete logged %+v, want a skip", got)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("provider called %d times, want no calls after the delete", calls.Load())
+	}
+
+	logs, err := de.db.GetDiscussionLogs(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	var kinds []string
+	for _, l := range logs {
+		kinds = append(kinds, l.LogType+"/"+l.Status)
+	}
+	want := []string{
+		models.LogTypeResponse + "/success",
+		models.LogTypeSystem + "/error",
+		models.LogTypeSkip + "/skipped",
+		models.LogTypeSkip + "/skipped",
+	}
+	if strings.Join(kinds, " ") != strings.Join(want, " ") {
+		t.Fatalf("transcript = %v, want %v", kinds, want)
+	}
+	if logs[0].Content != "Spaces, always." || logs[0].AgentID != alice.ID {
+		t.Errorf("first turn = %q by %d, want Alice's reply kept", logs[0].Content, logs[0].AgentID)
+	}
+	if !strings.Contains(logs[1].Content, "remaining turns are skipped") || logs[1].Metadata["alert"] != "agent_deleted" {
+		t.Errorf("delete note = %q %v", logs[1].Content, logs[1].Metadata)
+	}
+	if logs[2].Metadata["skip_reason"] != ErrAgentDeleted.Error() || logs[3].Metadata["skipped_role"] != "moderator" {
+		t.Errorf("skip notes = %v, %v", logs[2].Metadata, logs[3].Metadata)
+	}
+}
+
+func TestGetAgentsKeepsTheRequestedOrder(t *testing.T) {
+	de := newTestEngine(t)
+	alice := insertTestAgent(t, de, "Alice", "http://127.0.0.1:1")
+	bob := insertTestAgent(t, de, "Bob", "http://127.0.0.1:1")
+
+	agents, err := de.getAgents([]int64{bob.ID, alice.ID})
+	if err != nil {
+		t.Fatalf("getAgents: %v", err)
+	}
+	if len(agents) != 2 || agents[0].ID != bob.ID || agents[1].ID != alice.ID {
+		t.Errorf("agents = %+v, want Bob then Alice", agents)
+	}
+
+	alice.Disabled = true
+	if err := de.db.UpdateAgent(alice); err != nil {
+		t.Fatalf("UpdateAgent: %v", err)
+	}
+	if _, err := de.getAgents([]int64{bob.ID, alice.ID}); !errors.Is(err, ErrAgentDisabled) || !strings.Contains(err.Error(), "Alice") {
+		t.Errorf("getAgents with a disabled agent = %v, want ErrAgentDisabled naming Alice", err)
+	}
+}
+
+func TestGetAgentsReportsMissingAgents(t *testing.T) {
+	de := newTestEngine(t)
+	alice := insertTestAgent(t, de, "Alice", "http://127.0.0.1:1")
+
+	_, err := de.getAgents([]int64{998, alice.ID, 999})
+	var lookupErr *AgentLookupError
+	if !errors.As(err, &lookupErr) {
+		t.Fatalf("getAgents = %v, want an *AgentLookupError", err)
+	}
+	want := []AgentLookup{{ID: 998, Status: AgentLookupNotFound}, {ID: alice.ID, Status: AgentLookupFound}, {ID: 999, Status: AgentLookupNotFound}}
+	if !reflect.DeepEqual(lookupErr.Results, want) {
+		t.Errorf("results = %+v, want %+v", lookupErr.Results, want)
+	}
+	if err.Error() != "agents not found: 998, 999" || len(lookupErr.Failed()) != 0 {
+		t.Errorf("error = %q, failed %v", err, lookupErr.Failed())
+	}
+}
+
+func TestGetAgentsReportsDatabaseErrors(t *testing.T) {
+	de := newTestEngine(t)
+	alice := insertTestAgent(t, de, "Alice", "http://127.0.0.1:1")
+	de.db.Close()
+
+	_, err := de.getAgents([]int64{alice.ID, 999})
+	var lookupErr *AgentLookupError
+	if !errors.As(err, &lookupErr) {
+		t.Fatalf("getAgents = %v, want an *AgentLookupError", err)
+	}
+	if failed := lookupErr.Failed(); !reflect.DeepEqual(failed, []int64{alice.ID, 999}) {
+		t.Errorf("failed = %v, want every requested ID", failed)
+	}
+	for _, r := range lookupErr.Results {
+		if r.Error == "" {
+			t.Errorf("result %+v has no cause", r)
+		}
+	}
+	if !strings.HasPrefix(err.Error(), "failed to load agents 1, 999: ") {
+		t.Errorf("error = %q", err)
+	}
+}
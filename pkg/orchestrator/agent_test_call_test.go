@@ -0,0 +1,58 @@
+package orchestrator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+func TestTestAgent(t *testing.T) {
+	de := newTestEngine(t)
+	server, bodies := newBodyProvider(t, "Ahoy, matey!")
+	agent := insertTestAgent(t, de, "Alice", server.URL)
+	agent.SystemPrompt = "You are a pirate."
+
+	result := de.TestAgent(context.Background(), agent, "Say hello in one sentence")
+	if !result.Success || result.Error != "" || result.Content != "Ahoy, matey!" {
+		t.Fatalf("TestAgent = %+v", result)
+	}
+	if result.Prompt != "Say hello in one sentence" || result.PromptTokens != 5 || result.CompletionTokens != 3 || result.TotalTokens != 8 {
+		t.Errorf("TestAgent = %+v, want the prompt and 5+3 tokens", result)
+	}
+
+	// The request is a debate turn: persona and debate instructions included
+	sent := bodies()
+	if len(sent) != 1 {
+		t.Fatalf("provider got %d requests, want 1", len(sent))
+	}
+	if system := systemMessage(sent[0]); !strings.HasPrefix(system, "You are a pirate.") {
+		t.Errorf("system message = %q, want the persona first", system)
+	}
+	if user := userMessage(sent[0]); !strings.Contains(user, "Say hello in one sentence") {
+		t.Errorf("user message = %q, want the prompt", user)
+	}
+
+	page, err := de.db.GetDiscussionsPage(models.DiscussionQuery{Page: 1, PerPage: 10})
+	if err != nil || page.Total != 0 {
+		t.Errorf("discussions after a test call = %+v, %v, want none", page, err)
+	}
+}
+
+func TestTestAgentReportsFailure(t *testing.T) {
+	de := newTestEngine(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"message":"The model 'nope' does not exist"}}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+	agent := insertTestAgent(t, de, "Alice", server.URL)
+	agent.ModelName = "nope"
+
+	result := de.TestAgent(context.Background(), agent, "Hello")
+	if result.Success || result.Error == "" || result.Content != "" {
+		t.Errorf("TestAgent on a 404 = %+v, want a failure with an error", result)
+	}
+}
@@ -0,0 +1,219 @@
+package orchestrator
+
+import (
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+// AlertRules returns the stored alert rules, or the defaults when none or
+// invalid ones were saved
+func (de *DebateEngine) AlertRules() models.AlertRulesConfig {
+	cfg := models.DefaultAlertRules()
+	found, err := de.db.GetSettingJSON(database.SettingAlertRules, &cfg)
+	if err != nil {
+		log.Printf("Failed to read alert rules: %v", err)
+	}
+	if !found || err != nil || cfg.Validate() != nil {
+		cfg = models.DefaultAlertRules()
+	}
+	return cfg
+}
+
+// agentWindow is one agent's calls over the alert window
+type agentWindow struct {
+	calls, failed, succeeded int
+	latencyMs                float64 // summed over successful calls
+	failureRate              float64 // percent
+	avgLatencyMs             float64
+	hasFailureRate           bool
+	hasAverageLatency        bool
+}
+
+// alertWindows tallies each agent's calls. Rates are only set for agents
+// with at least minCalls calls; latency needs minCalls successful ones.
+func alertWindows(outcomes []models.AgentOutcome, minCalls int) map[int64]*agentWindow {
+	windows := make(map[int64]*agentWindow)
+	for _, o := range outcomes {
+		w := windows[o.AgentID]
+		if w == nil {
+			w = &agentWindow{}
+			windows[o.AgentID] = w
+		}
+		w.calls++
+		if o.Status == "success" {
+			w.succeeded++
+			w.latencyMs += float64(o.ResponseTime)
+		} else {
+			w.failed++
+		}
+	}
+
+	for _, w := range windows {
+		if w.calls >= minCalls {
+			w.failureRate = 100 * float64(w.failed) / float64(w.calls)
+			w.hasFailureRate = true
+		}
+		if w.succeeded >= minCalls {
+			w.avgLatencyMs = w.latencyMs / float64(w.succeeded)
+			w.hasAverageLatency = true
+		}
+	}
+	return windows
+}
+
+// breach reports the value of metric for w and whether it is at or over
+// the rule's threshold. A rule that is off or lacks data never breaches.
+func (w *agentWindow) breach(cfg models.AlertRulesConfig, metric string) (value, threshold float64, breached bool) {
+	if w == nil {
+		return 0, 0, false
+	}
+	switch metric {
+	case models.AlertMetricFailureRate:
+		threshold = float64(cfg.FailureRatePercent)
+		return w.failureRate, threshold, cfg.FailureRatePercent > 0 && w.hasFailureRate && w.failureRate >= threshold
+	case models.AlertMetricLatency:
+		threshold = float64(cfg.LatencyMs)
+		return w.avgLatencyMs, threshold, cfg.LatencyMs > 0 && w.hasAverageLatency && w.avgLatencyMs >= threshold
+	}
+	return 0, 0, false
+}
+
+var alertMetrics = []string{models.AlertMetricFailureRate, models.AlertMetricLatency}
+
+// EvaluateAlerts checks every agent's recent calls against the alert rules.
+// It fires an alert when a metric crosses its threshold and resolves it once
+// the metric recovers, notifying both on /api/events and the alert webhook.
+// It returns the alerts fired or resolved in this pass.
+func (de *DebateEngine) EvaluateAlerts() []*models.Alert {
+	cfg := de.AlertRules()
+	if !cfg.Enabled {
+		return nil
+	}
+
+	now := de.now()
+	window := time.Duration(cfg.WindowMinutes) * time.Minute
+	outcomes, err := de.db.GetAgentOutcomes(now.Add(-window))
+	if err != nil {
+		log.Printf("Failed to load agent calls for alerting: %v", err)
+		return nil
+	}
+	latest, err := de.db.GetLatestAlerts()
+	if err != nil {
+		log.Printf("Failed to load alerts: %v", err)
+		return nil
+	}
+	windows := alertWindows(outcomes, cfg.MinCalls)
+
+	// Agents with calls in the window, and those with an alert still firing
+	// whatever their traffic
+	agentIDs := make([]int64, 0, len(windows))
+	for id := range windows {
+		agentIDs = append(agentIDs, id)
+	}
+	for id, byMetric := range latest {
+		if _, ok := windows[id]; ok {
+			continue
+		}
+		for _, alert := range byMetric {
+			if alert.State == models.AlertStateFiring {
+				agentIDs = append(agentIDs, id)
+				break
+			}
+		}
+	}
+	sort.Slice(agentIDs, func(i, j int) bool { return agentIDs[i] < agentIDs[j] })
+
+	cooldown := time.Duration(cfg.CooldownMinutes) * time.Minute
+	var changed []*models.Alert
+	for _, agentID := range agentIDs {
+		for _, metric := range alertMetrics {
+			value, threshold, breached := windows[agentID].breach(cfg, metric)
+			last := latest[agentID][metric]
+			firing := last != nil && last.State == models.AlertStateFiring
+
+			switch {
+			case breached && !firing:
+				if last != nil && now.Sub(last.FiredAt) < cooldown {
+					continue
+				}
+				alert := de.fireAlert(agentID, metric, value, threshold, windows[agentID].calls, cfg, now)
+				if alert != nil {
+					changed = append(changed, alert)
+				}
+			case !breached && firing:
+				last.State = models.AlertStateResolved
+				last.ResolvedAt = &now
+				if err := de.db.ResolveAlert(last); err != nil {
+					log.Printf("Failed to resolve alert %d: %v", last.ID, err)
+					continue
+				}
+				de.notifyAlert(last, cfg, fmt.Sprintf("Resolved: %s", last.Message))
+				changed = append(changed, last)
+			}
+		}
+	}
+	return changed
+}
+
+// fireAlert records a new alert for an agent and sends its notification
+func (de *DebateEngine) fireAlert(agentID int64, metric string, value, threshold float64, calls int, cfg models.AlertRulesConfig, now time.Time) *models.Alert {
+	name := fmt.Sprintf("agent %d", agentID)
+	if agentName, err := de.db.GetAgentName(agentID); err == nil {
+		name = agentName
+	}
+
+	alert := &models.Alert{
+		AgentID:   agentID,
+		AgentName: name,
+		Metric:    metric,
+		State:     models.AlertStateFiring,
+		Value:     math.Round(value*10) / 10,
+		Threshold: threshold,
+		Calls:     calls,
+		FiredAt:   now,
+	}
+	switch metric {
+	case models.AlertMetricFailureRate:
+		alert.Message = fmt.Sprintf("%s failed %.0f%% of %d calls in the last %d minutes (threshold %.0f%%)",
+			name, value, calls, cfg.WindowMinutes, threshold)
+	case models.AlertMetricLatency:
+		alert.Message = fmt.Sprintf("%s averaged %.1fs per successful call in the last %d minutes (threshold %.1fs)",
+			name, value/1000, cfg.WindowMinutes, threshold/1000)
+	}
+
+	if err := de.db.InsertAlert(alert); err != nil {
+		log.Printf("Failed to record alert for agent %d: %v", agentID, err)
+		return nil
+	}
+	de.notifyAlert(alert, cfg, alert.Message)
+	return alert
+}
+
+// notifyAlert publishes an alert change on /api/events and to the alert webhook
+func (de *DebateEngine) notifyAlert(alert *models.Alert, cfg models.AlertRulesConfig, message string) {
+	log.Printf("Agent alert: %s", message)
+
+	eventType, webhookEvent := "agent_alert", models.WebhookEventAlertFiring
+	if alert.State == models.AlertStateResolved {
+		eventType, webhookEvent = "agent_alert_resolved", models.WebhookEventAlertResolved
+	}
+	de.broadcastGlobal(&models.EngineEvent{
+		Type:      eventType,
+		Message:   message,
+		CreatedAt: de.now(),
+	})
+
+	if cfg.Webhook != nil {
+		de.deliverWebhook(*cfg.Webhook, webhookEvent, models.WebhookPayload{
+			Event:  webhookEvent,
+			Alert:  alert,
+			Text:   message,
+			SentAt: de.now(),
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package orchestrator
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+)
+
+// insertCalls logs n calls of agent with status, each taking responseTime
+// milliseconds, made at the given time
+func insertCalls(t *testing.T, de *DebateEngine, discussionID int64, agent *models.Agent, status string, n, responseTime int, at time.Time) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		l := &models.DiscussionLog{DiscussionID: discussionID, AgentID: agent.ID, Content: "reply", Status: status, ResponseTime: responseTime, LogType: models.LogTypeResponse, CreatedAt: at}
+		if err := de.db.InsertDiscussionLog(l); err != nil {
+			t.Fatalf("InsertDiscussionLog: %v", err)
+		}
+	}
+}
+
+// engineEventTypes returns the types of the engine events queued on events
+func engineEventTypes(events chan Event) []string {
+	var types []string
+	for {
+		select {
+		case event := <-events:
+			if _, ok := event.Data.(models.EngineEvent); ok {
+				types = append(types, event.Type)
+			}
+		default:
+			return types
+		}
+	}
+}
+
+func setAlertRules(t *testing.T, de *DebateEngine, cfg models.AlertRulesConfig) {
+	t.Helper()
+	if err := de.db.SetSettingJSON(database.SettingAlertRules, cfg); err != nil {
+		t.Fatalf("SetSettingJSON: %v", err)
+	}
+}
+
+func TestAlertFiresCoolsDownAndResolves(t *testing.T) {
+	de := newTestEngine(t)
+	clock := newFakeClock(de)
+	receiver, posts := newWebhookReceiver(t, http.StatusOK)
+	setAlertRules(t, de, models.AlertRulesConfig{Enabled: true, WindowMinutes: 60, MinCalls: 4, FailureRatePercent: 50, CooldownMinutes: 30, Webhook: &models.DiscussionWebhook{URL: receiver.URL}})
+	events := de.SubscribeGlobal()
+	defer de.UnsubscribeGlobal(events)
+
+	alice := insertTestAgent(t, de, "Alice", "http://127.0.0.1:1")
+	bob := insertTestAgent(t, de, "Bob", "http://127.0.0.1:1")
+	carol := insertTestAgent(t, de, "Carol", "http://127.0.0.1:1")
+	discussion := insertTestDiscussion(t, de, "completed", alice, bob, carol)
+	start := clock.Now()
+	insertCalls(t, de, discussion.ID, alice, "error", 2, 100, start.Add(-10*time.Minute))
+	insertCalls(t, de, discussion.ID, alice, "success", 2, 100, start.Add(-10*time.Minute))
+	insertCalls(t, de, discussion.ID, bob, "success", 4, 100, start.Add(-10*time.Minute))
+	// Too few calls to judge, and calls from before the window
+	insertCalls(t, de, discussion.ID, carol, "timeout", 3, 100, start.Add(-10*time.Minute))
+	insertCalls(t, de, discussion.ID, carol, "error", 5, 100, start.Add(-2*time.Hour))
+
+	changed := de.EvaluateAlerts()
+	if len(changed) != 1 {
+		t.Fatalf("first pass changed %d alerts, want Alice's", len(changed))
+	}
+	fired := changed[0]
+	if fired.AgentID != alice.ID || fired.AgentName != "Alice" || fired.Metric != models.AlertMetricFailureRate || fired.State != models.AlertStateFiring ||
+		fired.Value != 50 || fired.Threshold != 50 || fired.Calls != 4 || !fired.FiredAt.Equal(start) {
+		t.Errorf("fired alert = %+v", fired)
+	}
+	if want := "Alice failed 50% of 4 calls in the last 60 minutes"; !strings.HasPrefix(fired.Message, want) {
+		t.Errorf("message = %q, want it to start with %q", fired.Message, want)
+	}
+	if got := posts(); len(got) != 1 || got[0].event != models.WebhookEventAlertFiring || got[0].payload.Alert == nil || got[0].payload.Alert.ID != fired.ID {
+		t.Errorf("webhook posts = %+v, want the firing alert", got)
+	}
+	if got := engineEventTypes(events); len(got) != 1 || got[0] != "agent_alert" {
+		t.Errorf("engine events = %v, want one agent_alert", got)
+	}
+
+	// A firing alert is not repeated
+	if changed := de.EvaluateAlerts(); len(changed) != 0 {
+		t.Errorf("second pass changed %+v, want nothing while the alert fires", changed)
+	}
+
+	// Recovered: 2 of 8 calls failed
+	clock.Advance(5 * time.Minute)
+	insertCalls(t, de, discussion.ID, alice, "success", 4, 100, clock.Now())
+	changed = de.EvaluateAlerts()
+	if len(changed) != 1 || changed[0].ID != fired.ID || changed[0].State != models.AlertStateResolved || changed[0].ResolvedAt == nil || !changed[0].ResolvedAt.Equal(clock.Now()) {
+		t.Fatalf("recovery changed %+v, want the alert resolved now", changed)
+	}
+	if got := posts(); len(got) != 2 || got[1].event != models.WebhookEventAlertResolved || !strings.HasPrefix(got[1].payload.Text, "Resolved: ") {
+		t.Errorf("webhook posts = %+v, want a resolution notice", got)
+	}
+	if got := engineEventTypes(events); len(got) != 1 || got[0] != "agent_alert_resolved" {
+		t.Errorf("engine events = %v, want one agent_alert_resolved", got)
+	}
+
+	// Failing again within the cooldown of the first alert is held back
+	insertCalls(t, de, discussion.ID, alice, "error", 6, 100, clock.Now())
+	if changed := de.EvaluateAlerts(); len(changed) != 0 {
+		t.Errorf("pass within the cooldown changed %+v", changed)
+	}
+	clock.Advance(30 * time.Minute)
+	changed = de.EvaluateAlerts()
+	if len(changed) != 1 || changed[0].ID == fired.ID || changed[0].State != models.AlertStateFiring || changed[0].Calls != 14 {
+		t.Fatalf("pass after the cooldown changed %+v, want a new alert over 14 calls", changed)
+	}
+
+	// Calls aging out of the window resolve the alert without new traffic
+	clock.Advance(2 * time.Hour)
+	changed = de.EvaluateAlerts()
+	if len(changed) != 1 || changed[0].State != models.AlertStateResolved {
+		t.Errorf("pass after the window changed %+v, want the alert resolved", changed)
+	}
+
+	alerts, err := de.db.GetAlerts("", 10)
+	if err != nil {
+		t.Fatalf("GetAlerts: %v", err)
+	}
+	if len(alerts) != 2 || alerts[0].State != models.AlertStateResolved || alerts[1].State != models.AlertStateResolved {
+		t.Errorf("recorded alerts = %+v, want two resolved alerts", alerts)
+	}
+}
+
+func TestLatencyAlert(t *testing.T) {
+	de := newTestEngine(t)
+	clock := newFakeClock(de)
+	setAlertRules(t, de, models.AlertRulesConfig{Enabled: true, WindowMinutes: 60, MinCalls: 3, LatencyMs: 2000})
+
+	alice := insertTestAgent(t, de, "Alice", "http://127.0.0.1:1")
+	discussion := insertTestDiscussion(t, de, "completed", alice)
+	// Failed calls count toward neither the latency nor, with its rule off,
+	// the failure rate
+	insertCalls(t, de, discussion.ID, alice, "success", 3, 3000, clock.Now())
+	insertCalls(t, de, discussion.ID, alice, "timeout", 5, 60000, clock.Now())
+
+	changed := de.EvaluateAlerts()
+	if len(changed) != 1 || changed[0].Metric != models.AlertMetricLatency || changed[0].Value != 3000 {
+		t.Fatalf("changed %+v, want one latency alert at 3000ms", changed)
+	}
+	if want := "Alice averaged 3.0s per successful call in the last 60 minutes (threshold 2.0s)"; changed[0].Message != want {
+		t.Errorf("message = %q, want %q", changed[0].Message, want)
+	}
+
+	setAlertRules(t, de, models.AlertRulesConfig{WindowMinutes: 60, MinCalls: 3, LatencyMs: 2000})
+	clock.Advance(2 * time.Hour)
+	if changed := de.EvaluateAlerts(); changed != nil {
+		t.Errorf("disabled rules changed %+v", changed)
+	}
+}
+
+func TestAlertRulesFallBackToDefaults(t *testing.T) {
+	de := newTestEngine(t)
+	if got := de.AlertRules(); got != models.DefaultAlertRules() {
+		t.Errorf("rules without settings = %+v, want the defaults", got)
+	}
+	setAlertRules(t, de, models.AlertRulesConfig{Enabled: true, WindowMinutes: 0, MinCalls: 1})
+	if got := de.AlertRules(); got != models.DefaultAlertRules() {
+		t.Errorf("rules after saving invalid ones = %+v, want the defaults", got)
+	}
+}
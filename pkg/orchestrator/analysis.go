@@ -0,0 +1,300 @@
+package orchestrator
+
+import (
+	"context"
+	"court-table-ai/pkg/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// JobTypeAnalyze identifies background consensus analysis jobs
+const JobTypeAnalyze = "analyze"
+
+// analysisSystemPrompt replaces the analyst's own system prompt so it maps
+// the debate rather than joining it
+const analysisSystemPrompt = "You analyze debate transcripts and record where each participant stands on the key points. " +
+	"Reply with a JSON object only, with no commentary and no code fences."
+
+// maxAnalysisRepairs is how many times a malformed analysis reply is sent
+// back for correction before its text is stored as it is
+const maxAnalysisRepairs = 1
+
+// rawAnalysisPoint is a key point as returned by the analyst before
+// validation
+type rawAnalysisPoint struct {
+	Point        string   `json:"point"`
+	Support      []string `json:"support"`
+	Oppose       []string `json:"oppose"`
+	NotAddressed []string `json:"not_addressed"`
+}
+
+// AnalyzeDiscussion runs a consensus analysis of a finished discussion with
+// the given analyst and stores it in place of the previous one
+func (de *DebateEngine) AnalyzeDiscussion(ctx context.Context, discussionID, analystID int64) (*models.DiscussionAnalysis, error) {
+	discussion, logs, err := de.GetDiscussionStatus(discussionID)
+	if err != nil {
+		return nil, err
+	}
+	analyst, err := de.db.GetAgent(analystID)
+	if err != nil {
+		return nil, fmt.Errorf("analyst agent %d not found: %w", analystID, err)
+	}
+
+	agents := make([]*models.Agent, 0, len(discussion.AgentIDs))
+	for _, id := range discussion.AgentIDs {
+		agent, err := de.db.GetAgent(id)
+		if err != nil {
+			return nil, fmt.Errorf("participant %d not found: %w", id, err)
+		}
+		agents = append(agents, agent)
+	}
+
+	analysis := de.analyze(ctx, discussion, agents, analyst, logTranscript(logs, agents))
+	if analysis.Status == models.AnalysisFailed {
+		return analysis, errors.New(analysis.Error)
+	}
+	return analysis, nil
+}
+
+// analyze asks analyst for the key points of the debate and each agent's
+// position on them, then stores and broadcasts the result. A malformed reply
+// is sent back once for repair and otherwise kept as raw text; a failed call
+// is stored as a failed analysis and noted in the discussion log, unless ctx
+// was cancelled, which leaves the previous analysis in place.
+func (de *DebateEngine) analyze(ctx context.Context, discussion *models.Discussion, agents []*models.Agent, analyst *models.Agent, transcript string) *models.DiscussionAnalysis {
+	temperature := 0.0
+	opts := CallOptions{SystemPrompt: analysisSystemPrompt, Temperature: &temperature}
+	prompt := buildAnalysisPrompt(discussion, agents, transcript)
+
+	analysis := &models.DiscussionAnalysis{DiscussionID: discussion.ID, AnalystID: analyst.ID}
+	for {
+		analysis.Attempts++
+		response, err := de.agentClient.CallAgentWithOptions(ctx, analyst, prompt, "", opts)
+		if err == nil && !response.Success {
+			err = errors.New(response.ErrorMessage)
+		}
+		if err != nil {
+			analysis.Status = models.AnalysisFailed
+			analysis.Error = err.Error()
+			// A stopped debate or cancelled job gives up the analysis on purpose
+			if ctx.Err() != nil {
+				return analysis
+			}
+			break
+		}
+
+		points, unknown, err := parseAnalysis(response.Content, agents)
+		if err == nil {
+			analysis.Status = models.AnalysisSuccess
+			analysis.Points = points
+			analysis.UnknownNames = unknown
+			analysis.RawText = ""
+			analysis.Error = ""
+			break
+		}
+		analysis.Status = models.AnalysisRaw
+		analysis.RawText = response.Content
+		analysis.Error = fmt.Sprintf("invalid analysis reply: %v", err)
+		if analysis.Attempts > maxAnalysisRepairs {
+			break
+		}
+		prompt = buildAnalysisRepairPrompt(response.Content, err)
+	}
+
+	if analysis.Status != models.AnalysisSuccess {
+		log.Printf("Analysis of discussion %d by %s ended as %s: %s", discussion.ID, analyst.Name, analysis.Status, analysis.Error)
+	}
+	if analysis.Status == models.AnalysisFailed {
+		de.insertSystemLog(discussion.ID, models.LogTypeSystem, "error",
+			fmt.Sprintf("%s could not analyze the discussion: %s", analyst.Name, analysis.Error),
+			models.JSONMap{"alert": "analysis", "analyst_id": strconv.FormatInt(analyst.ID, 10)})
+	}
+	if err := de.db.SaveDiscussionAnalysis(analysis); err != nil {
+		log.Printf("Failed to save analysis of discussion %d: %v", discussion.ID, err)
+		return analysis
+	}
+	de.touch(discussion.ID)
+	de.broadcast(discussion.ID, analysis)
+	return analysis
+}
+
+// buildAnalysisPrompt asks for the key points of the transcript as JSON
+func buildAnalysisPrompt(discussion *models.Discussion, agents []*models.Agent, transcript string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Debate topic: %s\n\n", promptTopic(discussion.Topic))
+	b.WriteString("Participants:\n")
+	for _, agent := range agents {
+		fmt.Fprintf(&b, "- %s\n", agent.Name)
+	}
+	fmt.Fprintf(&b, "\nFull transcript:\n%s\n\n", fence(transcript))
+	fmt.Fprintf(&b, "List the key points the debate turned on, at most %d. ", models.MaxAnalysisPoints)
+	b.WriteString("For each point, put every participant, by the exact name listed above, in one of ")
+	b.WriteString("\"support\" (argued for the point), \"oppose\" (argued against it) or ")
+	b.WriteString("\"not_addressed\" (did not take a position on it).\n\n")
+	b.WriteString("Respond with a single JSON object and nothing else, in this format:\n")
+	b.WriteString(`{"points": [{"point": "<one sentence>", "support": ["<name>", ...], "oppose": ["<name>", ...], "not_addressed": ["<name>", ...]}]}`)
+	b.WriteString("\n\n")
+	fmt.Fprintf(&b, "Write the points in %s.\n", discussion.LanguageName())
+	return b.String()
+}
+
+// buildAnalysisRepairPrompt asks the analyst to correct a malformed reply
+func buildAnalysisRepairPrompt(reply string, parseErr error) string {
+	return fmt.Sprintf("Your previous reply could not be used (%v):\n\n%s\n\n"+
+		"Reply again with only a JSON object with a \"points\" array of objects with the fields "+
+		"\"point\", \"support\", \"oppose\" and \"not_addressed\".", parseErr, reply)
+}
+
+// parseAnalysis decodes and validates an analysis reply. It tolerates code
+// fences, surrounding prose and a bare array of points. Names are matched to
+// agents ignoring case; names of no participant are returned separately, and
+// an agent listed under both support and oppose, or not at all, has not
+// addressed the point.
+func parseAnalysis(reply string, agents []*models.Agent) (models.JSONSlice[models.AnalysisPoint], models.JSONSlice[string], error) {
+	raw, err := decodeAnalysis(reply)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byName := make(map[string]int64, len(agents))
+	for _, agent := range agents {
+		byName[strings.ToLower(strings.TrimSpace(agent.Name))] = agent.ID
+	}
+
+	points := models.JSONSlice[models.AnalysisPoint]{}
+	unknown := models.JSONSlice[string]{}
+	seenUnknown := make(map[string]bool)
+	resolve := func(names []string) map[int64]bool {
+		ids := make(map[int64]bool)
+		for _, name := range names {
+			name = strings.TrimSpace(name)
+			if id, ok := byName[strings.ToLower(name)]; ok {
+				ids[id] = true
+			} else if name != "" && !seenUnknown[strings.ToLower(name)] {
+				seenUnknown[strings.ToLower(name)] = true
+				unknown = append(unknown, name)
+			}
+		}
+		return ids
+	}
+
+	for _, rp := range raw {
+		text := strings.Join(strings.Fields(rp.Point), " ")
+		if text == "" {
+			continue
+		}
+		if utf8.RuneCountInString(text) > models.MaxAnalysisPointLength {
+			text = string([]rune(text)[:models.MaxAnalysisPointLength])
+		}
+
+		support, oppose := resolve(rp.Support), resolve(rp.Oppose)
+		resolve(rp.NotAddressed)
+		point := models.AnalysisPoint{Point: text, Positions: make(map[int64]string, len(agents))}
+		for _, agent := range agents {
+			switch {
+			case support[agent.ID] && !oppose[agent.ID]:
+				point.Positions[agent.ID] = models.AnalysisSupport
+			case oppose[agent.ID] && !support[agent.ID]:
+				point.Positions[agent.ID] = models.AnalysisOppose
+			default:
+				point.Positions[agent.ID] = models.AnalysisNotAddressed
+			}
+		}
+		points = append(points, point)
+		if len(points) == models.MaxAnalysisPoints {
+			break
+		}
+	}
+
+	if len(points) == 0 {
+		return nil, nil, errors.New("no key point had any text")
+	}
+	return points, unknown, nil
+}
+
+// decodeAnalysis finds and decodes the JSON in an analysis reply
+func decodeAnalysis(reply string) ([]rawAnalysisPoint, error) {
+	text := strings.TrimSpace(reply)
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+	text = strings.TrimSpace(text)
+
+	if object := firstJSONObject(text); object != "" {
+		var wrapped struct {
+			Points []rawAnalysisPoint `json:"points"`
+		}
+		err := json.Unmarshal([]byte(object), &wrapped)
+		if err == nil && wrapped.Points != nil {
+			return wrapped.Points, nil
+		}
+		// Anything but a bare array of points must be the wrapper object
+		if strings.HasPrefix(text, "{") {
+			if err != nil {
+				return nil, fmt.Errorf("malformed JSON: %w", err)
+			}
+			return nil, errors.New("no \"points\" array found")
+		}
+	}
+
+	start := strings.Index(text, "[")
+	end := strings.LastIndex(text, "]")
+	if start < 0 || end < start {
+		return nil, errors.New("no JSON object found")
+	}
+	var raw []rawAnalysisPoint
+	if err := json.Unmarshal([]byte(text[start:end+1]), &raw); err != nil {
+		return nil, fmt.Errorf("malformed JSON: %w", err)
+	}
+	return raw, nil
+}
+
+// logTranscript renders the successful agent responses of logs as the
+// debate context, for analyses run after the debate
+func logTranscript(logs []*models.DiscussionLog, agents []*models.Agent) string {
+	names := make(map[int64]string, len(agents))
+	for _, agent := range agents {
+		names[agent.ID] = agent.Name
+	}
+
+	debateContext := &turnContext{}
+	turns := make(map[int64]int)
+	current := 0
+	for _, l := range logs {
+		if l.IsModerator || l.IsSystem() || l.LogType != models.LogTypeResponse {
+			continue
+		}
+		round := replayRound(l, turns, current)
+		if round > current {
+			current = round
+		}
+		if l.Status != "success" {
+			continue
+		}
+		name, ok := names[l.AgentID]
+		if !ok {
+			name = "#" + strconv.FormatInt(l.AgentID, 10)
+		}
+		debateContext.add(round, name, l.AgentID, l.Content)
+	}
+	return debateContext.Full()
+}
+
+// AnalysisMatrix builds the agreement matrix of an analysis with the
+// discussion's agents as columns, in speaking order
+func (de *DebateEngine) AnalysisMatrix(discussion *models.Discussion, analysis *models.DiscussionAnalysis) *models.AgreementMatrix {
+	agents := make([]models.AnalysisAgent, 0, len(discussion.AgentIDs))
+	for _, id := range discussion.AgentIDs {
+		name := "#" + strconv.FormatInt(id, 10)
+		if agentName, err := de.db.GetAgentName(id); err == nil {
+			name = agentName
+		}
+		agents = append(agents, models.AnalysisAgent{ID: id, Name: name})
+	}
+	return analysis.Matrix(agents)
+}
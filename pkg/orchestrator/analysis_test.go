@@ -0,0 +1,221 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+// newAnalystProvider returns a provider answering with replies in turn, the
+// last one repeated, and the user prompts it received
+func newAnalystProvider(t *testing.T, replies ...string) (*httptest.Server, func() []string) {
+	t.Helper()
+	var (
+		mu      sync.Mutex
+		prompts []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("provider got a body that is not JSON: %v", err)
+		}
+		mu.Lock()
+		prompts = append(prompts, userMessage(body))
+		reply := replies[min(len(prompts), len(replies))-1]
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":%q},"finish_reason":"stop"}]}`, reply)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), prompts...)
+	}
+}
+
+const (
+	validAnalysis = `{"points": [
+		{"point": "Spaces keep diffs aligned", "support": ["Alice"], "oppose": ["Bob"], "not_addressed": []},
+		{"point": "Tabs  let readers\n choose a width", "support": ["Alice", "Bob"], "oppose": [], "not_addressed": []}]}`
+	mismatchedAnalysis = "```json\n" + `{"points": [
+		{"point": "Spaces keep diffs aligned", "support": ["alice", "Carol"], "oppose": ["Bob", "Alice"]},
+		{"point": "Editors disagree", "support": ["Dave"], "not_addressed": ["carol"]}]}` + "\n```"
+	malformedAnalysis = `{"points": [{"point": "Spaces keep diffs aligned", "support": ["Alice"]`
+)
+
+func TestParseAnalysis(t *testing.T) {
+	alice := &models.Agent{ID: 1, Name: "Alice"}
+	bob := &models.Agent{ID: 2, Name: "Bob"}
+	agents := []*models.Agent{alice, bob}
+	type positions map[int64]string
+	s, o, n := models.AnalysisSupport, models.AnalysisOppose, models.AnalysisNotAddressed
+
+	tests := []struct {
+		name        string
+		reply       string
+		wantPoints  []string
+		wantPos     []positions
+		wantUnknown []string
+		wantErr     string
+	}{
+		{"valid", validAnalysis,
+			[]string{"Spaces keep diffs aligned", "Tabs let readers choose a width"},
+			[]positions{{1: s, 2: o}, {1: s, 2: s}}, nil, ""},
+		// A name listed on both sides, or nowhere, has not addressed the point
+		{"name mismatch", mismatchedAnalysis,
+			[]string{"Spaces keep diffs aligned", "Editors disagree"},
+			[]positions{{1: n, 2: o}, {1: n, 2: n}}, []string{"Carol", "Dave"}, ""},
+		{"bare array in prose", `Here you go: [{"point": "Spaces", "support": ["Bob"]}] Hope it helps.`,
+			[]string{"Spaces"}, []positions{{1: n, 2: s}}, nil, ""},
+		{"malformed", malformedAnalysis, nil, nil, nil, "malformed JSON"},
+		{"no points", `{"summary": "They agreed"}`, nil, nil, nil, `no "points" array`},
+		{"no JSON", "Alice and Bob mostly agreed.", nil, nil, nil, "no JSON object"},
+		{"empty points", `{"points": [{"point": "  ", "support": ["Alice"]}]}`, nil, nil, nil, "no key point had any text"},
+	}
+	for _, tt := range tests {
+		points, unknown, err := parseAnalysis(tt.reply, agents)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: parseAnalysis = %v, want an error containing %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: parseAnalysis: %v", tt.name, err)
+			continue
+		}
+		if len(points) != len(tt.wantPoints) {
+			t.Errorf("%s: %d points, want %d", tt.name, len(points), len(tt.wantPoints))
+			continue
+		}
+		for i, p := range points {
+			if p.Point != tt.wantPoints[i] || fmt.Sprint(p.Positions) != fmt.Sprint(tt.wantPos[i]) {
+				t.Errorf("%s: point %d = %q %v, want %q %v", tt.name, i, p.Point, p.Positions, tt.wantPoints[i], tt.wantPos[i])
+			}
+		}
+		if strings.Join(unknown, ",") != strings.Join(tt.wantUnknown, ",") {
+			t.Errorf("%s: unknown names = %v, want %v", tt.name, unknown, tt.wantUnknown)
+		}
+	}
+
+	// Long replies are cut to the limits
+	var many []string
+	for i := 0; i < models.MaxAnalysisPoints+5; i++ {
+		many = append(many, fmt.Sprintf(`{"point": %q}`, strings.Repeat("p", models.MaxAnalysisPointLength+i)))
+	}
+	points, _, err := parseAnalysis(`{"points": [`+strings.Join(many, ",")+`]}`, agents)
+	if err != nil || len(points) != models.MaxAnalysisPoints || len(points[0].Point) != models.MaxAnalysisPointLength {
+		t.Errorf("parseAnalysis of a long reply = %d points, %v", len(points), err)
+	}
+}
+
+func TestAnalyzeDiscussion(t *testing.T) {
+	tests := []struct {
+		name         string
+		replies      []string
+		wantStatus   string
+		wantAttempts int
+		wantPoints   int
+		wantUnknown  []string
+		wantRaw      string
+	}{
+		{"valid", []string{validAnalysis}, models.AnalysisSuccess, 1, 2, nil, ""},
+		{"name mismatch", []string{mismatchedAnalysis}, models.AnalysisSuccess, 1, 2, []string{"Carol", "Dave"}, ""},
+		{"repaired", []string{malformedAnalysis, validAnalysis}, models.AnalysisSuccess, 2, 2, nil, ""},
+		{"malformed", []string{malformedAnalysis, "Alice and Bob mostly agreed."}, models.AnalysisRaw, 2, 0, nil, "Alice and Bob mostly agreed."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			de := newTestEngine(t)
+			server, prompts := newAnalystProvider(t, tt.replies...)
+			alice := insertTestAgent(t, de, "Alice", server.URL)
+			bob := insertTestAgent(t, de, "Bob", server.URL)
+			discussion := insertTestDiscussion(t, de, "completed", alice, bob)
+
+			analysis, err := de.AnalyzeDiscussion(context.Background(), discussion.ID, alice.ID)
+			if err != nil {
+				t.Fatalf("AnalyzeDiscussion: %v", err)
+			}
+			stored, err := de.db.GetDiscussionAnalysis(discussion.ID)
+			if err != nil || stored == nil {
+				t.Fatalf("GetDiscussionAnalysis = %v, %v", stored, err)
+			}
+			for _, a := range []*models.DiscussionAnalysis{analysis, stored} {
+				if a.Status != tt.wantStatus || a.Attempts != tt.wantAttempts || len(a.Points) != tt.wantPoints || a.RawText != tt.wantRaw {
+					t.Errorf("analysis = %+v, want %s after %d attempts with %d points", a, tt.wantStatus, tt.wantAttempts, tt.wantPoints)
+				}
+				if strings.Join(a.UnknownNames, ",") != strings.Join(tt.wantUnknown, ",") {
+					t.Errorf("unknown names = %v, want %v", a.UnknownNames, tt.wantUnknown)
+				}
+			}
+			if tt.wantStatus == models.AnalysisRaw && !strings.Contains(stored.Error, "invalid analysis reply") {
+				t.Errorf("error = %q, want the reason the reply was unusable", stored.Error)
+			}
+
+			sent := prompts()
+			if len(sent) != tt.wantAttempts || !strings.Contains(sent[0], "- Alice\n- Bob") {
+				t.Fatalf("prompts = %q, want %d naming the participants", sent, tt.wantAttempts)
+			}
+			// The repair prompt quotes the reply it asks to correct
+			if tt.wantAttempts == 2 && !strings.Contains(sent[1], malformedAnalysis) {
+				t.Errorf("repair prompt = %q, want it to quote the malformed reply", sent[1])
+			}
+		})
+	}
+}
+
+func TestAnalyzeDiscussionFails(t *testing.T) {
+	de := newTestEngine(t)
+	server, _ := newFlakyProvider(t, "", http.StatusBadRequest)
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+	discussion := insertTestDiscussion(t, de, "completed", alice)
+
+	analysis, err := de.AnalyzeDiscussion(context.Background(), discussion.ID, alice.ID)
+	if err == nil || analysis.Status != models.AnalysisFailed {
+		t.Fatalf("AnalyzeDiscussion = %+v, %v, want a failed analysis", analysis, err)
+	}
+	logs, err := de.db.GetDiscussionLogs(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	if len(logs) != 1 || logs[0].Metadata["alert"] != "analysis" || !strings.Contains(logs[0].Content, "Alice could not analyze") {
+		t.Errorf("logs = %+v, want one noting the failed analysis", logs)
+	}
+}
+
+func TestDebateIsAnalyzedOnCompletion(t *testing.T) {
+	de := newTestEngine(t)
+	// Turns and the analysis share the provider, which always answers with
+	// the analysis
+	server, prompts := newAnalystProvider(t, validAnalysis)
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+	bob := insertTestAgent(t, de, "Bob", server.URL)
+
+	d := runToEnd(t, de, []*models.Agent{alice, bob}, 1, models.DiscussionSettings{Analyze: true})
+	analysis, err := de.db.GetDiscussionAnalysis(d.ID)
+	if err != nil || analysis == nil {
+		t.Fatalf("GetDiscussionAnalysis = %v, %v", analysis, err)
+	}
+	if analysis.Status != models.AnalysisSuccess || analysis.AnalystID != alice.ID {
+		t.Errorf("analysis = %+v, want a successful one by the first speaker", analysis)
+	}
+	if sent := prompts(); !strings.Contains(sent[len(sent)-1], "Full transcript") {
+		t.Errorf("last prompt = %q, want the analysis request", sent[len(sent)-1])
+	}
+
+	matrix := de.AnalysisMatrix(d, analysis)
+	if len(matrix.Agents) != 2 || matrix.Agents[0].Name != "Alice" || len(matrix.Rows) != 2 {
+		t.Fatalf("matrix = %+v", matrix)
+	}
+	if got := matrix.Rows[0].Positions; got[0] != models.AnalysisSupport || got[1] != models.AnalysisOppose {
+		t.Errorf("first row = %v, want Alice supporting and Bob opposing", got)
+	}
+}
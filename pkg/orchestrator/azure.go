@@ -0,0 +1,234 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"court-table-ai/pkg/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// azureFilterResult is one category of an Azure content filter verdict
+type azureFilterResult struct {
+	Filtered bool   `json:"filtered"`
+	Severity string `json:"severity"`
+}
+
+// azureErrorBody is the error Azure OpenAI returns, with the content filter
+// verdict when the prompt was blocked
+type azureErrorBody struct {
+	Error struct {
+		Code       string `json:"code"`
+		Message    string `json:"message"`
+		InnerError struct {
+			Code                string                       `json:"code"`
+			ContentFilterResult map[string]azureFilterResult `json:"content_filter_result"`
+		} `json:"innererror"`
+	} `json:"error"`
+}
+
+// azureChoices reads the content filter verdict on the replies of a
+// successful Azure chat completion
+type azureChoices struct {
+	Choices []struct {
+		FinishReason         string                       `json:"finish_reason"`
+		ContentFilterResults map[string]azureFilterResult `json:"content_filter_results"`
+	} `json:"choices"`
+}
+
+// azureEndpoint builds the chat completions URL of an Azure OpenAI agent
+// from its resource URL, with ModelName as the deployment. The provider URL
+// may be the bare resource or a full deployment URL; the API version is the
+// agent's, else the URL's ?api-version=, else DefaultAzureAPIVersion.
+func azureEndpoint(agent *models.Agent, path string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(agent.ProviderURL))
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid Azure OpenAI URL %q", agent.ProviderURL)
+	}
+
+	version := agent.APIVersion
+	if version == "" {
+		version = u.Query().Get("api-version")
+	}
+	if version == "" {
+		version = models.DefaultAzureAPIVersion
+	}
+
+	endpoint := url.URL{
+		Scheme:   u.Scheme,
+		Host:     u.Host,
+		Path:     "/openai/deployments/" + agent.ModelName + path,
+		RawQuery: url.Values{"api-version": {version}}.Encode(),
+	}
+	return endpoint.String(), nil
+}
+
+// callAzure calls an Azure OpenAI chat completions deployment. The request
+// is OpenAI's; Azure answers with the deployment's model whatever it names.
+func (ac *AgentClient) callAzure(ctx context.Context, agent *models.Agent, prompt string, contextStr string, opts CallOptions) (*models.AgentResponse, error) {
+	userMessage := prompt
+	if opts.SystemPrompt != "" && contextStr != "" {
+		userMessage = fmt.Sprintf("Previous context from other agents:\n%s\n\nYour task:\n%s", contextStr, prompt)
+	}
+	reqBody := OpenAIRequest{
+		Model: agent.ModelName,
+		Messages: []Message{
+			{Role: "system", Content: opts.systemPrompt(agent, debateInstructions(contextStr))},
+			{Role: "user", Content: userMessage},
+		},
+		Stream:      opts.streaming(),
+		Temperature: opts.Temperature,
+		MaxTokens:   opts.MaxTokens,
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return azureFailure(fmt.Errorf("failed to marshal request: %w", err))
+	}
+	endpoint, err := azureEndpoint(agent, "/chat/completions")
+	if err != nil {
+		return azureFailure(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return azureFailure(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	ac.setAuthHeaders(req, agent)
+
+	resp, err := ac.client.Do(req)
+	if err != nil {
+		return azureFailure(err)
+	}
+	defer resp.Body.Close()
+
+	if opts.streaming() && resp.StatusCode >= 200 && resp.StatusCode <= 299 && isStreamResponse(resp) {
+		response, err := readOpenAIStream(ctx, resp.Body, opts)
+		if err != nil {
+			return response, &probeError{err: err}
+		}
+		return response, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return azureFailure(fmt.Errorf("failed to read response: %w", err))
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if message := azureFilterError(body); message != "" {
+			return &models.AgentResponse{Success: false, ErrorMessage: message}, errors.New(message)
+		}
+		return azureFailure(fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body)))
+	}
+
+	response, err := parseOpenAIBody(body)
+	if err != nil {
+		// A reply withheld by the filter comes back as a 200 without content
+		if message := azureFilteredReply(body); message != "" {
+			return &models.AgentResponse{Success: false, ErrorMessage: message}, errors.New(message)
+		}
+		return azureFailure(err)
+	}
+	return response, nil
+}
+
+// azureFailure builds the failed response returned by callAzure
+func azureFailure(err error) (*models.AgentResponse, error) {
+	return &models.AgentResponse{
+		Success:      false,
+		ErrorMessage: fmt.Sprintf("Failed to call Azure OpenAI: %v", err),
+	}, err
+}
+
+// azureFilterError describes a prompt rejected by Azure's content filter,
+// or returns "" for any other error body
+func azureFilterError(body []byte) string {
+	var reply azureErrorBody
+	if json.Unmarshal(body, &reply) != nil || reply.Error.Code != "content_filter" {
+		return ""
+	}
+	message := "Azure content filter blocked the prompt"
+	if categories := filteredCategories(reply.Error.InnerError.ContentFilterResult); categories != "" {
+		message += ": " + categories
+	}
+	return message
+}
+
+// azureFilteredReply describes a reply withheld by Azure's content filter,
+// or returns "" when the reply was not filtered
+func azureFilteredReply(body []byte) string {
+	var reply azureChoices
+	if json.Unmarshal(body, &reply) != nil || len(reply.Choices) == 0 || reply.Choices[0].FinishReason != "content_filter" {
+		return ""
+	}
+	message := "Azure content filter blocked the reply"
+	if categories := filteredCategories(reply.Choices[0].ContentFilterResults); categories != "" {
+		message += ": " + categories
+	}
+	return message
+}
+
+// filteredCategories lists the categories a content filter verdict blocked,
+// with their severity, e.g. "hate (medium), violence (high)"
+func filteredCategories(results map[string]azureFilterResult) string {
+	var categories []string
+	for name, result := range results {
+		if !result.Filtered {
+			continue
+		}
+		category := strings.ReplaceAll(name, "_", " ")
+		if result.Severity != "" && result.Severity != "safe" {
+			category += " (" + result.Severity + ")"
+		}
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return strings.Join(categories, ", ")
+}
+
+// pingAzure sends the deployment a one-token completion, which checks the
+// resource, deployment, API version and key at once
+func (ac *AgentClient) pingAzure(ctx context.Context, agent *models.Agent) error {
+	jsonData, err := json.Marshal(OpenAIRequest{
+		Model:     agent.ModelName,
+		Messages:  []Message{{Role: "user", Content: "hi"}},
+		MaxTokens: 1,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create ping request: %v", err)
+	}
+	endpoint, err := azureEndpoint(agent, "/chat/completions")
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	ac.setAuthHeaders(req, agent)
+
+	resp, err := ac.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ping failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxProbeBody))
+		// The filter judging "hi" still proves the deployment answers
+		if azureFilterError(body) != "" {
+			return nil
+		}
+		return fmt.Errorf("ping returned status %d", resp.StatusCode)
+	}
+	return nil
+}
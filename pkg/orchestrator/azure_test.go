@@ -0,0 +1,145 @@
+package orchestrator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+func TestAzureEndpoint(t *testing.T) {
+	tests := []struct {
+		name       string
+		url        string
+		apiVersion string
+		want       string
+	}{
+		{"bare resource", "https://res.openai.azure.com", "",
+			"https://res.openai.azure.com/openai/deployments/gpt4o-prod/chat/completions?api-version=" + models.DefaultAzureAPIVersion},
+		{"version in the URL", "https://res.openai.azure.com/?api-version=2024-10-21", "",
+			"https://res.openai.azure.com/openai/deployments/gpt4o-prod/chat/completions?api-version=2024-10-21"},
+		// The deployment always comes from the model name
+		{"full deployment URL", "https://res.openai.azure.com/openai/deployments/other/chat/completions?api-version=2024-10-21", "",
+			"https://res.openai.azure.com/openai/deployments/gpt4o-prod/chat/completions?api-version=2024-10-21"},
+		{"agent version wins", "https://res.openai.azure.com?api-version=2024-10-21", "2025-01-01-preview",
+			"https://res.openai.azure.com/openai/deployments/gpt4o-prod/chat/completions?api-version=2025-01-01-preview"},
+	}
+	for _, tt := range tests {
+		agent := &models.Agent{ProviderURL: tt.url, ModelName: "gpt4o-prod", APIVersion: tt.apiVersion}
+		got, err := azureEndpoint(agent, "/chat/completions")
+		if err != nil || got != tt.want {
+			t.Errorf("%s: azureEndpoint = %s, %v, want %s", tt.name, got, err, tt.want)
+		}
+	}
+	if _, err := azureEndpoint(&models.Agent{ProviderURL: "res.openai.azure.com", ModelName: "gpt4o-prod"}, "/chat/completions"); err == nil {
+		t.Error("azureEndpoint accepted a URL without a scheme")
+	}
+}
+
+// azureRequest is what a fake Azure deployment saw of a request
+type azureRequest struct {
+	path, apiVersion, apiKey, authorization string
+}
+
+// newAzureServer answers every request with status and body and records the
+// requests it got
+func newAzureServer(t *testing.T, status int, body string) (*httptest.Server, func() []azureRequest) {
+	t.Helper()
+	var (
+		mu       sync.Mutex
+		requests []azureRequest
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, azureRequest{r.URL.Path, r.URL.Query().Get("api-version"), r.Header.Get("api-key"), r.Header.Get("Authorization")})
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []azureRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]azureRequest(nil), requests...)
+	}
+}
+
+func azureAgent(url string) *models.Agent {
+	return &models.Agent{Name: "Azure", ProviderType: models.ProviderAzure, ProviderURL: url, APIToken: "azure-key", ModelName: "gpt4o-prod", APIVersion: "2024-10-21", TimeoutSeconds: 10}
+}
+
+func TestCallAzure(t *testing.T) {
+	server, requests := newAzureServer(t, http.StatusOK,
+		`{"model":"gpt-4o","choices":[{"message":{"role":"assistant","content":"Spaces."},"finish_reason":"stop","content_filter_results":{"hate":{"filtered":false,"severity":"safe"}}}],"usage":{"prompt_tokens":9,"completion_tokens":2}}`)
+
+	response, err := NewAgentClient(nil).CallAgent(context.Background(), azureAgent(server.URL), "Tabs or spaces?", "")
+	if err != nil || !response.Success || response.Content != "Spaces." || response.CompletionTokens != 2 {
+		t.Fatalf("CallAgent = %+v, %v", response, err)
+	}
+	want := azureRequest{"/openai/deployments/gpt4o-prod/chat/completions", "2024-10-21", "azure-key", ""}
+	if got := requests(); len(got) != 1 || got[0] != want {
+		t.Errorf("requests = %+v, want one authenticated with api-key: %+v", got, want)
+	}
+}
+
+func TestCallAzureErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"filtered prompt", http.StatusBadRequest,
+			`{"error":{"code":"content_filter","message":"The response was filtered due to the prompt triggering Azure OpenAI's content management policy.","innererror":{"code":"ResponsibleAIPolicyViolation","content_filter_result":{"hate":{"filtered":true,"severity":"medium"},"self_harm":{"filtered":false,"severity":"safe"},"violence":{"filtered":true,"severity":"high"}}}}}`,
+			"Azure content filter blocked the prompt: hate (medium), violence (high)"},
+		{"filtered reply", http.StatusOK,
+			`{"choices":[{"message":{"role":"assistant"},"finish_reason":"content_filter","content_filter_results":{"sexual":{"filtered":true,"severity":"medium"}}}]}`,
+			"Azure content filter blocked the reply: sexual (medium)"},
+		{"filter without categories", http.StatusBadRequest, `{"error":{"code":"content_filter","message":"filtered"}}`,
+			"Azure content filter blocked the prompt"},
+		{"other error", http.StatusNotFound, `{"error":{"code":"DeploymentNotFound","message":"The API deployment for this resource does not exist."}}`,
+			"Failed to call Azure OpenAI: API returned status 404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := newAzureServer(t, tt.status, tt.body)
+			response, err := NewAgentClient(nil).CallAgent(context.Background(), azureAgent(server.URL), "Tabs or spaces?", "")
+			if err == nil || response.Success {
+				t.Fatalf("CallAgent = %+v, want a failure", response)
+			}
+			// A filter verdict reads as a sentence rather than the raw JSON
+			if !strings.HasPrefix(response.ErrorMessage, tt.wantErr) || (strings.Contains(tt.wantErr, "filter") && strings.Contains(response.ErrorMessage, "{")) {
+				t.Errorf("ErrorMessage = %q, want %q", response.ErrorMessage, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPingAzure(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr bool
+	}{
+		{"answers", http.StatusOK, `{"choices":[{"message":{"role":"assistant","content":"Hi"}}]}`, false},
+		// The filter judging the ping still proves the deployment answers
+		{"filtered", http.StatusBadRequest, `{"error":{"code":"content_filter"}}`, false},
+		{"wrong key", http.StatusUnauthorized, `{"error":{"code":"401","message":"Access denied"}}`, true},
+	}
+	for _, tt := range tests {
+		server, requests := newAzureServer(t, tt.status, tt.body)
+		err := NewAgentClient(nil).Ping(context.Background(), azureAgent(server.URL))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Ping = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if got := requests(); len(got) != 1 || got[0].apiKey != "azure-key" || got[0].path != "/openai/deployments/gpt4o-prod/chat/completions" {
+			t.Errorf("%s: ping requests = %+v", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package orchestrator
+
+import (
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/stats"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// adaptiveTokenCeiling is the most an adaptive turn asks for when its call
+// has no cap of its own: the reply allowance the client gives Anthropic
+// agents by default. A share at or above the cap leaves the call as it is.
+const adaptiveTokenCeiling = 4000
+
+// remainingCalls counts the calls a debate of maxRounds rounds among agents
+// still makes from the turn of the speaker at position spoken (from 0) of
+// round on, this turn included: the agent turns of this and the later
+// rounds, the lightning round, which counts as round maxRounds+1, and with
+// a moderator its interim comments, round summaries and closing remarks.
+// The summary, the verdict and the analysis are not counted.
+func remainingCalls(s models.DiscussionSettings, agents, maxRounds, round, spoken int, moderated bool) int {
+	calls := 0
+	for r := round; r <= maxRounds; r++ {
+		speakers := len(s.RoundSpeakers(agents, r))
+		turns := speakers
+		if r == round {
+			turns -= spoken
+		}
+		calls += turns
+		if moderated {
+			if s.RoundMode != models.RoundModeParallel {
+				calls += max(turns-1, 0) // interim comments between the turns
+			}
+			calls++ // round summary
+		}
+	}
+	if s.LightningRound {
+		turns := agents
+		if round > maxRounds {
+			turns -= spoken
+		}
+		calls += turns
+	}
+	if moderated {
+		calls++ // closing remarks
+	}
+	return calls
+}
+
+// allocateTurnTokens shares what is left of budget after used equally among
+// the outstanding calls. The prompt of each call is expected to cost
+// avgPrompt tokens, and the rest of its share is its max_tokens. A share at
+// or above ceiling returns 0, leaving the call as it is. ok is false when the
+// share is below floor: the budget cannot cover the rest of the debate.
+func allocateTurnTokens(budget, used, avgPrompt, outstanding, floor, ceiling int) (maxTokens int, ok bool) {
+	share := (budget-used)/max(outstanding, 1) - avgPrompt
+	if share < floor {
+		return 0, false
+	}
+	if share >= ceiling {
+		return 0, true
+	}
+	return share, true
+}
+
+// turnAllowance checks the discussion's token budget before an agent turn,
+// with outstanding the calls still to come including this one. It returns
+// the turn's lowered max_tokens, or 0 to leave the call as it is, and ok
+// false when the budget cannot cover the turn. Without adaptive_max_tokens
+// only a spent budget stops the debate. The usage is read from the
+// discussion log, so it survives a resumed debate.
+func (de *DebateEngine) turnAllowance(discussion *models.Discussion, outstanding int) (maxTokens int, ok bool) {
+	budget := discussion.Settings.TokenBudget
+	if budget <= 0 {
+		return 0, true
+	}
+	logs, err := de.db.GetDiscussionLogs(discussion.ID)
+	if err != nil {
+		// A failed read does not end the debate
+		log.Printf("Failed to check the token budget of discussion %d: %v", discussion.ID, err)
+		return 0, true
+	}
+	usage := stats.DiscussionTokenUsage(logs)
+	if !discussion.Settings.AdaptiveMaxTokens {
+		return 0, usage.TotalTokens < budget
+	}
+
+	calls := 0
+	for _, agent := range usage.Agents {
+		calls += agent.Calls
+	}
+	avgPrompt := 0
+	if calls > 0 {
+		avgPrompt = usage.PromptTokens / calls
+	}
+	ceiling := compactMaxTokens(discussion)
+	if ceiling == 0 {
+		ceiling = adaptiveTokenCeiling
+	}
+	return allocateTurnTokens(budget, usage.TotalTokens, avgPrompt, outstanding, discussion.Settings.TurnTokenFloor(), ceiling)
+}
+
+// stopForBudget ends the debate for its token budget in round, noting it in
+// the transcript once
+func (de *DebateEngine) stopForBudget(discussion *models.Discussion, round int) {
+	if discussion.EndReason == models.EndReasonBudget {
+		return
+	}
+	log.Printf("Token budget of discussion %d cannot cover round %d, ending the debate", discussion.ID, round)
+	discussion.EndReason = models.EndReasonBudget
+	de.insertSystemLog(discussion.ID, models.LogTypeSystem, "success",
+		fmt.Sprintf("Token budget of %d tokens reached in round %d, ending the debate", discussion.Settings.TokenBudget, round),
+		models.JSONMap{
+			"budget_round": strconv.Itoa(round),
+			"token_budget": strconv.Itoa(discussion.Settings.TokenBudget),
+		})
+}
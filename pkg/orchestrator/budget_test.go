@@ -0,0 +1,202 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+func TestRemainingCalls(t *testing.T) {
+	sequential := models.DiscussionSettings{}
+	tests := []struct {
+		name      string
+		settings  models.DiscussionSettings
+		round     int
+		spoken    int
+		moderated bool
+		want      int
+	}{
+		{"first turn", sequential, 1, 0, false, 9},
+		{"mid round", sequential, 2, 1, false, 5},
+		{"last turn", sequential, 3, 2, false, 1},
+		// Interim comments between the turns, a summary per round and the
+		// closing remarks
+		{"moderated", sequential, 1, 0, true, 9 + 3*2 + 3 + 1},
+		{"moderated mid round", sequential, 3, 1, true, 2 + 1 + 1 + 1},
+		{"parallel moderated", models.DiscussionSettings{RoundMode: models.RoundModeParallel}, 1, 0, true, 9 + 3 + 1},
+		{"lightning", models.DiscussionSettings{LightningRound: true}, 3, 0, false, 3 + 3},
+		{"in the lightning round", models.DiscussionSettings{LightningRound: true}, 4, 2, true, 1 + 1},
+		{"active speakers", models.DiscussionSettings{ActiveSpeakers: 2}, 1, 0, false, 6},
+	}
+	for _, tt := range tests {
+		if got := remainingCalls(tt.settings, 3, 3, tt.round, tt.spoken, tt.moderated); got != tt.want {
+			t.Errorf("%s: remainingCalls = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAllocateTurnTokens(t *testing.T) {
+	tests := []struct {
+		name                                              string
+		budget, used, avgPrompt, outstanding, floor, ceil int
+		want                                              int
+		wantOK                                            bool
+	}{
+		{"even share", 10000, 0, 0, 4, 128, 4000, 2500, true},
+		{"prompt cost deducted", 10000, 2000, 300, 4, 128, 4000, 1700, true},
+		{"share above the ceiling", 100000, 0, 0, 4, 128, 4000, 0, true},
+		{"exactly the floor", 1000, 0, 122, 4, 128, 4000, 128, true},
+		{"below the floor", 1000, 0, 123, 4, 128, 4000, 0, false},
+		{"overspent", 1000, 1200, 0, 1, 128, 4000, 0, false},
+		{"no calls left", 1000, 0, 0, 0, 128, 4000, 1000, true},
+	}
+	for _, tt := range tests {
+		got, ok := allocateTurnTokens(tt.budget, tt.used, tt.avgPrompt, tt.outstanding, tt.floor, tt.ceil)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("%s: allocateTurnTokens = %d, %v, want %d, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// TestAllocationTrajectories replays debates whose calls use their whole
+// allowance, or a fixed amount, and checks the budget reaches the last call
+func TestAllocationTrajectories(t *testing.T) {
+	const (
+		budget, floor, ceiling = 20000, 128, 4000
+		calls, prompt          = 12, 400
+	)
+	for _, trajectory := range []struct {
+		name  string
+		reply func(allowance int) int
+	}{
+		{"replies use their allowance", func(allowance int) int { return allowance }},
+		{"replies stop short", func(allowance int) int { return allowance / 2 }},
+	} {
+		used := 0
+		for call := 0; call < calls; call++ {
+			avgPrompt := 0
+			if call > 0 {
+				avgPrompt = prompt
+			}
+			allowance, ok := allocateTurnTokens(budget, used, avgPrompt, calls-call, floor, ceiling)
+			if !ok {
+				t.Fatalf("%s: call %d stopped with %d of %d tokens used", trajectory.name, call+1, used, budget)
+			}
+			// A share above the ceiling leaves the call at the ceiling
+			if allowance == 0 {
+				allowance = ceiling
+			}
+			used += prompt + trajectory.reply(allowance)
+		}
+		if used > budget {
+			t.Errorf("%s: the debate used %d tokens of a %d budget", trajectory.name, used, budget)
+		}
+	}
+
+	// Replies of 2500 tokens, ignoring their allowance, leave the seventh
+	// call 33 tokens
+	used, stopped := 0, 0
+	for call := 0; call < calls; call++ {
+		if _, ok := allocateTurnTokens(budget, used, prompt, calls-call, floor, ceiling); !ok {
+			stopped = call + 1
+			break
+		}
+		used += prompt + 2500
+	}
+	if stopped != 7 {
+		t.Errorf("overrunning replies stopped at call %d, want 7", stopped)
+	}
+}
+
+// newBudgetProvider answers chat completions reporting the given token usage
+// and returns the max_tokens of each request, 0 when unset
+func newBudgetProvider(t *testing.T, promptTokens, completionTokens int) (*httptest.Server, func() []int) {
+	t.Helper()
+	var (
+		mu        sync.Mutex
+		maxTokens []int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			MaxTokens int `json:"max_tokens"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		maxTokens = append(maxTokens, body.MaxTokens)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"Spaces."},"finish_reason":"stop"}],"usage":{"prompt_tokens":%d,"completion_tokens":%d}}`, promptTokens, completionTokens)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []int {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]int(nil), maxTokens...)
+	}
+}
+
+func TestAdaptiveMaxTokens(t *testing.T) {
+	tests := []struct {
+		name          string
+		settings      models.DiscussionSettings
+		wantMaxTokens []int
+		wantEnd       string
+		wantRounds    int
+	}{
+		// Every call costs 500 tokens: 100 of prompt and 400 of reply
+		{"reaches the last round", models.DiscussionSettings{TokenBudget: 4000, AdaptiveMaxTokens: true},
+			[]int{666, 600, 650, 733, 900, 1400}, models.EndReasonMaxRounds, 3},
+		// The fourth turn's share of 66 tokens is below the 128 floor
+		{"stops at the floor", models.DiscussionSettings{TokenBudget: 2000, AdaptiveMaxTokens: true},
+			[]int{333, 200, 150}, models.EndReasonBudget, 1},
+		{"raised floor", models.DiscussionSettings{TokenBudget: 2000, AdaptiveMaxTokens: true, MinTurnTokens: 160},
+			[]int{333, 200}, models.EndReasonBudget, 1},
+		// Without adaptive_max_tokens only a spent budget stops the debate
+		{"fixed", models.DiscussionSettings{TokenBudget: 1000},
+			[]int{0, 0}, models.EndReasonBudget, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			de := newTestEngine(t)
+			server, maxTokens := newBudgetProvider(t, 100, 400)
+			alice := insertTestAgent(t, de, "Alice", server.URL)
+			bob := insertTestAgent(t, de, "Bob", server.URL)
+
+			d := runToEnd(t, de, []*models.Agent{alice, bob}, 3, tt.settings)
+			if d.Status != "completed" || d.EndReason != tt.wantEnd || d.CompletedRounds != tt.wantRounds {
+				t.Errorf("discussion %s after %d rounds (%s), want %s after %d", d.Status, d.CompletedRounds, d.EndReason, tt.wantEnd, tt.wantRounds)
+			}
+			if got := maxTokens(); fmt.Sprint(got) != fmt.Sprint(tt.wantMaxTokens) {
+				t.Errorf("max_tokens sent = %v, want %v", got, tt.wantMaxTokens)
+			}
+
+			logs, err := de.db.GetDiscussionLogs(d.ID)
+			if err != nil {
+				t.Fatalf("GetDiscussionLogs: %v", err)
+			}
+			var recorded []int
+			stops := 0
+			for _, l := range logs {
+				if l.AgentID == alice.ID || l.AgentID == bob.ID {
+					allowance, _ := strconv.Atoi(l.Metadata["adaptive_max_tokens"])
+					recorded = append(recorded, allowance)
+				}
+				if l.Metadata["budget_round"] != "" {
+					stops++
+				}
+			}
+			if fmt.Sprint(recorded) != fmt.Sprint(tt.wantMaxTokens) {
+				t.Errorf("recorded allowances = %v, want %v", recorded, tt.wantMaxTokens)
+			}
+			if want := map[bool]int{true: 1, false: 0}[tt.wantEnd == models.EndReasonBudget]; stops != want {
+				t.Errorf("%d budget notes in the transcript, want %d", stops, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package orchestrator
+
+import (
+	"court-table-ai/pkg/models"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+)
+
+// add records a request of the call when tracing is on and returns its
+// record, or nil when the request is not kept
+func (t *callTrace) add(req *http.Request, started time.Time, resp *http.Response, err error) *models.CallTraceRequest {
+	if !t.record {
+		return nil
+	}
+	if len(t.requests) >= models.MaxCallTraceRequests {
+		t.dropped++
+		return nil
+	}
+
+	record := &models.CallTraceRequest{
+		Attempt:    t.attempt,
+		Method:     req.Method,
+		Endpoint:   req.URL.Scheme + "://" + req.URL.Host + req.URL.Path,
+		DurationMs: time.Since(started).Milliseconds(),
+	}
+	if req.ContentLength > 0 {
+		record.BytesOut = req.ContentLength
+	}
+	if err != nil {
+		record.Error = err.Error()
+	} else {
+		record.Status = resp.StatusCode
+	}
+	t.requests = append(t.requests, record)
+	return record
+}
+
+// encode returns the call's trace as JSON, or "" when tracing is off
+func (t *callTrace) encode() string {
+	if !t.record {
+		return ""
+	}
+	trace := models.CallTrace{Requests: make([]models.CallTraceRequest, 0, len(t.requests)), Dropped: t.dropped}
+	for _, r := range t.requests {
+		trace.Requests = append(trace.Requests, *r)
+	}
+	encoded, err := json.Marshal(trace)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
+
+// tracedBody counts the bytes read from a traced response and extends the
+// request's duration until its body is read
+type tracedBody struct {
+	io.ReadCloser
+	record  *models.CallTraceRequest
+	started time.Time
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.record.BytesIn += int64(n)
+	b.record.DurationMs = time.Since(b.started).Milliseconds()
+	return n, err
+}
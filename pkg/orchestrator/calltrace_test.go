@@ -0,0 +1,162 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+)
+
+// decodeCallTrace reads the trace a call left in its response metadata
+func decodeCallTrace(t *testing.T, response *models.AgentResponse) models.CallTrace {
+	t.Helper()
+	encoded, ok := response.Metadata["call_trace"]
+	if !ok {
+		t.Fatalf("response metadata %v has no call_trace", response.Metadata)
+	}
+	var trace models.CallTrace
+	if err := json.Unmarshal([]byte(encoded), &trace); err != nil {
+		t.Fatalf("call_trace %s: %v", encoded, err)
+	}
+	return trace
+}
+
+const traceReply = `{"choices":[{"message":{"role":"assistant","content":"Spaces."},"finish_reason":"stop"}]}`
+
+func TestCallTraceListsProbes(t *testing.T) {
+	de := newTestEngine(t)
+	// Only the base URL answers, so the call probes every candidate endpoint
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.Error(w, `{"error":{"message":"not found"}}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(traceReply))
+	}))
+	t.Cleanup(server.Close)
+	agent := insertTestAgent(t, de, "Alice", server.URL+"?key=secret")
+	agent.EndpointStyle = ""
+
+	response, err := de.agentClient.CallAgentWithOptions(context.Background(), agent, "Tabs or spaces?", "", CallOptions{Trace: true})
+	if err != nil || !response.Success {
+		t.Fatalf("CallAgentWithOptions = %+v, %v", response, err)
+	}
+	trace := decodeCallTrace(t, response)
+	wantPaths := []string{"/v1/chat/completions", "/chat/completions", ""}
+	wantStatuses := []int{http.StatusNotFound, http.StatusNotFound, http.StatusOK}
+	if len(trace.Requests) != len(wantPaths) || trace.Dropped != 0 {
+		t.Fatalf("trace = %+v, want %d requests", trace, len(wantPaths))
+	}
+	for i, r := range trace.Requests {
+		// The query string, which may hold a key, is left out
+		if r.Endpoint != server.URL+wantPaths[i] || r.Status != wantStatuses[i] || r.Attempt != 1 || r.Method != http.MethodPost {
+			t.Errorf("request %d = %+v, want POST %s answered %d", i, r, server.URL+wantPaths[i], wantStatuses[i])
+		}
+		if r.BytesOut <= 0 || r.BytesIn <= 0 || r.DurationMs < 0 || r.Error != "" {
+			t.Errorf("request %d = %+v, want its sizes and duration", i, r)
+		}
+	}
+	if last := trace.Requests[2]; last.BytesIn != int64(len(traceReply)) {
+		t.Errorf("answer read %d bytes, want %d", last.BytesIn, len(traceReply))
+	}
+}
+
+func TestCallTraceNumbersRetries(t *testing.T) {
+	de := newTestEngine(t)
+	if err := de.db.SetSettingJSON(database.SettingAgentRetries, models.AgentRetryConfig{MaxRetries: 2, BaseDelayMs: 1, MaxDelayMs: 2}); err != nil {
+		t.Fatalf("SetSettingJSON: %v", err)
+	}
+	server, _ := newFlakyProvider(t, "", http.StatusServiceUnavailable, 0)
+	agent := insertTestAgent(t, de, "Alice", server.URL)
+	agent.Trace = true
+
+	response, err := de.agentClient.CallAgent(context.Background(), agent, "Tabs or spaces?", "")
+	if err != nil || !response.Success {
+		t.Fatalf("CallAgent = %+v, %v", response, err)
+	}
+	trace := decodeCallTrace(t, response)
+	if len(trace.Requests) != 3 {
+		t.Fatalf("trace = %+v, want 3 requests", trace)
+	}
+	for i, want := range []struct {
+		status int
+		failed bool
+	}{{http.StatusServiceUnavailable, false}, {0, true}, {http.StatusOK, false}} {
+		r := trace.Requests[i]
+		if r.Attempt != i+1 || r.Status != want.status || (r.Error != "") != want.failed {
+			t.Errorf("request %d = %+v, want attempt %d with status %d", i, r, i+1, want.status)
+		}
+	}
+}
+
+func TestCallTraceIsOptIn(t *testing.T) {
+	de := newTestEngine(t)
+	server, _ := newFlakyProvider(t, "")
+	agent := insertTestAgent(t, de, "Alice", server.URL)
+
+	response, err := de.agentClient.CallAgent(context.Background(), agent, "Tabs or spaces?", "")
+	if err != nil || !response.Success {
+		t.Fatalf("CallAgent = %+v, %v", response, err)
+	}
+	if trace, ok := response.Metadata["call_trace"]; ok {
+		t.Errorf("call without tracing recorded %s", trace)
+	}
+}
+
+func TestCallTraceIsBounded(t *testing.T) {
+	trace := &callTrace{record: true}
+	endpoint, _ := url.Parse("https://api.example.com/v1/chat/completions?key=secret")
+	req := &http.Request{Method: http.MethodPost, URL: endpoint}
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests}
+	for i := 0; i < models.MaxCallTraceRequests+5; i++ {
+		trace.attempt = i + 1
+		trace.add(req, time.Now(), resp, nil)
+	}
+
+	var decoded models.CallTrace
+	if err := json.Unmarshal([]byte(trace.encode()), &decoded); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if len(decoded.Requests) != models.MaxCallTraceRequests || decoded.Dropped != 5 {
+		t.Errorf("trace kept %d requests and dropped %d, want %d and 5", len(decoded.Requests), decoded.Dropped, models.MaxCallTraceRequests)
+	}
+	if strings.Contains(decoded.Requests[0].Endpoint, "secret") {
+		t.Errorf("endpoint = %s, want it without the query string", decoded.Requests[0].Endpoint)
+	}
+
+	off := &callTrace{}
+	if off.add(req, time.Now(), resp, nil) != nil || off.encode() != "" {
+		t.Error("a call without tracing recorded a request")
+	}
+}
+
+func TestDiscussionTraceReachesLogs(t *testing.T) {
+	de := newTestEngine(t)
+	server, _ := newFlakyProvider(t, "")
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+
+	d := runToEnd(t, de, []*models.Agent{alice}, 1, models.DiscussionSettings{Trace: true})
+	logs, err := de.db.GetDiscussionLogs(d.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	traced := 0
+	for _, l := range logs {
+		if l.AgentID == alice.ID {
+			if _, ok := l.Metadata["call_trace"]; ok {
+				traced++
+			}
+		}
+	}
+	if traced != 1 {
+		t.Errorf("%d turns carry a trace, want 1", traced)
+	}
+}
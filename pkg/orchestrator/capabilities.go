@@ -0,0 +1,93 @@
+package orchestrator
+
+import (
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/version"
+	"log"
+)
+
+// Optional features reported by Capabilities. Features that this build does
+// not register are reported as disabled.
+const (
+	FeatureStreaming      = "streaming"
+	FeatureVoting         = "voting"
+	FeatureJudge          = "judge"
+	FeatureWebhooks       = "webhooks"
+	FeatureAuth           = "auth"
+	FeaturePostgres       = "postgres"
+	FeatureDuplicateGuard = "duplicate_guard"
+	FeatureSlowCallTrace  = "slow_call_tracing"
+	FeatureTranscriptLog  = "transcript_log"
+)
+
+// knownFeatures are always listed in Capabilities, registered or not
+var knownFeatures = []string{
+	FeatureStreaming, FeatureVoting, FeatureJudge, FeatureWebhooks, FeatureAuth, FeaturePostgres,
+	FeatureDuplicateGuard, FeatureSlowCallTrace, FeatureTranscriptLog,
+}
+
+// features holds the features built into this server, registered from the
+// init function of the file implementing each one. The check reports
+// whether the feature is currently on, which may depend on settings.
+var features = map[string]func(de *DebateEngine) bool{}
+
+// registerFeature adds a built-in feature; call it only from init
+func registerFeature(name string, enabled func(de *DebateEngine) bool) {
+	features[name] = enabled
+}
+
+// alwaysOn is the check of a feature that has no setting to turn it off
+func alwaysOn(*DebateEngine) bool { return true }
+
+func init() {
+	// Ratings and the transcript log live outside the engine, which reports
+	// them for their packages
+	registerFeature(FeatureVoting, alwaysOn)
+	registerFeature(FeatureTranscriptLog, func(de *DebateEngine) bool {
+		cfg, err := de.db.GetTranscriptLogConfig()
+		if err != nil {
+			log.Printf("Failed to read transcript log settings: %v", err)
+			return false
+		}
+		return cfg.Enabled
+	})
+}
+
+// FeatureEnabled reports whether an optional feature is built in and turned
+// on. Handlers gating a feature use it so they agree with Capabilities.
+func (de *DebateEngine) FeatureEnabled(name string) bool {
+	enabled, ok := features[name]
+	return ok && enabled(de)
+}
+
+// Capabilities reports the optional features of this server and the limits
+// it enforces
+func (de *DebateEngine) Capabilities() models.Capabilities {
+	flags := make(map[string]bool, len(knownFeatures)+len(features))
+	for _, name := range knownFeatures {
+		flags[name] = false
+	}
+	for name := range features {
+		flags[name] = de.FeatureEnabled(name)
+	}
+
+	timeouts, err := de.db.GetAgentTimeouts()
+	if err != nil {
+		log.Printf("Failed to read agent timeouts: %v", err)
+		timeouts = models.DefaultAgentTimeouts()
+	}
+
+	return models.Capabilities{
+		SchemaVersion: models.CapabilitiesSchemaVersion,
+		Version:       version.Version,
+		Features:      flags,
+		Limits: models.Limits{
+			MinRounds:              models.MinMaxRounds,
+			MaxRounds:              models.MaxMaxRounds,
+			MinCharLimit:           models.MinMaxCharLimit,
+			MaxCharLimit:           models.MaxMaxCharLimit,
+			MaxAgentTimeoutSeconds: timeouts.MaxSeconds,
+			MaxSystemPromptLength:  models.MaxSystemPromptLength,
+		},
+	}
+}
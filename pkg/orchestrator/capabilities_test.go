@@ -0,0 +1,71 @@
+package orchestrator
+
+import (
+	"testing"
+
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+)
+
+func TestCapabilities(t *testing.T) {
+	de := newTestEngine(t)
+
+	caps := de.Capabilities()
+	if caps.SchemaVersion != models.CapabilitiesSchemaVersion || caps.Version == "" {
+		t.Errorf("schema version %d, version %q", caps.SchemaVersion, caps.Version)
+	}
+	want := map[string]bool{
+		FeatureStreaming:      true,
+		FeatureVoting:         true,
+		FeatureJudge:          true,
+		FeatureWebhooks:       true,
+		FeatureAuth:           false,
+		FeaturePostgres:       false,
+		FeatureDuplicateGuard: true,
+		FeatureSlowCallTrace:  true,
+		FeatureTranscriptLog:  false,
+	}
+	for name, enabled := range want {
+		if got, ok := caps.Features[name]; !ok || got != enabled {
+			t.Errorf("feature %s = %v (listed %v), want %v", name, got, ok, enabled)
+		}
+	}
+	// Handlers gate features through FeatureEnabled, which must agree
+	for name, enabled := range caps.Features {
+		if de.FeatureEnabled(name) != enabled {
+			t.Errorf("FeatureEnabled(%s) = %v, capabilities say %v", name, !enabled, enabled)
+		}
+	}
+
+	limits := caps.Limits
+	if limits.MinRounds != models.MinMaxRounds || limits.MaxRounds != models.MaxMaxRounds ||
+		limits.MinCharLimit != models.MinMaxCharLimit || limits.MaxCharLimit != models.MaxMaxCharLimit ||
+		limits.MaxSystemPromptLength != models.MaxSystemPromptLength || limits.MaxAgentTimeoutSeconds != models.DefaultAgentTimeouts().MaxSeconds {
+		t.Errorf("limits = %+v", limits)
+	}
+}
+
+func TestCapabilitiesFollowSettings(t *testing.T) {
+	de := newTestEngine(t)
+	save := func(key string, v interface{}) {
+		t.Helper()
+		if err := de.db.SetSettingJSON(key, v); err != nil {
+			t.Fatalf("SetSettingJSON(%s): %v", key, err)
+		}
+	}
+
+	save(database.SettingDuplicateGuard, models.DuplicateGuardConfig{WindowSeconds: 0})
+	save(database.SettingSlowCall, models.SlowCallConfig{ThresholdSeconds: 0})
+	save(database.SettingTranscriptLog, models.TranscriptLogConfig{Enabled: true, Directory: t.TempDir(), Content: "hash", MaxFileMB: 10, RetentionDays: 7})
+
+	features := de.Capabilities().Features
+	if features[FeatureDuplicateGuard] || features[FeatureSlowCallTrace] || !features[FeatureTranscriptLog] {
+		t.Errorf("features after toggling settings = %v", features)
+	}
+	if de.FeatureEnabled(FeatureDuplicateGuard) || !de.FeatureEnabled(FeatureTranscriptLog) {
+		t.Error("FeatureEnabled does not follow the settings")
+	}
+	if de.FeatureEnabled("telepathy") {
+		t.Error("an unknown feature is enabled")
+	}
+}
@@ -0,0 +1,157 @@
+package orchestrator
+
+import (
+	"court-table-ai/pkg/models"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// citationMetadataKey holds the JSON array of URLs cited in a stored response
+const citationMetadataKey = "citations"
+
+var (
+	// bareURLPattern matches http(s) URLs up to whitespace, quotes, brackets
+	// or parentheses, which also stops it at the end of a markdown link target
+	bareURLPattern = regexp.MustCompile("https?://[^\\s<>()\\[\\]\"'`]+")
+	// markdownLinkPattern matches [text](url) links as a whole
+	markdownLinkPattern = regexp.MustCompile(`\[[^\]\n]*\]\(\s*https?://[^\s)]*\s*\)`)
+)
+
+// truncationMarker ends a response shortened to the discussion's limit
+const truncationMarker = "…"
+
+// truncateResponse shortens content to at most limit characters (runes),
+// including a trailing ellipsis. The cut falls at the last word boundary in
+// the second half of the allowance, never through a URL or a markdown link
+// (one that would be cut is dropped whole) and never inside an emoji
+// sequence or between a letter and its combining marks.
+func truncateResponse(content string, limit int) string {
+	if limit <= 0 || utf8.RuneCountInString(content) <= limit {
+		return content
+	}
+
+	// Byte offset after the first limit-1 runes, leaving room for the marker
+	budget := limit - utf8.RuneCountInString(truncationMarker)
+	cut := 0
+	for i := 0; i < budget; i++ {
+		_, size := utf8.DecodeRuneInString(content[cut:])
+		cut += size
+	}
+
+	for _, pattern := range []*regexp.Regexp{bareURLPattern, markdownLinkPattern} {
+		for _, span := range pattern.FindAllStringIndex(content, -1) {
+			if span[0] < cut && cut < span[1] {
+				cut = span[0]
+			}
+		}
+	}
+
+	// Scripts written with spaces are cut between words; others, such as
+	// Chinese or Japanese, wherever the allowance ends. Halfway is counted in
+	// characters; in bytes, a space after CJK text looks further along.
+	if space := strings.LastIndexFunc(content[:cut], unicode.IsSpace); space >= 0 &&
+		utf8.RuneCountInString(content[:space]) > utf8.RuneCountInString(content[:cut])/2 {
+		cut = space
+	}
+	for cut > 0 && splitsCluster(content, cut) {
+		_, size := utf8.DecodeLastRuneInString(content[:cut])
+		cut -= size
+	}
+
+	return strings.TrimRight(content[:cut], " \t\n([") + truncationMarker
+}
+
+// splitsCluster reports whether cutting content at byte offset cut would
+// separate characters that display as one, such as an emoji from its skin
+// tone or zero-width joiner, or a letter from its combining accent
+func splitsCluster(content string, cut int) bool {
+	before, _ := utf8.DecodeLastRuneInString(content[:cut])
+	after, _ := utf8.DecodeRuneInString(content[cut:])
+	return before == '\u200d' || after == '\u200d' ||
+		unicode.In(after, unicode.Mn, unicode.Me) ||
+		unicode.Is(unicode.Variation_Selector, after) ||
+		(after >= 0x1F3FB && after <= 0x1F3FF) // skin tone modifiers
+}
+
+// extractCitations returns the distinct URLs in content in order of
+// appearance, without trailing sentence punctuation or a truncation marker
+func extractCitations(content string) []string {
+	seen := make(map[string]bool)
+	var urls []string
+	for _, u := range bareURLPattern.FindAllString(content, -1) {
+		u = strings.TrimRight(u, ".,;:!?*_~"+truncationMarker)
+		if len(u) <= len("https://") || seen[u] {
+			continue
+		}
+		seen[u] = true
+		urls = append(urls, u)
+	}
+	return urls
+}
+
+// citationMetadata records the URLs cited in a stored response, if any
+func citationMetadata(metadata models.JSONMap, content string) {
+	urls := extractCitations(content)
+	if len(urls) == 0 {
+		return
+	}
+	if encoded, err := json.Marshal(urls); err == nil {
+		metadata[citationMetadataKey] = string(encoded)
+	}
+}
+
+// CitationReport aggregates the URLs cited across a discussion's successful
+// turns, in order of first citation. Entries written before citation tracking
+// are ignored.
+func CitationReport(logs []*models.DiscussionLog) []models.Citation {
+	byURL := make(map[string]*models.Citation)
+	var order []string
+	for _, l := range logs {
+		if l.IsSystem() || l.LogType != models.LogTypeResponse || l.Status != "success" {
+			continue
+		}
+		raw, ok := l.Metadata[citationMetadataKey]
+		if !ok {
+			continue
+		}
+		var urls []string
+		if err := json.Unmarshal([]byte(raw), &urls); err != nil {
+			continue
+		}
+
+		for _, u := range urls {
+			c, ok := byURL[u]
+			if !ok {
+				c = &models.Citation{URL: u}
+				byURL[u] = c
+				order = append(order, u)
+			}
+			c.LogIDs = append(c.LogIDs, l.ID)
+			if !containsID(c.AgentIDs, l.AgentID) {
+				c.AgentIDs = append(c.AgentIDs, l.AgentID)
+			}
+		}
+	}
+
+	report := make([]models.Citation, 0, len(order))
+	for _, u := range order {
+		c := byURL[u]
+		sort.Slice(c.AgentIDs, func(i, j int) bool { return c.AgentIDs[i] < c.AgentIDs[j] })
+		report = append(report, *c)
+	}
+	return report
+}
+
+// containsID reports whether ids contains id
+func containsID(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,204 @@
+package orchestrator
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"court-table-ai/pkg/models"
+)
+
+func TestTruncateResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		limit   int
+		want    string
+	}{
+		{"short enough", "hello world", 11, "hello world"},
+		{"no limit", "hello world", 0, "hello world"},
+		{"word boundary", "hello world foo bar", 12, "hello world…"},
+		{"back to a space in the second half", "hello wonderful world", 19, "hello wonderful…"},
+		{"mid-word when the last space is early", "hello wonderful world", 14, "hello wonderf…"},
+		{"chinese without spaces", "我们应该认真讨论这个问题", 6, "我们应该认…"},
+		{"japanese without spaces", "日本語のテキストです", 6, "日本語のテ…"},
+		{"space after CJK counts characters", "一二三 abcdefgh", 11, "一二三 abcdef…"},
+		{"space late in CJK text", "一二三四五六七 八九", 9, "一二三四五六七…"},
+		{"korean words", "안녕하세요 여러분 반갑습니다", 12, "안녕하세요 여러분…"},
+		{"emoji", "😀😃😄😁😆", 4, "😀😃😄…"},
+		{"inside a ZWJ sequence", "ab 👨‍👩‍👧‍👦", 6, "ab…"},
+		{"after a ZWJ sequence", "👨‍👩‍👧 and more", 7, "👨‍👩‍👧…"},
+		{"skin tone modifier", "👍🏽👍🏽👍🏽", 4, "👍🏽…"},
+		{"variation selector", "❤️❤️❤️", 4, "❤️…"},
+		{"combining accent", "cafés", 5, "caf…"},
+		{"URL dropped whole", "see https://example.com/a/long/path for more", 20, "see…"},
+		{"markdown link dropped whole", "read [the docs](https://example.com/docs) now", 20, "read…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateResponse(tt.content, tt.limit)
+			if got != tt.want {
+				t.Errorf("truncateResponse(%q, %d) = %q, want %q", tt.content, tt.limit, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("truncateResponse(%q, %d) is not valid UTF-8", tt.content, tt.limit)
+			}
+			if tt.limit > 0 && utf8.RuneCountInString(got) > tt.limit {
+				t.Errorf("truncateResponse(%q, %d) has %d characters", tt.content, tt.limit, utf8.RuneCountInString(got))
+			}
+		})
+	}
+}
+
+func TestTruncateResponseNearURLs(t *testing.T) {
+	longURL := "https://example.com/" + strings.Repeat("a", 60)
+	tests := []struct {
+		name    string
+		content string
+		limit   int
+		want    string
+	}{
+		{"long URL across the limit", "Sources: " + longURL + " and more", 40, "Sources:…"},
+		{"URL inside the limit kept", "See https://go.dev/doc then keep reading the rest", 30, "See https://go.dev/doc then…"},
+		{"URL ending at the limit kept", "Read https://go.dev/x and more", 22, "Read https://go.dev/x…"},
+		{"markdown link across the limit", "Per [the report](https://example.org/report.pdf) it holds", 30, "Per…"},
+		{"bare URL after a markdown link", "[a](https://a.example) then " + longURL, 40, "[a](https://a.example) then…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateResponse(tt.content, tt.limit); got != tt.want {
+				t.Errorf("truncateResponse(%q, %d) = %q, want %q", tt.content, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractCitations(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"none", "no links here, just http:// and https://", nil},
+		{"bare with trailing punctuation", "See https://example.net/data. Also https://example.net/more!", []string{"https://example.net/data", "https://example.net/more"}},
+		{"markdown and bare mixed", "Per [the report](https://example.org/report.pdf), and https://example.net/data (mirror: <https://mirror.example/data>)",
+			[]string{"https://example.org/report.pdf", "https://example.net/data", "https://mirror.example/data"}},
+		{"duplicates once", "https://a.example/x and [again](https://a.example/x)", []string{"https://a.example/x"}},
+		{"query strings kept", "https://example.com/search?q=tabs&lang=en", []string{"https://example.com/search?q=tabs&lang=en"}},
+		{"truncation marker dropped", "Read https://go.dev/x…", []string{"https://go.dev/x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractCitations(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractCitations(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCitationReport(t *testing.T) {
+	response := func(id, agentID int64, status string, urls ...string) *models.DiscussionLog {
+		l := &models.DiscussionLog{ID: id, AgentID: agentID, LogType: models.LogTypeResponse, Status: status, Metadata: models.JSONMap{}}
+		citationMetadata(l.Metadata, strings.Join(urls, " "))
+		return l
+	}
+	system := response(4, models.SystemAgentID, "success", "https://system.example")
+	system.LogType = models.LogTypeSystem
+	broken := response(6, 2, "success")
+	broken.Metadata[citationMetadataKey] = "not json"
+
+	logs := []*models.DiscussionLog{
+		response(1, 2, "success", "https://b.example", "https://a.example"),
+		response(2, 1, "success", "https://a.example"),
+		response(3, 1, "error", "https://failed.example"),
+		system,
+		response(5, 2, "success", "https://a.example"),
+		broken,
+	}
+	want := []models.Citation{
+		{URL: "https://b.example", AgentIDs: []int64{2}, LogIDs: []int64{1}},
+		{URL: "https://a.example", AgentIDs: []int64{1, 2}, LogIDs: []int64{1, 2, 5}},
+	}
+	if got := CitationReport(logs); !reflect.DeepEqual(got, want) {
+		t.Errorf("CitationReport = %+v, want %+v", got, want)
+	}
+	if got := CitationReport(nil); got == nil || len(got) != 0 {
+		t.Errorf("CitationReport(nil) = %#v, want an empty list for the API", got)
+	}
+}
+
+func TestTurnRecordsKeptCitations(t *testing.T) {
+	de := newTestEngine(t)
+	reply := "Evidence: https://example.com/study and the full dataset at https://example.com/" + strings.Repeat("x", 80)
+	server, _ := newTestProvider(t, reply)
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+	discussion := insertTestDiscussion(t, de, "running", alice)
+	discussion.MaxCharLimit = 60
+
+	out := de.takeTurn(context.Background(), discussion, alice, 1, "", "Your turn", "", 0, 0, runNow)
+	if !out.answered {
+		t.Fatalf("turn = %+v, want an answer", out)
+	}
+	logs, err := de.db.GetDiscussionLogs(discussion.ID)
+	if err != nil || len(logs) != 1 {
+		t.Fatalf("GetDiscussionLogs = %d logs, %v; want one", len(logs), err)
+	}
+	if want := "Evidence: https://example.com/study and the full dataset…"; logs[0].Content != want {
+		t.Errorf("stored content = %q, want %q", logs[0].Content, want)
+	}
+	if got, want := logs[0].Metadata[citationMetadataKey], `["https://example.com/study"]`; got != want {
+		t.Errorf("citations metadata = %s, want %s", got, want)
+	}
+}
+
+func TestStoredRepliesKeepTheCharacterLimit(t *testing.T) {
+	replies := map[string]string{
+		"chinese":  strings.Repeat("我们应该认真讨论这个问题。", 40),
+		"japanese": strings.Repeat("日本語のテキストです、", 50),
+		"emoji":    strings.Repeat("Spaces 👍🏽 win 👨‍👩‍👧‍👦 ", 40),
+		"mixed":    strings.Repeat("Kita setuju 😀 我们 ", 50),
+	}
+	for name, reply := range replies {
+		t.Run(name, func(t *testing.T) {
+			de := newTestEngine(t)
+			server := newScriptedProvider(t, reply, 5, 3, 100)
+			alice := insertTestAgent(t, de, "Alice", server.URL)
+
+			settings := models.DiscussionSettings{SummaryBackend: models.SummaryBackendExtractive, PromptProfile: models.PromptProfileStandard}
+			discussion, err := de.RunDebate(context.Background(), "Tabs or spaces", []int64{alice.ID}, nil, nil, nil, 1, "en", models.MinMaxCharLimit, settings)
+			if err != nil {
+				t.Fatalf("RunDebate: %v", err)
+			}
+			waitUntil(t, "the debate to end", func() bool {
+				d, err := de.db.GetDiscussion(discussion.ID)
+				return err == nil && !d.InProgress()
+			})
+
+			logs, err := de.db.GetDiscussionLogs(discussion.ID)
+			if err != nil {
+				t.Fatalf("GetDiscussionLogs: %v", err)
+			}
+			turns := 0
+			for _, l := range logs {
+				if l.AgentID != alice.ID {
+					continue
+				}
+				turns++
+				if !utf8.ValidString(l.Content) {
+					t.Errorf("stored reply is not valid UTF-8: %q", l.Content)
+				}
+				if n := utf8.RuneCountInString(l.Content); n > models.MinMaxCharLimit {
+					t.Errorf("stored reply has %d characters, over the limit of %d", n, models.MinMaxCharLimit)
+				}
+				if !strings.HasSuffix(l.Content, truncationMarker) {
+					t.Errorf("cut reply does not end with %q: %q", truncationMarker, l.Content)
+				}
+			}
+			if turns != 1 {
+				t.Errorf("found %d stored turns of Alice, want 1", turns)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package orchestrator
+
+import (
+	"context"
+	"court-table-ai/pkg/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// JobTypeExtractClaims identifies background claim extraction jobs
+const JobTypeExtractClaims = "extract_claims"
+
+// claimExtractionSystemPrompt replaces the extractor's own system prompt so
+// it answers with JSON rather than joining the debate
+const claimExtractionSystemPrompt = "You extract the distinct claims made in debate transcripts. " +
+	"Reply with a JSON array only, with no commentary and no code fences."
+
+// maxClaimRepairs is how many times a malformed extraction reply is sent back
+// for correction before the agent's extraction is recorded as failed
+const maxClaimRepairs = 1
+
+// agentTurn is one successful response of an agent, used as extraction input
+type agentTurn struct {
+	round   int
+	content string
+}
+
+// rawClaim is a claim as returned by the extractor before validation
+type rawClaim struct {
+	Claim  string `json:"claim"`
+	Stance string `json:"stance"`
+	Rounds []int  `json:"rounds"`
+}
+
+// ExtractClaims asks extractor for the distinct claims each participant made
+// and stores them. Each agent is extracted and stored separately: a failure is
+// recorded against that agent and the others carry on. It returns an error
+// only when the discussion cannot be read or every agent failed.
+func (de *DebateEngine) ExtractClaims(ctx context.Context, discussionID int64, extractorID int64, progress func(done, total int64)) error {
+	discussion, logs, err := de.GetDiscussionStatus(discussionID)
+	if err != nil {
+		return err
+	}
+	extractor, err := de.db.GetAgent(extractorID)
+	if err != nil {
+		return fmt.Errorf("extractor agent %d not found: %w", extractorID, err)
+	}
+
+	byAgent := make(map[int64][]agentTurn)
+	turns := make(map[int64]int)
+	current := 0
+	for _, l := range logs {
+		if l.IsModerator || l.IsSystem() || l.LogType != models.LogTypeResponse {
+			continue
+		}
+		round := replayRound(l, turns, current)
+		if round > current {
+			current = round
+		}
+		if l.Status == "success" && strings.TrimSpace(l.Content) != "" {
+			byAgent[l.AgentID] = append(byAgent[l.AgentID], agentTurn{round: round, content: l.Content})
+		}
+	}
+
+	agentIDs := make([]int64, 0, len(byAgent))
+	for id := range byAgent {
+		agentIDs = append(agentIDs, id)
+	}
+	sort.Slice(agentIDs, func(i, j int) bool { return agentIDs[i] < agentIDs[j] })
+
+	total := int64(len(agentIDs))
+	progress(0, total)
+
+	failed := 0
+	for i, agentID := range agentIDs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		name := fmt.Sprintf("#%d", agentID)
+		if agentName, err := de.db.GetAgentName(agentID); err == nil {
+			name = agentName
+		}
+
+		extraction := &models.ClaimExtraction{
+			DiscussionID: discussionID,
+			AgentID:      agentID,
+			ExtractorID:  extractorID,
+			Status:       models.ClaimExtractionSuccess,
+		}
+		claims, attempts, err := de.extractAgentClaims(ctx, extractor, discussion, name, byAgent[agentID])
+		extraction.Attempts = attempts
+		if err != nil {
+			fmt.Printf("Claim extraction for agent %s in discussion %d failed: %v\n", name, discussionID, err)
+			extraction.Status = models.ClaimExtractionFailed
+			extraction.Error = err.Error()
+			claims = nil
+			failed++
+		}
+
+		if err := de.db.SaveClaimExtraction(extraction, claims); err != nil {
+			return err
+		}
+		progress(int64(i+1), total)
+	}
+
+	if failed > 0 && failed == len(agentIDs) {
+		return fmt.Errorf("claim extraction failed for all %d agents", failed)
+	}
+	return nil
+}
+
+// extractAgentClaims runs the extraction prompt for one agent, sending a
+// malformed reply back for repair. It returns the number of calls made.
+func (de *DebateEngine) extractAgentClaims(ctx context.Context, extractor *models.Agent, discussion *models.Discussion, name string, turns []agentTurn) ([]*models.DiscussionClaim, int, error) {
+	rounds := make(map[int]bool)
+	for _, t := range turns {
+		rounds[t.round] = true
+	}
+
+	temperature := 0.0
+	opts := CallOptions{SystemPrompt: claimExtractionSystemPrompt, Temperature: &temperature}
+	prompt := buildClaimPrompt(discussion.Topic, name, turns)
+
+	attempts := 0
+	for {
+		attempts++
+		response, err := de.agentClient.CallAgentWithOptions(ctx, extractor, prompt, "", opts)
+		if err != nil {
+			return nil, attempts, err
+		}
+		if !response.Success {
+			return nil, attempts, errors.New(response.ErrorMessage)
+		}
+
+		claims, err := parseClaims(response.Content, rounds)
+		if err == nil {
+			return claims, attempts, nil
+		}
+		if attempts > maxClaimRepairs {
+			return nil, attempts, fmt.Errorf("invalid extraction reply: %w", err)
+		}
+
+		prompt = buildClaimRepairPrompt(response.Content, err)
+	}
+}
+
+// buildClaimPrompt asks for the claims made in one agent's responses
+func buildClaimPrompt(topic, name string, turns []agentTurn) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Debate topic: %s\n\n", promptTopic(topic))
+	fmt.Fprintf(&b, "Below are all responses from the participant %q, labelled by round.\n\n", name)
+	for _, t := range turns {
+		fmt.Fprintf(&b, "[Round %d]\n%s\n\n", t.round, fence(t.content))
+	}
+	fmt.Fprintf(&b, "List the distinct claims this participant made. Merge repeated claims into one. ")
+	fmt.Fprintf(&b, "Reply with a JSON array of objects with the fields \"claim\" (one sentence), ")
+	fmt.Fprintf(&b, "\"stance\" (\"for\", \"against\" or \"neutral\" towards the topic) and ")
+	fmt.Fprintf(&b, "\"rounds\" (the round numbers where the claim was made). ")
+	fmt.Fprintf(&b, "Reply with [] if there are no claims, and at most %d claims.", models.MaxClaimsPerAgent)
+	return b.String()
+}
+
+// buildClaimRepairPrompt asks the extractor to correct a malformed reply
+func buildClaimRepairPrompt(reply string, parseErr error) string {
+	return fmt.Sprintf("Your previous reply could not be used (%v):\n\n%s\n\n"+
+		"Reply again with only a JSON array of objects with the fields \"claim\", \"stance\" and \"rounds\".",
+		parseErr, reply)
+}
+
+// parseClaims decodes and validates an extraction reply. It tolerates code
+// fences, surrounding prose and a {"claims": [...]} wrapper; claims are
+// trimmed and deduplicated, stances normalised and rounds limited to those the
+// agent actually spoke in.
+func parseClaims(reply string, rounds map[int]bool) ([]*models.DiscussionClaim, error) {
+	raw, err := decodeClaims(reply)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := []*models.DiscussionClaim{}
+	seen := make(map[string]bool)
+	for _, rc := range raw {
+		text := strings.Join(strings.Fields(rc.Claim), " ")
+		if text == "" {
+			continue
+		}
+		if utf8.RuneCountInString(text) > models.MaxClaimLength {
+			text = string([]rune(text)[:models.MaxClaimLength])
+		}
+		key := strings.ToLower(text)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		claimRounds := models.JSONSlice[int]{}
+		inClaim := make(map[int]bool)
+		for _, r := range rc.Rounds {
+			if rounds[r] && !inClaim[r] {
+				inClaim[r] = true
+				claimRounds = append(claimRounds, r)
+			}
+		}
+		sort.Ints(claimRounds)
+
+		claims = append(claims, &models.DiscussionClaim{
+			Claim:  text,
+			Stance: normalizeStance(rc.Stance),
+			Rounds: claimRounds,
+		})
+		if len(claims) == models.MaxClaimsPerAgent {
+			break
+		}
+	}
+
+	if len(raw) > 0 && len(claims) == 0 {
+		return nil, errors.New("no claim had any text")
+	}
+	return claims, nil
+}
+
+// decodeClaims finds and decodes the JSON in an extraction reply
+func decodeClaims(reply string) ([]rawClaim, error) {
+	text := strings.TrimSpace(reply)
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+	text = strings.TrimSpace(text)
+
+	if strings.HasPrefix(text, "{") {
+		var wrapped struct {
+			Claims []rawClaim `json:"claims"`
+		}
+		if err := json.Unmarshal([]byte(text), &wrapped); err == nil && wrapped.Claims != nil {
+			return wrapped.Claims, nil
+		}
+	}
+
+	start := strings.Index(text, "[")
+	end := strings.LastIndex(text, "]")
+	if start < 0 || end < start {
+		return nil, errors.New("no JSON array found")
+	}
+
+	var raw []rawClaim
+	if err := json.Unmarshal([]byte(text[start:end+1]), &raw); err != nil {
+		return nil, fmt.Errorf("malformed JSON: %w", err)
+	}
+	return raw, nil
+}
+
+// normalizeStance maps the extractor's stance wording onto the stored values
+func normalizeStance(stance string) string {
+	switch strings.ToLower(strings.TrimSpace(stance)) {
+	case "for", "pro", "support", "supports", "in favor", "in favour", "agree":
+		return models.ClaimStanceFor
+	case "against", "con", "oppose", "opposes", "opposed", "disagree":
+		return models.ClaimStanceAgainst
+	default:
+		return models.ClaimStanceNeutral
+	}
+}
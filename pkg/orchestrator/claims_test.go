@@ -0,0 +1,167 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+func TestParseClaims(t *testing.T) {
+	rounds := map[int]bool{1: true, 2: true}
+	tests := []struct {
+		name    string
+		reply   string
+		want    []string // claim|stance|rounds
+		wantErr string
+	}{
+		{"plain array", `[{"claim":"Tabs save bytes.","stance":"for","rounds":[1]}]`, []string{"Tabs save bytes.|for|[1]"}, ""},
+		{"code fence", "```json\n[{\"claim\":\"Tabs save bytes.\",\"stance\":\"Pro\",\"rounds\":[2,1]}]\n```", []string{"Tabs save bytes.|for|[1 2]"}, ""},
+		{"prose around", `Here you go: [{"claim":"Spaces align.","stance":"oppose","rounds":[2]}] Hope that helps.`, []string{"Spaces align.|against|[2]"}, ""},
+		{"wrapper object", `{"claims":[{"claim":"Either works.","stance":"","rounds":[]}]}`, []string{"Either works.|neutral|[]"}, ""},
+		{"duplicates merged", `[{"claim":"Tabs  save bytes.","stance":"for","rounds":[1]},{"claim":"tabs save bytes.","stance":"for","rounds":[2]}]`, []string{"Tabs save bytes.|for|[1]"}, ""},
+		{"rounds the agent never spoke in", `[{"claim":"Tabs save bytes.","stance":"for","rounds":[1,3,1,0]}]`, []string{"Tabs save bytes.|for|[1]"}, ""},
+		{"empty array", `[]`, nil, ""},
+		{"no array", `I could not find any claims.`, nil, "no JSON array"},
+		{"broken JSON", `[{"claim": "Tabs save bytes.", "stance": }]`, nil, "malformed JSON"},
+		{"only blank claims", `[{"claim":"  ","stance":"for"}]`, nil, "no claim had any text"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := parseClaims(tt.reply, rounds)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseClaims = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseClaims: %v", err)
+			}
+			var got []string
+			for _, c := range claims {
+				b, _ := json.Marshal(c.Rounds)
+				got = append(got, c.Claim+"|"+c.Stance+"|"+strings.ReplaceAll(string(b), ",", " "))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("claims =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+// newExtractorProvider answers extraction prompts with reply(prompt) and
+// counts the calls
+func newExtractorProvider(t *testing.T, reply func(prompt string) string) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("extractor got a body that is not JSON: %v", err)
+		}
+		content, _ := json.Marshal(reply(userMessage(body)))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":` + string(content) + `},"finish_reason":"stop"}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestExtractClaims(t *testing.T) {
+	de := newTestEngine(t)
+	server, calls := newExtractorProvider(t, func(prompt string) string {
+		switch {
+		case strings.Contains(prompt, `participant "Alice"`):
+			return `[{"claim":"Tabs save bytes.","stance":"for","rounds":[1,2]}]`
+		case strings.Contains(prompt, `participant "Bob"`):
+			return `Sure! {"claim": "Spaces align code." BOB`
+		case strings.Contains(prompt, "could not be used") && strings.Contains(prompt, "BOB"):
+			return `[{"claim":"Spaces align code.","stance":"against","rounds":[1]}]`
+		default:
+			// Carol's extraction stays malformed after the repair
+			return "I would rather not."
+		}
+	})
+	extractor := insertTestAgent(t, de, "Extractor", server.URL)
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+	bob := insertTestAgent(t, de, "Bob", server.URL)
+	carol := insertTestAgent(t, de, "Carol", server.URL)
+	discussion := insertTestDiscussion(t, de, "completed", alice, bob, carol)
+	for round := 1; round <= 2; round++ {
+		for _, agent := range []*models.Agent{alice, bob, carol} {
+			l := &models.DiscussionLog{DiscussionID: discussion.ID, AgentID: agent.ID, Content: agent.Name + " argues", Status: "success", LogType: models.LogTypeResponse, Metadata: models.JSONMap{"round": strconv.Itoa(round)}}
+			if err := de.db.InsertDiscussionLog(l); err != nil {
+				t.Fatalf("InsertDiscussionLog: %v", err)
+			}
+		}
+	}
+
+	var progress [][2]int64
+	err := de.ExtractClaims(context.Background(), discussion.ID, extractor.ID, func(done, total int64) {
+		progress = append(progress, [2]int64{done, total})
+	})
+	if err != nil {
+		t.Fatalf("ExtractClaims: %v, want success while one agent fails", err)
+	}
+	if len(progress) != 4 || progress[3] != [2]int64{3, 3} {
+		t.Errorf("progress = %v, want 0 to 3 of 3", progress)
+	}
+	// Alice once, Bob with a repair, Carol with a failed repair
+	if calls.Load() != 5 {
+		t.Errorf("extractor called %d times, want 5", calls.Load())
+	}
+
+	stored, err := de.db.GetDiscussionClaims(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionClaims: %v", err)
+	}
+	extractions := make(map[int64]*models.ClaimExtraction)
+	for _, e := range stored.Extractions {
+		extractions[e.AgentID] = e
+	}
+	if e := extractions[alice.ID]; e == nil || e.Status != models.ClaimExtractionSuccess || e.Attempts != 1 || e.ClaimCount != 1 {
+		t.Errorf("Alice's extraction = %+v, want one claim in one attempt", e)
+	}
+	if e := extractions[bob.ID]; e == nil || e.Status != models.ClaimExtractionSuccess || e.Attempts != 2 || e.ClaimCount != 1 {
+		t.Errorf("Bob's extraction = %+v, want one claim after a repair", e)
+	}
+	if e := extractions[carol.ID]; e == nil || e.Status != models.ClaimExtractionFailed || e.Attempts != 2 || !strings.Contains(e.Error, "invalid extraction reply") {
+		t.Errorf("Carol's extraction = %+v, want a failure after the repair", e)
+	}
+
+	byAgent := make(map[int64]string)
+	for _, c := range stored.Claims {
+		byAgent[c.AgentID] += c.Claim + "/" + c.Stance
+	}
+	if byAgent[alice.ID] != "Tabs save bytes./for" || byAgent[bob.ID] != "Spaces align code./against" || byAgent[carol.ID] != "" {
+		t.Errorf("claims by agent = %v", byAgent)
+	}
+}
+
+func TestExtractClaimsAllFailing(t *testing.T) {
+	de := newTestEngine(t)
+	server, _ := newExtractorProvider(t, func(string) string { return "no" })
+	extractor := insertTestAgent(t, de, "Extractor", server.URL)
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+	discussion := insertTestDiscussion(t, de, "completed", alice)
+	if err := de.db.InsertDiscussionLog(&models.DiscussionLog{DiscussionID: discussion.ID, AgentID: alice.ID, Content: "Tabs.", Status: "success", LogType: models.LogTypeResponse}); err != nil {
+		t.Fatalf("InsertDiscussionLog: %v", err)
+	}
+
+	err := de.ExtractClaims(context.Background(), discussion.ID, extractor.ID, func(int64, int64) {})
+	if err == nil || !strings.Contains(err.Error(), "failed for all 1 agents") {
+		t.Errorf("ExtractClaims = %v, want the job to fail", err)
+	}
+	stored, err := de.db.GetDiscussionClaims(discussion.ID)
+	if err != nil || len(stored.Extractions) != 1 || stored.Extractions[0].Status != models.ClaimExtractionFailed {
+		t.Errorf("stored = %+v, %v; want the failure recorded", stored, err)
+	}
+}
@@ -0,0 +1,128 @@
+package orchestrator
+
+import (
+	"court-table-ai/pkg/models"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// compact reports whether the discussion uses the compact prompt profile
+func compact(discussion *models.Discussion) bool {
+	return discussion.PromptProfile() == models.PromptProfileCompact
+}
+
+// compactReplyRule is the single instruction on length and form closing
+// every compact prompt
+func compactReplyRule(discussion *models.Discussion) string {
+	return fmt.Sprintf("Reply in %s, one paragraph, no lists or headings, at most %d characters.",
+		discussion.LanguageName(), discussion.MaxCharLimit)
+}
+
+// compactStance is the one-line form of stanceGuidance
+func compactStance(stance string) string {
+	switch stance {
+	case models.StancePro:
+		return "Argue FOR the topic and never switch sides.\n"
+	case models.StanceCon:
+		return "Argue AGAINST the topic and never switch sides.\n"
+	case models.StanceNeutral:
+		return "Stay NEUTRAL and weigh both sides.\n"
+	}
+	return ""
+}
+
+// buildCompactPrompt creates an agent's turn prompt in the compact profile;
+// agentNum is only used after the first round
+func buildCompactPrompt(discussion *models.Discussion, agentID int64, round, agentNum int) string {
+	var prompt strings.Builder
+	prompt.WriteString(fmt.Sprintf("Debate topic: %s\n", promptTopic(discussion.Topic)))
+	if round <= 1 {
+		prompt.WriteString("Give your opening view with one key reason.\n")
+	} else {
+		prompt.WriteString(fmt.Sprintf("Round %d. You are Agent #%d. Answer the strongest point made so far.\n", round, agentNum))
+	}
+	prompt.WriteString(compactStance(discussion.StanceOf(agentID)))
+	prompt.WriteString(compactReplyRule(discussion))
+	return prompt.String()
+}
+
+// buildCompactModeratorPrompt creates a moderator prompt in the compact profile
+func (de *DebateEngine) buildCompactModeratorPrompt(discussion *models.Discussion, moderatorType string, contextStr string) string {
+	base := fmt.Sprintf("You moderate a debate on: %s\n", promptTopic(discussion.Topic))
+
+	switch moderatorType {
+	case "opening":
+		names := make([]string, 0, len(discussion.AgentIDs))
+		for _, id := range discussion.AgentIDs {
+			name := fmt.Sprintf("Agent #%d", id)
+			if agentName, err := de.db.GetAgentName(id); err == nil {
+				name = agentName
+			}
+			if stance := discussion.StanceOf(id); stance != "" {
+				name += " (" + strings.ToUpper(stance) + ")"
+			}
+			names = append(names, name)
+		}
+		return base + fmt.Sprintf("Open it and introduce the speakers in order: %s.\n", strings.Join(names, ", ")) + compactReplyRule(discussion)
+	case "interim":
+		return base + "Note the key point of this reply and invite the next speaker:\n" + fence(contextStr) + "\n" + compactReplyRule(discussion)
+	case "round_summary":
+		return base + "Sum up this round's agreements and disagreements.\n" + compactReplyRule(discussion)
+	case "closing":
+		return base + "Close it: the strongest arguments and what is still open.\n" + compactReplyRule(discussion)
+	case consensusPhase:
+		return base + "This round's replies:\n" + fence(contextStr) +
+			"\nHave the positions converged? Start with YES or NO, in English, then give one sentence in " + discussion.LanguageName() + "."
+	default:
+		return base + compactReplyRule(discussion)
+	}
+}
+
+// compactMaxTokens caps the tokens requested for a compact reply. A token
+// is at least one character in every script the debate may use, so the
+// limit plus some headroom never cuts a reply short. A scratchpad adds notes
+// outside the limit, so with one there is no cap.
+func compactMaxTokens(discussion *models.Discussion) int {
+	if !compact(discussion) || discussion.Settings.Scratchpad {
+		return 0
+	}
+	return discussion.MaxCharLimit + 64
+}
+
+// sentenceEnds are the punctuation marks closing a sentence
+const sentenceEnds = ".!?。！？"
+
+// truncateAtSentence cuts content to limit characters like truncateResponse,
+// but ends on the last full sentence when one ends in the second half of the
+// allowance, so a short reply reads as finished rather than cut off
+func truncateAtSentence(content string, limit int) string {
+	if limit <= 0 || utf8.RuneCountInString(content) <= limit {
+		return content
+	}
+
+	// Byte offset after the first limit runes
+	cut := 0
+	for i := 0; i < limit; i++ {
+		_, size := utf8.DecodeRuneInString(content[cut:])
+		cut += size
+	}
+
+	end := -1
+	for i, r := range content[:cut] {
+		if !strings.ContainsRune(sentenceEnds, r) {
+			continue
+		}
+		next := i + utf8.RuneLen(r)
+		following, _ := utf8.DecodeRuneInString(content[next:])
+		// CJK full stops need no space after them
+		if next == len(content) || unicode.IsSpace(following) || r >= 0x3000 {
+			end = next
+		}
+	}
+	if end > cut/2 {
+		return strings.TrimSpace(content[:end])
+	}
+	return truncateResponse(content, limit)
+}
@@ -0,0 +1,142 @@
+package orchestrator
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"court-table-ai/pkg/models"
+)
+
+func TestCompactPrompts(t *testing.T) {
+	de := newTestEngine(t)
+	alice := insertTestAgent(t, de, "Alice", "http://127.0.0.1:1")
+	bob := insertTestAgent(t, de, "Bob", "http://127.0.0.1:1")
+	discussion := &models.Discussion{
+		Topic:        "Tabs or spaces",
+		Language:     "en",
+		MaxCharLimit: 280,
+		AgentIDs:     models.JSONSlice[int64]{alice.ID, bob.ID},
+		Stances:      []models.AgentStance{{AgentID: alice.ID, Stance: models.StancePro}},
+	}
+	if got := discussion.PromptProfile(); got != models.PromptProfileCompact {
+		t.Fatalf("profile at 280 characters = %q, want compact", got)
+	}
+
+	prompts := map[string]string{
+		"opening turn":  de.buildPrompt(discussion, alice.ID),
+		"later turn":    de.buildRoundPrompt(discussion, bob.ID, 2, 2, 2),
+		"opening":       de.buildModeratorPrompt(discussion, "opening", ""),
+		"round summary": de.buildModeratorPrompt(discussion, "round_summary", ""),
+		"closing":       de.buildModeratorPrompt(discussion, "closing", ""),
+		"interim":       de.buildModeratorPrompt(discussion, "interim", "Spaces align."),
+	}
+	for name, prompt := range prompts {
+		// The instructions themselves stay shorter than the reply they ask for
+		if n := utf8.RuneCountInString(prompt); n > 280 {
+			t.Errorf("%s prompt has %d characters:\n%s", name, n, prompt)
+		}
+		if !strings.HasSuffix(prompt, "one paragraph, no lists or headings, at most 280 characters.") {
+			t.Errorf("%s prompt does not end with the reply rule:\n%s", name, prompt)
+		}
+		if strings.Contains(prompt, "Guidelines") || strings.Contains(prompt, "\n- ") {
+			t.Errorf("%s prompt carries a guidelines list:\n%s", name, prompt)
+		}
+	}
+	if !strings.Contains(prompts["opening turn"], "Argue FOR the topic") || strings.Contains(prompts["later turn"], "Argue") {
+		t.Errorf("stances are not kept to their agents:\n%s\n%s", prompts["opening turn"], prompts["later turn"])
+	}
+	if !strings.Contains(prompts["opening"], "Alice (PRO), Bob") {
+		t.Errorf("opening does not introduce the speakers:\n%s", prompts["opening"])
+	}
+	// The consensus check is still answered in English
+	if check := de.buildModeratorPrompt(discussion, consensusPhase, "Alice: spaces"); !strings.Contains(check, "Start with YES or NO") {
+		t.Errorf("consensus prompt = %q", check)
+	}
+
+	standard := &models.Discussion{Topic: "Tabs or spaces", Language: "en", MaxCharLimit: 1000, AgentIDs: models.JSONSlice[int64]{alice.ID}}
+	if prompt := de.buildPrompt(standard, alice.ID); utf8.RuneCountInString(prompt) <= utf8.RuneCountInString(prompts["opening turn"]) {
+		t.Errorf("standard prompt is no longer than the compact one:\n%s", prompt)
+	}
+}
+
+func TestCompactMaxTokens(t *testing.T) {
+	tests := []struct {
+		limit      int
+		settings   models.DiscussionSettings
+		wantTokens int
+	}{
+		{280, models.DiscussionSettings{}, 344},
+		{1000, models.DiscussionSettings{}, 0},
+		{1000, models.DiscussionSettings{PromptProfile: models.PromptProfileCompact}, 1064},
+		{280, models.DiscussionSettings{Scratchpad: true}, 0},
+	}
+	for _, tt := range tests {
+		d := &models.Discussion{MaxCharLimit: tt.limit, Settings: tt.settings}
+		if got := compactMaxTokens(d); got != tt.wantTokens {
+			t.Errorf("compactMaxTokens(limit %d, %+v) = %d, want %d", tt.limit, tt.settings, got, tt.wantTokens)
+		}
+	}
+}
+
+func TestTruncateAtSentence(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		limit   int
+		want    string
+	}{
+		{"fits", "Spaces. Always.", 20, "Spaces. Always."},
+		{"last full sentence", "Spaces keep diffs aligned. Tabs let readers choose a width.", 40, "Spaces keep diffs aligned."},
+		{"question and exclamation", "Why tabs? Spaces win! Every editor agrees", 30, "Why tabs? Spaces win!"},
+		{"CJK full stop", "空格更好。制表符不好。因为对齐很重要", 12, "空格更好。制表符不好。"},
+		{"sentence too early", "Yes. Spaces keep every diff aligned across editors", 30, truncateResponse("Yes. Spaces keep every diff aligned across editors", 30)},
+		{"no sentence", "Spaces keep every diff aligned across editors", 20, truncateResponse("Spaces keep every diff aligned across editors", 20)},
+		{"dot inside a word", "Use gofmt.Source and v1.2 for aligned diffs forever", 30, truncateResponse("Use gofmt.Source and v1.2 for aligned diffs forever", 30)},
+	}
+	for _, tt := range tests {
+		got := truncateAtSentence(tt.content, tt.limit)
+		if got != tt.want {
+			t.Errorf("%s: truncateAtSentence(%q, %d) = %q, want %q", tt.name, tt.content, tt.limit, got, tt.want)
+		}
+		if utf8.RuneCountInString(got) > tt.limit {
+			t.Errorf("%s: result has %d characters, over the limit of %d", tt.name, utf8.RuneCountInString(got), tt.limit)
+		}
+	}
+}
+
+func TestCompactDebate(t *testing.T) {
+	de := newTestEngine(t)
+	reply := strings.Repeat("Spaces keep every diff aligned. ", 10) + "Tabs"
+	server, bodies := newBodyProvider(t, reply)
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+
+	settings := models.DiscussionSettings{SummaryBackend: models.SummaryBackendExtractive}
+	discussion, err := de.RunDebate(context.Background(), "Tabs or spaces", []int64{alice.ID}, nil, nil, nil, 1, "en", 280, settings)
+	if err != nil {
+		t.Fatalf("RunDebate: %v", err)
+	}
+	waitUntil(t, "the debate to end", func() bool {
+		d, err := de.db.GetDiscussion(discussion.ID)
+		return err == nil && !d.InProgress()
+	})
+
+	got := bodies()
+	if len(got) != 1 || got[0]["max_tokens"] != float64(344) {
+		t.Fatalf("requests = %v, want one asking for 344 tokens", got)
+	}
+	logs, err := de.db.GetDiscussionLogs(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	for _, l := range logs {
+		if l.AgentID != alice.ID {
+			continue
+		}
+		// Eight full sentences fit in 280 characters
+		if want := strings.TrimSpace(strings.Repeat("Spaces keep every diff aligned. ", 8)); l.Content != want {
+			t.Errorf("stored reply = %q, want it cut after the last full sentence", l.Content)
+		}
+	}
+}
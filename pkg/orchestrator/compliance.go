@@ -0,0 +1,159 @@
+package orchestrator
+
+import (
+	"court-table-ai/pkg/models"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Minimum evidence before detectLanguage commits to an answer
+const (
+	minLanguageStopwords = 3
+	minScriptRunes       = 10
+)
+
+// languageStopwords holds frequent function words for the Latin-script
+// languages offered when starting a discussion, by language code
+var languageStopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "of", "to", "that", "this", "with", "for", "it", "not", "be", "have"},
+	"id": {"yang", "dan", "di", "ini", "itu", "dengan", "untuk", "tidak", "adalah", "dari", "dalam", "akan", "juga", "kita"},
+	"es": {"el", "la", "los", "las", "que", "es", "por", "para", "con", "una", "del", "pero", "como", "más"},
+	"fr": {"le", "la", "les", "est", "que", "et", "des", "une", "pour", "pas", "dans", "qui", "avec", "sur"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "mit", "zu", "den", "auf", "für", "sich"},
+}
+
+// detectLanguage makes a best-effort guess at the language code of text. It
+// only knows the languages offered in the UI and returns "" when unsure.
+func detectLanguage(text string) string {
+	var kana, han int
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		}
+	}
+	if kana >= minScriptRunes {
+		return "ja"
+	}
+	if han >= minScriptRunes {
+		return "zh"
+	}
+
+	counts := make(map[string]int)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		for lang, stopwords := range languageStopwords {
+			for _, sw := range stopwords {
+				if word == sw {
+					counts[lang]++
+				}
+			}
+		}
+	}
+
+	best, bestCount, runnerUp := "", 0, 0
+	for lang, n := range counts {
+		if n > bestCount || (n == bestCount && lang < best) {
+			best, bestCount, runnerUp = lang, n, bestCount
+		} else if n > runnerUp {
+			runnerUp = n
+		}
+	}
+	// Short or mixed replies are not judged
+	if bestCount < minLanguageStopwords || bestCount < runnerUp*3/2 {
+		return ""
+	}
+	return best
+}
+
+// complianceMetadata records how a raw agent reply measured up against the
+// discussion's character limit and language, before any truncation
+func complianceMetadata(discussion *models.Discussion, raw string) models.JSONMap {
+	rawChars := utf8.RuneCountInString(raw)
+	metadata := models.JSONMap{"raw_chars": strconv.Itoa(rawChars)}
+
+	if discussion.MaxCharLimit > 0 && rawChars > discussion.MaxCharLimit {
+		metadata["over_limit_by"] = strconv.Itoa(rawChars - discussion.MaxCharLimit)
+	}
+
+	detected := detectLanguage(raw)
+	language, known := models.NormalizeLanguage(discussion.Language)
+	switch {
+	case detected == "" || !known:
+		metadata["language_match"] = "unknown"
+	case detected == language:
+		metadata["language_match"] = "true"
+	default:
+		metadata["language_match"] = "false"
+	}
+	if detected != "" {
+		metadata["detected_language"] = detected
+	}
+
+	return metadata
+}
+
+// ComplianceReport aggregates per-agent compliance from the metadata stored
+// on successful and rejected agent turns. Entries written before compliance
+// tracking are ignored.
+func ComplianceReport(logs []*models.DiscussionLog) []models.AgentCompliance {
+	byAgent := make(map[int64]*models.AgentCompliance)
+	for _, l := range logs {
+		if l.IsModerator || l.IsSystem() || l.LogType != models.LogTypeResponse {
+			continue
+		}
+		if l.Status != "success" && l.Status != "rejected" {
+			continue
+		}
+		if _, ok := l.Metadata["raw_chars"]; !ok {
+			continue
+		}
+
+		c, ok := byAgent[l.AgentID]
+		if !ok {
+			c = &models.AgentCompliance{AgentID: l.AgentID}
+			byAgent[l.AgentID] = c
+		}
+
+		if l.Status == "rejected" {
+			c.Rejections++
+			if reason := l.Metadata["rejection_reason"]; reason != "" {
+				c.RejectionReasons = append(c.RejectionReasons, reason)
+			}
+			continue
+		}
+
+		c.Turns++
+		if l.Metadata["injection_flagged"] == "true" {
+			c.InjectionFlags++
+			c.FlaggedLogIDs = append(c.FlaggedLogIDs, l.ID)
+		}
+		if over, err := strconv.Atoi(l.Metadata["over_limit_by"]); err == nil && over > 0 {
+			c.OverLimitTurns++
+			c.TotalOverLimitBy += over
+			if over > c.MaxOverLimitBy {
+				c.MaxOverLimitBy = over
+			}
+		}
+		switch l.Metadata["language_match"] {
+		case "true":
+			c.LanguageChecked++
+		case "false":
+			c.LanguageChecked++
+			c.LanguageMismatches++
+		}
+	}
+
+	report := make([]models.AgentCompliance, 0, len(byAgent))
+	for _, c := range byAgent {
+		report = append(report, *c)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].AgentID < report[j].AgentID })
+	return report
+}
@@ -0,0 +1,71 @@
+package orchestrator
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"The point is that this is not the best way to do it, and the cost is high.", "en"},
+		{"Ini adalah cara yang tidak baik untuk kita dan itu juga mahal.", "id"},
+		{"Le coût est trop élevé et ce n'est pas la bonne façon pour les équipes qui travaillent.", "fr"},
+		{"Das ist nicht die beste Lösung und der Preis ist für die meisten zu hoch.", "de"},
+		{"El precio es alto y la solución que proponen no es para los equipos pequeños.", "es"},
+		{"これはとても良い考えですが、コストがかかりすぎると思います。", "ja"},
+		{"我认为这个方案的成本太高了，而且效率也不够好。", "zh"},
+		{"Yes.", ""},
+		{"12345 67890", ""},
+	}
+	for _, tt := range tests {
+		if got := detectLanguage(tt.text); got != tt.want {
+			t.Errorf("detectLanguage(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestComplianceReportFromScriptedTurns(t *testing.T) {
+	de := newTestEngine(t)
+	english := "The cost is high and the benefit is not clear. That is the problem with this plan for the team."
+	french := "Le coût est trop élevé et ce n'est pas la bonne façon pour les équipes."
+	verbose, _ := newTestProvider(t, english)
+	foreign, _ := newTestProvider(t, french)
+	alice := insertTestAgent(t, de, "Alice", verbose.URL)
+	bob := insertTestAgent(t, de, "Bob", foreign.URL)
+	discussion := insertTestDiscussion(t, de, "running", alice, bob)
+	discussion.Language = "en"
+	discussion.MaxCharLimit = 80
+
+	for round := 1; round <= 2; round++ {
+		de.takeTurn(context.Background(), discussion, alice, round, "", "Your turn", "", 0, 0, runNow)
+		de.takeTurn(context.Background(), discussion, bob, round, "", "Your turn", "", 0, 0, runNow)
+	}
+
+	logs, err := de.db.GetDiscussionLogs(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	for _, l := range logs {
+		if l.AgentID == alice.ID && (l.Metadata["raw_chars"] != "95" || l.Metadata["over_limit_by"] != "15" || len([]rune(l.Content)) > 80) {
+			t.Errorf("Alice's entry: %d chars stored, metadata %v; want 95 raw chars, 15 over and the stored reply truncated", len([]rune(l.Content)), l.Metadata)
+		}
+		if l.AgentID == bob.ID && (l.Metadata["detected_language"] != "fr" || l.Metadata["language_match"] != "false" || l.Metadata["over_limit_by"] != "") {
+			t.Errorf("Bob's entry metadata = %v, want French flagged as a mismatch within the limit", l.Metadata)
+		}
+	}
+
+	report := ComplianceReport(logs)
+	if len(report) != 2 {
+		t.Fatalf("report = %+v, want one entry per agent", report)
+	}
+	a, b := report[0], report[1]
+	if a.AgentID != alice.ID || a.Turns != 2 || a.OverLimitTurns != 2 || a.TotalOverLimitBy != 30 || a.MaxOverLimitBy != 15 || a.LanguageChecked != 2 || a.LanguageMismatches != 0 {
+		t.Errorf("Alice = %+v, want two over-limit turns in the right language", a)
+	}
+	if b.AgentID != bob.ID || b.Turns != 2 || b.OverLimitTurns != 0 || b.LanguageChecked != 2 || b.LanguageMismatches != 2 {
+		t.Errorf("Bob = %+v, want two wrong-language turns within the limit", b)
+	}
+}
@@ -0,0 +1,36 @@
+package orchestrator
+
+import (
+	"context"
+	"court-table-ai/pkg/models"
+	"strings"
+	"unicode"
+)
+
+// consensusPhase is the moderator phase asking whether a debate has converged
+const consensusPhase = "consensus_check"
+
+// checkConsensus asks checker whether the positions in roundText have
+// substantially converged. The call is logged as a moderator entry. A failed
+// call or an answer that is neither yes nor no counts as no consensus, so the
+// debate goes on.
+func (de *DebateEngine) checkConsensus(ctx context.Context, discussion *models.Discussion, checker *models.Agent, roundText string) bool {
+	if checker == nil || !de.waitIfPaused(ctx, discussion.ID) {
+		return false
+	}
+	logEntry := de.runModerator(ctx, discussion, checker, consensusPhase, roundText, 0)
+	if logEntry == nil || logEntry.Status != "success" {
+		return false
+	}
+	// Skip the "[Moderator - ...]" heading runModerator puts on the reply
+	_, reply, _ := strings.Cut(logEntry.Content, "\n")
+	return consensusAnswer(reply)
+}
+
+// consensusAnswer reports whether a consensus check reply starts with yes
+func consensusAnswer(reply string) bool {
+	words := strings.FieldsFunc(reply, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	return len(words) > 0 && strings.EqualFold(words[0], "yes")
+}
@@ -0,0 +1,170 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+)
+
+func TestConsensusAnswer(t *testing.T) {
+	tests := []struct {
+		reply string
+		want  bool
+	}{
+		{"YES. Both now favour spaces.", true},
+		{"yes", true},
+		{"  **Yes** - they agree.", true},
+		{"Yes, but only just.", true},
+		{"NO. Bob still prefers tabs.", false},
+		{"Yesterday they disagreed.", false},
+		{"They agree: yes.", false},
+		{"Oui", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := consensusAnswer(tt.reply); got != tt.want {
+			t.Errorf("consensusAnswer(%q) = %v, want %v", tt.reply, got, tt.want)
+		}
+	}
+}
+
+// newConsensusProvider answers debate turns and moderation with a fixed
+// reply and consensus checks, told apart by their prompt, with status and
+// answer. It counts the consensus checks.
+func newConsensusProvider(t *testing.T, status int, answer string) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var checks atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("provider got a body that is not JSON: %v", err)
+		}
+		reply := "Spaces keep diffs aligned."
+		if strings.Contains(allMessages(body), "substantially converged") {
+			checks.Add(1)
+			if status != http.StatusOK {
+				http.Error(w, `{"error":{"message":"overloaded"}}`, status)
+				return
+			}
+			reply = answer
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":%q},"finish_reason":"stop"}]}`, reply)
+	}))
+	t.Cleanup(server.Close)
+	return server, &checks
+}
+
+// consensusLogs returns the consensus check entries and the consensus notes
+// of a discussion
+func consensusLogs(t *testing.T, de *DebateEngine, discussionID int64) (checks, notes []*models.DiscussionLog) {
+	t.Helper()
+	logs, err := de.db.GetDiscussionLogs(discussionID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	for _, l := range logs {
+		switch {
+		case l.IsModerator && l.Metadata["moderator_phase"] == consensusPhase:
+			checks = append(checks, l)
+		case l.LogType == models.LogTypeSystem && l.Metadata["consensus_round"] != "":
+			notes = append(notes, l)
+		}
+	}
+	return checks, notes
+}
+
+func TestConsensusCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		answer     string
+		moderator  bool
+		wantChecks int
+		wantReason string
+		wantRounds int
+	}{
+		{"converged", http.StatusOK, "YES. Both favour spaces.", true, 1, models.EndReasonConsensus, 1},
+		{"converged without a moderator", http.StatusOK, "Yes.", false, 1, models.EndReasonConsensus, 1},
+		{"still apart", http.StatusOK, "NO. Tabs still have a defender.", true, 2, models.EndReasonMaxRounds, 3},
+		{"unclear answer", http.StatusOK, "Perhaps.", true, 2, models.EndReasonMaxRounds, 3},
+		{"check failed", http.StatusBadRequest, "", true, 2, models.EndReasonMaxRounds, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			de := newTestEngine(t)
+			if err := de.db.SetSettingJSON(database.SettingAgentRetries, models.AgentRetryConfig{MaxRetries: 0, BaseDelayMs: 1, MaxDelayMs: 1}); err != nil {
+				t.Fatalf("SetSettingJSON: %v", err)
+			}
+			server, calls := newConsensusProvider(t, tt.status, tt.answer)
+			alice := insertTestAgent(t, de, "Alice", server.URL)
+			bob := insertTestAgent(t, de, "Bob", server.URL)
+			var moderatorID *int64
+			if tt.moderator {
+				sage := insertTestAgent(t, de, "Sage", server.URL)
+				moderatorID = &sage.ID
+			}
+
+			settings := models.DiscussionSettings{EnableConsensusCheck: true, SummaryBackend: models.SummaryBackendExtractive}
+			discussion, err := de.RunDebate(context.Background(), "Tabs or spaces", []int64{alice.ID, bob.ID}, nil, moderatorID, nil, 3, "en", models.DefaultMaxCharLimit, settings)
+			if err != nil {
+				t.Fatalf("RunDebate: %v", err)
+			}
+			var d *models.Discussion
+			waitUntil(t, "the debate to end", func() bool {
+				d, err = de.db.GetDiscussion(discussion.ID)
+				return err == nil && !d.InProgress()
+			})
+
+			// A failed check never fails the debate, and there is no check
+			// after the last round
+			if d.Status != "completed" || d.EndReason != tt.wantReason || d.CompletedRounds != tt.wantRounds {
+				t.Errorf("ended %s (%s) after %d rounds, want completed (%s) after %d", d.Status, d.EndReason, d.CompletedRounds, tt.wantReason, tt.wantRounds)
+			}
+			if got := calls.Load(); got != int64(tt.wantChecks) {
+				t.Errorf("made %d consensus checks, want %d", got, tt.wantChecks)
+			}
+			checks, notes := consensusLogs(t, de, d.ID)
+			if len(checks) != tt.wantChecks {
+				t.Fatalf("logged %d consensus checks, want %d", len(checks), tt.wantChecks)
+			}
+			wantStatus, wantPrefix := "success", "[Moderator - Consensus Check]"
+			if tt.status != http.StatusOK {
+				wantStatus, wantPrefix = "error", "Moderator Error:"
+			}
+			for _, check := range checks {
+				if check.Status != wantStatus || !strings.HasPrefix(check.Content, wantPrefix) {
+					t.Errorf("consensus check entry = %s %q", check.Status, check.Content)
+				}
+			}
+			if tt.wantReason != models.EndReasonConsensus {
+				if len(notes) != 0 {
+					t.Errorf("logged %d consensus notes for a debate that ran every round", len(notes))
+				}
+				return
+			}
+			if len(notes) != 1 || notes[0].Content != "Consensus reached after round 1" || notes[0].Metadata["consensus_round"] != "1" {
+				t.Errorf("consensus notes = %+v, want one for round 1", notes)
+			}
+		})
+	}
+}
+
+func TestConsensusCheckOff(t *testing.T) {
+	de := newTestEngine(t)
+	server, calls := newConsensusProvider(t, http.StatusOK, "YES.")
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+
+	d := runToEnd(t, de, []*models.Agent{alice}, 2, models.DiscussionSettings{})
+	if d.EndReason != models.EndReasonMaxRounds || calls.Load() != 0 {
+		t.Errorf("ended (%s) after %d consensus checks, want every round without a check", d.EndReason, calls.Load())
+	}
+}
@@ -0,0 +1,157 @@
+package orchestrator
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ContextTruncationMarker ends a prior response that was shortened by the
+// per_turn_context_chars setting
+const ContextTruncationMarker = "…[truncated]"
+
+// contextTurn is one prior response fed to later agents
+type contextTurn struct {
+	round     int
+	agentName string
+	agentID   int64
+	content   string
+}
+
+// turnContext accumulates the debate context. Each turn is kept in full and
+// only shortened when the context is rendered for an agent.
+type turnContext struct {
+	perTurnChars int
+	// guarded fences each turn as quoted material, see InjectionGuard
+	guarded bool
+	turns   []contextTurn
+	// closing holds the closing statements of the lightning round
+	closing []contextTurn
+}
+
+// add records a successful response
+func (tc *turnContext) add(round int, agentName string, agentID int64, content string) {
+	tc.turns = append(tc.turns, contextTurn{round: round, agentName: agentName, agentID: agentID, content: content})
+}
+
+// addClosing records a closing statement of the lightning round
+func (tc *turnContext) addClosing(agentName string, agentID int64, content string) {
+	tc.closing = append(tc.closing, contextTurn{agentName: agentName, agentID: agentID, content: content})
+}
+
+// String renders the context sent to agents, truncating each turn
+func (tc *turnContext) String() string {
+	return tc.render(tc.perTurnChars, 0)
+}
+
+// Full renders the context without truncation, followed by the closing
+// statements
+func (tc *turnContext) Full() string {
+	full := tc.render(0, 0)
+	if len(tc.closing) == 0 {
+		return full
+	}
+
+	var b strings.Builder
+	b.WriteString(full)
+	b.WriteString("\n\nClosing statements:")
+	for _, turn := range tc.closing {
+		b.WriteString("\n\n")
+		label := fmt.Sprintf("Agent %s (%d)", turn.agentName, turn.agentID)
+		if tc.guarded {
+			b.WriteString(quoteTurn(label, turn.content))
+			continue
+		}
+		b.WriteString(label + ":\n" + turn.content)
+	}
+	return b.String()
+}
+
+// ClosingStatements lists the closing statements for readers, one
+// "- Name: statement" line each, or returns "" when there are none
+func (tc *turnContext) ClosingStatements() string {
+	if len(tc.closing) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Closing statements:\n")
+	for _, turn := range tc.closing {
+		b.WriteString(fmt.Sprintf("- %s: %s\n", turn.agentName, strings.Join(strings.Fields(turn.content), " ")))
+	}
+	return b.String()
+}
+
+// Round renders the turns of one round without truncation
+func (tc *turnContext) Round(round int) string {
+	return tc.render(0, round)
+}
+
+// render renders the turns of round, or of every round when round is zero
+func (tc *turnContext) render(limit, round int) string {
+	var b strings.Builder
+	for _, turn := range tc.turns {
+		if round > 0 && turn.round != round {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		} else if tc.guarded {
+			b.WriteString(guardedContextNote)
+			b.WriteString("\n\n")
+		}
+		if tc.guarded {
+			label := fmt.Sprintf("Round %d - Agent %s (%d)", turn.round, turn.agentName, turn.agentID)
+			b.WriteString(quoteTurn(label, truncateTurn(turn.content, limit)))
+			continue
+		}
+		b.WriteString(fmt.Sprintf("Round %d - Agent %s (%d):", turn.round, turn.agentName, turn.agentID))
+		b.WriteString("\n")
+		b.WriteString(truncateTurn(turn.content, limit))
+	}
+	return b.String()
+}
+
+// truncateTurn shortens content to at most limit characters plus the
+// truncation marker. It prefers to cut after the last full sentence, then at
+// a word boundary, as long as that keeps at least half of the allowance.
+// A limit of zero or less leaves content unchanged.
+func truncateTurn(content string, limit int) string {
+	runes := []rune(content)
+	if limit <= 0 || len(runes) <= limit {
+		return content
+	}
+
+	end := limit
+	if i := lastSentenceEnd(runes, limit); i >= limit/2 {
+		end = i
+	} else if i := lastSpace(runes, limit); i >= limit/2 {
+		end = i
+	}
+
+	return strings.TrimRightFunc(string(runes[:end]), unicode.IsSpace) + " " + ContextTruncationMarker
+}
+
+// lastSentenceEnd returns the index just past the last sentence terminator
+// within the first limit runes that is followed by whitespace, or -1
+func lastSentenceEnd(runes []rune, limit int) int {
+	for i := limit - 1; i >= 0; i-- {
+		switch runes[i] {
+		case '.', '!', '?', '\n':
+			if unicode.IsSpace(runes[i+1]) {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// lastSpace returns the index of the last whitespace rune within the first
+// limit runes, or -1
+func lastSpace(runes []rune, limit int) int {
+	for i := limit - 1; i >= 0; i-- {
+		if unicode.IsSpace(runes[i]) {
+			return i
+		}
+	}
+	return -1
+}
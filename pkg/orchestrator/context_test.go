@@ -0,0 +1,111 @@
+package orchestrator
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+func TestTruncateTurn(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		limit   int
+		want    string
+	}{
+		{"disabled", "One. Two. Three.", 0, "One. Two. Three."},
+		{"fits", "One. Two.", 9, "One. Two."},
+		{"sentence boundary", "First point here. Second point is longer.", 30, "First point here. " + ContextTruncationMarker},
+		{"question and exclamation", "Really? Yes! And then some more words", 14, "Really? Yes! " + ContextTruncationMarker},
+		{"newline ends a sentence", "Heading\nbody text that runs on and on", 20, "Heading\nbody text " + ContextTruncationMarker},
+		// A sentence end in the first half would throw away too much, so
+		// the cut falls back to the last word
+		{"sentence too early", "Hi. This sentence keeps going for quite a while", 30, "Hi. This sentence keeps going " + ContextTruncationMarker},
+		{"abbreviation without a space", "Version 2.5is great and more words follow", 12, "Version " + ContextTruncationMarker},
+		{"no spaces at all", strings.Repeat("x", 40), 10, strings.Repeat("x", 10) + " " + ContextTruncationMarker},
+		{"multi-byte runes", "Привет мир. Как дела сегодня?", 15, "Привет мир. " + ContextTruncationMarker},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateTurn(tt.content, tt.limit); got != tt.want {
+				t.Errorf("truncateTurn(%q, %d) = %q, want %q", tt.content, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTurnContextKeepsTurnsWhole(t *testing.T) {
+	long := "Tabs keep files small. They also let every reader pick an indent width."
+	tc := &turnContext{perTurnChars: 30}
+	tc.add(1, "Alice", 1, long)
+	tc.add(1, "Bob", 2, "Spaces.")
+
+	rendered := tc.String()
+	if !strings.Contains(rendered, "Tabs keep files small. "+ContextTruncationMarker) || strings.Contains(rendered, "indent width") {
+		t.Errorf("String() =\n%s\nwant Alice's turn truncated", rendered)
+	}
+	if !strings.Contains(rendered, "Round 1 - Agent Bob (2):\nSpaces.") {
+		t.Errorf("String() =\n%s\nwant Bob's short turn untouched", rendered)
+	}
+	if full := tc.Full(); !strings.Contains(full, long) || strings.Contains(full, ContextTruncationMarker) {
+		t.Errorf("Full() =\n%s\nwant every turn whole", full)
+	}
+	if round := tc.Round(1); !strings.Contains(round, long) {
+		t.Errorf("Round(1) =\n%s\nwant every turn whole", round)
+	}
+}
+
+func TestPerTurnContextCharsLeavesLogsComplete(t *testing.T) {
+	de := newTestEngine(t)
+	reply := "Tabs keep source files small on every disk. They also let every reader pick an indent width. And they are one keystroke, which matters."
+	server, bodies := newBodyProvider(t, reply)
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+	bob := insertTestAgent(t, de, "Bob", server.URL)
+
+	settings := models.DiscussionSettings{SummaryBackend: models.SummaryBackendExtractive, PerTurnContextChars: 60}
+	discussion, err := de.RunDebate(context.Background(), "Tabs or spaces", []int64{alice.ID, bob.ID}, nil, nil, nil, 2, "en", models.DefaultMaxCharLimit, settings)
+	if err != nil {
+		t.Fatalf("RunDebate: %v", err)
+	}
+	endedBeforeCleanup(t, de, discussion.ID)
+	waitUntil(t, "every turn", func() bool { return len(bodies()) >= 4 })
+
+	// In round two both round one replies arrive cut at a sentence
+	prompt := systemMessage(bodies()[2]) + userMessage(bodies()[2])
+	if strings.Count(prompt, "Tabs keep source files small on every disk. "+ContextTruncationMarker) != 2 || strings.Contains(prompt, "indent width") {
+		t.Errorf("round two prompt =\n%s\nwant both round one turns truncated", prompt)
+	}
+
+	var logs []*models.DiscussionLog
+	waitUntil(t, "every turn to be logged", func() bool {
+		logs, err = de.db.GetDiscussionLogs(discussion.ID)
+		n := 0
+		for _, l := range logs {
+			if l.LogType == models.LogTypeResponse && !l.IsModerator && !l.IsSystem() {
+				n++
+			}
+		}
+		return err == nil && n == 4
+	})
+	var contextChars []int
+	for _, l := range logs {
+		if l.LogType != models.LogTypeResponse || l.IsModerator || l.IsSystem() {
+			continue
+		}
+		if l.Content != reply {
+			t.Errorf("stored reply = %q, want it whole", l.Content)
+		}
+		n, err := strconv.Atoi(l.Metadata["context_chars"])
+		if err != nil {
+			t.Fatalf("context_chars = %q: %v", l.Metadata["context_chars"], err)
+		}
+		contextChars = append(contextChars, n)
+	}
+	// Each prior turn costs its label and at most the cap
+	if contextChars[0] != 0 || contextChars[1] == 0 || contextChars[1] >= len(reply) || contextChars[3] >= 3*len(reply) {
+		t.Errorf("context_chars = %v, want none for the opening turn and truncated contexts after it", contextChars)
+	}
+}
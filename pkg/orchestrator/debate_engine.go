@@ -4,70 +4,185 @@ import (
 	"context"
 	"court-table-ai/pkg/database"
 	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/stats"
+	"court-table-ai/pkg/summary"
+	"court-table-ai/pkg/version"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 )
 
+// ErrAllProvidersPaused is returned when the global pause_all kill switch is on
+var ErrAllProvidersPaused = errors.New("all providers are paused by administrator; new discussions cannot be started")
+
 // DebateEngine orchestrates the debate between multiple AI agents
 type DebateEngine struct {
-	db          *database.DB
-	agentClient *AgentClient
-	subscribers map[int64][]chan interface{}
-	subMu       sync.RWMutex
+	db                *database.DB
+	agentClient       *AgentClient
+	subscribers       map[int64][]*subscriber
+	globalSubscribers []chan Event
+	subMu             sync.RWMutex
+	reaped            atomic.Int64 // subscribers dropped for not reading
+	running           map[int64]*runningDebate
+	runMu             sync.Mutex
+	selfTests         map[int64]bool // discussions run by SelfTest, guarded by runMu
+	selfTestMu        sync.Mutex     // one SelfTest at a time
+	recent            *recentDiscussions
+	revision          atomic.Int64 // last revision given to a discussion event
+	webhookClient     *http.Client
+	now               func() time.Time
+	sleep             func(ctx context.Context, d time.Duration) error
 }
 
 // NewDebateEngine creates a new debate engine
 func NewDebateEngine(db *database.DB) *DebateEngine {
-	return &DebateEngine{
-		db:          db,
-		agentClient: NewAgentClient(),
-		subscribers: make(map[int64][]chan interface{}),
+	de := &DebateEngine{
+		db:            db,
+		agentClient:   NewAgentClient(db),
+		subscribers:   make(map[int64][]*subscriber),
+		running:       make(map[int64]*runningDebate),
+		selfTests:     make(map[int64]bool),
+		recent:        newRecentDiscussions(),
+		webhookClient: &http.Client{Timeout: webhookTimeout},
+		now:           time.Now,
+		sleep:         sleepContext,
 	}
+	// Revisions go on from the start time so they keep increasing across
+	// restarts for clients that reconnect to a new process
+	de.revision.Store(time.Now().UnixMicro())
+	return de
 }
 
-// Subscribe adds a subscriber for a discussion
-func (de *DebateEngine) Subscribe(discussionID int64) chan interface{} {
+// Subscribe adds a subscriber for a discussion. It fails with
+// ErrTooManySubscribers once the discussion has as many subscribers as the
+// subscriber limits allow.
+func (de *DebateEngine) Subscribe(discussionID int64) (chan Event, error) {
+	limit := de.SubscriberLimits().MaxPerDiscussion
+
 	de.subMu.Lock()
 	defer de.subMu.Unlock()
 
-	ch := make(chan interface{}, 10)
-	de.subscribers[discussionID] = append(de.subscribers[discussionID], ch)
-	return ch
+	if len(de.subscribers[discussionID]) >= limit {
+		return nil, ErrTooManySubscribers
+	}
+	sub := &subscriber{ch: make(chan Event, 10)}
+	de.subscribers[discussionID] = append(de.subscribers[discussionID], sub)
+	return sub.ch, nil
 }
 
-// Unsubscribe removes a subscriber
-func (de *DebateEngine) Unsubscribe(discussionID int64, ch chan interface{}) {
+// Unsubscribe removes a subscriber. A subscriber already dropped by the
+// reaper is left alone.
+func (de *DebateEngine) Unsubscribe(discussionID int64, ch chan Event) {
 	de.subMu.Lock()
 	defer de.subMu.Unlock()
 
 	subs := de.subscribers[discussionID]
 	for i, sub := range subs {
+		if sub.ch == ch {
+			de.removeSubscriber(discussionID, i)
+			break
+		}
+	}
+}
+
+// broadcast sends a copy of an update to all subscribers of a discussion
+func (de *DebateEngine) broadcast(discussionID int64, data interface{}) {
+	de.subMu.RLock()
+	defer de.subMu.RUnlock()
+
+	// Revisions are taken even without subscribers so they keep increasing
+	var revision int64
+	if _, ok := data.(*models.Discussion); ok {
+		revision = de.revision.Add(1)
+	}
+
+	subs := de.subscribers[discussionID]
+	if len(subs) == 0 {
+		return
+	}
+	// One copy serves every subscriber since none of them may modify it
+	event := newEvent(data)
+	event.Revision = revision
+	for _, sub := range subs {
+		sub.send(event, de.now())
+	}
+}
+
+// Revision returns the revision of the latest discussion event. A client
+// that reads a discussion after taking the revision has seen every update up
+// to it, so events at or below it can be ignored.
+func (de *DebateEngine) Revision() int64 {
+	return de.revision.Load()
+}
+
+// SubscribeGlobal adds a subscriber for engine-wide events
+func (de *DebateEngine) SubscribeGlobal() chan Event {
+	de.subMu.Lock()
+	defer de.subMu.Unlock()
+
+	ch := make(chan Event, 10)
+	de.globalSubscribers = append(de.globalSubscribers, ch)
+	return ch
+}
+
+// UnsubscribeGlobal removes an engine-wide subscriber
+func (de *DebateEngine) UnsubscribeGlobal(ch chan Event) {
+	de.subMu.Lock()
+	defer de.subMu.Unlock()
+
+	for i, sub := range de.globalSubscribers {
 		if sub == ch {
-			de.subscribers[discussionID] = append(subs[:i], subs[i+1:]...)
+			de.globalSubscribers = append(de.globalSubscribers[:i], de.globalSubscribers[i+1:]...)
 			close(ch)
 			break
 		}
 	}
 }
 
-// broadcast sends an update to all subscribers of a discussion
-func (de *DebateEngine) broadcast(discussionID int64, data interface{}) {
+// broadcastGlobal sends a copy of an event to all engine-wide subscribers
+func (de *DebateEngine) broadcastGlobal(data interface{}) {
 	de.subMu.RLock()
 	defer de.subMu.RUnlock()
 
-	for _, ch := range de.subscribers[discussionID] {
+	if len(de.globalSubscribers) == 0 {
+		return
+	}
+	event := newEvent(data)
+	for _, ch := range de.globalSubscribers {
 		select {
-		case ch <- data:
+		case ch <- event:
 		default:
 			// Buffer full, skip
 		}
 	}
 }
 
+// Notify publishes an engine-wide event, e.g. completion of a background job
+func (de *DebateEngine) Notify(event *models.EngineEvent) {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = de.now()
+	}
+	de.broadcastGlobal(event)
+}
+
 // RunDebate starts a debate session with the specified topic and agents
-func (de *DebateEngine) RunDebate(ctx context.Context, topic string, agentIDs []int64, moderatorID *int64, maxRounds int, language string, maxCharLimit int) (*models.Discussion, error) {
+func (de *DebateEngine) RunDebate(ctx context.Context, topic string, agentIDs []int64, stances []models.AgentStance, moderatorID, judgeID *int64, maxRounds int, language string, maxCharLimit int, settings models.DiscussionSettings) (*models.Discussion, error) {
+	var pauses models.ProviderPauses
+	if _, err := de.db.GetSettingJSON(database.SettingProviderPauses, &pauses); err != nil {
+		return nil, fmt.Errorf("failed to read provider pauses: %w", err)
+	}
+	if pauses.PauseAll {
+		return nil, ErrAllProvidersPaused
+	}
+
 	// 1. Verify agents exist BEFORE creating discussion
 	agents, err := de.getAgents(agentIDs)
 	if err != nil {
@@ -80,6 +195,28 @@ func (de *DebateEngine) RunDebate(ctx context.Context, topic string, agentIDs []
 		if err != nil {
 			return nil, fmt.Errorf("failed to verify moderator: %w", err)
 		}
+		if moderator.Disabled {
+			return nil, fmt.Errorf("failed to verify moderator: %w: %s", ErrAgentDisabled, moderator.Name)
+		}
+	}
+
+	var judge *models.Agent
+	if judgeID != nil {
+		judge, err = de.db.GetAgent(*judgeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify judge: %w", err)
+		}
+		if judge.Disabled {
+			return nil, fmt.Errorf("failed to verify judge: %w: %s", ErrAgentDisabled, judge.Name)
+		}
+	}
+
+	if settings.Order == models.DiscussionOrderReliability {
+		agents = de.orderByReliability(agents, agentIDs)
+		agentIDs = make([]int64, len(agents))
+		for i, agent := range agents {
+			agentIDs[i] = agent.ID
+		}
 	}
 
 	// 2. Create discussion record
@@ -88,9 +225,13 @@ func (de *DebateEngine) RunDebate(ctx context.Context, topic string, agentIDs []
 		Status:       "running",
 		AgentIDs:     models.JSONSlice[int64](agentIDs),
 		ModeratorID:  moderatorID,
+		JudgeID:      judgeID,
 		MaxRounds:    maxRounds,
 		Language:     language,
 		MaxCharLimit: maxCharLimit,
+		AppVersion:   version.Version,
+		Settings:     settings,
+		Stances:      stances,
 	}
 
 	if err := de.db.InsertDiscussion(discussion); err != nil {
@@ -99,22 +240,29 @@ func (de *DebateEngine) RunDebate(ctx context.Context, topic string, agentIDs []
 
 	// 3. Start debate in background goroutine
 	// Use background context so it doesn't get cancelled when HTTP request finishes
-	go de.executeDebate(context.Background(), discussion, agents, moderator)
+	debateCtx, cancel := context.WithCancelCause(context.Background())
+	de.trackDebate(discussion.ID, cancel)
+	// The debate goes on changing its discussion; the caller gets a copy
+	started := copyDiscussion(discussion)
+	go de.executeDebate(debateCtx, discussion, agents, moderator, judge)
 
-	return discussion, nil
+	return &started, nil
 }
 
 // executeDebate runs the actual debate logic
-func (de *DebateEngine) executeDebate(ctx context.Context, discussion *models.Discussion, agents []*models.Agent, moderator *models.Agent) {
+func (de *DebateEngine) executeDebate(ctx context.Context, discussion *models.Discussion, agents []*models.Agent, moderator, judge *models.Agent) {
+	defer de.untrackDebate(discussion.ID)
 	defer func() {
 		// Update discussion status when done
 		if r := recover(); r != nil {
 			log.Printf("Debate panicked: %v", r)
-			discussion.Status = "failed"
-		} else if discussion.Status == "running" {
-			discussion.Status = "completed"
+			de.endDiscussion(discussion, "failed", models.EndReasonFailed, discussion.ErrorMessage)
+			de.broadcast(discussion.ID, discussion)
+		} else if discussion.InProgress() {
+			de.endDiscussion(discussion, "completed", discussion.EndReason, discussion.ErrorMessage)
 		}
-		de.db.UpdateDiscussion(discussion)
+		final := copyDiscussion(discussion)
+		go de.notifyDiscussionWebhook(&final)
 	}()
 
 	log.Printf("Starting debate for discussion %d with %d agents%s (Max Rounds: %d, Language: %s, Max Chars: %d)",
@@ -133,80 +281,118 @@ func (de *DebateEngine) executeDebate(ctx context.Context, discussion *models.Di
 	}
 
 	// Build debate context from previous responses
-	var debateContext strings.Builder
-	roundCount := 1
+	debateContext := &turnContext{perTurnChars: discussion.Settings.PerTurnContextChars, guarded: discussion.Settings.InjectionGuard != nil}
+	discussion.CompletedRounds = 0
+	discussion.EndReason = ""
 	maxRounds := discussion.MaxRounds
 	if maxRounds <= 0 {
 		maxRounds = 3 // Default fallback
 	}
 
+	// Set when no agent answered in round 1; the debate then fails instead
+	// of completing with an empty transcript
+	var failure string
+
 	for round := 1; round <= maxRounds; round++ {
+		if !de.waitIfPaused(ctx, discussion.ID) {
+			break
+		}
 		roundActive := false
+		var roundErrors []string
 		log.Printf("Starting round %d for discussion %d", round, discussion.ID)
 
-		// Each agent responds in sequence
-		for i, agent := range agents {
-			// Build prompt for this agent
-			prompt := de.buildPrompt(discussion)
-			if round > 1 {
-				prompt = de.buildRoundPrompt(discussion, round, i+1, len(agents))
+		// Pacing waited before the next turn, recorded on its log entry
+		var pacing time.Duration
+		if round > 1 {
+			delay := discussion.Settings.RoundDelaySeconds
+			if delay == 0 {
+				delay = discussion.Settings.TurnDelaySeconds
 			}
+			pacing = de.pace(ctx, discussion.ID, time.Duration(delay)*time.Second)
+		}
 
-			// Call the agent
-			response, err := de.agentClient.CallAgent(ctx, agent, prompt, debateContext.String())
+		// Set when the token budget cannot cover the next turn
+		budgetSpent := false
 
-			// Log the interaction
-			logEntry := &models.DiscussionLog{
-				DiscussionID: discussion.ID,
-				AgentID:      agent.ID,
-				Status:       "success",
-				ResponseTime: response.ResponseTime,
-				IsModerator:  false,
+		speakers := roundSpeakers(discussion, agents, round)
+		if discussion.Settings.RoundMode == models.RoundModeParallel {
+			// Every agent answers the same context at once
+			allowance, ok := de.turnAllowance(discussion, remainingCalls(discussion.Settings, len(agents), maxRounds, round, 0, moderator != nil))
+			if !ok {
+				budgetSpent = true
+				speakers = nil
 			}
-
-			if err != nil {
-				log.Printf("Agent %s failed to respond: %v", agent.Name, err)
-				logEntry.Status = "error"
-				logEntry.Content = fmt.Sprintf("Error: %v", err)
-			} else if !response.Success {
-				log.Printf("Agent %s returned error: %s", agent.Name, response.ErrorMessage)
-				logEntry.Status = "error"
-				logEntry.Content = fmt.Sprintf("Error: %s", response.ErrorMessage)
-			} else {
-				log.Printf("Agent %s responded successfully (%d ms)", agent.Name, response.ResponseTime)
-				content := response.Content
-				
-				// Strictly enforce character limit (hard truncation)
-				if len(content) > discussion.MaxCharLimit {
-					content = content[:discussion.MaxCharLimit]
+			for i, out := range de.parallelRound(ctx, discussion, speakers, round, debateContext.String(), allowance, pacing) {
+				if out.answered {
+					roundActive = true
+					debateContext.add(round, speakers[i].Name, speakers[i].ID, out.accepted)
 				}
-				
-				logEntry.Content = content
-				roundActive = true
-
-				// Add to debate context for next agents
-				if debateContext.Len() > 0 {
-					debateContext.WriteString("\n\n")
+				if out.failure != "" {
+					roundErrors = append(roundErrors, out.failure)
 				}
-				debateContext.WriteString(fmt.Sprintf("Round %d - Agent %s (%d):", round, agent.Name, agent.ID))
-				debateContext.WriteString("\n")
-				debateContext.WriteString(content)
 			}
+		} else {
+			// Each agent responds in sequence
+			for i, agent := range speakers {
+				if i > 0 {
+					pacing = de.pace(ctx, discussion.ID, time.Duration(discussion.Settings.TurnDelaySeconds)*time.Second)
+				}
+				if !de.waitIfPaused(ctx, discussion.ID) {
+					break
+				}
+				allowance, ok := de.turnAllowance(discussion, remainingCalls(discussion.Settings, len(agents), maxRounds, round, i, moderator != nil))
+				if !ok {
+					budgetSpent = true
+					break
+				}
+				prompt := de.turnPrompt(discussion, agent.ID, round, i+1, len(speakers))
+				out := de.takeTurn(ctx, discussion, agent, round, "", prompt, debateContext.String(), allowance, pacing, runNow)
+				if out.aborted {
+					break
+				}
+				if out.failure != "" {
+					roundErrors = append(roundErrors, out.failure)
+				}
+				if out.skipped {
+					continue
+				}
+				if out.answered {
+					roundActive = true
+					// Add to debate context for next agents
+					debateContext.add(round, agent.Name, agent.ID, out.accepted)
+				}
 
-			// Save the log entry
-			if err := de.db.InsertDiscussionLog(logEntry); err != nil {
-				log.Printf("Failed to save discussion log: %v", err)
-			} else {
-				// Broadcast the new log
-				de.broadcast(discussion.ID, logEntry)
+				// Moderator provides commentary between agent responses if
+				// available; a failed turn leaves nothing to comment on
+				if moderator != nil && out.answered && i < len(speakers)-1 {
+					if !de.callModerator(ctx, discussion, moderator, "interim", out.accepted) {
+						log.Printf("Moderator failed to give interim commentary for discussion %d", discussion.ID)
+					}
+				}
 			}
+		}
 
-			// Moderator provides commentary between agent responses if available
-			if moderator != nil && i < len(agents)-1 {
-				if !de.callModerator(ctx, discussion, moderator, "interim", response.Content) {
-					log.Printf("Moderator failed to give interim commentary for discussion %d", discussion.ID)
-				}
+		// A round cut short by cancellation does not count as completed
+		if ctx.Err() != nil {
+			break
+		}
+
+		// Nor does one cut short by the token budget
+		if budgetSpent {
+			de.stopForBudget(discussion, round)
+			break
+		}
+
+		// If no agent responded successfully in this round, end the debate.
+		// There is nothing for the moderator to summarize.
+		if !roundActive {
+			log.Printf("No active responses in round %d, ending debate", round)
+			if round == 1 {
+				failure = "All agents failed in round 1: " + strings.Join(roundErrors, "; ")
+			} else {
+				discussion.EndReason = models.EndReasonAllAgentsDone
 			}
+			break
 		}
 
 		// Moderator provides round summary if available
@@ -216,13 +402,47 @@ func (de *DebateEngine) executeDebate(ctx context.Context, discussion *models.Di
 			}
 		}
 
-		// If no agent responded successfully in this round, end the debate
-		if !roundActive {
-			log.Printf("No active responses in round %d, ending debate", round)
+		discussion.CompletedRounds = round
+		if err := de.db.UpdateDiscussionProgress(discussion.ID, round); err != nil {
+			log.Printf("Failed to record progress of discussion %d: %v", discussion.ID, err)
+		}
+
+		// Converged positions end the debate early; after the last round
+		// there is nothing left to save
+		if discussion.Settings.EnableConsensusCheck && round < maxRounds &&
+			de.checkConsensus(ctx, discussion, summarizer(discussion, agents, moderator), debateContext.Round(round)) {
+			log.Printf("Consensus reached in discussion %d after round %d", discussion.ID, round)
+			discussion.EndReason = models.EndReasonConsensus
+			de.insertSystemLog(discussion.ID, models.LogTypeSystem, "success",
+				fmt.Sprintf("Consensus reached after round %d", round),
+				models.JSONMap{"consensus_round": strconv.Itoa(round)})
 			break
 		}
+	}
+
+	if errors.Is(context.Cause(ctx), errDebateStopped) {
+		log.Printf("Debate %d stopped by the user after %d rounds", discussion.ID, discussion.CompletedRounds)
+		de.saveSummary(discussion, de.generateSummary(ctx, discussion, debateContext, summarizer(discussion, agents, moderator)))
+		de.endDiscussion(discussion, "stopped", models.EndReasonStopped, "")
+		de.broadcast(discussion.ID, discussion)
+		return
+	}
+
+	// The watchdog force-failed the debate; it already recorded the outcome
+	if ctx.Err() != nil {
+		log.Printf("Debate %d cancelled: %v", discussion.ID, ctx.Err())
+		discussion.Status = "failed"
+		discussion.EndReason = models.EndReasonFailed
+		return
+	}
+
+	if failure != "" {
+		de.failDiscussion(discussion, failure)
+		return
+	}
 
-		roundCount++
+	if discussion.Settings.LightningRound {
+		de.lightningRound(ctx, discussion, agents, moderator != nil, debateContext)
 	}
 
 	// Moderator provides closing remarks if available
@@ -232,69 +452,252 @@ func (de *DebateEngine) executeDebate(ctx context.Context, discussion *models.Di
 		}
 	}
 
-	// Generate final summary
-	summary := de.generateSummary(discussion.Topic, debateContext.String())
-	discussion.FinalSummary = summary
-	discussion.Status = "completed"
-	de.db.UpdateDiscussion(discussion)
+	if discussion.EndReason == "" {
+		discussion.EndReason = models.EndReasonMaxRounds
+	}
+
+	// The judge rules on the finished debate before it is summarized
+	if judge != nil && de.waitIfPaused(ctx, discussion.ID) {
+		de.judgeDebate(ctx, discussion, agents, judge, debateContext.Full())
+	}
+
+	if discussion.Settings.Analyze && de.waitIfPaused(ctx, discussion.ID) {
+		de.analyze(ctx, discussion, agents, summarizer(discussion, agents, moderator), debateContext.Full())
+	}
+
+	// Generate final summary. A stop while paused here leaves ctx cancelled
+	// and the summary falls back to the non-AI backends.
+	de.waitIfPaused(ctx, discussion.ID)
+	summary := de.generateSummary(ctx, discussion, debateContext, summarizer(discussion, agents, moderator))
+	switch {
+	case errors.Is(context.Cause(ctx), errDebateStopped):
+		// Stopped while the summary was being written
+		log.Printf("Debate %d stopped by the user during the summary", discussion.ID)
+		de.saveSummary(discussion, summary)
+		de.endDiscussion(discussion, "stopped", models.EndReasonStopped, "")
+	case ctx.Err() != nil:
+		// The watchdog force-failed the debate meanwhile and recorded the outcome
+		log.Printf("Debate %d cancelled: %v", discussion.ID, ctx.Err())
+		discussion.Status = "failed"
+		discussion.EndReason = models.EndReasonFailed
+		return
+	default:
+		de.saveSummary(discussion, summary)
+		de.endDiscussion(discussion, "completed", discussion.EndReason, "")
+	}
 
 	// Broadcast discussion update
 	de.broadcast(discussion.ID, discussion)
 
-	log.Printf("Debate completed for discussion %d", discussion.ID)
+	log.Printf("Debate %s for discussion %d", discussion.Status, discussion.ID)
+}
+
+// endDiscussion records the final status of a running or paused debate and
+// mirrors it on discussion. Only the status columns are written, so a
+// concurrent change to the rest of the row is kept. A discussion that already
+// ended elsewhere, stopped by the user or force-failed by the watchdog, keeps
+// that outcome: discussion is reloaded to match it and false is returned.
+func (de *DebateEngine) endDiscussion(discussion *models.Discussion, status, endReason, errorMessage string) bool {
+	updated, err := de.db.UpdateDiscussionStatus(discussion.ID, status, endReason, errorMessage)
+	if err != nil {
+		log.Printf("Failed to update discussion %d: %v", discussion.ID, err)
+		return false
+	}
+	if !updated {
+		if current, err := de.db.GetDiscussion(discussion.ID); err == nil {
+			discussion.Status = current.Status
+			discussion.EndReason = current.EndReason
+			discussion.ErrorMessage = current.ErrorMessage
+		}
+		return false
+	}
+
+	discussion.Status = status
+	discussion.EndReason = endReason
+	discussion.ErrorMessage = errorMessage
+	discussion.UpdatedAt = de.now()
+	return true
+}
+
+// saveSummary stores the final summary of a discussion
+func (de *DebateEngine) saveSummary(discussion *models.Discussion, summary string) {
+	discussion.FinalSummary = summary
+	if err := de.db.UpdateDiscussionSummary(discussion.ID, summary); err != nil {
+		log.Printf("Failed to save summary of discussion %d: %v", discussion.ID, err)
+	}
+}
+
+// failDiscussion marks a discussion failed with the reason and announces it
+// on the discussion stream and the global event stream
+func (de *DebateEngine) failDiscussion(discussion *models.Discussion, reason string) {
+	log.Printf("Debate %d failed: %s", discussion.ID, reason)
+	if !de.endDiscussion(discussion, "failed", models.EndReasonFailed, reason) {
+		// Stopped or force-failed meanwhile; that outcome stands
+		de.broadcast(discussion.ID, discussion)
+		return
+	}
+
+	event := &models.EngineEvent{
+		Type:         "discussion_failed",
+		DiscussionID: discussion.ID,
+		Message:      reason,
+		CreatedAt:    de.now(),
+	}
+	de.broadcast(discussion.ID, event)
+	de.Notify(event)
+
+	// Sent last: discussion streams close once the status is final
+	de.broadcast(discussion.ID, discussion)
+}
+
+// errorClass condenses an agent failure into a short category for summaries
+func errorClass(err error, message string) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+
+	text := strings.ToLower(message)
+	if err != nil {
+		text = strings.ToLower(err.Error()) + " " + text
+	}
+
+	switch {
+	case strings.Contains(text, "deadline exceeded") || strings.Contains(text, "timeout") || strings.Contains(text, "timed out"):
+		return "timeout"
+	case strings.Contains(text, "status 401") || strings.Contains(text, "status 403") || strings.Contains(text, "unauthorized"):
+		return "auth"
+	case strings.Contains(text, "status 429") || strings.Contains(text, "rate limit"):
+		return "rate_limited"
+	case strings.Contains(text, "content filter"):
+		return "content_filter"
+	case strings.Contains(text, "status 5"):
+		return "server_error"
+	case strings.Contains(text, "status 4"):
+		return "client_error"
+	case strings.Contains(text, "connection refused") || strings.Contains(text, "no such host") ||
+		strings.Contains(text, "unreachable") || strings.Contains(text, "connection reset"):
+		return "connection"
+	case strings.Contains(text, "unmarshal") || strings.Contains(text, "parse") || strings.Contains(text, "empty response"):
+		return "invalid_response"
+	default:
+		return "error"
+	}
 }
 
 // buildPrompt creates a prompt for an agent's first round
-func (de *DebateEngine) buildPrompt(discussion *models.Discussion) string {
+func (de *DebateEngine) buildPrompt(discussion *models.Discussion, agentID int64) string {
+	if compact(discussion) {
+		return buildCompactPrompt(discussion, agentID, 1, 0)
+	}
 	var prompt strings.Builder
 
-	prompt.WriteString(fmt.Sprintf("You are an agent in a multi-agent debate about: \"%s\"\n\n", discussion.Topic))
-	prompt.WriteString(fmt.Sprintf("Language of discussion: %s\n", discussion.Language))
+	prompt.WriteString(fmt.Sprintf("You are an agent in a multi-agent debate about: %s\n\n", promptTopic(discussion.Topic)))
+	prompt.WriteString(fmt.Sprintf("Language of discussion: %s\n", discussion.LanguageName()))
 	prompt.WriteString(fmt.Sprintf("Maximum response length: %d characters\n\n", discussion.MaxCharLimit))
 	prompt.WriteString("This is the first round. Please provide your initial perspective on this topic.\n\n")
+	prompt.WriteString(stanceGuidance(discussion.StanceOf(agentID)))
 	prompt.WriteString("Guidelines:\n")
 	prompt.WriteString("- Provide a clear, thoughtful response\n")
 	prompt.WriteString("- Consider multiple perspectives\n")
 	prompt.WriteString("- Be specific and provide reasoning\n")
 	prompt.WriteString(fmt.Sprintf("- DO NOT EXCEED %d CHARACTERS\n", discussion.MaxCharLimit))
-	prompt.WriteString(fmt.Sprintf("- RESPOND ONLY IN %s\n", strings.ToUpper(discussion.Language)))
+	prompt.WriteString(fmt.Sprintf("- RESPOND ONLY IN %s\n", strings.ToUpper(discussion.LanguageName())))
 
 	return prompt.String()
 }
 
 // buildRoundPrompt creates a prompt for subsequent rounds
-func (de *DebateEngine) buildRoundPrompt(discussion *models.Discussion, round int, agentNum int, totalAgents int) string {
+func (de *DebateEngine) buildRoundPrompt(discussion *models.Discussion, agentID int64, round int, agentNum int, totalAgents int) string {
+	if compact(discussion) {
+		return buildCompactPrompt(discussion, agentID, round, agentNum)
+	}
 	var prompt strings.Builder
 
-	prompt.WriteString(fmt.Sprintf("This is Round %d of the debate about: \"%s\"\n\n", round, discussion.Topic))
-	prompt.WriteString(fmt.Sprintf("Language of discussion: %s\n", discussion.Language))
+	prompt.WriteString(fmt.Sprintf("This is Round %d of the debate about: %s\n\n", round, promptTopic(discussion.Topic)))
+	prompt.WriteString(fmt.Sprintf("Language of discussion: %s\n", discussion.LanguageName()))
 	prompt.WriteString(fmt.Sprintf("Maximum response length: %d characters\n\n", discussion.MaxCharLimit))
 	prompt.WriteString(fmt.Sprintf("You are Agent #%d. Please respond to the previous arguments from other agents.\n\n", agentNum))
+	prompt.WriteString(stanceGuidance(discussion.StanceOf(agentID)))
 	prompt.WriteString("Guidelines:\n")
 	prompt.WriteString("- Address specific points made by other agents\n")
 	prompt.WriteString("- Defend or modify your position based on new information\n")
 	prompt.WriteString("- Find common ground where possible\n")
 	prompt.WriteString("- Move the discussion toward resolution\n")
 	prompt.WriteString(fmt.Sprintf("- DO NOT EXCEED %d CHARACTERS\n", discussion.MaxCharLimit))
-	prompt.WriteString(fmt.Sprintf("- RESPOND ONLY IN %s\n", strings.ToUpper(discussion.Language)))
+	prompt.WriteString(fmt.Sprintf("- RESPOND ONLY IN %s\n", strings.ToUpper(discussion.LanguageName())))
 
 	return prompt.String()
 }
 
+// stanceGuidance tells an agent which side it was assigned, or is empty when
+// it has none
+func stanceGuidance(stance string) string {
+	var side string
+	switch stance {
+	case models.StancePro:
+		side = "You argue FOR the topic. Make the strongest case in its favour."
+	case models.StanceCon:
+		side = "You argue AGAINST the topic. Make the strongest case against it."
+	case models.StanceNeutral:
+		side = "You are NEUTRAL. Weigh the arguments of both sides without taking either."
+	default:
+		return ""
+	}
+	return fmt.Sprintf("Your assigned stance: %s\n%s\nKeep this stance consistently in every round; you may concede minor points, but never switch sides.\n\n", strings.ToUpper(stance), side)
+}
+
 // callModerator handles moderator interactions
 func (de *DebateEngine) callModerator(ctx context.Context, discussion *models.Discussion, moderator *models.Agent, moderatorType string, contextStr string) bool {
+	if !de.waitIfPaused(ctx, discussion.ID) {
+		return false
+	}
+	logEntry := de.runModerator(ctx, discussion, moderator, moderatorType, contextStr, 0)
+	return logEntry != nil && logEntry.Status == "success"
+}
+
+// runModerator calls the moderator for one phase and records the result. The
+// phase and its context are kept in the log metadata so a failed entry can be
+// retried with the same prompt. retryOf links the entry to the one it replaces.
+func (de *DebateEngine) runModerator(ctx context.Context, discussion *models.Discussion, moderator *models.Agent, moderatorType string, contextStr string, retryOf int64) *models.DiscussionLog {
 	// Build moderator prompt based on type
 	prompt := de.buildModeratorPrompt(discussion, moderatorType, contextStr)
 
-	response, err := de.agentClient.CallAgent(ctx, moderator, prompt, "")
+	opts := moderatorCallOptions(discussion.Settings.ModeratorOverrides)
+	if opts.MaxTokens == 0 {
+		opts.MaxTokens = compactMaxTokens(discussion)
+	}
+	opts.Trace = discussion.Settings.Trace
+	response, err := de.agentClient.CallAgentWithOptions(ctx, moderator, prompt, "", opts)
+	if err != nil && ctx.Err() != nil {
+		log.Printf("Moderator %s (%s) cancelled: %v", moderator.Name, moderatorType, ctx.Err())
+		return nil
+	}
+	if errors.Is(err, ErrProviderPaused) || errors.Is(err, ErrAgentDeleted) {
+		log.Printf("Skipping moderator %s (%s): %v", moderator.Name, moderatorType, err)
+		de.recordSkip(discussion.ID, moderator, true, err.Error())
+		return nil
+	}
 
 	// Log the moderator interaction
+	metadata := moderatorParamsMetadata(opts)
+	metadata["moderator_phase"] = moderatorType
+	if contextStr != "" {
+		metadata["moderator_context"] = contextStr
+	}
+	if retryOf > 0 {
+		metadata["retry_of"] = strconv.FormatInt(retryOf, 10)
+	}
+	addCallMetadata(metadata, response)
+
 	logEntry := &models.DiscussionLog{
-		DiscussionID: discussion.ID,
-		AgentID:      moderator.ID,
-		Status:       "success",
-		ResponseTime: response.ResponseTime,
-		IsModerator:  true,
+		DiscussionID:     discussion.ID,
+		AgentID:          moderator.ID,
+		Status:           "success",
+		ResponseTime:     response.ResponseTime,
+		IsModerator:      true,
+		Metadata:         metadata,
+		PromptTokens:     response.PromptTokens,
+		CompletionTokens: response.CompletionTokens,
 	}
 
 	if err != nil {
@@ -315,24 +718,80 @@ func (de *DebateEngine) callModerator(ctx context.Context, discussion *models.Di
 		log.Printf("Failed to save moderator log: %v", err)
 	} else {
 		// Broadcast the moderator log
+		de.touch(discussion.ID)
 		de.broadcast(discussion.ID, logEntry)
 	}
+	de.traceSlowCall(discussion.ID, moderator, response)
 
-	return logEntry.Status == "success"
+	return logEntry
+}
+
+// recordSkip writes a skip note so the transcript shows why an agent did not
+// take its turn. Skip notes are authored by the system participant and are
+// not added to the debate context.
+func (de *DebateEngine) recordSkip(discussionID int64, agent *models.Agent, isModerator bool, reason string) {
+	role := "agent"
+	if isModerator {
+		role = "moderator"
+	}
+
+	de.insertSystemLog(discussionID, models.LogTypeSkip, "skipped",
+		fmt.Sprintf("%s skipped this turn: %s", agent.Name, reason),
+		models.JSONMap{
+			"skip_reason":      reason,
+			"skipped_agent_id": strconv.FormatInt(agent.ID, 10),
+			"skipped_role":     role,
+		})
+}
+
+// moderatorCallOptions layers the discussion's moderator overrides on top of the agent defaults
+func moderatorCallOptions(overrides *models.ModeratorOverrides) CallOptions {
+	var opts CallOptions
+	if overrides == nil {
+		return opts
+	}
+	opts.SystemPrompt = overrides.SystemPrompt
+	opts.Temperature = overrides.Temperature
+	if overrides.MaxTokens != nil {
+		opts.MaxTokens = *overrides.MaxTokens
+	}
+	return opts
+}
+
+// moderatorParamsMetadata records the effective moderator call parameters for the log
+func moderatorParamsMetadata(opts CallOptions) models.JSONMap {
+	metadata := models.JSONMap{
+		"system_prompt": "default",
+		"temperature":   "default",
+		"max_tokens":    "default",
+	}
+	if opts.SystemPrompt != "" {
+		metadata["system_prompt"] = opts.SystemPrompt
+	}
+	if opts.Temperature != nil {
+		metadata["temperature"] = strconv.FormatFloat(*opts.Temperature, 'f', -1, 64)
+	}
+	if opts.MaxTokens > 0 {
+		metadata["max_tokens"] = strconv.Itoa(opts.MaxTokens)
+	}
+	return metadata
 }
 
 // buildModeratorPrompt creates prompts for different moderator interactions
 func (de *DebateEngine) buildModeratorPrompt(discussion *models.Discussion, moderatorType string, contextStr string) string {
-	topic := discussion.Topic
-	lang := discussion.Language
+	if compact(discussion) {
+		return de.buildCompactModeratorPrompt(discussion, moderatorType, contextStr)
+	}
+	topic := promptTopic(discussion.Topic)
+	lang := discussion.LanguageName()
 	limit := discussion.MaxCharLimit
 
-	basePrompt := fmt.Sprintf("You are the moderator for a multi-agent debate on: \"%s\"\nLanguage: %s\nMax length: %d characters\n\n", topic, lang, limit)
+	basePrompt := fmt.Sprintf("You are the moderator for a multi-agent debate on: %s\nLanguage: %s\nMax length: %d characters\n\n", topic, lang, limit)
 
 	switch moderatorType {
 	case "opening":
-		return basePrompt + `Your role is to:
-1. Welcome participants and set the tone
+		return basePrompt + de.debateBrief(discussion) + `Your role is to:
+1. Welcome the participants by name and set the tone
 2. Briefly explain the debate format and rules
 3. Remind agents to be respectful and constructive
 4. Introduce the topic and initial considerations
@@ -343,7 +802,7 @@ RESPOND ONLY IN ` + strings.ToUpper(lang) + `. DO NOT EXCEED ` + fmt.Sprint(limi
 	case "interim":
 		return basePrompt + `An agent just responded with:
 
-"` + contextStr + `"
+` + fence(contextStr) + `
 
 Your role is to:
 1. Briefly acknowledge the key points made
@@ -376,11 +835,52 @@ RESPOND ONLY IN ` + strings.ToUpper(lang) + `. DO NOT EXCEED ` + fmt.Sprint(limi
 Please provide a comprehensive closing statement (3-4 paragraphs).
 RESPOND ONLY IN ` + strings.ToUpper(lang) + `. DO NOT EXCEED ` + fmt.Sprint(limit) + ` CHARACTERS.`
 
+	case consensusPhase:
+		return basePrompt + `A round has ended. The participants' responses in that round were:
+
+` + fence(contextStr) + `
+
+Decide whether the participants' positions have substantially converged, so that further rounds would mostly repeat agreement rather than add new arguments or disagreement.
+
+Start your answer with YES or NO, in English, then explain your decision in one sentence in ` + lang + `.`
+
 	default:
 		return basePrompt + "Please provide appropriate moderation in " + lang + ". DO NOT EXCEED " + fmt.Sprint(limit) + " CHARACTERS."
 	}
 }
 
+// debateBrief describes the participants, with any assigned stances, and the
+// format of a debate for the moderator's opening, so it can introduce them
+func (de *DebateEngine) debateBrief(discussion *models.Discussion) string {
+	var brief strings.Builder
+	brief.WriteString("Participants, in speaking order:\n")
+	for i, id := range discussion.AgentIDs {
+		name := fmt.Sprintf("Agent #%d", id)
+		if agentName, err := de.db.GetAgentName(id); err == nil {
+			name = agentName
+		}
+		if stance := discussion.StanceOf(id); stance != "" {
+			name += " (" + strings.ToUpper(stance) + ")"
+		}
+		brief.WriteString(fmt.Sprintf("%d. %s\n", i+1, name))
+	}
+
+	rounds := discussion.MaxRounds
+	if rounds <= 0 {
+		rounds = 3
+	}
+	brief.WriteString(fmt.Sprintf("\nPlanned rounds: %d\n", rounds))
+	brief.WriteString("Format: sequential. In each round the participants answer one after another in the order above, each seeing the responses given so far. You comment between turns and summarize each round.\n")
+	if discussion.Settings.Order == models.DiscussionOrderReliability {
+		brief.WriteString("The speaking order was set by each participant's past reliability, most reliable first.\n")
+	}
+	if discussion.Settings.EnableConsensusCheck {
+		brief.WriteString("The debate ends early once the participants' positions have converged.\n")
+	}
+	brief.WriteString("\n")
+	return brief.String()
+}
+
 // getModeratorRole returns a human-readable role description
 func (de *DebateEngine) getModeratorRole(moderatorType string) string {
 	switch moderatorType {
@@ -392,77 +892,126 @@ func (de *DebateEngine) getModeratorRole(moderatorType string) string {
 		return "Round Summary"
 	case "closing":
 		return "Closing Remarks"
+	case consensusPhase:
+		return "Consensus Check"
 	default:
 		return "Moderation"
 	}
 }
-func (de *DebateEngine) generateSummary(topic string, context string) string {
-	if context == "" {
+
+// generateSummary writes the final summary with the discussion's summary
+// backend. The AI summary is the default; when the call fails, or ctx is
+// already cancelled, it falls back to the extractive summary, which needs no
+// provider. The basic one is also used when no sentence could be extracted.
+func (de *DebateEngine) generateSummary(ctx context.Context, discussion *models.Discussion, debateContext *turnContext, agent *models.Agent) string {
+	if len(debateContext.turns) == 0 {
 		return "No responses were generated during this debate."
 	}
 
-	// For now, create a simple summary. In a production system,
-	// you might want to use another AI call to generate a better summary
-	summary := fmt.Sprintf("Debate Summary for: %s\n\n", topic)
-	summary += "The debate involved multiple AI agents discussing this topic. "
-	summary += "Each agent provided their perspective and responded to others' arguments. "
-	summary += "For detailed discussion, please review the individual agent responses.\n\n"
+	header := fmt.Sprintf("Debate Summary for: %s\n\n", discussion.Topic)
+	if reason := models.EndReasonText(discussion.EndReason); reason != "" {
+		header += fmt.Sprintf("The debate ended after %d of %d rounds because %s.\n\n",
+			discussion.CompletedRounds, discussion.MaxRounds, reason)
+	}
+	// The lightning round's statements lead whatever the backend
+	if statements := debateContext.ClosingStatements(); statements != "" {
+		header += statements + "\n"
+	}
+
+	backend := discussion.Settings.SummaryBackend
+	if (backend == "" || backend == models.SummaryBackendAI) && agent != nil && ctx.Err() == nil {
+		limit := discussion.Settings.SummaryCharLimit
+		if limit == 0 {
+			limit = discussion.MaxCharLimit
+		}
+		text, err := de.aiSummary(ctx, agent, discussion, debateContext.Full(), limit)
+		if err == nil {
+			return header + text
+		}
+		// A stopped debate gives up the summary call on purpose
+		if ctx.Err() == nil {
+			log.Printf("AI summary by %s failed for discussion %d: %v", agent.Name, discussion.ID, err)
+			de.insertSystemLog(discussion.ID, models.LogTypeSystem, "error",
+				fmt.Sprintf("%s could not write the summary, a heuristic summary was used instead: %v", agent.Name, err),
+				models.JSONMap{"alert": "summary", "summary_agent_id": strconv.FormatInt(agent.ID, 10)})
+		}
+	}
+
+	if backend != models.SummaryBackendBasic {
+		limit := discussion.Settings.SummaryCharLimit
+		if limit == 0 {
+			limit = models.DefaultSummaryCharLimit
+		}
+		header += "Key points by participant:\n"
+
+		turns := make([]summary.Turn, 0, len(debateContext.turns))
+		for _, t := range debateContext.turns {
+			turns = append(turns, summary.Turn{AgentID: t.agentID, AgentName: t.agentName, Content: t.content})
+		}
+		if points := summary.Extractive(turns, discussion.Language, limit-utf8.RuneCountInString(header)); points != "" {
+			return header + points
+		}
+	}
+
+	return basicSummary(header, debateContext.Full())
+}
+
+// basicSummary lists the first lines of the transcript after the header
+func basicSummary(header, context string) string {
+	text := header
+	text += "The debate involved multiple AI agents discussing this topic. "
+	text += "Each agent provided their perspective and responded to others' arguments. "
+	text += "For detailed discussion, please review the individual agent responses.\n\n"
 
 	// Add first few lines of actual discussion as preview
 	lines := strings.Split(context, "\n")
 	if len(lines) > 5 {
-		summary += "Key points discussed:\n"
+		text += "Key points discussed:\n"
 		for i := 0; i < 5 && i < len(lines); i++ {
 			if strings.TrimSpace(lines[i]) != "" {
-				summary += "- " + strings.TrimSpace(lines[i]) + "\n"
+				text += "- " + strings.TrimSpace(lines[i]) + "\n"
 			}
 		}
 		if len(lines) > 5 {
-			summary += "... (see full discussion for more details)"
+			text += "... (see full discussion for more details)"
 		}
 	}
 
-	return summary
+	return text
 }
 
-// getAgents retrieves agent details from database
-func (de *DebateEngine) getAgents(agentIDs []int64) ([]*models.Agent, error) {
-	var agents []*models.Agent
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-	errChan := make(chan error, len(agentIDs))
-
-	for _, id := range agentIDs {
-		wg.Add(1)
-		go func(agentID int64) {
-			defer wg.Done()
-			agent, err := de.db.GetAgent(agentID)
-			if err != nil {
-				errChan <- err
-				return
-			}
-			mu.Lock()
-			agents = append(agents, agent)
-			mu.Unlock()
-		}(id)
+// orderByReliability sorts agents so the most reliable speaks first. Agents
+// without enough history to be rated are placed as if they scored the warning
+// threshold; ties keep their selected order. If the scores cannot be loaded
+// the selected order is kept.
+func (de *DebateEngine) orderByReliability(agents []*models.Agent, agentIDs []int64) []*models.Agent {
+	position := make(map[int64]int, len(agentIDs))
+	for i, id := range agentIDs {
+		position[id] = i
 	}
 
-	wg.Wait()
-	close(errChan)
-
-	// Check for errors
-	for err := range errChan {
-		if err != nil {
-			return nil, err
-		}
+	scores, err := stats.LoadReliability(de.db)
+	if err != nil {
+		log.Printf("Failed to load agent reliability, keeping selected order: %v", err)
+		scores = nil
 	}
 
-	// Verify all agents were found
-	if len(agents) != len(agentIDs) {
-		return nil, fmt.Errorf("only found %d out of %d agents", len(agents), len(agentIDs))
+	score := func(id int64) int {
+		if r, ok := scores[id]; ok && r.Rated {
+			return r.Score
+		}
+		return stats.LowReliabilityScore
 	}
 
-	return agents, nil
+	ordered := append([]*models.Agent(nil), agents...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := score(ordered[i].ID), score(ordered[j].ID)
+		if a != b {
+			return a > b
+		}
+		return position[ordered[i].ID] < position[ordered[j].ID]
+	})
+	return ordered
 }
 
 // PingAgent checks if an agent is reachable
@@ -475,6 +1024,46 @@ func (de *DebateEngine) PingAgent(ctx context.Context, agentID int64) error {
 	return de.agentClient.Ping(ctx, agent)
 }
 
+// agentTestTimeout caps an agent test call, below the agent's own timeout
+// when that is longer
+const agentTestTimeout = 30 * time.Second
+
+// TestAgent sends prompt to the agent the way a debate turn does, with its
+// persona and the debate instructions, and reports the reply. Nothing is
+// stored.
+func (de *DebateEngine) TestAgent(ctx context.Context, agent *models.Agent, prompt string) *models.AgentTestResult {
+	ctx, cancel := context.WithTimeout(ctx, agentTestTimeout)
+	defer cancel()
+
+	start := time.Now()
+	result := &models.AgentTestResult{Prompt: prompt}
+	response, err := de.agentClient.CallAgent(ctx, agent, prompt, "")
+	if response != nil {
+		result.Success = response.Success && err == nil
+		result.Content = response.Content
+		result.ResponseTime = response.ResponseTime
+		result.PromptTokens = response.PromptTokens
+		result.CompletionTokens = response.CompletionTokens
+		result.TotalTokens = response.PromptTokens + response.CompletionTokens
+		result.Error = response.ErrorMessage
+		result.Metadata = response.Metadata
+	} else {
+		result.ResponseTime = int(time.Since(start).Milliseconds())
+	}
+	if err != nil {
+		result.Error = err.Error()
+	} else if !result.Success && result.Error == "" {
+		result.Error = "agent returned no reply"
+	}
+	return result
+}
+
+// ListAgentModels lists the models offered by an agent's provider, see
+// AgentClient.ListModels
+func (de *DebateEngine) ListAgentModels(ctx context.Context, agent *models.Agent) ([]models.ProviderModel, error) {
+	return de.agentClient.ListModels(ctx, agent)
+}
+
 // GetDiscussionStatus retrieves the current status of a discussion
 func (de *DebateEngine) GetDiscussionStatus(discussionID int64) (*models.Discussion, []*models.DiscussionLog, error) {
 	discussion, err := de.db.GetDiscussion(discussionID)
@@ -486,23 +1075,51 @@ func (de *DebateEngine) GetDiscussionStatus(discussionID int64) (*models.Discuss
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get discussion logs: %w", err)
 	}
+	discussion.ApplyStances(logs)
 
 	return discussion, logs, nil
 }
 
-// StopDiscussion stops a running discussion
+// errDebateStopped is the cancellation cause of a debate stopped by the user
+var errDebateStopped = errors.New("discussion stopped")
+
+// stopWaitTimeout bounds how long StopDiscussion waits for the debate to
+// abort its in-flight call and record the stop
+const stopWaitTimeout = 10 * time.Second
+
+// StopDiscussion cancels a running or paused debate, aborting any in-flight
+// provider call, and waits for it to mark the discussion stopped. A discussion
+// left running without a debate, such as after a restart, is marked stopped
+// here.
 func (de *DebateEngine) StopDiscussion(discussionID int64) error {
 	discussion, err := de.db.GetDiscussion(discussionID)
 	if err != nil {
 		return fmt.Errorf("failed to get discussion: %w", err)
 	}
 
-	if discussion.Status != "running" {
+	if discussion.Status != "running" && discussion.Status != "paused" {
 		return fmt.Errorf("discussion is not running")
 	}
 
-	discussion.Status = "completed"
-	return de.db.UpdateDiscussion(discussion)
+	if done := de.stopDebate(discussionID); done != nil {
+		select {
+		case <-done:
+			return nil
+		case <-time.After(stopWaitTimeout):
+			log.Printf("Debate %d did not stop within %v; marking it stopped", discussionID, stopWaitTimeout)
+		}
+		if discussion, err = de.db.GetDiscussion(discussionID); err != nil {
+			return fmt.Errorf("failed to get discussion: %w", err)
+		}
+		if discussion.Status != "running" && discussion.Status != "paused" {
+			return nil
+		}
+	}
+
+	// The debate may still record its own outcome; whichever is first stands
+	de.endDiscussion(discussion, "stopped", models.EndReasonStopped, "")
+	de.broadcast(discussion.ID, discussion)
+	return nil
 }
 
 // RetryFailedAgent retries a failed agent response
@@ -513,43 +1130,199 @@ func (de *DebateEngine) RetryFailedAgent(ctx context.Context, discussionID int64
 		return fmt.Errorf("failed to get discussion: %w", err)
 	}
 
-	if discussion.Status != "running" {
+	// Discussions that failed in round 1 can be retried agent by agent
+	if discussion.Status != "running" && discussion.Status != "failed" {
 		return fmt.Errorf("discussion is not running")
 	}
 
+	_, err = de.retryAgentTurn(ctx, discussion, agentID, 0)
+	return err
+}
+
+// ErrNotResumable is returned when resuming a discussion that is neither
+// paused nor failed before any agent answered
+var ErrNotResumable = errors.New("only paused discussions and discussions where every agent failed in round 1 can be resumed")
+
+// ResumeDiscussion continues a paused discussion. A discussion that failed
+// because every agent failed in round 1 is run again from the start, with the
+// same agents and settings.
+func (de *DebateEngine) ResumeDiscussion(discussionID int64) (*models.Discussion, error) {
+	discussion, err := de.db.GetDiscussion(discussionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get discussion: %w", err)
+	}
+	if discussion.Status == "paused" {
+		return de.resumePaused(discussionID)
+	}
+	if discussion.Status != "failed" || discussion.ErrorMessage == "" {
+		return nil, ErrNotResumable
+	}
+
+	var pauses models.ProviderPauses
+	if _, err := de.db.GetSettingJSON(database.SettingProviderPauses, &pauses); err != nil {
+		return nil, fmt.Errorf("failed to read provider pauses: %w", err)
+	}
+	if pauses.PauseAll {
+		return nil, ErrAllProvidersPaused
+	}
+
+	agents, err := de.getAgents(discussion.AgentIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify agents: %w", err)
+	}
+	var moderator *models.Agent
+	if discussion.ModeratorID != nil {
+		if moderator, err = de.db.GetAgent(*discussion.ModeratorID); err != nil {
+			return nil, fmt.Errorf("failed to verify moderator: %w", err)
+		}
+	}
+	var judge *models.Agent
+	if discussion.JudgeID != nil {
+		if judge, err = de.db.GetAgent(*discussion.JudgeID); err != nil {
+			return nil, fmt.Errorf("failed to verify judge: %w", err)
+		}
+	}
+	if discussion.Verdict != nil {
+		if err := de.db.SaveDiscussionVerdict(discussion.ID, nil); err != nil {
+			return nil, err
+		}
+		discussion.Verdict = nil
+	}
+
+	restarted, err := de.db.RestartDiscussion(discussion.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !restarted {
+		// Resumed or deleted by another request meanwhile
+		return nil, ErrNotResumable
+	}
+	discussion.Status = "running"
+	discussion.ErrorMessage = ""
+	discussion.FinalSummary = ""
+	discussion.CompletedRounds = 0
+	discussion.EndReason = ""
+	de.broadcast(discussion.ID, discussion)
+
+	debateCtx, cancel := context.WithCancelCause(context.Background())
+	de.trackDebate(discussion.ID, cancel)
+	// The debate goes on changing its discussion; the caller gets a copy
+	started := copyDiscussion(discussion)
+	go de.executeDebate(debateCtx, discussion, agents, moderator, judge)
+
+	return &started, nil
+}
+
+// RetryLogEntry retries the failed or rejected turn recorded in a log entry.
+// Moderator entries are replayed with the prompt for their original phase. The
+// new entry is linked to the failed one through retry_of / retried_by metadata.
+func (de *DebateEngine) RetryLogEntry(ctx context.Context, discussionID int64, logID int64) (*models.DiscussionLog, error) {
+	discussion, err := de.db.GetDiscussion(discussionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get discussion: %w", err)
+	}
+	if discussion.Status == "deleting" {
+		return nil, fmt.Errorf("discussion is being deleted")
+	}
+
+	failed, err := de.db.GetDiscussionLog(logID)
+	if err != nil || failed.DiscussionID != discussionID {
+		return nil, fmt.Errorf("log entry not found")
+	}
+	if failed.IsSystem() {
+		return nil, fmt.Errorf("system entries cannot be retried")
+	}
+	if failed.Status != "error" && failed.Status != "timeout" && failed.Status != "rejected" {
+		return nil, fmt.Errorf("log entry did not fail")
+	}
+
+	var retried *models.DiscussionLog
+	if failed.IsModerator {
+		phase := failed.Metadata["moderator_phase"]
+		if phase == "" {
+			return nil, fmt.Errorf("moderator phase unknown for this entry")
+		}
+
+		moderator, err := de.db.GetAgent(failed.AgentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get moderator: %w", err)
+		}
+		if moderator.Disabled {
+			return nil, ErrAgentDisabled
+		}
+
+		retried = de.runModerator(ctx, discussion, moderator, phase, failed.Metadata["moderator_context"], failed.ID)
+		if retried == nil {
+			return nil, ErrProviderPaused
+		}
+	} else {
+		retried, err = de.retryAgentTurn(ctx, discussion, failed.AgentID, failed.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if failed.Metadata == nil {
+		failed.Metadata = models.JSONMap{}
+	}
+	failed.Metadata["retried_by"] = strconv.FormatInt(retried.ID, 10)
+	if err := de.db.UpdateDiscussionLogMetadata(failed.ID, failed.Metadata); err != nil {
+		log.Printf("Failed to link retried log %d: %v", failed.ID, err)
+	}
+
+	return retried, nil
+}
+
+// retryAgentTurn calls an agent again with the discussion so far as context
+func (de *DebateEngine) retryAgentTurn(ctx context.Context, discussion *models.Discussion, agentID int64, retryOf int64) (*models.DiscussionLog, error) {
 	agent, err := de.db.GetAgent(agentID)
 	if err != nil {
-		return fmt.Errorf("failed to get agent: %w", err)
+		return nil, fmt.Errorf("failed to get agent: %w", err)
+	}
+	if agent.Disabled {
+		return nil, ErrAgentDisabled
 	}
 
 	// Get previous logs to build context
-	logs, err := de.db.GetDiscussionLogs(discussionID)
+	logs, err := de.db.GetDiscussionLogs(discussion.ID)
 	if err != nil {
-		return fmt.Errorf("failed to get discussion logs: %w", err)
+		return nil, fmt.Errorf("failed to get discussion logs: %w", err)
 	}
 
 	// Build context from previous successful responses
 	var contextBuilder strings.Builder
 	for _, log := range logs {
-		if log.Status == "success" {
+		if log.Status == "success" && log.LogType == models.LogTypeResponse {
 			if contextBuilder.Len() > 0 {
 				contextBuilder.WriteString("\n\n")
 			}
-			contextBuilder.WriteString(log.Content)
+			contextBuilder.WriteString(truncateTurn(log.Content, discussion.Settings.PerTurnContextChars))
 		}
 	}
 
 	// Retry the agent call
-	prompt := de.buildPrompt(discussion) // Simplified prompt for retry
-	response, err := de.agentClient.CallAgent(ctx, agent, prompt, contextBuilder.String())
+	prompt := de.withScratchpad(discussion, agentID, de.buildPrompt(discussion, agentID)) // Simplified prompt for retry
+	contextStr := contextBuilder.String()
+	response, err := de.agentClient.CallAgent(ctx, agent, prompt, contextStr)
+	if errors.Is(err, ErrProviderPaused) || errors.Is(err, ErrAgentDeleted) {
+		return nil, err
+	}
 
 	// Create new log entry
 	logEntry := &models.DiscussionLog{
-		DiscussionID: discussionID,
-		AgentID:      agentID,
-		Status:       "success",
-		ResponseTime: response.ResponseTime,
+		DiscussionID:     discussion.ID,
+		AgentID:          agentID,
+		Status:           "success",
+		ResponseTime:     response.ResponseTime,
+		Stance:           discussion.StanceOf(agentID),
+		Metadata:         models.JSONMap{"context_chars": strconv.Itoa(utf8.RuneCountInString(contextStr))},
+		PromptTokens:     response.PromptTokens,
+		CompletionTokens: response.CompletionTokens,
 	}
+	if retryOf > 0 {
+		logEntry.Metadata["retry_of"] = strconv.FormatInt(retryOf, 10)
+	}
+	addCallMetadata(logEntry.Metadata, response)
 
 	if err != nil {
 		logEntry.Status = "error"
@@ -558,8 +1331,23 @@ func (de *DebateEngine) RetryFailedAgent(ctx context.Context, discussionID int64
 		logEntry.Status = "error"
 		logEntry.Content = fmt.Sprintf("Retry failed: %s", response.ErrorMessage)
 	} else {
-		logEntry.Content = response.Content
+		round := min(discussion.CompletedRounds+1, max(discussion.MaxRounds, 1))
+		logEntry.Content = de.keepNotes(discussion, agentID, round, response.Content, logEntry.Metadata)
+		for k, v := range complianceMetadata(discussion, logEntry.Content) {
+			logEntry.Metadata[k] = v
+		}
+		citationMetadata(logEntry.Metadata, logEntry.Content)
+		if reason := rejectionReason(discussion.Settings.Acceptance, logEntry.Content); reason != "" {
+			logEntry.Status = "rejected"
+			logEntry.Metadata["rejection_reason"] = reason
+		}
+	}
+
+	if err := de.db.InsertDiscussionLog(logEntry); err != nil {
+		return nil, err
 	}
+	de.broadcast(discussion.ID, logEntry)
+	de.traceSlowCall(discussion.ID, agent, response)
 
-	return de.db.InsertDiscussionLog(logEntry)
+	return logEntry, nil
 }
@@ -0,0 +1,514 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+)
+
+// newTestEngine returns an engine over a fresh database with every table and
+// migration applied
+func newTestEngine(t *testing.T) *DebateEngine {
+	t.Helper()
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.CreateTables(); err != nil {
+		t.Fatalf("CreateTables: %v", err)
+	}
+	return NewDebateEngine(db)
+}
+
+func insertTestAgent(t *testing.T, de *DebateEngine, name, url string) *models.Agent {
+	t.Helper()
+	agent := &models.Agent{
+		Name:           name,
+		ProviderType:   "openai",
+		ProviderURL:    url,
+		APIToken:       "sk-test",
+		ModelName:      "test-model",
+		TimeoutSeconds: 10,
+		EndpointStyle:  models.EndpointStyleChatCompletions,
+	}
+	if err := de.db.InsertAgent(agent); err != nil {
+		t.Fatalf("InsertAgent(%q): %v", name, err)
+	}
+	return agent
+}
+
+func insertTestDiscussion(t *testing.T, de *DebateEngine, status string, agents ...*models.Agent) *models.Discussion {
+	t.Helper()
+	discussion := &models.Discussion{Topic: "Tabs or spaces", Status: status, MaxRounds: 2, MaxCharLimit: models.DefaultMaxCharLimit}
+	for _, agent := range agents {
+		discussion.AgentIDs = append(discussion.AgentIDs, agent.ID)
+	}
+	if err := de.db.InsertDiscussion(discussion); err != nil {
+		t.Fatalf("InsertDiscussion: %v", err)
+	}
+	return discussion
+}
+
+// waitUntil polls cond until it holds, failing the test after five seconds
+func waitUntil(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// endedBeforeCleanup makes sure a debate started by the test is over before
+// the database closes
+func endedBeforeCleanup(t *testing.T, de *DebateEngine, discussionID int64) {
+	t.Helper()
+	t.Cleanup(func() {
+		waitUntil(t, "the debate to end", func() bool {
+			d, err := de.db.GetDiscussion(discussionID)
+			return err == nil && !d.InProgress()
+		})
+	})
+}
+
+// newTestProvider serves OpenAI chat completions answering reply and counts
+// the requests it gets
+func newTestProvider(t *testing.T, reply string) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"` + reply + `"},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":3}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+// newBodyProvider serves OpenAI chat completions answering reply and returns
+// the decoded request bodies it got
+func newBodyProvider(t *testing.T, reply string) (*httptest.Server, func() []map[string]interface{}) {
+	t.Helper()
+	var (
+		mu     sync.Mutex
+		bodies []map[string]interface{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("provider got a body that is not JSON: %v", err)
+		}
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"` + reply + `"},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":3}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []map[string]interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]map[string]interface{}(nil), bodies...)
+	}
+}
+
+// systemMessage returns the system message of a chat completions body
+func systemMessage(body map[string]interface{}) string {
+	messages, _ := body["messages"].([]interface{})
+	for _, m := range messages {
+		if msg, _ := m.(map[string]interface{}); msg["role"] == "system" {
+			content, _ := msg["content"].(string)
+			return content
+		}
+	}
+	return ""
+}
+
+func TestModeratorOverridesApplyOnlyToModeration(t *testing.T) {
+	de := newTestEngine(t)
+	server, bodies := newBodyProvider(t, "Noted.")
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+	alice.SystemPrompt = "You are Alice, a cheerful optimist."
+	if err := de.db.UpdateAgent(alice); err != nil {
+		t.Fatalf("UpdateAgent: %v", err)
+	}
+	discussion := insertTestDiscussion(t, de, "running", alice)
+	temperature := 0.1
+	maxTokens := 200
+	discussion.Settings.ModeratorOverrides = &models.ModeratorOverrides{
+		SystemPrompt: "You are a strict, neutral moderator.",
+		Temperature:  &temperature,
+		MaxTokens:    &maxTokens,
+	}
+
+	if out := de.takeTurn(context.Background(), discussion, alice, 1, "", "Your turn", "", 0, 0, runNow); !out.answered {
+		t.Fatalf("debate turn = %+v", out)
+	}
+	moderation := de.runModerator(context.Background(), discussion, alice, "summary", "", 0)
+	if moderation == nil || moderation.Status != "success" {
+		t.Fatalf("moderator turn = %+v", moderation)
+	}
+
+	got := bodies()
+	if len(got) != 2 {
+		t.Fatalf("provider got %d requests, want 2", len(got))
+	}
+	turn, moderator := got[0], got[1]
+	if system := systemMessage(turn); !strings.HasPrefix(system, alice.SystemPrompt) {
+		t.Errorf("debate turn system message = %q, want Alice's persona", system)
+	}
+	if _, ok := turn["temperature"]; ok {
+		t.Errorf("debate turn sent temperature %v, want the provider default", turn["temperature"])
+	}
+	if system := systemMessage(moderator); system != "You are a strict, neutral moderator." {
+		t.Errorf("moderator system message = %q, want the override", system)
+	}
+	if moderator["temperature"] != 0.1 || moderator["max_tokens"] != float64(200) {
+		t.Errorf("moderator parameters = temperature %v, max_tokens %v", moderator["temperature"], moderator["max_tokens"])
+	}
+	if turn["max_tokens"] == moderator["max_tokens"] {
+		t.Errorf("moderator max_tokens %v matches the debate turn", moderator["max_tokens"])
+	}
+
+	want := map[string]string{"system_prompt": "You are a strict, neutral moderator.", "temperature": "0.1", "max_tokens": "200"}
+	for key, value := range want {
+		if moderation.Metadata[key] != value {
+			t.Errorf("moderator log %s = %q, want %q", key, moderation.Metadata[key], value)
+		}
+	}
+}
+
+func TestModeratorWithoutOverridesUsesDefaults(t *testing.T) {
+	de := newTestEngine(t)
+	server, bodies := newBodyProvider(t, "Noted.")
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+	discussion := insertTestDiscussion(t, de, "running", alice)
+
+	moderation := de.runModerator(context.Background(), discussion, alice, "summary", "", 0)
+	if moderation == nil || moderation.Status != "success" {
+		t.Fatalf("moderator turn = %+v", moderation)
+	}
+	for _, key := range []string{"system_prompt", "temperature", "max_tokens"} {
+		if moderation.Metadata[key] != "default" {
+			t.Errorf("moderator log %s = %q, want default", key, moderation.Metadata[key])
+		}
+	}
+	if body := bodies()[0]; body["temperature"] != nil {
+		t.Errorf("moderator sent temperature %v without an override", body["temperature"])
+	}
+}
+
+func TestSkipNotesAreAttributed(t *testing.T) {
+	de := newTestEngine(t)
+	server, calls := newTestProvider(t, "Spaces, always.")
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+	bob := insertTestAgent(t, de, "Bob", server.URL)
+	carol := insertTestAgent(t, de, "Carol", server.URL)
+	bob.ProviderType = models.ProviderCustom
+	carol.ProviderType = models.ProviderCustom
+	for _, a := range []*models.Agent{bob, carol} {
+		if err := de.db.UpdateAgent(a); err != nil {
+			t.Fatalf("UpdateAgent(%s): %v", a.Name, err)
+		}
+	}
+	discussion := insertTestDiscussion(t, de, "running", alice, bob)
+	if err := de.db.SetSettingJSON(database.SettingProviderPauses, models.ProviderPauses{ProviderTypes: []string{"openai", "custom"}}); err != nil {
+		t.Fatalf("SetSettingJSON: %v", err)
+	}
+	if err := de.db.DeleteAgent(bob.ID); err != nil {
+		t.Fatalf("DeleteAgent: %v", err)
+	}
+
+	de.takeTurn(context.Background(), discussion, alice, 1, "", "Your turn", "", 0, 0, runNow)
+	de.takeTurn(context.Background(), discussion, bob, 1, "", "Your turn", "", 0, 0, runNow)
+	de.runModerator(context.Background(), discussion, carol, "summary", "", 0)
+	if calls.Load() != 0 {
+		t.Errorf("provider called %d times, want every call skipped", calls.Load())
+	}
+
+	logs, err := de.db.GetDiscussionLogs(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	want := []struct {
+		agent  *models.Agent
+		role   string
+		reason error
+	}{
+		{alice, "agent", ErrProviderPaused},
+		{bob, "agent", ErrAgentDeleted},
+		{carol, "moderator", ErrProviderPaused},
+	}
+	if len(logs) != len(want) {
+		t.Fatalf("got %d log entries, want %d skip notes", len(logs), len(want))
+	}
+	for i, w := range want {
+		l := logs[i]
+		if l.LogType != models.LogTypeSkip || l.Status != "skipped" || !l.IsSystem() || l.ResponseTime != 0 {
+			t.Errorf("entry %d = %s/%s by %d after %dms, want a system skip note", i, l.LogType, l.Status, l.AgentID, l.ResponseTime)
+		}
+		if l.Metadata["skipped_agent_id"] != strconv.FormatInt(w.agent.ID, 10) || l.Metadata["skipped_role"] != w.role || l.Metadata["skip_reason"] != w.reason.Error() {
+			t.Errorf("entry %d metadata = %v, want %s as %s: %v", i, l.Metadata, w.agent.Name, w.role, w.reason)
+		}
+		if !strings.HasPrefix(l.Content, w.agent.Name+" skipped this turn") {
+			t.Errorf("entry %d content = %q", i, l.Content)
+		}
+	}
+}
+
+// userMessage returns the user message of a chat completions body
+func userMessage(body map[string]interface{}) string {
+	messages, _ := body["messages"].([]interface{})
+	for _, m := range messages {
+		if msg, _ := m.(map[string]interface{}); msg["role"] == "user" {
+			content, _ := msg["content"].(string)
+			return content
+		}
+	}
+	return ""
+}
+
+func TestRetryModeratorEntry(t *testing.T) {
+	de := newTestEngine(t)
+	var failing atomic.Bool
+	var (
+		mu      sync.Mutex
+		prompts []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		prompts = append(prompts, userMessage(body))
+		mu.Unlock()
+		if failing.Load() {
+			http.Error(w, `{"error":{"message":"overloaded"}}`, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Order, please."},"finish_reason":"stop"}]}`))
+	}))
+	t.Cleanup(server.Close)
+	if err := de.db.SetSettingJSON(database.SettingAgentRetries, models.AgentRetryConfig{MaxRetries: 0, BaseDelayMs: 1, MaxDelayMs: 1}); err != nil {
+		t.Fatalf("SetSettingJSON: %v", err)
+	}
+	carol := insertTestAgent(t, de, "Carol", server.URL)
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+	discussion := insertTestDiscussion(t, de, "completed", alice)
+	discussion.ModeratorID = &carol.ID
+
+	tests := []struct {
+		phase      string
+		context    string
+		wantPrompt string
+	}{
+		{"interim", "Alice: spaces keep diffs clean.", "An agent just responded with:"},
+		{"closing", "", "The debate has concluded."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.phase, func(t *testing.T) {
+			failing.Store(true)
+			failed := de.runModerator(context.Background(), discussion, carol, tt.phase, tt.context, 0)
+			if failed == nil || failed.Status != "error" {
+				t.Fatalf("failing moderator call logged %+v", failed)
+			}
+
+			failing.Store(false)
+			retried, err := de.RetryLogEntry(context.Background(), discussion.ID, failed.ID)
+			if err != nil {
+				t.Fatalf("RetryLogEntry: %v", err)
+			}
+			if retried.Status != "success" || !retried.IsModerator || retried.AgentID != carol.ID {
+				t.Errorf("retried entry = %+v", retried)
+			}
+			if retried.Metadata["moderator_phase"] != tt.phase || retried.Metadata["retry_of"] != strconv.FormatInt(failed.ID, 10) {
+				t.Errorf("retried metadata = %v, want phase %s linked to %d", retried.Metadata, tt.phase, failed.ID)
+			}
+
+			mu.Lock()
+			original, retry := prompts[len(prompts)-2], prompts[len(prompts)-1]
+			mu.Unlock()
+			if retry != original || !strings.Contains(retry, tt.wantPrompt) || !strings.Contains(retry, tt.context) {
+				t.Errorf("retry prompt differs from the original %s prompt:\n%s\n---\n%s", tt.phase, original, retry)
+			}
+
+			stored, err := de.db.GetDiscussionLog(failed.ID)
+			if err != nil {
+				t.Fatalf("GetDiscussionLog: %v", err)
+			}
+			if stored.Metadata["retried_by"] != strconv.FormatInt(retried.ID, 10) {
+				t.Errorf("failed entry metadata = %v, want retried_by %d", stored.Metadata, retried.ID)
+			}
+		})
+	}
+
+	// Only failed entries can be retried
+	entries, _ := de.db.GetDiscussionLogs(discussion.ID)
+	last := entries[len(entries)-1]
+	if _, err := de.RetryLogEntry(context.Background(), discussion.ID, last.ID); err == nil {
+		t.Error("retrying a successful entry succeeded")
+	}
+}
+
+// newStatusProvider answers chat completions with the status held in status,
+// or a reply when it is 200
+func newStatusProvider(t *testing.T, status *atomic.Int64) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if code := int(status.Load()); code != http.StatusOK {
+			http.Error(w, `{"error":{"message":"no"}}`, code)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Spaces."},"finish_reason":"stop"}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAllAgentsFailingRoundOne(t *testing.T) {
+	de := newTestEngine(t)
+	if err := de.db.SetSettingJSON(database.SettingAgentRetries, models.AgentRetryConfig{MaxRetries: 0, BaseDelayMs: 1, MaxDelayMs: 1}); err != nil {
+		t.Fatalf("SetSettingJSON: %v", err)
+	}
+	events := de.SubscribeGlobal()
+	defer de.UnsubscribeGlobal(events)
+
+	var aliceStatus, bobStatus atomic.Int64
+	aliceStatus.Store(http.StatusInternalServerError)
+	bobStatus.Store(http.StatusUnauthorized)
+	alice := insertTestAgent(t, de, "Alice", newStatusProvider(t, &aliceStatus).URL)
+	bob := insertTestAgent(t, de, "Bob", newStatusProvider(t, &bobStatus).URL)
+	moderatorServer, moderatorBodies := newBodyProvider(t, "Welcome.")
+	moderator := insertTestAgent(t, de, "Judge", moderatorServer.URL)
+
+	discussion, err := de.RunDebate(context.Background(), "Tabs or spaces", []int64{alice.ID, bob.ID}, nil, &moderator.ID, nil, 2, "en", models.DefaultMaxCharLimit, models.DiscussionSettings{SummaryBackend: models.SummaryBackendExtractive})
+	if err != nil {
+		t.Fatalf("RunDebate: %v", err)
+	}
+	endedBeforeCleanup(t, de, discussion.ID)
+
+	var failed *models.Discussion
+	waitUntil(t, "the debate to end", func() bool {
+		failed, err = de.db.GetDiscussion(discussion.ID)
+		return err == nil && !failed.InProgress()
+	})
+	if failed.Status != "failed" || failed.EndReason != models.EndReasonFailed {
+		t.Fatalf("status %q, end reason %q; want failed", failed.Status, failed.EndReason)
+	}
+	if !strings.Contains(failed.ErrorMessage, "Alice (server_error)") || !strings.Contains(failed.ErrorMessage, "Bob (auth)") {
+		t.Errorf("error message = %q, want each agent's error class", failed.ErrorMessage)
+	}
+
+	// The moderator opened the debate and was not asked to sum up nothing
+	if n := len(moderatorBodies()); n != 1 {
+		t.Errorf("moderator called %d times, want only the opening", n)
+	}
+	logs, err := de.db.GetDiscussionLogs(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	for _, l := range logs {
+		if l.IsModerator && l.Metadata["moderator_phase"] != "opening" {
+			t.Errorf("moderator entry in phase %q", l.Metadata["moderator_phase"])
+		}
+	}
+
+	waitUntil(t, "the discussion_failed event", func() bool {
+		for {
+			select {
+			case event := <-events:
+				if e, ok := event.Data.(models.EngineEvent); ok && event.Type == "discussion_failed" && e.DiscussionID == discussion.ID {
+					return e.Message == failed.ErrorMessage
+				}
+			default:
+				return false
+			}
+		}
+	})
+
+	// Once the providers recover the discussion runs again from round one
+	aliceStatus.Store(http.StatusOK)
+	bobStatus.Store(http.StatusOK)
+	if _, err := de.ResumeDiscussion(discussion.ID); err != nil {
+		t.Fatalf("ResumeDiscussion: %v", err)
+	}
+	var resumed *models.Discussion
+	waitUntil(t, "the resumed debate to end", func() bool {
+		resumed, err = de.db.GetDiscussion(discussion.ID)
+		return err == nil && !resumed.InProgress()
+	})
+	if resumed.Status != "completed" || resumed.ErrorMessage != "" || resumed.CompletedRounds != 2 {
+		t.Errorf("resumed discussion = %s after %d rounds, error %q; want completed after 2", resumed.Status, resumed.CompletedRounds, resumed.ErrorMessage)
+	}
+	if _, err := de.ResumeDiscussion(discussion.ID); err != ErrNotResumable {
+		t.Errorf("resuming a completed discussion = %v, want ErrNotResumable", err)
+	}
+}
+
+func TestOpeningBrief(t *testing.T) {
+	de := newTestEngine(t)
+	alice := insertTestAgent(t, de, "Alice", "http://127.0.0.1:1")
+	bob := insertTestAgent(t, de, "Bob", "http://127.0.0.1:1")
+	carol := insertTestAgent(t, de, "Carol", "http://127.0.0.1:1")
+
+	discussion := insertTestDiscussion(t, de, "running", bob, alice, carol)
+	discussion.MaxRounds = 4
+	discussion.Stances = []models.AgentStance{{AgentID: alice.ID, Stance: models.StancePro}, {AgentID: bob.ID, Stance: models.StanceCon}}
+
+	prompt := de.buildModeratorPrompt(discussion, "opening", "")
+	for _, want := range []string{"1. Bob (CON)\n2. Alice (PRO)\n3. Carol\n", "Planned rounds: 4", "Format: sequential"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("opening prompt lacks %q:\n%s", want, prompt)
+		}
+	}
+	if strings.Contains(prompt, "reliability") || strings.Contains(prompt, "converged") {
+		t.Errorf("opening prompt mentions settings the discussion does not use:\n%s", prompt)
+	}
+
+	discussion.MaxRounds = 0
+	discussion.Settings.Order = models.DiscussionOrderReliability
+	discussion.Settings.EnableConsensusCheck = true
+	prompt = de.buildModeratorPrompt(discussion, "opening", "")
+	for _, want := range []string{"Planned rounds: 3", "past reliability", "positions have converged"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("opening prompt lacks %q:\n%s", want, prompt)
+		}
+	}
+
+	// Only the opening carries the brief
+	if prompt := de.buildModeratorPrompt(discussion, "closing", ""); strings.Contains(prompt, "Alice") {
+		t.Errorf("closing prompt has the roster:\n%s", prompt)
+	}
+
+	discussion.Settings.PromptProfile = models.PromptProfileCompact
+	prompt = de.buildModeratorPrompt(discussion, "opening", "")
+	if !strings.Contains(prompt, "Bob (CON), Alice (PRO), Carol.") {
+		t.Errorf("compact opening prompt lacks the roster:\n%s", prompt)
+	}
+}
+
+func TestOpeningBriefMissingAgent(t *testing.T) {
+	de := newTestEngine(t)
+	alice := insertTestAgent(t, de, "Alice", "http://127.0.0.1:1")
+	discussion := insertTestDiscussion(t, de, "running", alice)
+	discussion.AgentIDs = append(discussion.AgentIDs, 999)
+
+	if prompt := de.buildModeratorPrompt(discussion, "opening", ""); !strings.Contains(prompt, "1. Alice\n2. Agent #999\n") {
+		t.Errorf("opening prompt does not fall back to the agent ID:\n%s", prompt)
+	}
+}
@@ -0,0 +1,154 @@
+package orchestrator
+
+import (
+	"context"
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	registerFeature(FeatureStreaming, alwaysOn)
+}
+
+// DeltaCoalescingConfig returns the stored delta coalescing settings, or the
+// defaults when none were saved
+func (de *DebateEngine) DeltaCoalescingConfig() models.DeltaCoalescingConfig {
+	var cfg models.DeltaCoalescingConfig
+	found, err := de.db.GetSettingJSON(database.SettingDeltaCoalescing, &cfg)
+	if err != nil {
+		log.Printf("Failed to read delta coalescing settings: %v", err)
+	}
+	if !found || err != nil || cfg.Validate() != nil {
+		cfg = models.DeltaCoalescingConfig{FlushMs: models.DefaultDeltaFlushMs, MaxBufferBytes: models.DefaultDeltaMaxBufferBytes}
+	}
+	return cfg
+}
+
+// deltaCoalescer batches the partial content of one in-flight turn so a fast
+// model does not turn every token into an event. It sits between a streaming
+// callback, which calls Add, and broadcast: text is sent at most every flush
+// interval, or sooner once maxBytes have piled up since the last flush.
+// Close sends the final delta, which is always sent.
+type deltaCoalescer struct {
+	mu       sync.Mutex
+	send     func(*models.LogDelta)
+	interval time.Duration
+	maxBytes int
+
+	delta     models.LogDelta
+	content   strings.Builder
+	pending   int // bytes added since the last flush
+	lastFlush time.Time
+	timer     *time.Timer
+	closed    bool
+}
+
+// newDeltaCoalescer returns a coalescer broadcasting the deltas of a turn to
+// the discussion's subscribers
+func (de *DebateEngine) newDeltaCoalescer(discussionID, agentID int64, round int, isModerator bool) *deltaCoalescer {
+	cfg := de.DeltaCoalescingConfig()
+	return newDeltaCoalescer(models.LogDelta{
+		DiscussionID: discussionID,
+		AgentID:      agentID,
+		Round:        round,
+		IsModerator:  isModerator,
+	}, time.Duration(cfg.FlushMs)*time.Millisecond, cfg.MaxBufferBytes, func(delta *models.LogDelta) {
+		de.broadcast(discussionID, delta)
+	})
+}
+
+// callStreaming calls an agent for a turn with its reply streamed to the
+// discussion's subscribers as log_delta events while it is generated. Providers
+// that do not stream send no deltas.
+func (de *DebateEngine) callStreaming(ctx context.Context, discussionID int64, agent *models.Agent, round int, prompt, contextStr string, opts CallOptions) (*models.AgentResponse, error) {
+	coalescer := de.newDeltaCoalescer(discussionID, agent.ID, round, false)
+	deltas := make(chan string, 16)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		streamed := false
+		for text := range deltas {
+			coalescer.Add(text)
+			// Tokens arriving are progress, so a long reply does not look stalled
+			de.touch(discussionID)
+			streamed = true
+		}
+		if streamed {
+			coalescer.Close()
+		}
+	}()
+
+	response, err := de.agentClient.CallAgentStreamWithOptions(ctx, agent, prompt, contextStr, opts, deltas)
+	<-done
+	return response, err
+}
+
+func newDeltaCoalescer(turn models.LogDelta, interval time.Duration, maxBytes int, send func(*models.LogDelta)) *deltaCoalescer {
+	return &deltaCoalescer{send: send, interval: interval, maxBytes: maxBytes, delta: turn}
+}
+
+// Add appends text generated for the turn
+func (c *deltaCoalescer) Add(text string) {
+	if text == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+
+	c.content.WriteString(text)
+	c.pending += len(text)
+	if c.pending >= c.maxBytes || time.Since(c.lastFlush) >= c.interval {
+		c.flush(false)
+		return
+	}
+	if c.timer == nil {
+		c.timer = time.AfterFunc(c.interval-time.Since(c.lastFlush), c.flushPending)
+	}
+}
+
+// Close sends the final delta with the whole content of the turn. Text added
+// afterwards is ignored.
+func (c *deltaCoalescer) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.flush(true)
+	c.closed = true
+}
+
+// flushPending sends what piled up since the last flush when the interval
+// runs out
+func (c *deltaCoalescer) flushPending() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timer = nil
+	if !c.closed && c.pending > 0 {
+		c.flush(false)
+	}
+}
+
+// flush sends the cumulative content; c.mu must be held
+func (c *deltaCoalescer) flush(final bool) {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.delta.Sequence++
+	c.delta.Content = c.content.String()
+	c.delta.Final = final
+	c.pending = 0
+	c.lastFlush = time.Now()
+
+	delta := c.delta
+	c.send(&delta)
+}
@@ -0,0 +1,210 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/models"
+)
+
+// deltaRecorder collects the deltas a coalescer sends
+type deltaRecorder struct {
+	mu     sync.Mutex
+	deltas []models.LogDelta
+}
+
+func (r *deltaRecorder) send(d *models.LogDelta) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deltas = append(r.deltas, *d)
+}
+
+func (r *deltaRecorder) sent() []models.LogDelta {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]models.LogDelta(nil), r.deltas...)
+}
+
+// checkDeltas verifies deltas are numbered from 1, each extends the one
+// before, and only the last is final with the whole content
+func checkDeltas(t *testing.T, deltas []models.LogDelta, want string) {
+	t.Helper()
+	if len(deltas) == 0 {
+		t.Fatal("no deltas were sent")
+	}
+	prev := ""
+	for i, d := range deltas {
+		if d.Sequence != i+1 {
+			t.Errorf("delta %d has sequence %d", i, d.Sequence)
+		}
+		if !strings.HasPrefix(d.Content, prev) {
+			t.Errorf("delta %d content does not extend the previous one", d.Sequence)
+		}
+		if d.Final != (i == len(deltas)-1) {
+			t.Errorf("delta %d final = %v", d.Sequence, d.Final)
+		}
+		prev = d.Content
+	}
+	if last := deltas[len(deltas)-1]; last.Content != want {
+		t.Errorf("final content has %d bytes, want %d", len(last.Content), len(want))
+	}
+}
+
+func TestCoalescerBurst(t *testing.T) {
+	var rec deltaRecorder
+	c := newDeltaCoalescer(models.LogDelta{DiscussionID: 1, AgentID: 2, Round: 3}, time.Minute, models.DefaultDeltaMaxBufferBytes, rec.send)
+
+	var want strings.Builder
+	for i := 0; i < 1000; i++ {
+		text := fmt.Sprintf("t%d ", i)
+		want.WriteString(text)
+		c.Add(text)
+	}
+	c.Close()
+
+	// The first token goes out at once, then one delta per full buffer and
+	// the final one
+	deltas := rec.sent()
+	if max := want.Len()/models.DefaultDeltaMaxBufferBytes + 2; len(deltas) > max {
+		t.Errorf("1000 deltas in a burst made %d events, want at most %d", len(deltas), max)
+	}
+	checkDeltas(t, deltas, want.String())
+	if d := deltas[0]; d.DiscussionID != 1 || d.AgentID != 2 || d.Round != 3 {
+		t.Errorf("delta turn = %+v", d)
+	}
+}
+
+func TestCoalescerPacedStream(t *testing.T) {
+	var rec deltaRecorder
+	interval := 20 * time.Millisecond
+	c := newDeltaCoalescer(models.LogDelta{}, interval, models.DefaultDeltaMaxBufferBytes, rec.send)
+
+	var want strings.Builder
+	start := time.Now()
+	for i := 0; i < 1000; i++ {
+		want.WriteString("x")
+		c.Add("x")
+		time.Sleep(100 * time.Microsecond)
+	}
+	c.Close()
+	elapsed := time.Since(start)
+
+	// At most one flush per interval, one trailing flush and the final one
+	deltas := rec.sent()
+	if max := int(elapsed/interval) + 3; len(deltas) > max {
+		t.Errorf("1000 deltas over %v made %d events, want at most %d", elapsed, len(deltas), max)
+	}
+	checkDeltas(t, deltas, want.String())
+}
+
+func TestCoalescerTrailingFlush(t *testing.T) {
+	var rec deltaRecorder
+	c := newDeltaCoalescer(models.LogDelta{}, 20*time.Millisecond, models.DefaultDeltaMaxBufferBytes, rec.send)
+
+	c.Add("Spaces")
+	c.Add(", always")
+	waitUntil(t, "the trailing flush", func() bool { return len(rec.sent()) == 2 })
+	if d := rec.sent()[1]; d.Content != "Spaces, always" || d.Final {
+		t.Errorf("trailing delta = %+v, want the pending text, not final", d)
+	}
+
+	c.Close()
+	c.Add(" and forever")
+	c.Close()
+	deltas := rec.sent()
+	if len(deltas) != 3 || !deltas[2].Final || deltas[2].Content != "Spaces, always" {
+		t.Errorf("deltas after closing = %+v, want one final delta and nothing after it", deltas)
+	}
+}
+
+// newStreamingProvider streams chunks as chat completion deltas once release
+// is closed
+func newStreamingProvider(t *testing.T, chunks []string, release <-chan struct{}) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		var body struct {
+			Stream bool `json:"stream"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if !body.Stream {
+			http.Error(w, "streaming only", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", chunk)
+			flusher.Flush()
+		}
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":1000}}\n\ndata: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestStreamedTurnIsCoalesced(t *testing.T) {
+	de := newTestEngine(t)
+	chunks := make([]string, 1000)
+	for i := range chunks {
+		chunks[i] = string(rune('a' + i%26))
+	}
+	reply := strings.Join(chunks, "")
+	// The provider waits until the test has subscribed
+	release := make(chan struct{})
+	var releaseOnce sync.Once
+	open := func() { releaseOnce.Do(func() { close(release) }) }
+	server := newStreamingProvider(t, chunks, release)
+	t.Cleanup(open)
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+
+	settings := models.DiscussionSettings{SummaryBackend: models.SummaryBackendExtractive}
+	discussion, err := de.RunDebate(context.Background(), "Tabs or spaces", []int64{alice.ID}, nil, nil, nil, 1, "en", 2000, settings)
+	if err != nil {
+		t.Fatalf("RunDebate: %v", err)
+	}
+	events, err := de.Subscribe(discussion.ID)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer de.Unsubscribe(discussion.ID, events)
+	open()
+
+	var deltas []models.LogDelta
+	var stored *models.DiscussionLog
+	timeout := time.After(10 * time.Second)
+	for stored == nil {
+		select {
+		case e := <-events:
+			switch d := e.Data.(type) {
+			case models.LogDelta:
+				deltas = append(deltas, d)
+			case models.DiscussionLog:
+				if d.AgentID == alice.ID {
+					stored = &d
+				}
+			}
+		case <-timeout:
+			t.Fatalf("no log entry after %d deltas", len(deltas))
+		}
+	}
+
+	if len(deltas) > 50 {
+		t.Errorf("a 1000-chunk stream made %d log_delta events", len(deltas))
+	}
+	checkDeltas(t, deltas, reply)
+	if stored.Content != reply {
+		t.Errorf("stored reply has %d bytes, want %d", len(stored.Content), len(reply))
+	}
+	waitUntil(t, "the debate to end", func() bool {
+		d, err := de.db.GetDiscussion(discussion.ID)
+		return err == nil && !d.InProgress()
+	})
+}
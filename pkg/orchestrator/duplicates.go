@@ -0,0 +1,129 @@
+package orchestrator
+
+import (
+	"container/list"
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRecentDiscussions bounds the fingerprints remembered for duplicate
+// suppression; the least recently created are forgotten first
+const maxRecentDiscussions = 256
+
+func init() {
+	registerFeature(FeatureDuplicateGuard, func(de *DebateEngine) bool {
+		return de.DuplicateGuardConfig().WindowSeconds > 0
+	})
+}
+
+// recentDiscussions remembers the discussions created most recently by
+// fingerprint. The mutex is held while a discussion is created so two
+// identical submissions racing each other cannot both get through.
+type recentDiscussions struct {
+	mu      sync.Mutex
+	order   *list.List // of *recentDiscussion, most recent first
+	entries map[string]*list.Element
+}
+
+type recentDiscussion struct {
+	fingerprint  string
+	discussionID int64
+	createdAt    time.Time
+}
+
+func newRecentDiscussions() *recentDiscussions {
+	return &recentDiscussions{order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// lookup returns the discussion created with fingerprint since cutoff
+func (r *recentDiscussions) lookup(fingerprint string, cutoff time.Time) (int64, bool) {
+	e, ok := r.entries[fingerprint]
+	if !ok {
+		return 0, false
+	}
+	entry := e.Value.(*recentDiscussion)
+	if entry.createdAt.Before(cutoff) {
+		r.order.Remove(e)
+		delete(r.entries, fingerprint)
+		return 0, false
+	}
+	return entry.discussionID, true
+}
+
+// add records a discussion created with fingerprint, evicting the oldest
+// entry once the list is full
+func (r *recentDiscussions) add(fingerprint string, discussionID int64, createdAt time.Time) {
+	if e, ok := r.entries[fingerprint]; ok {
+		r.order.Remove(e)
+	}
+	r.entries[fingerprint] = r.order.PushFront(&recentDiscussion{fingerprint: fingerprint, discussionID: discussionID, createdAt: createdAt})
+
+	for r.order.Len() > maxRecentDiscussions {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.entries, oldest.Value.(*recentDiscussion).fingerprint)
+	}
+}
+
+// DiscussionFingerprint identifies a discussion submission by the client it
+// came from, its topic and its set of agents. Case and surrounding spaces of
+// the topic and the order of the agents do not matter.
+func DiscussionFingerprint(client, topic string, agentIDs []int64) string {
+	ids := append([]int64(nil), agentIDs...)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%v", client, strings.ToLower(strings.TrimSpace(topic)), ids)))
+	return hex.EncodeToString(sum[:])
+}
+
+// DuplicateGuardConfig returns the stored duplicate guard window, or the
+// default when none was saved
+func (de *DebateEngine) DuplicateGuardConfig() models.DuplicateGuardConfig {
+	var cfg models.DuplicateGuardConfig
+	found, err := de.db.GetSettingJSON(database.SettingDuplicateGuard, &cfg)
+	if err != nil {
+		log.Printf("Failed to read duplicate guard settings: %v", err)
+	}
+	if !found || err != nil || cfg.Validate() != nil {
+		cfg = models.DuplicateGuardConfig{WindowSeconds: models.DefaultDuplicateWindowSeconds}
+	}
+	return cfg
+}
+
+// CreateOnce calls create unless a discussion with the same fingerprint was
+// created within the duplicate guard window, in which case that discussion
+// is returned with suppressed set. Deleted discussions do not count.
+func (de *DebateEngine) CreateOnce(fingerprint string, create func() (*models.Discussion, error)) (discussion *models.Discussion, suppressed bool, err error) {
+	window := time.Duration(de.DuplicateGuardConfig().WindowSeconds) * time.Second
+	if window == 0 {
+		discussion, err = create()
+		return discussion, false, err
+	}
+
+	de.recent.mu.Lock()
+	defer de.recent.mu.Unlock()
+
+	now := de.now()
+	if id, ok := de.recent.lookup(fingerprint, now.Add(-window)); ok {
+		existing, err := de.db.GetDiscussion(id)
+		if err == nil && existing.Status != "deleting" {
+			log.Printf("Suppressed duplicate submission of discussion %d", id)
+			return existing, true, nil
+		}
+	}
+
+	discussion, err = create()
+	if err != nil {
+		return nil, false, err
+	}
+	de.recent.add(fingerprint, discussion.ID, now)
+	return discussion, false, nil
+}
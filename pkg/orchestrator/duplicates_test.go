@@ -0,0 +1,197 @@
+package orchestrator
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+)
+
+// countingCreate returns a create func for CreateOnce that stores a new
+// discussion each call, and the number of calls made
+func countingCreate(t *testing.T, de *DebateEngine) (func() (*models.Discussion, error), func() int) {
+	t.Helper()
+	var mu sync.Mutex
+	calls := 0
+	create := func() (*models.Discussion, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		discussion := &models.Discussion{Topic: "Tabs or spaces", Status: "completed", MaxRounds: 2, MaxCharLimit: models.DefaultMaxCharLimit}
+		if err := de.db.InsertDiscussion(discussion); err != nil {
+			return nil, err
+		}
+		return discussion, nil
+	}
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+	return create, count
+}
+
+func TestCreateOnceWindow(t *testing.T) {
+	de := newTestEngine(t)
+	clock := newFakeClock(de)
+	create, calls := countingCreate(t, de)
+	fp := DiscussionFingerprint("10.0.0.1", "Tabs or spaces", []int64{1, 2})
+
+	first, suppressed, err := de.CreateOnce(fp, create)
+	if err != nil || suppressed {
+		t.Fatalf("first CreateOnce = suppressed %v, %v", suppressed, err)
+	}
+
+	clock.Advance(9 * time.Second)
+	again, suppressed, err := de.CreateOnce(fp, create)
+	if err != nil || !suppressed || again.ID != first.ID {
+		t.Errorf("CreateOnce within the window = %d suppressed %v, %v; want discussion %d suppressed", again.ID, suppressed, err, first.ID)
+	}
+	if calls() != 1 {
+		t.Errorf("create called %d times within the window, want 1", calls())
+	}
+
+	// The window counts from the creation, not from the suppressed retry
+	clock.Advance(2 * time.Second)
+	later, suppressed, err := de.CreateOnce(fp, create)
+	if err != nil || suppressed || later.ID == first.ID {
+		t.Errorf("CreateOnce after the window = %d suppressed %v, %v; want a new discussion", later.ID, suppressed, err)
+	}
+	if calls() != 2 {
+		t.Errorf("create called %d times after the window, want 2", calls())
+	}
+}
+
+func TestCreateOnceDisabled(t *testing.T) {
+	de := newTestEngine(t)
+	newFakeClock(de)
+	if err := de.db.SetSettingJSON(database.SettingDuplicateGuard, models.DuplicateGuardConfig{WindowSeconds: 0}); err != nil {
+		t.Fatalf("SetSettingJSON: %v", err)
+	}
+	create, calls := countingCreate(t, de)
+	fp := DiscussionFingerprint("10.0.0.1", "Tabs or spaces", []int64{1, 2})
+
+	for i := 0; i < 3; i++ {
+		if _, suppressed, err := de.CreateOnce(fp, create); err != nil || suppressed {
+			t.Errorf("CreateOnce %d with the guard off = suppressed %v, %v", i, suppressed, err)
+		}
+	}
+	if calls() != 3 {
+		t.Errorf("create called %d times with the guard off, want 3", calls())
+	}
+}
+
+func TestCreateOnceIgnoresDeleted(t *testing.T) {
+	de := newTestEngine(t)
+	newFakeClock(de)
+	create, calls := countingCreate(t, de)
+	fp := DiscussionFingerprint("10.0.0.1", "Tabs or spaces", []int64{1, 2})
+
+	first, _, err := de.CreateOnce(fp, create)
+	if err != nil {
+		t.Fatalf("CreateOnce: %v", err)
+	}
+	if err := de.db.MarkDiscussionDeleting(first.ID, false); err != nil {
+		t.Fatalf("MarkDiscussionDeleting: %v", err)
+	}
+
+	again, suppressed, err := de.CreateOnce(fp, create)
+	if err != nil || suppressed || again.ID == first.ID {
+		t.Errorf("CreateOnce after deleting the original = %d suppressed %v, %v; want a new discussion", again.ID, suppressed, err)
+	}
+	if calls() != 2 {
+		t.Errorf("create called %d times, want 2", calls())
+	}
+}
+
+func TestCreateOnceConcurrent(t *testing.T) {
+	de := newTestEngine(t)
+	create, calls := countingCreate(t, de)
+	fp := DiscussionFingerprint("10.0.0.1", "Tabs or spaces", []int64{1, 2})
+
+	var wg sync.WaitGroup
+	ids := make([]int64, 8)
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			discussion, _, err := de.CreateOnce(fp, create)
+			if err != nil {
+				t.Errorf("CreateOnce: %v", err)
+				return
+			}
+			ids[i] = discussion.ID
+		}(i)
+	}
+	wg.Wait()
+
+	if calls() != 1 {
+		t.Errorf("create called %d times by racing submissions, want 1", calls())
+	}
+	for i, id := range ids {
+		if id != ids[0] {
+			t.Errorf("submission %d got discussion %d, want %d", i, id, ids[0])
+		}
+	}
+}
+
+func TestDiscussionFingerprint(t *testing.T) {
+	base := DiscussionFingerprint("10.0.0.1", "Tabs or spaces", []int64{1, 2, 3})
+	tests := []struct {
+		name     string
+		client   string
+		topic    string
+		agentIDs []int64
+		same     bool
+	}{
+		{"identical", "10.0.0.1", "Tabs or spaces", []int64{1, 2, 3}, true},
+		{"topic case", "10.0.0.1", "TABS OR SPACES", []int64{1, 2, 3}, true},
+		{"topic spaces", "10.0.0.1", "  Tabs or spaces\n", []int64{1, 2, 3}, true},
+		{"agent order", "10.0.0.1", "Tabs or spaces", []int64{3, 1, 2}, true},
+		{"other client", "10.0.0.2", "Tabs or spaces", []int64{1, 2, 3}, false},
+		{"other topic", "10.0.0.1", "Tabs or spaces?", []int64{1, 2, 3}, false},
+		{"other agents", "10.0.0.1", "Tabs or spaces", []int64{1, 2, 4}, false},
+		{"fewer agents", "10.0.0.1", "Tabs or spaces", []int64{1, 2}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := append([]int64(nil), tt.agentIDs...)
+			got := DiscussionFingerprint(tt.client, tt.topic, tt.agentIDs)
+			if (got == base) != tt.same {
+				t.Errorf("fingerprint equal to the base = %v, want %v", got == base, tt.same)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.agentIDs) {
+				t.Errorf("DiscussionFingerprint reordered the caller's agent IDs to %v", tt.agentIDs)
+			}
+		})
+	}
+}
+
+func TestRecentDiscussionsEviction(t *testing.T) {
+	r := newRecentDiscussions()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i <= maxRecentDiscussions; i++ {
+		r.add(fmt.Sprint("fp", i), int64(i+1), start)
+	}
+
+	if r.order.Len() != maxRecentDiscussions || len(r.entries) != maxRecentDiscussions {
+		t.Errorf("remembered %d/%d fingerprints, want %d", r.order.Len(), len(r.entries), maxRecentDiscussions)
+	}
+	if _, ok := r.lookup("fp0", start); ok {
+		t.Error("the oldest fingerprint was kept past the limit")
+	}
+	if id, ok := r.lookup("fp1", start); !ok || id != 2 {
+		t.Errorf("lookup(fp1) = %d %v, want 2 true", id, ok)
+	}
+
+	// An expired entry is dropped when looked up
+	if _, ok := r.lookup("fp2", start.Add(time.Second)); ok {
+		t.Error("lookup returned an entry created before the cutoff")
+	}
+	if _, ok := r.entries["fp2"]; ok {
+		t.Error("an expired entry was kept after lookup")
+	}
+}
@@ -0,0 +1,154 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+)
+
+// newScriptedProvider answers chat completions with reply, reporting the
+// given token usage, for the first answers calls and fails every call after
+func newScriptedProvider(t *testing.T, reply string, promptTokens, completionTokens int, answers int64) *httptest.Server {
+	t.Helper()
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) > answers {
+			http.Error(w, `{"error":{"message":"overloaded"}}`, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":%q},"finish_reason":"stop"}],"usage":{"prompt_tokens":%d,"completion_tokens":%d}}`, reply, promptTokens, completionTokens)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// runToEnd runs a debate between the given agents and returns it once it ended
+func runToEnd(t *testing.T, de *DebateEngine, agents []*models.Agent, maxRounds int, settings models.DiscussionSettings) *models.Discussion {
+	t.Helper()
+	var ids []int64
+	for _, agent := range agents {
+		ids = append(ids, agent.ID)
+	}
+	settings.SummaryBackend = models.SummaryBackendExtractive
+	discussion, err := de.RunDebate(context.Background(), "Tabs or spaces", ids, nil, nil, nil, maxRounds, "en", models.DefaultMaxCharLimit, settings)
+	if err != nil {
+		t.Fatalf("RunDebate: %v", err)
+	}
+	var ended *models.Discussion
+	waitUntil(t, "the debate to end", func() bool {
+		ended, err = de.db.GetDiscussion(discussion.ID)
+		return err == nil && !ended.InProgress()
+	})
+	return ended
+}
+
+func TestEndReasons(t *testing.T) {
+	tests := []struct {
+		name       string
+		reply      string
+		usage      int // tokens reported per call, split between prompt and completion
+		answers    int64
+		maxRounds  int
+		settings   models.DiscussionSettings
+		wantStatus string
+		wantReason string
+		wantRounds int
+	}{
+		{"every round ran", "Spaces, always.", 8, 100, 2, models.DiscussionSettings{}, "completed", models.EndReasonMaxRounds, 2},
+		{"nobody answered a later round", "Spaces, always.", 8, 1, 3, models.DiscussionSettings{}, "completed", models.EndReasonAllAgentsDone, 1},
+		{"positions converged", "Yes, we agree on spaces.", 8, 100, 3, models.DiscussionSettings{EnableConsensusCheck: true}, "completed", models.EndReasonConsensus, 1},
+		{"token budget spent", "Spaces, always.", 1000, 100, 3, models.DiscussionSettings{TokenBudget: models.MinTokenBudget}, "completed", models.EndReasonBudget, 1},
+		{"everyone failed in round 1", "Spaces, always.", 8, 0, 2, models.DiscussionSettings{}, "failed", models.EndReasonFailed, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			de := newTestEngine(t)
+			if err := de.db.SetSettingJSON(database.SettingAgentRetries, models.AgentRetryConfig{MaxRetries: 0, BaseDelayMs: 1, MaxDelayMs: 1}); err != nil {
+				t.Fatalf("SetSettingJSON: %v", err)
+			}
+			server := newScriptedProvider(t, tt.reply, tt.usage/2, tt.usage-tt.usage/2, tt.answers)
+			alice := insertTestAgent(t, de, "Alice", server.URL)
+
+			d := runToEnd(t, de, []*models.Agent{alice}, tt.maxRounds, tt.settings)
+			if d.Status != tt.wantStatus || d.EndReason != tt.wantReason || d.CompletedRounds != tt.wantRounds {
+				t.Errorf("ended %s (%s) after %d rounds, want %s (%s) after %d", d.Status, d.EndReason, d.CompletedRounds, tt.wantStatus, tt.wantReason, tt.wantRounds)
+			}
+			if d.MaxRounds != tt.maxRounds {
+				t.Errorf("max rounds = %d, want the requested %d kept", d.MaxRounds, tt.maxRounds)
+			}
+			if d.Status == "completed" {
+				want := fmt.Sprintf("after %d of %d rounds because %s", tt.wantRounds, tt.maxRounds, models.EndReasonText(tt.wantReason))
+				if !strings.Contains(d.FinalSummary, want) {
+					t.Errorf("summary = %q, want it to say it ended %s", d.FinalSummary, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStoppedEndReason(t *testing.T) {
+	de := newTestEngine(t)
+	discussion := startFrozenDebate(t, de)
+
+	if err := de.StopDiscussion(discussion.ID); err != nil {
+		t.Fatalf("StopDiscussion: %v", err)
+	}
+	var d *models.Discussion
+	waitUntil(t, "the debate to end", func() bool {
+		var err error
+		d, err = de.db.GetDiscussion(discussion.ID)
+		return err == nil && !d.InProgress()
+	})
+	if d.Status != "stopped" || d.EndReason != models.EndReasonStopped || d.CompletedRounds != 0 {
+		t.Errorf("ended %s (%s) after %d rounds, want stopped before finishing round 1", d.Status, d.EndReason, d.CompletedRounds)
+	}
+}
+
+func TestStopKeepsRecordedProgress(t *testing.T) {
+	de := newTestEngine(t)
+	// The first turn is answered; the second hangs until the debate stops
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Reading the body lets the server notice the call being cancelled
+		io.Copy(io.Discard, r.Body)
+		if calls.Add(1) > 1 {
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Spaces."},"finish_reason":"stop"}]}`)
+	}))
+	t.Cleanup(server.Close)
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+
+	settings := models.DiscussionSettings{SummaryBackend: models.SummaryBackendExtractive}
+	discussion, err := de.RunDebate(context.Background(), "Tabs or spaces", []int64{alice.ID}, nil, nil, nil, 3, "en", models.DefaultMaxCharLimit, settings)
+	if err != nil {
+		t.Fatalf("RunDebate: %v", err)
+	}
+	waitUntil(t, "round 1 to be recorded and round 2 to start", func() bool {
+		d, err := de.db.GetDiscussion(discussion.ID)
+		return err == nil && d.CompletedRounds == 1 && calls.Load() == 2
+	})
+	if err := de.StopDiscussion(discussion.ID); err != nil {
+		t.Fatalf("StopDiscussion: %v", err)
+	}
+
+	d, err := de.db.GetDiscussion(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussion: %v", err)
+	}
+	// Neither the stop nor the engine's progress and summary were lost
+	if d.Status != "stopped" || d.EndReason != models.EndReasonStopped || d.CompletedRounds != 1 || d.FinalSummary == "" {
+		t.Errorf("ended %s (%s) after %d rounds with summary %q, want stopped after round 1 with a summary", d.Status, d.EndReason, d.CompletedRounds, d.FinalSummary)
+	}
+}
@@ -0,0 +1,148 @@
+package orchestrator
+
+import (
+	"court-table-ai/pkg/models"
+	"strconv"
+)
+
+// Event types other than the EngineEvent types, which are used as they are
+const (
+	EventLog        = "log"
+	EventLogDelta   = "log_delta"
+	EventDiscussion = "discussion"
+	EventUpdate     = "update"
+	EventVerdict    = "verdict"
+	EventAnalysis   = "analysis"
+)
+
+// Event is one update delivered to subscribers. Data is a copy taken when
+// the update was broadcast, a models.DiscussionLog, models.LogDelta,
+// models.Discussion, models.Verdict, models.DiscussionAnalysis or models.EngineEvent value, so subscribers can read it while the engine goes
+// on changing its own values. Subscribers must not modify it.
+type Event struct {
+	Type string
+	Data interface{}
+	// Revision orders discussion events. It is set from an engine-wide
+	// counter when the event is broadcast, so a higher revision is a newer
+	// state of the discussion.
+	Revision int64
+}
+
+// ID identifies the event for deduplication: "log-<log ID>" for log entries
+// and "rev-<revision>" for discussion updates. Other events have no identity
+// and return "".
+func (e Event) ID() string {
+	switch e.Type {
+	case EventLog:
+		if l, ok := e.Data.(models.DiscussionLog); ok {
+			return "log-" + strconv.FormatInt(l.ID, 10)
+		}
+	case EventDiscussion:
+		return "rev-" + strconv.FormatInt(e.Revision, 10)
+	}
+	return ""
+}
+
+// newEvent wraps a broadcast value in an event, copying it
+func newEvent(data interface{}) Event {
+	switch v := data.(type) {
+	case *models.DiscussionLog:
+		return Event{Type: EventLog, Data: copyLog(v)}
+	case *models.LogDelta:
+		return Event{Type: EventLogDelta, Data: *v}
+	case *models.Discussion:
+		return Event{Type: EventDiscussion, Data: copyDiscussion(v)}
+	case *models.Verdict:
+		return Event{Type: EventVerdict, Data: copyVerdict(v)}
+	case *models.DiscussionAnalysis:
+		return Event{Type: EventAnalysis, Data: copyAnalysis(v)}
+	case *models.EngineEvent:
+		return Event{Type: v.Type, Data: *v}
+	default:
+		return Event{Type: EventUpdate, Data: data}
+	}
+}
+
+// copyLog returns a copy of l that shares no memory with it
+func copyLog(l *models.DiscussionLog) models.DiscussionLog {
+	c := *l
+	if l.Metadata != nil {
+		c.Metadata = make(models.JSONMap, len(l.Metadata))
+		for k, v := range l.Metadata {
+			c.Metadata[k] = v
+		}
+	}
+	return c
+}
+
+// copyDiscussion returns a copy of d that shares no memory with it
+func copyDiscussion(d *models.Discussion) models.Discussion {
+	c := *d
+	c.AgentIDs = append(models.JSONSlice[int64](nil), d.AgentIDs...)
+	if d.ModeratorID != nil {
+		id := *d.ModeratorID
+		c.ModeratorID = &id
+	}
+	if d.JudgeID != nil {
+		id := *d.JudgeID
+		c.JudgeID = &id
+	}
+	c.Stances = append([]models.AgentStance(nil), d.Stances...)
+	if d.Verdict != nil {
+		verdict := copyVerdict(d.Verdict)
+		c.Verdict = &verdict
+	}
+	if o := d.Settings.ModeratorOverrides; o != nil {
+		overrides := *o
+		if o.Temperature != nil {
+			t := *o.Temperature
+			overrides.Temperature = &t
+		}
+		if o.MaxTokens != nil {
+			n := *o.MaxTokens
+			overrides.MaxTokens = &n
+		}
+		c.Settings.ModeratorOverrides = &overrides
+	}
+	if a := d.Settings.Acceptance; a != nil {
+		acceptance := *a
+		acceptance.RefusalPatterns = append([]string(nil), a.RefusalPatterns...)
+		c.Settings.Acceptance = &acceptance
+	}
+	if w := d.Settings.Webhook; w != nil {
+		webhook := *w
+		webhook.Events = append([]string(nil), w.Events...)
+		c.Settings.Webhook = &webhook
+	}
+	return c
+}
+
+// copyVerdict returns a copy of v that shares no memory with it
+func copyVerdict(v *models.Verdict) models.Verdict {
+	c := *v
+	if v.WinnerAgentID != nil {
+		id := *v.WinnerAgentID
+		c.WinnerAgentID = &id
+	}
+	if v.Scores != nil {
+		c.Scores = make(models.VerdictScores, len(v.Scores))
+		for id, score := range v.Scores {
+			c.Scores[id] = score
+		}
+	}
+	return c
+}
+
+// copyAnalysis returns a copy of a that shares no memory with it
+func copyAnalysis(a *models.DiscussionAnalysis) models.DiscussionAnalysis {
+	c := *a
+	c.UnknownNames = append(models.JSONSlice[string](nil), a.UnknownNames...)
+	c.Points = make(models.JSONSlice[models.AnalysisPoint], len(a.Points))
+	for i, point := range a.Points {
+		c.Points[i] = models.AnalysisPoint{Point: point.Point, Positions: make(map[int64]string, len(point.Positions))}
+		for id, position := range point.Positions {
+			c.Points[i].Positions[id] = position
+		}
+	}
+	return c
+}
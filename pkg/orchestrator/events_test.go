@@ -0,0 +1,108 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+func TestBroadcastDeliversCopies(t *testing.T) {
+	de := newTestEngine(t)
+	ch, err := de.Subscribe(1)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer de.Unsubscribe(1, ch)
+
+	moderator, winner := int64(3), int64(1)
+	discussion := &models.Discussion{
+		ID:          1,
+		Status:      "running",
+		AgentIDs:    models.JSONSlice[int64]{1, 2},
+		ModeratorID: &moderator,
+		Verdict:     &models.Verdict{WinnerAgentID: &winner, Scores: models.VerdictScores{1: 8}},
+	}
+	entry := &models.DiscussionLog{ID: 7, DiscussionID: 1, Content: "Spaces.", Metadata: models.JSONMap{"round": "1"}}
+	de.broadcast(1, discussion)
+	de.broadcast(1, entry)
+
+	// The engine goes on changing its own values after broadcasting them
+	discussion.Status = "completed"
+	discussion.AgentIDs[0] = 99
+	moderator, winner = 98, 97
+	discussion.Verdict.Scores[1] = 0
+	entry.Content = "Tabs."
+	entry.Metadata["round"] = "2"
+
+	got := (<-ch).Data.(models.Discussion)
+	if got.Status != "running" || got.AgentIDs[0] != 1 || *got.ModeratorID != 3 || *got.Verdict.WinnerAgentID != 1 || got.Verdict.Scores[1] != 8 {
+		t.Errorf("delivered discussion = %+v, want the state when it was broadcast", got)
+	}
+	event := <-ch
+	if l := event.Data.(models.DiscussionLog); l.Content != "Spaces." || l.Metadata["round"] != "1" || event.Type != EventLog || event.ID() != "log-7" {
+		t.Errorf("delivered log = %s %+v, want the entry as broadcast", event.Type, l)
+	}
+}
+
+// TestConcurrentSubscribers runs a debate while several subscribers read and
+// encode its events. Run it with -race.
+func TestConcurrentSubscribers(t *testing.T) {
+	de := newTestEngine(t)
+	gate := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-gate:
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Spaces, always."},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":3}}`))
+	}))
+	defer server.Close()
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+	bob := insertTestAgent(t, de, "Bob", server.URL)
+	chair := insertTestAgent(t, de, "Chair", server.URL)
+
+	discussion, err := de.RunDebate(context.Background(), "Tabs or spaces", []int64{alice.ID, bob.ID}, nil, &chair.ID, nil, 3, "en", models.DefaultMaxCharLimit, models.DiscussionSettings{SummaryBackend: models.SummaryBackendExtractive})
+	if err != nil {
+		t.Fatalf("RunDebate: %v", err)
+	}
+	endedBeforeCleanup(t, de, discussion.ID)
+
+	const readers = 4
+	var wg sync.WaitGroup
+	counts := make([]int, readers)
+	for i := 0; i < readers; i++ {
+		ch, err := de.Subscribe(discussion.ID)
+		if err != nil {
+			t.Fatalf("Subscribe: %v", err)
+		}
+		wg.Add(1)
+		go func(i int, ch chan Event) {
+			defer wg.Done()
+			defer de.Unsubscribe(discussion.ID, ch)
+			for event := range ch {
+				if _, err := json.Marshal(event.Data); err != nil {
+					t.Errorf("encoding %s event: %v", event.Type, err)
+				}
+				counts[i]++
+				if d, ok := event.Data.(models.Discussion); ok && !d.InProgress() {
+					return
+				}
+			}
+		}(i, ch)
+	}
+	close(gate)
+	wg.Wait()
+
+	for i, n := range counts {
+		if n < 6 {
+			t.Errorf("reader %d got %d events, want every turn and the final status", i, n)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package orchestrator
+
+import (
+	"court-table-ai/pkg/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GoogleSafetyRating is Gemini's verdict on one harm category of a prompt or
+// candidate
+type GoogleSafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked,omitempty"`
+}
+
+// googleStopReasons explains the finish reasons of a Gemini candidate that
+// withheld the reply, in whole or in part
+var googleStopReasons = map[string]string{
+	"SAFETY":             "Gemini content filter blocked the reply",
+	"RECITATION":         "Gemini content filter blocked the reply as recitation of training data",
+	"BLOCKLIST":          "Gemini content filter blocked the reply for a blocklisted term",
+	"PROHIBITED_CONTENT": "Gemini content filter blocked the reply as prohibited content",
+	"SPII":               "Gemini content filter blocked the reply for sensitive personal information",
+	"OTHER":              "Gemini stopped the reply for an unspecified reason",
+}
+
+// parseGoogleBody reads a generateContent response. The text parts of the
+// first candidate are joined, leaving out thought summaries. A blocked
+// prompt, or a candidate the filters stopped, fails with the reason and the
+// harm categories involved; text generated before the stop is kept as the
+// partial Content.
+func parseGoogleBody(body []byte) (*models.AgentResponse, error) {
+	var googleResp GoogleResponse
+	if err := json.Unmarshal(body, &googleResp); err != nil {
+		return &models.AgentResponse{
+			Success:      false,
+			ErrorMessage: fmt.Sprintf("Failed to unmarshal response: %v", err),
+		}, err
+	}
+	usage := googleResp.UsageMetadata
+
+	if reason := googleResp.PromptFeedback.BlockReason; reason != "" {
+		message := fmt.Sprintf("Gemini content filter blocked the prompt (%s)", reason)
+		if categories := safetyCategories(googleResp.PromptFeedback.SafetyRatings); categories != "" {
+			message += ": " + categories
+		}
+		return &models.AgentResponse{Success: false, ErrorMessage: message, PromptTokens: usage.PromptTokenCount}, errors.New(message)
+	}
+
+	if len(googleResp.Candidates) == 0 {
+		return &models.AgentResponse{
+			Success:      false,
+			ErrorMessage: "No candidates returned from Gemini API",
+		}, fmt.Errorf("no candidates")
+	}
+
+	candidate := googleResp.Candidates[0]
+	var text strings.Builder
+	for _, part := range candidate.Content.Parts {
+		if !part.Thought {
+			text.WriteString(part.Text)
+		}
+	}
+
+	if explanation, stopped := googleStopReasons[candidate.FinishReason]; stopped {
+		message := fmt.Sprintf("%s (%s)", explanation, candidate.FinishReason)
+		if categories := safetyCategories(candidate.SafetyRatings); categories != "" {
+			message += ": " + categories
+		}
+		return &models.AgentResponse{
+			Success:          false,
+			Content:          text.String(),
+			ErrorMessage:     message,
+			PromptTokens:     usage.PromptTokenCount,
+			CompletionTokens: usage.CandidatesTokenCount,
+		}, errors.New(message)
+	}
+
+	if len(candidate.Content.Parts) == 0 {
+		return &models.AgentResponse{
+			Success:      false,
+			ErrorMessage: "No content parts returned from Gemini API",
+		}, fmt.Errorf("no content parts")
+	}
+
+	return &models.AgentResponse{
+		Success:          true,
+		Content:          text.String(),
+		PromptTokens:     usage.PromptTokenCount,
+		CompletionTokens: usage.CandidatesTokenCount,
+	}, nil
+}
+
+// safetyCategories lists the harm categories behind a block, e.g.
+// "dangerous content (HIGH), harassment (MEDIUM)". Categories marked blocked
+// are listed when there are any, else those rated above LOW.
+func safetyCategories(ratings []GoogleSafetyRating) string {
+	var blocked, flagged []string
+	for _, r := range ratings {
+		category := strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(r.Category, "HARM_CATEGORY_"), "_", " "))
+		if r.Probability != "" {
+			category += " (" + r.Probability + ")"
+		}
+		switch {
+		case r.Blocked:
+			blocked = append(blocked, category)
+		case r.Probability == "MEDIUM" || r.Probability == "HIGH":
+			flagged = append(flagged, category)
+		}
+	}
+	if len(blocked) == 0 {
+		blocked = flagged
+	}
+	sort.Strings(blocked)
+	return strings.Join(blocked, ", ")
+}
@@ -0,0 +1,61 @@
+package orchestrator
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+func TestParseGoogleBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantSuccess bool
+		wantContent string
+		wantErr     string
+	}{
+		{"single part", `{"candidates":[{"content":{"parts":[{"text":"Spaces."}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":7,"candidatesTokenCount":2}}`,
+			true, "Spaces.", ""},
+		// Long replies arrive split across parts, and thought summaries are left out
+		{"multiple parts", `{"candidates":[{"content":{"parts":[{"text":"Weighing both sides.","thought":true},{"text":"Spaces keep diffs "},{"text":"aligned."}]},"finishReason":"STOP"}]}`,
+			true, "Spaces keep diffs aligned.", ""},
+		{"max tokens", `{"candidates":[{"content":{"parts":[{"text":"Spaces keep"}]},"finishReason":"MAX_TOKENS"}]}`,
+			true, "Spaces keep", ""},
+		{"blocked reply", `{"candidates":[{"finishReason":"SAFETY","safetyRatings":[{"category":"HARM_CATEGORY_HARASSMENT","probability":"LOW"},{"category":"HARM_CATEGORY_DANGEROUS_CONTENT","probability":"HIGH","blocked":true}]}]}`,
+			false, "", "Gemini content filter blocked the reply (SAFETY): dangerous content (HIGH)"},
+		// Without a blocked flag the categories rated MEDIUM or above are named
+		{"stopped midway", `{"candidates":[{"content":{"parts":[{"text":"As the song goes"}]},"finishReason":"RECITATION","safetyRatings":[{"category":"HARM_CATEGORY_HATE_SPEECH","probability":"MEDIUM"},{"category":"HARM_CATEGORY_HARASSMENT","probability":"NEGLIGIBLE"}]}]}`,
+			false, "As the song goes", "Gemini content filter blocked the reply as recitation of training data (RECITATION): hate speech (MEDIUM)"},
+		{"other", `{"candidates":[{"finishReason":"OTHER"}]}`,
+			false, "", "Gemini stopped the reply for an unspecified reason (OTHER)"},
+		{"blocked prompt", `{"promptFeedback":{"blockReason":"SAFETY","safetyRatings":[{"category":"HARM_CATEGORY_SEXUALLY_EXPLICIT","probability":"HIGH","blocked":true}]},"usageMetadata":{"promptTokenCount":7}}`,
+			false, "", "Gemini content filter blocked the prompt (SAFETY): sexually explicit (HIGH)"},
+		{"no candidates", `{"candidates":[]}`, false, "", "No candidates returned from Gemini API"},
+		{"no parts", `{"candidates":[{"content":{"parts":[]},"finishReason":"STOP"}]}`, false, "", "No content parts returned from Gemini API"},
+	}
+	for _, tt := range tests {
+		response, err := parseGoogleBody([]byte(tt.body))
+		if response.Success != tt.wantSuccess || (err == nil) != tt.wantSuccess {
+			t.Errorf("%s: parseGoogleBody = %+v, %v, want success %v", tt.name, response, err, tt.wantSuccess)
+			continue
+		}
+		if response.Content != tt.wantContent || response.ErrorMessage != tt.wantErr {
+			t.Errorf("%s: content %q, error %q, want %q, %q", tt.name, response.Content, response.ErrorMessage, tt.wantContent, tt.wantErr)
+		}
+	}
+}
+
+func TestCallGoogleExplainsBlocks(t *testing.T) {
+	ac, _ := recordingClient(http.StatusOK, `{"candidates":[{"finishReason":"SAFETY","safetyRatings":[{"category":"HARM_CATEGORY_HARASSMENT","probability":"HIGH","blocked":true}]}],"usageMetadata":{"promptTokenCount":12}}`)
+	agent := &models.Agent{Name: "Gemini", ProviderType: models.ProviderGoogle, ProviderURL: "https://generativelanguage.googleapis.com/v1beta", APIToken: "key", ModelName: "gemini-1.5-flash", TimeoutSeconds: 10}
+
+	response, err := ac.CallAgent(context.Background(), agent, "Tabs or spaces?", "")
+	if err == nil || response.Success {
+		t.Fatalf("CallAgent = %+v, want a failure", response)
+	}
+	if want := "Gemini content filter blocked the reply (SAFETY): harassment (HIGH)"; response.ErrorMessage != want || response.PromptTokens != 12 {
+		t.Errorf("response = %+v, want %q with the prompt tokens", response, want)
+	}
+}
@@ -0,0 +1,143 @@
+package orchestrator
+
+import (
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// HostLimits returns the stored host limits, or the defaults when none were
+// saved
+func (de *DebateEngine) HostLimits() models.HostLimitsConfig {
+	cfg := models.DefaultHostLimits()
+	found, err := de.db.GetSettingJSON(database.SettingHostLimits, &cfg)
+	if err != nil {
+		log.Printf("Failed to read host limits: %v", err)
+	}
+	if !found || err != nil || cfg.Validate() != nil {
+		cfg = models.DefaultHostLimits()
+	}
+	return cfg
+}
+
+// HostLoadWarnings checks a discussion about to start against the host
+// limits, counting the calls running discussions already make to each host
+func (de *DebateEngine) HostLoadWarnings(agents []*models.Agent, moderator *models.Agent, parallel bool) []models.HostLoadWarning {
+	de.runMu.Lock()
+	ids := make([]int64, 0, len(de.running))
+	for id := range de.running {
+		ids = append(ids, id)
+	}
+	de.runMu.Unlock()
+
+	running := make(map[string][]int64)
+	for _, id := range ids {
+		discussion, err := de.db.GetDiscussion(id)
+		if err != nil {
+			continue
+		}
+		agentIDs := append([]int64(nil), discussion.AgentIDs...)
+		if discussion.ModeratorID != nil {
+			agentIDs = append(agentIDs, *discussion.ModeratorID)
+		}
+		seen := make(map[string]bool)
+		for _, agentID := range agentIDs {
+			agent, err := de.db.GetAgent(agentID)
+			if err != nil {
+				continue
+			}
+			if host := providerHost(agent); host != "" && !seen[host] {
+				seen[host] = true
+				running[host] = append(running[host], id)
+			}
+		}
+	}
+
+	return AnalyzeHostLoad(agents, moderator, parallel, running, de.HostLimits())
+}
+
+// AnalyzeHostLoad groups the agents and moderator of a new discussion by
+// provider host and returns a warning for each host whose limit the
+// discussion would exceed. A sequential discussion makes one call at a time;
+// a parallel one calls every agent of a round at once, and its moderator
+// only between rounds. running maps hosts to the running discussions calling
+// them, each counted as one call. Warnings are ordered by host.
+func AnalyzeHostLoad(agents []*models.Agent, moderator *models.Agent, parallel bool, running map[string][]int64, limits models.HostLimitsConfig) []models.HostLoadWarning {
+	byHost := make(map[string][]int64)
+	debaters := make(map[string]int)
+	var hosts []string
+	add := func(agent *models.Agent, debater bool) {
+		host := providerHost(agent)
+		if host == "" {
+			return
+		}
+		if _, ok := byHost[host]; !ok {
+			hosts = append(hosts, host)
+		}
+		byHost[host] = append(byHost[host], agent.ID)
+		if debater {
+			debaters[host]++
+		}
+	}
+	for _, agent := range agents {
+		add(agent, true)
+	}
+	if moderator != nil {
+		add(moderator, false)
+	}
+	sort.Strings(hosts)
+
+	warnings := []models.HostLoadWarning{}
+	for _, host := range hosts {
+		limit := limits.Limit(host)
+		if limit == 0 {
+			continue
+		}
+
+		own := 1
+		if parallel && debaters[host] > 1 {
+			own = debaters[host]
+		}
+		calls := own + len(running[host])
+		if calls <= limit {
+			continue
+		}
+
+		warning := models.HostLoadWarning{
+			Host:                 host,
+			Limit:                limit,
+			ConcurrentCalls:      calls,
+			AgentIDs:             byHost[host],
+			RunningDiscussionIDs: running[host],
+		}
+		var advice []string
+		if parallel && own > 1 {
+			advice = append(advice, "use sequential round mode")
+		}
+		if len(running[host]) > 0 {
+			advice = append(advice, "wait for the running discussions to finish")
+		}
+		advice = append(advice, "move agents to another host", "raise the host's limit if it can serve more")
+		warning.Message = fmt.Sprintf("Host %s may receive %d concurrent calls but is limited to %d, which shows up as timeouts; %s",
+			host, calls, limit, strings.Join(advice, ", or "))
+		warnings = append(warnings, warning)
+	}
+	return warnings
+}
+
+// providerHost returns the lower-cased host[:port] an agent calls, or "" for
+// agents without a usable provider URL
+func providerHost(agent *models.Agent) string {
+	if agent == nil || agent.Disabled {
+		return ""
+	}
+	u, err := url.Parse(agent.ProviderURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
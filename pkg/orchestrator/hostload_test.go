@@ -0,0 +1,151 @@
+package orchestrator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+func TestAnalyzeHostLoad(t *testing.T) {
+	agent := func(id int64, url string) *models.Agent {
+		return &models.Agent{ID: id, ProviderURL: url}
+	}
+	ollama := "http://localhost:11434"
+	lan := "http://192.168.1.20:11434"
+	cloud := "https://api.openai.com/v1"
+	defaults := models.DefaultHostLimits()
+
+	tests := []struct {
+		name      string
+		agents    []*models.Agent
+		moderator *models.Agent
+		parallel  bool
+		running   map[string][]int64
+		limits    models.HostLimitsConfig
+		want      []models.HostLoadWarning
+		advice    []string
+	}{
+		{
+			name:      "sequential on one local host",
+			agents:    []*models.Agent{agent(1, ollama), agent(2, ollama)},
+			moderator: agent(3, ollama),
+			limits:    defaults,
+		},
+		{
+			name:      "parallel debaters and moderator on one local host",
+			agents:    []*models.Agent{agent(1, ollama), agent(2, ollama)},
+			moderator: agent(3, ollama),
+			parallel:  true,
+			limits:    defaults,
+			want:      []models.HostLoadWarning{{Host: "localhost:11434", Limit: 1, ConcurrentCalls: 2, AgentIDs: []int64{1, 2, 3}}},
+			advice:    []string{"use sequential round mode"},
+		},
+		{
+			name:     "parallel debaters on separate hosts",
+			agents:   []*models.Agent{agent(1, ollama), agent(2, lan)},
+			parallel: true,
+			limits:   defaults,
+		},
+		{
+			name:      "parallel with only the moderator sharing a host",
+			agents:    []*models.Agent{agent(1, ollama), agent(2, cloud)},
+			moderator: agent(3, ollama),
+			parallel:  true,
+			limits:    defaults,
+		},
+		{
+			name:     "cloud hosts are unlimited by default",
+			agents:   []*models.Agent{agent(1, cloud), agent(2, cloud), agent(3, cloud)},
+			parallel: true,
+			limits:   defaults,
+		},
+		{
+			name:     "default limit for other hosts",
+			agents:   []*models.Agent{agent(1, cloud), agent(2, cloud), agent(3, cloud)},
+			parallel: true,
+			limits:   models.HostLimitsConfig{LocalLimit: 1, DefaultLimit: 2},
+			want:     []models.HostLoadWarning{{Host: "api.openai.com", Limit: 2, ConcurrentCalls: 3, AgentIDs: []int64{1, 2, 3}}},
+		},
+		{
+			name:     "override by host and port",
+			agents:   []*models.Agent{agent(1, lan), agent(2, lan)},
+			parallel: true,
+			limits:   models.HostLimitsConfig{LocalLimit: 1, Hosts: map[string]int{"192.168.1.20:11434": 2}},
+		},
+		{
+			name:     "override by host name",
+			agents:   []*models.Agent{agent(1, lan), agent(2, lan), agent(3, lan)},
+			parallel: true,
+			limits:   models.HostLimitsConfig{LocalLimit: 1, Hosts: map[string]int{"192.168.1.20": 2}},
+			want:     []models.HostLoadWarning{{Host: "192.168.1.20:11434", Limit: 2, ConcurrentCalls: 3, AgentIDs: []int64{1, 2, 3}}},
+		},
+		{
+			name:     "local limit turned off",
+			agents:   []*models.Agent{agent(1, ollama), agent(2, ollama)},
+			parallel: true,
+			limits:   models.HostLimitsConfig{},
+		},
+		{
+			name:    "running discussions on the host",
+			agents:  []*models.Agent{agent(1, ollama)},
+			running: map[string][]int64{"localhost:11434": {7}},
+			limits:  defaults,
+			want:    []models.HostLoadWarning{{Host: "localhost:11434", Limit: 1, ConcurrentCalls: 2, AgentIDs: []int64{1}, RunningDiscussionIDs: []int64{7}}},
+			advice:  []string{"wait for the running discussions to finish"},
+		},
+		{
+			name:     "several hosts ordered by name",
+			agents:   []*models.Agent{agent(1, lan), agent(2, ollama), agent(3, lan), agent(4, ollama)},
+			parallel: true,
+			limits:   defaults,
+			want: []models.HostLoadWarning{
+				{Host: "192.168.1.20:11434", Limit: 1, ConcurrentCalls: 2, AgentIDs: []int64{1, 3}},
+				{Host: "localhost:11434", Limit: 1, ConcurrentCalls: 2, AgentIDs: []int64{2, 4}},
+			},
+		},
+		{
+			name:     "disabled agents and unusable URLs are ignored",
+			agents:   []*models.Agent{agent(1, ollama), {ID: 2, ProviderURL: ollama, Disabled: true}, agent(3, "not a url"), agent(4, "http://[::1")},
+			parallel: true,
+			limits:   defaults,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AnalyzeHostLoad(tt.agents, tt.moderator, tt.parallel, tt.running, tt.limits)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d warnings, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, w := range got {
+				if !strings.HasPrefix(w.Message, "Host "+w.Host+" ") {
+					t.Errorf("warning message %q does not name the host", w.Message)
+				}
+				for _, advice := range tt.advice {
+					if !strings.Contains(w.Message, advice) {
+						t.Errorf("warning message %q lacks %q", w.Message, advice)
+					}
+				}
+				w.Message = ""
+				if !reflect.DeepEqual(w, tt.want[i]) {
+					t.Errorf("warning %d = %+v, want %+v", i, w, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzeHostLoadAdvice(t *testing.T) {
+	ollama := "http://localhost:11434"
+	agents := []*models.Agent{{ID: 1, ProviderURL: ollama}}
+
+	// Sequential mode cannot help a discussion that already makes one call
+	got := AnalyzeHostLoad(agents, nil, true, map[string][]int64{"localhost:11434": {7}}, models.DefaultHostLimits())
+	if len(got) != 1 || strings.Contains(got[0].Message, "sequential") {
+		t.Errorf("warnings = %+v, want one without sequential mode advice", got)
+	}
+	if got := AnalyzeHostLoad(nil, nil, true, nil, models.DefaultHostLimits()); got == nil || len(got) != 0 {
+		t.Errorf("warnings without agents = %#v, want an empty list", got)
+	}
+}
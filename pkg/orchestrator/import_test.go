@@ -0,0 +1,51 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+func TestImportedStubsCannotDebate(t *testing.T) {
+	de := newTestEngine(t)
+	server, calls := newTestProvider(t, "Spaces, always.")
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+
+	doc := &models.TranscriptImport{
+		Topic:               "Tabs or spaces",
+		Participants:        []models.ImportParticipant{{Name: "Alice"}, {Name: "Bob"}},
+		Turns:               []models.ImportTurn{{Participant: "Alice", Content: "Spaces."}, {Participant: "Bob", Content: "Tabs."}},
+		CreateMissingAgents: true,
+	}
+	if err := doc.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	result, err := de.db.ImportDiscussion(doc)
+	if err != nil {
+		t.Fatalf("ImportDiscussion: %v", err)
+	}
+	bob := result.Agents[1].AgentID
+
+	if _, err := de.RunDebate(context.Background(), "Tabs again", []int64{alice.ID, bob}, nil, nil, nil, 1, "en", models.DefaultMaxCharLimit, models.DiscussionSettings{}); !errors.Is(err, ErrAgentDisabled) {
+		t.Errorf("RunDebate with a stub = %v, want ErrAgentDisabled", err)
+	}
+	if _, err := de.RunDebate(context.Background(), "Tabs again", []int64{alice.ID}, nil, &bob, nil, 1, "en", models.DefaultMaxCharLimit, models.DiscussionSettings{}); !errors.Is(err, ErrAgentDisabled) {
+		t.Errorf("RunDebate with a stub moderator = %v, want ErrAgentDisabled", err)
+	}
+	if _, err := de.ResumeDiscussion(result.Discussion.ID); !errors.Is(err, ErrNotResumable) {
+		t.Errorf("ResumeDiscussion on an import = %v, want ErrNotResumable", err)
+	}
+
+	stub, err := de.db.GetAgent(bob)
+	if err != nil {
+		t.Fatalf("GetAgent: %v", err)
+	}
+	if _, err := de.agentClient.CallAgent(context.Background(), stub, "Your turn", ""); !errors.Is(err, ErrAgentDisabled) {
+		t.Errorf("calling a stub = %v, want ErrAgentDisabled", err)
+	}
+	if calls.Load() != 0 {
+		t.Errorf("provider called %d times, want none", calls.Load())
+	}
+}
@@ -0,0 +1,186 @@
+package orchestrator
+
+import (
+	"context"
+	"court-table-ai/pkg/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// judgeSystemPrompt replaces the judge's own system prompt so it rules on
+// the debate rather than taking part in it
+const judgeSystemPrompt = "You are an impartial judge of debates between AI agents. " +
+	"Judge the quality of the arguments made, not which side you agree with, and answer only with the requested JSON."
+
+func init() {
+	registerFeature(FeatureJudge, alwaysOn)
+}
+
+// buildJudgePrompt asks for a JSON verdict on the whole transcript
+func buildJudgePrompt(discussion *models.Discussion, agents []*models.Agent, transcript string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Debate topic: %s\n\n", promptTopic(discussion.Topic))
+	b.WriteString("Participants:\n")
+	for _, agent := range agents {
+		fmt.Fprintf(&b, "- Agent ID %d: %s", agent.ID, agent.Name)
+		if stance := discussion.StanceOf(agent.ID); stance != "" {
+			fmt.Fprintf(&b, " (assigned stance: %s)", stance)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "\nFull transcript:\n%s\n\n", fence(transcript))
+	b.WriteString("Decide which participant argued best. Score every participant from ")
+	fmt.Fprintf(&b, "%d to %d for the strength, evidence and consistency of their arguments.\n\n", models.MinVerdictScore, models.MaxVerdictScore)
+	b.WriteString("Respond with a single JSON object and nothing else, in this format:\n")
+	b.WriteString(`{"winner_agent_id": <agent ID>, "scores": {"<agent ID>": <score>, ...}, "reasoning": "<why the winner won>"}`)
+	b.WriteString("\n\n")
+	fmt.Fprintf(&b, "Write the reasoning in %s.\n", discussion.LanguageName())
+	return b.String()
+}
+
+// judgeDebate asks the judge for a verdict on the finished debate, then
+// stores and broadcasts it. A failed call is recorded in the discussion log
+// and leaves the discussion without a verdict.
+func (de *DebateEngine) judgeDebate(ctx context.Context, discussion *models.Discussion, agents []*models.Agent, judge *models.Agent, transcript string) {
+	temperature := 0.2
+	opts := CallOptions{SystemPrompt: judgeSystemPrompt, Temperature: &temperature}
+
+	response, err := de.agentClient.CallAgentWithOptions(ctx, judge, buildJudgePrompt(discussion, agents, transcript), "", opts)
+	if err == nil && !response.Success {
+		err = errors.New(response.ErrorMessage)
+	}
+	if err == nil && strings.TrimSpace(response.Content) == "" {
+		err = errors.New("the verdict was empty")
+	}
+	if err != nil {
+		// A stopped debate gives up the verdict on purpose
+		if ctx.Err() == nil {
+			log.Printf("Judge %s failed for discussion %d: %v", judge.Name, discussion.ID, err)
+			de.insertSystemLog(discussion.ID, models.LogTypeSystem, "error",
+				fmt.Sprintf("%s could not give a verdict: %v", judge.Name, err),
+				models.JSONMap{"alert": "verdict", "judge_id": strconv.FormatInt(judge.ID, 10)})
+		}
+		return
+	}
+
+	verdict := parseVerdict(response.Content, discussion.AgentIDs)
+	verdict.JudgeID = judge.ID
+	if err := de.db.SaveDiscussionVerdict(discussion.ID, verdict); err != nil {
+		log.Printf("Failed to save verdict of discussion %d: %v", discussion.ID, err)
+		return
+	}
+	discussion.Verdict = verdict
+	de.touch(discussion.ID)
+	de.broadcast(discussion.ID, verdict)
+}
+
+// parseVerdict reads the first JSON object of a judge's reply. The winner
+// must be one of agentIDs and scores of other agents are dropped; scores are
+// rounded and clamped to the verdict range. A reply without a readable
+// object becomes the reasoning of a verdict without scores.
+func parseVerdict(reply string, agentIDs []int64) *models.Verdict {
+	reply = strings.TrimSpace(reply)
+	var raw struct {
+		WinnerAgentID interface{} `json:"winner_agent_id"`
+		Scores        interface{} `json:"scores"`
+		Reasoning     string      `json:"reasoning"`
+	}
+	object := firstJSONObject(reply)
+	if object == "" || json.Unmarshal([]byte(object), &raw) != nil {
+		return &models.Verdict{Reasoning: reply}
+	}
+
+	inDebate := make(map[int64]bool, len(agentIDs))
+	for _, id := range agentIDs {
+		inDebate[id] = true
+	}
+
+	verdict := &models.Verdict{Reasoning: strings.TrimSpace(raw.Reasoning)}
+	if id, ok := verdictAgentID(raw.WinnerAgentID); ok && inDebate[id] {
+		verdict.WinnerAgentID = &id
+	}
+
+	scores := models.VerdictScores{}
+	addScore := func(agent, score interface{}) {
+		id, ok := verdictAgentID(agent)
+		value, isNumber := verdictNumber(score)
+		if !ok || !isNumber || !inDebate[id] {
+			return
+		}
+		scores[id] = int(math.Max(models.MinVerdictScore, math.Min(models.MaxVerdictScore, math.Round(value))))
+	}
+	switch s := raw.Scores.(type) {
+	case map[string]interface{}:
+		for agent, score := range s {
+			addScore(agent, score)
+		}
+	case []interface{}:
+		// [{"agent_id": 1, "score": 8}, ...]
+		for _, entry := range s {
+			if e, ok := entry.(map[string]interface{}); ok {
+				addScore(e["agent_id"], e["score"])
+			}
+		}
+	}
+	if len(scores) > 0 {
+		verdict.Scores = scores
+	}
+	return verdict
+}
+
+// verdictAgentID reads an agent ID given as a number or a numeric string
+func verdictAgentID(v interface{}) (int64, bool) {
+	n, ok := verdictNumber(v)
+	if !ok || n != math.Trunc(n) {
+		return 0, false
+	}
+	return int64(n), true
+}
+
+// verdictNumber reads a JSON number or a numeric string
+func verdictNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// firstJSONObject returns the first balanced {...} in text, skipping braces
+// inside JSON strings, or "" when there is none
+func firstJSONObject(text string) string {
+	start := strings.IndexByte(text, '{')
+	if start < 0 {
+		return ""
+	}
+	depth := 0
+	inString, escaped := false, false
+	for i := start; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return text[start : i+1]
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,209 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/models"
+)
+
+func TestParseVerdict(t *testing.T) {
+	id := func(v int64) *int64 { return &v }
+	agents := []int64{1, 2}
+	tests := []struct {
+		name  string
+		reply string
+		want  models.Verdict
+	}{
+		{
+			"plain object",
+			`{"winner_agent_id": 2, "scores": {"1": 6, "2": 8}, "reasoning": "Better evidence."}`,
+			models.Verdict{WinnerAgentID: id(2), Scores: models.VerdictScores{1: 6, 2: 8}, Reasoning: "Better evidence."},
+		},
+		{
+			"fenced with prose and braces in strings",
+			"Here is my verdict:\n```json\n{\"winner_agent_id\": \"1\", \"scores\": {\"1\": \"9\", \"2\": 7}, \"reasoning\": \"Used {braces} and \\\"quotes\\\".\"}\n```\nThanks {bye}",
+			models.Verdict{WinnerAgentID: id(1), Scores: models.VerdictScores{1: 9, 2: 7}, Reasoning: `Used {braces} and "quotes".`},
+		},
+		{
+			"scores as a list, rounded and clamped",
+			`{"winner_agent_id": 1, "scores": [{"agent_id": 1, "score": 12}, {"agent_id": "2", "score": 0.4}, {"agent_id": 3, "score": 5}], "reasoning": " Close. "}`,
+			models.Verdict{WinnerAgentID: id(1), Scores: models.VerdictScores{1: 10, 2: 1}, Reasoning: "Close."},
+		},
+		{
+			"winner and scores outside the debate",
+			`{"winner_agent_id": 7, "scores": {"7": 9, "x": 3, "1": "high"}, "reasoning": "Agent 7 won."}`,
+			models.Verdict{Reasoning: "Agent 7 won."},
+		},
+		{
+			"fractional winner",
+			`{"winner_agent_id": 1.5, "scores": {"1.5": 4}}`,
+			models.Verdict{},
+		},
+		{
+			"no object",
+			"  I think agent 2 won, clearly.  ",
+			models.Verdict{Reasoning: "I think agent 2 won, clearly."},
+		},
+		{
+			"unbalanced object",
+			`Verdict: {"winner_agent_id": 2, "scores": {"2": 8}`,
+			models.Verdict{Reasoning: `Verdict: {"winner_agent_id": 2, "scores": {"2": 8}`},
+		},
+		{
+			"invalid JSON",
+			`{winner: 2}`,
+			models.Verdict{Reasoning: `{winner: 2}`},
+		},
+	}
+	for _, tt := range tests {
+		if got := parseVerdict(tt.reply, agents); !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("%s: parseVerdict = %+v, want %+v", tt.name, *got, tt.want)
+		}
+	}
+}
+
+// newJudgeProvider answers the judge's call with reply once release is
+// closed and returns the bodies of the calls it got
+func newJudgeProvider(t *testing.T, status int, reply string, release <-chan struct{}) (*httptest.Server, func() []string) {
+	t.Helper()
+	var (
+		mu      sync.Mutex
+		prompts []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("judge got a body that is not JSON: %v", err)
+		}
+		mu.Lock()
+		prompts = append(prompts, systemMessage(body)+"\n"+userMessage(body))
+		mu.Unlock()
+		if status != http.StatusOK {
+			http.Error(w, `{"error":{"message":"judge unavailable"}}`, status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":%q},"finish_reason":"stop"}]}`, reply)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), prompts...)
+	}
+}
+
+// runJudgedDebate runs a one-round debate of Alice and Bob judged by judge,
+// returning it once it ended with the events it broadcast after release was
+// closed
+func runJudgedDebate(t *testing.T, de *DebateEngine, alice, bob, judge *models.Agent, release chan struct{}) (*models.Discussion, []Event) {
+	t.Helper()
+	settings := models.DiscussionSettings{SummaryBackend: models.SummaryBackendExtractive}
+	stances := []models.AgentStance{{AgentID: alice.ID, Stance: models.StancePro}}
+	discussion, err := de.RunDebate(context.Background(), "Tabs or spaces", []int64{alice.ID, bob.ID}, stances, nil, &judge.ID, 1, "en", models.DefaultMaxCharLimit, settings)
+	if err != nil {
+		t.Fatalf("RunDebate: %v", err)
+	}
+	events, err := de.Subscribe(discussion.ID)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer de.Unsubscribe(discussion.ID, events)
+	close(release)
+
+	var got []Event
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case e := <-events:
+			got = append(got, e)
+			if d, ok := e.Data.(models.Discussion); ok && !d.InProgress() {
+				ended, err := de.db.GetDiscussion(discussion.ID)
+				if err != nil {
+					t.Fatalf("GetDiscussion: %v", err)
+				}
+				return ended, got
+			}
+		case <-timeout:
+			t.Fatal("the debate did not end")
+		}
+	}
+}
+
+func TestJudgeVerdict(t *testing.T) {
+	de := newTestEngine(t)
+	server, _ := newTestProvider(t, "Spaces keep alignment.")
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+	bob := insertTestAgent(t, de, "Bob", server.URL)
+	release := make(chan struct{})
+	reply := fmt.Sprintf(`{"winner_agent_id": %d, "scores": {"%d": 8, "%d": 5}, "reasoning": "Alice was clearer."}`, alice.ID, alice.ID, bob.ID)
+	judgeServer, prompts := newJudgeProvider(t, http.StatusOK, reply, release)
+	judge := insertTestAgent(t, de, "Judge", judgeServer.URL)
+
+	d, events := runJudgedDebate(t, de, alice, bob, judge, release)
+	if d.Status != "completed" {
+		t.Errorf("discussion ended %s, want completed", d.Status)
+	}
+	want := models.Verdict{JudgeID: judge.ID, WinnerAgentID: &alice.ID, Scores: models.VerdictScores{alice.ID: 8, bob.ID: 5}, Reasoning: "Alice was clearer."}
+	if d.Verdict == nil || !reflect.DeepEqual(*d.Verdict, want) {
+		t.Errorf("stored verdict = %+v, want %+v", d.Verdict, want)
+	}
+
+	var broadcast *models.Verdict
+	for _, e := range events {
+		if v, ok := e.Data.(models.Verdict); ok && e.Type == EventVerdict {
+			broadcast = &v
+		}
+	}
+	if broadcast == nil || !reflect.DeepEqual(*broadcast, want) {
+		t.Errorf("broadcast verdict = %+v, want %+v", broadcast, want)
+	}
+
+	p := prompts()
+	if len(p) != 1 {
+		t.Fatalf("judge was called %d times, want 1", len(p))
+	}
+	for _, s := range []string{judgeSystemPrompt, fmt.Sprintf("Agent ID %d: Alice (assigned stance: pro)", alice.ID), fmt.Sprintf("Agent ID %d: Bob\n", bob.ID), "Spaces keep alignment."} {
+		if !strings.Contains(p[0], s) {
+			t.Errorf("judge prompt lacks %q:\n%s", s, p[0])
+		}
+	}
+}
+
+func TestJudgeFailure(t *testing.T) {
+	de := newTestEngine(t)
+	server, _ := newTestProvider(t, "Spaces keep alignment.")
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+	bob := insertTestAgent(t, de, "Bob", server.URL)
+	release := make(chan struct{})
+	judgeServer, _ := newJudgeProvider(t, http.StatusBadRequest, "", release)
+	judge := insertTestAgent(t, de, "Judge", judgeServer.URL)
+
+	d, _ := runJudgedDebate(t, de, alice, bob, judge, release)
+	if d.Status != "completed" || d.Verdict != nil {
+		t.Errorf("discussion ended %s with verdict %+v, want completed without one", d.Status, d.Verdict)
+	}
+	logs, _ := de.db.GetDiscussionLogs(d.ID)
+	alerts := 0
+	for _, l := range logs {
+		if l.LogType == models.LogTypeSystem && l.Metadata["alert"] == "verdict" {
+			alerts++
+			if l.Metadata["judge_id"] != fmt.Sprint(judge.ID) || !strings.HasPrefix(l.Content, "Judge could not give a verdict") {
+				t.Errorf("verdict alert = %q %v", l.Content, l.Metadata)
+			}
+		}
+	}
+	if alerts != 1 {
+		t.Errorf("got %d verdict alerts, want 1", alerts)
+	}
+}
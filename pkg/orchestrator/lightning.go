@@ -0,0 +1,76 @@
+package orchestrator
+
+import (
+	"context"
+	"court-table-ai/pkg/models"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// lightningRound gives every agent, in speaking order, one closing statement
+// of at most the lightning character limit. The statements are recorded with
+// phase closing_statement under the last completed round and kept apart in
+// debateContext, so the summary can put them up front. Agents keep their
+// stances and personas. moderated reports whether closing remarks follow,
+// for the token budget.
+func (de *DebateEngine) lightningRound(ctx context.Context, discussion *models.Discussion, agents []*models.Agent, moderated bool, debateContext *turnContext) {
+	limit := discussion.Settings.LightningLimit()
+	closing := lightningDiscussion(discussion, limit)
+	round := max(discussion.CompletedRounds, 1)
+	contextStr := debateContext.String()
+	log.Printf("Starting lightning round for discussion %d (%d characters)", discussion.ID, limit)
+
+	for i, agent := range agents {
+		var pacing time.Duration
+		if i > 0 {
+			pacing = de.pace(ctx, discussion.ID, time.Duration(discussion.Settings.TurnDelaySeconds)*time.Second)
+		}
+		if !de.waitIfPaused(ctx, discussion.ID) {
+			return
+		}
+		allowance, ok := de.turnAllowance(closing, remainingCalls(discussion.Settings, len(agents), discussion.MaxRounds, discussion.MaxRounds+1, i, moderated))
+		if !ok {
+			de.stopForBudget(discussion, round)
+			return
+		}
+		prompt := de.withScratchpad(discussion, agent.ID, buildClosingStatementPrompt(discussion, agent.ID, limit))
+		out := de.takeTurn(ctx, closing, agent, round, models.PhaseClosingStatement, prompt, contextStr, allowance, pacing, runNow)
+		if out.aborted {
+			return
+		}
+		if out.answered {
+			debateContext.addClosing(agent.Name, agent.ID, out.accepted)
+		}
+	}
+}
+
+// lightningDiscussion returns a copy of discussion limited to limit
+// characters per reply. A minimum length above the limit is dropped from the
+// acceptance rules, so a short statement is not rejected for being short.
+func lightningDiscussion(discussion *models.Discussion, limit int) *models.Discussion {
+	closing := *discussion
+	closing.MaxCharLimit = limit
+	if rules := discussion.Settings.Acceptance; rules != nil && rules.MinChars > limit {
+		relaxed := *rules
+		relaxed.MinChars = 0
+		closing.Settings.Acceptance = &relaxed
+	}
+	return &closing
+}
+
+// buildClosingStatementPrompt asks an agent for its closing statement
+func buildClosingStatementPrompt(discussion *models.Discussion, agentID int64, limit int) string {
+	var prompt strings.Builder
+
+	prompt.WriteString(fmt.Sprintf("The debate about %s is over. This is the lightning round: one closing statement per agent.\n\n", promptTopic(discussion.Topic)))
+	prompt.WriteString(fmt.Sprintf("Language of discussion: %s\n", discussion.LanguageName()))
+	prompt.WriteString(fmt.Sprintf("Maximum response length: %d characters\n\n", limit))
+	prompt.WriteString(stanceGuidance(discussion.StanceOf(agentID)))
+	prompt.WriteString("Summarize your final position in 2-3 sentences. Do not introduce new arguments or address other agents.\n")
+	prompt.WriteString(fmt.Sprintf("- DO NOT EXCEED %d CHARACTERS\n", limit))
+	prompt.WriteString(fmt.Sprintf("- RESPOND ONLY IN %s\n", strings.ToUpper(discussion.LanguageName())))
+
+	return prompt.String()
+}
@@ -0,0 +1,115 @@
+package orchestrator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+func TestLightningRound(t *testing.T) {
+	de := newTestEngine(t)
+	server, bodies := newBodyProvider(t, "Spaces, on balance.")
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+	bob := insertTestAgent(t, de, "Bob", server.URL)
+	bob.SystemPrompt = "You are a skeptical economist."
+	if err := de.db.UpdateAgent(bob); err != nil {
+		t.Fatalf("UpdateAgent: %v", err)
+	}
+	moderator := insertTestAgent(t, de, "Moderator", server.URL)
+
+	settings := models.DiscussionSettings{LightningRound: true, LightningCharLimit: 120, SummaryBackend: models.SummaryBackendExtractive}
+	stances := []models.AgentStance{{AgentID: bob.ID, Stance: models.StanceCon}}
+	discussion, err := de.RunDebate(context.Background(), "Tabs or spaces", []int64{alice.ID, bob.ID}, stances, &moderator.ID, nil, 2, "en", models.DefaultMaxCharLimit, settings)
+	if err != nil {
+		t.Fatalf("RunDebate: %v", err)
+	}
+	var ended *models.Discussion
+	waitUntil(t, "the debate to end", func() bool {
+		ended, err = de.db.GetDiscussion(discussion.ID)
+		return err == nil && !ended.InProgress()
+	})
+	if ended.Status != "completed" {
+		t.Fatalf("status = %s, want completed", ended.Status)
+	}
+
+	logs, err := de.db.GetDiscussionLogs(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	statements := map[int64]int{}
+	var phases []string
+	for _, l := range logs {
+		if phase := l.Metadata["phase"] + l.Metadata["moderator_phase"]; phase != "" {
+			phases = append(phases, phase)
+		}
+		if l.Metadata["phase"] == models.PhaseClosingStatement {
+			statements[l.AgentID]++
+			if l.IsModerator || l.Metadata["round"] != "2" {
+				t.Errorf("closing statement %+v, want an agent turn under round 2", l)
+			}
+		}
+	}
+	// Exactly one statement per agent, before the moderator's closing remarks
+	if len(statements) != 2 || statements[alice.ID] != 1 || statements[bob.ID] != 1 {
+		t.Errorf("closing statements per agent = %v, want one each", statements)
+	}
+	if got := strings.Join(phases, ","); !strings.HasSuffix(got, "closing_statement,closing_statement,closing") {
+		t.Errorf("phases = %s, want the statements just before the closing remarks", got)
+	}
+
+	// The statement prompts carry the reduced limit, the agent's stance and
+	// persona
+	var prompts []map[string]interface{}
+	for _, body := range bodies() {
+		if strings.Contains(userMessage(body), "lightning round") {
+			prompts = append(prompts, body)
+		}
+	}
+	if len(prompts) != 2 {
+		t.Fatalf("sent %d lightning prompts, want 2", len(prompts))
+	}
+	for _, body := range prompts {
+		prompt := userMessage(body)
+		if !strings.Contains(prompt, "Maximum response length: 120 characters") || !strings.Contains(prompt, "DO NOT EXCEED 120 CHARACTERS") || strings.Contains(prompt, "1000") {
+			t.Errorf("lightning prompt = %q, want the 120 character limit", prompt)
+		}
+	}
+	if bobs := userMessage(prompts[1]); !strings.Contains(bobs, "You argue AGAINST the topic") {
+		t.Errorf("Bob's lightning prompt = %q, want his stance", bobs)
+	}
+	if system := systemMessage(prompts[1]); !strings.HasPrefix(system, bob.SystemPrompt) {
+		t.Errorf("Bob's lightning system prompt = %q, want his persona", system)
+	}
+
+	// The statements lead the summary
+	if !strings.Contains(ended.FinalSummary, "Closing statements:\n- Alice: Spaces, on balance.\n- Bob: Spaces, on balance.") {
+		t.Errorf("summary = %q, want the closing statements up front", ended.FinalSummary)
+	}
+}
+
+func TestLightningRoundIsOptIn(t *testing.T) {
+	de := newTestEngine(t)
+	server, bodies := newBodyProvider(t, "Spaces.")
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+
+	d := runToEnd(t, de, []*models.Agent{alice}, 1, models.DiscussionSettings{})
+	for _, body := range bodies() {
+		if strings.Contains(userMessage(body), "lightning round") {
+			t.Errorf("discussion %d without a lightning round asked for a closing statement", d.ID)
+		}
+	}
+}
+
+func TestLightningDiscussion(t *testing.T) {
+	d := &models.Discussion{MaxCharLimit: 1000, Settings: models.DiscussionSettings{Acceptance: &models.AcceptanceRules{MinChars: 400}}}
+	closing := lightningDiscussion(d, 300)
+	if closing.MaxCharLimit != 300 || closing.Settings.Acceptance.MinChars != 0 {
+		t.Errorf("closing discussion = %d chars with %+v, want 300 without the minimum", closing.MaxCharLimit, closing.Settings.Acceptance)
+	}
+	// The debate's own rules are left alone
+	if d.MaxCharLimit != 1000 || d.Settings.Acceptance.MinChars != 400 {
+		t.Errorf("original discussion changed to %d chars with %+v", d.MaxCharLimit, d.Settings.Acceptance)
+	}
+}
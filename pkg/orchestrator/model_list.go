@@ -0,0 +1,160 @@
+package orchestrator
+
+import (
+	"context"
+	"court-table-ai/pkg/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// modelListTimeout bounds a model list request
+const modelListTimeout = 10 * time.Second
+
+// ErrModelListUnsupported is returned by ListModels for providers that have
+// no model list endpoint
+var ErrModelListUnsupported = errors.New("provider cannot list its models")
+
+// ListModels returns the models the agent's provider offers, sorted by ID:
+// Ollama's /api/tags, the OpenAI-compatible and Anthropic /v1/models and
+// Gemini's /v1beta/models, the endpoints Ping checks. Gemini models that
+// cannot generate content are left out. Azure and custom agents return
+// ErrModelListUnsupported.
+func (ac *AgentClient) ListModels(ctx context.Context, agent *models.Agent) ([]models.ProviderModel, error) {
+	endpoint, err := modelListEndpoint(agent)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, modelListTimeout)
+	defer cancel()
+	data, err := ac.getModelList(ctx, agent, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("model list %w", err)
+	}
+
+	list, err := parseModelList(agent.EffectiveProviderType(), data)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(list, func(a, b models.ProviderModel) int { return strings.Compare(a.ID, b.ID) })
+	return list, nil
+}
+
+// modelListEndpoint is the URL listing the models of the agent's provider
+func modelListEndpoint(agent *models.Agent) (string, error) {
+	switch agent.EffectiveProviderType() {
+	case models.ProviderOllama:
+		return agent.ProviderURL + "/api/tags", nil
+	case models.ProviderOpenAI, models.ProviderAnthropic:
+		if strings.Contains(agent.ProviderURL, "/v1") {
+			return agent.ProviderURL + "/models", nil
+		}
+		return agent.ProviderURL + "/v1/models", nil
+	case models.ProviderGoogle:
+		if strings.Contains(agent.ProviderURL, "generativelanguage.googleapis.com") {
+			return agent.ProviderURL + "/models", nil
+		}
+		return agent.ProviderURL + "/v1beta/models", nil
+	case models.ProviderAzure:
+		return "", fmt.Errorf("%w: Azure agents call a deployment, whose name is listed under Deployments in Azure AI Foundry", ErrModelListUnsupported)
+	default:
+		return "", fmt.Errorf("%w: custom providers have no standard model list, use POST /api/agents/probe to detect the provider type", ErrModelListUnsupported)
+	}
+}
+
+// getModelList fetches a model list endpoint with the agent's credentials
+func (ac *AgentClient) getModelList(ctx context.Context, agent *models.Agent, endpoint string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if agent.EffectiveProviderType() == models.ProviderAnthropic {
+		// setAuthHeaders only adds the version along with a token
+		req.Header.Set("anthropic-version", "2023-06-01")
+	}
+	ac.setAuthHeaders(req, agent)
+
+	resp, err := ac.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("returned status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	return data, nil
+}
+
+// parseModelList reads a model list response of providerType
+func parseModelList(providerType string, data []byte) ([]models.ProviderModel, error) {
+	list := []models.ProviderModel{}
+	switch providerType {
+	case models.ProviderOllama:
+		var tags struct {
+			Models []struct {
+				Name string `json:"name"`
+			} `json:"models"`
+		}
+		if err := json.Unmarshal(data, &tags); err != nil || tags.Models == nil {
+			return nil, errors.New("model list is not an Ollama tag list")
+		}
+		for _, m := range tags.Models {
+			list = append(list, models.ProviderModel{ID: m.Name, Name: m.Name})
+		}
+
+	case models.ProviderGoogle:
+		var resp struct {
+			Models []struct {
+				Name        string   `json:"name"`
+				DisplayName string   `json:"displayName"`
+				Methods     []string `json:"supportedGenerationMethods"`
+			} `json:"models"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil || resp.Models == nil {
+			return nil, errors.New("model list is not a Gemini model list")
+		}
+		for _, m := range resp.Models {
+			if m.Methods != nil && !slices.Contains(m.Methods, "generateContent") {
+				continue
+			}
+			list = append(list, providerModel(strings.TrimPrefix(m.Name, "models/"), m.DisplayName))
+		}
+
+	default:
+		// OpenAI-compatible and Anthropic lists; only Anthropic has display names
+		var resp struct {
+			Data []struct {
+				ID          string `json:"id"`
+				DisplayName string `json:"display_name"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil || resp.Data == nil {
+			return nil, errors.New("model list is not an OpenAI-style model list")
+		}
+		for _, m := range resp.Data {
+			list = append(list, providerModel(m.ID, m.DisplayName))
+		}
+	}
+	return list, nil
+}
+
+// providerModel names a listed model by its ID when it has no display name
+func providerModel(id, name string) models.ProviderModel {
+	if name == "" {
+		name = id
+	}
+	return models.ProviderModel{ID: id, Name: name}
+}
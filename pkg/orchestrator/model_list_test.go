@@ -0,0 +1,144 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+func TestListModels(t *testing.T) {
+	tests := []struct {
+		name       string
+		agent      *models.Agent
+		body       string
+		wantURL    string
+		wantHeader [2]string
+		want       []models.ProviderModel
+	}{
+		{
+			name:    "ollama",
+			agent:   &models.Agent{ProviderType: models.ProviderOllama, ProviderURL: "http://localhost:11434"},
+			body:    `{"models":[{"name":"qwen2:7b"},{"name":"llama3:latest"}]}`,
+			wantURL: "http://localhost:11434/api/tags",
+			want:    []models.ProviderModel{{ID: "llama3:latest", Name: "llama3:latest"}, {ID: "qwen2:7b", Name: "qwen2:7b"}},
+		},
+		{
+			name:       "openai",
+			agent:      &models.Agent{ProviderType: models.ProviderOpenAI, ProviderURL: "https://api.openai.com/v1", APIToken: "sk-test"},
+			body:       `{"object":"list","data":[{"id":"gpt-4o","object":"model"},{"id":"gpt-4o-mini","object":"model"}]}`,
+			wantURL:    "https://api.openai.com/v1/models",
+			wantHeader: [2]string{"Authorization", "Bearer sk-test"},
+			want:       []models.ProviderModel{{ID: "gpt-4o", Name: "gpt-4o"}, {ID: "gpt-4o-mini", Name: "gpt-4o-mini"}},
+		},
+		{
+			name:    "openai-compatible without /v1",
+			agent:   &models.Agent{ProviderType: models.ProviderOpenAI, ProviderURL: "http://localhost:8000"},
+			body:    `{"data":[{"id":"mistral"}]}`,
+			wantURL: "http://localhost:8000/v1/models",
+			want:    []models.ProviderModel{{ID: "mistral", Name: "mistral"}},
+		},
+		{
+			name:       "anthropic",
+			agent:      &models.Agent{ProviderType: models.ProviderAnthropic, ProviderURL: "https://api.anthropic.com", APIToken: "sk-ant"},
+			body:       `{"data":[{"id":"claude-3-5-haiku-20241022","display_name":"Claude Haiku 3.5","type":"model"}],"has_more":false}`,
+			wantURL:    "https://api.anthropic.com/v1/models",
+			wantHeader: [2]string{"x-api-key", "sk-ant"},
+			want:       []models.ProviderModel{{ID: "claude-3-5-haiku-20241022", Name: "Claude Haiku 3.5"}},
+		},
+		{
+			name:       "google",
+			agent:      &models.Agent{ProviderType: models.ProviderGoogle, ProviderURL: "https://generativelanguage.googleapis.com/v1beta", APIToken: "g-key"},
+			body:       `{"models":[{"name":"models/gemini-1.5-flash","displayName":"Gemini 1.5 Flash","supportedGenerationMethods":["generateContent","countTokens"]},{"name":"models/text-embedding-004","displayName":"Text Embedding 004","supportedGenerationMethods":["embedContent"]}]}`,
+			wantURL:    "https://generativelanguage.googleapis.com/v1beta/models",
+			wantHeader: [2]string{"x-goog-api-key", "g-key"},
+			want:       []models.ProviderModel{{ID: "gemini-1.5-flash", Name: "Gemini 1.5 Flash"}},
+		},
+		{
+			name:    "gemini-compatible proxy",
+			agent:   &models.Agent{ProviderType: models.ProviderGoogle, ProviderURL: "http://localhost:9000"},
+			body:    `{"models":[{"name":"models/gemini-pro"}]}`,
+			wantURL: "http://localhost:9000/v1beta/models",
+			want:    []models.ProviderModel{{ID: "gemini-pro", Name: "gemini-pro"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ac, sent := recordingClient(http.StatusOK, tt.body)
+			list, err := ac.ListModels(context.Background(), tt.agent)
+			if err != nil {
+				t.Fatalf("ListModels: %v", err)
+			}
+			if !reflect.DeepEqual(list, tt.want) {
+				t.Errorf("ListModels = %+v, want %+v", list, tt.want)
+			}
+			requests := sent()
+			if len(requests) != 1 || requests[0].Method != http.MethodGet || requests[0].URL.String() != tt.wantURL {
+				t.Fatalf("requests = %v, want one GET %s", requests, tt.wantURL)
+			}
+			if name := tt.wantHeader[0]; name != "" && requests[0].Header.Get(name) != tt.wantHeader[1] {
+				t.Errorf("%s = %q, want %q", name, requests[0].Header.Get(name), tt.wantHeader[1])
+			}
+		})
+	}
+}
+
+func TestListModelsAnthropicVersionWithoutToken(t *testing.T) {
+	ac, sent := recordingClient(http.StatusOK, `{"data":[]}`)
+	agent := &models.Agent{ProviderType: models.ProviderAnthropic, ProviderURL: "http://localhost:4000"}
+	list, err := ac.ListModels(context.Background(), agent)
+	if err != nil || list == nil || len(list) != 0 {
+		t.Fatalf("ListModels = %v, %v, want an empty list", list, err)
+	}
+	if v := sent()[0].Header.Get("anthropic-version"); v == "" {
+		t.Error("anthropic-version header missing on a tokenless request")
+	}
+}
+
+func TestListModelsUnsupported(t *testing.T) {
+	ac, sent := recordingClient(http.StatusOK, `{}`)
+	for _, agent := range []*models.Agent{
+		{ProviderType: models.ProviderAzure, ProviderURL: "https://example.openai.azure.com"},
+		{ProviderType: models.ProviderCustom, ProviderURL: "http://localhost:5000/generate"},
+	} {
+		if _, err := ac.ListModels(context.Background(), agent); !errors.Is(err, ErrModelListUnsupported) {
+			t.Errorf("ListModels(%s) = %v, want ErrModelListUnsupported", agent.ProviderType, err)
+		}
+	}
+	if n := len(sent()); n != 0 {
+		t.Errorf("%d requests sent for providers without a model list", n)
+	}
+}
+
+func TestListModelsErrors(t *testing.T) {
+	agent := &models.Agent{ProviderType: models.ProviderOpenAI, ProviderURL: "http://localhost:8000"}
+
+	ac, _ := recordingClient(http.StatusUnauthorized, `{"error":"bad key"}`)
+	if _, err := ac.ListModels(context.Background(), agent); err == nil || errors.Is(err, ErrModelListUnsupported) {
+		t.Errorf("ListModels on 401 = %v, want a provider error", err)
+	}
+
+	// An Ollama tag list is not an OpenAI model list
+	ac, _ = recordingClient(http.StatusOK, `{"models":[{"name":"llama3"}]}`)
+	if _, err := ac.ListModels(context.Background(), agent); err == nil {
+		t.Error("ListModels accepted a response of the wrong shape")
+	}
+}
+
+func TestPingUsesModelList(t *testing.T) {
+	ac, sent := recordingClient(http.StatusOK, `{"models":[]}`)
+	if err := ac.Ping(context.Background(), ollamaAgent()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	if requests := sent(); len(requests) != 1 || requests[0].URL.Path != "/api/tags" {
+		t.Errorf("requests = %v, want one to /api/tags", requests)
+	}
+
+	ac, _ = recordingClient(http.StatusInternalServerError, ``)
+	if err := ac.Ping(context.Background(), ollamaAgent()); err == nil {
+		t.Error("Ping succeeded on a 500")
+	}
+}
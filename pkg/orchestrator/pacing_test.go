@@ -0,0 +1,134 @@
+package orchestrator
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/models"
+)
+
+func TestPacingDelaysTurnsAndRounds(t *testing.T) {
+	de := newTestEngine(t)
+	clock := newFakeClock(de)
+	var mu sync.Mutex
+	var slept []time.Duration
+	de.sleep = func(ctx context.Context, d time.Duration) error {
+		mu.Lock()
+		slept = append(slept, d)
+		mu.Unlock()
+		clock.Advance(d)
+		return nil
+	}
+
+	server, _ := newTestProvider(t, "Spaces, always.")
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+	bob := insertTestAgent(t, de, "Bob", server.URL)
+	settings := models.DiscussionSettings{SummaryBackend: models.SummaryBackendExtractive, TurnDelaySeconds: 3, RoundDelaySeconds: 7}
+	discussion, err := de.RunDebate(context.Background(), "Tabs or spaces", []int64{alice.ID, bob.ID}, nil, nil, nil, 2, "en", models.DefaultMaxCharLimit, settings)
+	if err != nil {
+		t.Fatalf("RunDebate: %v", err)
+	}
+	waitUntil(t, "the debate to end", func() bool {
+		d, err := de.db.GetDiscussion(discussion.ID)
+		return err == nil && !d.InProgress()
+	})
+
+	mu.Lock()
+	got := append([]time.Duration(nil), slept...)
+	mu.Unlock()
+	want := []time.Duration{3 * time.Second, 7 * time.Second, 3 * time.Second}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pacing sleeps = %v, want %v", got, want)
+	}
+
+	logs, err := de.db.GetDiscussionLogs(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	var pacing []string
+	for _, l := range logs {
+		if l.LogType != models.LogTypeResponse {
+			continue
+		}
+		pacing = append(pacing, l.Metadata["pacing_ms"])
+		// The fake clock jumped by the delay; the call itself took real time
+		if l.ResponseTime >= 3000 {
+			t.Errorf("log %d response time %d ms includes the pacing delay", l.Sequence, l.ResponseTime)
+		}
+	}
+	if want := []string{"", "3000", "7000", "3000"}; !reflect.DeepEqual(pacing, want) {
+		t.Errorf("pacing_ms per response = %q, want %q", pacing, want)
+	}
+}
+
+func TestRoundDelayDefaultsToTurnDelay(t *testing.T) {
+	de := newTestEngine(t)
+	clock := newFakeClock(de)
+	var mu sync.Mutex
+	var slept []time.Duration
+	de.sleep = func(ctx context.Context, d time.Duration) error {
+		mu.Lock()
+		slept = append(slept, d)
+		mu.Unlock()
+		clock.Advance(d)
+		return nil
+	}
+
+	server, _ := newTestProvider(t, "Spaces, always.")
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+	settings := models.DiscussionSettings{SummaryBackend: models.SummaryBackendExtractive, TurnDelaySeconds: 2}
+	discussion, err := de.RunDebate(context.Background(), "Tabs or spaces", []int64{alice.ID}, nil, nil, nil, 3, "en", models.DefaultMaxCharLimit, settings)
+	if err != nil {
+		t.Fatalf("RunDebate: %v", err)
+	}
+	waitUntil(t, "the debate to end", func() bool {
+		d, err := de.db.GetDiscussion(discussion.ID)
+		return err == nil && !d.InProgress()
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []time.Duration{2 * time.Second, 2 * time.Second}; !reflect.DeepEqual(slept, want) {
+		t.Errorf("pacing sleeps = %v, want %v", slept, want)
+	}
+}
+
+func TestStopInterruptsPacing(t *testing.T) {
+	de := newTestEngine(t)
+	server, calls := newTestProvider(t, "Spaces, always.")
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+	bob := insertTestAgent(t, de, "Bob", server.URL)
+	settings := models.DiscussionSettings{SummaryBackend: models.SummaryBackendExtractive, TurnDelaySeconds: models.MaxPacingDelaySeconds}
+	discussion, err := de.RunDebate(context.Background(), "Tabs or spaces", []int64{alice.ID, bob.ID}, nil, nil, nil, 1, "en", models.DefaultMaxCharLimit, settings)
+	if err != nil {
+		t.Fatalf("RunDebate: %v", err)
+	}
+	endedBeforeCleanup(t, de, discussion.ID)
+
+	waitUntil(t, "the debate to show as pacing", func() bool {
+		for _, rd := range de.RunningDebates() {
+			if rd.DiscussionID == discussion.ID && rd.State == models.DebateStatePacing {
+				return true
+			}
+		}
+		return false
+	})
+
+	stopped := time.Now()
+	if err := de.StopDiscussion(discussion.ID); err != nil {
+		t.Fatalf("StopDiscussion: %v", err)
+	}
+	waitUntil(t, "the debate to end", func() bool {
+		d, err := de.db.GetDiscussion(discussion.ID)
+		return err == nil && !d.InProgress()
+	})
+	if waited := time.Since(stopped); waited > 2*time.Second {
+		t.Errorf("stop took %v, want it to cut the %ds delay short", waited, models.MaxPacingDelaySeconds)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("provider called %d times, want only the turn before the delay", calls.Load())
+	}
+}
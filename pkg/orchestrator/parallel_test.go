@@ -0,0 +1,132 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/models"
+)
+
+// runParallel runs a parallel debate between agents and returns it and its
+// agent entries once it ended
+func runParallel(t *testing.T, de *DebateEngine, agents []*models.Agent, rounds int) (*models.Discussion, []*models.DiscussionLog) {
+	t.Helper()
+	var ids []int64
+	for _, a := range agents {
+		ids = append(ids, a.ID)
+	}
+	settings := models.DiscussionSettings{SummaryBackend: models.SummaryBackendExtractive, RoundMode: models.RoundModeParallel}
+	discussion, err := de.RunDebate(context.Background(), "Tabs or spaces", ids, nil, nil, nil, rounds, "en", models.DefaultMaxCharLimit, settings)
+	if err != nil {
+		t.Fatalf("RunDebate: %v", err)
+	}
+	var d *models.Discussion
+	waitUntil(t, "the debate to end", func() bool {
+		d, err = de.db.GetDiscussion(discussion.ID)
+		return err == nil && !d.InProgress()
+	})
+	logs, err := de.db.GetDiscussionLogs(d.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	var turns []*models.DiscussionLog
+	for _, l := range logs {
+		if l.LogType == models.LogTypeResponse && !l.IsModerator {
+			turns = append(turns, l)
+		}
+	}
+	return d, turns
+}
+
+func TestParallelRoundCallsAgentsAtOnce(t *testing.T) {
+	de := newTestEngine(t)
+	// Each call waits until every agent was called, so a sequential round
+	// would never get past the first one
+	var arrived atomic.Int64
+	var together atomic.Bool
+	together.Store(true)
+	provider := func(status int, delay time.Duration, reply string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			arrived.Add(1)
+			deadline := time.Now().Add(2 * time.Second)
+			for arrived.Load() < 3 {
+				if time.Now().After(deadline) {
+					together.Store(false)
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+			time.Sleep(delay)
+			if status != http.StatusOK {
+				http.Error(w, `{"error":{"message":"overloaded"}}`, status)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":%q},"finish_reason":"stop"}]}`, reply)
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	// Alice speaks first but answers last, Bob fails
+	alice := insertTestAgent(t, de, "Alice", provider(http.StatusOK, 100*time.Millisecond, "Spaces.").URL)
+	bob := insertTestAgent(t, de, "Bob", provider(http.StatusBadRequest, 0, "").URL)
+	carol := insertTestAgent(t, de, "Carol", provider(http.StatusOK, 0, "Tabs.").URL)
+
+	d, turns := runParallel(t, de, []*models.Agent{alice, bob, carol}, 1)
+	if !together.Load() {
+		t.Error("the agents of the round were not called at the same time")
+	}
+	if d.Status != "completed" {
+		t.Errorf("discussion ended %s (%s), want completed", d.Status, d.ErrorMessage)
+	}
+	want := []struct {
+		agentID int64
+		status  string
+	}{{alice.ID, "success"}, {bob.ID, "error"}, {carol.ID, "success"}}
+	if len(turns) != len(want) {
+		t.Fatalf("got %d turns, want %d", len(turns), len(want))
+	}
+	for i, l := range turns {
+		if l.AgentID != want[i].agentID || l.Status != want[i].status {
+			t.Errorf("turn %d = agent %d %s, want agent %d %s", i+1, l.AgentID, l.Status, want[i].agentID, want[i].status)
+		}
+		if l.Metadata["round_mode"] != models.RoundModeParallel {
+			t.Errorf("turn %d round_mode = %q", i+1, l.Metadata["round_mode"])
+		}
+	}
+}
+
+func TestParallelRoundContext(t *testing.T) {
+	de := newTestEngine(t)
+	aliceServer, aliceBodies := newSequenceProvider(t, "Spaces keep alignment.", "Alice again.")
+	bobServer, bobBodies := newSequenceProvider(t, "Tabs save bytes.", "Bob again.")
+	alice := insertTestAgent(t, de, "Alice", aliceServer.URL)
+	bob := insertTestAgent(t, de, "Bob", bobServer.URL)
+
+	d, turns := runParallel(t, de, []*models.Agent{alice, bob}, 2)
+	if d.Status != "completed" || len(turns) != 4 {
+		t.Fatalf("discussion ended %s with %d turns, want completed with 4", d.Status, len(turns))
+	}
+
+	// Round 1 is answered blind; round 2 sees all of round 1
+	for name, bodies := range map[string][]map[string]interface{}{"Alice": aliceBodies(), "Bob": bobBodies()} {
+		if len(bodies) != 2 {
+			t.Fatalf("%s was called %d times, want 2", name, len(bodies))
+		}
+		first, second := allMessages(bodies[0]), allMessages(bodies[1])
+		for _, reply := range []string{"Spaces keep alignment.", "Tabs save bytes."} {
+			if strings.Contains(first, reply) {
+				t.Errorf("%s's round 1 prompt has %q", name, reply)
+			}
+			if !strings.Contains(second, reply) {
+				t.Errorf("%s's round 2 prompt lacks %q", name, reply)
+			}
+		}
+	}
+}
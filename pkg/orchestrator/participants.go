@@ -0,0 +1,68 @@
+package orchestrator
+
+import "court-table-ai/pkg/models"
+
+// Participants summarizes each debating agent of a discussion from its
+// transcript and the reader ratings of its turns, in the discussion's agent
+// order. The moderator and the judge are not included. The discussion API and
+// the JSON export both report it.
+func Participants(discussion *models.Discussion, logs []*models.DiscussionLog, ratings []*models.LogRating) []models.ParticipantSummary {
+	summaries := make([]models.ParticipantSummary, len(discussion.AgentIDs))
+	byAgent := make(map[int64]*models.ParticipantSummary, len(discussion.AgentIDs))
+	for i, id := range discussion.AgentIDs {
+		summaries[i] = models.ParticipantSummary{AgentID: id, Stance: discussion.StanceOf(id)}
+		byAgent[id] = &summaries[i]
+	}
+
+	latency := make(map[int64]int)
+	for _, l := range logs {
+		p := byAgent[l.AgentID]
+		if p == nil || l.IsModerator || l.LogType != models.LogTypeResponse {
+			continue
+		}
+		p.TotalTokens += l.PromptTokens + l.CompletionTokens
+
+		switch l.Status {
+		case "success":
+			p.Turns++
+			latency[l.AgentID] += l.ResponseTime
+			closing := l.Metadata["phase"] == models.PhaseClosingStatement
+			// A closing statement stays the final position once given
+			if closing || !p.ClosingStatement {
+				p.FinalPosition = l.Content
+				p.FinalPositionLogID = l.ID
+				p.ClosingStatement = closing
+			}
+		case "rejected", "skipped":
+			// Rejections are counted by the compliance report
+		default:
+			p.Failures++
+		}
+	}
+
+	for i := range summaries {
+		p := &summaries[i]
+		if p.Turns > 0 {
+			p.AvgResponseTimeMs = latency[p.AgentID] / p.Turns
+		}
+		if v := discussion.Verdict; v != nil {
+			if score, ok := v.Scores[p.AgentID]; ok {
+				p.JudgeScore = &score
+			}
+			p.Winner = v.IsWinner(p.AgentID)
+		}
+	}
+	votes := make(map[int64][]*models.LogRating)
+	for _, r := range ratings {
+		votes[r.AgentID] = append(votes[r.AgentID], r)
+	}
+	for i := range summaries {
+		summaries[i].Votes = models.CountRatings(votes[summaries[i].AgentID])
+	}
+	for _, c := range ComplianceReport(logs) {
+		if p := byAgent[c.AgentID]; p != nil {
+			p.Compliance = &c
+		}
+	}
+	return summaries
+}
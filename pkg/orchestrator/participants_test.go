@@ -0,0 +1,172 @@
+package orchestrator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+// participantsFixture is a two-round debate between agents 1 and 2, moderated
+// by agent 9, with a closing round for agent 1 only
+func participantsFixture() (*models.Discussion, []*models.DiscussionLog) {
+	winner := int64(2)
+	discussion := &models.Discussion{
+		ID:       7,
+		AgentIDs: models.JSONSlice[int64]{1, 2, 3},
+		Stances:  []models.AgentStance{{AgentID: 1, Stance: models.StancePro}, {AgentID: 2, Stance: models.StanceCon}},
+		Verdict:  &models.Verdict{JudgeID: 9, WinnerAgentID: &winner, Scores: models.VerdictScores{1: 6, 2: 8}},
+	}
+	turn := func(id, agent int64, status, content string, responseTime, tokens int, metadata models.JSONMap) *models.DiscussionLog {
+		return &models.DiscussionLog{
+			ID: id, DiscussionID: 7, AgentID: agent, LogType: models.LogTypeResponse, Status: status,
+			Content: content, ResponseTime: responseTime, PromptTokens: tokens, CompletionTokens: tokens, Metadata: metadata,
+		}
+	}
+	logs := []*models.DiscussionLog{
+		{ID: 1, DiscussionID: 7, AgentID: 9, IsModerator: true, LogType: models.LogTypeResponse, Status: "success", Content: "Opening"},
+		turn(2, 1, "success", "Tabs are flexible.", 1000, 10, models.JSONMap{"raw_chars": "18"}),
+		turn(3, 2, "success", "Spaces are consistent.", 3000, 20, models.JSONMap{"raw_chars": "22"}),
+		turn(4, 1, "timeout", "", 30000, 0, nil),
+		turn(5, 2, "rejected", "Off topic.", 500, 5, models.JSONMap{"raw_chars": "10", "rejection_reason": "off topic"}),
+		turn(6, 2, "success", "Spaces render the same everywhere.", 2000, 20, models.JSONMap{"raw_chars": "34"}),
+		{ID: 7, DiscussionID: 7, AgentID: models.SystemAgentID, LogType: models.LogTypeSkip, Status: "skipped", Content: "Agent 3 skipped this turn"},
+		turn(8, 1, "success", "Tabs, finally.", 2000, 10, models.JSONMap{"raw_chars": "14", "phase": models.PhaseClosingStatement}),
+		turn(9, 1, "success", "A late reply after closing.", 1000, 10, models.JSONMap{"raw_chars": "27"}),
+		{ID: 10, DiscussionID: 7, AgentID: 9, IsModerator: true, LogType: models.LogTypeResponse, Status: "success", Content: "Summary"},
+	}
+	return discussion, logs
+}
+
+func TestParticipants(t *testing.T) {
+	discussion, logs := participantsFixture()
+	ratings := []*models.LogRating{
+		{LogID: 2, AgentID: 1, Rating: 1},
+		{LogID: 2, AgentID: 1, Rater: "ann", Rating: -1},
+		{LogID: 3, AgentID: 2, Rating: 1},
+		{LogID: 6, AgentID: 2, Rating: 1},
+		{LogID: 6, AgentID: 2, Rater: "ann", Rating: 0},
+		{LogID: 1, AgentID: 9, Rating: 1}, // the moderator has no summary
+	}
+
+	got := Participants(discussion, logs, ratings)
+	if len(got) != 3 {
+		t.Fatalf("got %d participants, want 3 in agent order", len(got))
+	}
+
+	one, two, three := got[0], got[1], got[2]
+	if one.AgentID != 1 || two.AgentID != 2 || three.AgentID != 3 {
+		t.Fatalf("participants in order %d, %d, %d, want 1, 2, 3", one.AgentID, two.AgentID, three.AgentID)
+	}
+
+	if one.Stance != models.StancePro || two.Stance != models.StanceCon || three.Stance != "" {
+		t.Errorf("stances = %q, %q, %q", one.Stance, two.Stance, three.Stance)
+	}
+	if one.FinalPosition != "Tabs, finally." || one.FinalPositionLogID != 8 || !one.ClosingStatement {
+		t.Errorf("agent 1 final position = %q (log %d, closing %v), want the closing statement", one.FinalPosition, one.FinalPositionLogID, one.ClosingStatement)
+	}
+	if two.FinalPosition != "Spaces render the same everywhere." || two.FinalPositionLogID != 6 || two.ClosingStatement {
+		t.Errorf("agent 2 final position = %q (log %d, closing %v), want its last reply", two.FinalPosition, two.FinalPositionLogID, two.ClosingStatement)
+	}
+	if one.Turns != 3 || one.Failures != 1 || two.Turns != 2 || two.Failures != 0 {
+		t.Errorf("turns/failures = %d/%d and %d/%d, want 3/1 and 2/0", one.Turns, one.Failures, two.Turns, two.Failures)
+	}
+	if one.TotalTokens != 60 || two.TotalTokens != 90 {
+		t.Errorf("total tokens = %d and %d, want 60 and 90", one.TotalTokens, two.TotalTokens)
+	}
+	if one.AvgResponseTimeMs != 1333 || two.AvgResponseTimeMs != 2500 {
+		t.Errorf("average response times = %d and %d, want 1333 and 2500", one.AvgResponseTimeMs, two.AvgResponseTimeMs)
+	}
+	if one.JudgeScore == nil || *one.JudgeScore != 6 || two.JudgeScore == nil || *two.JudgeScore != 8 || three.JudgeScore != nil {
+		t.Errorf("judge scores = %v, %v, %v", one.JudgeScore, two.JudgeScore, three.JudgeScore)
+	}
+	if one.Winner || !two.Winner || three.Winner {
+		t.Errorf("winners = %v, %v, %v, want only agent 2", one.Winner, two.Winner, three.Winner)
+	}
+	if two.Compliance == nil || two.Compliance.Rejections != 1 || three.Compliance != nil {
+		t.Errorf("compliance = %+v and %+v", two.Compliance, three.Compliance)
+	}
+
+	if want := (models.RatingCounts{Up: 1, Down: 1}); one.Votes != want {
+		t.Errorf("agent 1 votes = %+v, want %+v", one.Votes, want)
+	}
+	if want := (models.RatingCounts{Up: 2, Neutral: 1}); two.Votes != want {
+		t.Errorf("agent 2 votes = %+v, want %+v", two.Votes, want)
+	}
+	if three.Votes != (models.RatingCounts{}) || three.Turns != 0 || three.FinalPosition != "" {
+		t.Errorf("agent 3 without turns = %+v", three)
+	}
+}
+
+func TestParticipantsWithoutVerdictOrRatings(t *testing.T) {
+	discussion, logs := participantsFixture()
+	discussion.Verdict = nil
+
+	for _, p := range Participants(discussion, logs, nil) {
+		if p.JudgeScore != nil || p.Winner {
+			t.Errorf("agent %d has a judge score or win without a verdict", p.AgentID)
+		}
+		if p.Votes != (models.RatingCounts{}) {
+			t.Errorf("agent %d has votes %+v without ratings", p.AgentID, p.Votes)
+		}
+	}
+}
+
+func TestStancesInPromptsAndLogs(t *testing.T) {
+	de := newTestEngine(t)
+	aliceServer, aliceBodies := newSequenceProvider(t, "Spaces keep alignment.")
+	bobServer, bobBodies := newSequenceProvider(t, "Tabs save bytes.")
+	carolServer, carolBodies := newSequenceProvider(t, "Both have merits.")
+	alice := insertTestAgent(t, de, "Alice", aliceServer.URL)
+	bob := insertTestAgent(t, de, "Bob", bobServer.URL)
+	carol := insertTestAgent(t, de, "Carol", carolServer.URL)
+
+	stances := []models.AgentStance{{AgentID: alice.ID, Stance: models.StancePro}, {AgentID: bob.ID, Stance: models.StanceCon}}
+	settings := models.DiscussionSettings{SummaryBackend: models.SummaryBackendExtractive}
+	discussion, err := de.RunDebate(context.Background(), "Tabs or spaces", []int64{alice.ID, bob.ID, carol.ID}, stances, nil, nil, 2, "en", models.DefaultMaxCharLimit, settings)
+	if err != nil {
+		t.Fatalf("RunDebate: %v", err)
+	}
+	waitUntil(t, "the debate to end", func() bool {
+		d, err := de.db.GetDiscussion(discussion.ID)
+		return err == nil && !d.InProgress()
+	})
+
+	// Every round restates the assigned side
+	for name, tt := range map[string]struct {
+		bodies []map[string]interface{}
+		want   string
+	}{
+		"Alice": {aliceBodies(), "Your assigned stance: PRO\nYou argue FOR the topic."},
+		"Bob":   {bobBodies(), "Your assigned stance: CON\nYou argue AGAINST the topic."},
+		"Carol": {carolBodies(), ""},
+	} {
+		if len(tt.bodies) != 2 {
+			t.Fatalf("%s was called %d times, want 2", name, len(tt.bodies))
+		}
+		for i, body := range tt.bodies {
+			prompt := userMessage(body)
+			switch {
+			case tt.want == "" && strings.Contains(prompt, "assigned stance"):
+				t.Errorf("%s's round %d prompt assigns a stance:\n%s", name, i+1, prompt)
+			case !strings.Contains(prompt, tt.want):
+				t.Errorf("%s's round %d prompt lacks %q:\n%s", name, i+1, tt.want, prompt)
+			}
+		}
+	}
+
+	d, logs, err := de.GetDiscussionStatus(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionStatus: %v", err)
+	}
+	if len(d.Stances) != 2 || d.StanceOf(alice.ID) != models.StancePro || d.StanceOf(bob.ID) != models.StanceCon {
+		t.Errorf("stored stances = %+v", d.Stances)
+	}
+	want := map[int64]string{alice.ID: models.StancePro, bob.ID: models.StanceCon, carol.ID: ""}
+	for _, l := range logs {
+		if stance, ok := want[l.AgentID]; ok && l.Stance != stance {
+			t.Errorf("log of agent %d has stance %q, want %q", l.AgentID, l.Stance, stance)
+		}
+	}
+}
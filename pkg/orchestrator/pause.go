@@ -0,0 +1,122 @@
+package orchestrator
+
+import (
+	"context"
+	"court-table-ai/pkg/models"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrNotPausable is returned when pausing a discussion that is not running
+var ErrNotPausable = errors.New("only running discussions can be paused")
+
+// ErrDebateGone is returned when resuming a paused discussion whose debate
+// did not survive a restart
+var ErrDebateGone = errors.New("the debate of this paused discussion is no longer running; stop it instead")
+
+// PauseDiscussion pauses a running debate. The call in flight finishes and is
+// recorded; the debate then waits before its next agent or moderator call
+// until it is resumed or stopped.
+func (de *DebateEngine) PauseDiscussion(discussionID int64) (*models.Discussion, error) {
+	discussion, err := de.db.GetDiscussion(discussionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get discussion: %w", err)
+	}
+	if discussion.Status != "running" {
+		return nil, ErrNotPausable
+	}
+
+	de.runMu.Lock()
+	rd, ok := de.running[discussionID]
+	if ok && rd.resume == nil {
+		rd.resume = make(chan struct{})
+	}
+	de.runMu.Unlock()
+	if !ok {
+		return nil, ErrNotPausable
+	}
+
+	// The debate may have finished meanwhile and recorded its outcome
+	switched, err := de.db.SwitchDiscussionStatus(discussionID, "running", "paused")
+	if err != nil || !switched {
+		de.releasePause(discussionID)
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrNotPausable
+	}
+
+	log.Printf("Discussion %d paused", discussionID)
+	return de.announceStatus(discussionID)
+}
+
+// resumePaused lets a paused debate continue with its next call
+func (de *DebateEngine) resumePaused(discussionID int64) (*models.Discussion, error) {
+	de.runMu.Lock()
+	rd, ok := de.running[discussionID]
+	paused := ok && rd.resume != nil
+	de.runMu.Unlock()
+	if !paused {
+		return nil, ErrDebateGone
+	}
+
+	switched, err := de.db.SwitchDiscussionStatus(discussionID, "paused", "running")
+	if err != nil {
+		return nil, err
+	}
+	if !switched {
+		return nil, ErrNotResumable
+	}
+	de.releasePause(discussionID)
+
+	log.Printf("Discussion %d resumed", discussionID)
+	return de.announceStatus(discussionID)
+}
+
+// releasePause wakes a debate waiting in waitIfPaused
+func (de *DebateEngine) releasePause(discussionID int64) {
+	de.runMu.Lock()
+	defer de.runMu.Unlock()
+
+	if rd, ok := de.running[discussionID]; ok && rd.resume != nil {
+		close(rd.resume)
+		rd.resume = nil
+	}
+}
+
+// announceStatus broadcasts the stored discussion after a status change
+func (de *DebateEngine) announceStatus(discussionID int64) (*models.Discussion, error) {
+	discussion, err := de.db.GetDiscussion(discussionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get discussion: %w", err)
+	}
+	de.broadcast(discussion.ID, discussion)
+	return discussion, nil
+}
+
+// waitIfPaused blocks while the debate is paused. It reports whether the
+// debate may go on, which is false once ctx is cancelled.
+func (de *DebateEngine) waitIfPaused(ctx context.Context, discussionID int64) bool {
+	de.runMu.Lock()
+	var resume chan struct{}
+	if rd, ok := de.running[discussionID]; ok {
+		resume = rd.resume
+	}
+	de.runMu.Unlock()
+
+	if resume != nil {
+		log.Printf("Debate %d waiting while paused", discussionID)
+		start := time.Now()
+		select {
+		case <-ctx.Done():
+			return false
+		case <-resume:
+		}
+		log.Printf("Debate %d continuing after a %v pause", discussionID, time.Since(start).Round(time.Second))
+		// The pause is not a stall; restart the idle clock
+		de.touch(discussionID)
+	}
+	return ctx.Err() == nil
+}
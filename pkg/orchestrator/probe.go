@@ -0,0 +1,309 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"court-table-ai/pkg/models"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// probeTimeout bounds a whole provider probe; the checks run concurrently
+// within it
+const probeTimeout = 10 * time.Second
+
+// maxProbeBody caps how much of a probe response is read
+const maxProbeBody = 1 << 20
+
+// probeTimeoutFactor is how many times the measured latency a suggested
+// timeout allows, since generating a reply takes far longer than the
+// lightweight probe requests
+const probeTimeoutFactor = 20
+
+// probeRoot strips the API version or prefix from a provider URL, so every
+// provider's endpoints can be tried from it
+func probeRoot(providerURL string) string {
+	root := strings.TrimSuffix(strings.TrimSpace(providerURL), "/")
+	for _, suffix := range []string{"/v1beta", "/v1", "/api"} {
+		root = strings.TrimSuffix(root, suffix)
+	}
+	return root
+}
+
+// ProbeProvider detects what kind of provider answers at providerURL and
+// suggests an agent configuration for it, see AgentClient.Probe
+func (de *DebateEngine) ProbeProvider(ctx context.Context, providerURL, apiToken string) *models.ProbeResult {
+	timeouts, err := de.db.GetAgentTimeouts()
+	if err != nil {
+		log.Printf("Failed to read agent timeout settings, using defaults: %v", err)
+	}
+	return de.agentClient.Probe(ctx, providerURL, apiToken, timeouts)
+}
+
+// Probe tries the model listing endpoints of Ollama (/api/tags), Google
+// (/v1beta/models) and OpenAI-compatible servers (/v1/models), and sends
+// Anthropic's /v1/messages a deliberately invalid request that is rejected
+// without generating anything. The checks run concurrently within
+// probeTimeout. Ollama and others also serve the OpenAI API, so when several
+// checks match their own endpoints win over /v1/models.
+func (ac *AgentClient) Probe(ctx context.Context, providerURL, apiToken string, timeouts models.AgentTimeouts) *models.ProbeResult {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	start := time.Now()
+
+	root := probeRoot(providerURL)
+	token := strings.TrimSpace(apiToken)
+
+	// In order of precedence
+	probes := []func(ctx context.Context, root, token string) models.ProbeCheck{
+		ac.probeOllama, ac.probeAnthropic, ac.probeGoogle, ac.probeOpenAI,
+	}
+	checks := make([]models.ProbeCheck, len(probes))
+	var wg sync.WaitGroup
+	for i, probe := range probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checks[i] = probe(ctx, root, token)
+		}()
+	}
+	wg.Wait()
+
+	result := &models.ProbeResult{
+		Models: []string{},
+		Checks: checks,
+		Suggested: models.ProbeSuggestion{
+			ProviderType:   models.ProviderCustom,
+			ProviderURL:    strings.TrimSuffix(strings.TrimSpace(providerURL), "/"),
+			TimeoutSeconds: timeouts.DefaultSeconds,
+		},
+	}
+	for _, check := range checks {
+		if !check.Matched {
+			continue
+		}
+		result.Detected = true
+		result.LatencyMs = check.LatencyMs
+		if check.Models != nil {
+			result.Models = check.Models
+		}
+
+		suggested := &result.Suggested
+		suggested.ProviderType = check.ProviderType
+		suggested.ProviderURL = probeProviderURL(check.ProviderType, root)
+		if check.ProviderType == models.ProviderOpenAI {
+			suggested.EndpointStyle = models.EndpointStyleChatCompletions
+		}
+		if len(result.Models) > 0 {
+			suggested.ModelName = result.Models[0]
+		}
+		suggested.TimeoutSeconds = suggestTimeout(check.LatencyMs, timeouts)
+		break
+	}
+
+	result.DurationMs = time.Since(start).Milliseconds()
+	return result
+}
+
+// probeProviderURL is the provider URL agents of providerType are called
+// with, given the probed root
+func probeProviderURL(providerType, root string) string {
+	switch providerType {
+	case models.ProviderOpenAI, models.ProviderAnthropic:
+		return root + "/v1"
+	case models.ProviderGoogle:
+		// Only Google's own host takes the model path under the version
+		if strings.Contains(root, "generativelanguage.googleapis.com") {
+			return root + "/v1beta"
+		}
+	}
+	return root
+}
+
+// suggestTimeout allows probeTimeoutFactor times the measured latency,
+// rounded up to whole seconds, within the configured default and maximum
+func suggestTimeout(latencyMs int64, timeouts models.AgentTimeouts) int {
+	seconds := int(math.Ceil(float64(latencyMs)/1000)) * probeTimeoutFactor
+	if seconds < timeouts.DefaultSeconds {
+		seconds = timeouts.DefaultSeconds
+	}
+	if timeouts.MaxSeconds > 0 && seconds > timeouts.MaxSeconds {
+		seconds = timeouts.MaxSeconds
+	}
+	return seconds
+}
+
+// probeRequest sends one probe request and reads its response body
+func (ac *AgentClient) probeRequest(ctx context.Context, check *models.ProbeCheck, method string, body []byte, headers map[string]string) []byte {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, check.Endpoint, reader)
+	if err != nil {
+		check.Error = fmt.Sprintf("failed to create request: %v", err)
+		return nil
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	began := time.Now()
+	resp, err := ac.client.Do(req)
+	if err != nil {
+		check.LatencyMs = time.Since(began).Milliseconds()
+		check.Error = err.Error()
+		return nil
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeBody))
+	check.LatencyMs = time.Since(began).Milliseconds()
+	check.Status = resp.StatusCode
+	if err != nil {
+		check.Error = fmt.Sprintf("failed to read response: %v", err)
+		return nil
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		check.Error = fmt.Sprintf("authentication failed (status %d)", resp.StatusCode)
+	}
+	return data
+}
+
+// bearer returns the Authorization header for token, if any
+func bearer(token string) map[string]string {
+	if token == "" {
+		return nil
+	}
+	return map[string]string{"Authorization": "Bearer " + token}
+}
+
+// probeOllama lists models from Ollama's /api/tags
+func (ac *AgentClient) probeOllama(ctx context.Context, root, token string) models.ProbeCheck {
+	check := models.ProbeCheck{ProviderType: models.ProviderOllama, Endpoint: root + "/api/tags"}
+	data := ac.probeRequest(ctx, &check, http.MethodGet, nil, bearer(token))
+	if check.Status != http.StatusOK {
+		return check
+	}
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if json.Unmarshal(data, &tags) != nil || tags.Models == nil {
+		return check
+	}
+	check.Matched = true
+	check.Models = []string{}
+	for _, m := range tags.Models {
+		check.Models = append(check.Models, m.Name)
+	}
+	return check
+}
+
+// probeOpenAI lists models from the OpenAI-compatible /v1/models
+func (ac *AgentClient) probeOpenAI(ctx context.Context, root, token string) models.ProbeCheck {
+	check := models.ProbeCheck{ProviderType: models.ProviderOpenAI, Endpoint: root + "/v1/models"}
+	data := ac.probeRequest(ctx, &check, http.MethodGet, nil, bearer(token))
+	if check.Status != http.StatusOK {
+		return check
+	}
+
+	ids, ok := modelIDs(data)
+	check.Matched = ok
+	check.Models = ids
+	return check
+}
+
+// probeAnthropic sends /v1/messages a request without messages, which
+// Anthropic rejects with its own error object, then lists the models when a
+// token was given
+func (ac *AgentClient) probeAnthropic(ctx context.Context, root, token string) models.ProbeCheck {
+	check := models.ProbeCheck{ProviderType: models.ProviderAnthropic, Endpoint: root + "/v1/messages"}
+	headers := map[string]string{"anthropic-version": "2023-06-01"}
+	if token != "" {
+		headers["x-api-key"] = token
+	}
+	data := ac.probeRequest(ctx, &check, http.MethodPost, []byte(`{"model":"probe","max_tokens":1,"messages":[]}`), headers)
+	if check.Status == 0 {
+		return check
+	}
+
+	var reply struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(data, &reply) != nil || (reply.Type != "error" && reply.Type != "message") {
+		return check
+	}
+	check.Matched = true
+	if token == "" {
+		return check
+	}
+
+	list := models.ProbeCheck{Endpoint: root + "/v1/models"}
+	data = ac.probeRequest(ctx, &list, http.MethodGet, nil, headers)
+	if list.Status == http.StatusOK {
+		check.Models, _ = modelIDs(data)
+	} else if list.Error != "" {
+		check.Error = list.Error
+	}
+	return check
+}
+
+// probeGoogle lists the Gemini models that can generate content
+func (ac *AgentClient) probeGoogle(ctx context.Context, root, token string) models.ProbeCheck {
+	check := models.ProbeCheck{ProviderType: models.ProviderGoogle, Endpoint: root + "/v1beta/models"}
+	var headers map[string]string
+	if token != "" {
+		headers = map[string]string{"x-goog-api-key": token}
+	}
+	data := ac.probeRequest(ctx, &check, http.MethodGet, nil, headers)
+	if check.Status != http.StatusOK {
+		return check
+	}
+
+	var list struct {
+		Models []struct {
+			Name    string   `json:"name"`
+			Methods []string `json:"supportedGenerationMethods"`
+		} `json:"models"`
+	}
+	if json.Unmarshal(data, &list) != nil || list.Models == nil {
+		return check
+	}
+	check.Matched = true
+	check.Models = []string{}
+	for _, m := range list.Models {
+		if m.Methods != nil && !slices.Contains(m.Methods, "generateContent") {
+			continue
+		}
+		check.Models = append(check.Models, strings.TrimPrefix(m.Name, "models/"))
+	}
+	return check
+}
+
+// modelIDs reads the model IDs of an OpenAI or Anthropic model list
+func modelIDs(data []byte) ([]string, bool) {
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if json.Unmarshal(data, &list) != nil || list.Data == nil {
+		return nil, false
+	}
+	ids := []string{}
+	for _, m := range list.Data {
+		ids = append(ids, m.ID)
+	}
+	return ids, true
+}
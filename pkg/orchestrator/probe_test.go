@@ -0,0 +1,171 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/models"
+)
+
+// newProbedServer serves the given bodies by method and path, with 400 for
+// Anthropic's messages endpoint and 200 otherwise, and answers anything else
+// with 404
+func newProbedServer(t *testing.T, routes map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		body, ok := routes[r.Method+" "+r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		status := http.StatusOK
+		// Anthropic rejects the probe's empty message list
+		if r.URL.Path == "/v1/messages" {
+			status = http.StatusBadRequest
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProbe(t *testing.T) {
+	const (
+		openAIModels = `{"object":"list","data":[{"id":"gpt-4o-mini"},{"id":"gpt-4o"}]}`
+		ollamaTags   = `{"models":[{"name":"llama3:8b"},{"name":"qwen2:7b"}]}`
+		anthropicErr = `{"type":"error","error":{"type":"invalid_request_error","message":"messages: at least one message is required"}}`
+		geminiModels = `{"models":[{"name":"models/embedding-001","supportedGenerationMethods":["embedContent"]},{"name":"models/gemini-1.5-flash","supportedGenerationMethods":["generateContent"]}]}`
+	)
+	tests := []struct {
+		name       string
+		routes     map[string]string
+		path       string
+		token      string
+		wantType   string
+		wantPath   string
+		wantStyle  string
+		wantModels []string
+	}{
+		{"openai", map[string]string{"GET /v1/models": openAIModels}, "/v1", "sk-test",
+			models.ProviderOpenAI, "/v1", models.EndpointStyleChatCompletions, []string{"gpt-4o-mini", "gpt-4o"}},
+		// Ollama also serves the OpenAI API, and its own endpoint wins
+		{"ollama", map[string]string{"GET /api/tags": ollamaTags, "GET /v1/models": openAIModels}, "", "",
+			models.ProviderOllama, "", "", []string{"llama3:8b", "qwen2:7b"}},
+		{"anthropic", map[string]string{"POST /v1/messages": anthropicErr, "GET /v1/models": `{"data":[{"id":"claude-3-5-haiku"}]}`}, "/v1/", "sk-ant",
+			models.ProviderAnthropic, "/v1", "", []string{"claude-3-5-haiku"}},
+		{"anthropic without a token", map[string]string{"POST /v1/messages": anthropicErr}, "", "",
+			models.ProviderAnthropic, "/v1", "", []string{}},
+		// Models that cannot generate are left out
+		{"google", map[string]string{"GET /v1beta/models": geminiModels}, "/v1beta", "key",
+			models.ProviderGoogle, "", "", []string{"gemini-1.5-flash"}},
+		{"unknown", map[string]string{"GET /": "<html>It works!</html>", "GET /v1/models": "<html>It works!</html>"}, "", "",
+			models.ProviderCustom, "", "", []string{}},
+	}
+	timeouts := models.AgentTimeouts{DefaultSeconds: 60, MaxSeconds: 300}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newProbedServer(t, tt.routes)
+			result := NewAgentClient(nil).Probe(context.Background(), server.URL+tt.path, tt.token, timeouts)
+
+			if result.Detected != (tt.wantType != models.ProviderCustom) || result.Suggested.ProviderType != tt.wantType {
+				t.Errorf("detected %v as %s, want %s", result.Detected, result.Suggested.ProviderType, tt.wantType)
+			}
+			if got := result.Suggested.ProviderURL; got != strings.TrimSuffix(server.URL+tt.wantPath, "/") {
+				t.Errorf("suggested URL = %s, want %s", got, server.URL+tt.wantPath)
+			}
+			if result.Suggested.EndpointStyle != tt.wantStyle {
+				t.Errorf("suggested style = %q, want %q", result.Suggested.EndpointStyle, tt.wantStyle)
+			}
+			if strings.Join(result.Models, ",") != strings.Join(tt.wantModels, ",") {
+				t.Errorf("models = %v, want %v", result.Models, tt.wantModels)
+			}
+			wantModel := ""
+			if len(tt.wantModels) > 0 {
+				wantModel = tt.wantModels[0]
+			}
+			if result.Suggested.ModelName != wantModel || result.Suggested.TimeoutSeconds != 60 {
+				t.Errorf("suggested %q with %ds, want %q with 60s", result.Suggested.ModelName, result.Suggested.TimeoutSeconds, wantModel)
+			}
+			if len(result.Checks) != 4 {
+				t.Errorf("ran %d checks, want 4", len(result.Checks))
+			}
+		})
+	}
+}
+
+func TestProbeReportsRejectedToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"message":"invalid api key"}}`, http.StatusUnauthorized)
+	}))
+	t.Cleanup(server.Close)
+
+	result := NewAgentClient(nil).Probe(context.Background(), server.URL, "sk-wrong", models.AgentTimeouts{DefaultSeconds: 60})
+	if result.Detected {
+		t.Errorf("probe detected %s behind a 401", result.Suggested.ProviderType)
+	}
+	for _, check := range result.Checks {
+		if check.Status != http.StatusUnauthorized || !strings.Contains(check.Error, "authentication failed") {
+			t.Errorf("check %s = %d %q, want the rejected token reported", check.Endpoint, check.Status, check.Error)
+		}
+	}
+}
+
+func TestProbeIsBounded(t *testing.T) {
+	hung := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-hung:
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(hung) })
+
+	// Checks run together, so the probe takes one timeout rather than four
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	result := NewAgentClient(nil).Probe(ctx, server.URL, "", models.AgentTimeouts{DefaultSeconds: 60})
+	if elapsed := time.Since(start); elapsed > 600*time.Millisecond {
+		t.Errorf("probe of a hanging server took %v", elapsed)
+	}
+	if result.Detected {
+		t.Error("probe detected a provider that never answered")
+	}
+	for _, check := range result.Checks {
+		if check.Status != 0 || check.Error == "" {
+			t.Errorf("check %s = %d %q, want it to fail without a response", check.Endpoint, check.Status, check.Error)
+		}
+	}
+}
+
+func TestSuggestTimeout(t *testing.T) {
+	timeouts := models.AgentTimeouts{DefaultSeconds: 60, MaxSeconds: 300}
+	for _, tt := range []struct {
+		latencyMs int64
+		want      int
+	}{
+		{0, 60},
+		{800, 60},
+		{3001, 80},
+		{10000, 200},
+		{60000, 300},
+	} {
+		if got := suggestTimeout(tt.latencyMs, timeouts); got != tt.want {
+			t.Errorf("suggestTimeout(%dms) = %d, want %d", tt.latencyMs, got, tt.want)
+		}
+	}
+	if got := suggestTimeout(60000, models.AgentTimeouts{DefaultSeconds: 60}); got != 1200 {
+		t.Errorf("suggestTimeout without a maximum = %d, want 1200", got)
+	}
+}
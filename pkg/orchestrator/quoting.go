@@ -0,0 +1,31 @@
+package orchestrator
+
+import (
+	"strconv"
+	"strings"
+)
+
+// promptTopic renders a discussion topic for a prompt: on one line and quoted
+// with its own quotes and backslashes escaped, so a topic cannot end the
+// quoting early or pose as further instructions on a new line
+func promptTopic(topic string) string {
+	return strconv.Quote(strings.Join(strings.Fields(topic), " "))
+}
+
+// fence wraps text quoted into a prompt in a Markdown code fence longer than
+// any run of backticks inside it, so the text cannot close the fence early
+func fence(text string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	marker := strings.Repeat("`", max(3, longest+1))
+	return marker + "\n" + strings.TrimRight(text, "\n") + "\n" + marker
+}
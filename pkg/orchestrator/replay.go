@@ -0,0 +1,148 @@
+package orchestrator
+
+import (
+	"court-table-ai/pkg/models"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Replay orderings: discussions created before sequence tracking have
+// sequence 0 on every entry and fall back to timestamp order
+const (
+	ReplayOrderingSequence  = "sequence"
+	ReplayOrderingTimestamp = "timestamp"
+)
+
+// ReplayDiscussion reconstructs a discussion as it stood once the entry at
+// position upto had been written: the transcript so far, the debate context
+// agents would have received next, and the round and phase reached. An upto
+// past the end, or negative, replays the whole transcript.
+func (de *DebateEngine) ReplayDiscussion(discussionID int64, upto int64) (*models.DiscussionReplay, error) {
+	discussion, logs, err := de.GetDiscussionStatus(discussionID)
+	if err != nil {
+		return nil, err
+	}
+
+	ordering := orderReplayLogs(logs)
+	total := int64(len(logs))
+	if upto < 0 || upto > total {
+		upto = total
+	}
+
+	// Sequences are contiguous per discussion, so the cut is positional in
+	// either ordering
+	shown := logs[:upto]
+
+	replay := &models.DiscussionReplay{
+		Logs:     shown,
+		Upto:     upto,
+		Total:    total,
+		Ordering: ordering,
+		Finished: upto == total && discussion.Status != "running",
+	}
+
+	state := *discussion
+	if upto < total {
+		state.Status = "running"
+		state.FinalSummary = ""
+		state.EndReason = ""
+	}
+	replay.Discussion = &state
+
+	agentNames := make(map[int64]string)
+	turns := make(map[int64]int)
+	debateContext := &turnContext{perTurnChars: discussion.Settings.PerTurnContextChars, guarded: discussion.Settings.InjectionGuard != nil}
+
+	for i, l := range shown {
+		last := i == len(shown)-1
+
+		switch {
+		case l.IsModerator && l.LogType == models.LogTypeResponse:
+			replay.Phase = l.Metadata["moderator_phase"]
+			if replay.Phase == "" {
+				replay.Phase = "moderator"
+			}
+			if last {
+				replay.ModeratorContext = l.Metadata["moderator_context"]
+			}
+
+		case l.LogType == models.LogTypeResponse && !l.IsSystem():
+			round := replayRound(l, turns, replay.Round)
+			if round > replay.Round {
+				replay.Round = round
+			}
+			replay.Phase = "agent"
+			if l.Metadata["phase"] == models.PhaseClosingStatement {
+				replay.Phase = models.PhaseClosingStatement
+			}
+
+			if l.Status != "success" {
+				continue
+			}
+			replay.CompletedTurns++
+
+			name, ok := agentNames[l.AgentID]
+			if !ok {
+				name = fmt.Sprintf("#%d", l.AgentID)
+				if agentName, err := de.db.GetAgentName(l.AgentID); err == nil {
+					name = agentName
+				}
+				agentNames[l.AgentID] = name
+			}
+
+			if l.Metadata["phase"] == models.PhaseClosingStatement {
+				debateContext.addClosing(name, l.AgentID, l.Content)
+				continue
+			}
+			debateContext.add(round, name, l.AgentID, l.Content)
+		}
+	}
+
+	replay.Context = debateContext.String()
+	return replay, nil
+}
+
+// orderReplayLogs sorts logs into transcript order and reports which ordering
+// was used. Sequence order is only trusted when every entry has one.
+func orderReplayLogs(logs []*models.DiscussionLog) string {
+	sequenced := true
+	for _, l := range logs {
+		if l.Sequence <= 0 {
+			sequenced = false
+			break
+		}
+	}
+
+	if sequenced {
+		sort.SliceStable(logs, func(i, j int) bool { return logs[i].Sequence < logs[j].Sequence })
+		return ReplayOrderingSequence
+	}
+
+	sort.SliceStable(logs, func(i, j int) bool {
+		if !logs[i].CreatedAt.Equal(logs[j].CreatedAt) {
+			return logs[i].CreatedAt.Before(logs[j].CreatedAt)
+		}
+		return logs[i].ID < logs[j].ID
+	})
+	return ReplayOrderingTimestamp
+}
+
+// replayRound returns the round of an agent entry. Older entries have no
+// round recorded, so it is inferred from how many turns the agent has taken;
+// retries stay in the round reached so far.
+func replayRound(l *models.DiscussionLog, turns map[int64]int, current int) int {
+	if round, err := strconv.Atoi(l.Metadata["round"]); err == nil && round > 0 {
+		return round
+	}
+
+	if l.Metadata["retry_of"] != "" {
+		if current < 1 {
+			return 1
+		}
+		return current
+	}
+
+	turns[l.AgentID]++
+	return turns[l.AgentID]
+}
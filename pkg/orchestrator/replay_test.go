@@ -0,0 +1,169 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/models"
+)
+
+// insertReplayLogs writes a moderated two-round debate between alice and bob
+func insertReplayLogs(t *testing.T, de *DebateEngine, discussionID int64, moderator, alice, bob *models.Agent) {
+	t.Helper()
+	entries := []*models.DiscussionLog{
+		{AgentID: moderator.ID, IsModerator: true, Content: "Welcome.", Metadata: models.JSONMap{"moderator_phase": "opening", "moderator_context": "Topic: Tabs or spaces"}},
+		{AgentID: alice.ID, Content: "Tabs.", Metadata: models.JSONMap{"round": "1"}},
+		{AgentID: bob.ID, Content: "Spaces.", Metadata: models.JSONMap{"round": "1"}},
+		{AgentID: moderator.ID, IsModerator: true, Content: "Round one is split.", Metadata: models.JSONMap{"moderator_phase": "interim", "moderator_context": "Round 1 - Agent Alice"}},
+		{AgentID: alice.ID, Content: "Still tabs.", Metadata: models.JSONMap{"round": "2"}},
+		{AgentID: bob.ID, Status: "error", Content: "timeout", Metadata: models.JSONMap{"round": "2"}},
+	}
+	for _, l := range entries {
+		l.DiscussionID = discussionID
+		l.LogType = models.LogTypeResponse
+		if l.Status == "" {
+			l.Status = "success"
+		}
+		if err := de.db.InsertDiscussionLog(l); err != nil {
+			t.Fatalf("InsertDiscussionLog: %v", err)
+		}
+	}
+}
+
+func TestReplayCutPoints(t *testing.T) {
+	de := newTestEngine(t)
+	moderator := insertTestAgent(t, de, "Judge", "http://127.0.0.1:1")
+	alice := insertTestAgent(t, de, "Alice", "http://127.0.0.1:1")
+	bob := insertTestAgent(t, de, "Bob", "http://127.0.0.1:1")
+	discussion := insertTestDiscussion(t, de, "completed", alice, bob)
+	insertReplayLogs(t, de, discussion.ID, moderator, alice, bob)
+
+	tests := []struct {
+		upto             int64
+		round            int
+		phase            string
+		turns            int
+		contextHas       []string
+		contextLacks     []string
+		moderatorContext string
+		finished         bool
+	}{
+		{upto: 0, round: 0, phase: "", turns: 0},
+		{upto: 1, round: 0, phase: "opening", turns: 0, moderatorContext: "Topic: Tabs or spaces"},
+		{upto: 2, round: 1, phase: "agent", turns: 1, contextHas: []string{"Round 1 - Agent Alice", "Tabs."}, contextLacks: []string{"Spaces."}},
+		{upto: 4, round: 1, phase: "interim", turns: 2, contextHas: []string{"Tabs.", "Spaces."}, moderatorContext: "Round 1 - Agent Alice"},
+		// A failed turn moves the round on but adds nothing to the context
+		{upto: 6, round: 2, phase: "agent", turns: 3, contextHas: []string{"Round 2 - Agent Alice", "Still tabs."}, contextLacks: []string{"timeout"}, finished: true},
+		{upto: 100, round: 2, phase: "agent", turns: 3, finished: true},
+	}
+	for _, tt := range tests {
+		replay, err := de.ReplayDiscussion(discussion.ID, tt.upto)
+		if err != nil {
+			t.Fatalf("ReplayDiscussion(%d): %v", tt.upto, err)
+		}
+		wantUpto := tt.upto
+		if wantUpto > 6 {
+			wantUpto = 6
+		}
+		if replay.Upto != wantUpto || int64(len(replay.Logs)) != wantUpto || replay.Total != 6 {
+			t.Errorf("upto %d: got upto %d with %d logs of %d", tt.upto, replay.Upto, len(replay.Logs), replay.Total)
+		}
+		if replay.Ordering != ReplayOrderingSequence {
+			t.Errorf("upto %d: ordering %q, want sequence", tt.upto, replay.Ordering)
+		}
+		if replay.Round != tt.round || replay.Phase != tt.phase || replay.CompletedTurns != tt.turns {
+			t.Errorf("upto %d: round %d phase %q turns %d, want %d %q %d", tt.upto, replay.Round, replay.Phase, replay.CompletedTurns, tt.round, tt.phase, tt.turns)
+		}
+		if replay.ModeratorContext != tt.moderatorContext {
+			t.Errorf("upto %d: moderator context %q, want %q", tt.upto, replay.ModeratorContext, tt.moderatorContext)
+		}
+		for _, s := range tt.contextHas {
+			if !strings.Contains(replay.Context, s) {
+				t.Errorf("upto %d: context lacks %q:\n%s", tt.upto, s, replay.Context)
+			}
+		}
+		for _, s := range tt.contextLacks {
+			if strings.Contains(replay.Context, s) {
+				t.Errorf("upto %d: context has %q:\n%s", tt.upto, s, replay.Context)
+			}
+		}
+		if replay.Finished != tt.finished {
+			t.Errorf("upto %d: finished %v, want %v", tt.upto, replay.Finished, tt.finished)
+		}
+		if wantStatus := map[bool]string{true: "completed", false: "running"}[tt.finished]; replay.Discussion.Status != wantStatus {
+			t.Errorf("upto %d: status %q, want %q", tt.upto, replay.Discussion.Status, wantStatus)
+		}
+	}
+}
+
+func TestOrderReplayLogsWithoutSequences(t *testing.T) {
+	// Entries from before sequence tracking, as read before migration 21
+	// numbered them
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	logs := []*models.DiscussionLog{
+		{ID: 4, Content: "Spaces again.", CreatedAt: base.Add(3 * time.Minute)},
+		{ID: 3, Content: "Tabs again.", CreatedAt: base.Add(time.Minute)},
+		{ID: 1, Content: "Tabs.", CreatedAt: base},
+		{ID: 2, Content: "Spaces.", CreatedAt: base.Add(time.Minute)},
+	}
+	if got := orderReplayLogs(logs); got != ReplayOrderingTimestamp {
+		t.Errorf("ordering %q, want timestamp", got)
+	}
+	var got []string
+	for _, l := range logs {
+		got = append(got, l.Content)
+	}
+	// Equal timestamps fall back to insertion order
+	if strings.Join(got, "|") != "Tabs.|Spaces.|Tabs again.|Spaces again." {
+		t.Errorf("logs = %v, want timestamp order", got)
+	}
+
+	// One unnumbered entry is enough to distrust the sequences
+	logs = []*models.DiscussionLog{{ID: 2, Sequence: 1, CreatedAt: base.Add(time.Minute)}, {ID: 1, CreatedAt: base}}
+	if got := orderReplayLogs(logs); got != ReplayOrderingTimestamp || logs[0].ID != 1 {
+		t.Errorf("partly sequenced logs ordered by %q starting with #%d", got, logs[0].ID)
+	}
+}
+
+func TestReplayInfersRoundsWithoutMetadata(t *testing.T) {
+	de := newTestEngine(t)
+	alice := insertTestAgent(t, de, "Alice", "http://127.0.0.1:1")
+	bob := insertTestAgent(t, de, "Bob", "http://127.0.0.1:1")
+	discussion := insertTestDiscussion(t, de, "completed", alice, bob)
+
+	// Older turns carry no round; a retry stays in the round reached so far
+	entries := []struct {
+		agent    *models.Agent
+		content  string
+		metadata models.JSONMap
+	}{
+		{alice, "Tabs.", nil},
+		{bob, "Spaces.", nil},
+		{alice, "Tabs again.", nil},
+		{alice, "Tabs, retried.", models.JSONMap{"retry_of": "3"}},
+	}
+	for _, e := range entries {
+		l := &models.DiscussionLog{DiscussionID: discussion.ID, AgentID: e.agent.ID, Content: e.content, Status: "success", LogType: models.LogTypeResponse, Metadata: e.metadata}
+		if err := de.db.InsertDiscussionLog(l); err != nil {
+			t.Fatalf("InsertDiscussionLog: %v", err)
+		}
+	}
+
+	for upto, wantRound := range map[int64]int{2: 1, 3: 2, 4: 2} {
+		replay, err := de.ReplayDiscussion(discussion.ID, upto)
+		if err != nil {
+			t.Fatalf("ReplayDiscussion(%d): %v", upto, err)
+		}
+		if replay.Round != wantRound {
+			t.Errorf("upto %d: round %d, want %d", upto, replay.Round, wantRound)
+		}
+	}
+	replay, err := de.ReplayDiscussion(discussion.ID, -1)
+	if err != nil {
+		t.Fatalf("ReplayDiscussion: %v", err)
+	}
+	if !strings.Contains(replay.Context, "Round 1 - Agent Bob (") || !strings.Contains(replay.Context, "Round 2 - Agent Alice (") || strings.Contains(replay.Context, "Round 3") {
+		t.Errorf("context:\n%s", replay.Context)
+	}
+}
@@ -0,0 +1,140 @@
+package orchestrator
+
+import (
+	"context"
+	"court-table-ai/pkg/models"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryableStatuses are the HTTP statuses worth another attempt. Other
+// failures, such as 400, 401 and 404, will not change on a retry.
+var retryableStatuses = map[int]bool{
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	529:                            true, // Anthropic: overloaded
+}
+
+// retryConfig returns the current agent retry settings
+func (ac *AgentClient) retryConfig() models.AgentRetryConfig {
+	if ac.db == nil {
+		return models.DefaultAgentRetryConfig()
+	}
+
+	cfg, err := ac.db.GetAgentRetryConfig()
+	if err != nil {
+		fmt.Printf("Failed to read agent retry settings, using defaults: %v\n", err)
+	}
+	return cfg
+}
+
+// traceTransport records the outcome of each HTTP request on the callTrace
+// in its context, so retries can be decided without every provider path
+// reporting its status code
+type traceTransport struct {
+	base http.RoundTripper
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	started := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if trace, ok := req.Context().Value(callTraceKey{}).(*callTrace); ok {
+		if err != nil {
+			trace.status = 0
+			trace.retryAfter = ""
+			trace.transportErr = true
+		} else {
+			trace.status = resp.StatusCode
+			trace.retryAfter = resp.Header.Get("Retry-After")
+			trace.transportErr = false
+		}
+		if record := trace.add(req, started, resp, err); record != nil && resp != nil {
+			resp.Body = &tracedBody{ReadCloser: resp.Body, record: record, started: started}
+		}
+	}
+	return resp, err
+}
+
+// resetAttempt clears the outcome of the previous attempt
+func (t *callTrace) resetAttempt() {
+	t.status = 0
+	t.retryAfter = ""
+	t.transportErr = false
+}
+
+// retryable reports whether the last request of the attempt failed in a way
+// another attempt may fix: a network error or a transient HTTP status. A
+// stream that broke off is not retried; its text was already sent.
+func (t *callTrace) retryable(ctx context.Context) bool {
+	if ctx.Err() != nil || t.streamed {
+		return false
+	}
+	if t.status == 0 {
+		return t.transportErr
+	}
+	return retryableStatuses[t.status]
+}
+
+// retryDelay returns how long to wait before retry n (1-based). A valid
+// Retry-After header wins; otherwise the delay backs off exponentially from
+// the base delay with jitter, capped at the maximum delay.
+func retryDelay(cfg models.AgentRetryConfig, n int, retryAfter string) time.Duration {
+	if d, ok := parseRetryAfter(retryAfter, time.Now()); ok {
+		return d
+	}
+
+	delay := time.Duration(cfg.BaseDelayMs) * time.Millisecond
+	max := time.Duration(cfg.MaxDelayMs) * time.Millisecond
+	for i := 1; i < n && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+
+	// Jitter keeps agents that failed together from retrying in step
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// waitForRetry sleeps for the delay unless the call would run out of time
+// first. It returns false when the retry should not be made.
+func waitForRetry(ctx context.Context, delay time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+		return false
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,203 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+)
+
+func TestRetryDelay(t *testing.T) {
+	cfg := models.AgentRetryConfig{MaxRetries: 5, BaseDelayMs: 100, MaxDelayMs: 500}
+	// Retry n waits between half and all of the backed off delay
+	for n, full := range map[int]time.Duration{1: 100, 2: 200, 3: 400, 4: 500, 10: 500} {
+		full *= time.Millisecond
+		for i := 0; i < 50; i++ {
+			if got := retryDelay(cfg, n, ""); got < full/2 || got > full {
+				t.Fatalf("retryDelay(retry %d) = %v, want between %v and %v", n, got, full/2, full)
+			}
+		}
+	}
+	// Retry-After wins over the backoff, even beyond the maximum delay
+	if got := retryDelay(cfg, 1, "3"); got != 3*time.Second {
+		t.Errorf("retryDelay with Retry-After 3 = %v, want 3s", got)
+	}
+	if got := retryDelay(cfg, 1, "soon"); got < 50*time.Millisecond || got > 100*time.Millisecond {
+		t.Errorf("retryDelay with an unreadable Retry-After = %v, want the backoff", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"0", 0, true},
+		{"7", 7 * time.Second, true},
+		{"-1", 0, false},
+		{"1.5", 0, false},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"tomorrow", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestWaitForRetry(t *testing.T) {
+	if !waitForRetry(context.Background(), time.Millisecond) {
+		t.Error("waitForRetry without a deadline = false")
+	}
+	// A retry that would start after the call timed out is not made
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	if waitForRetry(ctx, time.Minute) || time.Since(started) > 40*time.Millisecond {
+		t.Error("waitForRetry waited past the call deadline")
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if waitForRetry(cancelled, time.Minute) {
+		t.Error("waitForRetry after the call was cancelled = true")
+	}
+}
+
+// newFlakyProvider answers the first len(statuses) requests with those
+// statuses, setting retryAfter on them when given, and succeeds after. A
+// zero status drops the connection.
+func newFlakyProvider(t *testing.T, retryAfter string, statuses ...int) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		if n <= len(statuses) {
+			switch status := statuses[n-1]; status {
+			case 0:
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err == nil {
+					conn.Close()
+				}
+			default:
+				if retryAfter != "" {
+					w.Header().Set("Retry-After", retryAfter)
+				}
+				http.Error(w, `{"error":{"message":"try again"}}`, status)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Spaces."},"finish_reason":"stop"}]}`)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestCallAgentRetries(t *testing.T) {
+	tests := []struct {
+		name          string
+		statuses      []int
+		retryAfter    string
+		success       bool
+		wantCalls     int64
+		wantRetries   string
+		wantSucceeded string
+	}{
+		{"first attempt", nil, "", true, 1, "", ""},
+		{"rate limited once", []int{http.StatusTooManyRequests}, "0", true, 2, "1", "2"},
+		{"server errors", []int{http.StatusInternalServerError, http.StatusBadGateway}, "", true, 3, "2", "3"},
+		{"overloaded", []int{529}, "", true, 2, "1", "2"},
+		{"unavailable", []int{http.StatusServiceUnavailable}, "", true, 2, "1", "2"},
+		{"connection dropped", []int{0}, "", true, 2, "1", "2"},
+		{"retries run out", []int{503, 503, 503}, "", false, 3, "2", ""},
+		{"bad request", []int{http.StatusBadRequest}, "", false, 1, "", ""},
+		{"unauthorized", []int{http.StatusUnauthorized}, "", false, 1, "", ""},
+		{"not found", []int{http.StatusNotFound}, "", false, 1, "", ""},
+		{"transient then permanent", []int{503, http.StatusUnauthorized}, "", false, 2, "1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			de := newTestEngine(t)
+			if err := de.db.SetSettingJSON(database.SettingAgentRetries, models.AgentRetryConfig{MaxRetries: 2, BaseDelayMs: 1, MaxDelayMs: 2}); err != nil {
+				t.Fatalf("SetSettingJSON: %v", err)
+			}
+			server, calls := newFlakyProvider(t, tt.retryAfter, tt.statuses...)
+			agent := insertTestAgent(t, de, "Alice", server.URL)
+
+			response, _ := de.agentClient.CallAgent(context.Background(), agent, "Tabs or spaces?", "")
+			if response == nil || response.Success != tt.success {
+				t.Fatalf("response = %+v, want success %v", response, tt.success)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("provider got %d requests, want %d", got, tt.wantCalls)
+			}
+			if got := response.Metadata["retries"]; got != tt.wantRetries {
+				t.Errorf("retries = %q, want %q", got, tt.wantRetries)
+			}
+			if got := response.Metadata["succeeded_attempt"]; got != tt.wantSucceeded {
+				t.Errorf("succeeded_attempt = %q, want %q", got, tt.wantSucceeded)
+			}
+		})
+	}
+}
+
+func TestCallAgentRetriesStayWithinTimeout(t *testing.T) {
+	de := newTestEngine(t)
+	if err := de.db.SetSettingJSON(database.SettingAgentRetries, models.AgentRetryConfig{MaxRetries: 2, BaseDelayMs: 1, MaxDelayMs: 2}); err != nil {
+		t.Fatalf("SetSettingJSON: %v", err)
+	}
+	// Retry-After asks for longer than the agent's timeout allows
+	server, calls := newFlakyProvider(t, "3600", http.StatusTooManyRequests)
+	agent := insertTestAgent(t, de, "Alice", server.URL)
+
+	started := time.Now()
+	response, _ := de.agentClient.CallAgent(context.Background(), agent, "Tabs or spaces?", "")
+	if response == nil || response.Success || calls.Load() != 1 {
+		t.Errorf("response = %+v after %d requests, want one failed attempt", response, calls.Load())
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("call took %v, want it to give up instead of waiting", elapsed)
+	}
+}
+
+func TestRetriesAreLogged(t *testing.T) {
+	de := newTestEngine(t)
+	if err := de.db.SetSettingJSON(database.SettingAgentRetries, models.AgentRetryConfig{MaxRetries: 2, BaseDelayMs: 1, MaxDelayMs: 2}); err != nil {
+		t.Fatalf("SetSettingJSON: %v", err)
+	}
+	server, _ := newFlakyProvider(t, "", http.StatusServiceUnavailable)
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+
+	d := runToEnd(t, de, []*models.Agent{alice}, 2, models.DiscussionSettings{})
+	logs, err := de.db.GetDiscussionLogs(d.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	var turns []*models.DiscussionLog
+	for _, l := range logs {
+		if l.AgentID == alice.ID {
+			turns = append(turns, l)
+		}
+	}
+	if len(turns) != 2 {
+		t.Fatalf("got %d turns, want 2", len(turns))
+	}
+	if turns[0].Status != "success" || turns[0].Metadata["retries"] != "1" || turns[0].Metadata["succeeded_attempt"] != "2" {
+		t.Errorf("first turn = %s %v, want a success on the second attempt", turns[0].Status, turns[0].Metadata)
+	}
+	if _, retried := turns[1].Metadata["retries"]; retried {
+		t.Errorf("second turn metadata = %v, want no retries recorded", turns[1].Metadata)
+	}
+}
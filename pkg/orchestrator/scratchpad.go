@@ -0,0 +1,123 @@
+package orchestrator
+
+import (
+	"court-table-ai/pkg/models"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// Markers of the private notes block an agent may end its reply with.
+// Matching ignores case and spaces inside the brackets.
+const (
+	notesOpenMarker  = "[NOTES]"
+	notesCloseMarker = "[/NOTES]"
+)
+
+var (
+	notesOpen  = regexp.MustCompile(`(?i)\[\s*notes\s*\]`)
+	notesClose = regexp.MustCompile(`(?i)\[\s*/\s*notes\s*\]`)
+)
+
+// scratchpadPrompt puts the agent's latest notes above prompt and explains
+// how to write new ones
+func scratchpadPrompt(prompt, notes string) string {
+	var b strings.Builder
+	if notes != "" {
+		b.WriteString("Your private notes from your earlier turns (no one else can see them):\n")
+		b.WriteString(notes)
+		b.WriteString("\n\n")
+	}
+	b.WriteString(prompt)
+	b.WriteString("\nPrivate notes (optional):\n")
+	fmt.Fprintf(&b, "- You may end your reply with working notes for your later turns between %s and %s, ", notesOpenMarker, notesCloseMarker)
+	b.WriteString("such as arguments to answer or points to raise\n")
+	b.WriteString("- Only you will see them; they are removed from your reply and do not count toward its length\n")
+	b.WriteString("- New notes replace your previous ones, so repeat anything you still need\n")
+	return b.String()
+}
+
+// splitNotes separates the notes blocks from a reply. A block left open runs
+// to the end of the reply, and a closing marker without an opening one is
+// dropped. found reports whether any block was present, even an empty one.
+func splitNotes(content string) (public, notes string, found bool) {
+	var pub, blocks []string
+	rest := content
+	for {
+		open := notesOpen.FindStringIndex(rest)
+		if open == nil {
+			pub = append(pub, rest)
+			break
+		}
+		found = true
+		pub = append(pub, rest[:open[0]])
+		rest = rest[open[1]:]
+
+		end := len(rest)
+		next := len(rest)
+		if close := notesClose.FindStringIndex(rest); close != nil {
+			end, next = close[0], close[1]
+		}
+		// A second opening marker before the close starts a new block
+		if reopen := notesOpen.FindStringIndex(rest[:end]); reopen != nil {
+			end, next = reopen[0], reopen[0]
+		}
+		if block := strings.TrimSpace(rest[:end]); block != "" {
+			blocks = append(blocks, block)
+		}
+		rest = rest[next:]
+	}
+
+	public = notesClose.ReplaceAllString(strings.Join(pub, ""), "")
+	return strings.TrimSpace(public), strings.Join(blocks, "\n"), found
+}
+
+// scratchpadNotes returns an agent's latest notes in a discussion
+func (de *DebateEngine) scratchpadNotes(discussionID, agentID int64) string {
+	pad, err := de.db.GetAgentScratchpad(discussionID, agentID)
+	if err != nil {
+		log.Printf("Failed to read scratchpad of agent %d in discussion %d: %v", agentID, discussionID, err)
+		return ""
+	}
+	if pad == nil {
+		return ""
+	}
+	return pad.Notes
+}
+
+// withScratchpad adds the agent's notes and the notes instructions to a turn
+// prompt when the discussion uses scratchpads
+func (de *DebateEngine) withScratchpad(discussion *models.Discussion, agentID int64, prompt string) string {
+	if !discussion.Settings.Scratchpad {
+		return prompt
+	}
+	return scratchpadPrompt(prompt, de.scratchpadNotes(discussion.ID, agentID))
+}
+
+// keepNotes strips the notes from an agent's reply and stores them as the
+// agent's scratchpad, returning the public part. A reply without notes keeps
+// the previous ones. metadata receives the notes when the discussion reveals
+// them.
+func (de *DebateEngine) keepNotes(discussion *models.Discussion, agentID int64, round int, content string, metadata models.JSONMap) string {
+	if !discussion.Settings.Scratchpad {
+		return content
+	}
+	public, notes, found := splitNotes(content)
+	if !found {
+		return content
+	}
+	if notes == "" {
+		return public
+	}
+
+	notes = truncateResponse(notes, models.MaxScratchpadLength)
+	pad := &models.AgentScratchpad{DiscussionID: discussion.ID, AgentID: agentID, Notes: notes, Round: round}
+	if err := de.db.SaveAgentScratchpad(pad); err != nil {
+		log.Printf("Failed to save scratchpad of agent %d in discussion %d: %v", agentID, discussion.ID, err)
+	}
+	if discussion.Settings.RevealScratchpad {
+		metadata["scratchpad"] = notes
+	}
+	return public
+}
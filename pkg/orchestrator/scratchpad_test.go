@@ -0,0 +1,128 @@
+package orchestrator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+func TestSplitNotes(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		public  string
+		notes   string
+		found   bool
+	}{
+		{"no block", "Spaces, always.", "Spaces, always.", "", false},
+		{"closed block", "Spaces.\n[NOTES]\nAnswer Bob's width point\n[/NOTES]", "Spaces.", "Answer Bob's width point", true},
+		{"markers in any case and spacing", "Spaces. [ notes ]Raise diffs[ / Notes ] Done.", "Spaces.  Done.", "Raise diffs", true},
+		{"unclosed block runs to the end", "Spaces.\n[NOTES] Raise diffs\nand alignment", "Spaces.", "Raise diffs\nand alignment", true},
+		{"stray closing marker", "Spaces.[/NOTES] Always.", "Spaces. Always.", "", false},
+		{"empty block", "Spaces. [NOTES] [/NOTES]", "Spaces.", "", true},
+		{"several blocks", "[NOTES]one[/NOTES]Spaces.[NOTES]two[/NOTES]", "Spaces.", "one\ntwo", true},
+		{"reopened before closing", "Spaces. [NOTES]one [NOTES]two[/NOTES]", "Spaces.", "one\ntwo", true},
+		{"only notes", "[NOTES]keep quiet[/NOTES]", "", "keep quiet", true},
+	}
+	for _, tt := range tests {
+		public, notes, found := splitNotes(tt.content)
+		if public != tt.public || notes != tt.notes || found != tt.found {
+			t.Errorf("%s: splitNotes = %q, %q, %v; want %q, %q, %v", tt.name, public, notes, found, tt.public, tt.notes, tt.found)
+		}
+	}
+}
+
+func TestScratchpadIsPrivateAndCarriedForward(t *testing.T) {
+	for _, reveal := range []bool{false, true} {
+		de := newTestEngine(t)
+		notes := "Bob will say tabs save bytes; answer with diff noise"
+		aliceServer, aliceBodies := newSequenceProvider(t,
+			"Spaces keep alignment stable.\n[NOTES]\n"+notes+"\n[/NOTES]",
+			"Diffs stay readable with spaces.",
+		)
+		bobServer, bobBodies := newSequenceProvider(t, "Tabs save bytes and let readers choose.")
+		alice := insertTestAgent(t, de, "Alice", aliceServer.URL)
+		bob := insertTestAgent(t, de, "Bob", bobServer.URL)
+
+		settings := models.DiscussionSettings{SummaryBackend: models.SummaryBackendExtractive, Scratchpad: true, RevealScratchpad: reveal}
+		discussion, err := de.RunDebate(context.Background(), "Tabs or spaces", []int64{alice.ID, bob.ID}, nil, nil, nil, 3, "en", models.DefaultMaxCharLimit, settings)
+		if err != nil {
+			t.Fatalf("RunDebate: %v", err)
+		}
+		var d *models.Discussion
+		waitUntil(t, "the debate to end", func() bool {
+			d, err = de.db.GetDiscussion(discussion.ID)
+			return err == nil && !d.InProgress()
+		})
+
+		// Later prompts of Alice carry her notes even after a reply without any
+		bodies := aliceBodies()
+		if len(bodies) != 3 {
+			t.Fatalf("Alice was called %d times, want 3", len(bodies))
+		}
+		if strings.Contains(userMessage(bodies[0]), notes) {
+			t.Error("Alice's first prompt already has notes")
+		}
+		for i, body := range bodies {
+			prompt := userMessage(body)
+			if !strings.Contains(prompt, "[NOTES]") {
+				t.Errorf("Alice's prompt %d does not explain the notes block", i+1)
+			}
+			if i == 0 {
+				continue
+			}
+			if !strings.HasPrefix(prompt, "Your private notes from your earlier turns") || !strings.Contains(prompt, notes) {
+				t.Errorf("Alice's prompt %d does not start with her notes:\n%s", i+1, prompt)
+			}
+		}
+		for _, body := range bobBodies() {
+			if prompt := allMessages(body); strings.Contains(prompt, notes) {
+				t.Errorf("Bob's prompt has Alice's notes:\n%s", prompt)
+			}
+		}
+
+		logs, err := de.db.GetDiscussionLogs(d.ID)
+		if err != nil {
+			t.Fatalf("GetDiscussionLogs: %v", err)
+		}
+		for _, l := range logs {
+			if strings.Contains(l.Content, notes) || strings.Contains(l.Content, "NOTES") {
+				t.Errorf("log %d shows the notes: %q", l.ID, l.Content)
+			}
+			if l.AgentID == alice.ID && l.Metadata["round"] == "1" && (l.Metadata["scratchpad"] == notes) != reveal {
+				t.Errorf("reveal %v: round 1 metadata scratchpad = %q", reveal, l.Metadata["scratchpad"])
+			}
+		}
+		if strings.Contains(d.FinalSummary, notes) {
+			t.Errorf("the summary shows the notes: %q", d.FinalSummary)
+		}
+
+		pad, err := de.db.GetAgentScratchpad(d.ID, alice.ID)
+		if err != nil || pad == nil || pad.Notes != notes || pad.Round != 1 {
+			t.Errorf("Alice's scratchpad = %+v, %v; want her round 1 notes", pad, err)
+		}
+		if pad, err := de.db.GetAgentScratchpad(d.ID, bob.ID); err != nil || pad != nil {
+			t.Errorf("Bob's scratchpad = %+v, %v; want none", pad, err)
+		}
+	}
+}
+
+func TestScratchpadOff(t *testing.T) {
+	de := newTestEngine(t)
+	reply := "Spaces. [NOTES]kept in the reply[/NOTES]"
+	server, bodies := newSequenceProvider(t, reply)
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+
+	d := runToEnd(t, de, []*models.Agent{alice}, 2, models.DiscussionSettings{})
+	if prompt := userMessage(bodies()[0]); strings.Contains(prompt, "[NOTES]") {
+		t.Errorf("prompt explains notes without the scratchpad setting:\n%s", prompt)
+	}
+	logs, _ := de.db.GetDiscussionLogs(d.ID)
+	for _, l := range logs {
+		if l.AgentID == alice.ID && l.Content != reply {
+			t.Errorf("reply stored as %q, want it unchanged", l.Content)
+		}
+	}
+}
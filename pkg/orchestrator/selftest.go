@@ -0,0 +1,273 @@
+package orchestrator
+
+import (
+	"context"
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/version"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// selfTestReply is what the mock provider answers to every call, so the
+// summary stage can tell an AI summary from the heuristic fallback
+const selfTestReply = "Self-test reply from the mock provider."
+
+// selfTestTimeout bounds a whole self-test run
+const selfTestTimeout = 30 * time.Second
+
+// IsSelfTest reports whether a discussion belongs to a running self-test, so
+// side effects such as the transcript log can leave it out
+func (de *DebateEngine) IsSelfTest(discussionID int64) bool {
+	de.runMu.Lock()
+	defer de.runMu.Unlock()
+	return de.selfTests[discussionID]
+}
+
+// SelfTest runs a one-round debate between two agents answered by an
+// in-process mock of the OpenAI chat completions API. It goes through the
+// database, the engine, a temporary stream subscriber and the AI summary,
+// timing each stage, and purges the agents and the discussion afterwards.
+// Runs are serialized, and the agents get names unique to the run so they
+// never clash with the user's agents or with leftovers of a crashed run.
+func (de *DebateEngine) SelfTest(ctx context.Context) *models.SelfTestReport {
+	de.selfTestMu.Lock()
+	defer de.selfTestMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, selfTestTimeout)
+	defer cancel()
+
+	report := &models.SelfTestReport{Stages: []models.SelfTestStage{}}
+	start := time.Now()
+	stage := func(name string, run func() error) bool {
+		if report.FailedStage != "" {
+			return false
+		}
+		began := time.Now()
+		err := run()
+		result := models.SelfTestStage{Name: name, Passed: err == nil, DurationMs: time.Since(began).Milliseconds()}
+		if err != nil {
+			result.Error = err.Error()
+			report.FailedStage = name
+			report.Error = err.Error()
+		}
+		report.Stages = append(report.Stages, result)
+		return err == nil
+	}
+
+	mockURL, stopMock, mockErr := startSelfTestProvider()
+	if mockErr == nil {
+		defer stopMock()
+	}
+
+	var (
+		agents     []*models.Agent
+		discussion *models.Discussion
+		events     chan Event
+		received   []Event
+	)
+	stage(models.SelfTestStageDatabase, func() error {
+		if mockErr != nil {
+			return fmt.Errorf("failed to start the mock provider: %w", mockErr)
+		}
+
+		run := newRequestID()[:8]
+		for _, name := range []string{"A", "B"} {
+			agent := &models.Agent{
+				Name:           fmt.Sprintf("Self-test agent %s (run %s)", name, run),
+				ProviderType:   models.ProviderOpenAI,
+				ProviderURL:    mockURL + "/v1",
+				ModelName:      "selftest",
+				TimeoutSeconds: 10,
+				EndpointStyle:  models.EndpointStyleChatCompletions,
+			}
+			if err := de.db.InsertAgent(agent); err != nil {
+				return err
+			}
+			agents = append(agents, agent)
+		}
+
+		discussion = &models.Discussion{
+			Topic:        "Self-test: is this server able to run a debate?",
+			Status:       "running",
+			AgentIDs:     models.JSONSlice[int64]{agents[0].ID, agents[1].ID},
+			MaxRounds:    1,
+			Language:     "en",
+			MaxCharLimit: models.MinMaxCharLimit,
+			AppVersion:   version.Version,
+			Settings:     models.DiscussionSettings{SummaryBackend: models.SummaryBackendAI},
+			SelfTest:     true,
+		}
+		if err := de.db.InsertDiscussion(discussion); err != nil {
+			discussion = nil
+			return err
+		}
+		de.runMu.Lock()
+		de.selfTests[discussion.ID] = true
+		de.runMu.Unlock()
+		return nil
+	})
+
+	stage(models.SelfTestStageEngine, func() error {
+		var err error
+		if events, err = de.Subscribe(discussion.ID); err != nil {
+			return err
+		}
+		// Events are collected while the debate runs so the subscriber
+		// never falls behind
+		collected := make(chan []Event, 1)
+		go func() {
+			var got []Event
+			for event := range events {
+				got = append(got, event)
+			}
+			collected <- got
+		}()
+		defer func() {
+			de.Unsubscribe(discussion.ID, events)
+			received = <-collected
+		}()
+
+		debateCtx, cancelDebate := context.WithCancelCause(ctx)
+		defer cancelDebate(nil)
+		de.trackDebate(discussion.ID, cancelDebate)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			de.executeDebate(debateCtx, discussion, agents, nil, nil)
+		}()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			cancelDebate(ctx.Err())
+			<-done
+			return fmt.Errorf("debate did not finish within %s", selfTestTimeout)
+		}
+
+		current, err := de.db.GetDiscussion(discussion.ID)
+		if err != nil {
+			return err
+		}
+		discussion = current
+		if discussion.Status != "completed" {
+			return fmt.Errorf("debate ended as %s: %s", discussion.Status, discussion.ErrorMessage)
+		}
+		logs, err := de.db.GetDiscussionLogs(discussion.ID)
+		if err != nil {
+			return err
+		}
+		replies := 0
+		for _, l := range logs {
+			if l.Status == "success" && !l.IsModerator && !l.IsSystem() {
+				replies++
+			}
+		}
+		if replies != len(agents) {
+			return fmt.Errorf("expected %d agent replies, got %d", len(agents), replies)
+		}
+		return nil
+	})
+
+	stage(models.SelfTestStageBroadcast, func() error {
+		var logs int
+		final := false
+		for _, event := range received {
+			switch data := event.Data.(type) {
+			case models.DiscussionLog:
+				logs++
+			case models.Discussion:
+				final = final || data.Status == "completed"
+			}
+		}
+		if logs == 0 {
+			return errors.New("subscriber received no log events")
+		}
+		if !final {
+			return errors.New("subscriber did not receive the completed discussion")
+		}
+		return nil
+	})
+
+	stage(models.SelfTestStageSummary, func() error {
+		if !strings.Contains(discussion.FinalSummary, selfTestReply) {
+			return errors.New("final summary was not written by the summary agent")
+		}
+		return nil
+	})
+
+	// Cleanup always runs, and a failure there is reported even after an
+	// earlier stage failed
+	began := time.Now()
+	cleanup := models.SelfTestStage{Name: models.SelfTestStageCleanup, Passed: true}
+	if err := de.cleanupSelfTest(discussion, agents); err != nil {
+		cleanup.Passed = false
+		cleanup.Error = err.Error()
+		if report.FailedStage == "" {
+			report.FailedStage = cleanup.Name
+			report.Error = cleanup.Error
+		}
+	}
+	cleanup.DurationMs = time.Since(began).Milliseconds()
+	report.Stages = append(report.Stages, cleanup)
+
+	report.Passed = report.FailedStage == ""
+	report.DurationMs = time.Since(start).Milliseconds()
+	if !report.Passed {
+		log.Printf("Self-test failed at %s: %s", report.FailedStage, report.Error)
+	}
+	return report
+}
+
+// cleanupSelfTest purges what a self-test created
+func (de *DebateEngine) cleanupSelfTest(discussion *models.Discussion, agents []*models.Agent) error {
+	var errs []error
+	if discussion != nil {
+		if err := de.db.PurgeDiscussion(discussion.ID); err != nil {
+			errs = append(errs, fmt.Errorf("discussion %d: %w", discussion.ID, err))
+		}
+		de.runMu.Lock()
+		delete(de.selfTests, discussion.ID)
+		de.runMu.Unlock()
+	}
+	for _, agent := range agents {
+		if err := de.db.PurgeAgent(agent.ID); err != nil {
+			errs = append(errs, fmt.Errorf("agent %d: %w", agent.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// startSelfTestProvider serves serveSelfTestCompletion on a loopback port
+// and returns its base URL and a function stopping it
+func startSelfTestProvider() (string, func(), error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, err
+	}
+	server := &http.Server{Handler: http.HandlerFunc(serveSelfTestCompletion)}
+	go server.Serve(listener)
+	return "http://" + listener.Addr().String(), func() { server.Close() }, nil
+}
+
+// serveSelfTestCompletion answers every chat completion request of the
+// self-test with selfTestReply
+func serveSelfTestCompletion(w http.ResponseWriter, r *http.Request) {
+	response := OpenAIResponse{
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   "selftest",
+		Choices: []Choice{{Message: Message{Role: "assistant", Content: selfTestReply}, FinishReason: "stop"}},
+	}
+	response.Usage.PromptTokens = 1
+	response.Usage.CompletionTokens = 1
+	response.Usage.TotalTokens = 2
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
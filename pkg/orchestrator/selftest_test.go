@@ -0,0 +1,130 @@
+package orchestrator
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+func TestSelfTestConcurrentRuns(t *testing.T) {
+	de := newTestEngine(t)
+	// A user agent with the name older versions gave the self-test's agents
+	user := insertTestAgent(t, de, "Self-test agent A", "http://127.0.0.1:1")
+
+	reports := make([]*models.SelfTestReport, 3)
+	var wg sync.WaitGroup
+	for i := range reports {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reports[i] = de.SelfTest(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	for i, report := range reports {
+		if !report.Passed {
+			t.Errorf("run %d failed at %s: %s", i, report.FailedStage, report.Error)
+		}
+	}
+
+	agents, err := de.db.GetAllAgentsWithDeleted()
+	if err != nil {
+		t.Fatalf("GetAllAgentsWithDeleted: %v", err)
+	}
+	if len(agents) != 1 || agents[0].ID != user.ID || agents[0].Name != user.Name {
+		var names []string
+		for _, agent := range agents {
+			names = append(names, agent.Name)
+		}
+		t.Errorf("agents after the runs = %s, want only the user's agent", strings.Join(names, ", "))
+	}
+	counts, err := de.db.CountDiscussionsByStatus()
+	if err != nil {
+		t.Fatalf("CountDiscussionsByStatus: %v", err)
+	}
+	if len(counts) != 0 {
+		t.Errorf("discussions left behind: %v", counts)
+	}
+}
+
+// checkSelfTestCleanedUp fails the test when a self-test left agents or
+// discussions behind
+func checkSelfTestCleanedUp(t *testing.T, de *DebateEngine) {
+	t.Helper()
+	agents, err := de.db.GetAllAgentsWithDeleted()
+	if err != nil {
+		t.Fatalf("GetAllAgentsWithDeleted: %v", err)
+	}
+	if len(agents) != 0 {
+		t.Errorf("%d agents left behind", len(agents))
+	}
+	counts, err := de.db.CountDiscussionsByStatus()
+	if err != nil {
+		t.Fatalf("CountDiscussionsByStatus: %v", err)
+	}
+	if len(counts) != 0 {
+		t.Errorf("discussions left behind: %v", counts)
+	}
+}
+
+func TestSelfTestPasses(t *testing.T) {
+	de := newTestEngine(t)
+	report := de.SelfTest(context.Background())
+	if !report.Passed || report.FailedStage != "" || report.Error != "" {
+		t.Fatalf("report = %+v, want a pass", report)
+	}
+	var names []string
+	for _, stage := range report.Stages {
+		names = append(names, stage.Name)
+		if !stage.Passed || stage.Error != "" || stage.DurationMs < 0 {
+			t.Errorf("stage = %+v", stage)
+		}
+	}
+	if got := strings.Join(names, ","); got != "database,engine,broadcast,summary,cleanup" {
+		t.Errorf("stages = %s, want every stage in order", got)
+	}
+	checkSelfTestCleanedUp(t, de)
+}
+
+func TestSelfTestReportsSabotagedStage(t *testing.T) {
+	tests := []struct {
+		name       string
+		trigger    string
+		wantStage  string
+		wantError  string
+		wantStages string
+	}{
+		{"discussion not stored", "BEFORE INSERT ON discussions", models.SelfTestStageDatabase, "sabotaged", "database,cleanup"},
+		{"replies not stored", "BEFORE INSERT ON discussion_logs", models.SelfTestStageEngine, "expected 2 agent replies, got 0", "database,engine,cleanup"},
+		{"summary not stored", "BEFORE UPDATE OF final_summary ON discussions", models.SelfTestStageSummary, "final summary was not written", "database,engine,broadcast,summary,cleanup"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			de := newTestEngine(t)
+			if _, err := de.db.Exec("CREATE TRIGGER sabotage " + tt.trigger + " BEGIN SELECT RAISE(ABORT, 'sabotaged'); END"); err != nil {
+				t.Fatalf("CREATE TRIGGER: %v", err)
+			}
+
+			report := de.SelfTest(context.Background())
+			if report.Passed || report.FailedStage != tt.wantStage || !strings.Contains(report.Error, tt.wantError) {
+				t.Fatalf("report = %+v, want a failure at %s with %q", report, tt.wantStage, tt.wantError)
+			}
+			// Stages after the failing one are skipped, but cleanup runs
+			var names []string
+			for _, stage := range report.Stages {
+				names = append(names, stage.Name)
+				if stage.Passed == (stage.Name == tt.wantStage) {
+					t.Errorf("stage %s passed = %v", stage.Name, stage.Passed)
+				}
+			}
+			if got := strings.Join(names, ","); got != tt.wantStages {
+				t.Errorf("stages = %s, want %s", got, tt.wantStages)
+			}
+			checkSelfTestCleanedUp(t, de)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package orchestrator
+
+import (
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// SlowCallConfig returns the stored slow-call threshold, or the default when
+// none was saved
+func (de *DebateEngine) SlowCallConfig() models.SlowCallConfig {
+	var cfg models.SlowCallConfig
+	found, err := de.db.GetSettingJSON(database.SettingSlowCall, &cfg)
+	if err != nil {
+		log.Printf("Failed to read slow-call settings: %v", err)
+	}
+	if !found || err != nil || cfg.Validate() != nil {
+		cfg = models.SlowCallConfig{ThresholdSeconds: models.DefaultSlowCallSeconds}
+	}
+	return cfg
+}
+
+func init() {
+	registerFeature(FeatureSlowCallTrace, func(de *DebateEngine) bool {
+		return de.SlowCallConfig().ThresholdSeconds > 0
+	})
+}
+
+// traceSlowCall writes a system log entry and raises a slow_call event when
+// a call to agent took longer than the slow-call threshold, so degradation
+// shows during a live debate. It reports whether the call was slow.
+func (de *DebateEngine) traceSlowCall(discussionID int64, agent *models.Agent, response *models.AgentResponse) bool {
+	threshold := de.SlowCallConfig().ThresholdSeconds
+	if response == nil || threshold == 0 || response.ResponseTime <= threshold*1000 {
+		return false
+	}
+
+	metadata := models.JSONMap{
+		"alert":       "slow_call",
+		"agent_id":    strconv.FormatInt(agent.ID, 10),
+		"response_ms": strconv.Itoa(response.ResponseTime),
+		"threshold_s": strconv.Itoa(threshold),
+	}
+	details := []string{fmt.Sprintf("%s took %.1fs (threshold %ds)", agent.Name, float64(response.ResponseTime)/1000, threshold)}
+	if endpoint := response.Metadata["endpoint"]; endpoint != "" {
+		metadata["endpoint"] = endpoint
+		details = append(details, "endpoint "+endpoint)
+	}
+	if attempts := response.Metadata["attempts"]; attempts != "" {
+		metadata["attempts"] = attempts
+		details = append(details, attempts+" attempt(s)")
+	}
+	for _, key := range []string{"input_tokens", "output_tokens", "total_tokens"} {
+		if v := response.Metadata[key]; v != "" {
+			metadata[key] = v
+			details = append(details, strings.Replace(key, "_", " ", 1)+" "+v)
+		}
+	}
+	if !response.Success {
+		metadata["error_class"] = response.Metadata["error_class"]
+		details = append(details, "failed: "+response.Metadata["error_class"])
+	}
+
+	message := "Slow call: " + strings.Join(details, ", ")
+	log.Printf("Discussion %d: %s", discussionID, message)
+	de.insertSystemLog(discussionID, models.LogTypeSystem, "error", message, metadata)
+
+	event := &models.EngineEvent{
+		Type:         "slow_call",
+		DiscussionID: discussionID,
+		Message:      message,
+		CreatedAt:    de.now(),
+	}
+	de.broadcast(discussionID, event)
+	de.broadcastGlobal(event)
+	return true
+}
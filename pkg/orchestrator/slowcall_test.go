@@ -0,0 +1,82 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+)
+
+func TestTraceSlowCall(t *testing.T) {
+	de := newTestEngine(t)
+	alice := insertTestAgent(t, de, "Alice", "http://127.0.0.1:1")
+	discussion := insertTestDiscussion(t, de, "running", alice)
+	events := de.SubscribeGlobal()
+	defer de.UnsubscribeGlobal(events)
+
+	response := func(ms int, success bool) *models.AgentResponse {
+		return &models.AgentResponse{
+			Success:      success,
+			ResponseTime: ms,
+			Metadata: map[string]string{
+				"endpoint":     "http://provider/v1/chat/completions",
+				"attempts":     "2",
+				"input_tokens": "120",
+				"total_tokens": "180",
+				"error_class":  "timeout",
+			},
+		}
+	}
+
+	// The default threshold is a minute
+	if de.traceSlowCall(discussion.ID, alice, response(59000, true)) {
+		t.Error("59s call traced under the 60s default")
+	}
+	if err := de.db.SetSettingJSON(database.SettingSlowCall, models.SlowCallConfig{ThresholdSeconds: 2}); err != nil {
+		t.Fatalf("SetSettingJSON: %v", err)
+	}
+	if de.traceSlowCall(discussion.ID, alice, response(2000, true)) {
+		t.Error("call at exactly the threshold traced")
+	}
+	if de.traceSlowCall(discussion.ID, alice, nil) {
+		t.Error("call without a response traced")
+	}
+	if !de.traceSlowCall(discussion.ID, alice, response(2500, false)) {
+		t.Fatal("2.5s call not traced over a 2s threshold")
+	}
+
+	logs, err := de.db.GetDiscussionLogs(discussion.ID)
+	if err != nil || len(logs) != 1 {
+		t.Fatalf("GetDiscussionLogs = %d logs, %v; want the one slow-call entry", len(logs), err)
+	}
+	entry := logs[0]
+	if !entry.IsSystem() || entry.Metadata["alert"] != "slow_call" || entry.Metadata["response_ms"] != "2500" {
+		t.Errorf("entry = %+v, want a system slow_call entry for 2500ms", entry)
+	}
+	for key, want := range map[string]string{"endpoint": "http://provider/v1/chat/completions", "attempts": "2", "input_tokens": "120", "total_tokens": "180", "error_class": "timeout", "threshold_s": "2"} {
+		if entry.Metadata[key] != want {
+			t.Errorf("metadata[%s] = %q, want %q", key, entry.Metadata[key], want)
+		}
+	}
+	if want := "Alice took 2.5s (threshold 2s)"; !strings.Contains(entry.Content, want) {
+		t.Errorf("entry content = %q, want it to mention %q", entry.Content, want)
+	}
+
+	select {
+	case event := <-events:
+		if e, ok := event.Data.(models.EngineEvent); !ok || event.Type != "slow_call" || e.DiscussionID != discussion.ID {
+			t.Errorf("event = %+v, want slow_call for discussion %d", event, discussion.ID)
+		}
+	default:
+		t.Error("no slow_call event raised")
+	}
+
+	// Zero turns tracing off
+	if err := de.db.SetSettingJSON(database.SettingSlowCall, models.SlowCallConfig{}); err != nil {
+		t.Fatalf("SetSettingJSON: %v", err)
+	}
+	if de.traceSlowCall(discussion.ID, alice, response(600000, true)) {
+		t.Error("call traced with tracing off")
+	}
+}
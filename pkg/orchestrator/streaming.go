@@ -0,0 +1,275 @@
+package orchestrator
+
+import (
+	"bufio"
+	"context"
+	"court-table-ai/pkg/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxStreamLine bounds one line of a streamed response; a single SSE event
+// or NDJSON object never comes close
+const maxStreamLine = 1 << 20
+
+// CallAgentStream calls an agent like CallAgent, but asks providers that
+// support it (OpenAI-compatible chat completions, Anthropic and Ollama) to
+// stream their reply. Text is sent on deltas as it is generated, in order;
+// other providers send nothing. The full reply is still returned, and deltas
+// is closed before CallAgentStream returns. A stream that breaks off fails
+// the call with the text received so far as the response content, and is not
+// retried, since its text has already been sent.
+func (ac *AgentClient) CallAgentStream(ctx context.Context, agent *models.Agent, prompt string, contextStr string, deltas chan<- string) (*models.AgentResponse, error) {
+	return ac.CallAgentStreamWithOptions(ctx, agent, prompt, contextStr, CallOptions{}, deltas)
+}
+
+// CallAgentStreamWithOptions is CallAgentStream with per-call parameter overrides
+func (ac *AgentClient) CallAgentStreamWithOptions(ctx context.Context, agent *models.Agent, prompt string, contextStr string, opts CallOptions, deltas chan<- string) (*models.AgentResponse, error) {
+	defer close(deltas)
+	opts.deltas = deltas
+	return ac.CallAgentWithOptions(ctx, agent, prompt, contextStr, opts)
+}
+
+// streaming reports whether the caller wants the reply streamed
+func (o CallOptions) streaming() bool {
+	return o.deltas != nil
+}
+
+// emit sends streamed text to the caller and marks the call as streamed
+func (o CallOptions) emit(ctx context.Context, text string) {
+	if text == "" {
+		return
+	}
+	if trace, ok := ctx.Value(callTraceKey{}).(*callTrace); ok {
+		trace.streamed = true
+	}
+	select {
+	case o.deltas <- text:
+	case <-ctx.Done():
+	}
+}
+
+// readStreamLines calls fn with each non-empty line of a streamed body until
+// fn returns errStreamDone or an error, or the body ends
+func readStreamLines(body io.Reader, fn func(line string) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxStreamLine)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if err := fn(line); err != nil {
+			if errors.Is(err, errStreamDone) {
+				return nil
+			}
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("stream interrupted: %w", err)
+	}
+	return nil
+}
+
+// errStreamDone stops readStreamLines at the end marker of a stream
+var errStreamDone = errors.New("stream done")
+
+// sseData returns the payload of an SSE data line
+func sseData(line string) (string, bool) {
+	if !strings.HasPrefix(line, "data:") {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(line, "data:")), true
+}
+
+// streamFailure builds the failed response of a stream that broke off,
+// keeping the text received so far
+func streamFailure(content string, usage *models.AgentResponse, err error) (*models.AgentResponse, error) {
+	return &models.AgentResponse{
+		Success:          false,
+		Content:          content,
+		ErrorMessage:     err.Error(),
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+	}, err
+}
+
+// readOpenAIStream assembles a streamed chat completion: SSE data lines of
+// chunks whose choices carry content deltas, ending with "data: [DONE]".
+// Usage is read from any chunk that reports it.
+func readOpenAIStream(ctx context.Context, body io.Reader, opts CallOptions) (*models.AgentResponse, error) {
+	var content strings.Builder
+	usage := &models.AgentResponse{}
+	done := false
+
+	err := readStreamLines(body, func(line string) error {
+		data, ok := sseData(line)
+		if !ok {
+			return nil
+		}
+		if data == "[DONE]" {
+			done = true
+			return errStreamDone
+		}
+
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+				FinishReason string `json:"finish_reason"`
+			} `json:"choices"`
+			Usage *struct {
+				PromptTokens     int `json:"prompt_tokens"`
+				CompletionTokens int `json:"completion_tokens"`
+			} `json:"usage"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("failed to parse stream chunk: %v", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("API error in stream: %s", chunk.Error.Message)
+		}
+		if chunk.Usage != nil {
+			usage.PromptTokens = chunk.Usage.PromptTokens
+			usage.CompletionTokens = chunk.Usage.CompletionTokens
+		}
+		for _, choice := range chunk.Choices {
+			content.WriteString(choice.Delta.Content)
+			opts.emit(ctx, choice.Delta.Content)
+			if choice.FinishReason != "" {
+				done = true
+			}
+		}
+		return nil
+	})
+	if err == nil && !done {
+		err = errors.New("stream interrupted: ended before the reply was complete")
+	}
+	if err == nil && content.Len() == 0 {
+		err = errors.New("empty response in stream")
+	}
+	if err != nil {
+		return streamFailure(content.String(), usage, err)
+	}
+
+	usage.Success = true
+	usage.Content = content.String()
+	return usage, nil
+}
+
+// readAnthropicStream assembles a streamed Messages API reply from its
+// message_start, content_block_delta, message_delta and message_stop events
+func readAnthropicStream(ctx context.Context, body io.Reader, opts CallOptions) (*models.AgentResponse, error) {
+	var content strings.Builder
+	usage := &models.AgentResponse{}
+	done := false
+
+	err := readStreamLines(body, func(line string) error {
+		data, ok := sseData(line)
+		if !ok {
+			return nil
+		}
+
+		var event struct {
+			Type    string `json:"type"`
+			Message struct {
+				Usage struct {
+					InputTokens int `json:"input_tokens"`
+				} `json:"usage"`
+			} `json:"message"`
+			Delta struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"delta"`
+			Usage struct {
+				OutputTokens int `json:"output_tokens"`
+			} `json:"usage"`
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("failed to parse stream event: %v", err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			usage.PromptTokens = event.Message.Usage.InputTokens
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" {
+				content.WriteString(event.Delta.Text)
+				opts.emit(ctx, event.Delta.Text)
+			}
+		case "message_delta":
+			usage.CompletionTokens = event.Usage.OutputTokens
+		case "message_stop":
+			done = true
+			return errStreamDone
+		case "error":
+			return fmt.Errorf("API error in stream: %s", event.Error.Message)
+		}
+		return nil
+	})
+	if err == nil && !done {
+		err = errors.New("stream interrupted: ended before message_stop")
+	}
+	if err == nil && content.Len() == 0 {
+		err = errors.New("no text content found in Claude stream")
+	}
+	if err != nil {
+		return streamFailure(content.String(), usage, err)
+	}
+
+	usage.Success = true
+	usage.Content = content.String()
+	return usage, nil
+}
+
+// readOllamaStream assembles a streamed /api/chat or /api/generate reply: one
+// JSON object per line, the last with done set and the token counts
+func readOllamaStream(ctx context.Context, body io.Reader, opts CallOptions) (*models.AgentResponse, error) {
+	var content strings.Builder
+	usage := &models.AgentResponse{}
+	done := false
+
+	err := readStreamLines(body, func(line string) error {
+		var chunk struct {
+			OllamaResponse
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return fmt.Errorf("failed to parse stream chunk: %v", err)
+		}
+		if chunk.Error != "" {
+			return fmt.Errorf("API error in stream: %s", chunk.Error)
+		}
+
+		content.WriteString(chunk.text())
+		opts.emit(ctx, chunk.text())
+		if chunk.Done {
+			usage.PromptTokens = chunk.PromptEvalCount
+			usage.CompletionTokens = chunk.EvalCount
+			done = true
+			return errStreamDone
+		}
+		return nil
+	})
+	if err == nil && !done {
+		err = errors.New("stream interrupted: ended before done")
+	}
+	if err != nil {
+		return streamFailure(content.String(), usage, err)
+	}
+
+	usage.Success = true
+	usage.Content = content.String()
+	return usage, nil
+}
@@ -0,0 +1,197 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+)
+
+// streamClient returns an AgentClient without a database whose requests are
+// answered with body as contentType by a fake transport, and the stream flag
+// of each request it sent
+func streamClient(t *testing.T, contentType, body string) (*AgentClient, func() []bool) {
+	t.Helper()
+	var (
+		mu          sync.Mutex
+		streamFlags []bool
+	)
+	ac := NewAgentClient(nil)
+	ac.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		data, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		streamFlags = append(streamFlags, strings.Contains(string(data), `"stream":true`))
+		mu.Unlock()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {contentType}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    r,
+		}, nil
+	})}
+	return ac, func() []bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]bool(nil), streamFlags...)
+	}
+}
+
+// callStream calls agent through CallAgentStream and collects the deltas it
+// sent
+func callStream(t *testing.T, ac *AgentClient, agent *models.Agent) (*models.AgentResponse, []string, error) {
+	t.Helper()
+	deltas := make(chan string, 64)
+	response, err := ac.CallAgentStream(context.Background(), agent, "Tabs or spaces?", "", deltas)
+	var got []string
+	for text := range deltas {
+		got = append(got, text)
+	}
+	return response, got, err
+}
+
+const (
+	openAIStream = "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\n" +
+		"data: {\"choices\":[{\"delta\":{\"content\":\"Spaces\"}}]}\n\n" +
+		": keep-alive\n\n" +
+		"data: {\"choices\":[{\"delta\":{\"content\":\", always.\"}}]}\n\n" +
+		"data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}],\"usage\":{\"prompt_tokens\":12,\"completion_tokens\":4}}\n\n" +
+		"data: [DONE]\n\n"
+	anthropicStream = "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":12}}}\n\n" +
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0}\n\n" +
+		"event: ping\ndata: {\"type\":\"ping\"}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"Spaces\"}}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\", always.\"}}\n\n" +
+		"event: message_delta\ndata: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":4}}\n\n" +
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+	ollamaStream = "{\"message\":{\"role\":\"assistant\",\"content\":\"Spaces\"},\"done\":false}\n" +
+		"{\"message\":{\"role\":\"assistant\",\"content\":\", always.\"},\"done\":false}\n" +
+		"{\"message\":{\"role\":\"assistant\",\"content\":\"\"},\"done\":true,\"prompt_eval_count\":12,\"eval_count\":4}\n"
+)
+
+var streamProviders = []struct {
+	name        string
+	provider    string
+	url         string
+	contentType string
+	stream      string
+	// broken cuts the stream off after the first text
+	broken string
+	// failed reports an error after the first text
+	failed string
+}{
+	{"openai", models.ProviderOpenAI, "https://api.openai.com/v1", "text/event-stream", openAIStream,
+		"data: {\"choices\":[{\"delta\":{\"content\":\"Spaces\"}}]}\n\n",
+		"data: {\"choices\":[{\"delta\":{\"content\":\"Spaces\"}}]}\n\ndata: {\"error\":{\"message\":\"overloaded\"}}\n\n"},
+	{"anthropic", models.ProviderAnthropic, "https://api.anthropic.com/v1", "text/event-stream; charset=utf-8", anthropicStream,
+		strings.Join(strings.SplitAfter(anthropicStream, "\n\n")[:4], ""),
+		strings.Join(strings.SplitAfter(anthropicStream, "\n\n")[:4], "") + "event: error\ndata: {\"type\":\"error\",\"error\":{\"message\":\"overloaded\"}}\n\n"},
+	{"ollama", models.ProviderOllama, "http://localhost:11434", "application/x-ndjson", ollamaStream,
+		strings.SplitAfter(ollamaStream, "\n")[0],
+		strings.SplitAfter(ollamaStream, "\n")[0] + "{\"error\":\"overloaded\"}\n"},
+}
+
+func TestCallAgentStream(t *testing.T) {
+	for _, tt := range streamProviders {
+		t.Run(tt.name, func(t *testing.T) {
+			ac, streamFlags := streamClient(t, tt.contentType, tt.stream)
+			agent := &models.Agent{Name: tt.name, ProviderType: tt.provider, ProviderURL: tt.url, APIToken: "key", ModelName: "test-model", TimeoutSeconds: 10}
+
+			response, deltas, err := callStream(t, ac, agent)
+			if err != nil || !response.Success {
+				t.Fatalf("CallAgentStream = %+v, %v", response, err)
+			}
+			if got := streamFlags(); len(got) != 1 || !got[0] {
+				t.Errorf("requests asked for a stream: %v, want one that does", got)
+			}
+			if strings.Join(deltas, "|") != "Spaces|, always." {
+				t.Errorf("deltas = %q, want the text in order", deltas)
+			}
+			if response.Content != "Spaces, always." || response.PromptTokens != 12 || response.CompletionTokens != 4 {
+				t.Errorf("response = %q with %d/%d tokens, want the assembled reply with 12/4", response.Content, response.PromptTokens, response.CompletionTokens)
+			}
+		})
+	}
+}
+
+func TestCallAgentStreamBreaksOff(t *testing.T) {
+	for _, tt := range streamProviders {
+		for name, body := range map[string]string{"cut off": tt.broken, "error event": tt.failed} {
+			t.Run(tt.name+" "+name, func(t *testing.T) {
+				ac, streamFlags := streamClient(t, tt.contentType, body)
+				agent := &models.Agent{Name: tt.name, ProviderType: tt.provider, ProviderURL: tt.url, APIToken: "key", ModelName: "test-model", TimeoutSeconds: 10}
+
+				// The text already sent is kept, and the call is not retried
+				response, deltas, err := callStream(t, ac, agent)
+				if err == nil || response.Success || response.Content != "Spaces" {
+					t.Errorf("CallAgentStream = %+v, %v, want a failure keeping the partial reply", response, err)
+				}
+				if strings.Join(deltas, "|") != "Spaces" {
+					t.Errorf("deltas = %q, want the partial reply", deltas)
+				}
+				if got := len(streamFlags()); got != 1 {
+					t.Errorf("sent %d requests, want 1", got)
+				}
+			})
+		}
+	}
+}
+
+func TestCallAgentStreamPlainReply(t *testing.T) {
+	// A gateway that ignores stream answers with one JSON body
+	ac, _ := streamClient(t, "application/json", `{"choices":[{"message":{"role":"assistant","content":"Spaces."},"finish_reason":"stop"}]}`)
+	agent := &models.Agent{Name: "Plain", ProviderType: models.ProviderCustom, ProviderURL: "https://llm.example.com/v1", ModelName: "m", TimeoutSeconds: 10}
+
+	response, deltas, err := callStream(t, ac, agent)
+	if err != nil || !response.Success || response.Content != "Spaces." {
+		t.Fatalf("CallAgentStream = %+v, %v", response, err)
+	}
+	if len(deltas) != 0 {
+		t.Errorf("deltas = %q, want none for a plain reply", deltas)
+	}
+}
+
+func TestBrokenStreamIsLoggedAsPartial(t *testing.T) {
+	de := newTestEngine(t)
+	if err := de.db.SetSettingJSON(database.SettingAgentRetries, models.AgentRetryConfig{MaxRetries: 2, BaseDelayMs: 1, MaxDelayMs: 2}); err != nil {
+		t.Fatalf("SetSettingJSON: %v", err)
+	}
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Spaces keep\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		// The connection closes before the reply is complete
+	}))
+	t.Cleanup(server.Close)
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+
+	d := runToEnd(t, de, []*models.Agent{alice}, 1, models.DiscussionSettings{})
+	logs, err := de.db.GetDiscussionLogs(d.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	var turn *models.DiscussionLog
+	for _, l := range logs {
+		if l.AgentID == alice.ID {
+			turn = l
+		}
+	}
+	if turn == nil {
+		t.Fatal("no turn logged for Alice")
+	}
+	if turn.Status != "error" || turn.Metadata["partial"] != "true" || !strings.HasSuffix(turn.Content, "\n\nPartial reply:\nSpaces keep") {
+		t.Errorf("turn = %s %q %v, want an error keeping the partial reply", turn.Status, turn.Content, turn.Metadata)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("provider got %d requests, want the broken stream not retried", got)
+	}
+}
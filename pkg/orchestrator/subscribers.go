@@ -0,0 +1,140 @@
+package orchestrator
+
+import (
+	"context"
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTooManySubscribers is returned by Subscribe when a discussion already
+// has as many subscribers as the subscriber limits allow
+var ErrTooManySubscribers = errors.New("too many subscribers to this discussion")
+
+// subscriber is one live stream of a discussion's events
+type subscriber struct {
+	ch chan Event
+	// fullSince is when a broadcast first found the buffer full, in Unix
+	// nanoseconds, or 0 while the buffer has room
+	fullSince atomic.Int64
+}
+
+// send queues event without blocking. An event that does not fit is
+// dropped, and the time the buffer filled up is kept for the reaper.
+func (s *subscriber) send(event Event, now time.Time) {
+	select {
+	case s.ch <- event:
+		s.fullSince.Store(0)
+	default:
+		// Buffer full, skip
+		s.fullSince.CompareAndSwap(0, now.UnixNano())
+	}
+}
+
+// stuck reports whether the subscriber's buffer is full and has been since
+// before cutoff: its reader is gone or has stopped reading
+func (s *subscriber) stuck(cutoff time.Time) bool {
+	since := s.fullSince.Load()
+	return since != 0 && since <= cutoff.UnixNano() && len(s.ch) == cap(s.ch)
+}
+
+// removeSubscriber drops the subscriber at position i of a discussion and
+// closes its channel; the caller holds subMu
+func (de *DebateEngine) removeSubscriber(discussionID int64, i int) {
+	subs := de.subscribers[discussionID]
+	close(subs[i].ch)
+	subs = append(subs[:i], subs[i+1:]...)
+	if len(subs) == 0 {
+		delete(de.subscribers, discussionID)
+		return
+	}
+	de.subscribers[discussionID] = subs
+}
+
+// SubscriberLimits returns the stored subscriber limits, or the defaults
+// when none were saved
+func (de *DebateEngine) SubscriberLimits() models.SubscriberLimitsConfig {
+	var cfg models.SubscriberLimitsConfig
+	found, err := de.db.GetSettingJSON(database.SettingSubscriberLimits, &cfg)
+	if err != nil {
+		log.Printf("Failed to read subscriber limits: %v", err)
+	}
+	if !found || err != nil || cfg.Validate() != nil {
+		cfg = models.SubscriberLimitsConfig{MaxPerDiscussion: models.DefaultMaxSubscribers, IdleSeconds: models.DefaultSubscriberIdleSeconds}
+	}
+	return cfg
+}
+
+// SubscriberCounts returns the number of subscribers of each discussion that
+// has any
+func (de *DebateEngine) SubscriberCounts() map[int64]int {
+	de.subMu.RLock()
+	defer de.subMu.RUnlock()
+
+	counts := make(map[int64]int, len(de.subscribers))
+	for id, subs := range de.subscribers {
+		counts[id] = len(subs)
+	}
+	return counts
+}
+
+// SubscriberStats reports the subscriber limits with the current counts
+func (de *DebateEngine) SubscriberStats() models.SubscriberStats {
+	stats := models.SubscriberStats{
+		SubscriberLimitsConfig: de.SubscriberLimits(),
+		Discussions:            de.SubscriberCounts(),
+		Reaped:                 de.reaped.Load(),
+	}
+	for _, n := range stats.Discussions {
+		stats.Total += n
+	}
+	return stats
+}
+
+// ReapIdleSubscribers drops the discussion subscribers whose buffer has been
+// full for the configured idle period, closing their channels so the
+// streams serving them end. It returns the number dropped.
+func (de *DebateEngine) ReapIdleSubscribers() int {
+	cutoff := de.now().Add(-time.Duration(de.SubscriberLimits().IdleSeconds) * time.Second)
+
+	de.subMu.Lock()
+	defer de.subMu.Unlock()
+
+	reaped := 0
+	for id, subs := range de.subscribers {
+		dropped := 0
+		for i := len(subs) - 1; i >= 0; i-- {
+			if subs[i].stuck(cutoff) {
+				de.removeSubscriber(id, i)
+				dropped++
+			}
+		}
+		if dropped > 0 {
+			log.Printf("Dropped %d idle subscribers of discussion %d", dropped, id)
+		}
+		reaped += dropped
+	}
+	de.reaped.Add(int64(reaped))
+	return reaped
+}
+
+// StartSubscriberReaper drops idle subscribers every interval until ctx is
+// done
+func (de *DebateEngine) StartSubscriberReaper(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				de.ReapIdleSubscribers()
+			}
+		}
+	}()
+}
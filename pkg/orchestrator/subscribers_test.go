@@ -0,0 +1,116 @@
+package orchestrator
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+)
+
+func setSubscriberLimits(t *testing.T, de *DebateEngine, max, idleSeconds int) {
+	t.Helper()
+	if err := de.db.SetSettingJSON(database.SettingSubscriberLimits, models.SubscriberLimitsConfig{MaxPerDiscussion: max, IdleSeconds: idleSeconds}); err != nil {
+		t.Fatalf("SetSettingJSON: %v", err)
+	}
+}
+
+func TestSubscriberCap(t *testing.T) {
+	de := newTestEngine(t)
+	if limits := de.SubscriberLimits(); limits.MaxPerDiscussion != models.DefaultMaxSubscribers || limits.IdleSeconds != models.DefaultSubscriberIdleSeconds {
+		t.Errorf("default limits = %+v", limits)
+	}
+	setSubscriberLimits(t, de, 3, 60)
+
+	var chans []chan Event
+	for i := 0; i < 3; i++ {
+		ch, err := de.Subscribe(1)
+		if err != nil {
+			t.Fatalf("Subscribe %d: %v", i+1, err)
+		}
+		chans = append(chans, ch)
+	}
+	if ch, err := de.Subscribe(1); !errors.Is(err, ErrTooManySubscribers) || ch != nil {
+		t.Errorf("Subscribe over the cap = %v, %v, want ErrTooManySubscribers", ch, err)
+	}
+	// The cap is per discussion
+	if _, err := de.Subscribe(2); err != nil {
+		t.Errorf("Subscribe to another discussion: %v", err)
+	}
+
+	de.Unsubscribe(1, chans[0])
+	if _, err := de.Subscribe(1); err != nil {
+		t.Errorf("Subscribe after one left: %v", err)
+	}
+	stats := de.SubscriberStats()
+	if stats.Total != 4 || stats.Discussions[1] != 3 || stats.Discussions[2] != 1 || stats.MaxPerDiscussion != 3 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestReapIdleSubscribers(t *testing.T) {
+	de := newTestEngine(t)
+	clock := newFakeClock(de)
+	setSubscriberLimits(t, de, 10, 30)
+
+	unread, err := de.Subscribe(1)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	reader, _ := de.Subscribe(1)
+	drain := func() {
+		for len(reader) > 0 {
+			<-reader
+		}
+	}
+
+	// Fill the unread buffer, then overflow it
+	for i := 0; i <= cap(unread); i++ {
+		de.broadcast(1, models.DiscussionLog{Content: "Spaces."})
+		drain()
+	}
+	clock.Advance(29 * time.Second)
+	if n := de.ReapIdleSubscribers(); n != 0 {
+		t.Fatalf("reaped %d subscribers before the idle period", n)
+	}
+
+	clock.Advance(time.Second)
+	de.broadcast(1, models.DiscussionLog{Content: "Tabs."})
+	drain()
+	if n := de.ReapIdleSubscribers(); n != 1 {
+		t.Fatalf("reaped %d subscribers, want the unread one", n)
+	}
+	// The dropped channel is closed once its buffered events are read
+	for range unread {
+	}
+	if counts := de.SubscriberCounts(); counts[1] != 1 {
+		t.Errorf("subscribers left = %v, want the reader", counts)
+	}
+	if stats := de.SubscriberStats(); stats.Reaped != 1 {
+		t.Errorf("reaped count = %d, want 1", stats.Reaped)
+	}
+	// Unsubscribing a reaped channel is harmless
+	de.Unsubscribe(1, unread)
+	if counts := de.SubscriberCounts(); counts[1] != 1 {
+		t.Errorf("subscribers after unsubscribing the reaped one = %v", counts)
+	}
+}
+
+func TestFullBufferThatDrainsIsKept(t *testing.T) {
+	de := newTestEngine(t)
+	clock := newFakeClock(de)
+	setSubscriberLimits(t, de, 10, 30)
+
+	slow, _ := de.Subscribe(1)
+	for i := 0; i <= cap(slow); i++ {
+		de.broadcast(1, models.DiscussionLog{Content: "Spaces."})
+	}
+	// A slow reader catches up before the idle period ends
+	<-slow
+	de.broadcast(1, models.DiscussionLog{Content: "Tabs."})
+	clock.Advance(time.Minute)
+	if n := de.ReapIdleSubscribers(); n != 0 {
+		t.Errorf("reaped %d subscribers that kept reading", n)
+	}
+}
@@ -0,0 +1,69 @@
+package orchestrator
+
+import (
+	"context"
+	"court-table-ai/pkg/models"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// summarySystemPrompt replaces the summarizer's own system prompt so it
+// reports on the debate rather than taking part in it
+const summarySystemPrompt = "You write neutral, faithful summaries of debates between AI agents. " +
+	"Attribute positions to the participants who took them and do not add arguments of your own."
+
+// summarizer returns the agent that writes the AI summary: the moderator, or
+// the first participant of the discussion when there is none. agents is not
+// necessarily in the discussion's order.
+func summarizer(discussion *models.Discussion, agents []*models.Agent, moderator *models.Agent) *models.Agent {
+	if moderator != nil {
+		return moderator
+	}
+	if len(discussion.AgentIDs) > 0 {
+		for _, a := range agents {
+			if a.ID == discussion.AgentIDs[0] {
+				return a
+			}
+		}
+	}
+	if len(agents) > 0 {
+		return agents[0]
+	}
+	return nil
+}
+
+// aiSummary asks agent to summarize transcript in at most limit characters,
+// in the discussion's language
+func (de *DebateEngine) aiSummary(ctx context.Context, agent *models.Agent, discussion *models.Discussion, transcript string, limit int) (string, error) {
+	temperature := 0.3
+	opts := CallOptions{SystemPrompt: summarySystemPrompt, Temperature: &temperature}
+
+	response, err := de.agentClient.CallAgentWithOptions(ctx, agent, buildSummaryPrompt(discussion, transcript, limit), "", opts)
+	if err != nil {
+		return "", err
+	}
+	if !response.Success {
+		return "", errors.New(response.ErrorMessage)
+	}
+
+	text := strings.TrimSpace(response.Content)
+	if text == "" {
+		return "", errors.New("the summary was empty")
+	}
+	return truncateResponse(text, limit), nil
+}
+
+// buildSummaryPrompt asks for a summary of the whole transcript
+func buildSummaryPrompt(discussion *models.Discussion, transcript string, limit int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Debate topic: %s\n", promptTopic(discussion.Topic))
+	fmt.Fprintf(&b, "Language of discussion: %s\n\n", discussion.LanguageName())
+	fmt.Fprintf(&b, "Full transcript:\n%s\n\n", fence(transcript))
+	fmt.Fprintf(&b, "Summarize this debate: the main position of each participant, where they agreed, ")
+	fmt.Fprintf(&b, "where they still disagree, and the overall conclusion if one was reached.\n\n")
+	fmt.Fprintf(&b, "IMPORTANT:\n")
+	fmt.Fprintf(&b, "- DO NOT EXCEED %d CHARACTERS\n", limit)
+	fmt.Fprintf(&b, "- RESPOND ONLY IN %s\n", strings.ToUpper(discussion.LanguageName()))
+	return b.String()
+}
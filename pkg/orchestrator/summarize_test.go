@@ -0,0 +1,191 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+func TestSummarizer(t *testing.T) {
+	alice := &models.Agent{ID: 1, Name: "Alice"}
+	bob := &models.Agent{ID: 2, Name: "Bob"}
+	sage := &models.Agent{ID: 3, Name: "Sage"}
+	discussion := &models.Discussion{AgentIDs: models.JSONSlice[int64]{2, 1}}
+
+	if got := summarizer(discussion, []*models.Agent{alice, bob}, sage); got != sage {
+		t.Errorf("summarizer with a moderator = %v, want Sage", got)
+	}
+	// Agents are loaded in any order; the discussion's first agent is picked
+	if got := summarizer(discussion, []*models.Agent{alice, bob}, nil); got != bob {
+		t.Errorf("summarizer without a moderator = %v, want Bob", got)
+	}
+	if got := summarizer(&models.Discussion{}, []*models.Agent{alice}, nil); got != alice {
+		t.Errorf("summarizer of a discussion without agent IDs = %v, want Alice", got)
+	}
+	if got := summarizer(discussion, nil, nil); got != nil {
+		t.Errorf("summarizer without agents = %v, want none", got)
+	}
+}
+
+// newSummaryProvider answers debate turns with a fixed reply and hands
+// summary requests, told apart by their system prompt, to summarize. It
+// returns the bodies of the summary requests.
+func newSummaryProvider(t *testing.T, summarize http.HandlerFunc) (*httptest.Server, func() []map[string]interface{}) {
+	t.Helper()
+	var (
+		mu     sync.Mutex
+		bodies []map[string]interface{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("provider got a body that is not JSON: %v", err)
+		}
+		if systemMessage(body) == summarySystemPrompt {
+			mu.Lock()
+			bodies = append(bodies, body)
+			mu.Unlock()
+			summarize(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Spaces keep diffs aligned."},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":3}}`)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []map[string]interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]map[string]interface{}(nil), bodies...)
+	}
+}
+
+// runAISummaryDebate runs a one-round debate of agent with the AI summary
+// capped at limit characters and returns it once it ended
+func runAISummaryDebate(t *testing.T, de *DebateEngine, agent *models.Agent, language string, limit int) *models.Discussion {
+	t.Helper()
+	settings := models.DiscussionSettings{SummaryBackend: models.SummaryBackendAI, SummaryCharLimit: limit}
+	discussion, err := de.RunDebate(context.Background(), "Tabs or spaces", []int64{agent.ID}, nil, nil, nil, 1, language, models.DefaultMaxCharLimit, settings)
+	if err != nil {
+		t.Fatalf("RunDebate: %v", err)
+	}
+	var ended *models.Discussion
+	waitUntil(t, "the debate to end", func() bool {
+		ended, err = de.db.GetDiscussion(discussion.ID)
+		return err == nil && !ended.InProgress()
+	})
+	return ended
+}
+
+// summaryAlerts returns the system entries reporting a failed summary
+func summaryAlerts(t *testing.T, de *DebateEngine, discussionID int64) []*models.DiscussionLog {
+	t.Helper()
+	logs, err := de.db.GetDiscussionLogs(discussionID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	var alerts []*models.DiscussionLog
+	for _, l := range logs {
+		if l.LogType == models.LogTypeSystem && l.Metadata["alert"] == "summary" {
+			alerts = append(alerts, l)
+		}
+	}
+	return alerts
+}
+
+func TestAISummary(t *testing.T) {
+	de := newTestEngine(t)
+	server, summaries := newSummaryProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"  Alice argued for spaces and nobody disagreed with her.  "},"finish_reason":"stop"}]}`)
+	})
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+
+	d := runAISummaryDebate(t, de, alice, "id", 40)
+	if d.Status != "completed" {
+		t.Errorf("discussion ended %s, want completed", d.Status)
+	}
+	bodies := summaries()
+	if len(bodies) != 1 {
+		t.Fatalf("got %d summary calls, want 1", len(bodies))
+	}
+	prompt := userMessage(bodies[0])
+	for _, want := range []string{"Spaces keep diffs aligned.", "DO NOT EXCEED 40 CHARACTERS", "RESPOND ONLY IN INDONESIAN"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("summary prompt lacks %q:\n%s", want, prompt)
+		}
+	}
+	// The reply is trimmed and cut to the summary limit
+	if want := truncateResponse("Alice argued for spaces and nobody disagreed with her.", 40); !strings.HasSuffix(d.FinalSummary, want) {
+		t.Errorf("final summary = %q, want it to end with %q", d.FinalSummary, want)
+	}
+	if alerts := summaryAlerts(t, de, d.ID); len(alerts) != 0 {
+		t.Errorf("a successful summary recorded %d alerts", len(alerts))
+	}
+}
+
+func TestAISummaryFallsBack(t *testing.T) {
+	de := newTestEngine(t)
+	server, _ := newSummaryProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"message":"bad request"}}`, http.StatusBadRequest)
+	})
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+
+	d := runAISummaryDebate(t, de, alice, "en", 0)
+	if d.Status != "completed" {
+		t.Errorf("discussion ended %s, want completed", d.Status)
+	}
+	if !strings.Contains(d.FinalSummary, "Spaces keep diffs aligned") {
+		t.Errorf("final summary = %q, want the heuristic summary of the debate", d.FinalSummary)
+	}
+	alerts := summaryAlerts(t, de, d.ID)
+	if len(alerts) != 1 {
+		t.Fatalf("got %d summary alerts, want 1", len(alerts))
+	}
+	if alerts[0].Status != "error" || alerts[0].Metadata["summary_agent_id"] != fmt.Sprint(alice.ID) || !strings.Contains(alerts[0].Content, "Alice could not write the summary") {
+		t.Errorf("summary alert = %s %q %v", alerts[0].Status, alerts[0].Content, alerts[0].Metadata)
+	}
+}
+
+func TestStopDuringAISummary(t *testing.T) {
+	de := newTestEngine(t)
+	summarizing := make(chan struct{}, 1)
+	server, _ := newSummaryProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		summarizing <- struct{}{}
+		<-r.Context().Done()
+	})
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+
+	settings := models.DiscussionSettings{SummaryBackend: models.SummaryBackendAI}
+	discussion, err := de.RunDebate(context.Background(), "Tabs or spaces", []int64{alice.ID}, nil, nil, nil, 1, "en", models.DefaultMaxCharLimit, settings)
+	if err != nil {
+		t.Fatalf("RunDebate: %v", err)
+	}
+	endedBeforeCleanup(t, de, discussion.ID)
+	<-summarizing
+	if err := de.StopDiscussion(discussion.ID); err != nil {
+		t.Fatalf("StopDiscussion: %v", err)
+	}
+
+	var d *models.Discussion
+	waitUntil(t, "the debate to end", func() bool {
+		d, err = de.db.GetDiscussion(discussion.ID)
+		return err == nil && !d.InProgress()
+	})
+	if d.Status != "stopped" || d.EndReason != models.EndReasonStopped {
+		t.Errorf("discussion ended %s (%s), want stopped", d.Status, d.EndReason)
+	}
+	if !strings.Contains(d.FinalSummary, "Spaces keep diffs aligned") {
+		t.Errorf("final summary = %q, want the heuristic summary", d.FinalSummary)
+	}
+	// Giving up the call on purpose is not a failure
+	if alerts := summaryAlerts(t, de, d.ID); len(alerts) != 0 {
+		t.Errorf("stopping recorded %d summary alerts", len(alerts))
+	}
+}
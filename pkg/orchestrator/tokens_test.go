@@ -0,0 +1,77 @@
+package orchestrator
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/stats"
+)
+
+func TestSharedKeyCallsAreGrouped(t *testing.T) {
+	de := newTestEngine(t)
+	if err := de.db.SetSettingJSON(database.SettingAgentRetries, models.AgentRetryConfig{MaxRetries: 0, BaseDelayMs: 1, MaxDelayMs: 1}); err != nil {
+		t.Fatalf("SetSettingJSON: %v", err)
+	}
+	answering, _ := newTestProvider(t, "Spaces, always.")
+	var status atomic.Int64
+	status.Store(http.StatusTooManyRequests)
+	limited := newStatusProvider(t, &status)
+
+	// Alice and Bob share sk-test; Carol has her own key
+	alice := insertTestAgent(t, de, "Alice", answering.URL)
+	bob := insertTestAgent(t, de, "Bob", limited.URL)
+	carol := &models.Agent{Name: "Carol", ProviderType: "openai", ProviderURL: answering.URL, APIToken: "sk-carol", ModelName: "test-model", TimeoutSeconds: 10, EndpointStyle: models.EndpointStyleChatCompletions}
+	if err := de.db.InsertAgent(carol); err != nil {
+		t.Fatalf("InsertAgent: %v", err)
+	}
+	discussion := insertTestDiscussion(t, de, "running", alice, bob, carol)
+
+	for i, agent := range []*models.Agent{alice, bob, alice, carol} {
+		de.takeTurn(context.Background(), discussion, agent, i+1, "", "Your turn", "", 0, 0, runNow)
+	}
+
+	logs, err := de.db.GetDiscussionLogs(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	for _, l := range logs {
+		if l.LogType == models.LogTypeResponse && l.Metadata["token_fingerprint"] == "" {
+			t.Errorf("log %d has no token fingerprint", l.ID)
+		}
+		for _, v := range l.Metadata {
+			if v == "sk-test" || v == "sk-carol" {
+				t.Errorf("log %d metadata holds a raw token", l.ID)
+			}
+		}
+	}
+
+	calls, err := de.db.GetTokenCalls(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("GetTokenCalls: %v", err)
+	}
+	usage := stats.TokenUsage(calls)
+	byKey := make(map[string]models.TokenUsageDay)
+	for _, day := range usage {
+		byKey[day.Fingerprint] = day
+	}
+	if len(usage) != 2 {
+		t.Fatalf("usage = %+v, want one day for each of the two keys", usage)
+	}
+
+	shared := byKey[models.TokenFingerprint("sk-test")]
+	if shared.Calls != 3 || shared.Errors != 1 || shared.RateLimited != 1 || shared.TotalTokens != 16 {
+		t.Errorf("shared key = %+v, want 3 calls, 1 error, 1 rate limited and 16 tokens", shared)
+	}
+	if len(shared.AgentIDs) != 2 || shared.AgentIDs[0] != alice.ID || shared.AgentIDs[1] != bob.ID {
+		t.Errorf("shared key agents = %v, want Alice and Bob", shared.AgentIDs)
+	}
+	own := byKey[models.TokenFingerprint("sk-carol")]
+	if own.Calls != 1 || own.Errors != 0 || own.TotalTokens != 8 || len(own.AgentIDs) != 1 || own.AgentIDs[0] != carol.ID {
+		t.Errorf("Carol's key = %+v, want 1 call with 8 tokens from Carol", own)
+	}
+}
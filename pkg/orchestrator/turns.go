@@ -0,0 +1,256 @@
+package orchestrator
+
+import (
+	"context"
+	"court-table-ai/pkg/models"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// turnOutcome is what an agent's turn contributed to its round
+type turnOutcome struct {
+	answered bool   // a reply was accepted into the debate
+	accepted string // the accepted reply as stored
+	failure  string // "Name (class)" when the turn failed, was skipped or rejected
+	skipped  bool   // the agent's provider is paused or the agent was deleted
+	aborted  bool   // the debate was cancelled mid-call; nothing was recorded
+}
+
+// runNow records a turn's log entries as they are produced
+func runNow(record func()) { record() }
+
+// turnPrompt builds the prompt of an agent's turn; agentNum counts from 1
+func (de *DebateEngine) turnPrompt(discussion *models.Discussion, agentID int64, round, agentNum, totalAgents int) string {
+	prompt := de.buildPrompt(discussion, agentID)
+	if round > 1 {
+		prompt = de.buildRoundPrompt(discussion, agentID, round, agentNum, totalAgents)
+	}
+	return de.withScratchpad(discussion, agentID, prompt)
+}
+
+// roundSpeakers returns the agents speaking in round, in speaking order, see
+// DiscussionSettings.RoundSpeakers
+func roundSpeakers(discussion *models.Discussion, agents []*models.Agent, round int) []*models.Agent {
+	positions := discussion.Settings.RoundSpeakers(len(agents), round)
+	speakers := make([]*models.Agent, 0, len(positions))
+	for _, i := range positions {
+		speakers = append(speakers, agents[i])
+	}
+	return speakers
+}
+
+// takeTurn calls an agent for its turn. A reply that fails the acceptance
+// rules is kept as rejected and the turn is retried once with a nudge. Every
+// write to the discussion log is handed to defer, which runs it at once for a
+// sequential round or later, in speaking order, for a parallel one. phase,
+// when set, is recorded in the entries' metadata, and so is allowance, the
+// max_tokens an adaptive token budget gives the turn when above zero.
+func (de *DebateEngine) takeTurn(ctx context.Context, discussion *models.Discussion, agent *models.Agent, round int, phase, prompt, contextStr string, allowance int, pacing time.Duration, deferRecord func(func())) turnOutcome {
+	var (
+		out      turnOutcome
+		rejected *models.DiscussionLog
+	)
+	for attempt := 1; attempt <= 2; attempt++ {
+		attemptPrompt := prompt
+		if rejected != nil {
+			attemptPrompt = acceptanceRetryPrompt(prompt, rejected.Metadata["rejection_reason"], discussion.Settings.Acceptance)
+		}
+		opts := CallOptions{MaxTokens: compactMaxTokens(discussion), Trace: discussion.Settings.Trace}
+		if allowance > 0 {
+			opts.MaxTokens = allowance
+		}
+		response, err := de.callStreaming(ctx, discussion.ID, agent, round, attemptPrompt, contextStr, opts)
+		if err != nil && ctx.Err() != nil {
+			// The debate was stopped or force-failed mid-call; the aborted
+			// turn is not recorded
+			out.aborted = true
+			return out
+		}
+		if errors.Is(err, ErrProviderPaused) || errors.Is(err, ErrAgentDeleted) {
+			log.Printf("Skipping agent %s in round %d: %v", agent.Name, round, err)
+			deferRecord(func() { de.recordSkip(discussion.ID, agent, false, err.Error()) })
+			out.failure = fmt.Sprintf("%s (paused)", agent.Name)
+			if errors.Is(err, ErrAgentDeleted) {
+				out.failure = fmt.Sprintf("%s (deleted)", agent.Name)
+			}
+			out.skipped = true
+			return out
+		}
+
+		// Log the interaction
+		logEntry := &models.DiscussionLog{
+			DiscussionID:     discussion.ID,
+			AgentID:          agent.ID,
+			Status:           "success",
+			ResponseTime:     response.ResponseTime,
+			IsModerator:      false,
+			Stance:           discussion.StanceOf(agent.ID),
+			PromptTokens:     response.PromptTokens,
+			CompletionTokens: response.CompletionTokens,
+			Metadata: models.JSONMap{
+				"round":         strconv.Itoa(round),
+				"context_chars": strconv.Itoa(utf8.RuneCountInString(contextStr)),
+			},
+		}
+		if pacing > 0 && attempt == 1 {
+			logEntry.Metadata["pacing_ms"] = strconv.FormatInt(pacing.Milliseconds(), 10)
+		}
+		if phase != "" {
+			logEntry.Metadata["phase"] = phase
+		}
+		if allowance > 0 {
+			logEntry.Metadata["adaptive_max_tokens"] = strconv.Itoa(allowance)
+		}
+		if rejected != nil {
+			logEntry.Metadata["acceptance_retry"] = "true"
+		}
+		if discussion.Settings.RoundMode == models.RoundModeParallel {
+			logEntry.Metadata["round_mode"] = models.RoundModeParallel
+		}
+		addCallMetadata(logEntry.Metadata, response)
+
+		out.failure = ""
+		if err != nil {
+			log.Printf("Agent %s failed to respond: %v", agent.Name, err)
+			logEntry.Status = "error"
+			logEntry.Content = fmt.Sprintf("Error: %v", err)
+			// A stream that broke off keeps what was received
+			if response.Content != "" {
+				logEntry.Content += "\n\nPartial reply:\n" + response.Content
+				logEntry.Metadata["partial"] = "true"
+			}
+			out.failure = fmt.Sprintf("%s (%s)", agent.Name, errorClass(err, response.ErrorMessage))
+		} else if !response.Success {
+			log.Printf("Agent %s returned error: %s", agent.Name, response.ErrorMessage)
+			logEntry.Status = "error"
+			logEntry.Content = fmt.Sprintf("Error: %s", response.ErrorMessage)
+			out.failure = fmt.Sprintf("%s (%s)", agent.Name, errorClass(nil, response.ErrorMessage))
+		} else {
+			log.Printf("Agent %s responded successfully (%d ms)", agent.Name, response.ResponseTime)
+			// Private notes never reach the transcript or other agents
+			reply := de.keepNotes(discussion, agent.ID, round, response.Content, logEntry.Metadata)
+			content := reply
+			for k, v := range complianceMetadata(discussion, content) {
+				logEntry.Metadata[k] = v
+			}
+
+			// Strictly enforce character limit (hard truncation), never
+			// cutting through a cited URL; short replies end on a sentence
+			if compact(discussion) {
+				content = truncateAtSentence(content, discussion.MaxCharLimit)
+			} else {
+				content = truncateResponse(content, discussion.MaxCharLimit)
+			}
+			// Instructions aimed at the other agents are kept for review only
+			if guarded, stripped := stripInjections(discussion.Settings.InjectionGuard, content); stripped != nil {
+				log.Printf("Stripped %d injected line(s) from agent %s in round %d", len(stripped), agent.Name, round)
+				content = guarded
+				injectionMetadata(logEntry.Metadata, stripped)
+			}
+			citationMetadata(logEntry.Metadata, content)
+			logEntry.Content = content
+
+			if reason := rejectionReason(discussion.Settings.Acceptance, reply); reason != "" {
+				log.Printf("Rejected reply from agent %s in round %d: %s", agent.Name, round, reason)
+				logEntry.Status = "rejected"
+				logEntry.Metadata["rejection_reason"] = reason
+			} else {
+				out.answered = true
+				out.accepted = content
+			}
+		}
+
+		retryOf := rejected
+		deferRecord(func() { de.recordTurnLog(discussion.ID, agent, logEntry, response, retryOf) })
+
+		if logEntry.Status != "rejected" {
+			break
+		}
+		if rejected != nil {
+			out.failure = fmt.Sprintf("%s (rejected)", agent.Name)
+			break
+		}
+		rejected = logEntry
+	}
+	return out
+}
+
+// recordTurnLog saves and broadcasts an agent's log entry and links it to the
+// rejected entry it retries, if any
+func (de *DebateEngine) recordTurnLog(discussionID int64, agent *models.Agent, logEntry *models.DiscussionLog, response *models.AgentResponse, retryOf *models.DiscussionLog) {
+	if retryOf != nil && retryOf.ID > 0 {
+		logEntry.Metadata["retry_of"] = strconv.FormatInt(retryOf.ID, 10)
+	}
+
+	if err := de.db.InsertDiscussionLog(logEntry); err != nil {
+		log.Printf("Failed to save discussion log: %v", err)
+	} else {
+		de.touch(discussionID)
+		de.broadcast(discussionID, logEntry)
+	}
+	de.traceSlowCall(discussionID, agent, response)
+
+	if retryOf != nil && retryOf.ID > 0 && logEntry.ID > 0 {
+		retryOf.Metadata["retried_by"] = strconv.FormatInt(logEntry.ID, 10)
+		if err := de.db.UpdateDiscussionLogMetadata(retryOf.ID, retryOf.Metadata); err != nil {
+			log.Printf("Failed to link retried log %d: %v", retryOf.ID, err)
+		}
+	}
+}
+
+// parallelRound calls every agent of a round at once, all with the context of
+// the previous rounds, and returns their outcomes in speaking order. One
+// agent failing does not cancel the others. A turn's log entries are saved
+// and broadcast once it and every turn before it have finished, so the
+// transcript keeps the speaking order while replies still stream in. Every
+// turn gets the same token allowance, see takeTurn.
+func (de *DebateEngine) parallelRound(ctx context.Context, discussion *models.Discussion, agents []*models.Agent, round int, contextStr string, allowance int, pacing time.Duration) []turnOutcome {
+	outcomes := make([]turnOutcome, len(agents))
+	records := make([][]func(), len(agents))
+	finished := make(chan int, len(agents))
+
+	var wg sync.WaitGroup
+	for i, agent := range agents {
+		wg.Add(1)
+		go func(i int, agent *models.Agent) {
+			defer wg.Done()
+			defer func() { finished <- i }()
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("Turn of agent %s panicked: %v", agent.Name, r)
+					outcomes[i] = turnOutcome{failure: fmt.Sprintf("%s (error)", agent.Name)}
+				}
+			}()
+
+			// The round's pacing preceded every call but is counted once
+			turnPacing := pacing
+			if i > 0 {
+				turnPacing = 0
+			}
+			prompt := de.turnPrompt(discussion, agent.ID, round, i+1, len(agents))
+			outcomes[i] = de.takeTurn(ctx, discussion, agent, round, "", prompt, contextStr, allowance, turnPacing, func(record func()) {
+				records[i] = append(records[i], record)
+			})
+		}(i, agent)
+	}
+
+	done := make([]bool, len(agents))
+	next := 0
+	for range agents {
+		done[<-finished] = true
+		for next < len(agents) && done[next] {
+			for _, record := range records[next] {
+				record()
+			}
+			next++
+		}
+	}
+	wg.Wait()
+
+	return outcomes
+}
@@ -0,0 +1,41 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+func TestActiveSpeakersRotate(t *testing.T) {
+	de := newTestEngine(t)
+	server, _ := newTestProvider(t, "Spaces.")
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+	bob := insertTestAgent(t, de, "Bob", server.URL)
+	carol := insertTestAgent(t, de, "Carol", server.URL)
+	names := map[int64]string{alice.ID: "Alice", bob.ID: "Bob", carol.ID: "Carol"}
+
+	d := runToEnd(t, de, []*models.Agent{alice, bob, carol}, 3, models.DiscussionSettings{ActiveSpeakers: 2})
+	if d.Status != "completed" {
+		t.Fatalf("status = %s, want completed", d.Status)
+	}
+	logs, err := de.db.GetDiscussionLogs(d.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	spoke := map[string][]string{}
+	for _, l := range logs {
+		if name, ok := names[l.AgentID]; ok && l.LogType == "response" {
+			spoke[l.Metadata["round"]] = append(spoke[l.Metadata["round"]], name)
+		}
+	}
+	want := map[string][]string{"1": {"Alice", "Bob"}, "2": {"Carol", "Alice"}, "3": {"Bob", "Carol"}}
+	for round, names := range want {
+		if strings.Join(spoke[round], ",") != strings.Join(names, ",") {
+			t.Errorf("round %s speakers = %v, want %v", round, spoke[round], names)
+		}
+	}
+	if len(spoke) != len(want) {
+		t.Errorf("speakers by round = %v, want 3 rounds", spoke)
+	}
+}
@@ -0,0 +1,257 @@
+package orchestrator
+
+import (
+	"context"
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// runningDebate tracks an in-flight debate for the watchdog
+type runningDebate struct {
+	cancel       context.CancelCauseFunc
+	done         chan struct{} // closed once the debate is untracked
+	startedAt    time.Time
+	lastActivity time.Time
+	alerted      bool
+	pacing       bool
+	pacingTotal  time.Duration
+	resume       chan struct{} // set while paused, closed to resume
+}
+
+// trackDebate registers a running debate and its cancel function
+func (de *DebateEngine) trackDebate(discussionID int64, cancel context.CancelCauseFunc) {
+	de.runMu.Lock()
+	defer de.runMu.Unlock()
+
+	now := de.now()
+	de.running[discussionID] = &runningDebate{cancel: cancel, done: make(chan struct{}), startedAt: now, lastActivity: now}
+}
+
+// untrackDebate forgets a debate once it has finished
+func (de *DebateEngine) untrackDebate(discussionID int64) {
+	de.runMu.Lock()
+	defer de.runMu.Unlock()
+
+	if rd, ok := de.running[discussionID]; ok {
+		rd.cancel(nil)
+		close(rd.done)
+		delete(de.running, discussionID)
+	}
+}
+
+// stopDebate cancels a running debate on behalf of the user. It returns a
+// channel closed once the debate has recorded the stop, or nil if no debate
+// is running for the discussion.
+func (de *DebateEngine) stopDebate(discussionID int64) <-chan struct{} {
+	de.runMu.Lock()
+	defer de.runMu.Unlock()
+
+	rd, ok := de.running[discussionID]
+	if !ok {
+		return nil
+	}
+	rd.cancel(errDebateStopped)
+	return rd.done
+}
+
+// touch records progress on a debate and clears any previous stall alert
+func (de *DebateEngine) touch(discussionID int64) {
+	de.runMu.Lock()
+	defer de.runMu.Unlock()
+
+	if rd, ok := de.running[discussionID]; ok {
+		rd.lastActivity = de.now()
+		rd.alerted = false
+	}
+}
+
+// pace waits d between turns or rounds, returning early when ctx is cancelled.
+// The debate shows as pacing meanwhile and the wait counts as pacing overhead,
+// not as agent response time or activity. It returns the time waited.
+func (de *DebateEngine) pace(ctx context.Context, discussionID int64, d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+
+	de.setPacing(discussionID, true, 0)
+	start := de.now()
+	de.sleep(ctx, d)
+	elapsed := de.now().Sub(start)
+	de.setPacing(discussionID, false, elapsed)
+	return elapsed
+}
+
+// setPacing records whether a debate is pacing and adds elapsed pacing time
+func (de *DebateEngine) setPacing(discussionID int64, pacing bool, elapsed time.Duration) {
+	de.runMu.Lock()
+	defer de.runMu.Unlock()
+
+	if rd, ok := de.running[discussionID]; ok {
+		rd.pacing = pacing
+		rd.pacingTotal += elapsed
+		// The wait is not a stall; restart the idle clock once it ends
+		if !pacing {
+			rd.lastActivity = de.now()
+		}
+	}
+}
+
+// sleepContext waits d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// WatchdogConfig returns the stored watchdog configuration with defaults applied
+func (de *DebateEngine) WatchdogConfig() models.WatchdogConfig {
+	var cfg models.WatchdogConfig
+	if _, err := de.db.GetSettingJSON(database.SettingWatchdog, &cfg); err != nil {
+		log.Printf("Failed to read watchdog settings: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		cfg = models.WatchdogConfig{StallMinutes: models.DefaultWatchdogStallMinutes}
+	}
+	return cfg
+}
+
+// RunningDebates lists in-flight debates with the age of their last activity
+func (de *DebateEngine) RunningDebates() []models.RunningDebate {
+	threshold := time.Duration(de.WatchdogConfig().StallMinutes) * time.Minute
+
+	de.runMu.Lock()
+	now := de.now()
+	debates := make([]models.RunningDebate, 0, len(de.running))
+	for id, rd := range de.running {
+		age := now.Sub(rd.lastActivity)
+		debates = append(debates, models.RunningDebate{
+			DiscussionID:       id,
+			StartedAt:          rd.startedAt,
+			LastActivity:       rd.lastActivity,
+			LastActivityAgeSec: int64(age.Seconds()),
+			Stalled:            rd.resume == nil && age > threshold,
+			State:              models.DebateStateRunning,
+			PacingMs:           rd.pacingTotal.Milliseconds(),
+		})
+		switch {
+		case rd.resume != nil:
+			debates[len(debates)-1].State = models.DebateStatePaused
+		case rd.pacing:
+			debates[len(debates)-1].State = models.DebateStatePacing
+		}
+	}
+	de.runMu.Unlock()
+
+	sort.Slice(debates, func(i, j int) bool { return debates[i].DiscussionID < debates[j].DiscussionID })
+	subscribers := de.SubscriberCounts()
+	for i := range debates {
+		debates[i].Subscribers = subscribers[debates[i].DiscussionID]
+		if discussion, err := de.db.GetDiscussion(debates[i].DiscussionID); err == nil {
+			debates[i].Topic = discussion.Topic
+		}
+	}
+	return debates
+}
+
+// StartWatchdog checks for stalled debates and evaluates the agent alert
+// rules every interval until ctx is done
+func (de *DebateEngine) StartWatchdog(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				de.CheckStalledDebates()
+				de.EvaluateAlerts()
+			}
+		}
+	}()
+}
+
+// CheckStalledDebates alerts on debates with no new log entries for longer than
+// the configured threshold and, if enabled, force-fails them. Paused debates
+// are not stalled. It returns the IDs of debates flagged in this pass.
+func (de *DebateEngine) CheckStalledDebates() []int64 {
+	cfg := de.WatchdogConfig()
+	threshold := time.Duration(cfg.StallMinutes) * time.Minute
+
+	var stalled []int64
+	de.runMu.Lock()
+	now := de.now()
+	for id, rd := range de.running {
+		if rd.resume == nil && !rd.alerted && now.Sub(rd.lastActivity) > threshold {
+			rd.alerted = true
+			stalled = append(stalled, id)
+		}
+	}
+	de.runMu.Unlock()
+
+	for _, id := range stalled {
+		message := fmt.Sprintf("Watchdog: no activity for more than %d minutes", cfg.StallMinutes)
+		if cfg.ForceFail {
+			message += "; debate force-failed"
+		}
+		log.Printf("Discussion %d stalled: %s", id, message)
+
+		de.insertSystemLog(id, models.LogTypeSystem, "error", message, models.JSONMap{"alert": "watchdog"})
+		de.broadcastGlobal(&models.EngineEvent{
+			Type:         "watchdog_warning",
+			DiscussionID: id,
+			Message:      message,
+			CreatedAt:    now,
+		})
+
+		if cfg.ForceFail {
+			de.forceFail(id)
+		}
+	}
+
+	return stalled
+}
+
+// forceFail marks a stalled debate failed and releases its slot
+func (de *DebateEngine) forceFail(discussionID int64) {
+	discussion, err := de.db.GetDiscussion(discussionID)
+	if err != nil {
+		log.Printf("Watchdog failed to load discussion %d: %v", discussionID, err)
+	} else if discussion.Status == "running" {
+		if de.endDiscussion(discussion, "failed", models.EndReasonFailed, discussion.ErrorMessage) {
+			de.broadcast(discussionID, discussion)
+		}
+	}
+
+	de.untrackDebate(discussionID)
+}
+
+// insertSystemLog records an engine-generated message in the discussion log,
+// authored by the reserved system participant
+func (de *DebateEngine) insertSystemLog(discussionID int64, logType, status, content string, metadata models.JSONMap) {
+	logEntry := &models.DiscussionLog{
+		DiscussionID: discussionID,
+		AgentID:      models.SystemAgentID,
+		Content:      content,
+		Status:       status,
+		LogType:      logType,
+		Metadata:     metadata,
+	}
+
+	if err := de.db.InsertDiscussionLog(logEntry); err != nil {
+		log.Printf("Failed to save %s log: %v", logType, err)
+		return
+	}
+	de.broadcast(discussionID, logEntry)
+}
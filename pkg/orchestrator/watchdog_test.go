@@ -0,0 +1,160 @@
+package orchestrator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+)
+
+// fakeClock is a clock for the engine that only moves when told to
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock(de *DebateEngine) *fakeClock {
+	c := &fakeClock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	de.now = c.Now
+	return c
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newFrozenProvider accepts calls and never answers them until the caller
+// gives up. It counts the calls it got.
+func newFrozenProvider(t *testing.T) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var calls atomic.Int64
+	stop := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		select {
+		case <-r.Context().Done():
+		case <-stop:
+		}
+	}))
+	t.Cleanup(func() {
+		close(stop)
+		server.Close()
+	})
+	return server, &calls
+}
+
+// startFrozenDebate starts a one-round debate whose first agent never answers
+// and waits until it is stuck in that call
+func startFrozenDebate(t *testing.T, de *DebateEngine) *models.Discussion {
+	t.Helper()
+	server, calls := newFrozenProvider(t)
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+	bob := insertTestAgent(t, de, "Bob", server.URL)
+	discussion, err := de.RunDebate(context.Background(), "Tabs or spaces", []int64{alice.ID, bob.ID}, nil, nil, nil, 1, "en", models.DefaultMaxCharLimit, models.DiscussionSettings{SummaryBackend: models.SummaryBackendExtractive})
+	if err != nil {
+		t.Fatalf("RunDebate: %v", err)
+	}
+	endedBeforeCleanup(t, de, discussion.ID)
+	waitUntil(t, "the first agent call", func() bool { return calls.Load() > 0 })
+	return discussion
+}
+
+func TestWatchdogFlagsStalledDebate(t *testing.T) {
+	de := newTestEngine(t)
+	clock := newFakeClock(de)
+	events := de.SubscribeGlobal()
+	defer de.UnsubscribeGlobal(events)
+	discussion := startFrozenDebate(t, de)
+	t.Cleanup(func() { de.StopDiscussion(discussion.ID) })
+
+	clock.Advance(14 * time.Minute)
+	if stalled := de.CheckStalledDebates(); len(stalled) != 0 {
+		t.Fatalf("flagged %v before the 15 minute default threshold", stalled)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if stalled := de.CheckStalledDebates(); len(stalled) != 1 || stalled[0] != discussion.ID {
+		t.Fatalf("flagged %v, want discussion %d", stalled, discussion.ID)
+	}
+	if stalled := de.CheckStalledDebates(); len(stalled) != 0 {
+		t.Errorf("flagged %v again on the next pass", stalled)
+	}
+
+	waitUntil(t, "the watchdog warning", func() bool {
+		for {
+			select {
+			case event := <-events:
+				if e, ok := event.Data.(models.EngineEvent); ok && event.Type == "watchdog_warning" && e.DiscussionID == discussion.ID {
+					return true
+				}
+			default:
+				return false
+			}
+		}
+	})
+
+	debates := de.RunningDebates()
+	if len(debates) != 1 || !debates[0].Stalled || debates[0].LastActivityAgeSec != 16*60 {
+		t.Errorf("running debates = %+v, want one stalled for 960s", debates)
+	}
+
+	logs, err := de.db.GetDiscussionLogs(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussionLogs: %v", err)
+	}
+	var alerts int
+	for _, l := range logs {
+		if l.Metadata["alert"] == "watchdog" {
+			alerts++
+			if !strings.Contains(l.Content, "no activity for more than 15 minutes") || strings.Contains(l.Content, "force-failed") {
+				t.Errorf("watchdog log = %q", l.Content)
+			}
+		}
+	}
+	if alerts != 1 {
+		t.Errorf("got %d watchdog log entries, want 1", alerts)
+	}
+	if d, _ := de.db.GetDiscussion(discussion.ID); d.Status != "running" {
+		t.Errorf("status = %q, want the debate left running without force_fail", d.Status)
+	}
+}
+
+func TestWatchdogForceFail(t *testing.T) {
+	de := newTestEngine(t)
+	clock := newFakeClock(de)
+	if err := de.db.SetSettingJSON(database.SettingWatchdog, models.WatchdogConfig{StallMinutes: 5, ForceFail: true}); err != nil {
+		t.Fatalf("SetSettingJSON: %v", err)
+	}
+	discussion := startFrozenDebate(t, de)
+
+	clock.Advance(6 * time.Minute)
+	if stalled := de.CheckStalledDebates(); len(stalled) != 1 {
+		t.Fatalf("flagged %v, want discussion %d", stalled, discussion.ID)
+	}
+
+	d, err := de.db.GetDiscussion(discussion.ID)
+	if err != nil {
+		t.Fatalf("GetDiscussion: %v", err)
+	}
+	if d.Status != "failed" || d.EndReason != models.EndReasonFailed {
+		t.Errorf("discussion = %s (%s), want failed", d.Status, d.EndReason)
+	}
+	if debates := de.RunningDebates(); len(debates) != 0 {
+		t.Errorf("running debates = %+v, want the slot released", debates)
+	}
+}
@@ -0,0 +1,139 @@
+package orchestrator
+
+import (
+	"bytes"
+	"court-table-ai/pkg/models"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// webhookTimeout bounds a single webhook delivery
+const webhookTimeout = 10 * time.Second
+
+func init() {
+	registerFeature(FeatureWebhooks, alwaysOn)
+}
+
+// Headers sent with every webhook delivery
+const (
+	WebhookEventHeader     = "X-CourtTable-Event"
+	WebhookSignatureHeader = "X-CourtTable-Signature"
+)
+
+// SignWebhookPayload returns the signature header value for body: the
+// hex HMAC-SHA256 of the body keyed with secret, prefixed with "sha256="
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// endEvent maps the final status of a discussion to its webhook event
+func endEvent(status string) string {
+	switch status {
+	case "completed":
+		return models.WebhookEventCompleted
+	case "failed":
+		return models.WebhookEventFailed
+	case "stopped":
+		return models.WebhookEventStopped
+	}
+	return ""
+}
+
+// notifyDiscussionWebhook delivers the end of a discussion to its webhook,
+// if it has one subscribed to the outcome
+func (de *DebateEngine) notifyDiscussionWebhook(discussion *models.Discussion) {
+	hook := discussion.Settings.Webhook
+	event := endEvent(discussion.Status)
+	if hook == nil || event == "" || !hook.Wants(event) {
+		return
+	}
+	de.deliverWebhook(*hook, event, discussionPayload(discussion, event, de.now()))
+}
+
+// TestWebhook sends a sample signed payload to hook right away and returns
+// the delivery result. The sample describes discussion when one is given.
+func (de *DebateEngine) TestWebhook(hook models.DiscussionWebhook, discussion *models.Discussion) *models.WebhookDelivery {
+	payload := models.WebhookPayload{Event: models.WebhookEventTest, SentAt: de.now()}
+	if discussion != nil {
+		payload = discussionPayload(discussion, models.WebhookEventTest, de.now())
+	}
+	return de.deliverWebhook(hook, models.WebhookEventTest, payload)
+}
+
+func discussionPayload(discussion *models.Discussion, event string, now time.Time) models.WebhookPayload {
+	return models.WebhookPayload{
+		Event:           event,
+		DiscussionID:    discussion.ID,
+		Topic:           discussion.Topic,
+		Status:          discussion.Status,
+		EndReason:       discussion.EndReason,
+		CompletedRounds: discussion.CompletedRounds,
+		FinalSummary:    discussion.FinalSummary,
+		ErrorMessage:    discussion.ErrorMessage,
+		SentAt:          now,
+	}
+}
+
+// deliverWebhook posts payload to hook once and records the attempt
+func (de *DebateEngine) deliverWebhook(hook models.DiscussionWebhook, event string, payload models.WebhookPayload) *models.WebhookDelivery {
+	delivery := &models.WebhookDelivery{
+		DiscussionID: payload.DiscussionID,
+		URL:          hook.URL,
+		Event:        event,
+		CreatedAt:    de.now(),
+	}
+
+	if err := de.postWebhook(hook, event, payload, delivery); err != nil {
+		delivery.Error = err.Error()
+		log.Printf("Webhook %s to %s failed: %v", event, hook.URL, err)
+	}
+
+	if err := de.db.InsertWebhookDelivery(delivery); err != nil {
+		log.Printf("Failed to record webhook delivery: %v", err)
+	}
+	return delivery
+}
+
+// postWebhook sends the request and fills in the status code, latency and
+// outcome of delivery
+func (de *DebateEngine) postWebhook(hook models.DiscussionWebhook, event string, payload models.WebhookPayload, delivery *models.WebhookDelivery) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, event)
+	if hook.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(hook.Secret, body))
+	}
+
+	start := time.Now()
+	resp, err := de.webhookClient.Do(req)
+	delivery.LatencyMs = int(time.Since(start).Milliseconds())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	delivery.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+	}
+	delivery.Delivered = true
+	return nil
+}
@@ -0,0 +1,156 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+// webhookPost is a request received by a webhook receiver
+type webhookPost struct {
+	event     string
+	signature string
+	body      []byte
+	payload   models.WebhookPayload
+}
+
+// newWebhookReceiver serves a webhook endpoint answering status and returns
+// the posts it got
+func newWebhookReceiver(t *testing.T, status int) (*httptest.Server, func() []webhookPost) {
+	t.Helper()
+	var (
+		mu    sync.Mutex
+		posts []webhookPost
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		post := webhookPost{event: r.Header.Get(WebhookEventHeader), signature: r.Header.Get(WebhookSignatureHeader), body: body}
+		if err := json.Unmarshal(body, &post.payload); err != nil {
+			t.Errorf("webhook body is not a payload: %v", err)
+		}
+		mu.Lock()
+		posts = append(posts, post)
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []webhookPost {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]webhookPost(nil), posts...)
+	}
+}
+
+func TestTestWebhookReportsDelivery(t *testing.T) {
+	de := newTestEngine(t)
+	server, posts := newWebhookReceiver(t, http.StatusNoContent)
+
+	delivery := de.TestWebhook(models.DiscussionWebhook{URL: server.URL, Secret: "s3cret"}, nil)
+	if !delivery.Delivered || delivery.StatusCode != http.StatusNoContent || delivery.Error != "" || delivery.Event != models.WebhookEventTest {
+		t.Errorf("delivery = %+v, want delivered with 204", delivery)
+	}
+	got := posts()
+	if len(got) != 1 {
+		t.Fatalf("receiver got %d posts, want 1", len(got))
+	}
+	if got[0].event != models.WebhookEventTest || got[0].payload.Event != models.WebhookEventTest {
+		t.Errorf("post event = %q / %q, want %q", got[0].event, got[0].payload.Event, models.WebhookEventTest)
+	}
+	if want := SignWebhookPayload("s3cret", got[0].body); got[0].signature != want {
+		t.Errorf("signature = %q, want %q", got[0].signature, want)
+	}
+	// A test fire without a discussion is recorded on its own
+	if delivery.ID == 0 {
+		t.Error("the test fire was not recorded")
+	}
+}
+
+func TestTestWebhookReportsFailures(t *testing.T) {
+	de := newTestEngine(t)
+	failing, _ := newWebhookReceiver(t, http.StatusBadGateway)
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	delivery := de.TestWebhook(models.DiscussionWebhook{URL: failing.URL}, nil)
+	if delivery.Delivered || delivery.StatusCode != http.StatusBadGateway || delivery.Error != "endpoint returned status 502" {
+		t.Errorf("delivery to a failing endpoint = %+v", delivery)
+	}
+
+	delivery = de.TestWebhook(models.DiscussionWebhook{URL: down.URL}, nil)
+	if delivery.Delivered || delivery.StatusCode != 0 || delivery.Error == "" {
+		t.Errorf("delivery to a closed endpoint = %+v, want no status and an error", delivery)
+	}
+}
+
+func TestUnsignedWebhook(t *testing.T) {
+	de := newTestEngine(t)
+	server, posts := newWebhookReceiver(t, http.StatusOK)
+
+	de.TestWebhook(models.DiscussionWebhook{URL: server.URL}, nil)
+	if got := posts(); len(got) != 1 || got[0].signature != "" {
+		t.Errorf("posts without a secret = %+v, want one unsigned", got)
+	}
+}
+
+func TestDiscussionWebhookOnCompletion(t *testing.T) {
+	de := newTestEngine(t)
+	receiver, posts := newWebhookReceiver(t, http.StatusOK)
+	server := newScriptedProvider(t, "Spaces, always.", 5, 3, 100)
+	alice := insertTestAgent(t, de, "Alice", server.URL)
+
+	hook := &models.DiscussionWebhook{URL: receiver.URL, Secret: "s3cret", Events: []string{models.WebhookEventCompleted}}
+	d := runToEnd(t, de, []*models.Agent{alice}, 1, models.DiscussionSettings{Webhook: hook})
+	waitUntil(t, "the webhook delivery to be recorded", func() bool {
+		stored, err := de.db.GetWebhookDeliveries(d.ID)
+		return err == nil && len(stored) == 1
+	})
+
+	got := posts()
+	if len(got) != 1 {
+		t.Fatalf("receiver got %d posts, want 1", len(got))
+	}
+	p := got[0].payload
+	if got[0].event != models.WebhookEventCompleted || p.DiscussionID != d.ID || p.Status != "completed" || p.EndReason != models.EndReasonMaxRounds || p.CompletedRounds != 1 {
+		t.Errorf("payload = %+v, want the completed discussion %d", p, d.ID)
+	}
+	if got[0].signature != SignWebhookPayload("s3cret", got[0].body) {
+		t.Error("completion payload is not signed with the discussion's secret")
+	}
+}
+
+func TestDiscussionWebhookEvents(t *testing.T) {
+	tests := []struct {
+		status string
+		events []string
+		want   string
+	}{
+		{"completed", nil, models.WebhookEventCompleted},
+		{"failed", nil, models.WebhookEventFailed},
+		{"stopped", nil, models.WebhookEventStopped},
+		{"stopped", []string{models.WebhookEventStopped}, models.WebhookEventStopped},
+		{"completed", []string{models.WebhookEventFailed}, ""},
+		{"running", nil, ""},
+	}
+	for _, tt := range tests {
+		de := newTestEngine(t)
+		receiver, posts := newWebhookReceiver(t, http.StatusOK)
+		discussion := &models.Discussion{ID: 1, Status: tt.status, Settings: models.DiscussionSettings{Webhook: &models.DiscussionWebhook{URL: receiver.URL, Events: tt.events}}}
+
+		de.notifyDiscussionWebhook(discussion)
+		got := posts()
+		if tt.want == "" {
+			if len(got) != 0 {
+				t.Errorf("status %s with events %v sent %d posts, want none", tt.status, tt.events, len(got))
+			}
+			continue
+		}
+		if len(got) != 1 || got[0].event != tt.want {
+			t.Errorf("status %s with events %v sent %+v, want one %s", tt.status, tt.events, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Package seed fills an empty database with demo data so a first run has
+// something to show without configuring real providers.
+package seed
+
+import (
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+	"court-table-ai/pkg/version"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// demoProviderURL is where the demo agents expect a local Ollama server. The
+// seeded transcript reads fine without it; new debates need it running.
+const demoProviderURL = "http://localhost:11434"
+
+// demoTurn is one entry of the seeded transcript
+type demoTurn struct {
+	speaker int // index into the seeded agents
+	phase   string
+	round   int
+	content string
+}
+
+// Demo seeds the database when it is empty and has not been seeded before.
+// It reports whether anything was written.
+func Demo(db *database.DB) (bool, error) {
+	if _, seeded, err := db.GetSetting(database.SettingDemoSeeded); err != nil || seeded {
+		return false, err
+	}
+	empty, err := db.IsEmpty()
+	if err != nil || !empty {
+		return false, err
+	}
+
+	agents := []*models.Agent{
+		{Name: "Optimist", ProviderType: "ollama", ProviderURL: demoProviderURL, ModelName: "llama3.2", TimeoutSeconds: 60},
+		{Name: "Skeptic", ProviderType: "ollama", ProviderURL: demoProviderURL, ModelName: "mistral", TimeoutSeconds: 60},
+		{Name: "Chair", ProviderType: "ollama", ProviderURL: demoProviderURL, ModelName: "llama3.2", TimeoutSeconds: 60},
+	}
+	for _, agent := range agents {
+		if err := db.InsertAgent(agent); err != nil {
+			return false, err
+		}
+	}
+	moderatorID := agents[2].ID
+
+	discussion := &models.Discussion{
+		Topic:           "Should small teams adopt a four-day work week?",
+		Status:          "completed",
+		AgentIDs:        models.JSONSlice[int64]{agents[0].ID, agents[1].ID},
+		ModeratorID:     &moderatorID,
+		MaxRounds:       2,
+		Language:        models.DefaultLanguage,
+		MaxCharLimit:    1000,
+		AppVersion:      version.Version,
+		CompletedRounds: 2,
+		EndReason:       models.EndReasonMaxRounds,
+		FinalSummary: "Both participants agreed that a four-day week can work for small teams when " +
+			"output, not hours, is measured. The Optimist stressed retention and focus; the Skeptic " +
+			"stressed customer coverage and the risk of compressed, longer days. Recommended next " +
+			"step: a time-boxed trial with agreed metrics and a staggered schedule.",
+	}
+	if err := db.InsertDiscussion(discussion); err != nil {
+		return false, err
+	}
+
+	for _, turn := range demoTranscript {
+		agent := agents[turn.speaker]
+		entry := &models.DiscussionLog{
+			DiscussionID: discussion.ID,
+			AgentID:      agent.ID,
+			Status:       "success",
+			ResponseTime: 1200 + 350*len(turn.content)/100,
+			IsModerator:  turn.phase != "",
+			Metadata:     models.JSONMap{},
+			Content:      turn.content,
+		}
+		if turn.phase != "" {
+			entry.Metadata["moderator_phase"] = turn.phase
+		} else {
+			entry.Metadata["round"] = strconv.Itoa(turn.round)
+		}
+		if err := db.InsertDiscussionLog(entry); err != nil {
+			return false, err
+		}
+	}
+
+	if err := db.SetSetting(database.SettingDemoSeeded, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return false, fmt.Errorf("failed to record demo seed: %w", err)
+	}
+	return true, nil
+}
+
+// demoTranscript is a finished two-round debate with an opening, a round
+// summary and closing remarks from the moderator
+var demoTranscript = []demoTurn{
+	{speaker: 2, phase: "opening", content: "[Moderator - Opening Remarks]\n" +
+		"Welcome. Today we ask whether small teams should move to a four-day work week. " +
+		"Please argue from concrete effects on output, people and customers."},
+	{speaker: 0, round: 1, content: "A four-day week forces prioritisation. Trials in the UK and Iceland " +
+		"reported stable or higher output with lower burnout, and small teams feel turnover most: " +
+		"losing one engineer can cost months. An extra day of rest is a cheap retention tool."},
+	{speaker: 1, round: 1, content: "Those trials were mostly self-selected companies that expected it to work. " +
+		"A five-person team cannot absorb a customer emergency on the fifth day, and many teams simply " +
+		"compress forty hours into four long days, which raises error rates rather than lowering them."},
+	{speaker: 2, phase: "round_summary", content: "[Moderator - Round Summary]\n" +
+		"Round 1: the Optimist argues retention and focus; the Skeptic questions the evidence and " +
+		"raises coverage and compressed-hours risks."},
+	{speaker: 0, round: 2, content: "Coverage is solvable with staggered days off, and the point is a " +
+		"reduced load, not compressed hours. I would propose a three-month trial with agreed metrics: " +
+		"cycle time, incident count and customer response time."},
+	{speaker: 1, round: 2, content: "A staggered, time-boxed trial with metrics defined up front addresses " +
+		"most of my concerns. I would add an explicit rollback condition if response times slip, so the " +
+		"trial is an experiment rather than a one-way door."},
+	{speaker: 2, phase: "closing", content: "[Moderator - Closing Remarks]\n" +
+		"The debate converged on a measured trial: staggered days off, metrics agreed in advance and a " +
+		"clear rollback condition. Thank you both."},
+}
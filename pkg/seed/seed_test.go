@@ -0,0 +1,98 @@
+package seed
+
+import (
+	"path/filepath"
+	"testing"
+
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+)
+
+func newTestDB(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.CreateTables(); err != nil {
+		t.Fatalf("CreateTables: %v", err)
+	}
+	return db
+}
+
+// counts returns the number of agents, discussions and log entries
+func counts(t *testing.T, db *database.DB) [3]int {
+	t.Helper()
+	var c [3]int
+	for i, table := range []string{"agents", "discussions", "discussion_logs"} {
+		if err := db.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&c[i]); err != nil {
+			t.Fatalf("counting %s: %v", table, err)
+		}
+	}
+	return c
+}
+
+func TestDemoSeedsOnce(t *testing.T) {
+	db := newTestDB(t)
+
+	seeded, err := Demo(db)
+	if err != nil || !seeded {
+		t.Fatalf("first Demo = %v, %v; want a seed", seeded, err)
+	}
+	first := counts(t, db)
+	if first[0] != 3 || first[1] != 1 || first[2] != len(demoTranscript) {
+		t.Fatalf("seeded %d agents, %d discussions, %d logs; want 3, 1, %d", first[0], first[1], first[2], len(demoTranscript))
+	}
+	if _, ok, err := db.GetSetting(database.SettingDemoSeeded); err != nil || !ok {
+		t.Errorf("seed marker set = %v, %v; want it recorded", ok, err)
+	}
+
+	seeded, err = Demo(db)
+	if err != nil || seeded {
+		t.Fatalf("second Demo = %v, %v; want a skip", seeded, err)
+	}
+	if again := counts(t, db); again != first {
+		t.Errorf("second Demo changed counts from %v to %v", first, again)
+	}
+}
+
+func TestDemoSkipsNonEmptyDatabase(t *testing.T) {
+	db := newTestDB(t)
+	agent := &models.Agent{Name: "Mine", ProviderType: models.ProviderOpenAI, ProviderURL: "https://api.openai.com/v1", ModelName: "gpt-4o", TimeoutSeconds: 30}
+	if err := db.InsertAgent(agent); err != nil {
+		t.Fatalf("InsertAgent: %v", err)
+	}
+
+	seeded, err := Demo(db)
+	if err != nil || seeded {
+		t.Fatalf("Demo = %v, %v; want a skip", seeded, err)
+	}
+	if got := counts(t, db); got != [3]int{1, 0, 0} {
+		t.Errorf("counts after skipped seed = %v, want only the existing agent", got)
+	}
+	if _, ok, _ := db.GetSetting(database.SettingDemoSeeded); ok {
+		t.Errorf("skipped seed recorded the marker")
+	}
+}
+
+func TestDemoSkipsAfterDataIsCleared(t *testing.T) {
+	db := newTestDB(t)
+	if _, err := Demo(db); err != nil {
+		t.Fatalf("Demo: %v", err)
+	}
+	for _, table := range []string{"discussion_logs", "discussions", "agents"} {
+		if _, err := db.Exec(`DELETE FROM ` + table); err != nil {
+			t.Fatalf("clearing %s: %v", table, err)
+		}
+	}
+
+	// The marker outlives the data, so an emptied database stays empty
+	seeded, err := Demo(db)
+	if err != nil || seeded {
+		t.Fatalf("Demo = %v, %v; want a skip", seeded, err)
+	}
+	if got := counts(t, db); got != [3]int{} {
+		t.Errorf("counts = %v, want an empty database", got)
+	}
+}
@@ -0,0 +1,114 @@
+package stats
+
+import (
+	"court-table-ai/pkg/models"
+	"math"
+	"sort"
+	"strings"
+)
+
+// costPrecision rounds estimated costs to a millionth of a dollar
+const costPrecision = 1e6
+
+// FindPricing returns the pricing of a provider and model, falling back to
+// the provider's "*" entry, or nil when neither exists. Models match
+// regardless of case.
+func FindPricing(pricing []*models.ModelPricing, provider, model string) *models.ModelPricing {
+	var fallback *models.ModelPricing
+	for _, p := range pricing {
+		if p.Provider != provider {
+			continue
+		}
+		if strings.EqualFold(p.Model, model) {
+			return p
+		}
+		if p.Model == models.PricingAnyModel {
+			fallback = p
+		}
+	}
+	return fallback
+}
+
+// EstimateCosts sets EstimatedCost on each agent and moderator entry of a
+// discussion and returns the per-agent and total cost. The provider and model
+// recorded with a call are used, or the agent's current ones for entries
+// logged before they were recorded. A successful call without token usage or
+// with an unpriced model has no cost, and neither has any total including
+// it; a failed call without usage costs nothing.
+func EstimateCosts(logs []*models.DiscussionLog, agents map[int64]*models.Agent, pricing []*models.ModelPricing) models.DiscussionCost {
+	report := models.DiscussionCost{Currency: "USD", Agents: []models.AgentCost{}, UnpricedModels: []string{}}
+	byAgent := make(map[int64]*models.AgentCost)
+	sums := make(map[int64]float64)
+	var order []int64
+	unpriced := make(map[string]bool)
+
+	for _, l := range logs {
+		if l.IsSystem() || l.LogType != models.LogTypeResponse {
+			continue
+		}
+		agent, ok := byAgent[l.AgentID]
+		if !ok {
+			agent = &models.AgentCost{AgentID: l.AgentID}
+			byAgent[l.AgentID] = agent
+			order = append(order, l.AgentID)
+		}
+		agent.Calls++
+		agent.PromptTokens += l.PromptTokens
+		agent.CompletionTokens += l.CompletionTokens
+
+		l.EstimatedCost = nil
+		if l.PromptTokens == 0 && l.CompletionTokens == 0 {
+			if l.Status == "success" || l.Status == "rejected" {
+				agent.UnpricedCalls++
+			} else {
+				l.EstimatedCost = roundCost(0)
+			}
+			continue
+		}
+
+		provider, model := l.Metadata["provider"], l.Metadata["model"]
+		if provider == "" {
+			if a := agents[l.AgentID]; a != nil {
+				provider, model = a.EffectiveProviderType(), a.ModelName
+			}
+		}
+		p := FindPricing(pricing, provider, model)
+		if p == nil {
+			agent.UnpricedCalls++
+			if provider != "" {
+				unpriced[provider+"/"+model] = true
+			}
+			continue
+		}
+		cost := p.Cost(l.PromptTokens, l.CompletionTokens)
+		l.EstimatedCost = roundCost(cost)
+		sums[l.AgentID] += cost
+	}
+
+	var total float64
+	complete := true
+	for _, id := range order {
+		agent := byAgent[id]
+		if agent.UnpricedCalls == 0 {
+			agent.Cost = roundCost(sums[id])
+			total += sums[id]
+		} else {
+			complete = false
+		}
+		report.Agents = append(report.Agents, *agent)
+	}
+	if complete {
+		report.TotalCost = roundCost(total)
+	}
+
+	for key := range unpriced {
+		report.UnpricedModels = append(report.UnpricedModels, key)
+	}
+	sort.Strings(report.UnpricedModels)
+	return report
+}
+
+func roundCost(cost float64) *float64 {
+	rounded := math.Round(cost*costPrecision) / costPrecision
+	return &rounded
+}
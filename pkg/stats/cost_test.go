@@ -0,0 +1,103 @@
+package stats
+
+import (
+	"reflect"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+var testPricing = []*models.ModelPricing{
+	{Provider: models.ProviderOpenAI, Model: "gpt-4o", InputPer1K: 0.005, OutputPer1K: 0.015},
+	{Provider: models.ProviderOpenAI, Model: models.PricingAnyModel, InputPer1K: 0.001, OutputPer1K: 0.002},
+	{Provider: models.ProviderAnthropic, Model: "claude-x", InputPer1K: 0.003, OutputPer1K: 0.015},
+}
+
+func TestFindPricing(t *testing.T) {
+	tests := []struct {
+		provider, model string
+		want            *models.ModelPricing
+	}{
+		{models.ProviderOpenAI, "gpt-4o", testPricing[0]},
+		{models.ProviderOpenAI, "GPT-4o", testPricing[0]},
+		{models.ProviderOpenAI, "gpt-mini", testPricing[1]},
+		{models.ProviderAnthropic, "claude-x", testPricing[2]},
+		{models.ProviderAnthropic, "claude-y", nil},
+		{models.ProviderOllama, "gpt-4o", nil},
+	}
+	for _, tt := range tests {
+		if got := FindPricing(testPricing, tt.provider, tt.model); got != tt.want {
+			t.Errorf("FindPricing(%s, %s) = %+v, want %+v", tt.provider, tt.model, got, tt.want)
+		}
+	}
+}
+
+func costOf(c *float64) interface{} {
+	if c == nil {
+		return nil
+	}
+	return *c
+}
+
+func TestEstimateCosts(t *testing.T) {
+	agents := map[int64]*models.Agent{
+		1: {ID: 1, ProviderType: models.ProviderOpenAI, ModelName: "gpt-4o"},
+		2: {ID: 2, ProviderType: models.ProviderAnthropic, ModelName: "claude-x"},
+	}
+	logs := []*models.DiscussionLog{
+		// 1000 prompt and 2000 completion tokens of gpt-4o: 0.005 + 0.03
+		{AgentID: 1, LogType: models.LogTypeResponse, Status: "success", PromptTokens: 1000, CompletionTokens: 2000},
+		// The recorded model wins over the agent's current one
+		{AgentID: 1, LogType: models.LogTypeResponse, Status: "success", PromptTokens: 1000, Metadata: models.JSONMap{"provider": "openai", "model": "gpt-mini"}},
+		// A failed call without usage costs nothing
+		{AgentID: 1, LogType: models.LogTypeResponse, Status: "error"},
+		{AgentID: 2, LogType: models.LogTypeResponse, Status: "success", PromptTokens: 2000, CompletionTokens: 1000},
+		{AgentID: models.SystemAgentID, LogType: models.LogTypeResponse, Status: "success", PromptTokens: 1000},
+	}
+
+	report := EstimateCosts(logs, agents, testPricing)
+	if report.Currency != "USD" || costOf(report.TotalCost) != 0.057 {
+		t.Errorf("total = %v %s, want 0.057 USD", costOf(report.TotalCost), report.Currency)
+	}
+	want := []interface{}{0.035, 0.001, 0.0, 0.021, nil}
+	for i, l := range logs {
+		if got := costOf(l.EstimatedCost); got != want[i] {
+			t.Errorf("entry %d cost = %v, want %v", i, got, want[i])
+		}
+	}
+	if len(report.Agents) != 2 || costOf(report.Agents[0].Cost) != 0.036 || report.Agents[0].Calls != 3 || costOf(report.Agents[1].Cost) != 0.021 {
+		t.Errorf("agents = %+v", report.Agents)
+	}
+	if len(report.UnpricedModels) != 0 {
+		t.Errorf("unpriced models = %v, want none", report.UnpricedModels)
+	}
+}
+
+func TestEstimateCostsUnknownModelIsNull(t *testing.T) {
+	agents := map[int64]*models.Agent{
+		1: {ID: 1, ProviderType: models.ProviderOpenAI, ModelName: "gpt-4o"},
+		2: {ID: 2, ProviderType: models.ProviderOllama, ModelName: "llama3"},
+	}
+	logs := []*models.DiscussionLog{
+		{AgentID: 1, LogType: models.LogTypeResponse, Status: "success", PromptTokens: 1000},
+		{AgentID: 2, LogType: models.LogTypeResponse, Status: "success", PromptTokens: 1000, CompletionTokens: 500},
+		// A reply without usage cannot be priced either
+		{AgentID: 1, LogType: models.LogTypeResponse, Status: "success"},
+	}
+
+	report := EstimateCosts(logs, agents, testPricing)
+	if report.TotalCost != nil {
+		t.Errorf("total = %v, want null with unpriced calls", *report.TotalCost)
+	}
+	if logs[1].EstimatedCost != nil || logs[2].EstimatedCost != nil {
+		t.Error("an unpriced call has a cost")
+	}
+	for _, a := range report.Agents {
+		if a.Cost != nil || a.UnpricedCalls != 1 {
+			t.Errorf("agent %d cost = %v with %d unpriced calls, want null with 1", a.AgentID, costOf(a.Cost), a.UnpricedCalls)
+		}
+	}
+	if want := []string{"ollama/llama3"}; !reflect.DeepEqual(report.UnpricedModels, want) {
+		t.Errorf("unpriced models = %v, want %v", report.UnpricedModels, want)
+	}
+}
@@ -0,0 +1,221 @@
+package stats
+
+import (
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+	"math"
+	"sort"
+	"time"
+)
+
+// EstimateHistoryWindow is how far back the reply sizes used by estimates look
+const EstimateHistoryWindow = 30 * 24 * time.Hour
+
+// Assumptions of discussion estimates, in tokens unless noted
+const (
+	// estimateCharsPerToken converts character limits and topics to tokens
+	estimateCharsPerToken = 4
+	// estimatePromptOverhead covers the system prompt and instructions sent
+	// with every call
+	estimatePromptOverhead = 300
+	// The judge and the consensus check answer with short replies whatever
+	// the character limit
+	estimateVerdictTokens   = 300
+	estimateConsensusTokens = 50
+)
+
+// EstimatePlan describes a discussion to estimate before it runs
+type EstimatePlan struct {
+	Topic        string
+	Agents       []*models.Agent // in speaking order
+	Moderator    *models.Agent   // nil without a moderator
+	Judge        *models.Agent   // nil without a judge
+	MaxRounds    int
+	MaxCharLimit int
+	Settings     models.DiscussionSettings
+}
+
+// estimateTally adds up the calls of one agent or phase
+type estimateTally struct {
+	models.CallEstimate
+	cost     float64
+	unpriced bool
+}
+
+func (t *estimateTally) add(promptTokens, completionTokens int, pricing *models.ModelPricing) {
+	t.Calls++
+	t.PromptTokens += promptTokens
+	t.CompletionTokens += completionTokens
+	if pricing == nil {
+		t.unpriced = true
+		return
+	}
+	t.cost += pricing.Cost(promptTokens, completionTokens)
+}
+
+func (t *estimateTally) result() models.CallEstimate {
+	estimate := t.CallEstimate
+	if !t.unpriced {
+		estimate.Cost = roundCost(t.cost)
+	}
+	return estimate
+}
+
+// EstimateDiscussion estimates the calls, tokens and cost of a discussion,
+// assuming every round runs and every call succeeds on its first attempt.
+// It follows the engine's schedule: one turn per agent and round in either
+// round mode, and with a moderator the opening, a comment between turns in
+// sequential rounds, a summary per round and the closing. The consensus check
+// runs after every round but the last, and the lightning round's closing
+// statements precede the moderator's closing; then come the judge and the AI
+// summary.
+//
+// Replies are sized from an agent's average in history, or the character
+// limit when it has none. Prompts carry a fixed overhead plus the context the
+// engine sends: the earlier replies an agent sees, the reply a moderator
+// comments on, a round for the consensus check and the full transcript for
+// the judge and the summary.
+func EstimateDiscussion(plan EstimatePlan, history map[int64]models.AgentTokenHistory, pricing []*models.ModelPricing) models.DiscussionEstimate {
+	limitTokens := plan.MaxCharLimit / estimateCharsPerToken
+	overhead := estimatePromptOverhead + len([]rune(plan.Topic))/estimateCharsPerToken
+	parallel := plan.Settings.RoundMode == models.RoundModeParallel
+
+	reply := func(agent *models.Agent) int {
+		if h, ok := history[agent.ID]; ok && h.Calls > 0 {
+			return int(math.Round(h.AvgCompletionTokens))
+		}
+		return limitTokens
+	}
+	// contextShare is what a reply adds to the context later agents see
+	contextShare := func(tokens int) int {
+		if per := plan.Settings.PerTurnContextChars; per > 0 {
+			return min(tokens, per/estimateCharsPerToken)
+		}
+		return tokens
+	}
+
+	byAgent := make(map[int64]*estimateTally)
+	var agentOrder []int64
+	byPhase := make(map[string]*estimateTally)
+	var phaseOrder []string
+	unpriced := make(map[string]bool)
+	var total estimateTally
+
+	add := func(agent *models.Agent, phase string, promptTokens, completionTokens int) {
+		p := FindPricing(pricing, agent.EffectiveProviderType(), agent.ModelName)
+		if p == nil {
+			unpriced[agent.EffectiveProviderType()+"/"+agent.ModelName] = true
+		}
+		if byAgent[agent.ID] == nil {
+			byAgent[agent.ID] = &estimateTally{}
+			agentOrder = append(agentOrder, agent.ID)
+		}
+		if byPhase[phase] == nil {
+			byPhase[phase] = &estimateTally{}
+			phaseOrder = append(phaseOrder, phase)
+		}
+		byAgent[agent.ID].add(promptTokens, completionTokens, p)
+		byPhase[phase].add(promptTokens, completionTokens, p)
+		total.add(promptTokens, completionTokens, p)
+	}
+
+	moderator := plan.Moderator
+	if moderator != nil {
+		add(moderator, models.EstimatePhaseOpening, overhead, reply(moderator))
+	}
+
+	// Tokens of the context agents see and of the untruncated transcript
+	var context, transcript int
+	for round := 1; round <= plan.MaxRounds; round++ {
+		roundStart, roundTokens := context, 0
+		positions := plan.Settings.RoundSpeakers(len(plan.Agents), round)
+		for i, position := range positions {
+			agent := plan.Agents[position]
+			seen := context
+			if parallel {
+				// Agents of a parallel round see the previous rounds only
+				seen = roundStart
+			}
+			tokens := reply(agent)
+			add(agent, models.EstimatePhaseTurn, overhead+seen, tokens)
+			context += contextShare(tokens)
+			transcript += tokens
+			roundTokens += tokens
+
+			if moderator != nil && !parallel && i < len(positions)-1 {
+				add(moderator, models.EstimatePhaseInterim, overhead+tokens, reply(moderator))
+			}
+		}
+		if moderator != nil {
+			add(moderator, models.EstimatePhaseRoundSummary, overhead, reply(moderator))
+		}
+		if plan.Settings.EnableConsensusCheck && round < plan.MaxRounds {
+			if checker := estimateSummarizer(plan); checker != nil {
+				add(checker, models.EstimatePhaseConsensusCheck, overhead+roundTokens, estimateConsensusTokens)
+			}
+		}
+	}
+
+	if plan.Settings.LightningRound {
+		statementTokens := plan.Settings.LightningLimit() / estimateCharsPerToken
+		for _, agent := range plan.Agents {
+			tokens := min(reply(agent), statementTokens)
+			add(agent, models.EstimatePhaseClosingStatement, overhead+context, tokens)
+			transcript += tokens
+		}
+	}
+	if moderator != nil {
+		add(moderator, models.EstimatePhaseClosing, overhead, reply(moderator))
+	}
+	if plan.Judge != nil {
+		add(plan.Judge, models.EstimatePhaseJudge, overhead+transcript, estimateVerdictTokens)
+	}
+	if backend := plan.Settings.SummaryBackend; backend == "" || backend == models.SummaryBackendAI {
+		limit := plan.Settings.SummaryCharLimit
+		if limit == 0 {
+			limit = plan.MaxCharLimit
+		}
+		if summarizer := estimateSummarizer(plan); summarizer != nil {
+			add(summarizer, models.EstimatePhaseSummary, overhead+transcript, limit/estimateCharsPerToken)
+		}
+	}
+
+	estimate := models.DiscussionEstimate{
+		CallEstimate:   total.result(),
+		Currency:       "USD",
+		Agents:         []models.AgentEstimate{},
+		Phases:         []models.PhaseEstimate{},
+		UnpricedModels: []string{},
+	}
+	estimate.TotalTokens = estimate.PromptTokens + estimate.CompletionTokens
+	for _, id := range agentOrder {
+		_, historical := history[id]
+		estimate.Agents = append(estimate.Agents, models.AgentEstimate{AgentID: id, CallEstimate: byAgent[id].result(), Historical: historical})
+	}
+	for _, phase := range phaseOrder {
+		estimate.Phases = append(estimate.Phases, models.PhaseEstimate{Phase: phase, CallEstimate: byPhase[phase].result()})
+	}
+	for key := range unpriced {
+		estimate.UnpricedModels = append(estimate.UnpricedModels, key)
+	}
+	sort.Strings(estimate.UnpricedModels)
+	return estimate
+}
+
+// estimateSummarizer is the agent the engine asks for the summary and the
+// consensus check: the moderator, or else the first agent
+func estimateSummarizer(plan EstimatePlan) *models.Agent {
+	if plan.Moderator != nil {
+		return plan.Moderator
+	}
+	if len(plan.Agents) > 0 {
+		return plan.Agents[0]
+	}
+	return nil
+}
+
+// LoadTokenHistory returns the average reply size of every agent's calls
+// stored in the database within EstimateHistoryWindow
+func LoadTokenHistory(db *database.DB) (map[int64]models.AgentTokenHistory, error) {
+	return db.GetAgentTokenHistory(time.Now().Add(-EstimateHistoryWindow))
+}
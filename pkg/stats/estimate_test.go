@@ -0,0 +1,215 @@
+package stats
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+// Every estimate below uses an empty topic and a 1000 character limit, so a
+// prompt starts at 300 tokens and a reply without history is 250 tokens
+var (
+	estimateAlice = &models.Agent{ID: 1, ProviderType: models.ProviderOpenAI, ModelName: "gpt-4o"}
+	estimateBob   = &models.Agent{ID: 2, ProviderType: models.ProviderAnthropic, ModelName: "claude-x"}
+	estimateSage  = &models.Agent{ID: 3, ProviderType: models.ProviderOpenAI, ModelName: "gpt-mini"}
+)
+
+func usdCost(v float64) *float64 { return &v }
+
+func TestEstimateDiscussionSequential(t *testing.T) {
+	plan := EstimatePlan{
+		Agents:       []*models.Agent{estimateAlice, estimateBob},
+		MaxRounds:    2,
+		MaxCharLimit: 1000,
+		Settings:     models.DiscussionSettings{SummaryBackend: models.SummaryBackendExtractive},
+	}
+	got := EstimateDiscussion(plan, nil, testPricing)
+
+	// Each turn sees every reply before it: 300, 550, 800 and 1050 tokens
+	want := models.DiscussionEstimate{
+		CallEstimate: models.CallEstimate{Calls: 4, PromptTokens: 2700, CompletionTokens: 1000, Cost: usdCost(0.0253)},
+		TotalTokens:  3700,
+		Currency:     "USD",
+		Agents: []models.AgentEstimate{
+			{AgentID: 1, CallEstimate: models.CallEstimate{Calls: 2, PromptTokens: 1100, CompletionTokens: 500, Cost: usdCost(0.013)}},
+			{AgentID: 2, CallEstimate: models.CallEstimate{Calls: 2, PromptTokens: 1600, CompletionTokens: 500, Cost: usdCost(0.0123)}},
+		},
+		Phases: []models.PhaseEstimate{
+			{Phase: models.EstimatePhaseTurn, CallEstimate: models.CallEstimate{Calls: 4, PromptTokens: 2700, CompletionTokens: 1000, Cost: usdCost(0.0253)}},
+		},
+		UnpricedModels: []string{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("estimate =\n%s\nwant\n%s", describeEstimate(got), describeEstimate(want))
+	}
+}
+
+func TestEstimateDiscussionParallel(t *testing.T) {
+	plan := EstimatePlan{
+		Agents:       []*models.Agent{estimateAlice, estimateBob},
+		Moderator:    estimateSage,
+		MaxRounds:    3,
+		MaxCharLimit: 1000,
+		Settings:     models.DiscussionSettings{SummaryBackend: models.SummaryBackendBasic},
+	}
+	sequential := EstimateDiscussion(plan, nil, testPricing)
+	plan.Settings.RoundMode = models.RoundModeParallel
+	parallel := EstimateDiscussion(plan, nil, testPricing)
+
+	// Both modes make the same agent turns; parallel rounds skip the interim
+	// comments and only see the previous rounds
+	turns := func(e models.DiscussionEstimate) models.CallEstimate { return findPhase(e, models.EstimatePhaseTurn) }
+	if turns(sequential).Calls != 6 || turns(parallel).Calls != 6 {
+		t.Errorf("turns = %d sequential and %d parallel, want 6 each", turns(sequential).Calls, turns(parallel).Calls)
+	}
+	if got := findPhase(sequential, models.EstimatePhaseInterim).Calls; got != 3 {
+		t.Errorf("sequential interim comments = %d, want one between the turns of each round", got)
+	}
+	if got := findPhase(parallel, models.EstimatePhaseInterim).Calls; got != 0 {
+		t.Errorf("parallel interim comments = %d, want none", got)
+	}
+	// 300+300, 800+800 and 1300+1300 against 300+550, 800+1050 and 1300+1550
+	if got := turns(parallel).PromptTokens; got != 4800 {
+		t.Errorf("parallel turn prompts = %d tokens, want 4800", got)
+	}
+	if got := turns(sequential).PromptTokens; got != 5550 {
+		t.Errorf("sequential turn prompts = %d tokens, want 5550", got)
+	}
+	// Opening, 3 round summaries and the closing, plus 3 interim comments
+	if sequential.Calls != 14 || parallel.Calls != 11 {
+		t.Errorf("calls = %d sequential and %d parallel, want 14 and 11", sequential.Calls, parallel.Calls)
+	}
+}
+
+func TestEstimateDiscussionEveryPhase(t *testing.T) {
+	plan := EstimatePlan{
+		Agents:       []*models.Agent{estimateAlice, estimateBob},
+		Moderator:    estimateSage,
+		Judge:        estimateBob,
+		MaxRounds:    2,
+		MaxCharLimit: 1000,
+		Settings:     models.DiscussionSettings{EnableConsensusCheck: true},
+	}
+	// Alice's replies are sized from her history, the others from the limit
+	history := map[int64]models.AgentTokenHistory{1: {AgentID: 1, Calls: 12, AvgCompletionTokens: 99.6}}
+	got := EstimateDiscussion(plan, history, testPricing)
+
+	want := []models.PhaseEstimate{
+		{Phase: models.EstimatePhaseOpening, CallEstimate: models.CallEstimate{Calls: 1, PromptTokens: 300, CompletionTokens: 250, Cost: usdCost(0.0008)}},
+		{Phase: models.EstimatePhaseTurn, CallEstimate: models.CallEstimate{Calls: 4, PromptTokens: 2100, CompletionTokens: 700, Cost: usdCost(0.0187)}},
+		{Phase: models.EstimatePhaseInterim, CallEstimate: models.CallEstimate{Calls: 2, PromptTokens: 800, CompletionTokens: 500, Cost: usdCost(0.0018)}},
+		{Phase: models.EstimatePhaseRoundSummary, CallEstimate: models.CallEstimate{Calls: 2, PromptTokens: 600, CompletionTokens: 500, Cost: usdCost(0.0016)}},
+		// After round 1 only, sent Alice's and Bob's replies of that round
+		{Phase: models.EstimatePhaseConsensusCheck, CallEstimate: models.CallEstimate{Calls: 1, PromptTokens: 650, CompletionTokens: 50, Cost: usdCost(0.00075)}},
+		{Phase: models.EstimatePhaseClosing, CallEstimate: models.CallEstimate{Calls: 1, PromptTokens: 300, CompletionTokens: 250, Cost: usdCost(0.0008)}},
+		// The judge and the summary read the whole transcript
+		{Phase: models.EstimatePhaseJudge, CallEstimate: models.CallEstimate{Calls: 1, PromptTokens: 1000, CompletionTokens: 300, Cost: usdCost(0.0075)}},
+		{Phase: models.EstimatePhaseSummary, CallEstimate: models.CallEstimate{Calls: 1, PromptTokens: 1000, CompletionTokens: 250, Cost: usdCost(0.0015)}},
+	}
+	if !reflect.DeepEqual(got.Phases, want) {
+		t.Errorf("phases =\n%s\nwant\n%s", describeEstimate(models.DiscussionEstimate{Phases: got.Phases}), describeEstimate(models.DiscussionEstimate{Phases: want}))
+	}
+
+	wantAgents := []models.AgentEstimate{
+		{AgentID: 3, CallEstimate: models.CallEstimate{Calls: 8, PromptTokens: 3650, CompletionTokens: 1800, Cost: usdCost(0.00725)}},
+		{AgentID: 1, CallEstimate: models.CallEstimate{Calls: 2, PromptTokens: 950, CompletionTokens: 200, Cost: usdCost(0.00775)}, Historical: true},
+		{AgentID: 2, CallEstimate: models.CallEstimate{Calls: 3, PromptTokens: 2150, CompletionTokens: 800, Cost: usdCost(0.01845)}},
+	}
+	if !reflect.DeepEqual(got.Agents, wantAgents) {
+		t.Errorf("agents =\n%s\nwant\n%s", describeEstimate(models.DiscussionEstimate{Agents: got.Agents}), describeEstimate(models.DiscussionEstimate{Agents: wantAgents}))
+	}
+	if got.Calls != 13 || got.PromptTokens != 6750 || got.CompletionTokens != 2800 || got.TotalTokens != 9550 || costOf(got.Cost) != 0.03345 {
+		t.Errorf("total = %d calls, %d+%d=%d tokens, %v USD", got.Calls, got.PromptTokens, got.CompletionTokens, got.TotalTokens, costOf(got.Cost))
+	}
+}
+
+func TestEstimateDiscussionUnpriced(t *testing.T) {
+	local := &models.Agent{ID: 4, ProviderType: models.ProviderOllama, ModelName: "llama3"}
+	plan := EstimatePlan{
+		Agents:       []*models.Agent{estimateAlice, local},
+		MaxRounds:    1,
+		MaxCharLimit: 1000,
+		Settings:     models.DiscussionSettings{SummaryBackend: models.SummaryBackendExtractive, PerTurnContextChars: 200},
+	}
+	got := EstimateDiscussion(plan, nil, testPricing)
+
+	// One unpriced call leaves every total it is part of without a cost
+	if got.Cost != nil || findPhase(got, models.EstimatePhaseTurn).Cost != nil {
+		t.Errorf("total cost = %v, want none with an unpriced model", costOf(got.Cost))
+	}
+	if got.Agents[0].Cost == nil || got.Agents[1].Cost != nil {
+		t.Errorf("agent costs = %v and %v, want only Alice's", costOf(got.Agents[0].Cost), costOf(got.Agents[1].Cost))
+	}
+	if !reflect.DeepEqual(got.UnpricedModels, []string{"ollama/llama3"}) {
+		t.Errorf("unpriced models = %v", got.UnpricedModels)
+	}
+	// The context keeps 200 characters, 50 tokens, of Alice's reply
+	if got.Agents[1].PromptTokens != 350 {
+		t.Errorf("second prompt = %d tokens, want 350 with the context truncated", got.Agents[1].PromptTokens)
+	}
+
+	empty := EstimateDiscussion(EstimatePlan{MaxRounds: 3, MaxCharLimit: 1000}, nil, nil)
+	if empty.Calls != 0 || empty.Agents == nil || empty.Phases == nil || empty.UnpricedModels == nil || costOf(empty.Cost) != 0.0 {
+		t.Errorf("estimate without agents = %+v, want no calls and empty lists", empty)
+	}
+}
+
+// findPhase returns the estimate of one phase, zero when it has no calls
+func findPhase(e models.DiscussionEstimate, phase string) models.CallEstimate {
+	for _, p := range e.Phases {
+		if p.Phase == phase {
+			return p.CallEstimate
+		}
+	}
+	return models.CallEstimate{}
+}
+
+// describeEstimate lists an estimate's parts with their costs dereferenced
+func describeEstimate(e models.DiscussionEstimate) string {
+	var b strings.Builder
+	line := func(name interface{}, c models.CallEstimate) {
+		fmt.Fprintf(&b, "  %v: %d calls, %d+%d tokens, %v USD\n", name, c.Calls, c.PromptTokens, c.CompletionTokens, costOf(c.Cost))
+	}
+	if e.Calls > 0 {
+		line("total", e.CallEstimate)
+	}
+	for _, a := range e.Agents {
+		line(a.AgentID, a.CallEstimate)
+	}
+	for _, p := range e.Phases {
+		line(p.Phase, p.CallEstimate)
+	}
+	return b.String()
+}
+
+func TestEstimateDiscussionLightningRound(t *testing.T) {
+	plan := EstimatePlan{
+		Agents:       []*models.Agent{estimateAlice, estimateBob},
+		MaxRounds:    2,
+		MaxCharLimit: 1000,
+		Settings:     models.DiscussionSettings{SummaryBackend: models.SummaryBackendExtractive},
+	}
+	without := EstimateDiscussion(plan, nil, testPricing)
+	plan.Settings.LightningRound = true
+	with := EstimateDiscussion(plan, nil, testPricing)
+
+	// One statement per agent, capped at the 300 character default
+	statements := findPhase(with, models.EstimatePhaseClosingStatement)
+	if statements.Calls != 2 || statements.CompletionTokens != 150 {
+		t.Errorf("closing statements = %+v, want 2 calls of 75 tokens", statements)
+	}
+	if !reflect.DeepEqual(findPhase(with, models.EstimatePhaseTurn), findPhase(without, models.EstimatePhaseTurn)) {
+		t.Error("the lightning round changed the estimate of the turns")
+	}
+	if with.Calls != without.Calls+2 {
+		t.Errorf("calls = %d, want %d", with.Calls, without.Calls+2)
+	}
+
+	plan.Settings.LightningCharLimit = 100
+	if got := findPhase(EstimateDiscussion(plan, nil, testPricing), models.EstimatePhaseClosingStatement).CompletionTokens; got != 50 {
+		t.Errorf("statements under a 100 character limit = %d tokens, want 50", got)
+	}
+}
@@ -0,0 +1,82 @@
+package stats
+
+import (
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+	"sort"
+	"time"
+)
+
+// LatencyWindow is how far back latency percentiles look. Unlike reliability,
+// latency is not decayed: a slow call last week should still show in p99.
+const LatencyWindow = 30 * 24 * time.Hour
+
+// LatencyBucketBounds are the upper bounds, in milliseconds, of the latency
+// histogram buckets. Slower calls land in a final unbounded bucket.
+var LatencyBucketBounds = []int{1000, 2000, 5000, 10000, 30000, 60000, 120000}
+
+// Latency summarises the response times of an agent's successful calls.
+// Calls without a measured response time, such as imported turns, are
+// skipped.
+func Latency(agentID int64, outcomes []models.AgentOutcome) models.AgentLatency {
+	l := models.AgentLatency{AgentID: agentID, Histogram: make([]models.LatencyBucket, len(LatencyBucketBounds)+1)}
+	for i, bound := range LatencyBucketBounds {
+		l.Histogram[i].UpToMs = bound
+	}
+
+	var times []int
+	for _, o := range outcomes {
+		if o.AgentID != agentID || o.Status != "success" || o.ResponseTime <= 0 {
+			continue
+		}
+		times = append(times, o.ResponseTime)
+
+		bucket := sort.SearchInts(LatencyBucketBounds, o.ResponseTime)
+		l.Histogram[bucket].Count++
+	}
+	if len(times) == 0 {
+		return l
+	}
+
+	sort.Ints(times)
+	l.Samples = len(times)
+	l.P50Ms = percentile(times, 50)
+	l.P90Ms = percentile(times, 90)
+	l.P99Ms = percentile(times, 99)
+	l.MaxMs = times[len(times)-1]
+	return l
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted, which must
+// not be empty
+func percentile(sorted []int, p int) int {
+	rank := (p*len(sorted) + 99) / 100 // ceil(p/100 * n)
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// LatencyByAgent summarises every agent that appears in outcomes
+func LatencyByAgent(outcomes []models.AgentOutcome) map[int64]models.AgentLatency {
+	byAgent := make(map[int64][]models.AgentOutcome)
+	for _, o := range outcomes {
+		byAgent[o.AgentID] = append(byAgent[o.AgentID], o)
+	}
+
+	latencies := make(map[int64]models.AgentLatency, len(byAgent))
+	for id, history := range byAgent {
+		latencies[id] = Latency(id, history)
+	}
+	return latencies
+}
+
+// LoadLatency summarises every agent's calls stored in the database within
+// LatencyWindow
+func LoadLatency(db *database.DB) (map[int64]models.AgentLatency, error) {
+	outcomes, err := db.GetAgentOutcomes(time.Now().Add(-LatencyWindow))
+	if err != nil {
+		return nil, err
+	}
+	return LatencyByAgent(outcomes), nil
+}
@@ -0,0 +1,82 @@
+package stats
+
+import (
+	"testing"
+
+	"court-table-ai/pkg/models"
+)
+
+// latencies returns a successful call by agent 1 for each response time
+func latencies(ms ...int) []models.AgentOutcome {
+	outcomes := make([]models.AgentOutcome, len(ms))
+	for i, m := range ms {
+		outcomes[i] = models.AgentOutcome{AgentID: 1, Status: "success", ResponseTime: m}
+	}
+	return outcomes
+}
+
+func TestLatencyPercentiles(t *testing.T) {
+	// 1s to 100s in reverse, so the input order does not matter
+	var ms []int
+	for i := 100; i >= 1; i-- {
+		ms = append(ms, i*1000)
+	}
+	tests := []struct {
+		name                 string
+		outcomes             []models.AgentOutcome
+		p50, p90, p99, maxMs int
+	}{
+		{"hundred calls", latencies(ms...), 50000, 90000, 99000, 100000},
+		{"one call", latencies(4200), 4200, 4200, 4200, 4200},
+		// One slow call out of ten only shows from p99 up
+		{"a single outlier", latencies(900, 1000, 1100, 1200, 1300, 1400, 1500, 1600, 1700, 90000), 1300, 1700, 90000, 90000},
+		{"two calls", latencies(1000, 3000), 1000, 3000, 3000, 3000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := Latency(1, tt.outcomes)
+			if l.P50Ms != tt.p50 || l.P90Ms != tt.p90 || l.P99Ms != tt.p99 || l.MaxMs != tt.maxMs || l.Samples != len(tt.outcomes) {
+				t.Errorf("Latency = p50 %d p90 %d p99 %d max %d over %d, want %d %d %d %d over %d",
+					l.P50Ms, l.P90Ms, l.P99Ms, l.MaxMs, l.Samples, tt.p50, tt.p90, tt.p99, tt.maxMs, len(tt.outcomes))
+			}
+		})
+	}
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	outcomes := latencies(1000, 1001, 2000, 4999, 60000, 120000, 120001, 500000)
+	outcomes = append(outcomes,
+		models.AgentOutcome{AgentID: 1, Status: "error", ResponseTime: 1500},
+		models.AgentOutcome{AgentID: 1, Status: "timeout", TimedOut: true, ResponseTime: 30000},
+		models.AgentOutcome{AgentID: 1, Status: "success"}, // imported, not measured
+		models.AgentOutcome{AgentID: 2, Status: "success", ResponseTime: 1500},
+	)
+
+	l := Latency(1, outcomes)
+	if l.Samples != 8 {
+		t.Errorf("samples = %d, want only agent 1's measured successes", l.Samples)
+	}
+	want := []models.LatencyBucket{
+		{UpToMs: 1000, Count: 1},
+		{UpToMs: 2000, Count: 2},
+		{UpToMs: 5000, Count: 1},
+		{UpToMs: 10000},
+		{UpToMs: 30000},
+		{UpToMs: 60000, Count: 1},
+		{UpToMs: 120000, Count: 1},
+		{Count: 2},
+	}
+	if len(l.Histogram) != len(want) {
+		t.Fatalf("histogram = %+v, want %d buckets", l.Histogram, len(want))
+	}
+	for i := range want {
+		if l.Histogram[i] != want[i] {
+			t.Errorf("bucket %d = %+v, want %+v", i, l.Histogram[i], want[i])
+		}
+	}
+
+	empty := Latency(3, outcomes)
+	if empty.Samples != 0 || empty.P99Ms != 0 || len(empty.Histogram) != len(want) {
+		t.Errorf("agent without calls = %+v, want zero figures and empty buckets", empty)
+	}
+}
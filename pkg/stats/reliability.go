@@ -0,0 +1,147 @@
+package stats
+
+import (
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+	"math"
+	"time"
+)
+
+// Reliability scoring parameters. A call's weight halves every
+// ReliabilityHalfLife, so recent debates dominate the score; calls older than
+// ReliabilityWindow are not read at all.
+const (
+	ReliabilityHalfLife   = 14 * 24 * time.Hour
+	ReliabilityWindow     = 180 * 24 * time.Hour
+	MinReliabilitySamples = 3
+
+	// LowReliabilityScore is the score below which discussion validation warns
+	LowReliabilityScore = 60
+)
+
+// Score weights and the latency range mapped onto the latency component:
+// answers at or under latencyFastMs score full marks, at or over latencySlowMs
+// none
+const (
+	successWeight = 0.6
+	timeoutWeight = 0.25
+	latencyWeight = 0.15
+
+	latencyFastMs = 2000
+	latencySlowMs = 60000
+
+	// ratingPoints is how far reader ratings move the score: an average
+	// rating of 1 adds this many points, -1 removes them
+	ratingPoints = 10
+)
+
+// Reliability scores one agent's call history at time now. Only calls that
+// reached a verdict count: success rate and timeout rate are taken over all of
+// them, average latency over successful calls. Reader ratings of the agent's
+// turns then move the score by up to ratingPoints either way.
+func Reliability(agentID int64, outcomes []models.AgentOutcome, ratings []*models.LogRating, now time.Time) models.AgentReliability {
+	r := models.AgentReliability{AgentID: agentID}
+
+	var rated, ratingSum float64
+	for _, rating := range ratings {
+		if rating.AgentID != agentID {
+			continue
+		}
+		w := decayWeight(now.Sub(rating.UpdatedAt))
+		r.Ratings++
+		rated += w
+		ratingSum += w * float64(rating.Rating)
+	}
+	if rated > 0 {
+		r.AvgRating = round2(ratingSum / rated)
+	}
+
+	var total, succeeded, timedOut, latency float64
+	for _, o := range outcomes {
+		if o.AgentID != agentID {
+			continue
+		}
+		w := decayWeight(now.Sub(o.CreatedAt))
+
+		r.Samples++
+		total += w
+		if o.Status == "success" {
+			succeeded += w
+			latency += w * float64(o.ResponseTime)
+		} else if o.TimedOut {
+			timedOut += w
+		}
+	}
+	if total == 0 {
+		return r
+	}
+
+	r.Rated = r.Samples >= MinReliabilitySamples
+	r.SuccessRate = round2(succeeded / total)
+	r.TimeoutRate = round2(timedOut / total)
+
+	latencyScore := 0.0
+	if succeeded > 0 {
+		avg := latency / succeeded
+		r.AvgLatencyMs = int(math.Round(avg))
+		latencyScore = 1 - (avg-latencyFastMs)/(latencySlowMs-latencyFastMs)
+		latencyScore = math.Max(0, math.Min(1, latencyScore))
+	}
+
+	score := successWeight*(succeeded/total) + timeoutWeight*(1-timedOut/total) + latencyWeight*latencyScore
+	if rated > 0 {
+		score += ratingPoints / 100.0 * (ratingSum / rated)
+	}
+	r.Score = int(math.Round(100 * math.Max(0, math.Min(1, score))))
+	return r
+}
+
+// ReliabilityByAgent scores every agent that appears in outcomes or ratings
+func ReliabilityByAgent(outcomes []models.AgentOutcome, ratings []*models.LogRating, now time.Time) map[int64]models.AgentReliability {
+	byAgent := make(map[int64][]models.AgentOutcome)
+	for _, o := range outcomes {
+		byAgent[o.AgentID] = append(byAgent[o.AgentID], o)
+	}
+	ratingsByAgent := make(map[int64][]*models.LogRating)
+	for _, r := range ratings {
+		ratingsByAgent[r.AgentID] = append(ratingsByAgent[r.AgentID], r)
+		if _, ok := byAgent[r.AgentID]; !ok {
+			byAgent[r.AgentID] = nil
+		}
+	}
+
+	scores := make(map[int64]models.AgentReliability, len(byAgent))
+	for id, history := range byAgent {
+		scores[id] = Reliability(id, history, ratingsByAgent[id], now)
+	}
+	return scores
+}
+
+// LoadReliability scores every agent from the calls and ratings stored in the
+// database. Agents without any calls or ratings in the window are absent from
+// the result.
+func LoadReliability(db *database.DB) (map[int64]models.AgentReliability, error) {
+	now := time.Now()
+	outcomes, err := db.GetAgentOutcomes(now.Add(-ReliabilityWindow))
+	if err != nil {
+		return nil, err
+	}
+	ratings, err := db.GetAgentRatings(now.Add(-ReliabilityWindow))
+	if err != nil {
+		return nil, err
+	}
+	return ReliabilityByAgent(outcomes, ratings, now), nil
+}
+
+// decayWeight returns the weight of a call made age ago
+func decayWeight(age time.Duration) float64 {
+	if age < 0 {
+		age = 0
+	}
+	return math.Pow(0.5, float64(age)/float64(ReliabilityHalfLife))
+}
+
+// round2 rounds to two decimal places for display
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
@@ -0,0 +1,123 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/models"
+)
+
+// history returns n outcomes for agent 1 with the given status and latency,
+// made age before now
+func history(n int, status string, latencyMs int, now time.Time, age time.Duration) []models.AgentOutcome {
+	outcomes := make([]models.AgentOutcome, n)
+	for i := range outcomes {
+		outcomes[i] = models.AgentOutcome{AgentID: 1, Status: status, TimedOut: status == "timeout", ResponseTime: latencyMs, CreatedAt: now.Add(-age)}
+	}
+	return outcomes
+}
+
+func TestReliability(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	concat := func(parts ...[]models.AgentOutcome) []models.AgentOutcome {
+		var all []models.AgentOutcome
+		for _, p := range parts {
+			all = append(all, p...)
+		}
+		return all
+	}
+	tests := []struct {
+		name      string
+		outcomes  []models.AgentOutcome
+		ratings   []*models.LogRating
+		wantScore int
+		wantRated bool
+	}{
+		{"no history", nil, nil, 0, false},
+		{"too few calls", history(2, "success", 500, now, 0), nil, 100, false},
+		{"fast and always answering", history(5, "success", 1000, now, 0), nil, 100, true},
+		// Latency is scored linearly between 2s and 60s
+		{"slow", history(5, "success", 60000, now, 0), nil, 85, true},
+		{"a quarter of the way to slow", history(5, "success", 16500, now, 0), nil, 96, true},
+		{"always timing out", history(5, "timeout", 30000, now, 0), nil, 0, true},
+		{"always failing without timeouts", history(5, "error", 100, now, 0), nil, 25, true},
+		{"a quarter timeouts", concat(history(3, "success", 1000, now, 0), history(1, "timeout", 0, now, 0)), nil, 79, true},
+		// Three half-lives make the old calls count an eighth
+		{"old failures, recent successes", concat(history(4, "error", 0, now, 42*24*time.Hour), history(4, "success", 1000, now, 0)), nil, 93, true},
+		{"old successes, recent failures", concat(history(4, "success", 1000, now, 42*24*time.Hour), history(4, "error", 0, now, 0)), nil, 47, true},
+		{"calls from the future count as now", history(3, "error", 0, now, -time.Hour), nil, 25, true},
+		{"other agents are ignored", concat(history(3, "success", 1000, now, 0), []models.AgentOutcome{{AgentID: 2, Status: "error", CreatedAt: now}}), nil, 100, true},
+		{"poor ratings", history(5, "success", 1000, now, 0), []*models.LogRating{{AgentID: 1, Rating: -1, UpdatedAt: now}}, 90, true},
+		{"good ratings cap at 100", history(5, "success", 1000, now, 0), []*models.LogRating{{AgentID: 1, Rating: 1, UpdatedAt: now}}, 100, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Reliability(1, tt.outcomes, tt.ratings, now)
+			if r.Score != tt.wantScore || r.Rated != tt.wantRated {
+				t.Errorf("Reliability = score %d rated %v (%+v), want %d rated %v", r.Score, r.Rated, r, tt.wantScore, tt.wantRated)
+			}
+		})
+	}
+}
+
+func TestReliabilityRates(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	outcomes := append(history(2, "success", 3000, now, 0), history(1, "timeout", 0, now, 0)...)
+	outcomes = append(outcomes, history(1, "error", 0, now, 0)...)
+	outcomes[1].ResponseTime = 5000
+
+	r := Reliability(1, outcomes, nil, now)
+	if r.Samples != 4 || r.SuccessRate != 0.5 || r.TimeoutRate != 0.25 || r.AvgLatencyMs != 4000 {
+		t.Errorf("Reliability = %+v, want 4 samples, 0.5 success, 0.25 timeouts and 4000ms", r)
+	}
+}
+
+func TestReliabilityByAgent(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	outcomes := history(3, "success", 1000, now, 0)
+	outcomes = append(outcomes, models.AgentOutcome{AgentID: 2, Status: "error", CreatedAt: now})
+	ratings := []*models.LogRating{{AgentID: 3, Rating: 1, UpdatedAt: now}}
+
+	scores := ReliabilityByAgent(outcomes, ratings, now)
+	if len(scores) != 3 {
+		t.Fatalf("scores = %+v, want agents 1, 2 and the rated-only agent 3", scores)
+	}
+	if scores[1].Score != 100 || !scores[1].Rated {
+		t.Errorf("agent 1 = %+v", scores[1])
+	}
+	if scores[2].Rated || scores[2].Samples != 1 {
+		t.Errorf("agent 2 = %+v, want one unrated sample", scores[2])
+	}
+	if scores[3].Samples != 0 || scores[3].Ratings != 1 || scores[3].AvgRating != 1 {
+		t.Errorf("agent 3 = %+v, want a rating and no calls", scores[3])
+	}
+}
+
+func TestReliabilityRatingAverage(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	slow := history(5, "success", 60000, now, 0)
+	ratings := []*models.LogRating{
+		{AgentID: 1, Rating: 1, UpdatedAt: now},
+		{AgentID: 1, Rating: 1, UpdatedAt: now.Add(-time.Hour)},
+		// Three half-lives old, so it counts an eighth
+		{AgentID: 1, Rating: -1, UpdatedAt: now.Add(-42 * 24 * time.Hour)},
+		{AgentID: 2, Rating: -1, UpdatedAt: now},
+	}
+
+	r := Reliability(1, slow, ratings, now)
+	if r.Ratings != 3 || r.AvgRating != 0.88 {
+		t.Errorf("ratings = %d averaging %v, want 3 averaging 0.88", r.Ratings, r.AvgRating)
+	}
+	// 85 from the calls plus 10 points times the average
+	if r.Score != 94 {
+		t.Errorf("score = %d, want 94", r.Score)
+	}
+
+	// Only the agent's own ratings count
+	if r := Reliability(1, slow, ratings[3:], now); r.Ratings != 0 || r.Score != 85 {
+		t.Errorf("another agent's rating counted: %+v", r)
+	}
+	if r := Reliability(1, slow, []*models.LogRating{{AgentID: 1, Rating: -1, UpdatedAt: now}}, now); r.AvgRating != -1 || r.Score != 75 {
+		t.Errorf("thumbs down = %+v, want an average of -1 and a score of 75", r)
+	}
+}
@@ -0,0 +1,143 @@
+package stats
+
+import (
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Token usage parameters. Usage is reported for the last DefaultTokenStatsDays
+// days unless a caller asks for up to MaxTokenStatsDays; a shared token is
+// flagged when it was rate limited within SharedKeyRateLimitWindow.
+const (
+	DefaultTokenStatsDays    = 7
+	MaxTokenStatsDays        = 90
+	SharedKeyRateLimitWindow = 24 * time.Hour
+)
+
+// TokenUsage groups calls by token fingerprint and UTC day, newest day first
+func TokenUsage(calls []models.TokenCall) []models.TokenUsageDay {
+	type key struct{ fingerprint, date string }
+	byDay := make(map[key]*models.TokenUsageDay)
+	agents := make(map[key]map[int64]bool)
+
+	for _, call := range calls {
+		k := key{call.Fingerprint, call.CreatedAt.UTC().Format("2006-01-02")}
+		day, ok := byDay[k]
+		if !ok {
+			day = &models.TokenUsageDay{Fingerprint: k.fingerprint, Date: k.date}
+			byDay[k] = day
+			agents[k] = make(map[int64]bool)
+		}
+
+		day.Calls++
+		day.TotalTokens += call.TotalTokens
+		if call.Status != "success" {
+			day.Errors++
+		}
+		if call.ErrorClass == "rate_limited" {
+			day.RateLimited++
+		}
+		if !agents[k][call.AgentID] {
+			agents[k][call.AgentID] = true
+			day.AgentIDs = append(day.AgentIDs, call.AgentID)
+		}
+	}
+
+	usage := make([]models.TokenUsageDay, 0, len(byDay))
+	for _, day := range byDay {
+		sort.Slice(day.AgentIDs, func(i, j int) bool { return day.AgentIDs[i] < day.AgentIDs[j] })
+		usage = append(usage, *day)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Date != usage[j].Date {
+			return usage[i].Date > usage[j].Date
+		}
+		return usage[i].Fingerprint < usage[j].Fingerprint
+	})
+	return usage
+}
+
+// DiscussionTokenUsage totals the token usage stored on a discussion's agent
+// and moderator entries
+func DiscussionTokenUsage(logs []*models.DiscussionLog) models.DiscussionTokenUsage {
+	usage := models.DiscussionTokenUsage{Agents: []models.AgentTokenUsage{}}
+	byAgent := make(map[int64]*models.AgentTokenUsage)
+	var order []int64
+
+	for _, l := range logs {
+		if l.IsSystem() || l.LogType != models.LogTypeResponse {
+			continue
+		}
+		agent, ok := byAgent[l.AgentID]
+		if !ok {
+			agent = &models.AgentTokenUsage{AgentID: l.AgentID}
+			byAgent[l.AgentID] = agent
+			order = append(order, l.AgentID)
+		}
+
+		agent.Calls++
+		agent.PromptTokens += l.PromptTokens
+		agent.CompletionTokens += l.CompletionTokens
+		agent.TotalTokens += l.PromptTokens + l.CompletionTokens
+		usage.PromptTokens += l.PromptTokens
+		usage.CompletionTokens += l.CompletionTokens
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+
+	for _, id := range order {
+		usage.Agents = append(usage.Agents, *byAgent[id])
+	}
+	sort.SliceStable(usage.Agents, func(i, j int) bool { return usage.Agents[i].TotalTokens > usage.Agents[j].TotalTokens })
+	return usage
+}
+
+// LoadTokenUsage reports per-token usage for the last days days
+func LoadTokenUsage(db *database.DB, days int) ([]models.TokenUsageDay, error) {
+	calls, err := db.GetTokenCalls(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return nil, err
+	}
+	return TokenUsage(calls), nil
+}
+
+// MarkSharedKeys sets each agent's token fingerprint and warns on agents that
+// share a token with another agent when that token was rate limited within
+// SharedKeyRateLimitWindow
+func MarkSharedKeys(db *database.DB, agents []*models.Agent) error {
+	sharing := make(map[string][]*models.Agent)
+	for _, agent := range agents {
+		agent.TokenFingerprint = models.TokenFingerprint(agent.APIToken)
+		if agent.TokenFingerprint != "" {
+			sharing[agent.TokenFingerprint] = append(sharing[agent.TokenFingerprint], agent)
+		}
+	}
+
+	calls, err := db.GetTokenCalls(time.Now().Add(-SharedKeyRateLimitWindow))
+	if err != nil {
+		return err
+	}
+	rateLimited := make(map[string]int)
+	for _, call := range calls {
+		if call.ErrorClass == "rate_limited" {
+			rateLimited[call.Fingerprint]++
+		}
+	}
+
+	for fingerprint, group := range sharing {
+		if len(group) < 2 || rateLimited[fingerprint] == 0 {
+			continue
+		}
+		others := "another agent"
+		if len(group) > 2 {
+			others = fmt.Sprintf("%d other agents", len(group)-1)
+		}
+		for _, agent := range group {
+			agent.SharedKeyWarning = fmt.Sprintf("API key shared with %s was rate limited %d times in the last %d hours",
+				others, rateLimited[fingerprint], int(SharedKeyRateLimitWindow.Hours()))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package stats
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+)
+
+func TestDiscussionTokenUsageExcludesSystem(t *testing.T) {
+	logs := []*models.DiscussionLog{
+		{AgentID: 1, LogType: models.LogTypeResponse, PromptTokens: 10, CompletionTokens: 5},
+		{AgentID: 2, LogType: models.LogTypeResponse, PromptTokens: 20, CompletionTokens: 10},
+		{AgentID: 1, LogType: models.LogTypeResponse, PromptTokens: 3},
+		// Engine-written entries are not agent calls
+		{AgentID: models.SystemAgentID, LogType: models.LogTypeResponse, PromptTokens: 100, CompletionTokens: 100},
+		{AgentID: models.SystemAgentID, LogType: models.LogTypeSkip},
+		{AgentID: 2, LogType: models.LogTypeSystem, PromptTokens: 50},
+	}
+
+	usage := DiscussionTokenUsage(logs)
+	if usage.PromptTokens != 33 || usage.CompletionTokens != 15 || usage.TotalTokens != 48 {
+		t.Errorf("totals = %d/%d/%d, want 33/15/48", usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens)
+	}
+	if len(usage.Agents) != 2 {
+		t.Fatalf("agents = %+v, want agents 1 and 2 only", usage.Agents)
+	}
+	if a := usage.Agents[0]; a.AgentID != 2 || a.Calls != 1 || a.TotalTokens != 30 {
+		t.Errorf("first agent = %+v, want agent 2 with 30 tokens over 1 call", a)
+	}
+	if a := usage.Agents[1]; a.AgentID != 1 || a.Calls != 2 || a.TotalTokens != 18 {
+		t.Errorf("second agent = %+v, want agent 1 with 18 tokens over 2 calls", a)
+	}
+}
+
+func TestTokenUsageGroupsByKeyAndDay(t *testing.T) {
+	day1 := time.Date(2025, 6, 1, 23, 30, 0, 0, time.UTC)
+	day2 := time.Date(2025, 6, 2, 0, 30, 0, 0, time.UTC)
+	calls := []models.TokenCall{
+		{AgentID: 2, Fingerprint: "shared", Status: "success", TotalTokens: 10, CreatedAt: day1},
+		{AgentID: 1, Fingerprint: "shared", Status: "success", TotalTokens: 20, CreatedAt: day1},
+		{AgentID: 1, Fingerprint: "shared", Status: "error", ErrorClass: "rate_limited", CreatedAt: day1},
+		{AgentID: 2, Fingerprint: "shared", Status: "timeout", ErrorClass: "timeout", CreatedAt: day2},
+		{AgentID: 3, Fingerprint: "own", Status: "success", TotalTokens: 5, CreatedAt: day1},
+		// Same instant in another zone still falls on the first UTC day
+		{AgentID: 3, Fingerprint: "own", Status: "success", TotalTokens: 1, CreatedAt: day1.In(time.FixedZone("UTC+2", 2*3600))},
+	}
+	want := []models.TokenUsageDay{
+		{Fingerprint: "shared", Date: "2025-06-02", AgentIDs: []int64{2}, Calls: 1, Errors: 1},
+		{Fingerprint: "own", Date: "2025-06-01", AgentIDs: []int64{3}, Calls: 2, TotalTokens: 6},
+		{Fingerprint: "shared", Date: "2025-06-01", AgentIDs: []int64{1, 2}, Calls: 3, Errors: 1, RateLimited: 1, TotalTokens: 30},
+	}
+	if got := TokenUsage(calls); !reflect.DeepEqual(got, want) {
+		t.Errorf("TokenUsage =\n%+v\nwant\n%+v", got, want)
+	}
+	if got := TokenUsage(nil); got == nil || len(got) != 0 {
+		t.Errorf("TokenUsage(nil) = %#v, want an empty list", got)
+	}
+}
+
+func TestMarkSharedKeys(t *testing.T) {
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	if err := db.CreateTables(); err != nil {
+		t.Fatalf("CreateTables: %v", err)
+	}
+
+	var agents []*models.Agent
+	for _, a := range []struct{ name, token string }{{"Alice", "sk-shared"}, {"Bob", "sk-shared"}, {"Carol", "sk-carol"}, {"Dave", ""}} {
+		agent := &models.Agent{Name: a.name, ProviderType: models.ProviderOpenAI, ProviderURL: "https://api.openai.com/v1", APIToken: a.token, ModelName: "gpt-4o", TimeoutSeconds: 30}
+		if err := db.InsertAgent(agent); err != nil {
+			t.Fatalf("InsertAgent(%s): %v", a.name, err)
+		}
+		agents = append(agents, agent)
+	}
+	discussion := &models.Discussion{Topic: "Tabs or spaces", Status: "completed", MaxRounds: 1, AgentIDs: []int64{agents[0].ID}}
+	if err := db.InsertDiscussion(discussion); err != nil {
+		t.Fatalf("InsertDiscussion: %v", err)
+	}
+	shared := models.TokenFingerprint("sk-shared")
+
+	if err := MarkSharedKeys(db, agents); err != nil {
+		t.Fatalf("MarkSharedKeys: %v", err)
+	}
+	if agents[0].TokenFingerprint != shared || agents[3].TokenFingerprint != "" {
+		t.Errorf("fingerprints = %q, %q; want %q and none", agents[0].TokenFingerprint, agents[3].TokenFingerprint, shared)
+	}
+	for _, agent := range agents {
+		if agent.SharedKeyWarning != "" {
+			t.Errorf("%s warned %q before any rate limit", agent.Name, agent.SharedKeyWarning)
+		}
+	}
+
+	// A 429 on Bob's call flags both agents using the key, not Carol
+	entry := &models.DiscussionLog{
+		DiscussionID: discussion.ID,
+		AgentID:      agents[1].ID,
+		Status:       "error",
+		Metadata:     models.JSONMap{"token_fingerprint": shared, "error_class": "rate_limited"},
+		Content:      "status 429",
+	}
+	if err := db.InsertDiscussionLog(entry); err != nil {
+		t.Fatalf("InsertDiscussionLog: %v", err)
+	}
+	if err := MarkSharedKeys(db, agents); err != nil {
+		t.Fatalf("MarkSharedKeys: %v", err)
+	}
+	for i, agent := range agents {
+		if warned := agent.SharedKeyWarning != ""; warned != (i < 2) {
+			t.Errorf("%s warning = %q, want one only for the shared key", agent.Name, agent.SharedKeyWarning)
+		}
+	}
+	if want := "API key shared with another agent was rate limited 1 times in the last 24 hours"; agents[0].SharedKeyWarning != want {
+		t.Errorf("warning = %q, want %q", agents[0].SharedKeyWarning, want)
+	}
+}
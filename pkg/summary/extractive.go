@@ -0,0 +1,264 @@
+// Package summary condenses a finished debate without calling any provider,
+// so a summary is available even when every agent is unreachable.
+package summary
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Turn is one agent response in the order it was given
+type Turn struct {
+	AgentID   int64
+	AgentName string
+	Content   string
+}
+
+// Sentence scoring parameters
+const (
+	// minSentenceWords drops fragments such as "I agree." that say little
+	// on their own
+	minSentenceWords = 4
+	// leadBonus favours a turn's opening sentence, which usually states the
+	// agent's position; closingBonus does the same, less so, for its last
+	leadBonus    = 0.5
+	closingBonus = 0.2
+	// duplicateOverlap is the share of content words two sentences may have
+	// in common before the lower scored one is skipped as a repetition
+	duplicateOverlap = 0.6
+)
+
+// sentence is a candidate for the summary
+type sentence struct {
+	agent    int // index into the speakers in order of first appearance
+	turn     int
+	position int // within the turn
+	text     string
+	words    map[string]bool
+	score    float64
+}
+
+// Extractive picks the most representative sentences of the debate and
+// renders them as bullets attributed to their agents, at most limit
+// characters in total. Agents take turns contributing their next best
+// sentence so every participant is represented. Bullets keep the order in
+// which the points were made. Stop words are dropped for English and
+// Indonesian; other languages are scored on all words.
+func Extractive(turns []Turn, language string, limit int) string {
+	stop := stopWords(language)
+
+	var speakers []string
+	index := make(map[int64]int)
+	var candidates []*sentence
+	freq := make(map[string]int)
+
+	for t, turn := range turns {
+		a, ok := index[turn.AgentID]
+		if !ok {
+			a = len(speakers)
+			index[turn.AgentID] = a
+			speakers = append(speakers, turn.AgentName)
+		}
+
+		texts := splitSentences(turn.Content)
+		for i, text := range texts {
+			words := contentWords(text, stop)
+			for _, w := range words {
+				freq[w]++
+			}
+			if len(strings.Fields(text)) < minSentenceWords || len(words) == 0 {
+				continue
+			}
+
+			s := &sentence{agent: a, turn: t, position: i, text: text, words: make(map[string]bool)}
+			for _, w := range words {
+				s.words[w] = true
+			}
+			switch {
+			case i == 0:
+				s.score = leadBonus
+			case i == len(texts)-1:
+				s.score = closingBonus
+			}
+			candidates = append(candidates, s)
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	maxFreq := 0
+	for _, n := range freq {
+		if n > maxFreq {
+			maxFreq = n
+		}
+	}
+	for _, s := range candidates {
+		var sum float64
+		for w := range s.words {
+			sum += float64(freq[w]) / float64(maxFreq)
+		}
+		// Dividing by the square root rewards dense sentences without
+		// letting long ones win on length alone
+		s.score += sum / math.Sqrt(float64(len(s.words)))
+	}
+
+	// Each agent's candidates, best first
+	ranked := make([][]*sentence, len(speakers))
+	for _, s := range candidates {
+		ranked[s.agent] = append(ranked[s.agent], s)
+	}
+	for _, list := range ranked {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].score > list[j].score })
+	}
+
+	// In the first pass each agent's point must fit an equal share of the
+	// limit, so one long sentence cannot crowd out the other agents
+	var chosen []*sentence
+	used := 0
+	share := limit / len(speakers)
+	for pass := 0; ; pass++ {
+		progress := false
+		for a := range ranked {
+			size := limit - used
+			if pass == 0 && share < size {
+				size = share
+			}
+			for i, s := range ranked[a] {
+				n := utf8.RuneCountInString(bullet(speakers[a], s.text))
+				if n > size || repeats(s, chosen) {
+					continue
+				}
+				chosen = append(chosen, s)
+				used += n
+				ranked[a] = append(ranked[a][:i:i], ranked[a][i+1:]...)
+				progress = true
+				break
+			}
+		}
+		if !progress && pass > 0 {
+			break
+		}
+	}
+
+	if len(chosen) == 0 {
+		// Not even one sentence fits; shorten the overall best one
+		best := candidates[0]
+		for _, s := range candidates {
+			if s.score > best.score {
+				best = s
+			}
+		}
+		prefix := "- " + speakers[best.agent] + ": "
+		room := limit - utf8.RuneCountInString(prefix)
+		if room < minSentenceWords {
+			return ""
+		}
+		return prefix + shorten(best.text, room)
+	}
+
+	sort.Slice(chosen, func(i, j int) bool {
+		if chosen[i].turn != chosen[j].turn {
+			return chosen[i].turn < chosen[j].turn
+		}
+		return chosen[i].position < chosen[j].position
+	})
+
+	var b strings.Builder
+	for _, s := range chosen {
+		b.WriteString(bullet(speakers[s.agent], s.text))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// bullet renders one summary line
+func bullet(agent, text string) string {
+	return "- " + agent + ": " + text + "\n"
+}
+
+// repeats reports whether s mostly restates a sentence already chosen
+func repeats(s *sentence, chosen []*sentence) bool {
+	for _, c := range chosen {
+		shared := 0
+		for w := range s.words {
+			if c.words[w] {
+				shared++
+			}
+		}
+		smaller := len(s.words)
+		if len(c.words) < smaller {
+			smaller = len(c.words)
+		}
+		if float64(shared) > duplicateOverlap*float64(smaller) {
+			return true
+		}
+	}
+	return false
+}
+
+// listMarker matches markdown bullets, numbering, quotes and headings at the
+// start of a line
+var listMarker = regexp.MustCompile(`^(?:[-*+>#]+|\d+[.)])\s+`)
+
+// splitSentences breaks text into sentences at terminators followed by
+// whitespace and at line breaks, dropping markdown list and heading markers
+func splitSentences(text string) []string {
+	var sentences []string
+	for _, line := range strings.Split(text, "\n") {
+		line = listMarker.ReplaceAllString(strings.TrimSpace(line), "")
+		runes := []rune(line)
+		start := 0
+		for i, r := range runes {
+			if (r == '.' || r == '!' || r == '?') && (i+1 == len(runes) || unicode.IsSpace(runes[i+1])) {
+				if s := strings.TrimSpace(string(runes[start : i+1])); s != "" {
+					sentences = append(sentences, s)
+				}
+				start = i + 1
+			}
+		}
+		if s := strings.TrimSpace(string(runes[start:])); s != "" {
+			sentences = append(sentences, s)
+		}
+	}
+	return sentences
+}
+
+// contentWords lowercases the words of text and drops stop words. Hyphens
+// inside a word are kept so Indonesian reduplication such as "anak-anak"
+// stays one word.
+func contentWords(text string, stop map[string]bool) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+	})
+
+	words := fields[:0]
+	for _, w := range fields {
+		w = strings.Trim(w, "-")
+		if utf8.RuneCountInString(w) < 2 || stop[w] {
+			continue
+		}
+		words = append(words, w)
+	}
+	return words
+}
+
+// shorten cuts text to at most limit characters at a word boundary, ending
+// with an ellipsis
+func shorten(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	end := limit - 1
+	for i := end; i > limit/2; i-- {
+		if unicode.IsSpace(runes[i]) {
+			end = i
+			break
+		}
+	}
+	return strings.TrimRightFunc(string(runes[:end]), unicode.IsSpace) + "…"
+}
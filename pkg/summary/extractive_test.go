@@ -0,0 +1,127 @@
+package summary
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// fixture is a two-round debate in which Alice talks far more than the others
+var fixture = []Turn{
+	{1, "Alice", "Remote work raises productivity for focused engineering tasks. Studies of remote teams show fewer interruptions and longer focus blocks. Commuting time is returned to the employee, which improves retention. Remote hiring also widens the talent pool beyond one city. Tools for asynchronous collaboration have matured considerably."},
+	{2, "Bob", "Office work builds trust between new colleagues much faster. Mentoring junior engineers is harder when every conversation needs a meeting."},
+	{3, "Chen", "A hybrid schedule keeps focus days remote and collaboration days in the office."},
+	{1, "Alice", "Remote mentoring works when senior engineers pair on video deliberately. Retention numbers from remote teams back this up."},
+	{2, "Bob", "Trust between colleagues still grows faster in the office during the first months."},
+	{3, "Chen", "Teams should measure productivity and retention before settling the schedule."},
+}
+
+// bullets splits a summary into its agents and points
+func bullets(t *testing.T, summary string) (agents []string, points []string) {
+	t.Helper()
+	for _, line := range strings.Split(summary, "\n") {
+		agent, point, ok := strings.Cut(strings.TrimPrefix(line, "- "), ": ")
+		if !strings.HasPrefix(line, "- ") || !ok {
+			t.Fatalf("summary line %q is not an attributed bullet", line)
+		}
+		agents = append(agents, agent)
+		points = append(points, point)
+	}
+	return agents, points
+}
+
+func TestExtractiveBalancesAgents(t *testing.T) {
+	for _, limit := range []int{300, 500, 1000, 2000} {
+		summary := Extractive(fixture, "en", limit)
+		if n := utf8.RuneCountInString(summary); n > limit {
+			t.Errorf("limit %d: summary has %d characters", limit, n)
+		}
+
+		agents, points := bullets(t, summary)
+		count := map[string]int{}
+		for _, a := range agents {
+			count[a]++
+		}
+		for _, name := range []string{"Alice", "Bob", "Chen"} {
+			if count[name] == 0 {
+				t.Errorf("limit %d: %s is not represented in\n%s", limit, name, summary)
+			}
+		}
+		// Alice has the most to say, but while the limit bites she gets no
+		// more than one point ahead of each of the others
+		if limit <= 500 && (count["Alice"] > count["Bob"]+1 || count["Alice"] > count["Chen"]+1) {
+			t.Errorf("limit %d: Alice has %d of %d bullets", limit, count["Alice"], len(agents))
+		}
+
+		// Points keep the order in which they were made
+		last := -1
+		transcript := ""
+		for _, turn := range fixture {
+			transcript += turn.Content + "\n"
+		}
+		for _, p := range points {
+			at := strings.Index(transcript, p)
+			if at < 0 {
+				t.Errorf("limit %d: %q is not a sentence of the debate", limit, p)
+				continue
+			}
+			if at < last {
+				t.Errorf("limit %d: %q is out of debate order", limit, p)
+			}
+			last = at
+		}
+	}
+}
+
+func TestExtractiveSkipsRepetition(t *testing.T) {
+	turns := []Turn{
+		{1, "Alice", "Remote work raises engineering productivity a lot."},
+		{1, "Alice", "Remote work raises engineering productivity a lot, really."},
+		{2, "Bob", "Office work builds trust between colleagues faster."},
+	}
+	agents, _ := bullets(t, Extractive(turns, "en", 2000))
+	if !reflect.DeepEqual(agents, []string{"Alice", "Bob"}) {
+		t.Errorf("bullets by %v, want the repeated point once", agents)
+	}
+}
+
+func TestExtractiveShortensWhenNothingFits(t *testing.T) {
+	summary := Extractive(fixture[:1], "en", 40)
+	if n := utf8.RuneCountInString(summary); n > 40 || !strings.HasPrefix(summary, "- Alice: ") || !strings.HasSuffix(summary, "…") {
+		t.Errorf("summary = %q (%d characters), want one shortened bullet within 40", summary, n)
+	}
+	if got := Extractive(fixture[:1], "en", 10); got != "" {
+		t.Errorf("summary with no room = %q, want none", got)
+	}
+	if got := Extractive([]Turn{{1, "Alice", "I agree. Me too."}}, "en", 1000); got != "" {
+		t.Errorf("summary of fragments = %q, want none", got)
+	}
+}
+
+func TestExtractiveIndonesian(t *testing.T) {
+	turns := []Turn{
+		{1, "Sari", "Pendidikan anak-anak harus menjadi prioritas utama pemerintah daerah. Anggaran sekolah dasar perlu dinaikkan tahun depan."},
+		{2, "Budi", "Anggaran pendidikan anak-anak sudah cukup besar saat ini. Masalahnya adalah pengawasan penggunaan dana sekolah."},
+	}
+	agents, _ := bullets(t, Extractive(turns, "id", 400))
+	if len(agents) < 2 || agents[0] != "Sari" {
+		t.Errorf("bullets by %v, want both speakers in debate order", agents)
+	}
+
+	words := contentWords("Pendidikan anak-anak adalah yang utama dan", stopWords("id"))
+	if want := []string{"pendidikan", "anak-anak", "utama"}; !reflect.DeepEqual(words, want) {
+		t.Errorf("Indonesian content words = %q, want %q", words, want)
+	}
+	if words := contentWords("The schedule is what they should measure", stopWords("en")); !reflect.DeepEqual(words, []string{"schedule", "measure"}) {
+		t.Errorf("English content words = %q, want schedule and measure", words)
+	}
+}
+
+func TestSplitSentences(t *testing.T) {
+	got := splitSentences("## Position\n- Spaces are safer. Tabs vary!\n1. Editors differ? Yes\nversion 1.2 is out.")
+	want := []string{"Position", "Spaces are safer.", "Tabs vary!", "Editors differ?", "Yes", "version 1.2 is out."}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitSentences = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,49 @@
+package summary
+
+import "strings"
+
+// englishStopWords and indonesianStopWords are function words that carry no
+// topic of their own and would otherwise dominate word frequencies. Words are
+// split at apostrophes, so contractions appear as their stems.
+var englishStopWords = words(`
+	a about above after again against all also am an and any are as at be because been before being
+	below between both but by can could did do does doing down during each even few for from further
+	had has have having he her here hers herself him himself his how however i if in into is it its
+	itself just let like may me might more most must my myself no nor not now of off on once only or
+	other our ours ourselves out over own same shall she should so some such than that the their
+	theirs them themselves then there these they this those through to too under until up us very
+	was we were what when where which while who whom why will with would yes yet you your yours
+	yourself yourselves don doesn didn isn aren wasn won re ll ve
+	one two well still much many really rather perhaps indeed thus therefore`)
+
+var indonesianStopWords = words(`
+	ada adalah agar akan aku anda antara apa apabila atas atau bagai bagaimana bagi bahkan bahwa
+	banyak baru beberapa begitu belum benar berapa berbagai bisa boleh bukan dalam dan dapat dari
+	daripada demikian dengan di dia dirinya ia ialah ini itu jadi jika juga kalau kami kamu karena
+	ke kemudian kepada kita lagi lain lebih maka masih mau melainkan meliputi mereka merupakan
+	namun oleh pada para saat saja sama sangat saya se sebagai sebelum sedang sehingga sejak
+	sekarang selain selalu seluruh semua sendiri seperti serta setelah siapa sudah supaya tanpa
+	tapi telah tentang tersebut tetapi tidak untuk walaupun yaitu yakni yang hal cara bila pun nya
+	kan lah sih dong ya tak`)
+
+// stopWords returns the stop words for a discussion language, or none when
+// the language has no list
+func stopWords(language string) map[string]bool {
+	switch strings.ToLower(strings.TrimSpace(language)) {
+	case "english", "en":
+		return englishStopWords
+	case "indonesian", "bahasa indonesia", "indonesia", "id":
+		return indonesianStopWords
+	default:
+		return nil
+	}
+}
+
+// words builds a set from a whitespace separated list
+func words(list string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(list) {
+		set[w] = true
+	}
+	return set
+}
@@ -0,0 +1,308 @@
+package transcript
+
+import (
+	"court-table-ai/pkg/database"
+	"court-table-ai/pkg/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// File names are transcript-YYYY-MM-DD.jsonl, with .N before the extension
+// for each further file written on the same day once the size cap is reached
+const (
+	filePrefix = "transcript-"
+	fileSuffix = ".jsonl"
+	dayLayout  = "2006-01-02"
+)
+
+// Entry is one line of the transcript log
+type Entry struct {
+	LogID         int64     `json:"log_id"`
+	DiscussionID  int64     `json:"discussion_id"`
+	Sequence      int64     `json:"sequence"`
+	AgentID       int64     `json:"agent_id"`
+	AgentName     string    `json:"agent_name,omitempty"`
+	IsModerator   bool      `json:"is_moderator"`
+	Round         int       `json:"round,omitempty"`
+	LogType       string    `json:"log_type"`
+	Status        string    `json:"status"`
+	Content       string    `json:"content,omitempty"`
+	ContentSHA256 string    `json:"content_sha256"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// Logger appends every discussion log entry to daily-rotated JSON lines
+// files. The files are never rewritten, so they remain an audit trail when
+// the database is edited or deleted.
+type Logger struct {
+	mu sync.Mutex
+
+	// Config returns the current settings; it is read on every write so
+	// changes apply without a restart
+	Config func() (models.TranscriptLogConfig, error)
+	// AgentName resolves an agent ID to a display name, if set
+	AgentName func(id int64) string
+
+	now func() time.Time
+
+	file      *os.File
+	dir       string
+	day       string
+	part      int
+	size      int64
+	cleanedOn string
+}
+
+// NewLogger creates a logger configured from the database settings
+func NewLogger(db *database.DB) *Logger {
+	return &Logger{
+		Config: db.GetTranscriptLogConfig,
+		AgentName: func(id int64) string {
+			if id == models.SystemAgentID {
+				return "system"
+			}
+			if agentName, err := db.GetAgentName(id); err == nil {
+				return agentName
+			}
+			return ""
+		},
+		now: time.Now,
+	}
+}
+
+// Record appends entry to the transcript log. Failures are logged and never
+// returned, so the debate carries on regardless.
+func (l *Logger) Record(entry *models.DiscussionLog) {
+	cfg, err := l.Config()
+	if err != nil {
+		log.Printf("Transcript log: failed to read settings: %v", err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !cfg.Enabled {
+		l.closeFile()
+		return
+	}
+
+	line, err := l.encode(cfg, entry)
+	if err != nil {
+		log.Printf("Transcript log: failed to encode entry %d: %v", entry.ID, err)
+		return
+	}
+
+	if err := l.write(cfg, line); err != nil {
+		log.Printf("Transcript log: failed to write entry %d: %v", entry.ID, err)
+		l.closeFile()
+	}
+}
+
+// Close closes the current file
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.closeFile()
+}
+
+// encode builds the JSON line for entry
+func (l *Logger) encode(cfg models.TranscriptLogConfig, entry *models.DiscussionLog) ([]byte, error) {
+	sum := sha256.Sum256([]byte(entry.Content))
+	e := Entry{
+		LogID:         entry.ID,
+		DiscussionID:  entry.DiscussionID,
+		Sequence:      entry.Sequence,
+		AgentID:       entry.AgentID,
+		IsModerator:   entry.IsModerator,
+		LogType:       entry.LogType,
+		Status:        entry.Status,
+		ContentSHA256: hex.EncodeToString(sum[:]),
+		Timestamp:     entry.CreatedAt,
+	}
+	if cfg.Content == models.TranscriptContentFull {
+		e.Content = entry.Content
+	}
+	if round, err := strconv.Atoi(entry.Metadata["round"]); err == nil {
+		e.Round = round
+	}
+	if l.AgentName != nil {
+		e.AgentName = l.AgentName(entry.AgentID)
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+// write appends line to the current file, rotating first when the day has
+// changed or the line would take the file past its size cap. Each line is
+// synced before returning so it survives a crash of the process.
+func (l *Logger) write(cfg models.TranscriptLogConfig, line []byte) error {
+	day := l.now().Format(dayLayout)
+	maxSize := int64(cfg.MaxFileMB) * 1024 * 1024
+
+	if l.file == nil || l.dir != cfg.Directory || l.day != day {
+		l.closeFile()
+		if err := l.open(cfg.Directory, day); err != nil {
+			return err
+		}
+	}
+	// A single line larger than the cap still gets a file of its own
+	if l.size > 0 && l.size+int64(len(line)) > maxSize {
+		l.closeFile()
+		l.part++
+		if err := l.openPart(); err != nil {
+			return err
+		}
+	}
+
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+
+	if l.cleanedOn != day {
+		l.cleanedOn = day
+		if err := cleanup(cfg.Directory, l.now(), cfg.RetentionDays); err != nil {
+			log.Printf("Transcript log: retention cleanup failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// open continues the newest file for day in dir, creating dir if needed
+func (l *Logger) open(dir, day string) error {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	files, err := listFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	l.dir, l.day, l.part = dir, day, 0
+	for _, f := range files {
+		if f.day == day && f.part > l.part {
+			l.part = f.part
+		}
+	}
+	return l.openPart()
+}
+
+// openPart opens the current part for appending
+func (l *Logger) openPart() error {
+	file, err := os.OpenFile(filepath.Join(l.dir, fileName(l.day, l.part)), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+// closeFile closes the current file, if any
+func (l *Logger) closeFile() error {
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// fileName returns the name of part of the transcript for day
+func fileName(day string, part int) string {
+	if part == 0 {
+		return filePrefix + day + fileSuffix
+	}
+	return fmt.Sprintf("%s%s.%d%s", filePrefix, day, part, fileSuffix)
+}
+
+// logFile is a transcript file found on disk
+type logFile struct {
+	name string
+	day  string
+	part int
+}
+
+// listFiles returns the transcript files in dir, oldest first. Other files
+// are ignored.
+func listFiles(dir string) ([]logFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list directory: %w", err)
+	}
+
+	var files []logFile
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+
+		stem := strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix)
+		day, partStr, hasPart := strings.Cut(stem, ".")
+		if _, err := time.Parse(dayLayout, day); err != nil {
+			continue
+		}
+		part := 0
+		if hasPart {
+			if part, err = strconv.Atoi(partStr); err != nil || part < 1 {
+				continue
+			}
+		}
+		files = append(files, logFile{name: name, day: day, part: part})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].day != files[j].day {
+			return files[i].day < files[j].day
+		}
+		return files[i].part < files[j].part
+	})
+	return files, nil
+}
+
+// cleanup removes transcript files for days more than retentionDays before now
+func cleanup(dir string, now time.Time, retentionDays int) error {
+	files, err := listFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	cutoff := now.AddDate(0, 0, -retentionDays).Format(dayLayout)
+	for _, f := range files {
+		if f.day >= cutoff {
+			break
+		}
+		if err := os.Remove(filepath.Join(dir, f.name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
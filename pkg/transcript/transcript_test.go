@@ -0,0 +1,208 @@
+package transcript
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"court-table-ai/pkg/models"
+)
+
+// newTestLogger returns a logger writing to a temp directory under cfg, with
+// a clock the test sets through the returned pointer
+func newTestLogger(t *testing.T, cfg models.TranscriptLogConfig) (*Logger, *time.Time, string) {
+	t.Helper()
+	if cfg.Directory == "" {
+		cfg.Directory = t.TempDir()
+	}
+	cfg.Enabled = true
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	l := &Logger{
+		Config:    func() (models.TranscriptLogConfig, error) { return cfg, nil },
+		AgentName: func(id int64) string { return "Alice" },
+		now:       func() time.Time { return now },
+	}
+	t.Cleanup(func() { l.Close() })
+	return l, &now, cfg.Directory
+}
+
+// fileNames lists the files in dir
+func fileNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+// readEntries decodes every line of a transcript file
+func readEntries(t *testing.T, path string) []Entry {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 4<<20)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("line %q is not JSON: %v", scanner.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestDailyRotation(t *testing.T) {
+	l, now, dir := newTestLogger(t, models.TranscriptLogConfig{})
+
+	*now = time.Date(2025, 6, 1, 23, 59, 59, 0, time.UTC)
+	l.Record(&models.DiscussionLog{ID: 1, DiscussionID: 7, Content: "Before midnight"})
+	*now = now.Add(time.Second)
+	l.Record(&models.DiscussionLog{ID: 2, DiscussionID: 7, Content: "After midnight"})
+	l.Record(&models.DiscussionLog{ID: 3, DiscussionID: 7, Content: "Later"})
+
+	got := strings.Join(fileNames(t, dir), " ")
+	if got != "transcript-2025-06-01.jsonl transcript-2025-06-02.jsonl" {
+		t.Fatalf("files = %s", got)
+	}
+	if n := len(readEntries(t, filepath.Join(dir, "transcript-2025-06-01.jsonl"))); n != 1 {
+		t.Errorf("first day has %d lines, want 1", n)
+	}
+	if n := len(readEntries(t, filepath.Join(dir, "transcript-2025-06-02.jsonl"))); n != 2 {
+		t.Errorf("second day has %d lines, want 2", n)
+	}
+}
+
+func TestSizeRotation(t *testing.T) {
+	l, _, dir := newTestLogger(t, models.TranscriptLogConfig{MaxFileMB: 1})
+
+	// Two of these do not fit in one megabyte
+	big := strings.Repeat("x", 600*1024)
+	for id := int64(1); id <= 3; id++ {
+		l.Record(&models.DiscussionLog{ID: id, Content: big})
+	}
+	got := strings.Join(fileNames(t, dir), " ")
+	if got != "transcript-2025-06-01.1.jsonl transcript-2025-06-01.2.jsonl transcript-2025-06-01.jsonl" {
+		t.Fatalf("files = %s", got)
+	}
+
+	// A restarted logger continues the newest part instead of the first
+	restarted, _, _ := newTestLogger(t, models.TranscriptLogConfig{Directory: dir, MaxFileMB: 1})
+	restarted.Record(&models.DiscussionLog{ID: 4, Content: "small"})
+	entries := readEntries(t, filepath.Join(dir, "transcript-2025-06-01.2.jsonl"))
+	if len(entries) != 2 || entries[1].LogID != 4 {
+		t.Errorf("newest part holds %d entries, want the third big one and the new one", len(entries))
+	}
+
+	// A line bigger than the cap still gets written
+	huge := strings.Repeat("y", 1100*1024)
+	restarted.Record(&models.DiscussionLog{ID: 5, Content: huge})
+	if entries := readEntries(t, filepath.Join(dir, "transcript-2025-06-01.3.jsonl")); len(entries) != 1 || entries[0].LogID != 5 {
+		t.Errorf("oversized line not written to a part of its own")
+	}
+}
+
+func TestRetentionCleanup(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"transcript-2025-05-20.jsonl",
+		"transcript-2025-05-20.1.jsonl",
+		"transcript-2025-05-26.jsonl",
+		"transcript-2025-05-27.jsonl",
+		"transcript-2025-05-30.jsonl",
+		"transcript-notadate.jsonl",
+		"notes.txt",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}\n"), 0o640); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+
+	l, now, _ := newTestLogger(t, models.TranscriptLogConfig{Directory: dir, RetentionDays: 5})
+	l.Record(&models.DiscussionLog{ID: 1, Content: "today"})
+
+	// Five days before 2025-06-01 is 2025-05-27, the oldest day kept
+	want := "notes.txt transcript-2025-05-27.jsonl transcript-2025-05-30.jsonl transcript-2025-06-01.jsonl transcript-notadate.jsonl"
+	if got := strings.Join(fileNames(t, dir), " "); got != want {
+		t.Errorf("after cleanup files = %s\nwant %s", got, want)
+	}
+
+	// Cleanup runs once a day, on the first write of the day
+	os.WriteFile(filepath.Join(dir, "transcript-2025-05-01.jsonl"), []byte("{}\n"), 0o640)
+	l.Record(&models.DiscussionLog{ID: 2, Content: "again today"})
+	if _, err := os.Stat(filepath.Join(dir, "transcript-2025-05-01.jsonl")); err != nil {
+		t.Errorf("second write of the day ran the cleanup again")
+	}
+	*now = now.AddDate(0, 0, 1)
+	l.Record(&models.DiscussionLog{ID: 3, Content: "tomorrow"})
+	if _, err := os.Stat(filepath.Join(dir, "transcript-2025-05-01.jsonl")); !os.IsNotExist(err) {
+		t.Errorf("first write of the next day kept an expired file")
+	}
+}
+
+func TestContentModes(t *testing.T) {
+	content := "Spaces, always."
+	sum := sha256.Sum256([]byte(content))
+	wantHash := hex.EncodeToString(sum[:])
+
+	for _, mode := range []string{models.TranscriptContentFull, models.TranscriptContentHash} {
+		l, _, dir := newTestLogger(t, models.TranscriptLogConfig{Content: mode})
+		l.Record(&models.DiscussionLog{
+			ID: 9, DiscussionID: 3, Sequence: 4, AgentID: 2, LogType: models.LogTypeResponse, Status: "success",
+			Content: content, Metadata: models.JSONMap{"round": "2"},
+		})
+
+		entries := readEntries(t, filepath.Join(dir, "transcript-2025-06-01.jsonl"))
+		if len(entries) != 1 {
+			t.Fatalf("%s: %d entries, want 1", mode, len(entries))
+		}
+		e := entries[0]
+		if e.ContentSHA256 != wantHash {
+			t.Errorf("%s: hash %s, want %s", mode, e.ContentSHA256, wantHash)
+		}
+		if e.LogID != 9 || e.DiscussionID != 3 || e.Sequence != 4 || e.Round != 2 || e.AgentName != "Alice" {
+			t.Errorf("%s: entry = %+v", mode, e)
+		}
+		wantContent := content
+		if mode == models.TranscriptContentHash {
+			wantContent = ""
+		}
+		if e.Content != wantContent {
+			t.Errorf("%s: content %q, want %q", mode, e.Content, wantContent)
+		}
+	}
+}
+
+func TestWriteFailureIsNotFatal(t *testing.T) {
+	// A file where the directory should be makes every write fail
+	blocker := filepath.Join(t.TempDir(), "blocked")
+	if err := os.WriteFile(blocker, nil, 0o640); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	l, _, _ := newTestLogger(t, models.TranscriptLogConfig{Directory: blocker})
+	l.Record(&models.DiscussionLog{ID: 1, Content: "lost"})
+	if l.file != nil {
+		t.Error("logger kept a file open after a failed write")
+	}
+
+	l.Config = func() (models.TranscriptLogConfig, error) { return models.TranscriptLogConfig{}, os.ErrPermission }
+	l.Record(&models.DiscussionLog{ID: 2, Content: "also lost"})
+}
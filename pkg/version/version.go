@@ -0,0 +1,7 @@
+package version
+
+// Version is the application version recorded with each discussion.
+// Override it at build time with:
+//
+//	go build -ldflags "-X court-table-ai/pkg/version.Version=v1.2.3" ./cmd
+var Version = "0.1.0-dev"